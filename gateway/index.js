@@ -1,15 +1,19 @@
 const express = require('express');
 const cors = require('cors');
 const helmet = require('helmet');
-const jwt = require('jsonwebtoken');
 const { ApolloServer } = require('@apollo/server');
 const { expressMiddleware } = require('@apollo/server/express4');
 const { ApolloGateway, IntrospectAndCompose } = require('@apollo/gateway');
 const { json } = require('body-parser');
 const fetch = require('node-fetch');
+const { createJwksVerifier } = require('./jwks');
+
+// Tokens are signed by the identity service and verified against the public
+// keys it publishes
+const verifyToken = createJwksVerifier(process.env.JWKS_URL || 'http://localhost:8001/.well-known/jwks.json');
 
 // Authentication middleware for GraphQL context
-function getGraphQLContext({ req }) {
+async function getGraphQLContext({ req }) {
   const token = req.headers.authorization?.replace('Bearer ', '');
 
   if (!token) {
@@ -20,7 +24,7 @@ function getGraphQLContext({ req }) {
   }
 
   try {
-    const decoded = jwt.verify(token, process.env.JWT_SECRET || 'your-secret-key');
+    const decoded = await verifyToken(token);
     return {
       user: decoded,
       isAuthenticated: true,
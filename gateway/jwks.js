@@ -0,0 +1,89 @@
+const crypto = require('crypto');
+const jwt = require('jsonwebtoken');
+const fetch = require('node-fetch');
+
+// Algorithms the identity service signs tokens with
+const ALGORITHMS = ['RS256', 'ES256'];
+
+// createJwksVerifier returns a function that verifies a token against the
+// keys the identity service publishes at jwksUrl. Keys are cached and the set
+// is refetched when it is older than ttlMs or a token names a key that is not
+// cached yet, so rotated keys are picked up without a restart. Fetches are
+// throttled to one every minRefreshDelayMs, and the cached keys stay in use
+// while the identity service is unreachable.
+function createJwksVerifier(jwksUrl, { ttlMs = 15 * 60 * 1000, minRefreshDelayMs = 10 * 1000, timeoutMs = 5000 } = {}) {
+  let keys = new Map();
+  let fetchedAt = 0;
+  let lastAttempt = 0;
+  let inflight = null;
+
+  async function fetchKeys() {
+    const response = await fetch(jwksUrl, { timeout: timeoutMs });
+    if (!response.ok) {
+      throw new Error(`failed to fetch JWKS: unexpected status ${response.status}`);
+    }
+    const set = await response.json();
+
+    const fetched = new Map();
+    for (const jwk of set.keys || []) {
+      if (jwk.use && jwk.use !== 'sig') {
+        continue;
+      }
+      try {
+        fetched.set(jwk.kid, { alg: jwk.alg, key: crypto.createPublicKey({ key: jwk, format: 'jwk' }) });
+      } catch (error) {
+        // Skip keys the gateway cannot use rather than rejecting the set
+      }
+    }
+    keys = fetched;
+    fetchedAt = Date.now();
+  }
+
+  function refresh() {
+    if (inflight) {
+      return inflight;
+    }
+    if (Date.now() - lastAttempt < minRefreshDelayMs) {
+      return Promise.resolve();
+    }
+    lastAttempt = Date.now();
+    inflight = fetchKeys().finally(() => {
+      inflight = null;
+    });
+    return inflight;
+  }
+
+  async function getKey(kid) {
+    let entry = keys.get(kid);
+    if (!entry || Date.now() - fetchedAt > ttlMs) {
+      try {
+        await refresh();
+      } catch (error) {
+        if (!entry) {
+          throw error;
+        }
+        console.warn('Using cached signing keys:', error.message);
+      }
+      entry = keys.get(kid);
+    }
+    if (!entry) {
+      throw new Error(`token signed with unknown key ${kid}`);
+    }
+    return entry;
+  }
+
+  return async function verify(token) {
+    const decoded = jwt.decode(token, { complete: true });
+    if (!decoded || !decoded.header.kid) {
+      throw new Error('token has no kid header');
+    }
+
+    const { alg, key } = await getKey(decoded.header.kid);
+    if (alg && alg !== decoded.header.alg) {
+      throw new Error('token algorithm does not match key');
+    }
+    return jwt.verify(token, key, { algorithms: ALGORITHMS });
+  };
+}
+
+module.exports = { createJwksVerifier };
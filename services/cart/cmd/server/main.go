@@ -2,17 +2,20 @@ package main
 
 import (
 	"context"
+	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 
 	"unified-commerce/services/cart/graphql"
 	"unified-commerce/services/cart/handlers"
-	"unified-commerce/services/cart/models"
+	"unified-commerce/services/cart/migrations"
 	"unified-commerce/services/cart/repository"
 	"unified-commerce/services/cart/service"
+	"unified-commerce/services/shared/database"
+	"unified-commerce/services/shared/middleware"
 	sharedService "unified-commerce/services/shared/service"
+	"unified-commerce/services/shared/tax"
 )
 
 func main() {
@@ -27,16 +30,30 @@ func main() {
 		panic("Failed to create base service: " + err.Error())
 	}
 
+	// Run the migrate subcommand instead of the server when requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := database.RunMigrateCommand(context.Background(), baseService.PostgresDB.DB, baseService.Name, migrations.All(), os.Args[2:], os.Stdout); err != nil {
+			baseService.Logger.WithError(err).Fatal("Migrate command failed")
+		}
+		return
+	}
+
 	// Run database migrations
-	if err := runMigrations(baseService.PostgresDB.DB); err != nil {
+	if _, err := baseService.PostgresDB.Migrate(context.Background(), baseService.Name, migrations.All()); err != nil {
 		baseService.Logger.WithError(err).Fatal("Failed to run database migrations")
 	}
 
+	// Load tax rules
+	taxEngine, err := tax.NewEngineFromFile(baseService.Config.TaxRulesFile)
+	if err != nil {
+		baseService.Logger.WithError(err).Fatal("Failed to load tax rules")
+	}
+
 	// Setup routes
-	setupRoutes(baseService.Router, baseService)
+	setupRoutes(baseService.Router, baseService, taxEngine)
 
 	// Start background tasks
-	go startBackgroundTasks(baseService)
+	go startBackgroundTasks(baseService, taxEngine)
 
 	baseService.Logger.Info("Cart Service started successfully")
 
@@ -47,18 +64,21 @@ func main() {
 }
 
 // setupRoutes configures the HTTP routes for the cart service
-func setupRoutes(router *gin.Engine, baseService *sharedService.BaseService) {
+func setupRoutes(router *gin.Engine, baseService *sharedService.BaseService, taxes tax.Calculator) {
 	// Initialize repositories
 	cartRepo := repository.NewCartRepository(baseService.PostgresDB.DB, baseService.Logger)
 
 	// Initialize services
-	cartService := service.NewCartService(cartRepo, baseService.Logger)
+	cartService := service.NewCartService(cartRepo, baseService.Logger, taxes)
 
 	// Initialize handlers
 	cartHandler := handlers.NewCartHandler(cartService, baseService.Logger)
 
+	// Deduplicate retried checkout completions by their Idempotency-Key
+	idempotencyStore := middleware.NewPostgresIdempotencyStore(baseService.PostgresDB.DB)
+
 	// Register routes
-	cartHandler.RegisterRoutes(router)
+	cartHandler.RegisterRoutes(router, baseService.RateLimiter, idempotencyStore)
 
 	// Add GraphQL endpoints
 	graphqlHandler := graphql.NewGraphQLHandler(cartService, baseService.Logger)
@@ -72,27 +92,12 @@ func setupRoutes(router *gin.Engine, baseService *sharedService.BaseService) {
 	}
 }
 
-// runMigrations runs database migrations
-func runMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&models.Cart{},
-		&models.CartLineItem{},
-		&models.CartTaxLine{},
-		&models.CartShippingLine{},
-		&models.CartDiscountApplication{},
-		&models.CartLineItemDiscountAllocation{},
-		&models.Checkout{},
-		&models.CheckoutEvent{},
-		&models.ShippingRate{},
-		&models.PaymentMethod{},
-	)
-}
-
 // startBackgroundTasks starts background tasks for cart management
-func startBackgroundTasks(baseService *sharedService.BaseService) {
+func startBackgroundTasks(baseService *sharedService.BaseService, taxes tax.Calculator) {
 	// Initialize repositories and services for background tasks
 	cartRepo := repository.NewCartRepository(baseService.PostgresDB.DB, baseService.Logger)
-	cartService := service.NewCartService(cartRepo, baseService.Logger)
+	cartService := service.NewCartService(cartRepo, baseService.Logger, taxes)
+	idempotencyStore := middleware.NewPostgresIdempotencyStore(baseService.PostgresDB.DB)
 
 	// Process abandoned carts every hour
 	abandonmentTicker := time.NewTicker(1 * time.Hour)
@@ -119,6 +124,11 @@ func startBackgroundTasks(baseService *sharedService.BaseService) {
 			} else {
 				baseService.Logger.Info("Expired cart cleanup completed")
 			}
+
+			// Forget idempotency keys past their retention
+			if _, err := idempotencyStore.DeleteExpired(context.Background()); err != nil {
+				baseService.Logger.WithError(err).Error("Failed to delete expired idempotency keys")
+			}
 		}
 	}
 }
@@ -30,14 +30,15 @@ func NewCartHandler(service *service.CartService, logger *logger.Logger) *CartHa
 	}
 }
 
-// RegisterRoutes registers all cart and checkout routes
-func (h *CartHandler) RegisterRoutes(router *gin.Engine) {
+// RegisterRoutes registers all cart and checkout routes. Checkout
+// completions are deduplicated by their Idempotency-Key in idempotency.
+func (h *CartHandler) RegisterRoutes(router *gin.Engine, rateLimiter *middleware.RateLimiter, idempotency middleware.IdempotencyStore) {
 	v1 := router.Group("/api/v1")
 	{
 		// Protected routes (authentication required)
 		authConfig := middleware.DefaultAuthConfig()
 		protected := v1.Group("/")
-		protected.Use(middleware.JWTAuth(authConfig))
+		protected.Use(middleware.JWTAuth(authConfig), middleware.RateLimit(rateLimiter, middleware.UserRateLimitConfig()))
 		{
 			// Cart management
 			carts := protected.Group("/carts")
@@ -66,7 +67,7 @@ func (h *CartHandler) RegisterRoutes(router *gin.Engine) {
 				checkouts.POST("/:id/shipping-lines", h.AddShippingLine)
 				checkouts.POST("/:id/discounts", h.ApplyDiscountCode)
 				checkouts.DELETE("/:id/discounts/:code", h.RemoveDiscountCode)
-				checkouts.POST("/:id/complete", h.CompleteCheckout)
+				checkouts.POST("/:id/complete", middleware.Idempotency(idempotency, middleware.DefaultIdempotencyConfig()), h.CompleteCheckout)
 
 				// Checkout by token
 				checkouts.GET("/token/:token", h.GetCheckoutByToken)
@@ -90,6 +91,7 @@ func (h *CartHandler) RegisterRoutes(router *gin.Engine) {
 
 		// Public routes for guest carts
 		public := v1.Group("/public")
+		public.Use(middleware.RateLimit(rateLimiter, middleware.PublicRateLimitConfig()))
 		{
 			public.POST("/carts", h.CreateCart)
 			public.GET("/carts/session/:sessionId", h.GetCartBySession)
@@ -0,0 +1,60 @@
+// Package migrations holds the cart service's versioned schema
+// migrations. Add a migration for every schema change and never edit one
+// that has been released.
+package migrations
+
+import (
+	"unified-commerce/services/cart/migrations/baseline"
+	"unified-commerce/services/shared/database"
+)
+
+// All returns the cart service's migrations
+func All() []database.Migration {
+	return []database.Migration{
+		{
+			// Tables as AutoMigrate created them before versioned
+			// migrations, which adopts existing databases unchanged. The
+			// models are frozen in the baseline package.
+			Version: 1,
+			Name:    "baseline",
+			Up:      database.AutoMigrateModels(baseline.Models()...),
+			Down:    database.DropModels(baseline.Models()...),
+		},
+		{
+			// Tax classes and tax-inclusive pricing
+			Version: 2,
+			Name:    "cart_taxes",
+			Up: database.ExecSQL(
+				`ALTER TABLE carts ADD COLUMN IF NOT EXISTS taxes_included BOOLEAN DEFAULT false`,
+				`ALTER TABLE cart_line_items ADD COLUMN IF NOT EXISTS tax_class TEXT`,
+			),
+			Down: database.ExecSQL(
+				`ALTER TABLE cart_line_items DROP COLUMN IF EXISTS tax_class`,
+				`ALTER TABLE carts DROP COLUMN IF EXISTS taxes_included`,
+			),
+		},
+		{
+			// Idempotency keys of retried checkout completions and their
+			// stored responses
+			Version: 3,
+			Name:    "idempotency_keys",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS idempotency_keys (
+					scope VARCHAR(100) NOT NULL,
+					key VARCHAR(255) NOT NULL,
+					fingerprint VARCHAR(64) NOT NULL,
+					status BIGINT NOT NULL DEFAULT 0,
+					content_type VARCHAR(100),
+					body BYTEA,
+					created_at TIMESTAMPTZ NOT NULL,
+					expires_at TIMESTAMPTZ NOT NULL,
+					PRIMARY KEY (scope, key)
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS idempotency_keys`,
+			),
+		},
+	}
+}
@@ -32,6 +32,7 @@ type Cart struct {
 	TotalShipping float64 `json:"total_shipping" gorm:"type:decimal(12,2);default:0"`
 	TotalDiscount float64 `json:"total_discount" gorm:"type:decimal(12,2);default:0"`
 	TotalPrice    float64 `json:"total_price" gorm:"type:decimal(12,2);default:0"`
+	TaxesIncluded bool    `json:"taxes_included" gorm:"default:false"`
 
 	// Checkout Information
 	CheckoutStep     CheckoutStep `json:"checkout_step" gorm:"default:'cart'"`
@@ -128,6 +129,7 @@ type CartLineItem struct {
 	// Discounts and Tax
 	TotalDiscount float64 `json:"total_discount" gorm:"type:decimal(10,2);default:0"`
 	Taxable       bool    `json:"taxable" gorm:"default:true"`
+	TaxClass      string  `json:"tax_class"`
 
 	// Fulfillment
 	RequiresShipping bool `json:"requires_shipping" gorm:"default:true"`
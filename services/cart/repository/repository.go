@@ -513,8 +513,20 @@ func (r *CartRepository) recalculateCartTotals(tx *gorm.DB, cartID uuid.UUID) er
 		return err
 	}
 
+	// Tax-inclusive prices already contain the tax
+	var taxesIncluded bool
+	if err := tx.Model(&models.Cart{}).
+		Where("id = ?", cartID).
+		Select("taxes_included").
+		Scan(&taxesIncluded).Error; err != nil {
+		return err
+	}
+
 	// Update cart totals
-	totalPrice := subtotal + totalTax + totalShipping
+	totalPrice := subtotal + totalShipping
+	if !taxesIncluded {
+		totalPrice += totalTax
+	}
 	return tx.Model(&models.Cart{}).
 		Where("id = ?", cartID).
 		Updates(map[string]interface{}{
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"unified-commerce/services/cart/models"
 	"unified-commerce/services/cart/repository"
 	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/tax"
 )
 
 // Service errors
@@ -33,13 +35,15 @@ var (
 type CartService struct {
 	repo   *repository.CartRepository
 	logger *logger.Logger
+	taxes  tax.Calculator
 }
 
 // NewCartService creates a new cart service
-func NewCartService(repo *repository.CartRepository, logger *logger.Logger) *CartService {
+func NewCartService(repo *repository.CartRepository, logger *logger.Logger, taxes tax.Calculator) *CartService {
 	return &CartService{
 		repo:   repo,
 		logger: logger,
+		taxes:  taxes,
 	}
 }
 
@@ -47,10 +51,11 @@ func NewCartService(repo *repository.CartRepository, logger *logger.Logger) *Car
 
 // CreateCartRequest represents a request to create a cart
 type CreateCartRequest struct {
-	SessionID  string     `json:"session_id"`
-	CustomerID *uuid.UUID `json:"customer_id"`
-	MerchantID uuid.UUID  `json:"merchant_id" validate:"required"`
-	Currency   string     `json:"currency"`
+	SessionID     string     `json:"session_id"`
+	CustomerID    *uuid.UUID `json:"customer_id"`
+	MerchantID    uuid.UUID  `json:"merchant_id" validate:"required"`
+	Currency      string     `json:"currency"`
+	TaxesIncluded bool       `json:"taxes_included"`
 }
 
 // CreateCart creates a new shopping cart
@@ -64,12 +69,13 @@ func (s *CartService) CreateCart(ctx context.Context, req *CreateCartRequest) (*
 	expiresAt := time.Now().Add(30 * 24 * time.Hour)
 
 	cart := &models.Cart{
-		SessionID:  req.SessionID,
-		CustomerID: req.CustomerID,
-		MerchantID: req.MerchantID,
-		Status:     models.CartStatusActive,
-		Currency:   req.Currency,
-		ExpiresAt:  &expiresAt,
+		SessionID:     req.SessionID,
+		CustomerID:    req.CustomerID,
+		MerchantID:    req.MerchantID,
+		Status:        models.CartStatusActive,
+		Currency:      req.Currency,
+		TaxesIncluded: req.TaxesIncluded,
+		ExpiresAt:     &expiresAt,
 	}
 
 	if err := s.repo.CreateCart(ctx, cart); err != nil {
@@ -185,6 +191,13 @@ func (s *CartService) UpdateCart(ctx context.Context, id uuid.UUID, req *UpdateC
 		return nil, err
 	}
 
+	// Addresses decide which taxes apply
+	if req.BillingAddress != nil || req.ShippingAddress != nil {
+		if err := s.recalculateTaxes(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
 	s.logger.WithField("cart_id", id).Info("Cart updated successfully")
 	return cart, nil
 }
@@ -204,7 +217,8 @@ type AddLineItemRequest struct {
 	VariantTitle     string            `json:"variant_title"`
 	Vendor           string            `json:"vendor"`
 	ProductImage     string            `json:"product_image"`
-	Taxable          bool              `json:"taxable"`
+	Taxable          *bool             `json:"taxable"` // defaults to true
+	TaxClass         string            `json:"tax_class"`
 	RequiresShipping bool              `json:"requires_shipping"`
 	Properties       map[string]string `json:"properties"`
 }
@@ -234,7 +248,8 @@ func (s *CartService) AddLineItem(ctx context.Context, cartID uuid.UUID, req *Ad
 		VariantTitle:     req.VariantTitle,
 		Vendor:           req.Vendor,
 		ProductImage:     req.ProductImage,
-		Taxable:          req.Taxable,
+		Taxable:          req.Taxable == nil || *req.Taxable,
+		TaxClass:         req.TaxClass,
 		RequiresShipping: req.RequiresShipping,
 		Properties:       req.Properties,
 	}
@@ -244,9 +259,7 @@ func (s *CartService) AddLineItem(ctx context.Context, cartID uuid.UUID, req *Ad
 		return nil, err
 	}
 
-	// Get updated cart
-	_, err = s.GetCart(ctx, cartID)
-	if err != nil {
+	if err := s.recalculateTaxes(ctx, cartID); err != nil {
 		return nil, err
 	}
 
@@ -294,6 +307,10 @@ func (s *CartService) UpdateLineItem(ctx context.Context, lineItemID uuid.UUID,
 		return nil, err
 	}
 
+	if err := s.recalculateTaxes(ctx, lineItem.CartID); err != nil {
+		return nil, err
+	}
+
 	s.logger.WithField("line_item_id", lineItemID).Info("Line item updated successfully")
 	return lineItem, nil
 }
@@ -324,6 +341,10 @@ func (s *CartService) RemoveLineItem(ctx context.Context, lineItemID uuid.UUID)
 		return err
 	}
 
+	if err := s.recalculateTaxes(ctx, lineItem.CartID); err != nil {
+		return err
+	}
+
 	s.logger.WithField("line_item_id", lineItemID).Info("Line item removed successfully")
 	return nil
 }
@@ -465,6 +486,10 @@ func (s *CartService) UpdateCheckoutShippingAddress(ctx context.Context, checkou
 		return nil, err
 	}
 
+	if err := s.recalculateTaxes(ctx, cart.ID); err != nil {
+		return nil, err
+	}
+
 	// Update checkout step
 	checkout.CompletedStep = models.CheckoutStepShipping
 
@@ -504,6 +529,10 @@ func (s *CartService) AddShippingLine(ctx context.Context, checkoutID uuid.UUID,
 		return err
 	}
 
+	if err := s.recalculateTaxes(ctx, checkout.CartID); err != nil {
+		return err
+	}
+
 	s.logger.WithField("checkout_id", checkoutID).Info("Shipping line added successfully")
 	return nil
 }
@@ -555,6 +584,10 @@ func (s *CartService) ApplyDiscountCode(ctx context.Context, checkoutID uuid.UUI
 		return err
 	}
 
+	if err := s.recalculateTaxes(ctx, checkout.CartID); err != nil {
+		return err
+	}
+
 	// Create event
 	event := &models.CheckoutEvent{
 		CheckoutID:  checkout.ID,
@@ -586,6 +619,10 @@ func (s *CartService) RemoveDiscountCode(ctx context.Context, checkoutID uuid.UU
 		return err
 	}
 
+	if err := s.recalculateTaxes(ctx, checkout.CartID); err != nil {
+		return err
+	}
+
 	// Create event
 	event := &models.CheckoutEvent{
 		CheckoutID:  checkout.ID,
@@ -635,6 +672,87 @@ func (s *CartService) CompleteCheckout(ctx context.Context, checkoutID uuid.UUID
 	return orderID, nil
 }
 
+// Tax Calculation
+
+// shippingTaxLineID identifies shipping lines in tax requests
+const shippingTaxLineID = "shipping:"
+
+// recalculateTaxes replaces the tax lines of a cart with the taxes of its
+// line items and shipping lines, grouped by tax
+func (s *CartService) recalculateTaxes(ctx context.Context, cartID uuid.UUID) error {
+	cart, err := s.GetCart(ctx, cartID)
+	if err != nil {
+		return err
+	}
+
+	// Taxes are owed where the goods are shipped, or billed when nothing ships
+	address := cart.ShippingAddress
+	if address.Country == "" {
+		address = cart.BillingAddress
+	}
+
+	req := &tax.Request{
+		Address: tax.Address{
+			Country:    address.Country,
+			Province:   address.State,
+			PostalCode: address.PostalCode,
+		},
+		PricesIncludeTax: cart.TaxesIncluded,
+	}
+	for _, item := range cart.LineItems {
+		req.Lines = append(req.Lines, tax.Line{
+			ID:       item.ID.String(),
+			TaxClass: item.TaxClass,
+			Amount:   math.Max(item.LinePrice-item.TotalDiscount, 0),
+			Taxable:  item.Taxable,
+		})
+	}
+	for _, line := range cart.ShippingLines {
+		price := line.Price
+		if line.DiscountedPrice > 0 {
+			price = line.DiscountedPrice
+		}
+		req.Lines = append(req.Lines, tax.Line{
+			ID:       shippingTaxLineID + line.ID.String(),
+			TaxClass: tax.ShippingClass,
+			Amount:   price,
+			Taxable:  true,
+		})
+	}
+
+	result, err := s.taxes.Calculate(ctx, req)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to calculate cart taxes")
+		return err
+	}
+
+	// Carts keep one tax line per tax, summed over every line
+	var taxLines []models.CartTaxLine
+	index := make(map[string]int)
+	for _, line := range result.Lines {
+		for _, taxLine := range line.TaxLines {
+			key := fmt.Sprintf("%s:%v", taxLine.Title, taxLine.Rate)
+			if i, ok := index[key]; ok {
+				taxLines[i].Price = tax.Round(taxLines[i].Price + taxLine.Amount)
+				continue
+			}
+			index[key] = len(taxLines)
+			taxLines = append(taxLines, models.CartTaxLine{
+				CartID: cartID,
+				Title:  taxLine.Title,
+				Rate:   taxLine.Rate,
+				Price:  taxLine.Amount,
+			})
+		}
+	}
+
+	if err := s.repo.UpdateTaxLines(ctx, cartID, taxLines); err != nil {
+		s.logger.WithError(err).Error("Failed to update cart tax lines")
+		return err
+	}
+	return nil
+}
+
 // Utility Methods
 
 // generateCheckoutToken generates a unique checkout token
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -10,9 +12,11 @@ import (
 
 	"unified-commerce/services/identity/graphql"
 	"unified-commerce/services/identity/handlers"
+	"unified-commerce/services/identity/migrations"
 	"unified-commerce/services/identity/models"
 	"unified-commerce/services/identity/repository"
 	identityService "unified-commerce/services/identity/service"
+	"unified-commerce/services/shared/database"
 	"unified-commerce/services/shared/service"
 )
 
@@ -32,16 +36,24 @@ func main() {
 		log.Fatalf("Failed to create base service: %v", err)
 	}
 
-	// Setup routes after base service is initialized
-	setupRoutes(baseService.Router, baseService)
+	// Run the migrate subcommand instead of the server when requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := database.RunMigrateCommand(context.Background(), baseService.PostgresDB.DB, baseService.Name, migrations.All(), os.Args[2:], os.Stdout); err != nil {
+			baseService.Logger.WithError(err).Fatal("Migrate command failed")
+		}
+		return
+	}
 
 	// Run database migrations
-	repo := repository.NewRepository(baseService.PostgresDB)
-	if err := repo.Migrate(); err != nil {
+	if _, err := baseService.PostgresDB.Migrate(context.Background(), baseService.Name, migrations.All()); err != nil {
 		baseService.Logger.WithError(err).Fatal("Failed to run database migrations")
 	}
 
+	// Setup routes after base service is initialized
+	setupRoutes(baseService.Router, baseService)
+
 	// Seed initial data
+	repo := repository.NewRepository(baseService.PostgresDB)
 	if err := seedInitialData(repo); err != nil {
 		baseService.Logger.WithError(err).Warn("Failed to seed initial data")
 	}
@@ -64,8 +76,14 @@ func setupRoutes(router *gin.Engine, baseService *service.BaseService) {
 	// Create repository
 	repo := repository.NewRepository(baseService.PostgresDB)
 
+	// Load token signing keys
+	keyRing, err := loadKeyRing(baseService)
+	if err != nil {
+		baseService.Logger.WithError(err).Fatal("Failed to load token signing keys")
+	}
+
 	// Create service
-	identityService := identityService.NewIdentityService(repo, baseService.Logger, baseService.Config.JWTSecret, baseService.Tracer)
+	identityService := identityService.NewIdentityService(repo, baseService.Logger, keyRing, baseService.Tracer)
 
 	// Create handler
 	handler := handlers.NewIdentityHandler(identityService, baseService.Logger)
@@ -86,6 +104,21 @@ func setupRoutes(router *gin.Engine, baseService *service.BaseService) {
 	}
 }
 
+// loadKeyRing loads the token signing keys. Outside production a throwaway
+// key is generated when no key directory is configured.
+func loadKeyRing(baseService *service.BaseService) (*identityService.KeyRing, error) {
+	cfg := baseService.Config
+	if cfg.JWTSigningKeysDir != "" {
+		return identityService.LoadKeyRing(cfg.JWTSigningKeysDir, cfg.JWTSigningKeyID)
+	}
+	if cfg.Environment == "production" {
+		return nil, fmt.Errorf("JWT_SIGNING_KEYS_DIR must be set in production")
+	}
+
+	baseService.Logger.Warn("JWT_SIGNING_KEYS_DIR not set, signing tokens with an ephemeral key")
+	return identityService.NewEphemeralKeyRing()
+}
+
 // seedInitialData seeds the database with initial roles and permissions
 func seedInitialData(repo *repository.Repository) error {
 	ctx := context.Background()
@@ -135,6 +168,30 @@ func seedInitialData(repo *repository.Repository) error {
 		}
 	}
 
+	// Grant administrators every permission
+	allPermission := models.Permission{
+		Name:        "all",
+		DisplayName: "All permissions",
+		Description: "Every action on every resource",
+		Resource:    "*",
+		Action:      "*",
+		IsActive:    true,
+	}
+	db := repo.DB().DB.WithContext(ctx)
+	if err := db.Where("name = ?", allPermission.Name).FirstOrCreate(&allPermission).Error; err != nil {
+		return err
+	}
+	for _, roleName := range []string{"super_admin", "admin"} {
+		role, err := repo.Role.GetByName(ctx, roleName)
+		if err != nil {
+			return err
+		}
+		grant := models.RolePermission{RoleID: role.ID, PermissionID: allPermission.ID}
+		if err := db.Where(&grant).FirstOrCreate(&grant).Error; err != nil {
+			return err
+		}
+	}
+
 	// Seed default super admin user if not present
 	adminEmail := "admin@example.com"
 	if _, err := repo.User.GetByEmail(ctx, adminEmail); err != nil { // user not found
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 
@@ -28,6 +30,9 @@ func NewIdentityHandler(service *service.IdentityService, logger *logger.Logger)
 
 // RegisterRoutes registers all identity routes
 func (h *IdentityHandler) RegisterRoutes(router *gin.Engine) {
+	// Public keys for verifying tokens issued by this service
+	router.GET("/.well-known/jwks.json", h.GetJWKS)
+
 	v1 := router.Group("/api/v1")
 	{
 		// Public routes (no authentication required)
@@ -44,6 +49,7 @@ func (h *IdentityHandler) RegisterRoutes(router *gin.Engine) {
 
 		// Protected routes (authentication required)
 		authConfig := middleware.DefaultAuthConfig()
+		authConfig.KeySet = h.service.KeySet()
 		protected := v1.Group("/")
 		protected.Use(middleware.JWTAuth(authConfig))
 		{
@@ -71,6 +77,19 @@ func (h *IdentityHandler) RegisterRoutes(router *gin.Engine) {
 	}
 }
 
+// GetJWKS serves the JSON Web Key Set used by other services to verify tokens
+func (h *IdentityHandler) GetJWKS(c *gin.Context) {
+	jwks, err := h.service.JWKS()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to build JWKS")
+		httputil.InternalServerError(c, "Failed to load signing keys")
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwks)
+}
+
 // Register handles user registration
 func (h *IdentityHandler) Register(c *gin.Context) {
 	var req service.RegisterRequest
@@ -0,0 +1,24 @@
+// Package migrations holds the identity service's versioned schema
+// migrations. Add a migration for every schema change and never edit one
+// that has been released.
+package migrations
+
+import (
+	"unified-commerce/services/identity/migrations/baseline"
+	"unified-commerce/services/shared/database"
+)
+
+// All returns the identity service's migrations
+func All() []database.Migration {
+	return []database.Migration{
+		{
+			// Tables as AutoMigrate created them before versioned
+			// migrations, which adopts existing databases unchanged. The
+			// models are frozen in the baseline package.
+			Version: 1,
+			Name:    "baseline",
+			Up:      database.AutoMigrateModels(baseline.Models()...),
+			Down:    database.DropModels(baseline.Models()...),
+		},
+	}
+}
@@ -196,6 +196,30 @@ func (u *User) HasPermission(permissionName string) bool {
 	return false
 }
 
+// GetPermissions returns the user's active permissions as "resource:action"
+// strings, skipping roles that are inactive or have expired
+func (u *User) GetPermissions() []string {
+	seen := make(map[string]bool)
+	var permissions []string
+	for _, userRole := range u.Roles {
+		if !userRole.Role.IsActive || (userRole.ExpiresAt != nil && userRole.ExpiresAt.Before(time.Now())) {
+			continue
+		}
+		for _, rolePermission := range userRole.Role.Permissions {
+			permission := rolePermission.Permission
+			if !permission.IsActive || permission.Resource == "" || permission.Action == "" {
+				continue
+			}
+			key := permission.Resource + ":" + permission.Action
+			if !seen[key] {
+				seen[key] = true
+				permissions = append(permissions, key)
+			}
+		}
+	}
+	return permissions
+}
+
 // GetRoleNames returns a slice of role names for the user
 func (u *User) GetRoleNames() []string {
 	roleNames := make([]string, len(u.Roles))
@@ -331,23 +331,3 @@ func (r *Repository) DB() *database.PostgresDB {
 	return r.User.db
 }
 
-// Migrate runs database migrations for all models
-func (r *Repository) Migrate() error {
-	db := r.User.db.DB
-
-	// Drop merchant_members table if it exists (moved to Merchant Account service)
-	db.Exec("DROP TABLE IF EXISTS merchant_members CASCADE")
-
-	return db.AutoMigrate(
-		&models.User{},
-		&models.Role{},
-		&models.Permission{},
-		&models.UserRole{},
-		&models.RolePermission{},
-		&models.UserSession{},
-		// Note: MerchantMember is managed by the Merchant Account service
-		&models.PasswordReset{},
-		&models.EmailVerification{},
-		&models.AuditLog{},
-	)
-}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"unified-commerce/services/shared/middleware"
+)
+
+// Key ring errors
+var (
+	ErrNoSigningKeys      = errors.New("no signing keys found")
+	ErrSigningKeyNotFound = errors.New("signing key not found")
+)
+
+// SigningKey is a private key used to sign tokens
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+}
+
+// KeyRing holds the keys identity signs and verifies tokens with. Every key
+// in the ring is published in the JWKS and accepted for verification, but
+// only the signing key issues new tokens. To rotate, add a new key, switch
+// the signing key to it and remove the old key once its tokens have expired.
+type KeyRing struct {
+	signing *SigningKey
+	keys    map[string]*SigningKey
+}
+
+// LoadKeyRing loads every <kid>.pem private key in dir. RSA keys sign with
+// RS256 and P-256 keys with ES256. If signingKeyID is empty, the last kid in
+// sort order signs, so date-prefixed kids rotate by adding a file.
+func LoadKeyRing(dir, signingKeyID string) (*KeyRing, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoSigningKeys, dir)
+	}
+	sort.Strings(paths)
+
+	var keys []*SigningKey
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key %s: %w", path, err)
+		}
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := ParseSigningKey(kid, data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if signingKeyID == "" {
+		signingKeyID = keys[len(keys)-1].ID
+	}
+	return NewKeyRing(signingKeyID, keys...)
+}
+
+// NewKeyRing creates a key ring that signs with the key named signingKeyID
+func NewKeyRing(signingKeyID string, keys ...*SigningKey) (*KeyRing, error) {
+	ring := &KeyRing{keys: make(map[string]*SigningKey, len(keys))}
+	for _, key := range keys {
+		ring.keys[key.ID] = key
+	}
+
+	signing, ok := ring.keys[signingKeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSigningKeyNotFound, signingKeyID)
+	}
+	ring.signing = signing
+	return ring, nil
+}
+
+// NewEphemeralKeyRing generates a single ES256 key. Tokens signed with it
+// stop validating when the process restarts, so it is only meant for local
+// development.
+func NewEphemeralKeyRing() (*KeyRing, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	key := &SigningKey{
+		ID:         fmt.Sprintf("ephemeral-%d", time.Now().Unix()),
+		Method:     jwt.SigningMethodES256,
+		PrivateKey: privateKey,
+	}
+	return NewKeyRing(key.ID, key)
+}
+
+// ParseSigningKey parses a PEM-encoded RSA or P-256 private key
+func ParseSigningKey(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("signing key %s is not PEM encoded", kid)
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("signing key %s has unsupported PEM type %s", kid, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", kid, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("signing key %s: RSA keys must be at least 2048 bits", kid)
+		}
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodRS256, PrivateKey: k}, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("signing key %s: only P-256 EC keys are supported", kid)
+		}
+		return &SigningKey{ID: kid, Method: jwt.SigningMethodES256, PrivateKey: k}, nil
+	default:
+		return nil, fmt.Errorf("signing key %s has unsupported type %T", kid, parsed)
+	}
+}
+
+// SigningKeyID returns the kid of the key that signs new tokens
+func (r *KeyRing) SigningKeyID() string {
+	return r.signing.ID
+}
+
+// Sign signs the claims with the current signing key
+func (r *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(r.signing.Method, claims)
+	token.Header["kid"] = r.signing.ID
+	return token.SignedString(r.signing.PrivateKey)
+}
+
+// Keyfunc implements middleware.KeySet
+func (r *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, middleware.ErrMissingKeyID
+	}
+
+	key, ok := r.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", middleware.ErrUnknownKeyID, kid)
+	}
+	if key.Method.Alg() != token.Method.Alg() {
+		return nil, middleware.ErrAlgorithmInvalid
+	}
+	return key.PrivateKey.Public(), nil
+}
+
+// JWKS returns the public keys of the ring
+func (r *KeyRing) JWKS() (*middleware.JWKS, error) {
+	ids := make([]string, 0, len(r.keys))
+	for id := range r.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	set := &middleware.JWKS{Keys: make([]middleware.JWK, 0, len(ids))}
+	for _, id := range ids {
+		jwk, err := middleware.NewJWK(id, r.keys[id].PrivateKey.Public())
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
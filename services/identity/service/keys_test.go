@@ -0,0 +1,97 @@
+package service_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"unified-commerce/services/identity/service"
+	"unified-commerce/services/shared/middleware"
+)
+
+func newRSAKey(t *testing.T, kid string) *service.SigningKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return &service.SigningKey{ID: kid, Method: jwt.SigningMethodRS256, PrivateKey: privateKey}
+}
+
+func testClaims() *middleware.JWTClaims {
+	return &middleware.JWTClaims{
+		UserID: "user-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+func TestKeyRing_TokensVerifyAgainstPublishedJWKS(t *testing.T) {
+	ring, err := service.NewEphemeralKeyRing()
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jwks, err := ring.JWKS()
+		require.NoError(t, err)
+		json.NewEncoder(w).Encode(jwks)
+	}))
+	defer server.Close()
+
+	tokenString, err := ring.Sign(testClaims())
+	require.NoError(t, err)
+
+	claims := &middleware.JWTClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, middleware.NewJWKSCache(server.URL, time.Minute).Keyfunc,
+		jwt.WithValidMethods(middleware.SupportedSigningMethods))
+	require.NoError(t, err)
+	assert.True(t, token.Valid)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.Equal(t, ring.SigningKeyID(), token.Header["kid"])
+}
+
+func TestKeyRing_RotationKeepsOldTokensValid(t *testing.T) {
+	oldKey := newRSAKey(t, "2024-01")
+	newKey := newRSAKey(t, "2024-06")
+
+	before, err := service.NewKeyRing("2024-01", oldKey)
+	require.NoError(t, err)
+	oldToken, err := before.Sign(testClaims())
+	require.NoError(t, err)
+
+	after, err := service.NewKeyRing("2024-06", oldKey, newKey)
+	require.NoError(t, err)
+	newToken, err := after.Sign(testClaims())
+	require.NoError(t, err)
+
+	for _, tokenString := range []string{oldToken, newToken} {
+		_, err := jwt.ParseWithClaims(tokenString, &middleware.JWTClaims{}, after.Keyfunc,
+			jwt.WithValidMethods(middleware.SupportedSigningMethods))
+		assert.NoError(t, err)
+	}
+
+	// Once the old key is removed, tokens it signed are rejected
+	retired, err := service.NewKeyRing("2024-06", newKey)
+	require.NoError(t, err)
+	_, err = jwt.ParseWithClaims(oldToken, &middleware.JWTClaims{}, retired.Keyfunc)
+	assert.ErrorIs(t, err, middleware.ErrUnknownKeyID)
+}
+
+func TestKeyRing_RejectsHS256Tokens(t *testing.T) {
+	ring, err := service.NewEphemeralKeyRing()
+	require.NoError(t, err)
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	forged.Header["kid"] = ring.SigningKeyID()
+	tokenString, err := forged.SignedString([]byte("unified-commerce-jwt-secret-key"))
+	require.NoError(t, err)
+
+	_, err = jwt.ParseWithClaims(tokenString, &middleware.JWTClaims{}, ring.Keyfunc,
+		jwt.WithValidMethods(middleware.SupportedSigningMethods))
+	assert.Error(t, err)
+}
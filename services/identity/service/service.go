@@ -31,19 +31,19 @@ var (
 
 // IdentityService handles authentication and authorization business logic
 type IdentityService struct {
-	repo      *repository.Repository
-	logger    *logger.Logger
-	jwtSecret string
-	tracer    interface{} // This would be *service.Tracer but we can"t import it here due to circular dependency
+	repo   *repository.Repository
+	logger *logger.Logger
+	keys   *KeyRing
+	tracer interface{} // This would be *service.Tracer but we can"t import it here due to circular dependency
 }
 
 // NewIdentityService creates a new identity service
-func NewIdentityService(repo *repository.Repository, logger *logger.Logger, jwtSecret string, tracer interface{}) *IdentityService {
+func NewIdentityService(repo *repository.Repository, logger *logger.Logger, keys *KeyRing, tracer interface{}) *IdentityService {
 	return &IdentityService{
-		repo:      repo,
-		logger:    logger,
-		jwtSecret: jwtSecret,
-		tracer:    tracer,
+		repo:   repo,
+		logger: logger,
+		keys:   keys,
+		tracer: tracer,
 	}
 }
 
@@ -250,9 +250,8 @@ func (s *IdentityService) Logout(ctx context.Context, userID, accessToken string
 func (s *IdentityService) ValidateToken(ctx context.Context, tokenString string) (*models.User, error) {
 	claims := &middleware.JWTClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.jwtSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keys.Keyfunc,
+		jwt.WithValidMethods(middleware.SupportedSigningMethods))
 
 	if err != nil {
 		return nil, ErrInvalidToken
@@ -332,9 +331,10 @@ func (s *IdentityService) ChangePassword(ctx context.Context, userID, oldPasswor
 func (s *IdentityService) generateTokens(user *models.User) (string, string, error) {
 	// Access token (1 hour)
 	accessClaims := &middleware.JWTClaims{
-		UserID: user.ID,
-		Email:  user.Email,
-		Roles:  user.GetRoleNames(),
+		UserID:      user.ID,
+		Email:       user.Email,
+		Roles:       user.GetRoleNames(),
+		Permissions: user.GetPermissions(),
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -342,8 +342,7 @@ func (s *IdentityService) generateTokens(user *models.User) (string, string, err
 		},
 	}
 
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString([]byte(s.jwtSecret))
+	accessTokenString, err := s.keys.Sign(accessClaims)
 	if err != nil {
 		return "", "", err
 	}
@@ -359,8 +358,7 @@ func (s *IdentityService) generateTokens(user *models.User) (string, string, err
 		},
 	}
 
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString([]byte(s.jwtSecret))
+	refreshTokenString, err := s.keys.Sign(refreshClaims)
 	if err != nil {
 		return "", "", err
 	}
@@ -368,6 +366,16 @@ func (s *IdentityService) generateTokens(user *models.User) (string, string, err
 	return accessTokenString, refreshTokenString, nil
 }
 
+// JWKS returns the public keys used to verify tokens issued by this service
+func (s *IdentityService) JWKS() (*middleware.JWKS, error) {
+	return s.keys.JWKS()
+}
+
+// KeySet returns the key set used to verify tokens issued by this service
+func (s *IdentityService) KeySet() middleware.KeySet {
+	return s.keys
+}
+
 // hashToken creates a hash of a token for storage
 func (s *IdentityService) hashToken(token string) string {
 	hash := sha256.Sum256([]byte(token))
@@ -453,9 +461,8 @@ func (s *IdentityService) DeleteUser(ctx context.Context, userID string) error {
 func (s *IdentityService) RefreshToken(ctx context.Context, refreshTokenString string) (*AuthResponse, error) {
 	claims := &middleware.JWTClaims{}
 
-	token, err := jwt.ParseWithClaims(refreshTokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.jwtSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(refreshTokenString, claims, s.keys.Keyfunc,
+		jwt.WithValidMethods(middleware.SupportedSigningMethods))
 
 	if err != nil {
 		return nil, ErrInvalidToken
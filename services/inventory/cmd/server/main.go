@@ -9,18 +9,18 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 
 	"unified-commerce/services/inventory/eventhandlers"
 	"unified-commerce/services/inventory/graphql"
 	"unified-commerce/services/inventory/handlers"
-	"unified-commerce/services/inventory/models"
+	"unified-commerce/services/inventory/migrations"
 	"unified-commerce/services/inventory/repository"
 	"unified-commerce/services/inventory/service"
 	"unified-commerce/services/shared/config"
 	"unified-commerce/services/shared/database"
 	"unified-commerce/services/shared/logger"
 	"unified-commerce/services/shared/middleware"
+	"unified-commerce/services/shared/tenant"
 	"unified-commerce/shared/messaging"
 )
 
@@ -52,25 +52,60 @@ func main() {
 	}
 	defer postgresDB.Close()
 
+	// Connect to Redis for rate limiting; requests are not limited without it
+	redisClient, err := database.NewRedisConnection(database.NewRedisConfigFromEnv(
+		cfg.RedisURL,
+		cfg.RedisPassword,
+		cfg.RedisDB,
+	))
+	if err != nil {
+		log.WithError(err).Warn("Failed to connect to Redis, continuing without rate limiting")
+	} else {
+		defer redisClient.Close()
+	}
+	rateLimiter := middleware.NewRateLimiter(redisClient)
+
+	// Run the migrate subcommand instead of the server when requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := database.RunMigrateCommand(context.Background(), postgresDB.DB, "inventory", migrations.All(), os.Args[2:], os.Stdout); err != nil {
+			log.WithError(err).Fatal("Migrate command failed")
+		}
+		return
+	}
+
 	// Run database migrations
-	if err := runMigrations(postgresDB.DB); err != nil {
+	if _, err := postgresDB.Migrate(context.Background(), "inventory", migrations.All()); err != nil {
 		log.WithError(err).Fatal("Failed to run database migrations")
 	}
 
 	// Initialize repositories
 	inventoryRepo := repository.NewInventoryRepository(postgresDB.DB, log)
 
+	// Load event schemas
+	schemaRegistry, err := messaging.DefaultSchemaRegistry()
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load event schemas")
+	}
+
 	// Initialize services
-	inventoryService := service.NewInventoryService(inventoryRepo, log)
+	inventoryService := service.NewInventoryService(inventoryRepo, log, schemaRegistry)
 
 	// Initialize event handlers
-	orderEventHandler := eventhandlers.NewOrderEventHandler(inventoryService, log)
+	orderEventHandler := eventhandlers.NewOrderEventHandler(inventoryService, schemaRegistry, log)
+	merchantEventHandler := eventhandlers.NewMerchantEventHandler(inventoryService, schemaRegistry, log)
+	eventHandlers := messaging.TopicHandlers{
+		"orders.placed":        orderEventHandler,
+		"returns.received":     orderEventHandler,
+		"draft_orders.updated": orderEventHandler,
+		"merchants.updated":    merchantEventHandler,
+	}
 
 	// Initialize Event Consumer
 	consumerConfig := messaging.ConsumerConfig{
+		Driver:    cfg.MessagingDriver,
 		Brokers:   cfg.KafkaBrokers,
 		GroupID:   "inventory-service",
-		Topics:    []string{"orders.placed"},
+		Topics:    eventHandlers.Topics(),
 		UseDocker: messaging.DetectEnvironment(),
 	}
 	consumer, err := messaging.NewEventConsumer(consumerConfig)
@@ -81,8 +116,34 @@ func main() {
 	}
 	defer consumer.Close()
 
+	// Initialize Event Producer for dead letters and outbox events
+	producer, err := messaging.NewEventProducer(messaging.ProducerConfig{
+		Driver:    cfg.MessagingDriver,
+		Brokers:   cfg.KafkaBrokers,
+		UseDocker: messaging.DetectEnvironment(),
+	})
+	if err != nil {
+		log.WithError(err).Warn("Failed to create event producer, using no-op producer for graceful degradation")
+		producer = messaging.NewNoOpProducer()
+	}
+	defer producer.Close()
+
 	// Start consuming messages in a separate goroutine
-	go startEventConsumption(consumer, orderEventHandler, log)
+	consumerCtx, stopConsumer := context.WithCancel(context.Background())
+	defer stopConsumer()
+	// Skip redelivered events; the processed-event record commits with the stock change
+	processedEvents := messaging.NewPostgresProcessedEventStore(postgresDB.DB)
+	idempotentHandler := messaging.NewIdempotentHandler(processedEvents, consumerConfig.GroupID, eventHandlers)
+	consumerRunner := messaging.NewConsumerRunner(consumer, idempotentHandler, producer, consumerConfig.GroupID, messaging.DefaultRetryPolicy())
+	go startEventConsumption(consumerCtx, consumerRunner, consumerConfig.Topics, log)
+	// Forget processed events once they can no longer be redelivered
+	go processedEvents.RunPurge(consumerCtx, time.Hour, messaging.DefaultProcessedEventRetention)
+
+	// Relay outbox events, such as routed fulfillments, to the event stream
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	outboxRelay := messaging.NewOutboxRelay(postgresDB.DB, messaging.NewValidatingProducer(producer, schemaRegistry), messaging.DefaultOutboxRelayConfig())
+	go outboxRelay.Run(relayCtx)
 
 	// Initialize handlers
 	inventoryHandler := handlers.NewInventoryHandler(inventoryService, log)
@@ -92,13 +153,18 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.New()
+	// Only trust X-Forwarded-For from our own proxies, so clients cannot
+	// choose their IP
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.WithError(err).Fatal("Invalid trusted proxies")
+	}
 
 	// Add middleware
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 	router.Use(middleware.CORS())
 	router.Use(middleware.RequestID())
-	router.Use(middleware.RateLimit())
+	router.Use(middleware.RateLimit(rateLimiter, middleware.DefaultRateLimitConfig()))
 
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
@@ -120,7 +186,7 @@ func main() {
 	graphqlHandler := graphql.NewGraphQLHandler(inventoryService, log)
 	playgroundHandler := graphql.NewPlaygroundHandler()
 
-	router.Any("/graphql", gin.WrapH(graphqlHandler))
+	router.Any("/graphql", middleware.OptionalJWTAuth(middleware.DefaultAuthConfig()), middleware.Tenant(), gin.WrapH(graphqlHandler))
 	router.GET("/graphql/playground", gin.WrapH(playgroundHandler))
 
 	// Start server
@@ -161,19 +227,6 @@ func main() {
 	log.Info("Inventory Service stopped")
 }
 
-// runMigrations runs database migrations
-func runMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&models.Location{},
-		&models.InventoryItem{},
-		&models.StockMovement{},
-		&models.StockReservation{},
-		&models.StockTransfer{},
-		&models.StockTransferItem{},
-		&models.StockAlert{},
-	)
-}
-
 // checkPostgreSQL checks PostgreSQL connection health
 func checkPostgreSQL(db *database.PostgresDB) string {
 	sqlDB, err := db.DB.DB()
@@ -187,31 +240,12 @@ func checkPostgreSQL(db *database.PostgresDB) string {
 	return "healthy"
 }
 
-// startEventConsumption handles event consumption from the messaging system
-func startEventConsumption(consumer messaging.EventConsumer, handler *eventhandlers.OrderEventHandler, log *logger.Logger) {
-	if err := consumer.Subscribe([]string{"orders.placed"}); err != nil {
-		log.WithError(err).Error("Failed to subscribe to topics, event consumption disabled")
-		return
-	}
-
+// startEventConsumption handles event consumption from the messaging system.
+// Messages that keep failing are moved to their dead-letter topic.
+func startEventConsumption(ctx context.Context, runner *messaging.ConsumerRunner, topics []string, log *logger.Logger) {
 	log.Info("Starting event consumption for inventory service")
-	for {
-		msg, err := consumer.ReadMessage()
-		if err != nil {
-			log.WithError(err).Error("Failed to read message")
-			time.Sleep(1 * time.Second)
-			continue
-		}
-
-		// Convert to the format expected by the handler
-		if err := handler.HandleOrderPlacedEvent(msg.Value); err != nil {
-			log.WithError(err).Error("Failed to handle order placed event")
-		} else {
-			log.Info("Successfully processed order placed event")
-			if err := consumer.CommitMessage(msg); err != nil {
-				log.WithError(err).Error("Failed to commit message")
-			}
-		}
+	if err := runner.Run(ctx, topics); err != nil && err != context.Canceled {
+		log.WithError(err).Error("Event consumption stopped")
 	}
 }
 
@@ -224,7 +258,7 @@ func startBackgroundTasks(service *service.InventoryService, log *logger.Logger)
 	for {
 		select {
 		case <-ticker.C:
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			ctx, cancel := context.WithTimeout(tenant.WithoutScope(context.Background()), 30*time.Second)
 			if err := service.ProcessExpiredReservations(ctx); err != nil {
 				log.WithError(err).Error("Failed to process expired reservations")
 			}
@@ -0,0 +1,89 @@
+package eventhandlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/inventory/service"
+	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/sequence"
+	"unified-commerce/services/shared/tenant"
+	"unified-commerce/shared/messaging"
+)
+
+// MerchantEventHandler handles events published by the merchant account
+// service
+type MerchantEventHandler struct {
+	inventoryService *service.InventoryService
+	schemas          *messaging.SchemaRegistry
+	log              *logger.Logger
+}
+
+// NewMerchantEventHandler creates a new MerchantEventHandler
+func NewMerchantEventHandler(inventoryService *service.InventoryService, schemas *messaging.SchemaRegistry, log *logger.Logger) *MerchantEventHandler {
+	return &MerchantEventHandler{
+		inventoryService: inventoryService,
+		schemas:          schemas,
+		log:              log,
+	}
+}
+
+// MerchantUpdatedEvent represents the merchant.updated v1 event, which
+// carries the formats the merchant's documents are numbered with
+type MerchantUpdatedEvent struct {
+	MerchantID    uuid.UUID              `json:"merchant_id"`
+	OrderIDPrefix string                 `json:"order_id_prefix"`
+	NumberFormats []sequence.NamedFormat `json:"number_formats"`
+}
+
+// HandleMessage implements messaging.MessageHandler, dispatching on topic
+func (h *MerchantEventHandler) HandleMessage(msg *messaging.Message) error {
+	return h.HandleMessageContext(context.Background(), msg)
+}
+
+// HandleMessageContext implements messaging.ContextMessageHandler. When the
+// context carries a deduplication transaction, the formats are saved inside
+// it.
+func (h *MerchantEventHandler) HandleMessageContext(ctx context.Context, msg *messaging.Message) error {
+	switch msg.Topic {
+	case "merchants.updated":
+		return h.handleMerchantUpdatedEvent(ctx, msg.Value)
+	default:
+		return messaging.Permanent(fmt.Errorf("unexpected topic %s", msg.Topic))
+	}
+}
+
+// handleMerchantUpdatedEvent validates and decodes a merchant updated event
+// and saves the merchant's number formats
+func (h *MerchantEventHandler) handleMerchantUpdatedEvent(ctx context.Context, messageBytes []byte) error {
+	env, err := h.schemas.ValidatePayload(messageBytes)
+	if err != nil {
+		h.log.WithError(err).Error("Rejected invalid MerchantUpdated event")
+		return messaging.Permanent(err)
+	}
+
+	var event MerchantUpdatedEvent
+	if err := env.Decode(&event); err != nil {
+		h.log.WithError(err).Error("Failed to decode MerchantUpdated event")
+		return messaging.Permanent(err)
+	}
+
+	// Every merchant's updates arrive on this topic
+	ctx = tenant.WithoutScope(ctx)
+
+	formats := sequence.ByName(event.NumberFormats)
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		// Commit the formats together with the processed-event record
+		err = h.inventoryService.SaveNumberFormatsInTx(ctx, tx, event.MerchantID, formats)
+	} else {
+		err = h.inventoryService.SaveNumberFormats(ctx, event.MerchantID, formats)
+	}
+	if errors.Is(err, sequence.ErrInvalidFormat) {
+		// Retrying cannot make the formats valid
+		return messaging.Permanent(err)
+	}
+	return err
+}
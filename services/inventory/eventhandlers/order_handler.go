@@ -3,52 +3,157 @@ package eventhandlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 
+	"unified-commerce/services/inventory/models"
 	"unified-commerce/services/inventory/service"
 	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/tenant"
+	"unified-commerce/shared/messaging"
 )
 
-// OrderEventHandler handles events related to orders.
+// OrderEventHandler handles events published by the order service: placed
+// orders, received returns and changed draft orders.
 type OrderEventHandler struct {
 	inventoryService *service.InventoryService
+	schemas          *messaging.SchemaRegistry
 	log              *logger.Logger
 }
 
 // NewOrderEventHandler creates a new OrderEventHandler.
-func NewOrderEventHandler(inventoryService *service.InventoryService, log *logger.Logger) *OrderEventHandler {
+func NewOrderEventHandler(inventoryService *service.InventoryService, schemas *messaging.SchemaRegistry, log *logger.Logger) *OrderEventHandler {
 	return &OrderEventHandler{
 		inventoryService: inventoryService,
+		schemas:          schemas,
 		log:              log,
 	}
 }
 
-// OrderPlacedEvent represents the structure of an order placed event.
-// We define it here to avoid a direct dependency on the order service's models.
+// OrderPlacedEvent represents the fields of the order.placed v1 event that
+// inventory reads. The full contract lives in the shared schema registry,
+// which validates each event before it is decoded into this struct.
 type OrderPlacedEvent struct {
-	ID        uuid.UUID          `json:"id"`
-	LineItems []OrderLineItemDTO `json:"line_items"`
+	ID              uuid.UUID          `json:"id"`
+	MerchantID      uuid.UUID          `json:"merchant_id"`
+	ShippingAddress AddressDTO         `json:"shipping_address"`
+	LineItems       []OrderLineItemDTO `json:"line_items"`
+}
+
+type AddressDTO struct {
+	State     string  `json:"state"`
+	Country   string  `json:"country"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
 }
 
 type OrderLineItemDTO struct {
+	ID               uuid.UUID  `json:"id"`
 	ProductVariantID *uuid.UUID `json:"product_variant_id"`
 	Quantity         int        `json:"quantity"`
 }
 
+// ReturnReceivedEvent represents the fields of the return.received v1 event
+// that inventory reads
+type ReturnReceivedEvent struct {
+	ReturnID     uuid.UUID                   `json:"return_id"`
+	ReturnNumber string                      `json:"return_number"`
+	OrderID      uuid.UUID                   `json:"order_id"`
+	LineItems    []ReturnReceivedLineItemDTO `json:"line_items"`
+}
+
+type ReturnReceivedLineItemDTO struct {
+	ProductVariantID *uuid.UUID `json:"product_variant_id"`
+	LocationID       *uuid.UUID `json:"location_id"`
+	RestockQuantity  int        `json:"restock_quantity"`
+}
+
+// DraftOrderUpdatedEvent represents the fields of the draft_order.updated
+// v1 event that inventory reads. Stock is held under the draft's order ID.
+type DraftOrderUpdatedEvent struct {
+	ID         uuid.UUID               `json:"id"`
+	OrderID    uuid.UUID               `json:"order_id"`
+	MerchantID uuid.UUID               `json:"merchant_id"`
+	LocationID *uuid.UUID              `json:"location_id"`
+	Status     string                  `json:"status"`
+	ExpiresAt  time.Time               `json:"expires_at"`
+	LineItems  []DraftOrderLineItemDTO `json:"line_items"`
+}
+
+type DraftOrderLineItemDTO struct {
+	ProductVariantID *uuid.UUID `json:"product_variant_id"`
+	Quantity         int        `json:"quantity"`
+}
+
+// draftStatusesHoldingStock are the draft order statuses that keep stock
+// reserved
+var draftStatusesHoldingStock = map[string]bool{
+	"open":            true,
+	"invoice_sent":    true,
+	"payment_pending": true,
+}
+
+// HandleMessage implements messaging.MessageHandler, dispatching on topic
+func (h *OrderEventHandler) HandleMessage(msg *messaging.Message) error {
+	return h.HandleMessageContext(context.Background(), msg)
+}
+
+// HandleMessageContext implements messaging.ContextMessageHandler. When the
+// context carries a deduplication transaction, stock is adjusted inside it.
+func (h *OrderEventHandler) HandleMessageContext(ctx context.Context, msg *messaging.Message) error {
+	switch msg.Topic {
+	case "orders.placed":
+		return h.handleOrderPlacedEvent(ctx, msg.Value)
+	case "returns.received":
+		return h.handleReturnReceivedEvent(ctx, msg.Value)
+	case "draft_orders.updated":
+		return h.handleDraftOrderUpdatedEvent(ctx, msg.Value)
+	default:
+		return messaging.Permanent(fmt.Errorf("unexpected topic %s", msg.Topic))
+	}
+}
+
 // HandleOrderPlacedEvent handles the order placed event from raw message bytes
 func (h *OrderEventHandler) HandleOrderPlacedEvent(messageBytes []byte) error {
+	return h.handleOrderPlacedEvent(context.Background(), messageBytes)
+}
+
+// handleOrderPlacedEvent validates and decodes an order placed event
+func (h *OrderEventHandler) handleOrderPlacedEvent(ctx context.Context, messageBytes []byte) error {
 	h.log.Info("Received OrderPlaced event")
 
+	env, err := h.schemas.ValidatePayload(messageBytes)
+	if errors.Is(err, messaging.ErrNotEnvelope) {
+		// Events published before envelopes were introduced carry the raw order
+		return h.handleLegacyOrderPlacedEvent(ctx, messageBytes)
+	}
+	if err != nil {
+		h.log.WithError(err).Error("Rejected invalid OrderPlaced event")
+		// Retrying cannot fix a malformed event, so send it straight to the dead-letter topic.
+		return messaging.Permanent(err)
+	}
+
+	var event OrderPlacedEvent
+	if err := env.Decode(&event); err != nil {
+		h.log.WithError(err).Error("Failed to decode OrderPlaced event")
+		return messaging.Permanent(err)
+	}
+
+	return h.processOrderPlacedEvent(ctx, event)
+}
+
+// handleLegacyOrderPlacedEvent handles an order placed event without an envelope
+func (h *OrderEventHandler) handleLegacyOrderPlacedEvent(ctx context.Context, messageBytes []byte) error {
 	var event OrderPlacedEvent
 	if err := json.Unmarshal(messageBytes, &event); err != nil {
 		h.log.WithError(err).Error("Failed to unmarshal OrderPlaced event")
-		// Return nil to commit the message and prevent reprocessing of a malformed event.
-		return nil
+		return messaging.Permanent(fmt.Errorf("failed to unmarshal OrderPlaced event: %w", err))
 	}
 
-	return h.processOrderPlacedEvent(event)
+	return h.processOrderPlacedEvent(ctx, event)
 }
 
 // HandleOrderPlaced is the legacy handler for the "orders.placed" topic (for backward compatibility)
@@ -56,34 +161,155 @@ func (h *OrderEventHandler) HandleOrderPlaced(messageBytes []byte) error {
 	return h.HandleOrderPlacedEvent(messageBytes)
 }
 
-// processOrderPlacedEvent processes the actual order placed event
-func (h *OrderEventHandler) processOrderPlacedEvent(event OrderPlacedEvent) error {
-
+// processOrderPlacedEvent routes the order's lines to the locations that
+// will fulfill them, taking the stock out at each location
+func (h *OrderEventHandler) processOrderPlacedEvent(ctx context.Context, event OrderPlacedEvent) error {
 	h.log.WithField("order_id", event.ID).Info("Processing OrderPlaced event")
 
-	// In a real application, you would determine the location from the order.
-	// For now, we'll assume a default location or a simple lookup.
-	// This is a placeholder for more complex logic.
-	const defaultLocationID = "00000000-0000-0000-0000-000000000001" // Example UUID
-	locationID, _ := uuid.Parse(defaultLocationID)
+	// Orders of every merchant arrive on this topic
+	ctx = tenant.WithoutScope(ctx)
 
-	adjustments := make([]service.StockAdjustment, len(event.LineItems))
-	for i, item := range event.LineItems {
+	req := &service.RouteOrderRequest{
+		OrderID:    event.ID,
+		MerchantID: event.MerchantID,
+		Destination: service.RoutingDestination{
+			State:     event.ShippingAddress.State,
+			Country:   event.ShippingAddress.Country,
+			Latitude:  event.ShippingAddress.Latitude,
+			Longitude: event.ShippingAddress.Longitude,
+		},
+	}
+	for _, item := range event.LineItems {
+		line := service.RoutingLine{LineItemID: item.ID, Quantity: item.Quantity}
 		if item.ProductVariantID != nil {
-			adjustments[i] = service.StockAdjustment{
-				ProductVariantID: *item.ProductVariantID,
-				Quantity:         -item.Quantity, // Decrement stock
-			}
+			line.ProductVariantID = *item.ProductVariantID
 		}
+		req.Lines = append(req.Lines, line)
 	}
 
-	ctx := context.Background()
-	if err := h.inventoryService.AdjustStock(ctx, locationID, "sale", fmt.Sprintf("Order %s", event.ID), adjustments); err != nil {
-		h.log.WithError(err).WithField("order_id", event.ID).Error("Failed to adjust stock for order")
+	var plan *service.RoutingPlan
+	var err error
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		// Commit the stock change together with the processed-event record
+		plan, err = h.inventoryService.RouteOrderInTx(ctx, tx, req)
+	} else {
+		plan, err = h.inventoryService.RouteOrder(ctx, req)
+	}
+	if err != nil {
+		h.log.WithError(err).WithField("order_id", event.ID).Error("Failed to route order")
 		// Return the error to signal that the message should be re-processed.
 		return err
 	}
 
-	h.log.WithField("order_id", event.ID).Info("Successfully adjusted stock for order")
+	h.log.WithFields(map[string]interface{}{
+		"order_id":     event.ID,
+		"fulfillments": len(plan.Fulfillments),
+		"unallocated":  len(plan.Unallocated),
+	}).Info("Successfully routed order")
+	return nil
+}
+
+// handleReturnReceivedEvent validates and decodes a return received event
+// and restocks the units that can be sold again
+func (h *OrderEventHandler) handleReturnReceivedEvent(ctx context.Context, messageBytes []byte) error {
+	env, err := h.schemas.ValidatePayload(messageBytes)
+	if err != nil {
+		h.log.WithError(err).Error("Rejected invalid ReturnReceived event")
+		return messaging.Permanent(err)
+	}
+
+	var event ReturnReceivedEvent
+	if err := env.Decode(&event); err != nil {
+		h.log.WithError(err).Error("Failed to decode ReturnReceived event")
+		return messaging.Permanent(err)
+	}
+
+	// Returns of every merchant arrive on this topic
+	ctx = tenant.WithoutScope(ctx)
+
+	req := &service.RestockReturnRequest{ReturnID: event.ReturnID, ReturnNumber: event.ReturnNumber}
+	for _, item := range event.LineItems {
+		if item.RestockQuantity == 0 {
+			continue
+		}
+		if item.ProductVariantID == nil || item.LocationID == nil {
+			h.log.WithField("return_id", event.ReturnID).Warn("Returned units have no variant or location to restock")
+			continue
+		}
+		req.Lines = append(req.Lines, service.RestockLine{
+			LocationID:       *item.LocationID,
+			ProductVariantID: *item.ProductVariantID,
+			Quantity:         item.RestockQuantity,
+		})
+	}
+
+	var restocked int
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		restocked, err = h.inventoryService.RestockReturnInTx(ctx, tx, req)
+	} else {
+		restocked, err = h.inventoryService.RestockReturn(ctx, req)
+	}
+	if err != nil {
+		h.log.WithError(err).WithField("return_id", event.ReturnID).Error("Failed to restock return")
+		return err
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"return_id": event.ReturnID,
+		"order_id":  event.OrderID,
+		"restocked": restocked,
+	}).Info("Successfully restocked return")
+	return nil
+}
+
+// handleDraftOrderUpdatedEvent validates and decodes a draft order updated
+// event and replaces the stock held for the draft
+func (h *OrderEventHandler) handleDraftOrderUpdatedEvent(ctx context.Context, messageBytes []byte) error {
+	env, err := h.schemas.ValidatePayload(messageBytes)
+	if err != nil {
+		h.log.WithError(err).Error("Rejected invalid DraftOrderUpdated event")
+		return messaging.Permanent(err)
+	}
+
+	var event DraftOrderUpdatedEvent
+	if err := env.Decode(&event); err != nil {
+		h.log.WithError(err).Error("Failed to decode DraftOrderUpdated event")
+		return messaging.Permanent(err)
+	}
+
+	// Drafts of every merchant arrive on this topic
+	ctx = tenant.WithoutScope(ctx)
+
+	req := &service.SyncReservationsRequest{
+		Reference:  event.OrderID.String(),
+		Type:       models.ReservationTypeOrder,
+		MerchantID: event.MerchantID,
+		LocationID: event.LocationID,
+		Hold:       draftStatusesHoldingStock[event.Status],
+		ExpiresAt:  &event.ExpiresAt,
+	}
+	for _, item := range event.LineItems {
+		if item.ProductVariantID != nil {
+			req.Lines = append(req.Lines, service.ReservationLine{ProductVariantID: *item.ProductVariantID, Quantity: item.Quantity})
+		}
+	}
+
+	var reservations []*models.StockReservation
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		// Commit the reservations together with the processed-event record
+		reservations, err = h.inventoryService.SyncReservationsInTx(ctx, tx, req)
+	} else {
+		reservations, err = h.inventoryService.SyncReservations(ctx, req)
+	}
+	if err != nil {
+		h.log.WithError(err).WithField("draft_order_id", event.ID).Error("Failed to sync draft order reservations")
+		return err
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"draft_order_id": event.ID,
+		"status":         event.Status,
+		"reservations":   len(reservations),
+	}).Info("Synced draft order reservations")
 	return nil
 }
@@ -37,7 +37,7 @@ func (h *InventoryHandler) RegisterRoutes(router *gin.Engine) {
 		// Protected routes (authentication required)
 		authConfig := middleware.DefaultAuthConfig()
 		protected := v1.Group("/")
-		protected.Use(middleware.JWTAuth(authConfig))
+		protected.Use(middleware.JWTAuth(authConfig), middleware.Tenant())
 		{
 			// Location management
 			locations := protected.Group("/locations")
@@ -103,6 +103,12 @@ func (h *InventoryHandler) CreateLocation(c *gin.Context) {
 		return
 	}
 
+	merchantID, ok := middleware.TenantMerchantID(c)
+	if !ok {
+		return
+	}
+	req.MerchantID = merchantID
+
 	if err := h.validator.Struct(&req); err != nil {
 		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
 		return
@@ -655,11 +661,36 @@ func (h *InventoryHandler) CancelReservation(c *gin.Context) {
 	httputil.Success(c, nil, "Reservation cancelled successfully")
 }
 
-// Stock Transfer Handlers (placeholder implementations)
+// Stock Transfer Handlers
 
 // CreateTransfer handles creating a new stock transfer
 func (h *InventoryHandler) CreateTransfer(c *gin.Context) {
-	httputil.Success(c, gin.H{"message": "Stock transfer creation - to be implemented"})
+	var req service.CreateTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
+	transfer, err := h.service.CreateTransfer(c.Request.Context(), &req)
+	if err != nil {
+		switch err {
+		case service.ErrLocationNotFound:
+			httputil.NotFound(c, "Location not found")
+		case service.ErrInvalidTransfer, service.ErrLocationInactive:
+			httputil.BadRequest(c, err.Error())
+		default:
+			h.logger.WithError(err).Error("Failed to create stock transfer")
+			httputil.InternalServerError(c, "Failed to create stock transfer")
+		}
+		return
+	}
+
+	httputil.Created(c, transfer, "Stock transfer created successfully")
 }
 
 // GetTransfers handles retrieving stock transfers
@@ -669,7 +700,24 @@ func (h *InventoryHandler) GetTransfers(c *gin.Context) {
 
 // GetTransfer handles retrieving a specific stock transfer
 func (h *InventoryHandler) GetTransfer(c *gin.Context) {
-	httputil.Success(c, gin.H{"message": "Stock transfer retrieval - to be implemented"})
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid transfer ID")
+		return
+	}
+
+	transfer, err := h.service.GetTransfer(c.Request.Context(), id)
+	if err != nil {
+		if err == service.ErrTransferNotFound {
+			httputil.NotFound(c, "Stock transfer not found")
+		} else {
+			h.logger.WithError(err).Error("Failed to get stock transfer")
+			httputil.InternalServerError(c, "Failed to get stock transfer")
+		}
+		return
+	}
+
+	httputil.Success(c, transfer, "Stock transfer retrieved successfully")
 }
 
 // UpdateTransfer handles updating a stock transfer
@@ -0,0 +1,96 @@
+// Package migrations holds the inventory service's versioned schema
+// migrations. Add a migration for every schema change and never edit one
+// that has been released.
+package migrations
+
+import (
+	"unified-commerce/services/inventory/migrations/baseline"
+	"unified-commerce/services/shared/database"
+)
+
+// All returns the inventory service's migrations
+func All() []database.Migration {
+	return []database.Migration{
+		{
+			// Tables as AutoMigrate created them before versioned
+			// migrations, which adopts existing databases unchanged. The
+			// models are frozen in the baseline package.
+			Version: 1,
+			Name:    "baseline",
+			Up:      database.AutoMigrateModels(baseline.Models()...),
+			Down:    database.DropModels(baseline.Models()...),
+		},
+		{
+			// Outbox for the events inventory publishes, starting with
+			// routed fulfillments
+			Version: 2,
+			Name:    "outbox_messages",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS outbox_messages (
+					id UUID PRIMARY KEY,
+					sequence BIGSERIAL NOT NULL,
+					aggregate_type TEXT NOT NULL,
+					aggregate_id TEXT NOT NULL,
+					topic TEXT NOT NULL,
+					key TEXT,
+					payload BYTEA NOT NULL,
+					attempts BIGINT DEFAULT 0,
+					last_error TEXT,
+					next_attempt_at TIMESTAMPTZ NOT NULL,
+					published_at TIMESTAMPTZ,
+					created_at TIMESTAMPTZ
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_messages_sequence ON outbox_messages (sequence)`,
+				`CREATE INDEX IF NOT EXISTS idx_outbox_aggregate ON outbox_messages (aggregate_type, aggregate_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_outbox_messages_next_attempt_at ON outbox_messages (next_attempt_at)`,
+				`CREATE INDEX IF NOT EXISTS idx_outbox_messages_published_at ON outbox_messages (published_at)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS outbox_messages`,
+			),
+		},
+		{
+			// Per-merchant counters and formats for transfer numbers
+			Version: 3,
+			Name:    "number_sequences",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS number_sequences (
+					merchant_id UUID NOT NULL,
+					name VARCHAR(50) NOT NULL,
+					period VARCHAR(20) NOT NULL,
+					value BIGINT NOT NULL,
+					updated_at TIMESTAMPTZ,
+					PRIMARY KEY (merchant_id, name, period)
+				)`,
+				`CREATE TABLE IF NOT EXISTS number_formats (
+					merchant_id UUID NOT NULL,
+					name VARCHAR(50) NOT NULL,
+					template TEXT NOT NULL,
+					prefix VARCHAR(20),
+					padding BIGINT NOT NULL DEFAULT 0,
+					reset_yearly BOOLEAN NOT NULL DEFAULT false,
+					updated_at TIMESTAMPTZ,
+					PRIMARY KEY (merchant_id, name)
+				)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS number_formats`,
+				`DROP TABLE IF EXISTS number_sequences`,
+			),
+		},
+		{
+			// Transfer numbers come from per-merchant sequences and may
+			// repeat across merchants
+			Version: 4,
+			Name:    "merchant_scoped_numbers",
+			Up: database.ExecSQL(
+				`ALTER TABLE stock_transfers DROP CONSTRAINT IF EXISTS uni_stock_transfers_transfer_number`,
+				`ALTER TABLE stock_transfers DROP CONSTRAINT IF EXISTS stock_transfers_transfer_number_key`,
+				`CREATE INDEX IF NOT EXISTS idx_stock_transfers_transfer_number ON stock_transfers (transfer_number)`,
+			),
+			// Reverting keeps the plain index, since numbers allocated since
+			// may already repeat across merchants
+			Down: database.ExecSQL(),
+		},
+	}
+}
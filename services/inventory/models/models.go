@@ -53,6 +53,11 @@ type Settings struct {
 	ReorderQuantity    int                    `json:"reorder_quantity"`
 	BusinessHours      map[string]interface{} `json:"business_hours"`
 	Notifications      map[string]interface{} `json:"notifications"`
+
+	// Order routing: locations with a higher priority are preferred, and a
+	// location with fulfillment countries only ships to those countries
+	FulfillmentPriority  int      `json:"fulfillment_priority"`
+	FulfillmentCountries []string `json:"fulfillment_countries,omitempty"`
 }
 
 // InventoryItem represents the inventory of a specific product variant at a location
@@ -192,7 +197,7 @@ const (
 // StockTransfer represents movement of inventory between locations
 type StockTransfer struct {
 	ID             uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	TransferNumber string         `json:"transfer_number" gorm:"unique;not null"`
+	TransferNumber string         `json:"transfer_number" gorm:"not null;index"`
 	FromLocationID uuid.UUID      `json:"from_location_id" gorm:"type:uuid;not null;index"`
 	ToLocationID   uuid.UUID      `json:"to_location_id" gorm:"type:uuid;not null;index"`
 	Status         TransferStatus `json:"status" gorm:"default:'pending'"`
@@ -7,9 +7,13 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"unified-commerce/services/inventory/models"
 	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/sequence"
+	"unified-commerce/services/shared/tenant"
+	"unified-commerce/shared/messaging"
 )
 
 // InventoryRepository handles database operations for inventory management
@@ -60,10 +64,10 @@ func (r *InventoryRepository) CreateLocation(ctx context.Context, location *mode
 	return nil
 }
 
-// GetLocation retrieves a location by ID
+// GetLocation retrieves a location of the context's merchant by ID
 func (r *InventoryRepository) GetLocation(ctx context.Context, id uuid.UUID) (*models.Location, error) {
 	var location models.Location
-	if err := r.db.WithContext(ctx).First(&location, "id = ?", id).Error; err != nil {
+	if err := r.db.WithContext(ctx).Scopes(tenant.Scope(ctx)).First(&location, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -79,7 +83,7 @@ func (r *InventoryRepository) GetLocationsByMerchant(ctx context.Context, mercha
 	var total int64
 
 	// Count total records
-	if err := r.db.WithContext(ctx).Model(&models.Location{}).
+	if err := r.db.WithContext(ctx).Model(&models.Location{}).Scopes(tenant.Scope(ctx)).
 		Where("merchant_id = ?", merchantID).Count(&total).Error; err != nil {
 		r.logger.WithError(err).Error("Failed to count locations")
 		return nil, 0, err
@@ -87,6 +91,7 @@ func (r *InventoryRepository) GetLocationsByMerchant(ctx context.Context, mercha
 
 	// Get paginated results
 	if err := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
 		Where("merchant_id = ?", merchantID).
 		Order("created_at DESC").
 		Limit(limit).Offset(offset).
@@ -98,6 +103,21 @@ func (r *InventoryRepository) GetLocationsByMerchant(ctx context.Context, mercha
 	return locations, total, nil
 }
 
+// GetActiveLocationsByMerchant retrieves a merchant's active locations, for
+// routing orders
+func (r *InventoryRepository) GetActiveLocationsByMerchant(ctx context.Context, merchantID uuid.UUID) ([]*models.Location, error) {
+	var locations []*models.Location
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
+		Where("merchant_id = ? AND is_active = ?", merchantID, true).
+		Order("code").
+		Find(&locations).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to get active locations")
+		return nil, err
+	}
+	return locations, nil
+}
+
 // UpdateLocation updates a location
 func (r *InventoryRepository) UpdateLocation(ctx context.Context, location *models.Location) error {
 	if err := r.db.WithContext(ctx).Save(location).Error; err != nil {
@@ -109,7 +129,7 @@ func (r *InventoryRepository) UpdateLocation(ctx context.Context, location *mode
 
 // DeleteLocation soft deletes a location
 func (r *InventoryRepository) DeleteLocation(ctx context.Context, id uuid.UUID) error {
-	if err := r.db.WithContext(ctx).Delete(&models.Location{}, "id = ?", id).Error; err != nil {
+	if err := r.db.WithContext(ctx).Scopes(tenant.Scope(ctx)).Delete(&models.Location{}, "id = ?", id).Error; err != nil {
 		r.logger.WithError(err).Error("Failed to delete location")
 		return err
 	}
@@ -175,10 +195,11 @@ func (r *InventoryRepository) CreateInventoryItem(ctx context.Context, item *mod
 	return nil
 }
 
-// GetInventoryItem retrieves an inventory item by ID
+// GetInventoryItem retrieves an inventory item by ID. Items are scoped to the
+// context's merchant through their location.
 func (r *InventoryRepository) GetInventoryItem(ctx context.Context, id uuid.UUID) (*models.InventoryItem, error) {
 	var item models.InventoryItem
-	if err := r.db.WithContext(ctx).Preload("Location").First(&item, "id = ?", id).Error; err != nil {
+	if err := r.db.WithContext(ctx).Scopes(tenant.ScopeThrough(ctx, "location_id", "locations")).Preload("Location").First(&item, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -191,7 +212,7 @@ func (r *InventoryRepository) GetInventoryItem(ctx context.Context, id uuid.UUID
 // GetInventoryItemBySKUAndLocation retrieves an inventory item by SKU and location
 func (r *InventoryRepository) GetInventoryItemBySKUAndLocation(ctx context.Context, sku string, locationID uuid.UUID) (*models.InventoryItem, error) {
 	var item models.InventoryItem
-	if err := r.db.WithContext(ctx).Preload("Location").
+	if err := r.db.WithContext(ctx).Scopes(tenant.ScopeThrough(ctx, "location_id", "locations")).Preload("Location").
 		First(&item, "sku = ? AND location_id = ?", sku, locationID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -208,14 +229,14 @@ func (r *InventoryRepository) GetInventoryItemsByLocation(ctx context.Context, l
 	var total int64
 
 	// Count total records
-	if err := r.db.WithContext(ctx).Model(&models.InventoryItem{}).
+	if err := r.db.WithContext(ctx).Model(&models.InventoryItem{}).Scopes(tenant.ScopeThrough(ctx, "location_id", "locations")).
 		Where("location_id = ?", locationID).Count(&total).Error; err != nil {
 		r.logger.WithError(err).Error("Failed to count inventory items")
 		return nil, 0, err
 	}
 
 	// Get paginated results
-	if err := r.db.WithContext(ctx).Preload("Location").
+	if err := r.db.WithContext(ctx).Scopes(tenant.ScopeThrough(ctx, "location_id", "locations")).Preload("Location").
 		Where("location_id = ?", locationID).
 		Order("created_at DESC").
 		Limit(limit).Offset(offset).
@@ -230,7 +251,7 @@ func (r *InventoryRepository) GetInventoryItemsByLocation(ctx context.Context, l
 // GetInventoryItemsByProduct retrieves all inventory items for a product across locations
 func (r *InventoryRepository) GetInventoryItemsByProduct(ctx context.Context, productID uuid.UUID) ([]*models.InventoryItem, error) {
 	var items []*models.InventoryItem
-	if err := r.db.WithContext(ctx).Preload("Location").
+	if err := r.db.WithContext(ctx).Scopes(tenant.ScopeThrough(ctx, "location_id", "locations")).Preload("Location").
 		Where("product_id = ?", productID).
 		Find(&items).Error; err != nil {
 		r.logger.WithError(err).Error("Failed to get inventory items by product")
@@ -239,6 +260,23 @@ func (r *InventoryRepository) GetInventoryItemsByProduct(ctx context.Context, pr
 	return items, nil
 }
 
+// GetInventoryItemsByVariants retrieves the inventory items of product
+// variants at the given locations, locking them until the transaction ends
+func (r *InventoryRepository) GetInventoryItemsByVariants(ctx context.Context, locationIDs, productVariantIDs []uuid.UUID) ([]*models.InventoryItem, error) {
+	var items []*models.InventoryItem
+	if len(locationIDs) == 0 || len(productVariantIDs) == 0 {
+		return items, nil
+	}
+	if err := r.db.WithContext(ctx).Scopes(tenant.ScopeThrough(ctx, "location_id", "locations")).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("location_id IN ? AND product_variant_id IN ? AND status = ?", locationIDs, productVariantIDs, models.InventoryStatusActive).
+		Find(&items).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to get inventory items by variants")
+		return nil, err
+	}
+	return items, nil
+}
+
 // UpdateInventoryItem updates an inventory item
 func (r *InventoryRepository) UpdateInventoryItem(ctx context.Context, item *models.InventoryItem) error {
 	if err := r.db.WithContext(ctx).Save(item).Error; err != nil {
@@ -253,7 +291,7 @@ func (r *InventoryRepository) UpdateInventoryQuantity(ctx context.Context, itemI
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Get current inventory item
 		var item models.InventoryItem
-		if err := tx.First(&item, "id = ?", itemID).Error; err != nil {
+		if err := tx.Scopes(tenant.ScopeThrough(ctx, "location_id", "locations")).First(&item, "id = ?", itemID).Error; err != nil {
 			return err
 		}
 
@@ -297,7 +335,7 @@ func (r *InventoryRepository) GetLowStockItems(ctx context.Context, locationID *
 	var items []*models.InventoryItem
 	var total int64
 
-	query := r.db.WithContext(ctx).Model(&models.InventoryItem{}).
+	query := r.db.WithContext(ctx).Model(&models.InventoryItem{}).Scopes(tenant.ScopeThrough(ctx, "location_id", "locations")).
 		Where("quantity <= low_stock_threshold AND status = ?", models.InventoryStatusActive)
 
 	if locationID != nil {
@@ -311,7 +349,7 @@ func (r *InventoryRepository) GetLowStockItems(ctx context.Context, locationID *
 	}
 
 	// Get paginated results
-	query = r.db.WithContext(ctx).Preload("Location").
+	query = r.db.WithContext(ctx).Scopes(tenant.ScopeThrough(ctx, "location_id", "locations")).Preload("Location").
 		Where("quantity <= low_stock_threshold AND status = ?", models.InventoryStatusActive)
 
 	if locationID != nil {
@@ -623,3 +661,100 @@ func (r *InventoryRepository) ExpireReservations(ctx context.Context) error {
 		return nil
 	})
 }
+
+// ReleaseReservations ends the active reservations of a reference with the
+// given status, returning their quantities to available stock. It returns
+// the number of reservations released.
+func (r *InventoryRepository) ReleaseReservations(ctx context.Context, reference string, status models.ReservationStatus) (int, error) {
+	var reservations []*models.StockReservation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("reference = ? AND status = ?", reference, models.ReservationStatusActive).
+			Find(&reservations).Error; err != nil {
+			return err
+		}
+
+		for _, reservation := range reservations {
+			reservation.Status = status
+			if err := tx.Save(reservation).Error; err != nil {
+				return err
+			}
+
+			var item models.InventoryItem
+			if err := tx.First(&item, "id = ?", reservation.InventoryItemID).Error; err != nil {
+				return err
+			}
+
+			item.ReservedQuantity -= reservation.Quantity
+			item.AvailableQuantity = item.Quantity - item.ReservedQuantity
+			if err := tx.Save(&item).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to release reservations")
+		return 0, err
+	}
+	return len(reservations), nil
+}
+
+// Transfer Operations
+
+// CreateTransfer creates a stock transfer with its items
+func (r *InventoryRepository) CreateTransfer(ctx context.Context, transfer *models.StockTransfer) error {
+	if err := r.db.WithContext(ctx).Omit("FromLocation", "ToLocation").Create(transfer).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to create stock transfer")
+		return err
+	}
+	return nil
+}
+
+// GetTransfer retrieves a stock transfer from one of the context's
+// merchant's locations, with its items
+func (r *InventoryRepository) GetTransfer(ctx context.Context, id uuid.UUID) (*models.StockTransfer, error) {
+	var transfer models.StockTransfer
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeThrough(ctx, "from_location_id", "locations")).
+		Preload("Items").Preload("FromLocation").Preload("ToLocation").
+		First(&transfer, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get stock transfer")
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// Number Operations
+
+// NextNumber allocates a merchant's next number of a sequence, such as
+// sequence.Transfer. Call it on a repository bound to the transaction that
+// stores the numbered document.
+func (r *InventoryRepository) NextNumber(ctx context.Context, merchantID uuid.UUID, name string) (string, error) {
+	return sequence.NewAllocator(sequence.NewPostgresStore(r.db)).Next(ctx, merchantID, name)
+}
+
+// SaveNumberFormats replaces a merchant's number formats
+func (r *InventoryRepository) SaveNumberFormats(ctx context.Context, merchantID uuid.UUID, formats map[string]sequence.Format) error {
+	if err := sequence.NewPostgresStore(r.db).SaveFormats(ctx, merchantID, formats); err != nil {
+		r.logger.WithError(err).Error("Failed to save number formats")
+		return err
+	}
+	return nil
+}
+
+// Outbox Operations
+
+// EnqueueOutbox stores events to be relayed to the event stream. Use it on a
+// repository bound to the transaction of the change the events describe.
+func (r *InventoryRepository) EnqueueOutbox(ctx context.Context, messages ...*messaging.OutboxMessage) error {
+	if err := messaging.EnqueueOutbox(r.db.WithContext(ctx), messages...); err != nil {
+		r.logger.WithError(err).Error("Failed to enqueue outbox messages")
+		return err
+	}
+	return nil
+}
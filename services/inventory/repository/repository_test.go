@@ -0,0 +1,45 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/inventory/migrations"
+	"unified-commerce/services/inventory/models"
+	"unified-commerce/services/inventory/repository"
+	"unified-commerce/services/shared/database/dbtest"
+	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/tenant"
+)
+
+func newTestRepository(t *testing.T) *repository.InventoryRepository {
+	t.Helper()
+	db := dbtest.Open(t, "inventory", migrations.All())
+	return repository.NewInventoryRepository(db, logger.NewLogger(logger.Config{Level: "error"}))
+}
+
+func TestInventoryRepository_HidesOtherMerchantsItems(t *testing.T) {
+	repo := newTestRepository(t)
+	merchantA, merchantB := uuid.New(), uuid.New()
+	ctxA := tenant.WithMerchantID(context.Background(), merchantA.String())
+
+	location := &models.Location{ID: uuid.New(), MerchantID: merchantA, Name: "Warehouse", Type: "warehouse", Code: "WH-" + uuid.NewString()}
+	if err := repo.CreateLocation(ctxA, location); err != nil {
+		t.Fatal(err)
+	}
+	item := &models.InventoryItem{ID: uuid.New(), LocationID: location.ID, ProductID: uuid.New(), SKU: "SKU-1", Quantity: 5}
+	if err := repo.CreateInventoryItem(ctxA, item); err != nil {
+		t.Fatal(err)
+	}
+
+	if found, err := repo.GetInventoryItem(ctxA, item.ID); err != nil || found == nil {
+		t.Fatalf("owner lookup: item = %v, err = %v", found, err)
+	}
+
+	ctxB := tenant.WithMerchantID(context.Background(), merchantB.String())
+	if found, err := repo.GetInventoryItem(ctxB, item.ID); err != nil || found != nil {
+		t.Fatalf("another merchant got item %v, err = %v; want not found", found, err)
+	}
+}
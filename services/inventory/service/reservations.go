@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/inventory/models"
+	"unified-commerce/services/inventory/repository"
+)
+
+// Held Reservations
+
+// SyncReservationsRequest describes the stock a reference, such as a draft
+// order, should hold. Each sync replaces the reference's active
+// reservations; a request that does not hold stock releases them.
+type SyncReservationsRequest struct {
+	Reference  string
+	Type       models.ReservationType
+	MerchantID uuid.UUID
+	LocationID *uuid.UUID // preferred location, if any
+	Hold       bool
+	ExpiresAt  *time.Time
+	Lines      []ReservationLine
+}
+
+// ReservationLine is a quantity of a product variant to hold
+type ReservationLine struct {
+	ProductVariantID uuid.UUID
+	Quantity         int
+}
+
+// SyncReservations replaces a reference's reservations in one transaction
+// and returns the reservations made
+func (s *InventoryService) SyncReservations(ctx context.Context, req *SyncReservationsRequest) ([]*models.StockReservation, error) {
+	tx := s.repo.BeginTx(ctx)
+	if tx == nil {
+		return nil, fmt.Errorf("failed to begin transaction")
+	}
+	defer s.repo.RollbackTx(tx)
+
+	reservations, err := s.syncReservations(ctx, s.repo.WithTx(tx), req)
+	if err != nil {
+		return nil, err
+	}
+
+	return reservations, s.repo.CommitTx(tx)
+}
+
+// SyncReservationsInTx replaces a reference's reservations inside a
+// transaction owned by the caller
+func (s *InventoryService) SyncReservationsInTx(ctx context.Context, tx *gorm.DB, req *SyncReservationsRequest) ([]*models.StockReservation, error) {
+	return s.syncReservations(ctx, s.repo.WithTx(tx), req)
+}
+
+// syncReservations cancels the reference's active reservations and, while
+// it holds stock, reserves each line again from the merchant's active
+// locations. Quantities that are not in stock are logged and left
+// unreserved rather than failing the sync.
+func (s *InventoryService) syncReservations(ctx context.Context, repo *repository.InventoryRepository, req *SyncReservationsRequest) ([]*models.StockReservation, error) {
+	if _, err := repo.ReleaseReservations(ctx, req.Reference, models.ReservationStatusCancelled); err != nil {
+		return nil, err
+	}
+	if !req.Hold {
+		return nil, nil
+	}
+
+	locations, err := repo.GetActiveLocationsByMerchant(ctx, req.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	locationIDs := make([]uuid.UUID, len(locations))
+	for i, location := range locations {
+		locationIDs[i] = location.ID
+	}
+	var variantIDs []uuid.UUID
+	for _, line := range req.Lines {
+		if line.ProductVariantID != uuid.Nil {
+			variantIDs = append(variantIDs, line.ProductVariantID)
+		}
+	}
+	items, err := repo.GetInventoryItemsByVariants(ctx, locationIDs, variantIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	allocations, shortfall := PlanReservations(req.Lines, req.LocationID, locations, items)
+	reservations := make([]*models.StockReservation, 0, len(allocations))
+	for _, allocation := range allocations {
+		item := allocation.Item
+		reservation := &models.StockReservation{
+			InventoryItemID:  item.ID,
+			LocationID:       item.LocationID,
+			ProductID:        item.ProductID,
+			ProductVariantID: item.ProductVariantID,
+			SKU:              item.SKU,
+			Quantity:         allocation.Quantity,
+			Type:             req.Type,
+			Reference:        req.Reference,
+			Status:           models.ReservationStatusActive,
+			ExpiresAt:        req.ExpiresAt,
+		}
+		if err := repo.CreateReservation(ctx, reservation); err != nil {
+			s.logger.WithError(err).
+				WithField("reference", req.Reference).
+				WithField("inventory_item_id", item.ID).
+				Error("Failed to reserve stock")
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+
+	for _, line := range shortfall {
+		s.logger.WithFields(map[string]interface{}{
+			"reference":          req.Reference,
+			"product_variant_id": line.ProductVariantID,
+			"quantity":           line.Quantity,
+		}).Warn("Not enough stock to reserve")
+	}
+	return reservations, nil
+}
+
+// ReservationAllocation is a quantity to reserve from one inventory item
+type ReservationAllocation struct {
+	Item     *models.InventoryItem
+	Quantity int
+}
+
+// PlanReservations assigns each line to the items holding stock for it,
+// trying the preferred location first and then the others, taking from the
+// items with the most available stock. It returns the allocations and the
+// quantities no item has available.
+func PlanReservations(lines []ReservationLine, preferred *uuid.UUID, locations []*models.Location, items []*models.InventoryItem) ([]ReservationAllocation, []ReservationLine) {
+	ordered := make([]*models.Location, 0, len(locations))
+	for _, location := range locations {
+		if preferred != nil && location.ID == *preferred {
+			ordered = append([]*models.Location{location}, ordered...)
+		} else {
+			ordered = append(ordered, location)
+		}
+	}
+
+	available := make(map[uuid.UUID]int, len(items))
+	for _, item := range items {
+		available[item.ID] = max(item.AvailableQuantity, 0)
+	}
+	stock := stockByLocation(items)
+
+	var allocations []ReservationAllocation
+	var shortfall []ReservationLine
+	for _, line := range lines {
+		if line.Quantity <= 0 || line.ProductVariantID == uuid.Nil {
+			continue
+		}
+		remaining := line.Quantity
+		for _, location := range ordered {
+			for remaining > 0 {
+				var best *models.InventoryItem
+				for _, item := range stock[location.ID][line.ProductVariantID] {
+					if available[item.ID] > 0 && (best == nil || available[item.ID] > available[best.ID]) {
+						best = item
+					}
+				}
+				if best == nil {
+					break
+				}
+				take := min(remaining, available[best.ID])
+				available[best.ID] -= take
+				remaining -= take
+				allocations = append(allocations, ReservationAllocation{Item: best, Quantity: take})
+			}
+		}
+		if remaining > 0 {
+			shortfall = append(shortfall, ReservationLine{ProductVariantID: line.ProductVariantID, Quantity: remaining})
+		}
+	}
+	return allocations, shortfall
+}
@@ -0,0 +1,50 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/inventory/models"
+	"unified-commerce/services/inventory/service"
+)
+
+func TestPlanReservations_PrefersLocationThenMostStock(t *testing.T) {
+	store, warehouse := location("STORE", 0, 0), location("WH", 0, 0)
+	shirt := uuid.New()
+	storeShirt, smallLot, largeLot := stock(store, shirt, 2), stock(warehouse, shirt, 3), stock(warehouse, shirt, 10)
+	lines := []service.ReservationLine{{ProductVariantID: shirt, Quantity: 6}}
+
+	allocations, shortfall := service.PlanReservations(lines, &store.ID, []*models.Location{warehouse, store},
+		[]*models.InventoryItem{smallLot, largeLot, storeShirt})
+	if len(shortfall) != 0 {
+		t.Fatalf("expected everything reserved, got shortfall %+v", shortfall)
+	}
+	if len(allocations) != 2 {
+		t.Fatalf("expected two allocations, got %+v", allocations)
+	}
+	if allocations[0].Item != storeShirt || allocations[0].Quantity != 2 {
+		t.Errorf("expected the preferred store's stock first, got %+v", allocations[0])
+	}
+	if allocations[1].Item != largeLot || allocations[1].Quantity != 4 {
+		t.Errorf("expected the rest from the largest lot, got %+v", allocations[1])
+	}
+}
+
+func TestPlanReservations_ReportsShortfall(t *testing.T) {
+	warehouse := location("WH", 0, 0)
+	shirt, hat := uuid.New(), uuid.New()
+	lines := []service.ReservationLine{
+		{ProductVariantID: shirt, Quantity: 5},
+		{ProductVariantID: hat, Quantity: 1},
+	}
+
+	allocations, shortfall := service.PlanReservations(lines, nil, []*models.Location{warehouse},
+		[]*models.InventoryItem{stock(warehouse, shirt, 3)})
+	if len(allocations) != 1 || allocations[0].Quantity != 3 {
+		t.Errorf("expected the available shirts reserved, got %+v", allocations)
+	}
+	if len(shortfall) != 2 || shortfall[0].Quantity != 2 || shortfall[1].ProductVariantID != hat {
+		t.Errorf("expected the missing shirts and hat reported, got %+v", shortfall)
+	}
+}
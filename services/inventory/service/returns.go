@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/inventory/models"
+	"unified-commerce/services/inventory/repository"
+)
+
+// Return Restocking
+
+// RestockReturnRequest asks for the restockable units of a received return
+// to be put back in stock
+type RestockReturnRequest struct {
+	ReturnID     uuid.UUID
+	ReturnNumber string
+	Lines        []RestockLine
+}
+
+// RestockLine is a quantity of a product variant returned to a location
+type RestockLine struct {
+	LocationID       uuid.UUID
+	ProductVariantID uuid.UUID
+	Quantity         int
+}
+
+// RestockReturn puts a return's units back in stock in one transaction
+func (s *InventoryService) RestockReturn(ctx context.Context, req *RestockReturnRequest) (int, error) {
+	tx := s.repo.BeginTx(ctx)
+	if tx == nil {
+		return 0, fmt.Errorf("failed to begin transaction")
+	}
+	defer s.repo.RollbackTx(tx)
+
+	restocked, err := s.restockReturn(ctx, s.repo.WithTx(tx), req)
+	if err != nil {
+		return 0, err
+	}
+
+	return restocked, s.repo.CommitTx(tx)
+}
+
+// RestockReturnInTx puts a return's units back in stock inside a
+// transaction owned by the caller
+func (s *InventoryService) RestockReturnInTx(ctx context.Context, tx *gorm.DB, req *RestockReturnRequest) (int, error) {
+	return s.restockReturn(ctx, s.repo.WithTx(tx), req)
+}
+
+// restockReturn records a return movement into the active item of each
+// line's variant at its location and returns the number of units restocked.
+// Variants no longer stocked at the location are skipped.
+func (s *InventoryService) restockReturn(ctx context.Context, repo *repository.InventoryRepository, req *RestockReturnRequest) (int, error) {
+	reference := fmt.Sprintf("Return %s", req.ReturnNumber)
+	restocked := 0
+	for _, line := range req.Lines {
+		if line.Quantity <= 0 {
+			continue
+		}
+
+		items, err := repo.GetInventoryItemsByVariants(ctx, []uuid.UUID{line.LocationID}, []uuid.UUID{line.ProductVariantID})
+		if err != nil {
+			return 0, err
+		}
+		if len(items) == 0 {
+			s.logger.WithField("return_id", req.ReturnID).
+				WithField("location_id", line.LocationID).
+				WithField("product_variant_id", line.ProductVariantID).
+				Warn("No inventory item to restock returned units into")
+			continue
+		}
+
+		item := items[0]
+		if err := repo.UpdateInventoryQuantity(ctx, item.ID, item.Quantity+line.Quantity, models.MovementTypeIn, models.MovementReasonReturn, reference, "", nil); err != nil {
+			s.logger.WithError(err).WithField("inventory_item_id", item.ID).Error("Failed to restock returned units")
+			return 0, err
+		}
+		restocked += line.Quantity
+	}
+	return restocked, nil
+}
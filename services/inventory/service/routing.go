@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/inventory/models"
+	"unified-commerce/services/inventory/repository"
+	"unified-commerce/shared/messaging"
+)
+
+// Order Routing
+
+// RouteOrderRequest asks for a placed order's lines to be routed to the
+// locations that will fulfill them
+type RouteOrderRequest struct {
+	OrderID     uuid.UUID
+	MerchantID  uuid.UUID
+	Destination RoutingDestination
+	Lines       []RoutingLine
+}
+
+// RoutingDestination is the shipping address of an order. Coordinates of
+// zero are treated as unknown.
+type RoutingDestination struct {
+	State     string
+	Country   string
+	Latitude  float64
+	Longitude float64
+}
+
+// RoutingLine is a quantity of an order line. Lines without a product
+// variant are not stocked and are never routed.
+type RoutingLine struct {
+	LineItemID       uuid.UUID `json:"line_item_id"`
+	ProductVariantID uuid.UUID `json:"product_variant_id"`
+	Quantity         int       `json:"quantity"`
+}
+
+// RoutedFulfillment is the part of an order shipped from one location
+type RoutedFulfillment struct {
+	LocationID uuid.UUID     `json:"location_id"`
+	LineItems  []RoutingLine `json:"line_items"`
+}
+
+// RoutingPlan splits an order into fulfillments by location. It is the
+// payload of the fulfillment.routed event.
+type RoutingPlan struct {
+	OrderID      uuid.UUID           `json:"order_id"`
+	MerchantID   uuid.UUID           `json:"merchant_id"`
+	Fulfillments []RoutedFulfillment `json:"fulfillments"`
+	Unallocated  []RoutingLine       `json:"unallocated,omitempty"` // quantities no location has in stock
+}
+
+// RouteOrder routes an order, takes the routed quantities out of stock at
+// their locations and publishes the plan, all in one transaction
+func (s *InventoryService) RouteOrder(ctx context.Context, req *RouteOrderRequest) (*RoutingPlan, error) {
+	tx := s.repo.BeginTx(ctx)
+	if tx == nil {
+		return nil, fmt.Errorf("failed to begin transaction")
+	}
+	defer s.repo.RollbackTx(tx)
+
+	plan, err := s.routeOrder(ctx, s.repo.WithTx(tx), req)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, s.repo.CommitTx(tx)
+}
+
+// RouteOrderInTx routes an order inside a transaction owned by the caller,
+// so the stock changes commit or roll back together with the caller's own
+// writes
+func (s *InventoryService) RouteOrderInTx(ctx context.Context, tx *gorm.DB, req *RouteOrderRequest) (*RoutingPlan, error) {
+	return s.routeOrder(ctx, s.repo.WithTx(tx), req)
+}
+
+// routeOrder routes an order using the given repository
+func (s *InventoryService) routeOrder(ctx context.Context, repo *repository.InventoryRepository, req *RouteOrderRequest) (*RoutingPlan, error) {
+	// Stock held for the order while it was a draft is taken below instead
+	if _, err := repo.ReleaseReservations(ctx, req.OrderID.String(), models.ReservationStatusFulfilled); err != nil {
+		return nil, err
+	}
+
+	locations, err := repo.GetActiveLocationsByMerchant(ctx, req.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	locationIDs := make([]uuid.UUID, len(locations))
+	for i, location := range locations {
+		locationIDs[i] = location.ID
+	}
+	var variantIDs []uuid.UUID
+	for _, line := range req.Lines {
+		if line.ProductVariantID != uuid.Nil {
+			variantIDs = append(variantIDs, line.ProductVariantID)
+		}
+	}
+	items, err := repo.GetInventoryItemsByVariants(ctx, locationIDs, variantIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := PlanRouting(req, locations, items)
+
+	// Take the routed quantities out of the items they were found in
+	stock := stockByLocation(items)
+	reference := fmt.Sprintf("Order %s", req.OrderID)
+	for _, fulfillment := range plan.Fulfillments {
+		for _, line := range fulfillment.LineItems {
+			remaining := line.Quantity
+			for _, item := range stock[fulfillment.LocationID][line.ProductVariantID] {
+				take := min(remaining, item.AvailableQuantity)
+				if take <= 0 {
+					continue
+				}
+				if err := repo.UpdateInventoryQuantity(ctx, item.ID, item.Quantity-take, models.MovementTypeOut, models.MovementReasonSale, reference, "", nil); err != nil {
+					s.logger.WithError(err).
+						WithField("location_id", fulfillment.LocationID).
+						WithField("inventory_item_id", item.ID).
+						Error("Failed to take routed stock")
+					return nil, err
+				}
+				item.Quantity -= take
+				item.AvailableQuantity -= take
+				remaining -= take
+			}
+		}
+	}
+
+	env, err := messaging.NewEnvelope(ctx, messaging.EventTypeFulfillmentRouted, 1, req.MerchantID.String(), plan)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := messaging.NewEnvelopeOutboxMessage(s.schemas, "order", req.OrderID.String(), "fulfillments.routed", req.OrderID.String(), env)
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.EnqueueOutbox(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// PlanRouting assigns an order's lines to the locations holding stock for
+// them. A single location that can ship the whole order is preferred over
+// splitting it. Otherwise each line is taken from the locations already
+// shipping part of the order first and then from the others, in rank order.
+// Quantities no location has in stock are left unallocated.
+func PlanRouting(req *RouteOrderRequest, locations []*models.Location, items []*models.InventoryItem) *RoutingPlan {
+	plan := &RoutingPlan{
+		OrderID:      req.OrderID,
+		MerchantID:   req.MerchantID,
+		Fulfillments: []RoutedFulfillment{},
+	}
+
+	ranked := rankLocations(locations, req.Destination)
+	available := make(map[uuid.UUID]map[uuid.UUID]int, len(ranked))
+	for locationID, variants := range stockByLocation(items) {
+		available[locationID] = make(map[uuid.UUID]int, len(variants))
+		for variantID, variantItems := range variants {
+			for _, item := range variantItems {
+				available[locationID][variantID] += max(item.AvailableQuantity, 0)
+			}
+		}
+	}
+
+	var lines []RoutingLine
+	for _, line := range req.Lines {
+		if line.Quantity <= 0 {
+			continue
+		}
+		if line.ProductVariantID == uuid.Nil {
+			plan.Unallocated = append(plan.Unallocated, line)
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return plan
+	}
+
+	// Ship from a single location when one has everything
+	for _, location := range ranked {
+		if canShipAll(available[location.ID], lines) {
+			plan.Fulfillments = append(plan.Fulfillments, RoutedFulfillment{LocationID: location.ID, LineItems: lines})
+			return plan
+		}
+	}
+
+	// Otherwise split the order, preferring locations already in use
+	used := make(map[uuid.UUID]int) // location ID to fulfillment index
+	allocate := func(location *models.Location, line RoutingLine, remaining int) int {
+		take := min(remaining, available[location.ID][line.ProductVariantID])
+		if take <= 0 {
+			return remaining
+		}
+		available[location.ID][line.ProductVariantID] -= take
+
+		i, ok := used[location.ID]
+		if !ok {
+			i = len(plan.Fulfillments)
+			used[location.ID] = i
+			plan.Fulfillments = append(plan.Fulfillments, RoutedFulfillment{LocationID: location.ID})
+		}
+		routed := line
+		routed.Quantity = take
+		plan.Fulfillments[i].LineItems = append(plan.Fulfillments[i].LineItems, routed)
+		return remaining - take
+	}
+
+	for _, line := range lines {
+		remaining := line.Quantity
+		for _, location := range ranked {
+			if _, ok := used[location.ID]; ok && remaining > 0 {
+				remaining = allocate(location, line, remaining)
+			}
+		}
+		for _, location := range ranked {
+			if _, ok := used[location.ID]; !ok && remaining > 0 {
+				remaining = allocate(location, line, remaining)
+			}
+		}
+		if remaining > 0 {
+			unallocated := line
+			unallocated.Quantity = remaining
+			plan.Unallocated = append(plan.Unallocated, unallocated)
+		}
+	}
+
+	return plan
+}
+
+// canShipAll reports whether a location's stock covers every line
+func canShipAll(available map[uuid.UUID]int, lines []RoutingLine) bool {
+	needed := make(map[uuid.UUID]int, len(lines))
+	for _, line := range lines {
+		needed[line.ProductVariantID] += line.Quantity
+	}
+	for variantID, quantity := range needed {
+		if available[variantID] < quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// stockByLocation groups inventory items by location and product variant
+func stockByLocation(items []*models.InventoryItem) map[uuid.UUID]map[uuid.UUID][]*models.InventoryItem {
+	stock := make(map[uuid.UUID]map[uuid.UUID][]*models.InventoryItem)
+	for _, item := range items {
+		if item.ProductVariantID == nil {
+			continue
+		}
+		if stock[item.LocationID] == nil {
+			stock[item.LocationID] = make(map[uuid.UUID][]*models.InventoryItem)
+		}
+		stock[item.LocationID][*item.ProductVariantID] = append(stock[item.LocationID][*item.ProductVariantID], item)
+	}
+	return stock
+}
+
+// rankLocations returns the locations that may ship to the destination, most
+// preferred first: by fulfillment priority, then by distance, then by region
+// when either side has no coordinates
+func rankLocations(locations []*models.Location, destination RoutingDestination) []*models.Location {
+	type candidate struct {
+		location *models.Location
+		distance float64
+		region   int
+	}
+
+	var candidates []candidate
+	for _, location := range locations {
+		if !location.IsActive || !shipsTo(location, destination.Country) {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			location: location,
+			distance: distanceTo(location.Address, destination),
+			region:   regionOf(location.Address, destination),
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.location.Settings.FulfillmentPriority != b.location.Settings.FulfillmentPriority {
+			return a.location.Settings.FulfillmentPriority > b.location.Settings.FulfillmentPriority
+		}
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		if a.region != b.region {
+			return a.region < b.region
+		}
+		return a.location.Code < b.location.Code
+	})
+
+	ranked := make([]*models.Location, len(candidates))
+	for i, candidate := range candidates {
+		ranked[i] = candidate.location
+	}
+	return ranked
+}
+
+// shipsTo reports whether a location fulfills orders to the country
+func shipsTo(location *models.Location, country string) bool {
+	if len(location.Settings.FulfillmentCountries) == 0 {
+		return true
+	}
+	for _, allowed := range location.Settings.FulfillmentCountries {
+		if strings.EqualFold(allowed, strings.TrimSpace(country)) {
+			return true
+		}
+	}
+	return false
+}
+
+// earthRadiusKm is the mean radius of the Earth
+const earthRadiusKm = 6371.0
+
+// distanceTo returns the great-circle distance in kilometres from a location
+// to the destination, or +Inf when either has no coordinates
+func distanceTo(address models.Address, destination RoutingDestination) float64 {
+	if (address.Latitude == 0 && address.Longitude == 0) || (destination.Latitude == 0 && destination.Longitude == 0) {
+		return math.Inf(1)
+	}
+
+	lat1, lat2 := address.Latitude*math.Pi/180, destination.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (destination.Longitude - address.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// regionOf ranks how close a location is to the destination by address:
+// 0 in the same state, 1 in the same country, 2 elsewhere
+func regionOf(address models.Address, destination RoutingDestination) int {
+	if !strings.EqualFold(strings.TrimSpace(address.Country), strings.TrimSpace(destination.Country)) {
+		return 2
+	}
+	if destination.State != "" && strings.EqualFold(strings.TrimSpace(address.State), strings.TrimSpace(destination.State)) {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,95 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/inventory/models"
+	"unified-commerce/services/inventory/service"
+)
+
+func location(code string, latitude, longitude float64) *models.Location {
+	return &models.Location{
+		ID:       uuid.New(),
+		Code:     code,
+		IsActive: true,
+		Address:  models.Address{Country: "US", Latitude: latitude, Longitude: longitude},
+	}
+}
+
+func stock(location *models.Location, variantID uuid.UUID, available int) *models.InventoryItem {
+	return &models.InventoryItem{
+		ID:                uuid.New(),
+		LocationID:        location.ID,
+		ProductVariantID:  &variantID,
+		Quantity:          available,
+		AvailableQuantity: available,
+	}
+}
+
+// Shipping to Chicago
+var chicago = service.RoutingDestination{Country: "US", State: "IL", Latitude: 41.88, Longitude: -87.63}
+
+func TestPlanRouting_PrefersSingleNearestLocation(t *testing.T) {
+	newYork, milwaukee := location("NYC", 40.71, -74.01), location("MKE", 43.04, -87.91)
+	shirt, hat := uuid.New(), uuid.New()
+	req := &service.RouteOrderRequest{Destination: chicago, Lines: []service.RoutingLine{
+		{LineItemID: uuid.New(), ProductVariantID: shirt, Quantity: 2},
+		{LineItemID: uuid.New(), ProductVariantID: hat, Quantity: 1},
+	}}
+
+	plan := service.PlanRouting(req, []*models.Location{newYork, milwaukee}, []*models.InventoryItem{
+		stock(newYork, shirt, 5), stock(newYork, hat, 5),
+		stock(milwaukee, shirt, 5), stock(milwaukee, hat, 5),
+	})
+	if len(plan.Fulfillments) != 1 || plan.Fulfillments[0].LocationID != milwaukee.ID {
+		t.Fatalf("expected one fulfillment from Milwaukee, got %+v", plan.Fulfillments)
+	}
+	if len(plan.Fulfillments[0].LineItems) != 2 || len(plan.Unallocated) != 0 {
+		t.Errorf("expected both lines shipped together, got %+v", plan)
+	}
+
+	// Merchant priority outranks distance
+	newYork.Settings.FulfillmentPriority = 1
+	plan = service.PlanRouting(req, []*models.Location{newYork, milwaukee}, []*models.InventoryItem{
+		stock(newYork, shirt, 5), stock(newYork, hat, 5),
+		stock(milwaukee, shirt, 5), stock(milwaukee, hat, 5),
+	})
+	if len(plan.Fulfillments) != 1 || plan.Fulfillments[0].LocationID != newYork.ID {
+		t.Errorf("expected the prioritized New York location, got %+v", plan.Fulfillments)
+	}
+}
+
+func TestPlanRouting_SplitsAcrossLocations(t *testing.T) {
+	newYork, milwaukee := location("NYC", 40.71, -74.01), location("MKE", 43.04, -87.91)
+	canada := location("YYZ", 43.65, -79.38)
+	canada.Settings.FulfillmentCountries = []string{"CA"}
+	shirt, hat, scarf := uuid.New(), uuid.New(), uuid.New()
+	shirtLine, hatLine, scarfLine := uuid.New(), uuid.New(), uuid.New()
+	req := &service.RouteOrderRequest{Destination: chicago, Lines: []service.RoutingLine{
+		{LineItemID: shirtLine, ProductVariantID: shirt, Quantity: 3},
+		{LineItemID: hatLine, ProductVariantID: hat, Quantity: 1},
+		{LineItemID: scarfLine, ProductVariantID: scarf, Quantity: 1},
+	}}
+
+	plan := service.PlanRouting(req, []*models.Location{newYork, milwaukee, canada}, []*models.InventoryItem{
+		stock(milwaukee, shirt, 2), stock(newYork, shirt, 5), stock(newYork, hat, 5),
+		stock(canada, scarf, 5),
+	})
+	if len(plan.Fulfillments) != 2 {
+		t.Fatalf("expected two fulfillments, got %+v", plan.Fulfillments)
+	}
+
+	fromMilwaukee, fromNewYork := plan.Fulfillments[0], plan.Fulfillments[1]
+	if fromMilwaukee.LocationID != milwaukee.ID || len(fromMilwaukee.LineItems) != 1 || fromMilwaukee.LineItems[0].Quantity != 2 {
+		t.Errorf("expected two shirts from Milwaukee, got %+v", fromMilwaukee)
+	}
+	if fromNewYork.LocationID != newYork.ID || len(fromNewYork.LineItems) != 2 ||
+		fromNewYork.LineItems[0].Quantity != 1 || fromNewYork.LineItems[1].LineItemID != hatLine {
+		t.Errorf("expected the last shirt and the hat from New York, got %+v", fromNewYork)
+	}
+	if len(plan.Unallocated) != 1 || plan.Unallocated[0].LineItemID != scarfLine {
+		t.Errorf("expected the scarf, only stocked for Canada, to be unallocated, got %+v", plan.Unallocated)
+	}
+}
@@ -7,10 +7,12 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 
 	"unified-commerce/services/inventory/models"
 	"unified-commerce/services/inventory/repository"
 	"unified-commerce/services/shared/logger"
+	"unified-commerce/shared/messaging"
 )
 
 // Service errors
@@ -20,6 +22,7 @@ var (
 	ErrInsufficientStock     = errors.New("insufficient stock")
 	ErrReservationNotFound   = errors.New("reservation not found")
 	ErrTransferNotFound      = errors.New("transfer not found")
+	ErrInvalidTransfer       = errors.New("transfer must be between two locations of the same merchant")
 	ErrInvalidQuantity       = errors.New("invalid quantity")
 	ErrLocationInactive      = errors.New("location is inactive")
 	ErrDuplicateLocation     = errors.New("location code already exists")
@@ -33,15 +36,17 @@ type StockAdjustment struct {
 
 // InventoryService handles business logic for inventory management
 type InventoryService struct {
-	repo   *repository.InventoryRepository
-	logger *logger.Logger
+	repo    *repository.InventoryRepository
+	logger  *logger.Logger
+	schemas *messaging.SchemaRegistry
 }
 
 // NewInventoryService creates a new inventory service
-func NewInventoryService(repo *repository.InventoryRepository, logger *logger.Logger) *InventoryService {
+func NewInventoryService(repo *repository.InventoryRepository, logger *logger.Logger, schemas *messaging.SchemaRegistry) *InventoryService {
 	return &InventoryService{
-		repo:   repo,
-		logger: logger,
+		repo:    repo,
+		logger:  logger,
+		schemas: schemas,
 	}
 }
 
@@ -49,7 +54,7 @@ func NewInventoryService(repo *repository.InventoryRepository, logger *logger.Lo
 
 // CreateLocationRequest represents a request to create a location
 type CreateLocationRequest struct {
-	MerchantID  uuid.UUID       `json:"merchant_id" validate:"required"`
+	MerchantID  uuid.UUID       `json:"-" validate:"required"`
 	Name        string          `json:"name" validate:"required"`
 	Type        string          `json:"type" validate:"required,oneof=warehouse store online consignment"`
 	Code        string          `json:"code" validate:"required"`
@@ -82,15 +87,29 @@ func (s *InventoryService) CreateLocation(ctx context.Context, req *CreateLocati
 
 // AdjustStock adjusts the stock levels for multiple inventory items at a specific location.
 func (s *InventoryService) AdjustStock(ctx context.Context, locationID uuid.UUID, reason, reference string, adjustments []StockAdjustment) error {
-	// In a real application, this should be a single database transaction.
 	tx := s.repo.BeginTx(ctx)
 	if tx == nil {
 		return fmt.Errorf("failed to begin transaction")
 	}
 	defer s.repo.RollbackTx(tx)
 
+	if err := s.adjustStock(ctx, s.repo.WithTx(tx), locationID, reason, reference, adjustments); err != nil {
+		return err
+	}
+
+	return s.repo.CommitTx(tx)
+}
+
+// AdjustStockInTx applies stock adjustments inside a transaction owned by the
+// caller, so they commit or roll back together with the caller's own writes.
+func (s *InventoryService) AdjustStockInTx(ctx context.Context, tx *gorm.DB, locationID uuid.UUID, reason, reference string, adjustments []StockAdjustment) error {
+	return s.adjustStock(ctx, s.repo.WithTx(tx), locationID, reason, reference, adjustments)
+}
+
+// adjustStock applies stock adjustments using the given repository
+func (s *InventoryService) adjustStock(ctx context.Context, repo *repository.InventoryRepository, locationID uuid.UUID, reason, reference string, adjustments []StockAdjustment) error {
 	for _, adj := range adjustments {
-		err := s.repo.WithTx(tx).AdjustInventoryLevel(ctx, locationID, adj.ProductVariantID, adj.Quantity, reason, reference)
+		err := repo.AdjustInventoryLevel(ctx, locationID, adj.ProductVariantID, adj.Quantity, reason, reference)
 		if err != nil {
 			s.logger.WithError(err).
 				WithField("location_id", locationID).
@@ -99,8 +118,7 @@ func (s *InventoryService) AdjustStock(ctx context.Context, locationID uuid.UUID
 			return err
 		}
 	}
-
-	return s.repo.CommitTx(tx)
+	return nil
 }
 
 // GetLocation retrieves a location by ID
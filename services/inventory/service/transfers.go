@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/inventory/models"
+	"unified-commerce/services/inventory/repository"
+	"unified-commerce/services/shared/sequence"
+)
+
+// Stock Transfers
+
+// CreateTransferRequest represents a request to move stock between two of a
+// merchant's locations
+type CreateTransferRequest struct {
+	FromLocationID uuid.UUID                   `json:"from_location_id" validate:"required"`
+	ToLocationID   uuid.UUID                   `json:"to_location_id" validate:"required"`
+	RequestedBy    uuid.UUID                   `json:"requested_by" validate:"required"`
+	ExpectedAt     *time.Time                  `json:"expected_at"`
+	Notes          string                      `json:"notes"`
+	Items          []CreateTransferItemRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+// CreateTransferItemRequest represents an item in a transfer request
+type CreateTransferItemRequest struct {
+	ProductID        uuid.UUID  `json:"product_id" validate:"required"`
+	ProductVariantID *uuid.UUID `json:"product_variant_id"`
+	SKU              string     `json:"sku" validate:"required"`
+	Quantity         int        `json:"quantity" validate:"required,min=1"`
+	Cost             float64    `json:"cost" validate:"min=0"`
+	Notes            string     `json:"notes"`
+}
+
+// CreateTransfer creates a pending stock transfer, numbered from the
+// merchant's transfer sequence in the transaction that stores it
+func (s *InventoryService) CreateTransfer(ctx context.Context, req *CreateTransferRequest) (*models.StockTransfer, error) {
+	if req.FromLocationID == req.ToLocationID {
+		return nil, ErrInvalidTransfer
+	}
+
+	tx := s.repo.BeginTx(ctx)
+	if tx == nil {
+		return nil, fmt.Errorf("failed to begin transaction")
+	}
+	defer s.repo.RollbackTx(tx)
+
+	transfer, err := s.createTransfer(ctx, s.repo.WithTx(tx), req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CommitTx(tx); err != nil {
+		return nil, err
+	}
+
+	s.logger.WithField("transfer_id", transfer.ID).WithField("transfer_number", transfer.TransferNumber).Info("Stock transfer created successfully")
+	return transfer, nil
+}
+
+func (s *InventoryService) createTransfer(ctx context.Context, repo *repository.InventoryRepository, req *CreateTransferRequest) (*models.StockTransfer, error) {
+	from, err := repo.GetLocation(ctx, req.FromLocationID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := repo.GetLocation(ctx, req.ToLocationID)
+	if err != nil {
+		return nil, err
+	}
+	if from == nil || to == nil {
+		return nil, ErrLocationNotFound
+	}
+	if from.MerchantID != to.MerchantID {
+		return nil, ErrInvalidTransfer
+	}
+	if !from.IsActive || !to.IsActive {
+		return nil, ErrLocationInactive
+	}
+
+	transferNumber, err := repo.NextNumber(ctx, from.MerchantID, sequence.Transfer)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &models.StockTransfer{
+		ID:             uuid.New(),
+		TransferNumber: transferNumber,
+		FromLocationID: from.ID,
+		ToLocationID:   to.ID,
+		Status:         models.TransferStatusPending,
+		RequestedBy:    req.RequestedBy,
+		ExpectedAt:     req.ExpectedAt,
+		Notes:          req.Notes,
+	}
+	for _, item := range req.Items {
+		transfer.Items = append(transfer.Items, models.StockTransferItem{
+			TransferID:        transfer.ID,
+			ProductID:         item.ProductID,
+			ProductVariantID:  item.ProductVariantID,
+			SKU:               item.SKU,
+			RequestedQuantity: item.Quantity,
+			Cost:              item.Cost,
+			Notes:             item.Notes,
+		})
+	}
+
+	if err := repo.CreateTransfer(ctx, transfer); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// GetTransfer retrieves a stock transfer by ID
+func (s *InventoryService) GetTransfer(ctx context.Context, id uuid.UUID) (*models.StockTransfer, error) {
+	transfer, err := s.repo.GetTransfer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if transfer == nil {
+		return nil, ErrTransferNotFound
+	}
+	return transfer, nil
+}
+
+// Number Formats
+
+// SaveNumberFormats replaces the formats a merchant's transfers are
+// numbered with. Sequences without a format use sequence.Defaults.
+func (s *InventoryService) SaveNumberFormats(ctx context.Context, merchantID uuid.UUID, formats map[string]sequence.Format) error {
+	return s.repo.SaveNumberFormats(ctx, merchantID, formats)
+}
+
+// SaveNumberFormatsInTx replaces a merchant's number formats inside a
+// transaction owned by the caller
+func (s *InventoryService) SaveNumberFormatsInTx(ctx context.Context, tx *gorm.DB, merchantID uuid.UUID, formats map[string]sequence.Format) error {
+	return s.repo.WithTx(tx).SaveNumberFormats(ctx, merchantID, formats)
+}
@@ -3,15 +3,19 @@ package main
 import (
 	"context"
 	"log"
+	"os"
 
 	"github.com/gin-gonic/gin"
 
 	"unified-commerce/services/merchant-account/graphql"
 	"unified-commerce/services/merchant-account/handlers"
+	"unified-commerce/services/merchant-account/migrations"
 	"unified-commerce/services/merchant-account/models"
 	"unified-commerce/services/merchant-account/repository"
 	merchantService "unified-commerce/services/merchant-account/service"
+	"unified-commerce/services/shared/database"
 	"unified-commerce/services/shared/service"
+	"unified-commerce/shared/messaging"
 )
 
 func main() {
@@ -26,16 +30,48 @@ func main() {
 		log.Fatalf("Failed to create base service: %v", err)
 	}
 
-	// Setup custom routes
-	setupRoutes(baseService.Router, baseService)
+	// Run the migrate subcommand instead of the server when requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := database.RunMigrateCommand(context.Background(), baseService.PostgresDB.DB, baseService.Name, migrations.All(), os.Args[2:], os.Stdout); err != nil {
+			baseService.Logger.WithError(err).Fatal("Migrate command failed")
+		}
+		return
+	}
 
 	// Run database migrations
-	repo := repository.NewRepository(baseService.PostgresDB)
-	if err := repo.Migrate(); err != nil {
+	if _, err := baseService.PostgresDB.Migrate(context.Background(), baseService.Name, migrations.All()); err != nil {
 		baseService.Logger.WithError(err).Fatal("Failed to run database migrations")
 	}
 
+	// Load event schemas
+	schemaRegistry, err := messaging.DefaultSchemaRegistry()
+	if err != nil {
+		baseService.Logger.WithError(err).Fatal("Failed to load event schemas")
+	}
+
+	// Initialize Event Producer for outbox events
+	producer, err := messaging.NewEventProducer(messaging.ProducerConfig{
+		Driver:    baseService.Config.MessagingDriver,
+		Brokers:   baseService.Config.KafkaBrokers,
+		UseDocker: messaging.DetectEnvironment(),
+	})
+	if err != nil {
+		baseService.Logger.WithError(err).Warn("Failed to create event producer, using no-op producer for graceful degradation")
+		producer = messaging.NewNoOpProducer()
+	}
+	defer producer.Close()
+
+	// Relay outbox events, such as merchants' number formats, to the event stream
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	outboxRelay := messaging.NewOutboxRelay(baseService.PostgresDB.DB, messaging.NewValidatingProducer(producer, schemaRegistry), messaging.DefaultOutboxRelayConfig())
+	go outboxRelay.Run(relayCtx)
+
+	// Setup custom routes
+	setupRoutes(baseService.Router, baseService, schemaRegistry)
+
 	// Seed initial data
+	repo := repository.NewRepository(baseService.PostgresDB)
 	if err := seedInitialData(repo); err != nil {
 		baseService.Logger.WithError(err).Warn("Failed to seed initial data")
 	}
@@ -49,12 +85,12 @@ func main() {
 }
 
 // setupRoutes configures the HTTP routes for the merchant account service
-func setupRoutes(router *gin.Engine, baseService *service.BaseService) {
+func setupRoutes(router *gin.Engine, baseService *service.BaseService, schemas *messaging.SchemaRegistry) {
 	// Create repository
 	repo := repository.NewRepository(baseService.PostgresDB)
 
 	// Create service
-	merchantService := merchantService.NewMerchantService(repo, baseService.Logger)
+	merchantService := merchantService.NewMerchantService(repo, baseService.Logger, schemas)
 
 	// Create handlers
 	handler := handlers.NewMerchantHandler(merchantService, baseService.Logger)
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -47,6 +48,7 @@ func (h *MerchantHandler) RegisterRoutes(router *gin.Engine) {
 		
 		// Member management
 		merchants.GET("/:id/members", h.GetMerchantMembers)
+		merchants.GET("/:id/members/me/permissions", h.GetMyPermissions)
 		merchants.POST("/:id/members/invite", h.InviteMember)
 		merchants.POST("/:id/members/:memberId/accept", h.AcceptInvitation)
 		merchants.DELETE("/:id/members/:memberId", h.RemoveMember)
@@ -189,11 +191,13 @@ func (h *MerchantHandler) UpdateMerchant(c *gin.Context) {
 
 	merchant, err := h.service.UpdateMerchant(c.Request.Context(), merchantID, userID.(string), &req)
 	if err != nil {
-		switch err {
-		case service.ErrMerchantNotFound:
+		switch {
+		case errors.Is(err, service.ErrMerchantNotFound):
 			httputil.NotFound(c, "Merchant not found")
-		case service.ErrUnauthorized:
+		case errors.Is(err, service.ErrUnauthorized):
 			httputil.Forbidden(c, "Access denied")
+		case errors.Is(err, service.ErrInvalidNumberFormat):
+			httputil.BadRequest(c, err.Error())
 		default:
 			h.logger.WithError(err).Error("Failed to update merchant")
 			httputil.InternalServerError(c, "Failed to update merchant")
@@ -357,6 +361,30 @@ func (h *MerchantHandler) GetMerchantMembers(c *gin.Context) {
 	httputil.Success(c, gin.H{"message": "Get merchant members endpoint - to be implemented"})
 }
 
+// GetMyPermissions returns the authenticated user's member permissions for a
+// merchant. Other services use it to enforce merchant-scoped permissions.
+func (h *MerchantHandler) GetMyPermissions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		httputil.Unauthorized(c, "Authentication required")
+		return
+	}
+
+	permissions, err := h.service.GetMemberPermissions(c.Request.Context(), c.Param("id"), userID.(string))
+	if err != nil {
+		switch err {
+		case service.ErrMemberNotFound:
+			httputil.NotFound(c, "Not a member of this merchant")
+		default:
+			h.logger.WithError(err).Error("Failed to get member permissions")
+			httputil.InternalServerError(c, "Failed to retrieve permissions")
+		}
+		return
+	}
+
+	httputil.Success(c, gin.H{"permissions": permissions})
+}
+
 func (h *MerchantHandler) AcceptInvitation(c *gin.Context) {
 	httputil.Success(c, gin.H{"message": "Accept invitation endpoint - to be implemented"})
 }
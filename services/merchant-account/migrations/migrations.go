@@ -0,0 +1,109 @@
+// Package migrations holds the merchant account service's versioned schema
+// migrations. Add a migration for every schema change and never edit one
+// that has been released.
+package migrations
+
+import (
+	"gorm.io/gorm"
+
+	"unified-commerce/services/merchant-account/migrations/baseline"
+	"unified-commerce/services/shared/database"
+)
+
+// All returns the merchant account service's migrations
+func All() []database.Migration {
+	return []database.Migration{
+		{
+			// Tables as AutoMigrate created them before versioned
+			// migrations, which adopts existing databases unchanged. The
+			// models are frozen in the baseline package.
+			Version: 1,
+			Name:    "baseline",
+			Up: func(tx *gorm.DB) error {
+				// merchant_members used to live in identity with foreign
+				// keys to its users table, which this database does not have
+				if err := database.ExecSQL(
+					"ALTER TABLE IF EXISTS merchant_members DROP CONSTRAINT IF EXISTS fk_users_merchant_members",
+					"ALTER TABLE IF EXISTS merchant_members DROP CONSTRAINT IF EXISTS fk_merchant_members_user_id",
+					"ALTER TABLE IF EXISTS merchant_members DROP CONSTRAINT IF EXISTS fk_merchant_members_invited_by",
+					"ALTER TABLE IF EXISTS merchant_members DROP CONSTRAINT IF EXISTS merchant_members_user_id_fkey",
+					"ALTER TABLE IF EXISTS merchant_members DROP CONSTRAINT IF EXISTS merchant_members_invited_by_fkey",
+				)(tx); err != nil {
+					return err
+				}
+				return database.AutoMigrateModels(baseline.Models()...)(tx)
+			},
+			Down: database.DropModels(baseline.Models()...),
+		},
+		{
+			// Outbox for events such as merchants' number formats
+			Version: 2,
+			Name:    "outbox",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS outbox_messages (
+					id UUID PRIMARY KEY,
+					sequence BIGSERIAL NOT NULL,
+					aggregate_type TEXT NOT NULL,
+					aggregate_id TEXT NOT NULL,
+					topic TEXT NOT NULL,
+					key TEXT,
+					payload BYTEA NOT NULL,
+					attempts BIGINT DEFAULT 0,
+					last_error TEXT,
+					next_attempt_at TIMESTAMPTZ NOT NULL,
+					published_at TIMESTAMPTZ,
+					created_at TIMESTAMPTZ
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_messages_sequence ON outbox_messages (sequence)`,
+				`CREATE INDEX IF NOT EXISTS idx_outbox_aggregate ON outbox_messages (aggregate_type, aggregate_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_outbox_messages_next_attempt_at ON outbox_messages (next_attempt_at)`,
+				`CREATE INDEX IF NOT EXISTS idx_outbox_messages_published_at ON outbox_messages (published_at)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS outbox_messages`,
+			),
+		},
+		{
+			// Per-merchant counters and formats for invoice numbers
+			Version: 3,
+			Name:    "number_sequences",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS number_sequences (
+					merchant_id UUID NOT NULL,
+					name VARCHAR(50) NOT NULL,
+					period VARCHAR(20) NOT NULL,
+					value BIGINT NOT NULL,
+					updated_at TIMESTAMPTZ,
+					PRIMARY KEY (merchant_id, name, period)
+				)`,
+				`CREATE TABLE IF NOT EXISTS number_formats (
+					merchant_id UUID NOT NULL,
+					name VARCHAR(50) NOT NULL,
+					template TEXT NOT NULL,
+					prefix VARCHAR(20),
+					padding BIGINT NOT NULL DEFAULT 0,
+					reset_yearly BOOLEAN NOT NULL DEFAULT false,
+					updated_at TIMESTAMPTZ,
+					PRIMARY KEY (merchant_id, name)
+				)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS number_formats`,
+				`DROP TABLE IF EXISTS number_sequences`,
+			),
+		},
+		{
+			// Invoice numbers come from per-merchant sequences, so they are
+			// unique within a merchant rather than across the platform
+			Version: 4,
+			Name:    "merchant_scoped_numbers",
+			Up: database.ExecSQL(
+				`DROP INDEX IF EXISTS idx_invoices_invoice_number`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_merchant_number ON invoices (merchant_id, invoice_number)`,
+			),
+			// Reverting keeps the merchant-scoped index, since numbers
+			// allocated since may already repeat across merchants
+			Down: database.ExecSQL(),
+		},
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+
+	"unified-commerce/services/shared/sequence"
 )
 
 // Merchant represents a merchant business account
@@ -41,16 +43,33 @@ func (m *Merchant) IsEntity() {}
 
 // MerchantSettings contains merchant configuration settings
 type MerchantSettings struct {
-	Currency          string                 `json:"currency"`
-	Timezone          string                 `json:"timezone"`
-	DateFormat        string                 `json:"date_format"`
-	WeightUnit        string                 `json:"weight_unit"`
-	DimensionUnit     string                 `json:"dimension_unit"`
-	OrderIDPrefix     string                 `json:"order_id_prefix"`
-	NotificationPrefs map[string]bool        `json:"notification_preferences"`
-	BusinessHours     map[string]string      `json:"business_hours"`
-	ShippingZones     []string               `json:"shipping_zones"`
-	TaxSettings       map[string]interface{} `json:"tax_settings"`
+	Currency          string                     `json:"currency"`
+	Timezone          string                     `json:"timezone"`
+	DateFormat        string                     `json:"date_format"`
+	WeightUnit        string                     `json:"weight_unit"`
+	DimensionUnit     string                     `json:"dimension_unit"`
+	OrderIDPrefix     string                     `json:"order_id_prefix"`
+	NumberFormats     map[string]sequence.Format `json:"number_formats"` // by sequence, such as "invoice"
+	NotificationPrefs map[string]bool            `json:"notification_preferences"`
+	BusinessHours     map[string]string          `json:"business_hours"`
+	ShippingZones     []string                   `json:"shipping_zones"`
+	TaxSettings       map[string]interface{}     `json:"tax_settings"`
+}
+
+// SequenceFormats returns the formats the merchant's documents are numbered
+// with. Orders without a configured format use the default order format
+// with the merchant's order ID prefix.
+func (s *MerchantSettings) SequenceFormats() map[string]sequence.Format {
+	formats := make(map[string]sequence.Format, len(s.NumberFormats)+1)
+	for name, format := range s.NumberFormats {
+		formats[name] = format
+	}
+	if _, ok := formats[sequence.Order]; !ok && s.OrderIDPrefix != "" {
+		format := sequence.DefaultFormat(sequence.Order)
+		format.Prefix = s.OrderIDPrefix
+		formats[sequence.Order] = format
+	}
+	return formats
 }
 
 // MerchantAddress represents merchant business addresses
@@ -188,9 +207,9 @@ func (MerchantMember) TableName() string {
 // Invoice represents billing invoices for merchants
 type Invoice struct {
 	ID             string     `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	MerchantID     string     `json:"merchant_id" gorm:"not null;index"`
+	MerchantID     string     `json:"merchant_id" gorm:"not null;index;uniqueIndex:idx_invoices_merchant_number,priority:1"`
 	SubscriptionID string     `json:"subscription_id" gorm:"index"`
-	InvoiceNumber  string     `json:"invoice_number" gorm:"uniqueIndex;not null"`
+	InvoiceNumber  string     `json:"invoice_number" gorm:"not null;uniqueIndex:idx_invoices_merchant_number,priority:2"`
 	Status         string     `json:"status"` // "draft", "sent", "paid", "overdue", "cancelled"
 	Currency       string     `json:"currency" gorm:"default:'USD'"`
 	Subtotal       float64    `json:"subtotal"`
@@ -2,12 +2,16 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 
 	"unified-commerce/services/merchant-account/models"
 	"unified-commerce/services/shared/database"
+	"unified-commerce/services/shared/sequence"
+	"unified-commerce/shared/messaging"
 )
 
 // MerchantRepository handles merchant data operations
@@ -333,6 +337,47 @@ func (r *MerchantMemberRepository) UpdateStatus(ctx context.Context, memberID, s
 		Updates(updates).Error
 }
 
+// InvoiceRepository handles invoice data operations
+type InvoiceRepository struct {
+	db *database.PostgresDB
+}
+
+// NewInvoiceRepository creates a new invoice repository
+func NewInvoiceRepository(db *database.PostgresDB) *InvoiceRepository {
+	return &InvoiceRepository{db: db}
+}
+
+// Create numbers an invoice from its merchant's invoice sequence and
+// creates it with its line items in the same transaction, so invoice
+// numbers have no gaps
+func (r *InvoiceRepository) Create(ctx context.Context, invoice *models.Invoice) error {
+	merchantID, err := uuid.Parse(invoice.MerchantID)
+	if err != nil {
+		return fmt.Errorf("invalid merchant ID %q: %w", invoice.MerchantID, err)
+	}
+
+	return r.db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		invoiceNumber, err := sequence.NewAllocator(sequence.NewPostgresStore(tx)).Next(ctx, merchantID, sequence.Invoice)
+		if err != nil {
+			return err
+		}
+		invoice.InvoiceNumber = invoiceNumber
+		return tx.Omit("Merchant", "Subscription").Create(invoice).Error
+	})
+}
+
+// GetByID retrieves an invoice with its line items
+func (r *InvoiceRepository) GetByID(ctx context.Context, id string) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := r.db.DB.WithContext(ctx).
+		Preload("LineItems").
+		First(&invoice, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
 // Repository aggregates all repositories
 type Repository struct {
 	Merchant        *MerchantRepository
@@ -340,6 +385,9 @@ type Repository struct {
 	Subscription    *SubscriptionRepository
 	Plan            *PlanRepository
 	MerchantMember  *MerchantMemberRepository
+	Invoice         *InvoiceRepository
+
+	db *database.PostgresDB
 }
 
 // NewRepository creates a new repository with all sub-repositories
@@ -350,46 +398,32 @@ func NewRepository(db *database.PostgresDB) *Repository {
 		Subscription:    NewSubscriptionRepository(db),
 		Plan:            NewPlanRepository(db),
 		MerchantMember:  NewMerchantMemberRepository(db),
+		Invoice:         NewInvoiceRepository(db),
+		db:              db,
 	}
 }
 
-// Migrate runs database migrations for all models
-func (r *Repository) Migrate() error {
-	// Configure GORM to disable foreign keys for this migration
-	db := r.Merchant.db.DB.Set("gorm:auto_preload", false)
-	db = db.Set("gorm:association_autoupdate", false)
-	db = db.Set("gorm:association_autocreate", false)
-
-	// Check if merchant_members table exists first
-	var merchantMembersExists bool
-	db.Raw("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_name = 'merchant_members')").Scan(&merchantMembersExists)
-
-	// Only drop constraints if the table actually exists
-	if merchantMembersExists {
-		// Drop ALL foreign key constraints on merchant_members table if they exist
-		db.Exec("ALTER TABLE merchant_members DROP CONSTRAINT IF EXISTS fk_users_merchant_members")
-		db.Exec("ALTER TABLE merchant_members DROP CONSTRAINT IF EXISTS fk_merchant_members_user_id")
-		db.Exec("ALTER TABLE merchant_members DROP CONSTRAINT IF EXISTS fk_merchant_members_invited_by")
-		db.Exec("ALTER TABLE merchant_members DROP CONSTRAINT IF EXISTS merchant_members_user_id_fkey")
-		db.Exec("ALTER TABLE merchant_members DROP CONSTRAINT IF EXISTS merchant_members_invited_by_fkey")
-
-		// Drop and recreate table to avoid constraint issues
-		db.Exec("DROP TABLE IF EXISTS merchant_members CASCADE")
+// Transaction runs fn with a repository whose operations share one
+// transaction, committed if fn returns nil
+func (r *Repository) Transaction(ctx context.Context, fn func(repo *Repository) error) error {
+	return r.db.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewRepository(&database.PostgresDB{DB: tx, SqlDB: r.db.SqlDB, Config: r.db.Config}))
+	})
+}
+
+// SaveNumberFormats replaces the formats a merchant's documents are
+// numbered with
+func (r *Repository) SaveNumberFormats(ctx context.Context, merchantID string, formats map[string]sequence.Format) error {
+	id, err := uuid.Parse(merchantID)
+	if err != nil {
+		return fmt.Errorf("invalid merchant ID %q: %w", merchantID, err)
 	}
+	return sequence.NewPostgresStore(r.db.DB).SaveFormats(ctx, id, formats)
+}
 
-	// Run migrations with disabled constraints
-	err := db.AutoMigrate(
-		&models.Merchant{},
-		&models.MerchantAddress{},
-		&models.BankAccount{},
-		&models.Subscription{},
-		&models.Plan{},
-		&models.Store{},
-		&models.MerchantMember{},
-		&models.Invoice{},
-		&models.InvoiceLineItem{},
-		&models.MerchantVerification{},
-	)
-
-	return err
+// EnqueueOutbox stores events to be relayed to the event stream. Use it on a
+// repository from Transaction so the events commit with the change they
+// describe.
+func (r *Repository) EnqueueOutbox(ctx context.Context, messages ...*messaging.OutboxMessage) error {
+	return messaging.EnqueueOutbox(r.db.DB.WithContext(ctx), messages...)
 }
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+
+	"unified-commerce/services/merchant-account/models"
+	"unified-commerce/services/merchant-account/repository"
+	"unified-commerce/services/shared/sequence"
+	"unified-commerce/shared/messaging"
+)
+
+// Number Formats
+
+// MerchantUpdatedEvent is the payload of the merchant.updated event, which
+// shares the formats a merchant's documents are numbered with with the
+// services that number them
+type MerchantUpdatedEvent struct {
+	MerchantID    string                 `json:"merchant_id"`
+	OrderIDPrefix string                 `json:"order_id_prefix"`
+	NumberFormats []sequence.NamedFormat `json:"number_formats"`
+}
+
+// saveNumberFormats stores the merchant's number formats for its invoices
+// and publishes them to the other services. Use it on a repository from
+// Transaction so the formats are published with the merchant's change.
+func (s *MerchantService) saveNumberFormats(ctx context.Context, repo *repository.Repository, merchant *models.Merchant) error {
+	formats := merchant.Settings.SequenceFormats()
+	if err := repo.SaveNumberFormats(ctx, merchant.ID, formats); err != nil {
+		return err
+	}
+
+	env, err := messaging.NewEnvelope(ctx, messaging.EventTypeMerchantUpdated, 1, merchant.ID, MerchantUpdatedEvent{
+		MerchantID:    merchant.ID,
+		OrderIDPrefix: merchant.Settings.OrderIDPrefix,
+		NumberFormats: sequence.Named(formats),
+	})
+	if err != nil {
+		return err
+	}
+	msg, err := messaging.NewEnvelopeOutboxMessage(s.schemas, "merchant", merchant.ID, "merchants.updated", merchant.ID, env)
+	if err != nil {
+		return err
+	}
+	return repo.EnqueueOutbox(ctx, msg)
+}
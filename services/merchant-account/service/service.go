@@ -9,7 +9,9 @@ import (
 	"unified-commerce/services/merchant-account/models"
 	"unified-commerce/services/merchant-account/repository"
 	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/sequence"
 	"unified-commerce/services/shared/utils"
+	"unified-commerce/shared/messaging"
 )
 
 var (
@@ -20,19 +22,22 @@ var (
 	ErrPlanNotFound         = errors.New("plan not found")
 	ErrMemberNotFound       = errors.New("member not found")
 	ErrInvalidRole          = errors.New("invalid role")
+	ErrInvalidNumberFormat  = sequence.ErrInvalidFormat
 )
 
 // MerchantService handles merchant account business logic
 type MerchantService struct {
-	repo   *repository.Repository
-	logger *logger.Logger
+	repo    *repository.Repository
+	logger  *logger.Logger
+	schemas *messaging.SchemaRegistry
 }
 
 // NewMerchantService creates a new merchant service
-func NewMerchantService(repo *repository.Repository, logger *logger.Logger) *MerchantService {
+func NewMerchantService(repo *repository.Repository, logger *logger.Logger, schemas *messaging.SchemaRegistry) *MerchantService {
 	return &MerchantService{
-		repo:   repo,
-		logger: logger,
+		repo:    repo,
+		logger:  logger,
+		schemas: schemas,
 	}
 }
 
@@ -132,8 +137,14 @@ func (s *MerchantService) CreateMerchant(ctx context.Context, req *CreateMerchan
 		},
 	}
 
-	// Create merchant
-	if err := s.repo.Merchant.Create(ctx, merchant); err != nil {
+	// Create merchant and share its number formats
+	err = s.repo.Transaction(ctx, func(repo *repository.Repository) error {
+		if err := repo.Merchant.Create(ctx, merchant); err != nil {
+			return err
+		}
+		return s.saveNumberFormats(ctx, repo, merchant)
+	})
+	if err != nil {
 		s.logger.WithError(err).Error("Failed to create merchant")
 		return nil, fmt.Errorf("failed to create merchant account")
 	}
@@ -278,8 +289,25 @@ func (s *MerchantService) UpdateMerchant(ctx context.Context, merchantID, userID
 	if len(req.Settings.BusinessHours) > 0 {
 		merchant.Settings.BusinessHours = req.Settings.BusinessHours
 	}
+	if req.Settings.OrderIDPrefix != "" {
+		merchant.Settings.OrderIDPrefix = req.Settings.OrderIDPrefix
+	}
+	if req.Settings.NumberFormats != nil {
+		for name, format := range req.Settings.NumberFormats {
+			if err := format.Validate(); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		merchant.Settings.NumberFormats = req.Settings.NumberFormats
+	}
 
-	if err := s.repo.Merchant.Update(ctx, merchant); err != nil {
+	err = s.repo.Transaction(ctx, func(repo *repository.Repository) error {
+		if err := repo.Merchant.Update(ctx, merchant); err != nil {
+			return err
+		}
+		return s.saveNumberFormats(ctx, repo, merchant)
+	})
+	if err != nil {
 		s.logger.WithError(err).Error("Failed to update merchant")
 		return nil, fmt.Errorf("failed to update merchant")
 	}
@@ -377,6 +405,16 @@ func (s *MerchantService) RemoveMember(ctx context.Context, merchantID, memberID
 	return s.repo.MerchantMember.UpdateStatus(ctx, memberID, "removed")
 }
 
+// GetMemberPermissions returns the permissions of an active merchant member
+func (s *MerchantService) GetMemberPermissions(ctx context.Context, merchantID, userID string) ([]string, error) {
+	member, err := s.repo.MerchantMember.GetByUserAndMerchant(ctx, userID, merchantID)
+	if err != nil || member.Status != "active" {
+		return nil, ErrMemberNotFound
+	}
+
+	return member.Permissions, nil
+}
+
 // ListPlans returns all available subscription plans
 func (s *MerchantService) ListPlans(ctx context.Context) ([]models.Plan, error) {
 	return s.repo.Plan.ListActive(ctx)
@@ -420,9 +458,44 @@ func (s *MerchantService) CreateSubscription(ctx context.Context, merchantID, pl
 		return nil, fmt.Errorf("failed to create subscription")
 	}
 
+	if _, err := s.invoiceSubscription(ctx, subscription, plan); err != nil {
+		s.logger.WithError(err).WithField("subscription_id", subscription.ID).Error("Failed to invoice subscription")
+	}
+
 	return subscription, nil
 }
 
+// invoiceSubscription invoices the current period of a subscription. The
+// invoice is numbered from the merchant's invoice sequence.
+func (s *MerchantService) invoiceSubscription(ctx context.Context, subscription *models.Subscription, plan *models.Plan) (*models.Invoice, error) {
+	invoice := &models.Invoice{
+		MerchantID:     subscription.MerchantID,
+		SubscriptionID: subscription.ID,
+		Status:         "sent",
+		Currency:       subscription.Currency,
+		Subtotal:       subscription.Amount,
+		TotalAmount:    subscription.Amount,
+		AmountDue:      subscription.Amount,
+		DueDate:        subscription.CurrentPeriodStart.AddDate(0, 0, 14),
+		LineItems: []models.InvoiceLineItem{
+			{
+				Description: fmt.Sprintf("%s (%s to %s)", plan.DisplayName,
+					subscription.CurrentPeriodStart.Format("2006-01-02"), subscription.CurrentPeriodEnd.Format("2006-01-02")),
+				Quantity:  1,
+				UnitPrice: subscription.Amount,
+				Amount:    subscription.Amount,
+			},
+		},
+	}
+
+	if err := s.repo.Invoice.Create(ctx, invoice); err != nil {
+		return nil, err
+	}
+
+	s.logger.WithField("invoice_id", invoice.ID).WithField("invoice_number", invoice.InvoiceNumber).Info("Subscription invoiced")
+	return invoice, nil
+}
+
 // GetSubscription retrieves merchant's subscription information
 func (s *MerchantService) GetSubscription(ctx context.Context, merchantID, userID string) (*models.Subscription, error) {
 	merchant, err := s.repo.Merchant.GetByID(ctx, merchantID)
@@ -0,0 +1,123 @@
+// Package carrier tracks shipments with shipping carriers. Each Carrier
+// authenticates the webhooks its carrier sends and normalizes the statuses
+// they report into Updates with a models.ShipmentStatus, so shipments are
+// tracked the same way whichever carrier moves them.
+package carrier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"unified-commerce/services/order/models"
+)
+
+// Carrier errors
+var (
+	ErrUnknownCarrier   = errors.New("unknown carrier")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidPayload   = errors.New("invalid webhook payload")
+)
+
+// Update is a status a carrier reported for a shipment. Status is empty
+// when the carrier's status has no ShipmentStatus equivalent.
+type Update struct {
+	TrackingNumber string
+	Status         models.ShipmentStatus
+	CarrierStatus  string // the carrier's own status code
+	Description    string
+	Location       string
+	OccurredAt     time.Time
+}
+
+// Carrier is a shipping carrier whose shipments can be tracked
+type Carrier interface {
+	// Code identifies the carrier, as fulfillments store it in
+	// TrackingCompany
+	Code() string
+	// TrackingURL returns the carrier's public tracking page for a
+	// tracking number
+	TrackingURL(trackingNumber string) string
+	// ParseWebhook authenticates a webhook the carrier sent and returns
+	// the updates it reports
+	ParseWebhook(header http.Header, body []byte) ([]Update, error)
+}
+
+// Registry holds the carriers shipments can be tracked with
+type Registry struct {
+	carriers map[string]Carrier
+}
+
+// NewRegistry creates a registry of the carriers
+func NewRegistry(carriers ...Carrier) *Registry {
+	r := &Registry{carriers: make(map[string]Carrier, len(carriers))}
+	for _, carrier := range carriers {
+		r.carriers[carrier.Code()] = carrier
+	}
+	return r
+}
+
+// DefaultRegistry returns a registry of the built-in carriers. secrets maps
+// carrier codes to the secrets their webhooks are authenticated with; the
+// webhooks of carriers without a secret are rejected.
+func DefaultRegistry(secrets map[string]string) *Registry {
+	return NewRegistry(
+		NewUPS(secrets[UPSCode]),
+		NewFedEx(secrets[FedExCode]),
+	)
+}
+
+// Get returns the carrier with the code, matched case-insensitively
+func (r *Registry) Get(code string) (Carrier, error) {
+	carrier, ok := r.carriers[Normalize(code)]
+	if !ok {
+		return nil, ErrUnknownCarrier
+	}
+	return carrier, nil
+}
+
+// Normalize returns the code form of a carrier name, such as ups for "UPS"
+func Normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// verifySecret checks a webhook carries the shared secret as is
+func verifySecret(secret, presented string) error {
+	if secret == "" || !hmac.Equal([]byte(secret), []byte(presented)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// verifyHMAC checks a webhook's signature is the base64 HMAC-SHA256 of its
+// body keyed with the secret
+func verifyHMAC(secret, signature string, body []byte) error {
+	if secret == "" {
+		return ErrInvalidSignature
+	}
+	presented, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(presented, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// joinLocation joins the non-empty parts of a location
+func joinLocation(parts ...string) string {
+	var nonEmpty []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
+}
@@ -0,0 +1,93 @@
+package carrier_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"unified-commerce/services/order/carrier"
+	"unified-commerce/services/order/models"
+)
+
+func TestUPS_ParseWebhook(t *testing.T) {
+	ups := carrier.NewUPS("credential")
+	body := []byte(`{
+		"trackingNumber": "1Z999AA10123456784",
+		"gmtActivityDate": "20261015",
+		"gmtActivityTime": "143005",
+		"activityLocation": {"city": "Louisville", "stateProvince": "KY", "country": "US"},
+		"activityStatus": {"type": "D", "code": "FS", "description": "Delivered"}
+	}`)
+
+	if _, err := ups.ParseWebhook(http.Header{"Credential": {"wrong"}}, body); !errors.Is(err, carrier.ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for a wrong credential, got %v", err)
+	}
+
+	updates, err := ups.ParseWebhook(http.Header{"Credential": {"credential"}}, body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := carrier.Update{
+		TrackingNumber: "1Z999AA10123456784",
+		Status:         models.ShipmentStatusDelivered,
+		CarrierStatus:  "DFS",
+		Description:    "Delivered",
+		Location:       "Louisville, KY, US",
+		OccurredAt:     time.Date(2026, 10, 15, 14, 30, 5, 0, time.UTC),
+	}
+	if len(updates) != 1 || updates[0] != want {
+		t.Errorf("expected %+v, got %+v", want, updates)
+	}
+}
+
+func TestFedEx_ParseWebhook(t *testing.T) {
+	fedex := carrier.NewFedEx("secret")
+	body := []byte(`{
+		"trackingNumber": "794644790132",
+		"scanEvents": [
+			{"date": "2026-10-14T08:00:00-05:00", "eventType": "PU", "eventDescription": "Picked up"},
+			{"date": "2026-10-15T09:12:00-05:00", "eventType": "HP", "eventDescription": "Ready for pickup"}
+		]
+	}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	if _, err := fedex.ParseWebhook(http.Header{"Fdx-Signature": {signature}}, append(body, ' ')); !errors.Is(err, carrier.ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for a tampered body, got %v", err)
+	}
+
+	updates, err := fedex.ParseWebhook(http.Header{"Fdx-Signature": {signature}}, body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(updates) != 2 {
+		t.Fatalf("expected 2 updates, got %d", len(updates))
+	}
+	if updates[0].Status != models.ShipmentStatusInTransit {
+		t.Errorf("expected a pickup to be in transit, got %q", updates[0].Status)
+	}
+	if updates[1].Status != "" || updates[1].CarrierStatus != "HP" {
+		t.Errorf("expected an unmapped event to keep only its carrier status, got %+v", updates[1])
+	}
+}
+
+func TestRegistry_RejectsCarriersWithoutSecret(t *testing.T) {
+	registry := carrier.DefaultRegistry(map[string]string{carrier.UPSCode: "credential"})
+
+	if _, err := registry.Get("Acme Freight"); !errors.Is(err, carrier.ErrUnknownCarrier) {
+		t.Errorf("expected ErrUnknownCarrier, got %v", err)
+	}
+
+	fedex, err := registry.Get("FedEx")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := fedex.ParseWebhook(http.Header{}, []byte(`{}`)); !errors.Is(err, carrier.ErrInvalidSignature) {
+		t.Errorf("expected webhooks of a carrier without a secret to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package carrier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"unified-commerce/services/order/models"
+)
+
+// FedExCode is the code of FedEx
+const FedExCode = "fedex"
+
+// fedexStatuses maps FedEx scan event types to shipment statuses
+var fedexStatuses = map[string]models.ShipmentStatus{
+	"OC": models.ShipmentStatusConfirmed, // shipment information sent
+	"PU": models.ShipmentStatusInTransit, // picked up
+	"AR": models.ShipmentStatusInTransit, // arrived at facility
+	"DP": models.ShipmentStatusInTransit, // departed facility
+	"IT": models.ShipmentStatusInTransit,
+	"AF": models.ShipmentStatusInTransit, // at facility
+	"OD": models.ShipmentStatusOutForDelivery,
+	"DL": models.ShipmentStatusDelivered,
+	"DE": models.ShipmentStatusException, // delivery exception
+	"SE": models.ShipmentStatusException, // shipment exception
+	"CA": models.ShipmentStatusCancelled,
+	"RS": models.ShipmentStatusFailure, // return to shipper
+}
+
+// FedEx tracks FedEx shipments through tracking webhooks, signed with the
+// base64 HMAC-SHA256 of their body in the fdx-signature header
+type FedEx struct {
+	secret string
+}
+
+// NewFedEx creates the FedEx carrier, accepting webhooks signed with the
+// secret
+func NewFedEx(secret string) *FedEx {
+	return &FedEx{secret: secret}
+}
+
+// fedexWebhook is a tracking webhook, reporting the scan events of a
+// package
+type fedexWebhook struct {
+	TrackingNumber string `json:"trackingNumber"`
+	ScanEvents     []struct {
+		Date             time.Time `json:"date"`
+		EventType        string    `json:"eventType"`
+		EventDescription string    `json:"eventDescription"`
+		ScanLocation     struct {
+			City                string `json:"city"`
+			StateOrProvinceCode string `json:"stateOrProvinceCode"`
+			CountryCode         string `json:"countryCode"`
+		} `json:"scanLocation"`
+	} `json:"scanEvents"`
+}
+
+// Code returns fedex
+func (c *FedEx) Code() string {
+	return FedExCode
+}
+
+// TrackingURL returns the FedEx tracking page of a tracking number
+func (c *FedEx) TrackingURL(trackingNumber string) string {
+	return "https://www.fedex.com/fedextrack/?trknbr=" + url.QueryEscape(trackingNumber)
+}
+
+// ParseWebhook parses a tracking webhook
+func (c *FedEx) ParseWebhook(header http.Header, body []byte) ([]Update, error) {
+	if err := verifyHMAC(c.secret, header.Get("fdx-signature"), body); err != nil {
+		return nil, err
+	}
+
+	var webhook fedexWebhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if webhook.TrackingNumber == "" {
+		return nil, fmt.Errorf("%w: trackingNumber is required", ErrInvalidPayload)
+	}
+
+	updates := make([]Update, 0, len(webhook.ScanEvents))
+	for _, event := range webhook.ScanEvents {
+		if event.Date.IsZero() {
+			return nil, fmt.Errorf("%w: scan event %s has no date", ErrInvalidPayload, event.EventType)
+		}
+		updates = append(updates, Update{
+			TrackingNumber: webhook.TrackingNumber,
+			Status:         fedexStatuses[event.EventType],
+			CarrierStatus:  event.EventType,
+			Description:    event.EventDescription,
+			Location:       joinLocation(event.ScanLocation.City, event.ScanLocation.StateOrProvinceCode, event.ScanLocation.CountryCode),
+			OccurredAt:     event.Date,
+		})
+	}
+	return updates, nil
+}
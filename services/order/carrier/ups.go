@@ -0,0 +1,95 @@
+package carrier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"unified-commerce/services/order/models"
+)
+
+// UPSCode is the code of UPS
+const UPSCode = "ups"
+
+// upsStatuses maps UPS activity status types to shipment statuses
+var upsStatuses = map[string]models.ShipmentStatus{
+	"M":  models.ShipmentStatusConfirmed, // manifest, label created
+	"MV": models.ShipmentStatusCancelled, // manifest voided
+	"P":  models.ShipmentStatusInTransit, // pickup
+	"I":  models.ShipmentStatusInTransit,
+	"W":  models.ShipmentStatusInTransit, // in a warehouse
+	"O":  models.ShipmentStatusOutForDelivery,
+	"D":  models.ShipmentStatusDelivered,
+	"X":  models.ShipmentStatusException,
+	"RS": models.ShipmentStatusFailure, // returned to shipper
+}
+
+// UPS tracks UPS shipments through Track Alert webhooks, which present the
+// subscription's credential in the Credential header
+type UPS struct {
+	credential string
+}
+
+// NewUPS creates the UPS carrier, accepting webhooks that present the
+// credential
+func NewUPS(credential string) *UPS {
+	return &UPS{credential: credential}
+}
+
+// upsWebhook is a Track Alert webhook, reporting one activity of a package
+type upsWebhook struct {
+	TrackingNumber   string `json:"trackingNumber"`
+	GMTActivityDate  string `json:"gmtActivityDate"` // yyyyMMdd
+	GMTActivityTime  string `json:"gmtActivityTime"` // HHmmss
+	ActivityLocation struct {
+		City          string `json:"city"`
+		StateProvince string `json:"stateProvince"`
+		Country       string `json:"country"`
+	} `json:"activityLocation"`
+	ActivityStatus struct {
+		Type        string `json:"type"`
+		Code        string `json:"code"`
+		Description string `json:"description"`
+	} `json:"activityStatus"`
+}
+
+// Code returns ups
+func (c *UPS) Code() string {
+	return UPSCode
+}
+
+// TrackingURL returns the UPS tracking page of a tracking number
+func (c *UPS) TrackingURL(trackingNumber string) string {
+	return "https://www.ups.com/track?tracknum=" + url.QueryEscape(trackingNumber)
+}
+
+// ParseWebhook parses a Track Alert webhook
+func (c *UPS) ParseWebhook(header http.Header, body []byte) ([]Update, error) {
+	if err := verifySecret(c.credential, header.Get("Credential")); err != nil {
+		return nil, err
+	}
+
+	var webhook upsWebhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if webhook.TrackingNumber == "" {
+		return nil, fmt.Errorf("%w: trackingNumber is required", ErrInvalidPayload)
+	}
+	occurredAt, err := time.Parse("20060102150405", webhook.GMTActivityDate+webhook.GMTActivityTime)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid activity time", ErrInvalidPayload)
+	}
+
+	status := webhook.ActivityStatus
+	return []Update{{
+		TrackingNumber: webhook.TrackingNumber,
+		Status:         upsStatuses[status.Type],
+		CarrierStatus:  status.Type + status.Code,
+		Description:    status.Description,
+		Location:       joinLocation(webhook.ActivityLocation.City, webhook.ActivityLocation.StateProvince, webhook.ActivityLocation.Country),
+		OccurredAt:     occurredAt,
+	}}, nil
+}
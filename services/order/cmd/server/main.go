@@ -9,17 +9,20 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 
+	"unified-commerce/services/order/carrier"
+	"unified-commerce/services/order/eventhandlers"
 	"unified-commerce/services/order/graphql"
 	"unified-commerce/services/order/handlers"
-	"unified-commerce/services/order/models"
+	"unified-commerce/services/order/migrations"
 	"unified-commerce/services/order/repository"
+	"unified-commerce/services/order/risk"
 	"unified-commerce/services/order/service"
 	"unified-commerce/services/shared/config"
 	"unified-commerce/services/shared/database"
 	"unified-commerce/services/shared/logger"
 	"unified-commerce/services/shared/middleware"
+	"unified-commerce/services/shared/tax"
 	"unified-commerce/shared/messaging"
 )
 
@@ -51,8 +54,22 @@ func main() {
 	}
 	defer postgresDB.Close()
 
+	// Connect to Redis for rate limiting; requests are not limited without it
+	redisClient, err := database.NewRedisConnection(database.NewRedisConfigFromEnv(
+		cfg.RedisURL,
+		cfg.RedisPassword,
+		cfg.RedisDB,
+	))
+	if err != nil {
+		log.WithError(err).Warn("Failed to connect to Redis, continuing without rate limiting")
+	} else {
+		defer redisClient.Close()
+	}
+	rateLimiter := middleware.NewRateLimiter(redisClient)
+
 	// Initialize Event Producer
 	producerConfig := messaging.ProducerConfig{
+		Driver:    cfg.MessagingDriver,
 		Brokers:   cfg.KafkaBrokers,
 		UseDocker: messaging.DetectEnvironment(),
 	}
@@ -64,16 +81,75 @@ func main() {
 	}
 	defer producer.Close()
 
+	// Load event schemas
+	schemaRegistry, err := messaging.DefaultSchemaRegistry()
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load event schemas")
+	}
+
+	// Run the migrate subcommand instead of the server when requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := database.RunMigrateCommand(context.Background(), postgresDB.DB, "order", migrations.All(), os.Args[2:], os.Stdout); err != nil {
+			log.WithError(err).Fatal("Migrate command failed")
+		}
+		return
+	}
+
 	// Run database migrations
-	if err := runMigrations(postgresDB.DB); err != nil {
+	if _, err := postgresDB.Migrate(context.Background(), "order", migrations.All()); err != nil {
 		log.WithError(err).Fatal("Failed to run database migrations")
 	}
 
 	// Initialize repositories
 	orderRepo := repository.NewOrderRepository(postgresDB.DB, log)
 
+	// Load tax rules
+	taxEngine, err := tax.NewEngineFromFile(cfg.TaxRulesFile)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load tax rules")
+	}
+
 	// Initialize services
-	orderService := service.NewOrderService(orderRepo, log, producer)
+	carriers := carrier.DefaultRegistry(cfg.CarrierWebhookSecrets)
+	orderService := service.NewOrderService(orderRepo, log, schemaRegistry, taxEngine, cfg.InvoiceURL, carriers, cfg.TrackingTokenSecret, risk.NewEngine(risk.DefaultConfig()))
+
+	// Consume fulfillments routed by inventory, the payments of orders and
+	// draft orders, disputed payments and merchants' number formats
+	inventoryEventHandler := eventhandlers.NewInventoryEventHandler(orderService, schemaRegistry, log)
+	paymentEventHandler := eventhandlers.NewPaymentEventHandler(orderService, schemaRegistry, log)
+	merchantEventHandler := eventhandlers.NewMerchantEventHandler(orderService, schemaRegistry, log)
+	eventHandlers := messaging.TopicHandlers{
+		"fulfillments.routed": inventoryEventHandler,
+		"payments.authorized": paymentEventHandler,
+		"payments.captured":   paymentEventHandler,
+		"payments.voided":     paymentEventHandler,
+		"payments.failed":     paymentEventHandler,
+		"payments.disputed":   paymentEventHandler,
+		"merchants.updated":   merchantEventHandler,
+	}
+	consumerConfig := messaging.ConsumerConfig{
+		Driver:    cfg.MessagingDriver,
+		Brokers:   cfg.KafkaBrokers,
+		GroupID:   "order-service",
+		Topics:    eventHandlers.Topics(),
+		UseDocker: messaging.DetectEnvironment(),
+	}
+	consumer, err := messaging.NewEventConsumer(consumerConfig)
+	if err != nil {
+		log.WithError(err).Warn("Failed to create event consumer, using no-op consumer for graceful degradation")
+		consumer = messaging.NewNoOpConsumer(consumerConfig.Topics)
+	}
+	defer consumer.Close()
+
+	consumerCtx, stopConsumer := context.WithCancel(context.Background())
+	defer stopConsumer()
+	// Skip redelivered events; the processed-event record commits with their changes
+	processedEvents := messaging.NewPostgresProcessedEventStore(postgresDB.DB)
+	idempotentHandler := messaging.NewIdempotentHandler(processedEvents, consumerConfig.GroupID, eventHandlers)
+	consumerRunner := messaging.NewConsumerRunner(consumer, idempotentHandler, producer, consumerConfig.GroupID, messaging.DefaultRetryPolicy())
+	go startEventConsumption(consumerCtx, consumerRunner, consumerConfig.Topics, log)
+	// Forget processed events once they can no longer be redelivered
+	go processedEvents.RunPurge(consumerCtx, time.Hour, messaging.DefaultProcessedEventRetention)
 
 	// Initialize handlers
 	orderHandler := handlers.NewOrderHandler(orderService, log)
@@ -83,13 +159,18 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.New()
+	// Only trust X-Forwarded-For from our own proxies, so clients cannot
+	// choose their IP
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.WithError(err).Fatal("Invalid trusted proxies")
+	}
 
 	// Add middleware
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 	router.Use(middleware.CORS())
 	router.Use(middleware.RequestID())
-	router.Use(middleware.RateLimit())
+	router.Use(middleware.RateLimit(rateLimiter, middleware.DefaultRateLimitConfig()))
 
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
@@ -104,14 +185,19 @@ func main() {
 		c.JSON(http.StatusOK, health)
 	})
 
+	// Deduplicate retried captures, transactions, refunds and draft order
+	// payments by their Idempotency-Key
+	idempotencyStore := middleware.NewPostgresIdempotencyStore(postgresDB.DB)
+
 	// Register routes
-	orderHandler.RegisterRoutes(router)
+	orderHandler.RegisterRoutes(router, rateLimiter, idempotencyStore)
 
 	// Add GraphQL endpoints
 	graphqlHandler := graphql.NewGraphQLHandler(orderService, log)
 	playgroundHandler := graphql.NewPlaygroundHandler()
 
-	router.Any("/graphql", gin.WrapH(graphqlHandler))
+	router.Any("/graphql", middleware.OptionalJWTAuth(middleware.DefaultAuthConfig()), middleware.Tenant(),
+		middleware.Idempotency(idempotencyStore, middleware.DefaultIdempotencyConfig()), gin.WrapH(graphqlHandler))
 	router.GET("/graphql/playground", gin.WrapH(playgroundHandler))
 
 	// Start server
@@ -124,7 +210,13 @@ func main() {
 	}
 
 	// Start background tasks
-	go startBackgroundTasks(orderService, log)
+	go startBackgroundTasks(orderService, idempotencyStore, log)
+
+	// Relay outbox events to the event stream
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	outboxRelay := messaging.NewOutboxRelay(postgresDB.DB, messaging.NewValidatingProducer(producer, schemaRegistry), messaging.DefaultOutboxRelayConfig())
+	go outboxRelay.Run(relayCtx)
 
 	// Start server in a goroutine
 	go func() {
@@ -152,20 +244,6 @@ func main() {
 	log.Info("Order Service stopped")
 }
 
-// runMigrations runs database migrations
-func runMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&models.Order{},
-		&models.OrderLineItem{},
-		&models.Fulfillment{},
-		&models.FulfillmentLineItem{},
-		&models.Transaction{},
-		&models.Return{},
-		&models.ReturnLineItem{},
-		&models.OrderEvent{},
-	)
-}
-
 // checkPostgreSQL checks PostgreSQL connection health
 func checkPostgreSQL(db *database.PostgresDB) string {
 	sqlDB, err := db.DB.DB()
@@ -179,8 +257,17 @@ func checkPostgreSQL(db *database.PostgresDB) string {
 	return "healthy"
 }
 
+// startEventConsumption handles event consumption from the messaging system.
+// Messages that keep failing are moved to their dead-letter topic.
+func startEventConsumption(ctx context.Context, runner *messaging.ConsumerRunner, topics []string, log *logger.Logger) {
+	log.Info("Starting event consumption for order service")
+	if err := runner.Run(ctx, topics); err != nil && err != context.Canceled {
+		log.WithError(err).Error("Event consumption stopped")
+	}
+}
+
 // startBackgroundTasks starts background tasks for order management
-func startBackgroundTasks(service *service.OrderService, log *logger.Logger) {
+func startBackgroundTasks(service *service.OrderService, idempotency *middleware.PostgresIdempotencyStore, log *logger.Logger) {
 	// Order processing tasks could be added here
 	// For example: auto-confirm orders after payment, send notifications, etc.
 
@@ -190,8 +277,20 @@ func startBackgroundTasks(service *service.OrderService, log *logger.Logger) {
 	for {
 		select {
 		case <-ticker.C:
-			// Background task placeholder
 			log.Debug("Running background order processing tasks")
+
+			// Release the stock of draft orders left unpaid
+			expired, err := service.ExpireDraftOrders(context.Background())
+			if err != nil {
+				log.WithError(err).Error("Failed to expire draft orders")
+			} else if expired > 0 {
+				log.WithField("count", expired).Info("Expired draft orders")
+			}
+
+			// Forget idempotency keys past their retention
+			if _, err := idempotency.DeleteExpired(context.Background()); err != nil {
+				log.WithError(err).Error("Failed to delete expired idempotency keys")
+			}
 		}
 	}
 }
@@ -0,0 +1,110 @@
+package eventhandlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/models"
+	"unified-commerce/services/order/service"
+	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/tenant"
+	"unified-commerce/shared/messaging"
+)
+
+// InventoryEventHandler handles events published by the inventory service
+type InventoryEventHandler struct {
+	orderService *service.OrderService
+	schemas      *messaging.SchemaRegistry
+	log          *logger.Logger
+}
+
+// NewInventoryEventHandler creates a new InventoryEventHandler
+func NewInventoryEventHandler(orderService *service.OrderService, schemas *messaging.SchemaRegistry, log *logger.Logger) *InventoryEventHandler {
+	return &InventoryEventHandler{
+		orderService: orderService,
+		schemas:      schemas,
+		log:          log,
+	}
+}
+
+// FulfillmentRoutedEvent represents the fulfillment.routed v1 event, which
+// splits a placed order into fulfillments by shipping location
+type FulfillmentRoutedEvent struct {
+	OrderID      uuid.UUID                      `json:"order_id"`
+	MerchantID   uuid.UUID                      `json:"merchant_id"`
+	Fulfillments []RoutedFulfillmentDTO         `json:"fulfillments"`
+	Unallocated  []RoutedFulfillmentLineItemDTO `json:"unallocated"`
+}
+
+type RoutedFulfillmentDTO struct {
+	LocationID uuid.UUID                      `json:"location_id"`
+	LineItems  []RoutedFulfillmentLineItemDTO `json:"line_items"`
+}
+
+type RoutedFulfillmentLineItemDTO struct {
+	LineItemID uuid.UUID `json:"line_item_id"`
+	Quantity   int       `json:"quantity"`
+}
+
+// HandleMessage implements messaging.MessageHandler, dispatching on topic
+func (h *InventoryEventHandler) HandleMessage(msg *messaging.Message) error {
+	return h.HandleMessageContext(context.Background(), msg)
+}
+
+// HandleMessageContext implements messaging.ContextMessageHandler. When the
+// context carries a deduplication transaction, fulfillments are created
+// inside it.
+func (h *InventoryEventHandler) HandleMessageContext(ctx context.Context, msg *messaging.Message) error {
+	switch msg.Topic {
+	case "fulfillments.routed":
+		return h.handleFulfillmentRoutedEvent(ctx, msg.Value)
+	default:
+		return messaging.Permanent(fmt.Errorf("unexpected topic %s", msg.Topic))
+	}
+}
+
+// handleFulfillmentRoutedEvent validates and decodes a fulfillment routed
+// event and creates the routed fulfillments
+func (h *InventoryEventHandler) handleFulfillmentRoutedEvent(ctx context.Context, messageBytes []byte) error {
+	env, err := h.schemas.ValidatePayload(messageBytes)
+	if err != nil {
+		h.log.WithError(err).Error("Rejected invalid FulfillmentRouted event")
+		return messaging.Permanent(err)
+	}
+
+	var event FulfillmentRoutedEvent
+	if err := env.Decode(&event); err != nil {
+		h.log.WithError(err).Error("Failed to decode FulfillmentRouted event")
+		return messaging.Permanent(err)
+	}
+
+	// Routing events of every merchant arrive on this topic
+	ctx = tenant.WithoutScope(ctx)
+
+	req := &service.RouteFulfillmentsRequest{OrderID: event.OrderID}
+	for _, routed := range event.Fulfillments {
+		fulfillment := service.RoutedFulfillmentRequest{LocationID: routed.LocationID}
+		for _, item := range routed.LineItems {
+			fulfillment.LineItems = append(fulfillment.LineItems, service.CreateFulfillmentLineItem{LineItemID: item.LineItemID, Quantity: item.Quantity})
+		}
+		req.Fulfillments = append(req.Fulfillments, fulfillment)
+	}
+	for _, item := range event.Unallocated {
+		req.Unallocated = append(req.Unallocated, service.CreateFulfillmentLineItem{LineItemID: item.LineItemID, Quantity: item.Quantity})
+	}
+
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		// Commit the fulfillments together with the processed-event record
+		_, err = h.orderService.RouteFulfillmentsInTx(ctx, tx, req)
+	} else {
+		_, err = h.orderService.RouteFulfillments(ctx, req)
+	}
+	if errors.Is(err, models.ErrLineItemNotInOrder) {
+		// The event does not match the order, retrying cannot fix it
+		return messaging.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,237 @@
+package eventhandlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/models"
+	"unified-commerce/services/order/service"
+	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/tenant"
+	"unified-commerce/shared/messaging"
+)
+
+// PaymentEventHandler handles events published by the payment service
+type PaymentEventHandler struct {
+	orderService *service.OrderService
+	schemas      *messaging.SchemaRegistry
+	log          *logger.Logger
+}
+
+// NewPaymentEventHandler creates a new PaymentEventHandler
+func NewPaymentEventHandler(orderService *service.OrderService, schemas *messaging.SchemaRegistry, log *logger.Logger) *PaymentEventHandler {
+	return &PaymentEventHandler{
+		orderService: orderService,
+		schemas:      schemas,
+		log:          log,
+	}
+}
+
+// PaymentEvent represents the payment.authorized, payment.captured,
+// payment.voided and payment.failed v1 events
+type PaymentEvent struct {
+	PaymentID  uuid.UUID `json:"payment_id"`
+	OrderID    uuid.UUID `json:"order_id"`
+	MerchantID uuid.UUID `json:"merchant_id"`
+	Amount     float64   `json:"amount"`
+	Currency   string    `json:"currency"`
+	Reason     string    `json:"reason"`
+}
+
+// PaymentDisputedEvent represents the payment.disputed v1 event
+type PaymentDisputedEvent struct {
+	DisputeID     uuid.UUID  `json:"dispute_id"`
+	PaymentID     uuid.UUID  `json:"payment_id"`
+	OrderID       uuid.UUID  `json:"order_id"`
+	MerchantID    uuid.UUID  `json:"merchant_id"`
+	Amount        float64    `json:"amount"`
+	Currency      string     `json:"currency"`
+	Reason        string     `json:"reason"`
+	EvidenceDueBy *time.Time `json:"evidence_due_by"`
+}
+
+// HandleMessage implements messaging.MessageHandler, dispatching on topic
+func (h *PaymentEventHandler) HandleMessage(msg *messaging.Message) error {
+	return h.HandleMessageContext(context.Background(), msg)
+}
+
+// HandleMessageContext implements messaging.ContextMessageHandler. When the
+// context carries a deduplication transaction, the payment is applied
+// inside it.
+func (h *PaymentEventHandler) HandleMessageContext(ctx context.Context, msg *messaging.Message) error {
+	switch msg.Topic {
+	case "payments.captured":
+		return h.handlePaymentEvent(ctx, msg.Value, true)
+	case "payments.failed":
+		return h.handlePaymentEvent(ctx, msg.Value, false)
+	case "payments.authorized":
+		return h.handlePaymentTransactionEvent(ctx, msg.Value, models.TransactionKindAuthorization)
+	case "payments.voided":
+		return h.handlePaymentTransactionEvent(ctx, msg.Value, models.TransactionKindVoid)
+	case "payments.disputed":
+		return h.handlePaymentDisputedEvent(ctx, msg.Value)
+	default:
+		return messaging.Permanent(fmt.Errorf("unexpected topic %s", msg.Topic))
+	}
+}
+
+// handlePaymentEvent validates and decodes a payment outcome and applies it
+// to the draft order paid for. Payments of orders that were not drafts are
+// recorded on the order: captures on its ledger, failures for risk
+// scoring.
+func (h *PaymentEventHandler) handlePaymentEvent(ctx context.Context, messageBytes []byte, captured bool) error {
+	event, err := h.decodePaymentEvent(messageBytes)
+	if err != nil {
+		return err
+	}
+
+	// Payments of every merchant arrive on these topics
+	ctx = tenant.WithoutScope(ctx)
+
+	payment := &service.DraftOrderPayment{
+		PaymentID: event.PaymentID,
+		OrderID:   event.OrderID,
+		Amount:    event.Amount,
+		Currency:  event.Currency,
+		Captured:  captured,
+		Reason:    event.Reason,
+	}
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		// Commit the order together with the processed-event record
+		_, err = h.orderService.CompleteDraftOrderPaymentInTx(ctx, tx, payment)
+	} else {
+		_, err = h.orderService.CompleteDraftOrderPayment(ctx, payment)
+	}
+	switch {
+	case errors.Is(err, service.ErrDraftOrderNotFound):
+		if captured {
+			return h.recordPaymentTransaction(ctx, event, models.TransactionKindCapture)
+		}
+		return h.recordPaymentFailure(ctx, event)
+	case errors.Is(err, service.ErrDraftOrderNotPayable):
+		// The draft is no longer awaiting this payment, retrying cannot fix it
+		return messaging.Permanent(err)
+	}
+	return err
+}
+
+// handlePaymentTransactionEvent validates and decodes an authorization or
+// void of an order's payment and records it on the order
+func (h *PaymentEventHandler) handlePaymentTransactionEvent(ctx context.Context, messageBytes []byte, kind models.TransactionKind) error {
+	event, err := h.decodePaymentEvent(messageBytes)
+	if err != nil {
+		return err
+	}
+
+	// Payments of every merchant arrive on these topics
+	ctx = tenant.WithoutScope(ctx)
+
+	return h.recordPaymentTransaction(ctx, event, kind)
+}
+
+// handlePaymentDisputedEvent validates and decodes a dispute of an order's
+// payment and sends the payment service the order's evidence against it.
+// Disputes of unknown orders are ignored.
+func (h *PaymentEventHandler) handlePaymentDisputedEvent(ctx context.Context, messageBytes []byte) error {
+	env, err := h.schemas.ValidatePayload(messageBytes)
+	if err != nil {
+		h.log.WithError(err).Error("Rejected invalid PaymentDisputed event")
+		return messaging.Permanent(err)
+	}
+
+	var event PaymentDisputedEvent
+	if err := env.Decode(&event); err != nil {
+		h.log.WithError(err).Error("Failed to decode PaymentDisputed event")
+		return messaging.Permanent(err)
+	}
+
+	// Payments of every merchant arrive on this topic
+	ctx = tenant.WithoutScope(ctx)
+
+	dispute := &service.PaymentDispute{
+		DisputeID:     event.DisputeID,
+		PaymentID:     event.PaymentID,
+		OrderID:       event.OrderID,
+		Amount:        event.Amount,
+		Currency:      event.Currency,
+		Reason:        event.Reason,
+		EvidenceDueBy: event.EvidenceDueBy,
+	}
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		// Commit the evidence event together with the processed-event
+		// record
+		err = h.orderService.AssembleDisputeEvidenceInTx(ctx, tx, dispute)
+	} else {
+		err = h.orderService.AssembleDisputeEvidence(ctx, dispute)
+	}
+	if errors.Is(err, service.ErrOrderNotFound) {
+		return nil
+	}
+	return err
+}
+
+// decodePaymentEvent validates and decodes a payment event
+func (h *PaymentEventHandler) decodePaymentEvent(messageBytes []byte) (*PaymentEvent, error) {
+	env, err := h.schemas.ValidatePayload(messageBytes)
+	if err != nil {
+		h.log.WithError(err).Error("Rejected invalid payment event")
+		return nil, messaging.Permanent(err)
+	}
+
+	var event PaymentEvent
+	if err := env.Decode(&event); err != nil {
+		h.log.WithError(err).Error("Failed to decode payment event")
+		return nil, messaging.Permanent(err)
+	}
+	return &event, nil
+}
+
+// recordPaymentTransaction records an authorization, capture or void of an
+// order's payment on the order. Payments of unknown orders are ignored.
+func (h *PaymentEventHandler) recordPaymentTransaction(ctx context.Context, event *PaymentEvent, kind models.TransactionKind) error {
+	payment := &service.PaymentTransaction{
+		PaymentID: event.PaymentID,
+		OrderID:   event.OrderID,
+		Kind:      kind,
+		Amount:    event.Amount,
+		Currency:  event.Currency,
+	}
+
+	var err error
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		err = h.orderService.RecordPaymentTransactionInTx(ctx, tx, payment)
+	} else {
+		err = h.orderService.RecordPaymentTransaction(ctx, payment)
+	}
+	if errors.Is(err, service.ErrOrderNotFound) {
+		return nil
+	}
+	return err
+}
+
+// recordPaymentFailure records a failed payment of an order that was not a
+// draft. Payments of unknown orders are ignored.
+func (h *PaymentEventHandler) recordPaymentFailure(ctx context.Context, event *PaymentEvent) error {
+	failure := &service.PaymentFailure{
+		PaymentID: event.PaymentID,
+		OrderID:   event.OrderID,
+		Amount:    event.Amount,
+		Currency:  event.Currency,
+		Reason:    event.Reason,
+	}
+
+	var err error
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		err = h.orderService.RecordPaymentFailureInTx(ctx, tx, failure)
+	} else {
+		err = h.orderService.RecordPaymentFailure(ctx, failure)
+	}
+	if errors.Is(err, service.ErrOrderNotFound) {
+		return nil
+	}
+	return err
+}
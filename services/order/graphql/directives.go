@@ -0,0 +1,35 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+
+	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/middleware"
+)
+
+// newHasPermissionDirective implements the @hasPermission directive. The
+// generated executor calls it for every annotated field with the resource
+// and action from schema.graphql, so annotating a field is enough to
+// protect it.
+func newHasPermissionDirective(authorizer *middleware.Authorizer, logger *logger.Logger) func(ctx context.Context, obj interface{}, next graphql.Resolver, resource string, action string) (interface{}, error) {
+	return func(ctx context.Context, obj interface{}, next graphql.Resolver, resource string, action string) (interface{}, error) {
+		err := authorizer.Authorize(ctx, resource, action)
+		switch {
+		case err == nil:
+			return next(ctx)
+		case errors.Is(err, middleware.ErrUnauthenticated), errors.Is(err, middleware.ErrPermissionDenied):
+			return nil, err
+		default:
+			field := ""
+			if fc := graphql.GetFieldContext(ctx); fc != nil {
+				field = fc.Field.Name
+			}
+			logger.WithError(err).WithField("field", field).Error("Permission check failed")
+			return nil, fmt.Errorf("unable to verify permissions")
+		}
+	}
+}
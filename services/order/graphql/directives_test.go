@@ -0,0 +1,85 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/middleware"
+)
+
+func TestHasPermissionDirective(t *testing.T) {
+	directive := newHasPermissionDirective(middleware.DefaultAuthorizer(), logger.NewLogger(logger.Config{Level: "error"}))
+	resolved := "resolved"
+	next := func(ctx context.Context) (interface{}, error) { return resolved, nil }
+
+	tests := []struct {
+		name    string
+		claims  *middleware.JWTClaims
+		want    interface{}
+		wantErr error
+	}{
+		{name: "unauthenticated", wantErr: middleware.ErrUnauthenticated},
+		{name: "missing permission", claims: &middleware.JWTClaims{UserID: "user-1"}, wantErr: middleware.ErrPermissionDenied},
+		{
+			name:   "granted",
+			claims: &middleware.JWTClaims{UserID: "user-1", Permissions: []string{middleware.Permission("orders", "update")}},
+			want:   resolved,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.claims != nil {
+				ctx = middleware.ContextWithClaims(ctx, tt.claims, "token")
+			}
+			got, err := directive(ctx, nil, next, "orders", "update")
+			if err != tt.wantErr || got != tt.want {
+				t.Fatalf("got %v, err = %v; want %v, err = %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHasPermissionDirective_GuardsAnnotatedFields(t *testing.T) {
+	handler := NewGraphQLHandler(nil, logger.NewLogger(logger.Config{Level: "error"}))
+
+	tests := []struct {
+		name   string
+		claims *middleware.JWTClaims
+		want   string
+	}{
+		{name: "unauthenticated", want: middleware.ErrUnauthenticated.Error()},
+		{name: "missing permission", claims: &middleware.JWTClaims{UserID: "user-1"}, want: middleware.ErrPermissionDenied.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"query":"mutation { removeLineItem(id: \"line-1\") }"}`
+			req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.claims != nil {
+				req = req.WithContext(middleware.ContextWithClaims(req.Context(), tt.claims, "token"))
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			var response struct {
+				Errors []struct {
+					Message string `json:"message"`
+				} `json:"errors"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Errors) != 1 || response.Errors[0].Message != tt.want {
+				t.Fatalf("errors = %+v, want %q", response.Errors, tt.want)
+			}
+		})
+	}
+}
@@ -9,18 +9,25 @@ import (
 	"github.com/99designs/gqlgen/graphql/playground"
 	"unified-commerce/services/order/service"
 	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/middleware"
 )
 
 // NewGraphQLHandler creates a new GraphQL HTTP handler
 func NewGraphQLHandler(orderService *service.OrderService, logger *logger.Logger) http.Handler {
 	// Create a simple executable schema
 	schema := NewExecutableSchema(Config{
-		Resolvers: NewResolver(orderService, logger),
+		Resolvers:  NewResolver(orderService, logger),
+		Directives: DirectiveRoot{
+			HasPermission: newHasPermissionDirective(middleware.DefaultAuthorizer(), logger),
+		},
 	})
 
 	// Create the GraphQL server
 	srv := handler.NewDefaultServer(schema)
 
+	// Refuse mutations that move money unless they can be deduplicated
+	srv.AroundRootFields(requireIdempotencyKey)
+
 	// Add recovery handler
 	srv.SetRecoverFunc(func(ctx context.Context, err interface{}) error {
 		logger.WithField("panic", err).Error("GraphQL panic recovered")
@@ -1,5 +1,15 @@
 package graphql
 
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/models"
+)
+
 // stringValue converts a string pointer to a string value, returning empty string if nil
 func stringValue(s *string) string {
 	if s == nil {
@@ -7,3 +17,58 @@ func stringValue(s *string) string {
 	}
 	return *s
 }
+
+// orderQuery converts an order filter to an order query. Enum values are
+// lowercased to the stored statuses and times are RFC 3339.
+func orderQuery(filter *OrderFilter) (*models.OrderQuery, error) {
+	query := &models.OrderQuery{
+		Statuses:            lowerEnums(filter.Status),
+		FulfillmentStatuses: lowerEnums(filter.FulfillmentStatus),
+		PaymentStatuses:     lowerEnums(filter.PaymentStatus),
+		Sources:             lowerEnums(filter.Source),
+		CustomerEmail:       stringValue(filter.CustomerEmail),
+		MinTotal:            filter.MinTotal,
+		MaxTotal:            filter.MaxTotal,
+		Tags:                filter.Tags,
+		SKU:                 stringValue(filter.Sku),
+		Search:              stringValue(filter.Query),
+	}
+
+	if filter.CustomerID != nil {
+		id, err := uuid.Parse(*filter.CustomerID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid customerId")
+		}
+		query.CustomerID = &id
+	}
+
+	var err error
+	if query.CreatedFrom, err = parseTime(filter.CreatedFrom, "createdFrom"); err != nil {
+		return nil, err
+	}
+	if query.CreatedTo, err = parseTime(filter.CreatedTo, "createdTo"); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// lowerEnums lowercases GraphQL enum values
+func lowerEnums[T ~string](values []T) []T {
+	lowered := make([]T, len(values))
+	for i, value := range values {
+		lowered[i] = T(strings.ToLower(string(value)))
+	}
+	return lowered
+}
+
+// parseTime parses an optional RFC 3339 time argument
+func parseTime(value *string, name string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return &t, nil
+}
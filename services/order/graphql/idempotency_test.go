@@ -0,0 +1,53 @@
+package graphql
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/middleware"
+)
+
+func TestRequireIdempotencyKey(t *testing.T) {
+	handler := NewGraphQLHandler(nil, logger.NewLogger(logger.Config{Level: "error"}))
+	claims := &middleware.JWTClaims{UserID: "user-1", Permissions: []string{middleware.Permission("transactions", "create")}}
+
+	tests := []struct {
+		name string
+		key  string
+		want string
+	}{
+		{name: "without key", want: "capturePayment requires an Idempotency-Key header"},
+		// The order ID is invalid, so reaching the resolver fails there
+		{name: "with key", key: "key-1", want: "invalid order ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"query":"mutation { capturePayment(id: \"order-1\") { id } }"}`
+			req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			ctx := middleware.ContextWithClaims(req.Context(), claims, "token")
+			if tt.key != "" {
+				ctx = middleware.ContextWithIdempotencyKey(ctx, tt.key)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req.WithContext(ctx))
+
+			var response struct {
+				Errors []struct {
+					Message string `json:"message"`
+				} `json:"errors"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Errors) != 1 || response.Errors[0].Message != tt.want {
+				t.Fatalf("errors = %+v, want %q", response.Errors, tt.want)
+			}
+		})
+	}
+}
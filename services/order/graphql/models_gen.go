@@ -3,9 +3,6 @@
 package graphql
 
 import (
-	"fmt"
-	"io"
-	"strconv"
 	"unified-commerce/services/order/models"
 )
 
@@ -70,15 +67,36 @@ type FulfillOrderInput struct {
 type Mutation struct {
 }
 
+type OrderConnection struct {
+	Edges    []*OrderEdge `json:"edges"`
+	PageInfo *PageInfo    `json:"pageInfo"`
+}
+
+type OrderEdge struct {
+	Cursor string        `json:"cursor"`
+	Node   *models.Order `json:"node"`
+}
+
 type OrderFilter struct {
-	CustomerID        *string                   `json:"customerId,omitempty"`
-	MerchantID        *string                   `json:"merchantId,omitempty"`
-	Status            *models.OrderStatus       `json:"status,omitempty"`
-	FulfillmentStatus *models.FulfillmentStatus `json:"fulfillmentStatus,omitempty"`
-	PaymentStatus     *models.PaymentStatus     `json:"paymentStatus,omitempty"`
-	Source            *models.OrderSource       `json:"source,omitempty"`
-	Limit             *int                      `json:"limit,omitempty"`
-	Offset            *int                      `json:"offset,omitempty"`
+	CustomerID        *string                    `json:"customerId,omitempty"`
+	MerchantID        *string                    `json:"merchantId,omitempty"`
+	Status            []models.OrderStatus       `json:"status,omitempty"`
+	FulfillmentStatus []models.FulfillmentStatus `json:"fulfillmentStatus,omitempty"`
+	PaymentStatus     []models.PaymentStatus     `json:"paymentStatus,omitempty"`
+	Source            []models.OrderSource       `json:"source,omitempty"`
+	CustomerEmail     *string                    `json:"customerEmail,omitempty"`
+	CreatedFrom       *string                    `json:"createdFrom,omitempty"`
+	CreatedTo         *string                    `json:"createdTo,omitempty"`
+	MinTotal          *float64                   `json:"minTotal,omitempty"`
+	MaxTotal          *float64                   `json:"maxTotal,omitempty"`
+	Tags              []string                   `json:"tags,omitempty"`
+	Sku               *string                    `json:"sku,omitempty"`
+	Query             *string                    `json:"query,omitempty"`
+}
+
+type PageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor,omitempty"`
 }
 
 type Query struct {
@@ -103,138 +121,3 @@ type UpdateOrderLineItemInput struct {
 	Price      *float64 `json:"price,omitempty"`
 	Properties *string  `json:"properties,omitempty"`
 }
-
-type LineItemFulfillmentStatus string
-
-const (
-	LineItemFulfillmentStatusUnfulfilled LineItemFulfillmentStatus = "UNFULFILLED"
-	LineItemFulfillmentStatusFulfilled   LineItemFulfillmentStatus = "FULFILLED"
-	LineItemFulfillmentStatusRestocked   LineItemFulfillmentStatus = "RESTOCKED"
-)
-
-var AllLineItemFulfillmentStatus = []LineItemFulfillmentStatus{
-	LineItemFulfillmentStatusUnfulfilled,
-	LineItemFulfillmentStatusFulfilled,
-	LineItemFulfillmentStatusRestocked,
-}
-
-func (e LineItemFulfillmentStatus) IsValid() bool {
-	switch e {
-	case LineItemFulfillmentStatusUnfulfilled, LineItemFulfillmentStatusFulfilled, LineItemFulfillmentStatusRestocked:
-		return true
-	}
-	return false
-}
-
-func (e LineItemFulfillmentStatus) String() string {
-	return string(e)
-}
-
-func (e *LineItemFulfillmentStatus) UnmarshalGQL(v interface{}) error {
-	str, ok := v.(string)
-	if !ok {
-		return fmt.Errorf("enums must be strings")
-	}
-
-	*e = LineItemFulfillmentStatus(str)
-	if !e.IsValid() {
-		return fmt.Errorf("%s is not a valid LineItemFulfillmentStatus", str)
-	}
-	return nil
-}
-
-func (e LineItemFulfillmentStatus) MarshalGQL(w io.Writer) {
-	fmt.Fprint(w, strconv.Quote(e.String()))
-}
-
-type TransactionKind string
-
-const (
-	TransactionKindAuthorization TransactionKind = "AUTHORIZATION"
-	TransactionKindCapture       TransactionKind = "CAPTURE"
-	TransactionKindSale          TransactionKind = "SALE"
-	TransactionKindVoid          TransactionKind = "VOID"
-	TransactionKindRefund        TransactionKind = "REFUND"
-)
-
-var AllTransactionKind = []TransactionKind{
-	TransactionKindAuthorization,
-	TransactionKindCapture,
-	TransactionKindSale,
-	TransactionKindVoid,
-	TransactionKindRefund,
-}
-
-func (e TransactionKind) IsValid() bool {
-	switch e {
-	case TransactionKindAuthorization, TransactionKindCapture, TransactionKindSale, TransactionKindVoid, TransactionKindRefund:
-		return true
-	}
-	return false
-}
-
-func (e TransactionKind) String() string {
-	return string(e)
-}
-
-func (e *TransactionKind) UnmarshalGQL(v interface{}) error {
-	str, ok := v.(string)
-	if !ok {
-		return fmt.Errorf("enums must be strings")
-	}
-
-	*e = TransactionKind(str)
-	if !e.IsValid() {
-		return fmt.Errorf("%s is not a valid TransactionKind", str)
-	}
-	return nil
-}
-
-func (e TransactionKind) MarshalGQL(w io.Writer) {
-	fmt.Fprint(w, strconv.Quote(e.String()))
-}
-
-type TransactionStatus string
-
-const (
-	TransactionStatusPending TransactionStatus = "PENDING"
-	TransactionStatusSuccess TransactionStatus = "SUCCESS"
-	TransactionStatusFailure TransactionStatus = "FAILURE"
-	TransactionStatusError   TransactionStatus = "ERROR"
-)
-
-var AllTransactionStatus = []TransactionStatus{
-	TransactionStatusPending,
-	TransactionStatusSuccess,
-	TransactionStatusFailure,
-	TransactionStatusError,
-}
-
-func (e TransactionStatus) IsValid() bool {
-	switch e {
-	case TransactionStatusPending, TransactionStatusSuccess, TransactionStatusFailure, TransactionStatusError:
-		return true
-	}
-	return false
-}
-
-func (e TransactionStatus) String() string {
-	return string(e)
-}
-
-func (e *TransactionStatus) UnmarshalGQL(v interface{}) error {
-	str, ok := v.(string)
-	if !ok {
-		return fmt.Errorf("enums must be strings")
-	}
-
-	*e = TransactionStatus(str)
-	if !e.IsValid() {
-		return fmt.Errorf("%s is not a valid TransactionStatus", str)
-	}
-	return nil
-}
-
-func (e TransactionStatus) MarshalGQL(w io.Writer) {
-	fmt.Fprint(w, strconv.Quote(e.String()))
-}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"time"
 	"unified-commerce/services/order/models"
+	"unified-commerce/services/shared/tenant"
 
 	"github.com/google/uuid"
 )
@@ -49,8 +50,15 @@ func (r *mutationResolver) CreateOrder(ctx context.Context, input CreateOrderInp
 	now := time.Now()
 	orderID := uuid.New()
 
-	// Use available fields from input
-	merchantID, _ := uuid.Parse(input.MerchantID)
+	// The order belongs to the merchant the request is scoped to
+	merchant, err := tenant.OwnerMerchantID(ctx, input.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	merchantID, err := uuid.Parse(merchant)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merchant ID")
+	}
 	var customerID *uuid.UUID
 	if input.CustomerID != nil {
 		id, _ := uuid.Parse(*input.CustomerID)
@@ -98,7 +106,11 @@ func (r *mutationResolver) FulfillOrder(ctx context.Context, id string, input Fu
 
 // CapturePayment is the resolver for the capturePayment field.
 func (r *mutationResolver) CapturePayment(ctx context.Context, id string, amount *float64) (*models.Order, error) {
-	panic(fmt.Errorf("not implemented: CapturePayment - capturePayment"))
+	orderID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid order ID")
+	}
+	return r.OrderService.RequestPaymentCapture(ctx, orderID, amount, nil)
 }
 
 // RefundOrder is the resolver for the refundOrder field.
@@ -211,11 +223,6 @@ func (r *orderLineItemResolver) FulfillmentService(ctx context.Context, obj *mod
 	panic(fmt.Errorf("not implemented: FulfillmentService - fulfillmentService"))
 }
 
-// FulfillmentStatus is the resolver for the fulfillmentStatus field.
-func (r *orderLineItemResolver) FulfillmentStatus(ctx context.Context, obj *models.OrderLineItem) (LineItemFulfillmentStatus, error) {
-	panic(fmt.Errorf("not implemented: FulfillmentStatus - fulfillmentStatus"))
-}
-
 // Properties is the resolver for the properties field.
 func (r *orderLineItemResolver) Properties(ctx context.Context, obj *models.OrderLineItem) (*string, error) {
 	panic(fmt.Errorf("not implemented: Properties - properties"))
@@ -249,9 +256,48 @@ func (r *queryResolver) Order(ctx context.Context, id string) (*models.Order, er
 }
 
 // Orders is the resolver for the orders field.
-func (r *queryResolver) Orders(ctx context.Context, filter *OrderFilter) ([]*models.Order, error) {
-	// Basic implementation for federation testing
-	return []*models.Order{}, nil
+func (r *queryResolver) Orders(ctx context.Context, filter *OrderFilter, first *int, after *string) (*OrderConnection, error) {
+	if filter == nil {
+		filter = &OrderFilter{}
+	}
+
+	merchant := stringValue(filter.MerchantID)
+	if merchant == "" {
+		merchant, _ = tenant.MerchantID(ctx)
+	}
+	merchantID, err := uuid.Parse(merchant)
+	if err != nil {
+		return nil, fmt.Errorf("merchantId is required")
+	}
+
+	query, err := orderQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := 0
+	if first != nil {
+		limit = *first
+	}
+	page, err := r.OrderService.SearchOrders(ctx, merchantID, query, stringValue(after), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	connection := &OrderConnection{
+		Edges:    make([]*OrderEdge, 0, len(page.Orders)),
+		PageInfo: &PageInfo{HasNextPage: page.HasMore},
+	}
+	for _, order := range page.Orders {
+		connection.Edges = append(connection.Edges, &OrderEdge{
+			Cursor: models.CursorFor(order).Encode(),
+			Node:   order,
+		})
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+	return connection, nil
 }
 
 // OrderByNumber is the resolver for the orderByNumber field.
@@ -269,11 +315,6 @@ func (r *transactionResolver) ID(ctx context.Context, obj *models.Transaction) (
 	panic(fmt.Errorf("not implemented: ID - id"))
 }
 
-// Kind is the resolver for the kind field.
-func (r *transactionResolver) Kind(ctx context.Context, obj *models.Transaction) (TransactionKind, error) {
-	panic(fmt.Errorf("not implemented: Kind - kind"))
-}
-
 // PaymentMethodID is the resolver for the paymentMethodId field.
 func (r *transactionResolver) PaymentMethodID(ctx context.Context, obj *models.Transaction) (*string, error) {
 	panic(fmt.Errorf("not implemented: PaymentMethodID - paymentMethodId"))
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/models"
+	"unified-commerce/services/order/service"
+	httputil "unified-commerce/services/shared/http"
+	"unified-commerce/services/shared/middleware"
+)
+
+// Draft Order Handlers
+
+// CreateDraftOrder handles creating a draft order for a phone or email sale
+func (h *OrderHandler) CreateDraftOrder(c *gin.Context) {
+	var req service.CreateDraftOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	merchantID, ok := middleware.TenantMerchantID(c)
+	if !ok {
+		return
+	}
+	req.MerchantID = merchantID
+
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
+	draft, err := h.service.CreateDraftOrder(c.Request.Context(), &req, currentUserID(c))
+	if err != nil {
+		h.draftOrderError(c, err, "Failed to create draft order")
+		return
+	}
+
+	httputil.Created(c, draft, "Draft order created successfully")
+}
+
+// GetDraftOrders handles listing a merchant's draft orders, optionally of
+// one status
+func (h *OrderHandler) GetDraftOrders(c *gin.Context) {
+	merchantID := c.Query("merchant_id")
+	if merchantID == "" {
+		httputil.BadRequest(c, "merchant_id is required")
+		return
+	}
+
+	merchantUUID, err := uuid.Parse(merchantID)
+	if err != nil {
+		httputil.BadRequest(c, "Invalid merchant ID")
+		return
+	}
+
+	pagination := httputil.GetPaginationParams(c)
+	status := models.DraftOrderStatus(c.Query("status"))
+	drafts, total, err := h.service.GetDraftOrdersByMerchant(c.Request.Context(), merchantUUID, status, pagination.Page, pagination.PerPage)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get draft orders")
+		httputil.InternalServerError(c, "Failed to get draft orders")
+		return
+	}
+
+	httputil.SuccessWithMeta(c, drafts, &httputil.MetaInfo{
+		Page:    pagination.Page,
+		PerPage: pagination.PerPage,
+		Total:   total,
+	}, "Draft orders retrieved successfully")
+}
+
+// GetDraftOrder handles retrieving a draft order
+func (h *OrderHandler) GetDraftOrder(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid draft order ID")
+		return
+	}
+
+	draft, err := h.service.GetDraftOrder(c.Request.Context(), id)
+	if err != nil {
+		h.draftOrderError(c, err, "Failed to get draft order")
+		return
+	}
+
+	httputil.Success(c, draft, "Draft order retrieved successfully")
+}
+
+// UpdateDraftOrder handles changing a draft order
+func (h *OrderHandler) UpdateDraftOrder(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid draft order ID")
+		return
+	}
+
+	var req service.UpdateDraftOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
+	draft, err := h.service.UpdateDraftOrder(c.Request.Context(), id, &req)
+	if err != nil {
+		h.draftOrderError(c, err, "Failed to update draft order")
+		return
+	}
+
+	httputil.Success(c, draft, "Draft order updated successfully")
+}
+
+// SendDraftOrderInvoice handles sending a draft order's invoice with a new
+// payment link
+func (h *OrderHandler) SendDraftOrderInvoice(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid draft order ID")
+		return
+	}
+
+	var req service.SendDraftOrderInvoiceRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
+	invoice, err := h.service.SendDraftOrderInvoice(c.Request.Context(), id, &req)
+	if err != nil {
+		h.draftOrderError(c, err, "Failed to send draft order invoice")
+		return
+	}
+
+	httputil.Success(c, invoice, "Draft order invoice sent successfully")
+}
+
+// CancelDraftOrder handles cancelling a draft order
+func (h *OrderHandler) CancelDraftOrder(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid draft order ID")
+		return
+	}
+
+	draft, err := h.service.CancelDraftOrder(c.Request.Context(), id)
+	if err != nil {
+		h.draftOrderError(c, err, "Failed to cancel draft order")
+		return
+	}
+
+	httputil.Success(c, draft, "Draft order cancelled successfully")
+}
+
+// GetDraftOrderInvoice handles showing a draft order's invoice to the
+// customer holding its payment link
+func (h *OrderHandler) GetDraftOrderInvoice(c *gin.Context) {
+	draft, err := h.service.GetDraftOrderInvoice(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		h.draftOrderError(c, err, "Failed to get draft order invoice")
+		return
+	}
+
+	httputil.Success(c, draftOrderInvoiceView(draft), "Draft order invoice retrieved successfully")
+}
+
+// PayDraftOrder handles a customer paying a draft order's invoice. The
+// payment completes asynchronously; the invoice shows its outcome.
+func (h *OrderHandler) PayDraftOrder(c *gin.Context) {
+	var req service.PayDraftOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
+	draft, err := h.service.PayDraftOrder(c.Request.Context(), c.Param("token"), &req)
+	if err != nil {
+		h.draftOrderError(c, err, "Failed to pay draft order")
+		return
+	}
+
+	httputil.Accepted(c, draftOrderInvoiceView(draft), "Draft order payment requested")
+}
+
+// draftOrderInvoiceView returns the limited draft order information shown
+// to customers paying an invoice
+func draftOrderInvoiceView(draft *models.DraftOrder) map[string]interface{} {
+	lineItems := make([]map[string]interface{}, 0, len(draft.LineItems))
+	for _, lineItem := range draft.LineItems {
+		lineItems = append(lineItems, map[string]interface{}{
+			"name":           lineItem.Name,
+			"variant_title":  lineItem.VariantTitle,
+			"quantity":       lineItem.Quantity,
+			"price":          lineItem.Price,
+			"total_discount": lineItem.TotalDiscount,
+		})
+	}
+
+	return map[string]interface{}{
+		"draft_number":     draft.DraftNumber,
+		"status":           draft.Status,
+		"line_items":       lineItems,
+		"shipping_address": draft.ShippingAddress,
+		"shipping_method":  draft.ShippingMethod,
+		"currency":         draft.Currency,
+		"subtotal_price":   draft.SubtotalPrice,
+		"total_discount":   draft.TotalDiscount,
+		"total_shipping":   draft.TotalShipping,
+		"total_tax":        draft.TotalTax,
+		"total_price":      draft.TotalPrice,
+		"payment_error":    draft.PaymentError,
+		"expires_at":       draft.ExpiresAt,
+	}
+}
+
+// draftOrderError responds to an error from a draft order operation
+func (h *OrderHandler) draftOrderError(c *gin.Context, err error, message string) {
+	switch {
+	case err == service.ErrDraftOrderNotFound:
+		httputil.NotFound(c, "Draft order not found")
+	case errors.Is(err, service.ErrInvalidDiscount), errors.Is(err, service.ErrNoInvoiceRecipient):
+		httputil.BadRequest(c, err.Error())
+	case errors.Is(err, service.ErrInvalidStatus), errors.Is(err, service.ErrDraftOrderNotEditable),
+		errors.Is(err, service.ErrDraftOrderNotPayable), errors.Is(err, service.ErrDraftOrderExpired):
+		httputil.Conflict(c, err.Error())
+	default:
+		h.logger.WithError(err).Error(message)
+		httputil.InternalServerError(c, message)
+	}
+}
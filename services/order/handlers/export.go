@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/models"
+	httputil "unified-commerce/services/shared/http"
+)
+
+// orderExportColumns are the columns of an order CSV export, one row per
+// order
+var orderExportColumns = []string{
+	"id", "order_number", "created_at", "status", "payment_status", "fulfillment_status",
+	"source", "customer_id", "customer_email", "currency", "subtotal_price", "total_discount",
+	"total_shipping", "total_tax", "total_price", "taxes_included", "item_count", "tags",
+}
+
+// ExportOrders handles exporting a merchant's orders for accounting. The
+// orders matching the same filters as GetOrders are streamed newest first
+// as CSV, or as NDJSON with one order per line when format is ndjson.
+func (h *OrderHandler) ExportOrders(c *gin.Context) {
+	merchantID := c.Query("merchant_id")
+	if merchantID == "" {
+		httputil.BadRequest(c, "merchant_id is required")
+		return
+	}
+
+	merchantUUID, err := uuid.Parse(merchantID)
+	if err != nil {
+		httputil.BadRequest(c, "Invalid merchant ID")
+		return
+	}
+
+	query, err := parseOrderQuery(c)
+	if err == nil {
+		err = query.Validate()
+	}
+	if err != nil {
+		httputil.BadRequest(c, err.Error())
+		return
+	}
+
+	var write func([]*models.Order) error
+	switch format := c.DefaultQuery("format", "csv"); format {
+	case "csv":
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", `attachment; filename="orders.csv"`)
+		write = h.csvOrderWriter(c)
+	case "ndjson":
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="orders.ndjson"`)
+		write = h.ndjsonOrderWriter(c)
+	default:
+		httputil.BadRequest(c, "format must be csv or ndjson")
+		return
+	}
+	c.Status(http.StatusOK)
+
+	// The status is sent with the first rows, so a failure part way through
+	// can only cut the export short
+	if err := h.service.ExportOrders(c.Request.Context(), merchantUUID, query, write); err != nil {
+		if !errors.Is(err, c.Request.Context().Err()) {
+			h.logger.WithError(err).WithField("merchant_id", merchantUUID).Error("Failed to export orders")
+		}
+		_ = c.Error(err)
+	}
+}
+
+// csvOrderWriter returns a writer streaming batches of orders as CSV rows
+// after a header row
+func (h *OrderHandler) csvOrderWriter(c *gin.Context) func([]*models.Order) error {
+	w := csv.NewWriter(c.Writer)
+	header := false
+	return func(orders []*models.Order) error {
+		if !header {
+			if err := w.Write(orderExportColumns); err != nil {
+				return err
+			}
+			header = true
+		}
+		for _, order := range orders {
+			if err := w.Write(orderExportRow(order)); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		c.Writer.Flush()
+		return w.Error()
+	}
+}
+
+// ndjsonOrderWriter returns a writer streaming batches of orders as one
+// JSON document per line
+func (h *OrderHandler) ndjsonOrderWriter(c *gin.Context) func([]*models.Order) error {
+	encoder := json.NewEncoder(c.Writer)
+	return func(orders []*models.Order) error {
+		for _, order := range orders {
+			if err := encoder.Encode(order); err != nil {
+				return err
+			}
+		}
+		c.Writer.Flush()
+		return nil
+	}
+}
+
+// orderExportRow returns the order's CSV row in orderExportColumns order
+func orderExportRow(order *models.Order) []string {
+	customerID := ""
+	if order.CustomerID != nil {
+		customerID = order.CustomerID.String()
+	}
+	quantity := 0
+	for _, lineItem := range order.LineItems {
+		quantity += lineItem.Quantity
+	}
+
+	return []string{
+		order.ID.String(),
+		order.OrderNumber,
+		order.CreatedAt.UTC().Format(time.RFC3339),
+		string(order.Status),
+		string(order.PaymentStatus),
+		string(order.FulfillmentStatus),
+		string(order.Source),
+		customerID,
+		order.Customer.Email,
+		order.Currency,
+		formatAmount(order.SubtotalPrice),
+		formatAmount(order.TotalDiscount),
+		formatAmount(order.TotalShipping),
+		formatAmount(order.TotalTax),
+		formatAmount(order.TotalPrice),
+		strconv.FormatBool(order.TaxesIncluded),
+		strconv.Itoa(quantity),
+		strings.Join(order.Tags, ";"),
+	}
+}
+
+// formatAmount formats a money amount with two decimals
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
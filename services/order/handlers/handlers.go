@@ -1,19 +1,28 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 
+	"unified-commerce/services/order/models"
 	"unified-commerce/services/order/service"
 	httputil "unified-commerce/services/shared/http"
 	"unified-commerce/services/shared/logger"
 	"unified-commerce/services/shared/middleware"
+	"unified-commerce/services/shared/tenant"
 )
 
+// maxWebhookBytes bounds the size of carrier webhooks
+const maxWebhookBytes = 1 << 20
+
 // OrderHandler handles HTTP requests for order operations
 type OrderHandler struct {
 	service   *service.OrderService
@@ -30,37 +39,71 @@ func NewOrderHandler(service *service.OrderService, logger *logger.Logger) *Orde
 	}
 }
 
-// RegisterRoutes registers all order routes
-func (h *OrderHandler) RegisterRoutes(router *gin.Engine) {
+// RegisterRoutes registers all order routes. Payment captures, transactions,
+// refunds and draft order payments are deduplicated by their
+// Idempotency-Key in idempotency.
+func (h *OrderHandler) RegisterRoutes(router *gin.Engine, rateLimiter *middleware.RateLimiter, idempotency middleware.IdempotencyStore) {
 	v1 := router.Group("/api/v1")
 	{
 		// Protected routes (authentication required)
 		authConfig := middleware.DefaultAuthConfig()
 		protected := v1.Group("/")
-		protected.Use(middleware.JWTAuth(authConfig))
+		protected.Use(middleware.JWTAuth(authConfig), middleware.Tenant(), middleware.RateLimit(rateLimiter, middleware.MerchantRateLimitConfig()))
+		idempotent := middleware.Idempotency(idempotency, middleware.DefaultIdempotencyConfig())
 		{
 			// Order management
 			orders := protected.Group("/orders")
 			{
-				orders.POST("", h.CreateOrder)
-				orders.GET("", h.GetOrders)
+				orders.POST("", middleware.RequirePermission("orders", "create"), h.CreateOrder)
+				orders.GET("", middleware.RequirePermission("orders", "read"), h.GetOrders)
+				orders.GET("/export", middleware.RequirePermission("orders", "read"), h.ExportOrders)
 				orders.GET("/:id", h.GetOrder)
-				orders.PUT("/:id", h.UpdateOrder)
-				orders.POST("/:id/confirm", h.ConfirmOrder)
-				orders.POST("/:id/cancel", h.CancelOrder)
+				orders.PUT("/:id", middleware.RequirePermission("orders", "update"), h.UpdateOrder)
+				orders.POST("/:id/confirm", middleware.RequirePermission("orders", "update"), h.ConfirmOrder)
+				orders.POST("/:id/cancel", middleware.RequirePermission("orders", "update"), h.CancelOrder)
 
 				// Line item management
-				orders.POST("/:id/line-items", h.AddLineItem)
-				orders.PUT("/:id/line-items/:lineItemId", h.UpdateLineItem)
-				orders.DELETE("/:id/line-items/:lineItemId", h.RemoveLineItem)
+				orders.POST("/:id/line-items", middleware.RequirePermission("orders", "update"), h.AddLineItem)
+				orders.PUT("/:id/line-items/:lineItemId", middleware.RequirePermission("orders", "update"), h.UpdateLineItem)
+				orders.DELETE("/:id/line-items/:lineItemId", middleware.RequirePermission("orders", "update"), h.RemoveLineItem)
+
+				// Order editing
+				orders.POST("/:id/edits", middleware.RequirePermission("orders", "update"), h.BeginOrderEdit)
+
+				// Tracking links for shipping notifications
+				orders.GET("/:id/tracking-token", middleware.RequirePermission("orders", "read"), h.GetTrackingToken)
+
+				// Capture of authorized payments
+				orders.POST("/:id/capture", middleware.RequirePermission("transactions", "create"), idempotent, h.CapturePayment)
+
+				// Risk review
+				orders.GET("/:id/risk", middleware.RequirePermission("orders", "read"), h.GetRiskAssessment)
+				orders.POST("/:id/risk/approve", middleware.RequirePermission("orders", "update"), h.ApproveRiskReview)
+				orders.POST("/:id/risk/cancel", middleware.RequirePermission("orders", "update"), h.CancelRiskReview)
 
 				// Order lookup
 				orders.GET("/by-number/:orderNumber", h.GetOrderByNumber)
-				orders.GET("/customer/:customerId", h.GetOrdersByCustomer)
+				orders.GET("/customer/:customerId", middleware.RequirePermission("orders", "read"), h.GetOrdersByCustomer)
+			}
+
+			// Order edit sessions
+			orderEdits := protected.Group("/order-edits")
+			orderEdits.Use(middleware.RequirePermission("orders", "update"))
+			{
+				orderEdits.GET("/:id", h.GetOrderEdit)
+				orderEdits.POST("/:id/line-items", h.AddOrderEditLineItem)
+				orderEdits.PUT("/:id/line-items/:lineItemId", h.UpdateOrderEditLineItem)
+				orderEdits.DELETE("/:id/line-items/:lineItemId", h.RemoveOrderEditLineItem)
+				orderEdits.POST("/:id/commit", h.CommitOrderEdit)
+				orderEdits.POST("/:id/discard", h.DiscardOrderEdit)
 			}
 
+			// Review queue of orders held for risk
+			protected.GET("/risk-reviews", middleware.RequirePermission("orders", "read"), h.GetRiskReviews)
+
 			// Fulfillment management
 			fulfillments := protected.Group("/fulfillments")
+			fulfillments.Use(middleware.RequirePermission("fulfillments", "update"))
 			{
 				fulfillments.POST("", h.CreateFulfillment)
 				fulfillments.PUT("/:id", h.UpdateFulfillment)
@@ -70,8 +113,8 @@ func (h *OrderHandler) RegisterRoutes(router *gin.Engine) {
 			// Transaction management
 			transactions := protected.Group("/transactions")
 			{
-				transactions.POST("", h.CreateTransaction)
-				transactions.GET("/order/:orderId", h.GetTransactionsByOrder)
+				transactions.POST("", middleware.RequirePermission("transactions", "create"), idempotent, h.CreateTransaction)
+				transactions.GET("/order/:orderId", middleware.RequirePermission("transactions", "read"), h.GetTransactionsByOrder)
 			}
 
 			// Return management
@@ -79,23 +122,51 @@ func (h *OrderHandler) RegisterRoutes(router *gin.Engine) {
 			{
 				returns.POST("", h.CreateReturn)
 				returns.GET("/:id", h.GetReturn)
-				returns.PUT("/:id", h.UpdateReturn)
-				returns.POST("/:id/process", h.ProcessReturn)
+				returns.PUT("/:id", middleware.RequirePermission("returns", "update"), h.UpdateReturn)
+				returns.POST("/:id/approve", middleware.RequirePermission("returns", "update"), h.ApproveReturn)
+				returns.POST("/:id/reject", middleware.RequirePermission("returns", "update"), h.RejectReturn)
+				returns.POST("/:id/label", middleware.RequirePermission("returns", "update"), h.IssueReturnLabel)
+				returns.POST("/:id/receive", middleware.RequirePermission("returns", "update"), h.ReceiveReturn)
+				returns.POST("/:id/inspect", middleware.RequirePermission("returns", "update"), h.InspectReturn)
+				returns.POST("/:id/refund", middleware.RequirePermission("returns", "refund"), idempotent, h.RefundReturn)
 				returns.GET("/order/:orderId", h.GetReturnsByOrder)
 			}
 
+			// Draft orders for phone and email sales
+			draftOrders := protected.Group("/draft-orders")
+			{
+				draftOrders.POST("", middleware.RequirePermission("draft_orders", "create"), h.CreateDraftOrder)
+				draftOrders.GET("", middleware.RequirePermission("draft_orders", "read"), h.GetDraftOrders)
+				draftOrders.GET("/:id", middleware.RequirePermission("draft_orders", "read"), h.GetDraftOrder)
+				draftOrders.PUT("/:id", middleware.RequirePermission("draft_orders", "update"), h.UpdateDraftOrder)
+				draftOrders.POST("/:id/send-invoice", middleware.RequirePermission("draft_orders", "update"), h.SendDraftOrderInvoice)
+				draftOrders.POST("/:id/cancel", middleware.RequirePermission("draft_orders", "update"), h.CancelDraftOrder)
+			}
+
 			// Analytics and reporting
 			analytics := protected.Group("/analytics")
+			analytics.Use(middleware.RequirePermission("analytics", "read"))
 			{
 				analytics.GET("/orders/stats", h.GetOrderStats)
 				analytics.GET("/orders/:id/events", h.GetOrderEvents)
 			}
 		}
 
-		// Public routes for order tracking
+		// Public routes for order tracking and invoices
 		public := v1.Group("/public")
+		public.Use(middleware.RateLimit(rateLimiter, middleware.PublicRateLimitConfig()))
 		{
 			public.GET("/orders/:orderNumber/track", h.TrackOrder)
+
+			// Draft order invoices, paid through their payment links
+			public.GET("/draft-orders/:token", h.GetDraftOrderInvoice)
+			public.POST("/draft-orders/:token/pay", idempotent, h.PayDraftOrder)
+		}
+
+		// Carrier webhooks, authenticated by each carrier's signature
+		webhooks := v1.Group("/webhooks")
+		{
+			webhooks.POST("/carriers/:carrier", h.ReceiveCarrierWebhook)
 		}
 	}
 }
@@ -110,6 +181,15 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
+	merchantID, ok := middleware.TenantMerchantID(c)
+	if !ok {
+		return
+	}
+	req.MerchantID = merchantID
+	// Fraud checks compare orders by the address the request came from,
+	// resolved through the trusted proxies, never one the client sends
+	req.BrowserIP = c.ClientIP()
+
 	if err := h.validator.Struct(&req); err != nil {
 		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
 		return
@@ -125,7 +205,8 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	httputil.Created(c, order, "Order created successfully")
 }
 
-// GetOrders handles retrieving orders with filters
+// GetOrders handles searching a merchant's orders. Results are newest
+// first; pass the returned next_cursor as after to get the next page.
 func (h *OrderHandler) GetOrders(c *gin.Context) {
 	merchantID := c.Query("merchant_id")
 	if merchantID == "" {
@@ -139,21 +220,36 @@ func (h *OrderHandler) GetOrders(c *gin.Context) {
 		return
 	}
 
-	// Parse filters
-	filters := h.parseOrderFilters(c)
-	pagination := httputil.GetPaginationParams(c)
+	query, err := parseOrderQuery(c)
+	if err != nil {
+		httputil.BadRequest(c, err.Error())
+		return
+	}
 
-	orders, total, err := h.service.GetOrdersByMerchant(c.Request.Context(), merchantUUID, filters, pagination.Page, pagination.PerPage)
+	limit := service.DefaultSearchLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > service.MaxSearchLimit {
+			httputil.BadRequest(c, fmt.Sprintf("limit must be between 1 and %d", service.MaxSearchLimit))
+			return
+		}
+	}
+
+	page, err := h.service.SearchOrders(c.Request.Context(), merchantUUID, query, c.Query("after"), limit)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to get orders")
-		httputil.InternalServerError(c, "Failed to get orders")
+		if errors.Is(err, service.ErrInvalidOrderQuery) || errors.Is(err, service.ErrInvalidCursor) {
+			httputil.BadRequest(c, err.Error())
+		} else {
+			h.logger.WithError(err).Error("Failed to get orders")
+			httputil.InternalServerError(c, "Failed to get orders")
+		}
 		return
 	}
 
-	httputil.SuccessWithMeta(c, orders, &httputil.MetaInfo{
-		Page:    pagination.Page,
-		PerPage: pagination.PerPage,
-		Total:   total,
+	httputil.SuccessWithMeta(c, page.Orders, &httputil.MetaInfo{
+		PerPage:    limit,
+		NextCursor: page.NextCursor,
+		HasMore:    page.HasMore,
 	}, "Orders retrieved successfully")
 }
 
@@ -271,11 +367,11 @@ func (h *OrderHandler) ConfirmOrder(c *gin.Context) {
 	}
 
 	if err := h.service.ConfirmOrder(c.Request.Context(), id, userUUID); err != nil {
-		switch err {
-		case service.ErrOrderNotFound:
+		switch {
+		case err == service.ErrOrderNotFound:
 			httputil.NotFound(c, "Order not found")
-		case service.ErrInvalidStatus:
-			httputil.BadRequest(c, "Invalid order status for confirmation")
+		case errors.Is(err, service.ErrInvalidStatus):
+			httputil.Conflict(c, err.Error())
 		default:
 			h.logger.WithError(err).Error("Failed to confirm order")
 			httputil.InternalServerError(c, "Failed to confirm order")
@@ -318,13 +414,15 @@ func (h *OrderHandler) CancelOrder(c *gin.Context) {
 	}
 
 	if err := h.service.CancelOrder(c.Request.Context(), id, req.Reason, userUUID); err != nil {
-		switch err {
-		case service.ErrOrderNotFound:
+		switch {
+		case err == service.ErrOrderNotFound:
 			httputil.NotFound(c, "Order not found")
-		case service.ErrOrderAlreadyCancelled:
+		case err == service.ErrOrderAlreadyCancelled:
 			httputil.BadRequest(c, "Order is already cancelled")
-		case service.ErrOrderNotCancellable:
+		case err == service.ErrOrderNotCancellable:
 			httputil.BadRequest(c, "Order cannot be cancelled")
+		case errors.Is(err, service.ErrInvalidStatus), errors.Is(err, service.ErrRiskReviewClosed):
+			httputil.Conflict(c, err.Error())
 		default:
 			h.logger.WithError(err).Error("Failed to cancel order")
 			httputil.InternalServerError(c, "Failed to cancel order")
@@ -358,12 +456,7 @@ func (h *OrderHandler) AddLineItem(c *gin.Context) {
 
 	lineItem, err := h.service.AddLineItem(c.Request.Context(), orderID, &req)
 	if err != nil {
-		if err == service.ErrOrderNotFound {
-			httputil.NotFound(c, "Order not found")
-		} else {
-			h.logger.WithError(err).Error("Failed to add line item")
-			httputil.InternalServerError(c, "Failed to add line item")
-		}
+		h.orderEditError(c, err, "Failed to add line item")
 		return
 	}
 
@@ -384,14 +477,14 @@ func (h *OrderHandler) UpdateLineItem(c *gin.Context) {
 		return
 	}
 
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
 	lineItem, err := h.service.UpdateLineItem(c.Request.Context(), lineItemID, &req)
 	if err != nil {
-		if err == service.ErrLineItemNotFound {
-			httputil.NotFound(c, "Line item not found")
-		} else {
-			h.logger.WithError(err).Error("Failed to update line item")
-			httputil.InternalServerError(c, "Failed to update line item")
-		}
+		h.orderEditError(c, err, "Failed to update line item")
 		return
 	}
 
@@ -407,18 +500,195 @@ func (h *OrderHandler) RemoveLineItem(c *gin.Context) {
 	}
 
 	if err := h.service.RemoveLineItem(c.Request.Context(), lineItemID); err != nil {
-		if err == service.ErrLineItemNotFound {
-			httputil.NotFound(c, "Line item not found")
-		} else {
-			h.logger.WithError(err).Error("Failed to remove line item")
-			httputil.InternalServerError(c, "Failed to remove line item")
-		}
+		h.orderEditError(c, err, "Failed to remove line item")
 		return
 	}
 
 	httputil.Success(c, nil, "Line item removed successfully")
 }
 
+// Order Edit Handlers
+
+// BeginOrderEdit handles starting an edit of an order
+func (h *OrderHandler) BeginOrderEdit(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	// The body is optional
+	var req service.BeginOrderEditRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+			return
+		}
+	}
+
+	edit, err := h.service.BeginOrderEdit(c.Request.Context(), orderID, req.Notes, currentUserID(c))
+	if err != nil {
+		h.orderEditError(c, err, "Failed to begin order edit")
+		return
+	}
+
+	httputil.Created(c, edit, "Order edit started successfully")
+}
+
+// GetOrderEdit handles retrieving an order edit with a preview of its changes
+func (h *OrderHandler) GetOrderEdit(c *gin.Context) {
+	editID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid order edit ID")
+		return
+	}
+
+	edit, err := h.service.GetOrderEdit(c.Request.Context(), editID)
+	if err != nil {
+		h.orderEditError(c, err, "Failed to get order edit")
+		return
+	}
+
+	httputil.Success(c, edit, "Order edit retrieved successfully")
+}
+
+// AddOrderEditLineItem handles staging a new line item on an order edit
+func (h *OrderHandler) AddOrderEditLineItem(c *gin.Context) {
+	editID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid order edit ID")
+		return
+	}
+
+	var req service.AddLineItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
+	edit, err := h.service.AddOrderEditLineItem(c.Request.Context(), editID, &req)
+	if err != nil {
+		h.orderEditError(c, err, "Failed to stage line item")
+		return
+	}
+
+	httputil.Success(c, edit, "Line item staged successfully")
+}
+
+// UpdateOrderEditLineItem handles staging a line item change on an order edit
+func (h *OrderHandler) UpdateOrderEditLineItem(c *gin.Context) {
+	editID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid order edit ID")
+		return
+	}
+	lineItemID, err := uuid.Parse(c.Param("lineItemId"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid line item ID")
+		return
+	}
+
+	var req service.UpdateLineItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
+	edit, err := h.service.UpdateOrderEditLineItem(c.Request.Context(), editID, lineItemID, &req)
+	if err != nil {
+		h.orderEditError(c, err, "Failed to stage line item change")
+		return
+	}
+
+	httputil.Success(c, edit, "Line item change staged successfully")
+}
+
+// RemoveOrderEditLineItem handles staging a line item removal on an order edit
+func (h *OrderHandler) RemoveOrderEditLineItem(c *gin.Context) {
+	editID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid order edit ID")
+		return
+	}
+	lineItemID, err := uuid.Parse(c.Param("lineItemId"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid line item ID")
+		return
+	}
+
+	edit, err := h.service.RemoveOrderEditLineItem(c.Request.Context(), editID, lineItemID)
+	if err != nil {
+		h.orderEditError(c, err, "Failed to stage line item removal")
+		return
+	}
+
+	httputil.Success(c, edit, "Line item removal staged successfully")
+}
+
+// CommitOrderEdit handles applying an order edit to its order
+func (h *OrderHandler) CommitOrderEdit(c *gin.Context) {
+	editID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid order edit ID")
+		return
+	}
+
+	edit, err := h.service.CommitOrderEdit(c.Request.Context(), editID, currentUserID(c))
+	if err != nil {
+		h.orderEditError(c, err, "Failed to commit order edit")
+		return
+	}
+
+	httputil.Success(c, edit, "Order edit committed successfully")
+}
+
+// DiscardOrderEdit handles discarding an order edit
+func (h *OrderHandler) DiscardOrderEdit(c *gin.Context) {
+	editID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid order edit ID")
+		return
+	}
+
+	edit, err := h.service.DiscardOrderEdit(c.Request.Context(), editID)
+	if err != nil {
+		h.orderEditError(c, err, "Failed to discard order edit")
+		return
+	}
+
+	httputil.Success(c, edit, "Order edit discarded successfully")
+}
+
+// orderEditError responds to an error from a line item change or order edit
+func (h *OrderHandler) orderEditError(c *gin.Context, err error, message string) {
+	switch {
+	case err == service.ErrOrderNotFound:
+		httputil.NotFound(c, "Order not found")
+	case err == service.ErrOrderEditNotFound:
+		httputil.NotFound(c, "Order edit not found")
+	case err == service.ErrLineItemNotFound:
+		httputil.NotFound(c, "Line item not found")
+	case errors.Is(err, service.ErrLineItemNotInOrder), errors.Is(err, service.ErrInvalidOrderEdit):
+		httputil.BadRequest(c, err.Error())
+	case errors.Is(err, service.ErrOrderNotEditable), errors.Is(err, service.ErrOrderEditClosed),
+		errors.Is(err, service.ErrQuantityFulfilled), errors.Is(err, service.ErrOrderEditEmpty):
+		httputil.Conflict(c, err.Error())
+	default:
+		h.logger.WithError(err).Error(message)
+		httputil.InternalServerError(c, message)
+	}
+}
+
 // Fulfillment Management Handlers
 
 // CreateFulfillment handles creating a new fulfillment
@@ -438,6 +708,8 @@ func (h *OrderHandler) CreateFulfillment(c *gin.Context) {
 	if err != nil {
 		if err == service.ErrOrderNotFound {
 			httputil.NotFound(c, "Order not found")
+		} else if errors.Is(err, service.ErrInvalidStatus) {
+			httputil.Conflict(c, err.Error())
 		} else {
 			h.logger.WithError(err).Error("Failed to create fulfillment")
 			httputil.InternalServerError(c, "Failed to create fulfillment")
@@ -461,10 +733,7 @@ func (h *OrderHandler) MarkFulfillmentShipped(c *gin.Context) {
 		return
 	}
 
-	var req struct {
-		TrackingNumber string `json:"tracking_number" validate:"required"`
-		TrackingURL    string `json:"tracking_url"`
-	}
+	var req service.MarkFulfillmentShippedRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		httputil.BadRequest(c, "Invalid request body")
 		return
@@ -475,9 +744,11 @@ func (h *OrderHandler) MarkFulfillmentShipped(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.MarkFulfillmentShipped(c.Request.Context(), fulfillmentID, req.TrackingNumber, req.TrackingURL); err != nil {
+	if err := h.service.MarkFulfillmentShipped(c.Request.Context(), fulfillmentID, &req); err != nil {
 		if err == service.ErrFulfillmentNotFound {
 			httputil.NotFound(c, "Fulfillment not found")
+		} else if errors.Is(err, service.ErrInvalidStatus) {
+			httputil.Conflict(c, err.Error())
 		} else {
 			h.logger.WithError(err).Error("Failed to mark fulfillment as shipped")
 			httputil.InternalServerError(c, "Failed to mark fulfillment as shipped")
@@ -507,6 +778,8 @@ func (h *OrderHandler) CreateTransaction(c *gin.Context) {
 	if err != nil {
 		if err == service.ErrOrderNotFound {
 			httputil.NotFound(c, "Order not found")
+		} else if errors.Is(err, service.ErrInvalidStatus) {
+			httputil.Conflict(c, err.Error())
 		} else {
 			h.logger.WithError(err).Error("Failed to create transaction")
 			httputil.InternalServerError(c, "Failed to create transaction")
@@ -522,6 +795,50 @@ func (h *OrderHandler) GetTransactionsByOrder(c *gin.Context) {
 	httputil.Success(c, gin.H{"message": "Get transactions by order - to be implemented"})
 }
 
+// CapturePayment handles asking the payment service to capture an amount of
+// an order's authorized payment, or all that is left of it when no amount
+// is given. The capture is applied to the order once it is reported back.
+func (h *OrderHandler) CapturePayment(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	var req struct {
+		Amount *float64 `json:"amount" validate:"omitempty,gt=0"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
+	order, err := h.service.RequestPaymentCapture(c.Request.Context(), orderID, req.Amount, currentUserID(c))
+	if err != nil {
+		switch {
+		case err == service.ErrOrderNotFound:
+			httputil.NotFound(c, "Order not found")
+		case err == service.ErrNothingToCapture:
+			httputil.Conflict(c, err.Error())
+		case err == service.ErrInvalidCaptureAmount:
+			httputil.BadRequest(c, err.Error())
+		default:
+			h.logger.WithError(err).Error("Failed to request payment capture")
+			httputil.InternalServerError(c, "Failed to request payment capture")
+		}
+		return
+	}
+
+	httputil.Accepted(c, order, "Payment capture requested")
+}
+
 // Return Management Handlers
 
 // CreateReturn handles creating a return
@@ -539,12 +856,7 @@ func (h *OrderHandler) CreateReturn(c *gin.Context) {
 
 	returnItem, err := h.service.CreateReturn(c.Request.Context(), &req)
 	if err != nil {
-		if err == service.ErrOrderNotFound {
-			httputil.NotFound(c, "Order not found")
-		} else {
-			h.logger.WithError(err).Error("Failed to create return")
-			httputil.InternalServerError(c, "Failed to create return")
-		}
+		h.returnError(c, err, "Failed to create return")
 		return
 	}
 
@@ -553,16 +865,63 @@ func (h *OrderHandler) CreateReturn(c *gin.Context) {
 
 // GetReturn handles retrieving a return
 func (h *OrderHandler) GetReturn(c *gin.Context) {
-	httputil.Success(c, gin.H{"message": "Get return - to be implemented"})
+	returnID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid return ID")
+		return
+	}
+
+	returnItem, err := h.service.GetReturn(c.Request.Context(), returnID)
+	if err != nil {
+		h.returnError(c, err, "Failed to get return")
+		return
+	}
+
+	httputil.Success(c, returnItem)
 }
 
 // UpdateReturn handles updating a return
 func (h *OrderHandler) UpdateReturn(c *gin.Context) {
-	httputil.Success(c, gin.H{"message": "Update return - to be implemented"})
+	returnID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid return ID")
+		return
+	}
+
+	var req service.UpdateReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	returnItem, err := h.service.UpdateReturn(c.Request.Context(), returnID, &req)
+	if err != nil {
+		h.returnError(c, err, "Failed to update return")
+		return
+	}
+
+	httputil.Success(c, returnItem, "Return updated successfully")
 }
 
-// ProcessReturn handles processing a return
-func (h *OrderHandler) ProcessReturn(c *gin.Context) {
+// ApproveReturn handles authorizing a requested return
+func (h *OrderHandler) ApproveReturn(c *gin.Context) {
+	returnID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid return ID")
+		return
+	}
+
+	returnItem, err := h.service.ApproveReturn(c.Request.Context(), returnID, currentUserID(c))
+	if err != nil {
+		h.returnError(c, err, "Failed to approve return")
+		return
+	}
+
+	httputil.Success(c, returnItem, "Return approved successfully")
+}
+
+// RejectReturn handles denying a requested return
+func (h *OrderHandler) RejectReturn(c *gin.Context) {
 	returnID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		httputil.BadRequest(c, "Invalid return ID")
@@ -570,8 +929,7 @@ func (h *OrderHandler) ProcessReturn(c *gin.Context) {
 	}
 
 	var req struct {
-		RefundAmount float64 `json:"refund_amount" validate:"required,min=0"`
-		RestockItems bool    `json:"restock_items"`
+		Reason string `json:"reason" validate:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		httputil.BadRequest(c, "Invalid request body")
@@ -583,22 +941,150 @@ func (h *OrderHandler) ProcessReturn(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.ProcessReturn(c.Request.Context(), returnID, req.RefundAmount, req.RestockItems); err != nil {
-		if err == service.ErrReturnNotFound {
-			httputil.NotFound(c, "Return not found")
-		} else {
-			h.logger.WithError(err).Error("Failed to process return")
-			httputil.InternalServerError(c, "Failed to process return")
-		}
+	returnItem, err := h.service.RejectReturn(c.Request.Context(), returnID, req.Reason, currentUserID(c))
+	if err != nil {
+		h.returnError(c, err, "Failed to reject return")
+		return
+	}
+
+	httputil.Success(c, returnItem, "Return rejected successfully")
+}
+
+// IssueReturnLabel handles recording the shipping label of a return
+func (h *OrderHandler) IssueReturnLabel(c *gin.Context) {
+	returnID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid return ID")
+		return
+	}
+
+	var req service.IssueReturnLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
+	returnItem, err := h.service.IssueReturnLabel(c.Request.Context(), returnID, &req, currentUserID(c))
+	if err != nil {
+		h.returnError(c, err, "Failed to issue return label")
+		return
+	}
+
+	httputil.Success(c, returnItem, "Return label issued successfully")
+}
+
+// ReceiveReturn handles recording the arrival of a return's items
+func (h *OrderHandler) ReceiveReturn(c *gin.Context) {
+	returnID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid return ID")
+		return
+	}
+
+	var req service.ReceiveReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
+	returnItem, err := h.service.ReceiveReturn(c.Request.Context(), returnID, &req, currentUserID(c))
+	if err != nil {
+		h.returnError(c, err, "Failed to receive return")
+		return
+	}
+
+	httputil.Success(c, returnItem, "Return received successfully")
+}
+
+// InspectReturn handles recording the inspection of a received return
+func (h *OrderHandler) InspectReturn(c *gin.Context) {
+	returnID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid return ID")
 		return
 	}
 
-	httputil.Success(c, nil, "Return processed successfully")
+	var req service.InspectReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
+	returnItem, err := h.service.InspectReturn(c.Request.Context(), returnID, &req, currentUserID(c))
+	if err != nil {
+		h.returnError(c, err, "Failed to inspect return")
+		return
+	}
+
+	httputil.Success(c, returnItem, "Return inspected successfully")
+}
+
+// RefundReturn handles refunding an inspected return
+func (h *OrderHandler) RefundReturn(c *gin.Context) {
+	returnID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid return ID")
+		return
+	}
+
+	returnItem, err := h.service.RefundReturn(c.Request.Context(), returnID, currentUserID(c))
+	if err != nil {
+		h.returnError(c, err, "Failed to refund return")
+		return
+	}
+
+	httputil.Success(c, returnItem, "Return refunded successfully")
 }
 
 // GetReturnsByOrder handles retrieving returns for an order
 func (h *OrderHandler) GetReturnsByOrder(c *gin.Context) {
-	httputil.Success(c, gin.H{"message": "Get returns by order - to be implemented"})
+	orderID, err := uuid.Parse(c.Param("orderId"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	returns, err := h.service.GetReturnsByOrder(c.Request.Context(), orderID)
+	if err != nil {
+		h.returnError(c, err, "Failed to get returns")
+		return
+	}
+
+	httputil.Success(c, returns)
+}
+
+// returnError responds to an error from a return or a step of its workflow
+func (h *OrderHandler) returnError(c *gin.Context, err error, message string) {
+	switch {
+	case err == service.ErrOrderNotFound:
+		httputil.NotFound(c, "Order not found")
+	case err == service.ErrReturnNotFound:
+		httputil.NotFound(c, "Return not found")
+	case errors.Is(err, service.ErrLineItemNotInOrder), errors.Is(err, service.ErrReturnLineItem),
+		errors.Is(err, service.ErrInvalidReturnReceipt):
+		httputil.BadRequest(c, err.Error())
+	case errors.Is(err, service.ErrInvalidStatus), errors.Is(err, service.ErrReturnNotEditable),
+		errors.Is(err, service.ErrReturnQuantity):
+		httputil.Conflict(c, err.Error())
+	default:
+		h.logger.WithError(err).Error(message)
+		httputil.InternalServerError(c, message)
+	}
 }
 
 // Analytics and Reporting Handlers
@@ -663,7 +1149,8 @@ func (h *OrderHandler) GetOrderEvents(c *gin.Context) {
 
 // Public Handlers
 
-// TrackOrder handles public order tracking
+// TrackOrder handles public order tracking. Customers identify themselves
+// with the email the order was placed with or the token of a tracking link.
 func (h *OrderHandler) TrackOrder(c *gin.Context) {
 	orderNumber := c.Param("orderNumber")
 	if orderNumber == "" {
@@ -671,76 +1158,180 @@ func (h *OrderHandler) TrackOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := h.service.GetOrderByNumber(c.Request.Context(), orderNumber)
+	// Tracking is public, so the lookup is not scoped to a merchant; only
+	// the order's tracking is returned
+	tracking, err := h.service.TrackOrder(tenant.WithoutScope(c.Request.Context()), orderNumber, c.Query("email"), c.Query("token"))
 	if err != nil {
-		if err == service.ErrOrderNotFound {
+		switch {
+		case errors.Is(err, service.ErrTrackingCredentials):
+			httputil.Unauthorized(c, err.Error())
+		case errors.Is(err, service.ErrOrderNotFound), errors.Is(err, service.ErrTrackingTokenDisabled):
 			httputil.NotFound(c, "Order not found")
-		} else {
+		default:
 			h.logger.WithError(err).Error("Failed to track order")
 			httputil.InternalServerError(c, "Failed to track order")
 		}
 		return
 	}
 
-	// Return limited order information for public tracking
-	trackingInfo := map[string]interface{}{
-		"order_number":       order.OrderNumber,
-		"status":             order.Status,
-		"fulfillment_status": order.FulfillmentStatus,
-		"tracking_number":    order.TrackingNumber,
-		"tracking_url":       order.TrackingURL,
-		"carrier":            order.Carrier,
-		"shipped_at":         order.ShippedAt,
-		"delivered_at":       order.DeliveredAt,
-		"created_at":         order.CreatedAt,
+	httputil.Success(c, tracking, "Order tracking information retrieved successfully")
+}
+
+// GetTrackingToken handles retrieving the token of an order's tracking link
+func (h *OrderHandler) GetTrackingToken(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	token, err := h.service.GetTrackingToken(c.Request.Context(), orderID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOrderNotFound):
+			httputil.NotFound(c, "Order not found")
+		case errors.Is(err, service.ErrTrackingTokenDisabled):
+			httputil.Conflict(c, err.Error())
+		default:
+			h.logger.WithError(err).Error("Failed to get tracking token")
+			httputil.InternalServerError(c, "Failed to get tracking token")
+		}
+		return
 	}
 
-	httputil.Success(c, trackingInfo, "Order tracking information retrieved successfully")
+	httputil.Success(c, map[string]interface{}{"token": token}, "Tracking token retrieved successfully")
+}
+
+// ReceiveCarrierWebhook handles a carrier's shipment status webhook. Carriers
+// retry webhooks that fail, so statuses of unknown shipments are accepted
+// and ignored.
+func (h *OrderHandler) ReceiveCarrierWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBytes))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	// Webhooks report shipments of every merchant
+	recorded, err := h.service.ReceiveCarrierWebhook(tenant.WithoutScope(c.Request.Context()), c.Param("carrier"), c.Request.Header, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownCarrier):
+			httputil.NotFound(c, "Carrier not found")
+		case errors.Is(err, service.ErrWebhookSignature):
+			httputil.Unauthorized(c, err.Error())
+		case errors.Is(err, service.ErrInvalidWebhook):
+			httputil.BadRequest(c, err.Error())
+		default:
+			h.logger.WithError(err).Error("Failed to process carrier webhook")
+			httputil.InternalServerError(c, "Failed to process carrier webhook")
+		}
+		return
+	}
+
+	httputil.Success(c, map[string]interface{}{"recorded": recorded}, "Carrier webhook processed successfully")
 }
 
 // Helper Methods
 
-// parseOrderFilters parses query parameters into order filters
-func (h *OrderHandler) parseOrderFilters(c *gin.Context) map[string]interface{} {
-	filters := make(map[string]interface{})
+// parseOrderQuery parses query parameters into an order query. Status,
+// source and tag parameters take comma-separated lists and dates are either
+// RFC 3339 times or whole days.
+func parseOrderQuery(c *gin.Context) (*models.OrderQuery, error) {
+	query := &models.OrderQuery{
+		Statuses:            queryList[models.OrderStatus](c, "status"),
+		FulfillmentStatuses: queryList[models.FulfillmentStatus](c, "fulfillment_status"),
+		PaymentStatuses:     queryList[models.PaymentStatus](c, "payment_status"),
+		Sources:             queryList[models.OrderSource](c, "source"),
+		Tags:                queryList[string](c, "tags"),
+		CustomerEmail:       strings.TrimSpace(c.Query("email")),
+		SKU:                 strings.TrimSpace(c.Query("sku")),
+		Search:              strings.TrimSpace(c.Query("q")),
+	}
+
+	if customerID := c.Query("customer_id"); customerID != "" {
+		id, err := uuid.Parse(customerID)
+		if err != nil {
+			return nil, errors.New("invalid customer_id")
+		}
+		query.CustomerID = &id
+	}
 
-	if status := c.Query("status"); status != "" {
-		filters["status"] = status
+	var err error
+	if query.CreatedFrom, err = queryTime(c, "date_from", false); err != nil {
+		return nil, err
 	}
-	if paymentStatus := c.Query("payment_status"); paymentStatus != "" {
-		filters["payment_status"] = paymentStatus
+	if query.CreatedTo, err = queryTime(c, "date_to", true); err != nil {
+		return nil, err
 	}
-	if fulfillmentStatus := c.Query("fulfillment_status"); fulfillmentStatus != "" {
-		filters["fulfillment_status"] = fulfillmentStatus
+	if query.MinTotal, err = queryAmount(c, "min_total"); err != nil {
+		return nil, err
 	}
-	if source := c.Query("source"); source != "" {
-		filters["source"] = source
+	if query.MaxTotal, err = queryAmount(c, "max_total"); err != nil {
+		return nil, err
 	}
-	if customerID := c.Query("customer_id"); customerID != "" {
-		if id, err := uuid.Parse(customerID); err == nil {
-			filters["customer_id"] = id
+
+	return query, nil
+}
+
+// queryList returns the non-empty values of a comma-separated query
+// parameter
+func queryList[T ~string](c *gin.Context, key string) []T {
+	var values []T
+	for _, value := range strings.Split(c.Query(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, T(value))
 		}
 	}
-	if dateFrom := c.Query("date_from"); dateFrom != "" {
-		if t, err := time.Parse("2006-01-02", dateFrom); err == nil {
-			filters["date_from"] = t
-		}
+	return values
+}
+
+// queryTime parses a time query parameter. A whole day starts at midnight,
+// or ends at the following midnight when endOfDay is set.
+func queryTime(c *gin.Context, key string, endOfDay bool) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
 	}
-	if dateTo := c.Query("date_to"); dateTo != "" {
-		if t, err := time.Parse("2006-01-02", dateTo); err == nil {
-			filters["date_to"] = t
-		}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
 	}
-	if minTotal := c.Query("min_total"); minTotal != "" {
-		if amount, err := strconv.ParseFloat(minTotal, 64); err == nil {
-			filters["min_total"] = amount
-		}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", key)
 	}
-	if maxTotal := c.Query("max_total"); maxTotal != "" {
-		if amount, err := strconv.ParseFloat(maxTotal, 64); err == nil {
-			filters["max_total"] = amount
-		}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
 	}
+	return &t, nil
+}
 
-	return filters
+// queryAmount parses a non-negative amount query parameter
+func queryAmount(c *gin.Context, key string) (*float64, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || amount < 0 {
+		return nil, fmt.Errorf("invalid %s", key)
+	}
+	return &amount, nil
+}
+
+// currentUserID returns the authenticated user's ID, if any
+func currentUserID(c *gin.Context) *uuid.UUID {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		return nil
+	}
+	uid, ok := userID.(string)
+	if !ok {
+		return nil
+	}
+	parsed, err := uuid.Parse(uid)
+	if err != nil {
+		return nil
+	}
+	return &parsed
 }
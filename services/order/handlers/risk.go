@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/models"
+	"unified-commerce/services/order/service"
+	httputil "unified-commerce/services/shared/http"
+)
+
+// Risk Review Handlers
+
+// GetRiskAssessment handles retrieving an order's risk assessment
+func (h *OrderHandler) GetRiskAssessment(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	assessment, err := h.service.GetRiskAssessment(c.Request.Context(), orderID)
+	if err != nil {
+		h.riskError(c, err, "Failed to get risk assessment")
+		return
+	}
+
+	httputil.Success(c, assessment, "Risk assessment retrieved successfully")
+}
+
+// GetRiskReviews handles listing a merchant's risk reviews with their
+// orders, the pending ones unless another status is given
+func (h *OrderHandler) GetRiskReviews(c *gin.Context) {
+	merchantID := c.Query("merchant_id")
+	if merchantID == "" {
+		httputil.BadRequest(c, "merchant_id is required")
+		return
+	}
+
+	merchantUUID, err := uuid.Parse(merchantID)
+	if err != nil {
+		httputil.BadRequest(c, "Invalid merchant ID")
+		return
+	}
+
+	status := models.RiskReviewStatus(c.DefaultQuery("status", string(models.RiskReviewPending)))
+	pagination := httputil.GetPaginationParams(c)
+	assessments, total, err := h.service.GetRiskReviews(c.Request.Context(), merchantUUID, status, pagination.Page, pagination.PerPage)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get risk reviews")
+		httputil.InternalServerError(c, "Failed to get risk reviews")
+		return
+	}
+
+	httputil.SuccessWithMeta(c, assessments, &httputil.MetaInfo{
+		Page:    pagination.Page,
+		PerPage: pagination.PerPage,
+		Total:   total,
+	}, "Risk reviews retrieved successfully")
+}
+
+// ApproveRiskReview handles releasing an order held for risk review
+func (h *OrderHandler) ApproveRiskReview(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			httputil.BadRequest(c, "Invalid request body")
+			return
+		}
+	}
+
+	assessment, err := h.service.ApproveRiskReview(c.Request.Context(), orderID, req.Note, currentUserID(c))
+	if err != nil {
+		h.riskError(c, err, "Failed to approve risk review")
+		return
+	}
+
+	httputil.Success(c, assessment, "Order approved successfully")
+}
+
+// CancelRiskReview handles cancelling an order held for risk review
+func (h *OrderHandler) CancelRiskReview(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason" validate:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
+	assessment, err := h.service.CancelRiskReview(c.Request.Context(), orderID, req.Reason, currentUserID(c))
+	if err != nil {
+		h.riskError(c, err, "Failed to cancel risk review")
+		return
+	}
+
+	httputil.Success(c, assessment, "Order cancelled successfully")
+}
+
+// riskError responds to an error from a risk assessment or review
+func (h *OrderHandler) riskError(c *gin.Context, err error, message string) {
+	switch {
+	case err == service.ErrRiskAssessmentNotFound:
+		httputil.NotFound(c, "Risk assessment not found")
+	case errors.Is(err, service.ErrRiskReviewClosed), errors.Is(err, service.ErrInvalidStatus):
+		httputil.Conflict(c, err.Error())
+	default:
+		h.logger.WithError(err).Error(message)
+		httputil.InternalServerError(c, message)
+	}
+}
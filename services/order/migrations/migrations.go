@@ -0,0 +1,411 @@
+// Package migrations holds the order service's versioned schema
+// migrations. Add a migration for every schema change and never edit one
+// that has been released.
+package migrations
+
+import (
+	"unified-commerce/services/order/migrations/baseline"
+	"unified-commerce/services/shared/database"
+)
+
+// All returns the order service's migrations
+func All() []database.Migration {
+	return []database.Migration{
+		{
+			// Tables as AutoMigrate created them before versioned
+			// migrations, which adopts existing databases unchanged. The
+			// models are frozen in the baseline package.
+			Version: 1,
+			Name:    "baseline",
+			Up:      database.AutoMigrateModels(baseline.Models()...),
+			Down:    database.DropModels(baseline.Models()...),
+		},
+		{
+			// Payment details on transactions, used to derive the order's
+			// payment status
+			Version: 2,
+			Name:    "transaction_payment_details",
+			Up: database.ExecSQL(
+				`ALTER TABLE transactions
+					ADD COLUMN IF NOT EXISTS payment_id UUID,
+					ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'sale',
+					ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'success',
+					ADD COLUMN IF NOT EXISTS amount DECIMAL(12,2) DEFAULT 0,
+					ADD COLUMN IF NOT EXISTS currency TEXT DEFAULT 'USD'`,
+				`CREATE INDEX IF NOT EXISTS idx_transactions_payment_id ON transactions (payment_id)`,
+			),
+			Down: database.ExecSQL(
+				`DROP INDEX IF EXISTS idx_transactions_payment_id`,
+				`ALTER TABLE transactions
+					DROP COLUMN IF EXISTS payment_id,
+					DROP COLUMN IF EXISTS kind,
+					DROP COLUMN IF EXISTS status,
+					DROP COLUMN IF EXISTS amount,
+					DROP COLUMN IF EXISTS currency`,
+			),
+		},
+		{
+			// Tax classes, tax-inclusive pricing and shipping taxes
+			Version: 3,
+			Name:    "order_taxes",
+			Up: database.ExecSQL(
+				`ALTER TABLE orders
+					ADD COLUMN IF NOT EXISTS taxes_included BOOLEAN DEFAULT false,
+					ADD COLUMN IF NOT EXISTS shipping_tax_lines JSONB`,
+				`ALTER TABLE order_line_items ADD COLUMN IF NOT EXISTS tax_class TEXT`,
+			),
+			Down: database.ExecSQL(
+				`ALTER TABLE order_line_items DROP COLUMN IF EXISTS tax_class`,
+				`ALTER TABLE orders
+					DROP COLUMN IF EXISTS taxes_included,
+					DROP COLUMN IF EXISTS shipping_tax_lines`,
+			),
+		},
+		{
+			// Order edit sessions staging line item changes
+			Version: 4,
+			Name:    "order_edits",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS order_edits (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					order_id UUID NOT NULL REFERENCES orders (id),
+					status TEXT NOT NULL DEFAULT 'open',
+					changes JSONB,
+					notes TEXT,
+					user_id UUID,
+					original_total_price DECIMAL(12,2) DEFAULT 0,
+					subtotal_price DECIMAL(12,2) DEFAULT 0,
+					total_tax DECIMAL(12,2) DEFAULT 0,
+					total_price DECIMAL(12,2) DEFAULT 0,
+					payment_delta DECIMAL(12,2) DEFAULT 0,
+					committed_at TIMESTAMPTZ,
+					discarded_at TIMESTAMPTZ,
+					created_at TIMESTAMPTZ,
+					updated_at TIMESTAMPTZ
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_order_edits_order_id ON order_edits (order_id)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS order_edits`,
+			),
+		},
+		{
+			// Deduplication of consumed events, starting with the
+			// fulfillments routed by inventory
+			Version: 5,
+			Name:    "processed_events",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS processed_events (
+					consumer TEXT NOT NULL,
+					event_id TEXT NOT NULL,
+					processed_at TIMESTAMPTZ,
+					PRIMARY KEY (consumer, event_id)
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_processed_events_processed_at ON processed_events (processed_at)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS processed_events`,
+			),
+		},
+		{
+			// Routed fulfillments stay unfulfilled until they ship, while
+			// every earlier fulfillment was counted when it was created.
+			// Reverting leaves them fulfilled, which older code ignores.
+			Version: 6,
+			Name:    "fulfillment_status_backfill",
+			Up:      database.ExecSQL(`UPDATE fulfillments SET status = 'fulfilled' WHERE status <> 'fulfilled'`),
+			Down:    database.ExecSQL(),
+		},
+		{
+			// Return workflow: denial reasons, return labels, the receiving
+			// location, step timestamps and per-line receipt, condition and
+			// restocking fee
+			Version: 7,
+			Name:    "return_workflow",
+			Up: database.ExecSQL(
+				`ALTER TABLE returns
+					ADD COLUMN IF NOT EXISTS denial_reason TEXT,
+					ADD COLUMN IF NOT EXISTS label_carrier TEXT,
+					ADD COLUMN IF NOT EXISTS label_tracking_number TEXT,
+					ADD COLUMN IF NOT EXISTS label_url TEXT,
+					ADD COLUMN IF NOT EXISTS location_id UUID,
+					ADD COLUMN IF NOT EXISTS authorized_at TIMESTAMPTZ,
+					ADD COLUMN IF NOT EXISTS label_issued_at TIMESTAMPTZ,
+					ADD COLUMN IF NOT EXISTS received_at TIMESTAMPTZ,
+					ADD COLUMN IF NOT EXISTS inspected_at TIMESTAMPTZ`,
+				`ALTER TABLE return_line_items
+					ADD COLUMN IF NOT EXISTS received_quantity BIGINT DEFAULT 0,
+					ADD COLUMN IF NOT EXISTS condition TEXT,
+					ADD COLUMN IF NOT EXISTS restocking_fee DECIMAL(10,2) DEFAULT 0`,
+			),
+			Down: database.ExecSQL(
+				`ALTER TABLE return_line_items
+					DROP COLUMN IF EXISTS received_quantity,
+					DROP COLUMN IF EXISTS condition,
+					DROP COLUMN IF EXISTS restocking_fee`,
+				`ALTER TABLE returns
+					DROP COLUMN IF EXISTS denial_reason,
+					DROP COLUMN IF EXISTS label_carrier,
+					DROP COLUMN IF EXISTS label_tracking_number,
+					DROP COLUMN IF EXISTS label_url,
+					DROP COLUMN IF EXISTS location_id,
+					DROP COLUMN IF EXISTS authorized_at,
+					DROP COLUMN IF EXISTS label_issued_at,
+					DROP COLUMN IF EXISTS received_at,
+					DROP COLUMN IF EXISTS inspected_at`,
+			),
+		},
+		{
+			// Order search: keyset pagination walks a merchant's orders
+			// newest first, and tag and SKU filters need their own indexes
+			Version: 8,
+			Name:    "order_search",
+			Up: database.ExecSQL(
+				`CREATE INDEX IF NOT EXISTS idx_orders_merchant_created ON orders (merchant_id, created_at DESC, id DESC)`,
+				`CREATE INDEX IF NOT EXISTS idx_orders_tags ON orders USING GIN (tags)`,
+				`CREATE INDEX IF NOT EXISTS idx_order_line_items_sku ON order_line_items (sku, order_id)`,
+			),
+			Down: database.ExecSQL(
+				`DROP INDEX IF EXISTS idx_order_line_items_sku`,
+				`DROP INDEX IF EXISTS idx_orders_tags`,
+				`DROP INDEX IF EXISTS idx_orders_merchant_created`,
+			),
+		},
+		{
+			// Draft orders composed by staff and paid through an invoice
+			Version: 9,
+			Name:    "draft_orders",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS draft_orders (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					draft_number TEXT NOT NULL CONSTRAINT uni_draft_orders_draft_number UNIQUE,
+					merchant_id UUID NOT NULL,
+					customer_id UUID,
+					location_id UUID,
+					order_id UUID NOT NULL,
+					status TEXT NOT NULL DEFAULT 'open',
+					user_id UUID,
+					customer_email TEXT,
+					customer_phone TEXT,
+					customer_first_name TEXT,
+					customer_last_name TEXT,
+					billing_first_name TEXT,
+					billing_last_name TEXT,
+					billing_company TEXT,
+					billing_street1 TEXT,
+					billing_street2 TEXT,
+					billing_city TEXT,
+					billing_state TEXT,
+					billing_country TEXT,
+					billing_postal_code TEXT,
+					billing_phone TEXT,
+					billing_latitude DECIMAL,
+					billing_longitude DECIMAL,
+					shipping_first_name TEXT,
+					shipping_last_name TEXT,
+					shipping_company TEXT,
+					shipping_street1 TEXT,
+					shipping_street2 TEXT,
+					shipping_city TEXT,
+					shipping_state TEXT,
+					shipping_country TEXT,
+					shipping_postal_code TEXT,
+					shipping_phone TEXT,
+					shipping_latitude DECIMAL,
+					shipping_longitude DECIMAL,
+					applied_discount JSONB,
+					shipping_method TEXT,
+					shipping_rate DECIMAL(10,2) DEFAULT 0,
+					taxes_included BOOLEAN DEFAULT false,
+					currency TEXT DEFAULT 'USD',
+					subtotal_price DECIMAL(12,2) DEFAULT 0,
+					total_discount DECIMAL(12,2) DEFAULT 0,
+					total_shipping DECIMAL(12,2) DEFAULT 0,
+					total_tax DECIMAL(12,2) DEFAULT 0,
+					total_price DECIMAL(12,2) DEFAULT 0,
+					source TEXT DEFAULT 'phone',
+					tags TEXT[],
+					notes TEXT,
+					internal_notes TEXT,
+					invoice_token_hash TEXT,
+					invoice_sent_to TEXT,
+					invoice_sent_at TIMESTAMPTZ,
+					payment_error TEXT,
+					expires_at TIMESTAMPTZ,
+					completed_at TIMESTAMPTZ,
+					cancelled_at TIMESTAMPTZ,
+					created_at TIMESTAMPTZ,
+					updated_at TIMESTAMPTZ
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_draft_orders_merchant_id ON draft_orders (merchant_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_draft_orders_customer_id ON draft_orders (customer_id)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_draft_orders_order_id ON draft_orders (order_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_draft_orders_invoice_token_hash ON draft_orders (invoice_token_hash)`,
+				`CREATE TABLE IF NOT EXISTS draft_order_line_items (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					draft_order_id UUID NOT NULL REFERENCES draft_orders (id),
+					product_id UUID NOT NULL,
+					product_variant_id UUID,
+					name TEXT NOT NULL,
+					sku TEXT NOT NULL,
+					product_title TEXT,
+					variant_title TEXT,
+					vendor TEXT,
+					quantity BIGINT NOT NULL,
+					price DECIMAL(10,2) NOT NULL,
+					original_price DECIMAL(10,2) DEFAULT 0,
+					applied_discount JSONB,
+					total_discount DECIMAL(10,2) DEFAULT 0,
+					taxable BOOLEAN DEFAULT true,
+					tax_class TEXT,
+					requires_shipping BOOLEAN DEFAULT true,
+					properties JSONB,
+					created_at TIMESTAMPTZ,
+					updated_at TIMESTAMPTZ
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_draft_order_line_items_draft_order_id ON draft_order_line_items (draft_order_id)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS draft_order_line_items`,
+				`DROP TABLE IF EXISTS draft_orders`,
+			),
+		},
+		{
+			// Per-merchant counters and formats for order, draft order and
+			// return numbers
+			Version: 10,
+			Name:    "number_sequences",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS number_sequences (
+					merchant_id UUID NOT NULL,
+					name VARCHAR(50) NOT NULL,
+					period VARCHAR(20) NOT NULL,
+					value BIGINT NOT NULL,
+					updated_at TIMESTAMPTZ,
+					PRIMARY KEY (merchant_id, name, period)
+				)`,
+				`CREATE TABLE IF NOT EXISTS number_formats (
+					merchant_id UUID NOT NULL,
+					name VARCHAR(50) NOT NULL,
+					template TEXT NOT NULL,
+					prefix VARCHAR(20),
+					padding BIGINT NOT NULL DEFAULT 0,
+					reset_yearly BOOLEAN NOT NULL DEFAULT false,
+					updated_at TIMESTAMPTZ,
+					PRIMARY KEY (merchant_id, name)
+				)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS number_formats`,
+				`DROP TABLE IF EXISTS number_sequences`,
+			),
+		},
+		{
+			// Numbers come from per-merchant sequences, so they are unique
+			// within a merchant rather than across the platform. Returns
+			// carry no merchant and rely on their order's.
+			Version: 11,
+			Name:    "merchant_scoped_numbers",
+			Up: database.ExecSQL(
+				`ALTER TABLE orders DROP CONSTRAINT IF EXISTS uni_orders_order_number`,
+				`ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_order_number_key`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_merchant_number ON orders (merchant_id, order_number)`,
+				`ALTER TABLE draft_orders DROP CONSTRAINT IF EXISTS uni_draft_orders_draft_number`,
+				`ALTER TABLE draft_orders DROP CONSTRAINT IF EXISTS draft_orders_draft_number_key`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_draft_orders_merchant_number ON draft_orders (merchant_id, draft_number)`,
+				`ALTER TABLE returns DROP CONSTRAINT IF EXISTS uni_returns_return_number`,
+				`ALTER TABLE returns DROP CONSTRAINT IF EXISTS returns_return_number_key`,
+				`CREATE INDEX IF NOT EXISTS idx_returns_return_number ON returns (return_number)`,
+			),
+			// Reverting keeps the merchant-scoped indexes, since numbers
+			// allocated since may already repeat across merchants
+			Down: database.ExecSQL(),
+		},
+		{
+			// Carrier tracking events of fulfillments, deduplicated per scan,
+			// and the tracking number lookup of carrier webhooks
+			Version: 12,
+			Name:    "tracking_events",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS tracking_events (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					fulfillment_id UUID NOT NULL REFERENCES fulfillments (id),
+					carrier TEXT NOT NULL,
+					tracking_number TEXT NOT NULL,
+					status TEXT,
+					carrier_status TEXT,
+					description TEXT,
+					location TEXT,
+					occurred_at TIMESTAMPTZ NOT NULL,
+					created_at TIMESTAMPTZ
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_tracking_events_scan ON tracking_events (fulfillment_id, occurred_at, carrier_status)`,
+				`CREATE INDEX IF NOT EXISTS idx_fulfillments_tracking_number ON fulfillments (tracking_number)`,
+			),
+			Down: database.ExecSQL(
+				`DROP INDEX IF EXISTS idx_fulfillments_tracking_number`,
+				`DROP TABLE IF EXISTS tracking_events`,
+			),
+		},
+		{
+			// Risk assessments of orders, the hold of orders waiting for
+			// review and the lookups of the velocity rules
+			Version: 13,
+			Name:    "order_risk",
+			Up: database.ExecSQL(
+				`ALTER TABLE orders ADD COLUMN IF NOT EXISTS browser_ip TEXT`,
+				`ALTER TABLE orders ADD COLUMN IF NOT EXISTS risk_level TEXT`,
+				`ALTER TABLE orders ADD COLUMN IF NOT EXISTS held_for_review BOOLEAN NOT NULL DEFAULT false`,
+				`CREATE TABLE IF NOT EXISTS risk_assessments (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					order_id UUID NOT NULL REFERENCES orders (id),
+					merchant_id UUID NOT NULL,
+					score BIGINT,
+					level TEXT NOT NULL,
+					signals JSONB,
+					review_status TEXT NOT NULL,
+					reviewed_by UUID,
+					reviewed_at TIMESTAMPTZ,
+					review_note TEXT,
+					created_at TIMESTAMPTZ,
+					updated_at TIMESTAMPTZ
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_risk_assessments_order_id ON risk_assessments (order_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_risk_assessments_review_queue ON risk_assessments (merchant_id, review_status, created_at)`,
+				`CREATE INDEX IF NOT EXISTS idx_orders_merchant_email_created ON orders (merchant_id, LOWER(customer_email), created_at)`,
+				`CREATE INDEX IF NOT EXISTS idx_orders_merchant_ip_created ON orders (merchant_id, browser_ip, created_at) WHERE browser_ip IS NOT NULL AND browser_ip <> ''`,
+			),
+			Down: database.ExecSQL(
+				`DROP INDEX IF EXISTS idx_orders_merchant_ip_created`,
+				`DROP INDEX IF EXISTS idx_orders_merchant_email_created`,
+				`DROP TABLE IF EXISTS risk_assessments`,
+				`ALTER TABLE orders DROP COLUMN IF EXISTS held_for_review`,
+				`ALTER TABLE orders DROP COLUMN IF EXISTS risk_level`,
+				`ALTER TABLE orders DROP COLUMN IF EXISTS browser_ip`,
+			),
+		},
+		{
+			// Idempotency keys of retried payment mutations and their
+			// stored responses
+			Version: 14,
+			Name:    "idempotency_keys",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS idempotency_keys (
+					scope VARCHAR(100) NOT NULL,
+					key VARCHAR(255) NOT NULL,
+					fingerprint VARCHAR(64) NOT NULL,
+					status BIGINT NOT NULL DEFAULT 0,
+					content_type VARCHAR(100),
+					body BYTEA,
+					created_at TIMESTAMPTZ NOT NULL,
+					expires_at TIMESTAMPTZ NOT NULL,
+					PRIMARY KEY (scope, key)
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS idempotency_keys`,
+			),
+		},
+	}
+}
@@ -0,0 +1,325 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/shared/tax"
+)
+
+// Draft order errors
+var (
+	ErrDraftOrderNotEditable = errors.New("draft order can no longer be changed")
+	ErrDraftOrderNotPayable  = errors.New("draft order is not awaiting payment")
+	ErrDraftOrderExpired     = errors.New("draft order has expired")
+	ErrInvalidDiscount       = errors.New("invalid discount")
+)
+
+// DraftOrder is an order composed by staff, typically for a phone or email
+// sale, before the customer has paid. The customer pays through a tokenized
+// invoice link, after which the draft becomes an order with OrderID as its
+// ID. Stock for the draft's items is reserved under OrderID until ExpiresAt.
+type DraftOrder struct {
+	ID          uuid.UUID        `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	DraftNumber string           `json:"draft_number" gorm:"not null;uniqueIndex:idx_draft_orders_merchant_number,priority:2"`
+	MerchantID  uuid.UUID        `json:"merchant_id" gorm:"type:uuid;not null;index;uniqueIndex:idx_draft_orders_merchant_number,priority:1"`
+	CustomerID  *uuid.UUID       `json:"customer_id" gorm:"type:uuid;index"`
+	LocationID  *uuid.UUID       `json:"location_id" gorm:"type:uuid"`
+	OrderID     uuid.UUID        `json:"order_id" gorm:"type:uuid;not null;uniqueIndex"`
+	Status      DraftOrderStatus `json:"status" gorm:"not null;default:'open'"`
+	UserID      *uuid.UUID       `json:"user_id" gorm:"type:uuid"` // staff member who created the draft
+
+	// Customer Information
+	Customer        CustomerInfo `json:"customer" gorm:"embedded;embeddedPrefix:customer_"`
+	BillingAddress  Address      `json:"billing_address" gorm:"embedded;embeddedPrefix:billing_"`
+	ShippingAddress Address      `json:"shipping_address" gorm:"embedded;embeddedPrefix:shipping_"`
+
+	// Pricing
+	AppliedDiscount *DraftDiscount `json:"applied_discount,omitempty" gorm:"type:jsonb"`
+	ShippingMethod  string         `json:"shipping_method"`
+	ShippingRate    float64        `json:"shipping_rate" gorm:"type:decimal(10,2);default:0"`
+	TaxesIncluded   bool           `json:"taxes_included" gorm:"default:false"`
+	Currency        string         `json:"currency" gorm:"default:'USD'"`
+
+	// Totals as the order would have them, updated with every change
+	SubtotalPrice float64 `json:"subtotal_price" gorm:"type:decimal(12,2);default:0"`
+	TotalDiscount float64 `json:"total_discount" gorm:"type:decimal(12,2);default:0"`
+	TotalShipping float64 `json:"total_shipping" gorm:"type:decimal(12,2);default:0"`
+	TotalTax      float64 `json:"total_tax" gorm:"type:decimal(12,2);default:0"`
+	TotalPrice    float64 `json:"total_price" gorm:"type:decimal(12,2);default:0"`
+
+	// Order Metadata
+	Source        OrderSource `json:"source" gorm:"default:'phone'"`
+	Tags          []string    `json:"tags" gorm:"type:text[]"`
+	Notes         string      `json:"notes"`
+	InternalNotes string      `json:"internal_notes"`
+
+	// Invoice. Only a hash of the payment link's token is kept.
+	InvoiceTokenHash string     `json:"-" gorm:"index"`
+	InvoiceSentTo    string     `json:"invoice_sent_to"`
+	InvoiceSentAt    *time.Time `json:"invoice_sent_at"`
+	PaymentError     string     `json:"payment_error,omitempty"`
+
+	// Timestamps
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CancelledAt *time.Time `json:"cancelled_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Relationships
+	LineItems []DraftOrderLineItem `json:"line_items,omitempty" gorm:"foreignKey:DraftOrderID"`
+}
+
+// DraftOrderStatus represents the status of a draft order
+type DraftOrderStatus string
+
+const (
+	DraftOrderStatusOpen           DraftOrderStatus = "open"
+	DraftOrderStatusInvoiceSent    DraftOrderStatus = "invoice_sent"
+	DraftOrderStatusPaymentPending DraftOrderStatus = "payment_pending"
+	DraftOrderStatusCompleted      DraftOrderStatus = "completed"
+	DraftOrderStatusCancelled      DraftOrderStatus = "cancelled"
+	DraftOrderStatusExpired        DraftOrderStatus = "expired"
+)
+
+// DraftOrderLineItem is a line of a draft order. Staff may sell at a custom
+// price and discount the line.
+type DraftOrderLineItem struct {
+	ID               uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	DraftOrderID     uuid.UUID  `json:"draft_order_id" gorm:"type:uuid;not null;index"`
+	ProductID        uuid.UUID  `json:"product_id" gorm:"type:uuid;not null"`
+	ProductVariantID *uuid.UUID `json:"product_variant_id" gorm:"type:uuid"`
+
+	// Product Information
+	Name         string `json:"name" gorm:"not null"`
+	SKU          string `json:"sku" gorm:"not null"`
+	ProductTitle string `json:"product_title"`
+	VariantTitle string `json:"variant_title"`
+	Vendor       string `json:"vendor"`
+
+	// Quantity and Pricing
+	Quantity        int            `json:"quantity" gorm:"not null"`
+	Price           float64        `json:"price" gorm:"type:decimal(10,2);not null"`
+	OriginalPrice   float64        `json:"original_price" gorm:"type:decimal(10,2);default:0"` // catalogue price when a custom price is charged
+	AppliedDiscount *DraftDiscount `json:"applied_discount,omitempty" gorm:"type:jsonb"`
+	TotalDiscount   float64        `json:"total_discount" gorm:"type:decimal(10,2);default:0"` // line discount and share of the draft's discount
+
+	// Tax and Shipping
+	Taxable          bool              `json:"taxable" gorm:"default:true"`
+	TaxClass         string            `json:"tax_class"`
+	RequiresShipping bool              `json:"requires_shipping" gorm:"default:true"`
+	Properties       map[string]string `json:"properties,omitempty" gorm:"type:jsonb"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// DraftDiscount is a discount applied by staff to a draft order or one of
+// its lines
+type DraftDiscount struct {
+	Type  DiscountType `json:"type"`
+	Value float64      `json:"value"`
+	Title string       `json:"title,omitempty"`
+}
+
+// DiscountType represents how a discount's value is applied
+type DiscountType string
+
+const (
+	DiscountTypeFixedAmount DiscountType = "fixed_amount"
+	DiscountTypePercentage  DiscountType = "percentage"
+)
+
+// Validate rejects discounts of unknown type, negative discounts and
+// percentages over 100
+func (d *DraftDiscount) Validate() error {
+	switch {
+	case d.Value < 0:
+		return ErrInvalidDiscount
+	case d.Type == DiscountTypePercentage && d.Value > 100:
+		return ErrInvalidDiscount
+	case d.Type != DiscountTypePercentage && d.Type != DiscountTypeFixedAmount:
+		return ErrInvalidDiscount
+	}
+	return nil
+}
+
+// Amount returns the discount taken off an amount, which is never more than
+// the amount itself
+func (d *DraftDiscount) Amount(amount float64) float64 {
+	if d == nil || amount <= 0 {
+		return 0
+	}
+	discount := d.Value
+	if d.Type == DiscountTypePercentage {
+		discount = amount * d.Value / 100
+	}
+	return tax.Round(min(discount, amount))
+}
+
+// draftTransitions lists the statuses a draft order may move to from each
+// status. Changing a draft after its invoice was sent reopens it, and a
+// failed payment returns it to awaiting payment.
+var draftTransitions = map[DraftOrderStatus][]DraftOrderStatus{
+	DraftOrderStatusOpen:           {DraftOrderStatusInvoiceSent, DraftOrderStatusCancelled, DraftOrderStatusExpired},
+	DraftOrderStatusInvoiceSent:    {DraftOrderStatusOpen, DraftOrderStatusInvoiceSent, DraftOrderStatusPaymentPending, DraftOrderStatusCancelled, DraftOrderStatusExpired},
+	DraftOrderStatusPaymentPending: {DraftOrderStatusInvoiceSent, DraftOrderStatusCompleted},
+}
+
+// TransitionTo moves the draft to the status and stamps the time it got
+// there. Undeclared changes are rejected with a TransitionError.
+func (d *DraftOrder) TransitionTo(status DraftOrderStatus, now time.Time) error {
+	allowed := false
+	for _, to := range draftTransitions[d.Status] {
+		if to == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return &TransitionError{Machine: "draft_order", From: string(d.Status), To: string(status)}
+	}
+
+	d.Status = status
+	switch status {
+	case DraftOrderStatusInvoiceSent:
+		d.InvoiceSentAt = &now
+	case DraftOrderStatusCompleted:
+		d.CompletedAt = &now
+	case DraftOrderStatusCancelled:
+		d.CancelledAt = &now
+	}
+	return nil
+}
+
+// IsEditable reports whether the draft's contents can still be changed
+func (d *DraftOrder) IsEditable() bool {
+	return d.Status == DraftOrderStatusOpen || d.Status == DraftOrderStatusInvoiceSent
+}
+
+// HoldsStock reports whether stock should stay reserved for the draft
+func (d *DraftOrder) HoldsStock() bool {
+	switch d.Status {
+	case DraftOrderStatusOpen, DraftOrderStatusInvoiceSent, DraftOrderStatusPaymentPending:
+		return true
+	default:
+		return false
+	}
+}
+
+// ApplyDiscounts sets each line's total discount from its own discount and
+// a share of the draft's discount. The draft's discount applies to the lines
+// after their own discounts and is shared in proportion to what remains of
+// each; rounding is settled on the last line so the shares add up.
+func (d *DraftOrder) ApplyDiscounts() {
+	var remaining float64
+	for i := range d.LineItems {
+		lineItem := &d.LineItems[i]
+		linePrice := float64(lineItem.Quantity) * lineItem.Price
+		lineItem.TotalDiscount = lineItem.AppliedDiscount.Amount(linePrice)
+		remaining += linePrice - lineItem.TotalDiscount
+	}
+
+	discount := d.AppliedDiscount.Amount(remaining)
+	if discount == 0 {
+		return
+	}
+	var shared float64
+	last := -1
+	for i := range d.LineItems {
+		lineItem := &d.LineItems[i]
+		net := float64(lineItem.Quantity)*lineItem.Price - lineItem.TotalDiscount
+		if net <= 0 {
+			continue
+		}
+		share := tax.Round(discount * net / remaining)
+		lineItem.TotalDiscount = tax.Round(lineItem.TotalDiscount + share)
+		shared += share
+		last = i
+	}
+	if last >= 0 {
+		d.LineItems[last].TotalDiscount = tax.Round(d.LineItems[last].TotalDiscount + discount - shared)
+	}
+}
+
+// BuildOrder returns the order the draft becomes, with the draft's OrderID
+// and discounted lines. Taxes are left to be calculated; the draft itself is
+// not modified.
+func (d *DraftOrder) BuildOrder(orderNumber string) *Order {
+	order := &Order{
+		ID:                d.OrderID,
+		OrderNumber:       orderNumber,
+		MerchantID:        d.MerchantID,
+		CustomerID:        d.CustomerID,
+		LocationID:        d.LocationID,
+		Status:            OrderStatusPending,
+		FulfillmentStatus: FulfillmentStatusUnfulfilled,
+		PaymentStatus:     PaymentStatusPending,
+		Customer:          d.Customer,
+		BillingAddress:    d.BillingAddress,
+		ShippingAddress:   d.ShippingAddress,
+		ShippingMethod:    d.ShippingMethod,
+		ShippingRate:      d.ShippingRate,
+		TotalShipping:     d.ShippingRate,
+		TaxesIncluded:     d.TaxesIncluded,
+		Source:            d.Source,
+		Currency:          d.Currency,
+		Tags:              d.Tags,
+		Notes:             d.Notes,
+		InternalNotes:     d.InternalNotes,
+	}
+
+	for _, item := range d.LineItems {
+		order.LineItems = append(order.LineItems, OrderLineItem{
+			ID:                uuid.New(),
+			OrderID:           order.ID,
+			ProductID:         item.ProductID,
+			ProductVariantID:  item.ProductVariantID,
+			Name:              item.Name,
+			SKU:               item.SKU,
+			ProductTitle:      item.ProductTitle,
+			VariantTitle:      item.VariantTitle,
+			Vendor:            item.Vendor,
+			Quantity:          item.Quantity,
+			Price:             item.Price,
+			CompareAtPrice:    item.OriginalPrice,
+			LinePrice:         float64(item.Quantity) * item.Price,
+			TotalDiscount:     item.TotalDiscount,
+			Taxable:           item.Taxable,
+			TaxClass:          item.TaxClass,
+			RequiresShipping:  item.RequiresShipping,
+			Properties:        item.Properties,
+			FulfillmentStatus: FulfillmentStatusUnfulfilled,
+		})
+		order.TotalDiscount += item.TotalDiscount
+	}
+	order.TotalDiscount = tax.Round(order.TotalDiscount)
+	return order
+}
+
+// SetTotals copies the totals of the order the draft becomes
+func (d *DraftOrder) SetTotals(order *Order) {
+	d.SubtotalPrice = order.SubtotalPrice
+	d.TotalDiscount = order.TotalDiscount
+	d.TotalShipping = order.TotalShipping
+	d.TotalTax = order.TotalTax
+	d.TotalPrice = order.TotalPrice
+}
+
+func (d *DraftOrder) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+func (dli *DraftOrderLineItem) BeforeCreate(tx *gorm.DB) error {
+	if dli.ID == uuid.Nil {
+		dli.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/models"
+)
+
+func TestDraftOrder_ApplyDiscounts(t *testing.T) {
+	draft := &models.DraftOrder{
+		OrderID:         uuid.New(),
+		AppliedDiscount: &models.DraftDiscount{Type: models.DiscountTypeFixedAmount, Value: 10},
+		ShippingRate:    5,
+		LineItems: []models.DraftOrderLineItem{
+			{Quantity: 2, Price: 30, AppliedDiscount: &models.DraftDiscount{Type: models.DiscountTypePercentage, Value: 50}},
+			{Quantity: 1, Price: 10},
+			{Quantity: 1, Price: 20},
+		},
+	}
+
+	// The line discount leaves 30 + 10 + 20 = 60, which shares the draft's 10
+	draft.ApplyDiscounts()
+	want := []float64{30 + 5, 1.67, 3.33}
+	for i, lineItem := range draft.LineItems {
+		if lineItem.TotalDiscount != want[i] {
+			t.Errorf("line %d: expected discount %.2f, got %.2f", i, want[i], lineItem.TotalDiscount)
+		}
+	}
+
+	order := draft.BuildOrder("1001")
+	if order.ID != draft.OrderID || order.TotalDiscount != 40 || order.TotalShipping != 5 {
+		t.Errorf("expected the order to carry the draft's ID, discounts and shipping, got %+v", order)
+	}
+	if len(order.LineItems) != 3 || order.LineItems[0].LinePrice != 60 {
+		t.Errorf("expected the draft's lines at their custom prices, got %+v", order.LineItems)
+	}
+}
+
+func TestDraftDiscount_Validate(t *testing.T) {
+	for _, discount := range []models.DraftDiscount{
+		{Type: models.DiscountTypePercentage, Value: 120},
+		{Type: models.DiscountTypeFixedAmount, Value: -1},
+		{Type: "bogus", Value: 5},
+	} {
+		if err := discount.Validate(); !errors.Is(err, models.ErrInvalidDiscount) {
+			t.Errorf("expected %+v to be rejected, got %v", discount, err)
+		}
+	}
+
+	fixed := &models.DraftDiscount{Type: models.DiscountTypeFixedAmount, Value: 50}
+	if amount := fixed.Amount(20); amount != 20 {
+		t.Errorf("expected a discount never to exceed the amount, got %.2f", amount)
+	}
+}
+
+func TestDraftOrder_Workflow(t *testing.T) {
+	now := time.Now()
+	draft := &models.DraftOrder{Status: models.DraftOrderStatusOpen}
+
+	if err := draft.TransitionTo(models.DraftOrderStatusPaymentPending, now); !errors.Is(err, models.ErrInvalidTransition) {
+		t.Fatalf("expected an open draft not to be payable, got %v", err)
+	}
+
+	for _, status := range []models.DraftOrderStatus{
+		models.DraftOrderStatusInvoiceSent,
+		models.DraftOrderStatusPaymentPending,
+		models.DraftOrderStatusInvoiceSent, // the payment failed
+		models.DraftOrderStatusPaymentPending,
+		models.DraftOrderStatusCompleted,
+	} {
+		if err := draft.TransitionTo(status, now); err != nil {
+			t.Fatalf("unexpected error moving to %s: %v", status, err)
+		}
+	}
+	if draft.InvoiceSentAt == nil || draft.CompletedAt == nil || draft.HoldsStock() || draft.IsEditable() {
+		t.Errorf("expected a completed draft to be timestamped and closed, got %+v", draft)
+	}
+
+	if err := draft.TransitionTo(models.DraftOrderStatusCancelled, now); !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("expected a completed draft not to be cancellable, got %v", err)
+	}
+}
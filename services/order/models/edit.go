@@ -0,0 +1,242 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Order edit errors
+var (
+	ErrOrderNotEditable       = errors.New("order can no longer be edited")
+	ErrOrderEditClosed        = errors.New("order edit is already committed or discarded")
+	ErrLineItemNotInOrder     = errors.New("line item is not part of the order")
+	ErrQuantityBelowFulfilled = errors.New("quantity cannot be reduced below the fulfilled quantity")
+	ErrInvalidOrderEdit       = errors.New("invalid order edit change")
+)
+
+// OrderEdit stages changes to an order's line items so their effect on the
+// order's totals and payment can be previewed before they are committed
+// together. The totals hold the preview as of the last staged change.
+type OrderEdit struct {
+	ID      uuid.UUID         `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	OrderID uuid.UUID         `json:"order_id" gorm:"type:uuid;not null;index"`
+	Status  OrderEditStatus   `json:"status" gorm:"not null;default:'open'"`
+	Changes []OrderEditChange `json:"changes" gorm:"type:jsonb"`
+	Notes   string            `json:"notes"`
+	UserID  *uuid.UUID        `json:"user_id" gorm:"type:uuid"`
+
+	// Preview
+	OriginalTotalPrice float64 `json:"original_total_price" gorm:"type:decimal(12,2);default:0"`
+	SubtotalPrice      float64 `json:"subtotal_price" gorm:"type:decimal(12,2);default:0"`
+	TotalTax           float64 `json:"total_tax" gorm:"type:decimal(12,2);default:0"`
+	TotalPrice         float64 `json:"total_price" gorm:"type:decimal(12,2);default:0"`
+	PaymentDelta       float64 `json:"payment_delta" gorm:"type:decimal(12,2);default:0"` // change to the total, captured or refunded once the order is paid
+
+	// Line items of the order with the changes applied, filled in by previews
+	LineItems []OrderLineItem `json:"line_items,omitempty" gorm:"-"`
+
+	// Timestamps
+	CommittedAt *time.Time `json:"committed_at"`
+	DiscardedAt *time.Time `json:"discarded_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Relationships
+	Order Order `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+}
+
+// OrderEditStatus represents the status of an order edit
+type OrderEditStatus string
+
+const (
+	OrderEditStatusOpen      OrderEditStatus = "open"
+	OrderEditStatusCommitted OrderEditStatus = "committed"
+	OrderEditStatusDiscarded OrderEditStatus = "discarded"
+)
+
+// OrderEditChange is a change staged on an order edit. Additions carry the
+// new line item, whose ID is assigned when the change is staged.
+type OrderEditChange struct {
+	Type       OrderEditChangeType `json:"type"`
+	LineItemID uuid.UUID           `json:"line_item_id"`
+	Quantity   int                 `json:"quantity,omitempty"`
+	Price      *float64            `json:"price,omitempty"`
+	LineItem   *OrderEditLineItem  `json:"line_item,omitempty"`
+}
+
+// OrderEditChangeType represents the type of an order edit change
+type OrderEditChangeType string
+
+const (
+	OrderEditChangeAddLineItem    OrderEditChangeType = "add_line_item"
+	OrderEditChangeUpdateLineItem OrderEditChangeType = "update_line_item"
+	OrderEditChangeRemoveLineItem OrderEditChangeType = "remove_line_item"
+)
+
+// OrderEditLineItem is a line item added by an order edit
+type OrderEditLineItem struct {
+	ProductID        uuid.UUID         `json:"product_id"`
+	ProductVariantID *uuid.UUID        `json:"product_variant_id"`
+	SKU              string            `json:"sku"`
+	Name             string            `json:"name"`
+	Quantity         int               `json:"quantity"`
+	Price            float64           `json:"price"`
+	Taxable          bool              `json:"taxable"`
+	TaxClass         string            `json:"tax_class"`
+	RequiresShipping bool              `json:"requires_shipping"`
+	Properties       map[string]string `json:"properties,omitempty"`
+}
+
+// OrderEditDiff records how a committed edit changed a line item
+type OrderEditDiff struct {
+	Type         OrderEditChangeType `json:"type"`
+	LineItemID   uuid.UUID           `json:"line_item_id"`
+	SKU          string              `json:"sku"`
+	QuantityFrom int                 `json:"quantity_from"`
+	QuantityTo   int                 `json:"quantity_to"`
+	PriceFrom    float64             `json:"price_from"`
+	PriceTo      float64             `json:"price_to"`
+}
+
+// IsEditable reports whether the order's line items can still be edited.
+// Shipped and closed orders cannot be edited.
+func (o *Order) IsEditable() bool {
+	switch o.Status {
+	case OrderStatusPending, OrderStatusConfirmed, OrderStatusProcessing:
+		return true
+	default:
+		return false
+	}
+}
+
+// ApplyTo returns the order's line items with the edit's changes applied in
+// order. Changed and added line items have their tax lines cleared so they
+// can be recalculated; the order itself is not modified.
+func (e *OrderEdit) ApplyTo(order *Order) ([]OrderLineItem, error) {
+	if !order.IsEditable() {
+		return nil, ErrOrderNotEditable
+	}
+
+	lineItems := make([]OrderLineItem, len(order.LineItems))
+	copy(lineItems, order.LineItems)
+
+	find := func(id uuid.UUID) int {
+		for i := range lineItems {
+			if lineItems[i].ID == id {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, change := range e.Changes {
+		switch change.Type {
+		case OrderEditChangeAddLineItem:
+			if change.LineItem == nil || change.LineItem.Quantity < 1 || change.LineItem.Price < 0 {
+				return nil, ErrInvalidOrderEdit
+			}
+			added := change.LineItem
+			lineItems = append(lineItems, OrderLineItem{
+				ID:                change.LineItemID,
+				OrderID:           order.ID,
+				ProductID:         added.ProductID,
+				ProductVariantID:  added.ProductVariantID,
+				Name:              added.Name,
+				SKU:               added.SKU,
+				Quantity:          added.Quantity,
+				Price:             added.Price,
+				LinePrice:         float64(added.Quantity) * added.Price,
+				Taxable:           added.Taxable,
+				TaxClass:          added.TaxClass,
+				RequiresShipping:  added.RequiresShipping,
+				Properties:        added.Properties,
+				FulfillmentStatus: FulfillmentStatusUnfulfilled,
+			})
+
+		case OrderEditChangeUpdateLineItem:
+			i := find(change.LineItemID)
+			if i < 0 {
+				return nil, ErrLineItemNotInOrder
+			}
+			lineItem := &lineItems[i]
+			if change.Quantity > 0 {
+				if change.Quantity < lineItem.FulfilledQuantity {
+					return nil, ErrQuantityBelowFulfilled
+				}
+				lineItem.Quantity = change.Quantity
+			}
+			if change.Price != nil {
+				if *change.Price < 0 {
+					return nil, ErrInvalidOrderEdit
+				}
+				lineItem.Price = *change.Price
+			}
+			lineItem.LinePrice = float64(lineItem.Quantity) * lineItem.Price
+			lineItem.FulfillmentStatus = LineItemFulfillmentStatus(lineItem)
+			lineItem.TaxLines = nil
+
+		case OrderEditChangeRemoveLineItem:
+			i := find(change.LineItemID)
+			if i < 0 {
+				return nil, ErrLineItemNotInOrder
+			}
+			if lineItems[i].FulfilledQuantity > 0 {
+				return nil, ErrQuantityBelowFulfilled
+			}
+			lineItems = append(lineItems[:i], lineItems[i+1:]...)
+
+		default:
+			return nil, ErrInvalidOrderEdit
+		}
+	}
+
+	return lineItems, nil
+}
+
+// DiffLineItems compares an order's line items before and after an edit
+func DiffLineItems(before, after []OrderLineItem) []OrderEditDiff {
+	var diffs []OrderEditDiff
+	remaining := make(map[uuid.UUID]OrderLineItem, len(after))
+	for _, lineItem := range after {
+		remaining[lineItem.ID] = lineItem
+	}
+
+	for _, old := range before {
+		updated, ok := remaining[old.ID]
+		delete(remaining, old.ID)
+		switch {
+		case !ok:
+			diffs = append(diffs, OrderEditDiff{
+				Type: OrderEditChangeRemoveLineItem, LineItemID: old.ID, SKU: old.SKU,
+				QuantityFrom: old.Quantity, PriceFrom: old.Price,
+			})
+		case updated.Quantity != old.Quantity || updated.Price != old.Price:
+			diffs = append(diffs, OrderEditDiff{
+				Type: OrderEditChangeUpdateLineItem, LineItemID: old.ID, SKU: old.SKU,
+				QuantityFrom: old.Quantity, QuantityTo: updated.Quantity,
+				PriceFrom: old.Price, PriceTo: updated.Price,
+			})
+		}
+	}
+
+	// Whatever is left was added, in the order it was added
+	for _, lineItem := range after {
+		if _, ok := remaining[lineItem.ID]; ok {
+			diffs = append(diffs, OrderEditDiff{
+				Type: OrderEditChangeAddLineItem, LineItemID: lineItem.ID, SKU: lineItem.SKU,
+				QuantityTo: lineItem.Quantity, PriceTo: lineItem.Price,
+			})
+		}
+	}
+	return diffs
+}
+
+func (e *OrderEdit) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
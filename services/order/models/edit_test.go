@@ -0,0 +1,111 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/models"
+)
+
+func editableOrder() *models.Order {
+	return &models.Order{
+		ID:     uuid.New(),
+		Status: models.OrderStatusConfirmed,
+		LineItems: []models.OrderLineItem{
+			{ID: uuid.New(), SKU: "SHIRT", Quantity: 2, Price: 20, LinePrice: 40, FulfilledQuantity: 2,
+				TaxLines: []models.TaxLine{{Title: "VAT", Rate: 0.2, Price: 8}}},
+			{ID: uuid.New(), SKU: "HAT", Quantity: 1, Price: 15, LinePrice: 15},
+		},
+	}
+}
+
+func TestOrderEdit_ApplyTo(t *testing.T) {
+	order := editableOrder()
+	shirt, hat := order.LineItems[0].ID, order.LineItems[1].ID
+	price := 18.0
+	added := uuid.New()
+
+	edit := &models.OrderEdit{Changes: []models.OrderEditChange{
+		{Type: models.OrderEditChangeUpdateLineItem, LineItemID: shirt, Quantity: 3, Price: &price},
+		{Type: models.OrderEditChangeRemoveLineItem, LineItemID: hat},
+		{Type: models.OrderEditChangeAddLineItem, LineItemID: added, LineItem: &models.OrderEditLineItem{SKU: "SOCKS", Quantity: 4, Price: 5}},
+	}}
+
+	lineItems, err := edit.ApplyTo(order)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lineItems) != 2 {
+		t.Fatalf("expected 2 line items, got %d", len(lineItems))
+	}
+	if got := lineItems[0]; got.LinePrice != 54 || got.TaxLines != nil || got.FulfillmentStatus != models.FulfillmentStatusPartiallyFulfilled {
+		t.Errorf("expected updated shirt line without taxes, got %+v", got)
+	}
+	if got := lineItems[1]; got.ID != added || got.LinePrice != 20 {
+		t.Errorf("expected added socks line, got %+v", got)
+	}
+	if len(order.LineItems) != 2 || order.LineItems[0].Quantity != 2 {
+		t.Errorf("expected the order to be left unchanged")
+	}
+
+	diffs := models.DiffLineItems(order.LineItems, lineItems)
+	if len(diffs) != 3 {
+		t.Fatalf("expected 3 diffs, got %+v", diffs)
+	}
+	if diffs[0].Type != models.OrderEditChangeUpdateLineItem || diffs[0].QuantityFrom != 2 || diffs[0].QuantityTo != 3 {
+		t.Errorf("expected shirt quantity 2 -> 3, got %+v", diffs[0])
+	}
+	if diffs[1].Type != models.OrderEditChangeRemoveLineItem || diffs[2].Type != models.OrderEditChangeAddLineItem {
+		t.Errorf("expected hat removal then socks addition, got %+v", diffs[1:])
+	}
+}
+
+func TestOrderEdit_ApplyToRejectsInvalidChanges(t *testing.T) {
+	order := editableOrder()
+	shirt := order.LineItems[0].ID
+
+	tests := []struct {
+		name     string
+		change   models.OrderEditChange
+		expected error
+	}{
+		{"below fulfilled quantity", models.OrderEditChange{Type: models.OrderEditChangeUpdateLineItem, LineItemID: shirt, Quantity: 1}, models.ErrQuantityBelowFulfilled},
+		{"remove fulfilled line item", models.OrderEditChange{Type: models.OrderEditChangeRemoveLineItem, LineItemID: shirt}, models.ErrQuantityBelowFulfilled},
+		{"unknown line item", models.OrderEditChange{Type: models.OrderEditChangeRemoveLineItem, LineItemID: uuid.New()}, models.ErrLineItemNotInOrder},
+		{"addition without line item", models.OrderEditChange{Type: models.OrderEditChangeAddLineItem, LineItemID: uuid.New()}, models.ErrInvalidOrderEdit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edit := &models.OrderEdit{Changes: []models.OrderEditChange{tt.change}}
+			if _, err := edit.ApplyTo(order); !errors.Is(err, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	order.Status = models.OrderStatusShipped
+	if _, err := (&models.OrderEdit{}).ApplyTo(order); !errors.Is(err, models.ErrOrderNotEditable) {
+		t.Errorf("expected shipped orders not to be editable, got %v", err)
+	}
+}
+
+func TestOrder_CalculateTotals(t *testing.T) {
+	order := editableOrder()
+	order.TotalShipping = 5
+	order.TotalDiscount = 10
+	order.ShippingTaxLines = []models.TaxLine{{Title: "VAT", Rate: 0.2, Price: 1}}
+
+	order.CalculateTotals()
+	if order.SubtotalPrice != 55 || order.TotalTax != 9 || order.TotalPrice != 59 {
+		t.Errorf("expected 55 subtotal, 9 tax and 59 total, got %v, %v and %v", order.SubtotalPrice, order.TotalTax, order.TotalPrice)
+	}
+
+	order.TaxesIncluded = true
+	order.CalculateTotals()
+	if order.TotalPrice != 50 {
+		t.Errorf("expected included taxes not to be added, got %v", order.TotalPrice)
+	}
+}
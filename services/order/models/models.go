@@ -5,6 +5,8 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+
+	"unified-commerce/services/shared/tax"
 )
 
 // User represents a user entity for federation (minimal implementation)
@@ -18,8 +20,8 @@ func (u User) IsEntity() {}
 // Order represents a customer order
 type Order struct {
 	ID                uuid.UUID         `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	OrderNumber       string            `json:"order_number" gorm:"unique;not null;index"`
-	MerchantID        uuid.UUID         `json:"merchant_id" gorm:"type:uuid;not null;index"`
+	OrderNumber       string            `json:"order_number" gorm:"not null;index;uniqueIndex:idx_orders_merchant_number,priority:2"`
+	MerchantID        uuid.UUID         `json:"merchant_id" gorm:"type:uuid;not null;index;uniqueIndex:idx_orders_merchant_number,priority:1"`
 	CustomerID        *uuid.UUID        `json:"customer_id" gorm:"type:uuid;index"`
 	LocationID        *uuid.UUID        `json:"location_id" gorm:"type:uuid;index"`
 	Status            OrderStatus       `json:"status" gorm:"default:'pending'"`
@@ -39,13 +41,15 @@ type Order struct {
 	TotalShipping float64 `json:"total_shipping" gorm:"type:decimal(12,2);default:0"`
 	TotalDiscount float64 `json:"total_discount" gorm:"type:decimal(12,2);default:0"`
 	TotalPrice    float64 `json:"total_price" gorm:"type:decimal(12,2);default:0"`
+	TaxesIncluded bool    `json:"taxes_included" gorm:"default:false"`
 
 	// Shipping Information
-	ShippingMethod string  `json:"shipping_method"`
-	ShippingRate   float64 `json:"shipping_rate" gorm:"type:decimal(10,2)"`
-	TrackingNumber string  `json:"tracking_number"`
-	TrackingURL    string  `json:"tracking_url"`
-	Carrier        string  `json:"carrier"`
+	ShippingMethod   string    `json:"shipping_method"`
+	ShippingRate     float64   `json:"shipping_rate" gorm:"type:decimal(10,2)"`
+	ShippingTaxLines []TaxLine `json:"shipping_tax_lines,omitempty" gorm:"type:jsonb"`
+	TrackingNumber   string    `json:"tracking_number"`
+	TrackingURL      string    `json:"tracking_url"`
+	Carrier          string    `json:"carrier"`
 
 	// Order Metadata
 	Source        OrderSource `json:"source" gorm:"default:'online'"`
@@ -54,6 +58,12 @@ type Order struct {
 	Tags          []string    `json:"tags" gorm:"type:text[]"`
 	Notes         string      `json:"notes"`
 	InternalNotes string      `json:"internal_notes"`
+	BrowserIP     string      `json:"browser_ip"`
+
+	// Risk, scored when the order is placed. Orders held for review cannot
+	// be confirmed.
+	RiskLevel     RiskLevel `json:"risk_level"`
+	HeldForReview bool      `json:"held_for_review" gorm:"default:false"`
 
 	// Timestamps
 	ProcessedAt *time.Time `json:"processed_at"`
@@ -74,6 +84,29 @@ type Order struct {
 // IsEntity marks Order as a federation entity
 func (o Order) IsEntity() {}
 
+// CalculateTotals sets the order's subtotal, tax and total from its line
+// items, their tax lines and the shipping tax lines
+func (o *Order) CalculateTotals() {
+	var subtotal, totalTax float64
+	for _, lineItem := range o.LineItems {
+		subtotal += lineItem.LinePrice
+		for _, taxLine := range lineItem.TaxLines {
+			totalTax += taxLine.Price
+		}
+	}
+	for _, taxLine := range o.ShippingTaxLines {
+		totalTax += taxLine.Price
+	}
+
+	o.SubtotalPrice = tax.Round(subtotal)
+	o.TotalTax = tax.Round(totalTax)
+	o.TotalPrice = o.SubtotalPrice + o.TotalShipping - o.TotalDiscount
+	if !o.TaxesIncluded {
+		o.TotalPrice += o.TotalTax
+	}
+	o.TotalPrice = tax.Round(o.TotalPrice)
+}
+
 // OrderStatus represents the overall status of an order
 type OrderStatus string
 
@@ -176,6 +209,7 @@ type OrderLineItem struct {
 
 	// Tax Information
 	Taxable  bool      `json:"taxable" gorm:"default:true"`
+	TaxClass string    `json:"tax_class"`
 	TaxLines []TaxLine `json:"tax_lines,omitempty" gorm:"type:jsonb"`
 
 	// Fulfillment
@@ -223,8 +257,9 @@ type Fulfillment struct {
 	DeliveredAt *time.Time `json:"delivered_at"`
 
 	// Relationships
-	Order     Order                 `json:"order,omitempty" gorm:"foreignKey:OrderID"`
-	LineItems []FulfillmentLineItem `json:"line_items,omitempty" gorm:"foreignKey:FulfillmentID"`
+	Order          Order                 `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+	LineItems      []FulfillmentLineItem `json:"line_items,omitempty" gorm:"foreignKey:FulfillmentID"`
+	TrackingEvents []TrackingEvent       `json:"tracking_events,omitempty" gorm:"foreignKey:FulfillmentID"`
 }
 
 // ShipmentStatus represents the status of a shipment
@@ -260,8 +295,13 @@ type Transaction struct {
 	ID      uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
 	OrderID uuid.UUID `json:"order_id" gorm:"type:uuid;not null;index"`
 
-	// We're not storing the full transaction details here since they're managed by the payment service
-	// This is just a reference to the transaction in the payment service
+	// We're not storing the full transaction details here since they're managed by the payment service.
+	// Only what's needed to derive the order's payment status is kept alongside the reference.
+	PaymentID *uuid.UUID        `json:"payment_id" gorm:"type:uuid;index"`
+	Kind      TransactionKind   `json:"kind" gorm:"not null;default:'sale'"`
+	Status    TransactionStatus `json:"status" gorm:"not null;default:'success'"`
+	Amount    float64           `json:"amount" gorm:"type:decimal(12,2);default:0"`
+	Currency  string            `json:"currency" gorm:"default:'USD'"`
 
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
@@ -273,17 +313,46 @@ type Transaction struct {
 // IsEntity marks Transaction as a federation entity
 func (t Transaction) IsEntity() {}
 
-// Return represents a product return
+// TransactionKind represents what a transaction did with the payment
+type TransactionKind string
+
+const (
+	TransactionKindAuthorization TransactionKind = "authorization"
+	TransactionKindCapture       TransactionKind = "capture"
+	TransactionKindSale          TransactionKind = "sale"
+	TransactionKindVoid          TransactionKind = "void"
+	TransactionKindRefund        TransactionKind = "refund"
+)
+
+// TransactionStatus represents the outcome of a transaction
+type TransactionStatus string
+
+const (
+	TransactionStatusPending TransactionStatus = "pending"
+	TransactionStatusSuccess TransactionStatus = "success"
+	TransactionStatusFailure TransactionStatus = "failure"
+)
+
+// Return represents a product return, moving through a return merchandise
+// authorization (RMA) workflow: requested (pending), authorized or denied,
+// label issued, received, inspected and refunded
 type Return struct {
 	ID           uuid.UUID    `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
 	OrderID      uuid.UUID    `json:"order_id" gorm:"type:uuid;not null;index"`
-	ReturnNumber string       `json:"return_number" gorm:"unique;not null"`
+	ReturnNumber string       `json:"return_number" gorm:"not null;index"`
 	Status       ReturnStatus `json:"status" gorm:"default:'pending'"`
 
 	// Return Information
 	Reason        ReturnReason `json:"reason" gorm:"not null"`
 	CustomerNotes string       `json:"customer_notes"`
 	InternalNotes string       `json:"internal_notes"`
+	DenialReason  string       `json:"denial_reason"`
+
+	// Return Shipping
+	LabelCarrier        string     `json:"label_carrier"`
+	LabelTrackingNumber string     `json:"label_tracking_number"`
+	LabelURL            string     `json:"label_url"`
+	LocationID          *uuid.UUID `json:"location_id" gorm:"type:uuid"` // where the items were received
 
 	// Financial Information
 	RefundAmount  float64 `json:"refund_amount" gorm:"type:decimal(12,2)"`
@@ -295,11 +364,15 @@ type Return struct {
 	RefundShipping bool `json:"refund_shipping" gorm:"default:false"`
 
 	// Timestamps
-	RequestedAt time.Time  `json:"requested_at" gorm:"autoCreateTime"`
-	ProcessedAt *time.Time `json:"processed_at"`
-	CompletedAt *time.Time `json:"completed_at"`
-	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+	RequestedAt   time.Time  `json:"requested_at" gorm:"autoCreateTime"`
+	AuthorizedAt  *time.Time `json:"authorized_at"`
+	LabelIssuedAt *time.Time `json:"label_issued_at"`
+	ReceivedAt    *time.Time `json:"received_at"`
+	InspectedAt   *time.Time `json:"inspected_at"`
+	ProcessedAt   *time.Time `json:"processed_at"`
+	CompletedAt   *time.Time `json:"completed_at"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
 
 	// Relationships
 	Order     Order            `json:"order,omitempty" gorm:"foreignKey:OrderID"`
@@ -310,13 +383,16 @@ type Return struct {
 type ReturnStatus string
 
 const (
-	ReturnStatusPending    ReturnStatus = "pending"
-	ReturnStatusAuthorized ReturnStatus = "authorized"
-	ReturnStatusReceived   ReturnStatus = "received"
-	ReturnStatusProcessed  ReturnStatus = "processed"
-	ReturnStatusCompleted  ReturnStatus = "completed"
-	ReturnStatusDenied     ReturnStatus = "denied"
-	ReturnStatusCancelled  ReturnStatus = "cancelled"
+	ReturnStatusPending     ReturnStatus = "pending"
+	ReturnStatusAuthorized  ReturnStatus = "authorized"
+	ReturnStatusLabelIssued ReturnStatus = "label_issued"
+	ReturnStatusReceived    ReturnStatus = "received"
+	ReturnStatusInspected   ReturnStatus = "inspected"
+	ReturnStatusRefunded    ReturnStatus = "refunded"
+	ReturnStatusProcessed   ReturnStatus = "processed"
+	ReturnStatusCompleted   ReturnStatus = "completed"
+	ReturnStatusDenied      ReturnStatus = "denied"
+	ReturnStatusCancelled   ReturnStatus = "cancelled"
 )
 
 // ReturnReason represents the reason for a return
@@ -335,22 +411,36 @@ const (
 
 // ReturnLineItem represents items in a return
 type ReturnLineItem struct {
-	ID              uuid.UUID    `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	ReturnID        uuid.UUID    `json:"return_id" gorm:"type:uuid;not null;index"`
-	LineItemID      uuid.UUID    `json:"line_item_id" gorm:"type:uuid;not null;index"`
-	Quantity        int          `json:"quantity" gorm:"not null"`
-	Reason          ReturnReason `json:"reason"`
-	Notes           string       `json:"notes"`
-	RefundAmount    float64      `json:"refund_amount" gorm:"type:decimal(10,2)"`
-	RestockQuantity int          `json:"restock_quantity" gorm:"default:0"`
-	CreatedAt       time.Time    `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt       time.Time    `json:"updated_at" gorm:"autoUpdateTime"`
+	ID               uuid.UUID       `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ReturnID         uuid.UUID       `json:"return_id" gorm:"type:uuid;not null;index"`
+	LineItemID       uuid.UUID       `json:"line_item_id" gorm:"type:uuid;not null;index"`
+	Quantity         int             `json:"quantity" gorm:"not null"`
+	Reason           ReturnReason    `json:"reason"`
+	Notes            string          `json:"notes"`
+	ReceivedQuantity int             `json:"received_quantity" gorm:"default:0"`
+	Condition        ReturnCondition `json:"condition"`
+	RefundAmount     float64         `json:"refund_amount" gorm:"type:decimal(10,2)"`
+	RestockingFee    float64         `json:"restocking_fee" gorm:"type:decimal(10,2);default:0"`
+	RestockQuantity  int             `json:"restock_quantity" gorm:"default:0"`
+	CreatedAt        time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
 
 	// Relationships
 	Return   Return        `json:"return,omitempty" gorm:"foreignKey:ReturnID"`
 	LineItem OrderLineItem `json:"line_item,omitempty" gorm:"foreignKey:LineItemID"`
 }
 
+// ReturnCondition is the condition a returned item arrived in
+type ReturnCondition string
+
+const (
+	ReturnConditionNew       ReturnCondition = "new"
+	ReturnConditionOpened    ReturnCondition = "opened"
+	ReturnConditionUsed      ReturnCondition = "used"
+	ReturnConditionDamaged   ReturnCondition = "damaged"
+	ReturnConditionDefective ReturnCondition = "defective"
+)
+
 // OrderEvent represents events in the order lifecycle
 type OrderEvent struct {
 	ID          uuid.UUID              `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
@@ -371,11 +461,21 @@ type OrderEventType string
 const (
 	OrderEventCreated           OrderEventType = "created"
 	OrderEventConfirmed         OrderEventType = "confirmed"
+	OrderEventProcessing        OrderEventType = "processing"
 	OrderEventPaymentAuthorized OrderEventType = "payment_authorized"
 	OrderEventPaymentCaptured   OrderEventType = "payment_captured"
+	OrderEventPaymentFailed     OrderEventType = "payment_failed"
+	OrderEventPaymentVoided     OrderEventType = "payment_voided"
+	OrderEventCaptureRequested  OrderEventType = "capture_requested"
+	OrderEventPaymentDisputed   OrderEventType = "payment_disputed"
+	OrderEventRouted            OrderEventType = "routed"
 	OrderEventFulfilled         OrderEventType = "fulfilled"
 	OrderEventShipped           OrderEventType = "shipped"
 	OrderEventDelivered         OrderEventType = "delivered"
+	OrderEventShipmentUpdated   OrderEventType = "shipment_updated"
+	OrderEventRiskHeld          OrderEventType = "risk_held"
+	OrderEventRiskApproved      OrderEventType = "risk_approved"
+	OrderEventEdited            OrderEventType = "edited"
 	OrderEventCancelled         OrderEventType = "cancelled"
 	OrderEventReturned          OrderEventType = "returned"
 	OrderEventRefunded          OrderEventType = "refunded"
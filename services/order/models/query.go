@@ -0,0 +1,96 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Order query errors
+var (
+	ErrInvalidOrderQuery = errors.New("invalid order query")
+	ErrInvalidCursor     = errors.New("invalid cursor")
+)
+
+// OrderQuery selects a merchant's orders. Every set field narrows the
+// results; a status set matches any of its statuses, while every tag must
+// be on the order.
+type OrderQuery struct {
+	Statuses            []OrderStatus
+	FulfillmentStatuses []FulfillmentStatus
+	PaymentStatuses     []PaymentStatus
+	Sources             []OrderSource
+	CustomerID          *uuid.UUID
+	CustomerEmail       string
+	CreatedFrom         *time.Time
+	CreatedTo           *time.Time
+	MinTotal            *float64
+	MaxTotal            *float64
+	Tags                []string
+	// SKU matches orders with a line item of the SKU
+	SKU string
+	// Search matches order numbers containing the text
+	Search string
+}
+
+// Validate rejects ranges that can never match
+func (q *OrderQuery) Validate() error {
+	if q.CreatedFrom != nil && q.CreatedTo != nil && q.CreatedFrom.After(*q.CreatedTo) {
+		return ErrInvalidOrderQuery
+	}
+	if q.MinTotal != nil && q.MaxTotal != nil && *q.MinTotal > *q.MaxTotal {
+		return ErrInvalidOrderQuery
+	}
+	return nil
+}
+
+// OrderCursor is the position of an order in a listing sorted newest first.
+// Orders created at the same time are ordered by ID so the position is
+// stable while orders are added.
+type OrderCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// CursorFor returns the cursor positioned at the order
+func CursorFor(order *Order) OrderCursor {
+	return OrderCursor{CreatedAt: order.CreatedAt, ID: order.ID}
+}
+
+// Encode returns the cursor as an opaque string for clients
+func (c OrderCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeOrderCursor parses a cursor returned by Encode
+func DecodeOrderCursor(s string) (*OrderCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &OrderCursor{}
+	if cursor.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.ID, err = uuid.Parse(id); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// OrderPage is a page of orders. NextCursor continues the listing after the
+// last order and is empty once there are no more.
+type OrderPage struct {
+	Orders     []*Order `json:"orders"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	HasMore    bool     `json:"has_more"`
+}
@@ -0,0 +1,44 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/models"
+)
+
+func TestOrderCursor_RoundTrip(t *testing.T) {
+	order := &models.Order{ID: uuid.New(), CreatedAt: time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC)}
+
+	cursor, err := models.DecodeOrderCursor(models.CursorFor(order).Encode())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cursor.ID != order.ID || !cursor.CreatedAt.Equal(order.CreatedAt) {
+		t.Errorf("expected the cursor to point at the order, got %+v", cursor)
+	}
+
+	for _, invalid := range []string{"not base64!", "bm8tc2VwYXJhdG9y", "Zm9vfGJhcg"} {
+		if _, err := models.DecodeOrderCursor(invalid); !errors.Is(err, models.ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor for %q, got %v", invalid, err)
+		}
+	}
+}
+
+func TestOrderQuery_Validate(t *testing.T) {
+	from, to := time.Now(), time.Now().Add(-time.Hour)
+	minTotal, maxTotal := 50.0, 10.0
+
+	if err := (&models.OrderQuery{}).Validate(); err != nil {
+		t.Errorf("expected an empty query to be valid, got %v", err)
+	}
+	if err := (&models.OrderQuery{CreatedFrom: &from, CreatedTo: &to}).Validate(); !errors.Is(err, models.ErrInvalidOrderQuery) {
+		t.Errorf("expected an inverted date range to be rejected, got %v", err)
+	}
+	if err := (&models.OrderQuery{MinTotal: &minTotal, MaxTotal: &maxTotal}).Validate(); !errors.Is(err, models.ErrInvalidOrderQuery) {
+		t.Errorf("expected an inverted total range to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/shared/tax"
+)
+
+// Return errors
+var (
+	ErrReturnQuantityExceeded = errors.New("return quantity exceeds the fulfilled quantity not yet returned")
+	ErrReturnLineItemNotFound = errors.New("line item is not part of the return")
+	ErrInvalidReturnReceipt   = errors.New("received quantity exceeds the returned quantity")
+	ErrReturnNotEditable      = errors.New("return can no longer be changed")
+)
+
+// returnTransitions lists the statuses a return may move to from each
+// status. Items can be received without a label, for returns dropped off in
+// store.
+var returnTransitions = map[ReturnStatus][]ReturnStatus{
+	ReturnStatusPending:     {ReturnStatusAuthorized, ReturnStatusDenied, ReturnStatusCancelled},
+	ReturnStatusAuthorized:  {ReturnStatusLabelIssued, ReturnStatusReceived, ReturnStatusCancelled},
+	ReturnStatusLabelIssued: {ReturnStatusLabelIssued, ReturnStatusReceived, ReturnStatusCancelled},
+	ReturnStatusReceived:    {ReturnStatusInspected},
+	ReturnStatusInspected:   {ReturnStatusRefunded},
+}
+
+// TransitionTo moves the return to the status and stamps the time it got
+// there. A label may be reissued; any other undeclared change is rejected
+// with a TransitionError.
+func (r *Return) TransitionTo(status ReturnStatus, now time.Time) error {
+	allowed := false
+	for _, to := range returnTransitions[r.Status] {
+		if to == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return &TransitionError{Machine: "return", From: string(r.Status), To: string(status)}
+	}
+
+	r.Status = status
+	switch status {
+	case ReturnStatusAuthorized:
+		r.AuthorizedAt = &now
+	case ReturnStatusLabelIssued:
+		r.LabelIssuedAt = &now
+	case ReturnStatusReceived:
+		r.ReceivedAt = &now
+	case ReturnStatusInspected:
+		r.InspectedAt = &now
+	case ReturnStatusRefunded:
+		r.ProcessedAt = &now
+		r.CompletedAt = &now
+	case ReturnStatusDenied, ReturnStatusCancelled:
+		r.CompletedAt = &now
+	}
+	return nil
+}
+
+// IsActive reports whether the return still claims its items, which is the
+// case unless it was denied or cancelled
+func (r *Return) IsActive() bool {
+	return r.Status != ReturnStatusDenied && r.Status != ReturnStatusCancelled
+}
+
+// Restockable reports whether items in the condition can be sold again
+func (c ReturnCondition) Restockable() bool {
+	return c == ReturnConditionNew || c == ReturnConditionOpened
+}
+
+// IsValid reports whether the condition is known
+func (c ReturnCondition) IsValid() bool {
+	switch c {
+	case ReturnConditionNew, ReturnConditionOpened, ReturnConditionUsed, ReturnConditionDamaged, ReturnConditionDefective:
+		return true
+	default:
+		return false
+	}
+}
+
+// ReturnableQuantities returns how many units of each line item can still be
+// returned: the fulfilled quantity less the units claimed by active returns
+func ReturnableQuantities(lineItems []OrderLineItem, returns []Return) map[uuid.UUID]int {
+	returnable := make(map[uuid.UUID]int, len(lineItems))
+	for _, lineItem := range lineItems {
+		returnable[lineItem.ID] = lineItem.FulfilledQuantity
+	}
+	for _, returnItem := range returns {
+		if !returnItem.IsActive() {
+			continue
+		}
+		for _, lineItem := range returnItem.LineItems {
+			returnable[lineItem.LineItemID] -= lineItem.Quantity
+		}
+	}
+	return returnable
+}
+
+// CheckReturnable checks the return's line items against the order's other
+// returns. Each item must be in the order and have enough fulfilled units
+// left to return.
+func (r *Return) CheckReturnable(order *Order, returns []Return) error {
+	returnable := ReturnableQuantities(order.LineItems, returns)
+	for _, lineItem := range r.LineItems {
+		available, ok := returnable[lineItem.LineItemID]
+		if !ok {
+			return ErrLineItemNotInOrder
+		}
+		if lineItem.Quantity > available {
+			return ErrReturnQuantityExceeded
+		}
+		returnable[lineItem.LineItemID] = available - lineItem.Quantity
+	}
+	return nil
+}
+
+// ReturnReceipt records how many units of a return line arrived and their
+// condition
+type ReturnReceipt struct {
+	ReturnLineItemID uuid.UUID
+	Quantity         int
+	Condition        ReturnCondition
+}
+
+// Receive records the receipts on the return's lines. Lines without a
+// receipt arrived in full and new. Units in a restockable condition are
+// restocked if the return restocks items.
+func (r *Return) Receive(receipts []ReturnReceipt) error {
+	byLine := make(map[uuid.UUID]ReturnReceipt, len(receipts))
+	for _, receipt := range receipts {
+		byLine[receipt.ReturnLineItemID] = receipt
+	}
+
+	for i := range r.LineItems {
+		lineItem := &r.LineItems[i]
+		receipt, ok := byLine[lineItem.ID]
+		delete(byLine, lineItem.ID)
+		if !ok {
+			receipt = ReturnReceipt{Quantity: lineItem.Quantity, Condition: ReturnConditionNew}
+		}
+		if receipt.Quantity < 0 || receipt.Quantity > lineItem.Quantity {
+			return ErrInvalidReturnReceipt
+		}
+
+		lineItem.ReceivedQuantity = receipt.Quantity
+		lineItem.Condition = receipt.Condition
+		lineItem.RestockQuantity = 0
+		if r.RestockItems && receipt.Condition.Restockable() {
+			lineItem.RestockQuantity = receipt.Quantity
+		}
+	}
+
+	if len(byLine) > 0 {
+		return ErrReturnLineItemNotFound
+	}
+	return nil
+}
+
+// CalculateRefund sets the refund of each line from the received quantity
+// at the price paid for it, including discounts and taxes, less the line's
+// restocking fee. The return's refund adds the order's shipping when it is
+// refunded. Refunds are never negative.
+func (r *Return) CalculateRefund(order *Order) {
+	lineItems := make(map[uuid.UUID]*OrderLineItem, len(order.LineItems))
+	for i := range order.LineItems {
+		lineItems[order.LineItems[i].ID] = &order.LineItems[i]
+	}
+
+	var refund, fees float64
+	for i := range r.LineItems {
+		returnLine := &r.LineItems[i]
+		lineItem, ok := lineItems[returnLine.LineItemID]
+		if !ok || lineItem.Quantity == 0 {
+			returnLine.RefundAmount = 0
+			continue
+		}
+
+		paid := lineItem.LinePrice - lineItem.TotalDiscount
+		if !order.TaxesIncluded {
+			for _, taxLine := range lineItem.TaxLines {
+				paid += taxLine.Price
+			}
+		}
+		amount := paid / float64(lineItem.Quantity) * float64(returnLine.ReceivedQuantity)
+		returnLine.RefundAmount = tax.Round(max(amount-returnLine.RestockingFee, 0))
+
+		refund += returnLine.RefundAmount
+		fees += returnLine.RestockingFee
+	}
+
+	if r.RefundShipping {
+		refund += order.TotalShipping
+		if !order.TaxesIncluded {
+			for _, taxLine := range order.ShippingTaxLines {
+				refund += taxLine.Price
+			}
+		}
+	}
+
+	r.RestockingFee = tax.Round(fees)
+	r.RefundAmount = tax.Round(refund)
+}
@@ -0,0 +1,112 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/models"
+)
+
+func TestReturn_Workflow(t *testing.T) {
+	now := time.Now()
+	returnItem := &models.Return{Status: models.ReturnStatusPending}
+
+	if err := returnItem.TransitionTo(models.ReturnStatusReceived, now); !errors.Is(err, models.ErrInvalidTransition) {
+		t.Fatalf("expected a pending return not to be receivable, got %v", err)
+	}
+
+	for _, status := range []models.ReturnStatus{
+		models.ReturnStatusAuthorized,
+		models.ReturnStatusLabelIssued,
+		models.ReturnStatusReceived,
+		models.ReturnStatusInspected,
+		models.ReturnStatusRefunded,
+	} {
+		if err := returnItem.TransitionTo(status, now); err != nil {
+			t.Fatalf("unexpected error moving to %s: %v", status, err)
+		}
+	}
+	if returnItem.ReceivedAt == nil || returnItem.CompletedAt == nil {
+		t.Errorf("expected the steps to be timestamped, got %+v", returnItem)
+	}
+
+	if err := returnItem.TransitionTo(models.ReturnStatusCancelled, now); !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("expected a refunded return not to be cancellable, got %v", err)
+	}
+}
+
+func TestReturn_CheckReturnable(t *testing.T) {
+	lineItemID := uuid.New()
+	order := &models.Order{LineItems: []models.OrderLineItem{{ID: lineItemID, Quantity: 3, FulfilledQuantity: 2}}}
+	existing := []models.Return{
+		{Status: models.ReturnStatusAuthorized, LineItems: []models.ReturnLineItem{{LineItemID: lineItemID, Quantity: 1}}},
+		{Status: models.ReturnStatusDenied, LineItems: []models.ReturnLineItem{{LineItemID: lineItemID, Quantity: 2}}},
+	}
+
+	returnItem := &models.Return{LineItems: []models.ReturnLineItem{{LineItemID: lineItemID, Quantity: 1}}}
+	if err := returnItem.CheckReturnable(order, existing); err != nil {
+		t.Errorf("expected the last fulfilled unit to be returnable, got %v", err)
+	}
+
+	returnItem.LineItems[0].Quantity = 2
+	if err := returnItem.CheckReturnable(order, existing); !errors.Is(err, models.ErrReturnQuantityExceeded) {
+		t.Errorf("expected ErrReturnQuantityExceeded, got %v", err)
+	}
+
+	returnItem.LineItems[0].LineItemID = uuid.New()
+	if err := returnItem.CheckReturnable(order, existing); !errors.Is(err, models.ErrLineItemNotInOrder) {
+		t.Errorf("expected ErrLineItemNotInOrder, got %v", err)
+	}
+}
+
+func TestReturn_ReceiveAndCalculateRefund(t *testing.T) {
+	shirt, hat := uuid.New(), uuid.New()
+	order := &models.Order{
+		TotalShipping:    5,
+		ShippingTaxLines: []models.TaxLine{{Price: 0.5}},
+		LineItems: []models.OrderLineItem{
+			{ID: shirt, Quantity: 2, LinePrice: 40, TotalDiscount: 4, TaxLines: []models.TaxLine{{Price: 3.6}}},
+			{ID: hat, Quantity: 1, LinePrice: 15},
+		},
+	}
+	returnItem := &models.Return{
+		RestockItems:   true,
+		RefundShipping: true,
+		LineItems: []models.ReturnLineItem{
+			{ID: uuid.New(), LineItemID: shirt, Quantity: 2},
+			{ID: uuid.New(), LineItemID: hat, Quantity: 1},
+		},
+	}
+
+	err := returnItem.Receive([]models.ReturnReceipt{
+		{ReturnLineItemID: returnItem.LineItems[0].ID, Quantity: 1, Condition: models.ReturnConditionDamaged},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	shirtLine, hatLine := &returnItem.LineItems[0], &returnItem.LineItems[1]
+	if shirtLine.ReceivedQuantity != 1 || shirtLine.RestockQuantity != 0 {
+		t.Errorf("expected one damaged shirt not to be restocked, got %+v", shirtLine)
+	}
+	if hatLine.ReceivedQuantity != 1 || hatLine.RestockQuantity != 1 {
+		t.Errorf("expected the unlisted hat to arrive new and be restocked, got %+v", hatLine)
+	}
+
+	hatLine.RestockingFee = 3
+	returnItem.CalculateRefund(order)
+
+	// Half of the discounted, taxed shirt, the hat less its fee, and shipping
+	if shirtLine.RefundAmount != 19.8 || hatLine.RefundAmount != 12 {
+		t.Errorf("unexpected line refunds %.2f and %.2f", shirtLine.RefundAmount, hatLine.RefundAmount)
+	}
+	if returnItem.RefundAmount != 37.3 || returnItem.RestockingFee != 3 {
+		t.Errorf("expected a refund of 37.30 after a 3.00 fee, got %.2f and %.2f", returnItem.RefundAmount, returnItem.RestockingFee)
+	}
+
+	if err := returnItem.Receive([]models.ReturnReceipt{{ReturnLineItemID: shirtLine.ID, Quantity: 3}}); !errors.Is(err, models.ErrInvalidReturnReceipt) {
+		t.Errorf("expected ErrInvalidReturnReceipt, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Risk errors
+var (
+	ErrRiskReviewClosed = errors.New("risk review is not pending")
+)
+
+// RiskLevel represents how likely an order is to be fraudulent
+type RiskLevel string
+
+const (
+	RiskLevelLow    RiskLevel = "low"
+	RiskLevelMedium RiskLevel = "medium"
+	RiskLevelHigh   RiskLevel = "high"
+)
+
+// RiskReviewStatus represents where an order is in the risk review queue
+type RiskReviewStatus string
+
+const (
+	RiskReviewNotRequired RiskReviewStatus = "not_required"
+	RiskReviewPending     RiskReviewStatus = "pending"
+	RiskReviewApproved    RiskReviewStatus = "approved"
+	RiskReviewCancelled   RiskReviewStatus = "cancelled"
+)
+
+// RiskSignal is a reason an order was found risky, adding Score to the
+// order's risk score
+type RiskSignal struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Score       int    `json:"score"`
+}
+
+// RiskAssessment is the fraud risk scored for an order when it was placed,
+// and rescored as payments fail. High-risk orders wait in the review queue,
+// held from being confirmed, until staff approve or cancel them.
+type RiskAssessment struct {
+	ID           uuid.UUID        `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	OrderID      uuid.UUID        `json:"order_id" gorm:"type:uuid;not null;uniqueIndex"`
+	MerchantID   uuid.UUID        `json:"merchant_id" gorm:"type:uuid;not null;index"`
+	Score        int              `json:"score"`
+	Level        RiskLevel        `json:"level" gorm:"not null"`
+	Signals      []RiskSignal     `json:"signals" gorm:"type:jsonb"`
+	ReviewStatus RiskReviewStatus `json:"review_status" gorm:"not null;index"`
+	ReviewedBy   *uuid.UUID       `json:"reviewed_by" gorm:"type:uuid"`
+	ReviewedAt   *time.Time       `json:"reviewed_at"`
+	ReviewNote   string           `json:"review_note"`
+	CreatedAt    time.Time        `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time        `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Relationships
+	Order *Order `json:"order,omitempty" gorm:"foreignKey:OrderID"`
+}
+
+func (a *RiskAssessment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// Rescore replaces the assessment's score. An order whose score becomes
+// high while it is still pending is held for review, unless staff have
+// already reviewed it. It reports whether the order was newly held.
+func (a *RiskAssessment) Rescore(scored *RiskAssessment, order *Order) bool {
+	a.Score = scored.Score
+	a.Level = scored.Level
+	a.Signals = scored.Signals
+	order.RiskLevel = scored.Level
+
+	if a.Level != RiskLevelHigh || a.ReviewStatus != RiskReviewNotRequired || order.Status != OrderStatusPending {
+		return false
+	}
+	a.ReviewStatus = RiskReviewPending
+	order.HeldForReview = true
+	return true
+}
+
+// Review closes a pending review as approved or cancelled, releasing the
+// order's hold
+func (a *RiskAssessment) Review(status RiskReviewStatus, order *Order, note string, userID *uuid.UUID, now time.Time) error {
+	if a.ReviewStatus != RiskReviewPending {
+		return ErrRiskReviewClosed
+	}
+	a.ReviewStatus = status
+	a.ReviewNote = note
+	a.ReviewedBy = userID
+	a.ReviewedAt = &now
+	order.HeldForReview = false
+	return nil
+}
+
+// RiskHistory is what the order's merchant has seen of its customer, its
+// email and its browser's IP address. Order counts leave out the order
+// itself; failed payments include the order's own.
+type RiskHistory struct {
+	PreviousOrders       int64 // every other order of the customer or email
+	RecentCustomerOrders int64 // orders of the customer since VelocitySince
+	RecentEmailOrders    int64 // orders with the email since VelocitySince
+	RecentIPOrders       int64 // orders from the browser IP since VelocitySince
+	FailedPayments       int64 // failed payments of the order and the customer's orders since FailedPaymentsSince
+}
+
+// RiskHistoryQuery sets the windows a RiskHistory counts recent activity in
+type RiskHistoryQuery struct {
+	VelocitySince       time.Time
+	FailedPaymentsSince time.Time
+}
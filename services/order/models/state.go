@@ -0,0 +1,477 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/shared/tax"
+)
+
+// ErrInvalidTransition is matched by every TransitionError
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// Guard errors
+var (
+	ErrOrderHasNoLineItems = errors.New("order has no line items")
+	ErrOrderNotOpen        = errors.New("order is not confirmed or is already closed")
+	ErrOrderNotFulfilled   = errors.New("order is not fully fulfilled")
+	ErrOrderHasFulfillment = errors.New("order has fulfilled items, create a return instead")
+	ErrOrderNotRefunded    = errors.New("order payment is not fully refunded")
+	ErrOrderCancelled      = errors.New("order is cancelled")
+	ErrOrderHeldForReview  = errors.New("order is held for risk review")
+)
+
+// TransitionError reports a status change a state machine rejected. Reason
+// is the guard's error when the transition is declared but not allowed for
+// the order, and nil when the transition is not declared at all.
+type TransitionError struct {
+	Machine string
+	From    string
+	To      string
+	Reason  error
+}
+
+func (e *TransitionError) Error() string {
+	if e.Reason != nil {
+		return fmt.Sprintf("%s status cannot change from %s to %s: %v", e.Machine, e.From, e.To, e.Reason)
+	}
+	return fmt.Sprintf("%s status cannot change from %s to %s", e.Machine, e.From, e.To)
+}
+
+// Is makes every TransitionError match ErrInvalidTransition
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// Unwrap returns the guard's error
+func (e *TransitionError) Unwrap() error {
+	return e.Reason
+}
+
+// Transition declares that a status may change from any of From to To.
+// Guard can veto the change for a particular order; Effect applies its side
+// effects, such as timestamps, to the order; Event is recorded when the
+// transition happens, unless it is empty.
+type Transition[S ~string] struct {
+	From   []S
+	To     S
+	Event  OrderEventType
+	Guard  func(order *Order) error
+	Effect func(order *Order, now time.Time)
+}
+
+// StateMachine holds the declared transitions of one of the order's status
+// fields
+type StateMachine[S ~string] struct {
+	name        string
+	get         func(order *Order) S
+	set         func(order *Order, status S)
+	transitions map[S]map[S]*Transition[S]
+}
+
+// NewStateMachine creates a state machine for the status field read by get
+// and written by set
+func NewStateMachine[S ~string](name string, get func(*Order) S, set func(*Order, S), transitions ...Transition[S]) *StateMachine[S] {
+	m := &StateMachine[S]{
+		name:        name,
+		get:         get,
+		set:         set,
+		transitions: make(map[S]map[S]*Transition[S]),
+	}
+	for i := range transitions {
+		transition := &transitions[i]
+		for _, from := range transition.From {
+			if m.transitions[from] == nil {
+				m.transitions[from] = make(map[S]*Transition[S])
+			}
+			m.transitions[from][transition.To] = transition
+		}
+	}
+	return m
+}
+
+// Name returns the name of the status field the machine governs
+func (m *StateMachine[S]) Name() string {
+	return m.name
+}
+
+// Can reports whether a transition from one status to the other is declared
+func (m *StateMachine[S]) Can(from, to S) bool {
+	_, ok := m.transitions[from][to]
+	return ok
+}
+
+// Transition moves the order to the status. It runs the guard, sets the
+// status and applies the side effects, and returns the transition taken, or
+// nil if the order already has the status. The order is left unchanged when
+// an error is returned.
+func (m *StateMachine[S]) Transition(order *Order, to S, now time.Time) (*Transition[S], error) {
+	from := m.get(order)
+	if from == to {
+		return nil, nil
+	}
+
+	transition, ok := m.transitions[from][to]
+	if !ok {
+		return nil, &TransitionError{Machine: m.name, From: string(from), To: string(to)}
+	}
+	if transition.Guard != nil {
+		if err := transition.Guard(order); err != nil {
+			return nil, &TransitionError{Machine: m.name, From: string(from), To: string(to), Reason: err}
+		}
+	}
+
+	m.set(order, to)
+	if transition.Effect != nil {
+		transition.Effect(order, now)
+	}
+	return transition, nil
+}
+
+// OrderStateMachine governs Order.Status
+var OrderStateMachine = NewStateMachine("order",
+	func(o *Order) OrderStatus { return o.Status },
+	func(o *Order, s OrderStatus) { o.Status = s },
+	Transition[OrderStatus]{
+		From:   []OrderStatus{OrderStatusPending},
+		To:     OrderStatusConfirmed,
+		Event:  OrderEventConfirmed,
+		Guard:  isConfirmable,
+		Effect: func(o *Order, now time.Time) { o.ProcessedAt = &now },
+	},
+	Transition[OrderStatus]{
+		From:  []OrderStatus{OrderStatusConfirmed},
+		To:    OrderStatusProcessing,
+		Event: OrderEventProcessing,
+	},
+	Transition[OrderStatus]{
+		From:   []OrderStatus{OrderStatusConfirmed, OrderStatusProcessing},
+		To:     OrderStatusShipped,
+		Event:  OrderEventShipped,
+		Guard:  isFulfilled,
+		Effect: func(o *Order, now time.Time) { o.ShippedAt = &now },
+	},
+	Transition[OrderStatus]{
+		From:   []OrderStatus{OrderStatusShipped},
+		To:     OrderStatusDelivered,
+		Event:  OrderEventDelivered,
+		Effect: func(o *Order, now time.Time) { o.DeliveredAt = &now },
+	},
+	Transition[OrderStatus]{
+		From:   []OrderStatus{OrderStatusPending, OrderStatusConfirmed, OrderStatusProcessing},
+		To:     OrderStatusCancelled,
+		Event:  OrderEventCancelled,
+		Guard:  hasNoFulfillment,
+		Effect: func(o *Order, now time.Time) { o.CancelledAt = &now },
+	},
+	Transition[OrderStatus]{
+		From:  []OrderStatus{OrderStatusShipped, OrderStatusDelivered},
+		To:    OrderStatusReturned,
+		Event: OrderEventReturned,
+	},
+	Transition[OrderStatus]{
+		From:  []OrderStatus{OrderStatusCancelled, OrderStatusDelivered, OrderStatusReturned},
+		To:    OrderStatusRefunded,
+		Event: OrderEventRefunded,
+		Guard: isRefunded,
+	},
+)
+
+// FulfillmentStateMachine governs Order.FulfillmentStatus. The fulfilled
+// event is recorded per fulfillment, so reaching fulfilled only stamps
+// FulfilledAt.
+var FulfillmentStateMachine = NewStateMachine("fulfillment",
+	func(o *Order) FulfillmentStatus { return o.FulfillmentStatus },
+	func(o *Order, s FulfillmentStatus) { o.FulfillmentStatus = s },
+	Transition[FulfillmentStatus]{
+		From:  []FulfillmentStatus{FulfillmentStatusUnfulfilled},
+		To:    FulfillmentStatusPartiallyFulfilled,
+		Guard: isOpen,
+	},
+	Transition[FulfillmentStatus]{
+		From:   []FulfillmentStatus{FulfillmentStatusUnfulfilled, FulfillmentStatusPartiallyFulfilled},
+		To:     FulfillmentStatusFulfilled,
+		Guard:  isOpen,
+		Effect: func(o *Order, now time.Time) { o.FulfilledAt = &now },
+	},
+	Transition[FulfillmentStatus]{
+		// An order edit added items to a fulfilled order
+		From:   []FulfillmentStatus{FulfillmentStatusFulfilled},
+		To:     FulfillmentStatusPartiallyFulfilled,
+		Guard:  isOpen,
+		Effect: func(o *Order, now time.Time) { o.FulfilledAt = nil },
+	},
+	Transition[FulfillmentStatus]{
+		From: []FulfillmentStatus{FulfillmentStatusPartiallyFulfilled, FulfillmentStatusFulfilled},
+		To:   FulfillmentStatusRestocked,
+	},
+)
+
+// PaymentStateMachine governs Order.PaymentStatus
+var PaymentStateMachine = NewStateMachine("payment",
+	func(o *Order) PaymentStatus { return o.PaymentStatus },
+	func(o *Order, s PaymentStatus) { o.PaymentStatus = s },
+	Transition[PaymentStatus]{
+		From:  []PaymentStatus{PaymentStatusPending, PaymentStatusFailed},
+		To:    PaymentStatusAuthorized,
+		Event: OrderEventPaymentAuthorized,
+		Guard: isNotCancelled,
+	},
+	Transition[PaymentStatus]{
+		From:  []PaymentStatus{PaymentStatusPending, PaymentStatusFailed, PaymentStatusAuthorized},
+		To:    PaymentStatusPartiallyPaid,
+		Event: OrderEventPaymentCaptured,
+		Guard: isNotCancelled,
+	},
+	Transition[PaymentStatus]{
+		From:  []PaymentStatus{PaymentStatusPending, PaymentStatusFailed, PaymentStatusAuthorized, PaymentStatusPartiallyPaid},
+		To:    PaymentStatusPaid,
+		Event: OrderEventPaymentCaptured,
+		Guard: isNotCancelled,
+	},
+	Transition[PaymentStatus]{
+		// An order edit raised the total of a paid order
+		From:  []PaymentStatus{PaymentStatusPaid},
+		To:    PaymentStatusPartiallyPaid,
+		Guard: isNotCancelled,
+	},
+	Transition[PaymentStatus]{
+		From:  []PaymentStatus{PaymentStatusPending},
+		To:    PaymentStatusFailed,
+		Event: OrderEventPaymentFailed,
+	},
+	Transition[PaymentStatus]{
+		From:  []PaymentStatus{PaymentStatusAuthorized},
+		To:    PaymentStatusVoided,
+		Event: OrderEventPaymentVoided,
+	},
+	Transition[PaymentStatus]{
+		From:  []PaymentStatus{PaymentStatusPartiallyPaid, PaymentStatusPaid, PaymentStatusCaptured},
+		To:    PaymentStatusPartiallyRefunded,
+		Event: OrderEventRefunded,
+	},
+	Transition[PaymentStatus]{
+		From:  []PaymentStatus{PaymentStatusPartiallyPaid, PaymentStatusPaid, PaymentStatusCaptured, PaymentStatusPartiallyRefunded},
+		To:    PaymentStatusRefunded,
+		Event: OrderEventRefunded,
+	},
+)
+
+// Guards
+
+func hasLineItems(o *Order) error {
+	if len(o.LineItems) == 0 {
+		return ErrOrderHasNoLineItems
+	}
+	return nil
+}
+
+func isConfirmable(o *Order) error {
+	if err := hasLineItems(o); err != nil {
+		return err
+	}
+	if o.HeldForReview {
+		return ErrOrderHeldForReview
+	}
+	return nil
+}
+
+func isOpen(o *Order) error {
+	if o.Status != OrderStatusConfirmed && o.Status != OrderStatusProcessing {
+		return ErrOrderNotOpen
+	}
+	return nil
+}
+
+func isFulfilled(o *Order) error {
+	if o.FulfillmentStatus != FulfillmentStatusFulfilled {
+		return ErrOrderNotFulfilled
+	}
+	return nil
+}
+
+func hasNoFulfillment(o *Order) error {
+	if o.FulfillmentStatus != FulfillmentStatusUnfulfilled {
+		return ErrOrderHasFulfillment
+	}
+	return nil
+}
+
+func isRefunded(o *Order) error {
+	if o.PaymentStatus != PaymentStatusRefunded {
+		return ErrOrderNotRefunded
+	}
+	return nil
+}
+
+func isNotCancelled(o *Order) error {
+	if o.Status == OrderStatusCancelled {
+		return ErrOrderCancelled
+	}
+	return nil
+}
+
+// Derived statuses
+
+// LineItemFulfillmentStatus computes a line item's fulfillment status from
+// its fulfilled quantity
+func LineItemFulfillmentStatus(lineItem *OrderLineItem) FulfillmentStatus {
+	switch {
+	case lineItem.FulfilledQuantity <= 0:
+		return FulfillmentStatusUnfulfilled
+	case lineItem.FulfilledQuantity < lineItem.Quantity:
+		return FulfillmentStatusPartiallyFulfilled
+	default:
+		return FulfillmentStatusFulfilled
+	}
+}
+
+// DeriveFulfillmentStatus computes the order's fulfillment status from its
+// line items
+func DeriveFulfillmentStatus(lineItems []OrderLineItem) FulfillmentStatus {
+	var quantity, fulfilled int
+	for _, lineItem := range lineItems {
+		quantity += lineItem.Quantity
+		fulfilled += min(lineItem.FulfilledQuantity, lineItem.Quantity)
+	}
+
+	switch {
+	case fulfilled == 0:
+		return FulfillmentStatusUnfulfilled
+	case fulfilled < quantity:
+		return FulfillmentStatusPartiallyFulfilled
+	default:
+		return FulfillmentStatusFulfilled
+	}
+}
+
+// DeriveShippingStatus computes the order status implied by its shipments:
+// shipped once the order is fulfilled and every shipment has left, delivered
+// once every shipment has arrived. ok is false when the shipments imply
+// neither.
+func DeriveShippingStatus(order *Order) (status OrderStatus, ok bool) {
+	if order.FulfillmentStatus != FulfillmentStatusFulfilled {
+		return "", false
+	}
+
+	shipments, shipped, delivered := 0, 0, 0
+	for _, fulfillment := range order.Fulfillments {
+		if fulfillment.ShipmentStatus == ShipmentStatusCancelled {
+			continue
+		}
+		shipments++
+		if fulfillment.ShippedAt != nil {
+			shipped++
+		}
+		if fulfillment.ShipmentStatus == ShipmentStatusDelivered {
+			delivered++
+		}
+	}
+
+	switch {
+	case shipments == 0:
+		return "", false
+	case delivered == shipments:
+		return OrderStatusDelivered, true
+	case shipped == shipments:
+		return OrderStatusShipped, true
+	default:
+		return "", false
+	}
+}
+
+// DerivePaymentStatus computes the order's payment status from its
+// successful transactions against the order total
+func DerivePaymentStatus(total float64, transactions []Transaction) PaymentStatus {
+	var authorized, captured, refunded float64
+	var voided, failed bool
+	for _, transaction := range transactions {
+		if transaction.Status == TransactionStatusFailure {
+			failed = true
+			continue
+		}
+		if transaction.Status != TransactionStatusSuccess {
+			continue
+		}
+		switch transaction.Kind {
+		case TransactionKindAuthorization:
+			authorized += transaction.Amount
+		case TransactionKindCapture, TransactionKindSale:
+			captured += transaction.Amount
+		case TransactionKindRefund:
+			refunded += transaction.Amount
+		case TransactionKindVoid:
+			voided = true
+		}
+	}
+
+	switch {
+	case refunded > 0 && refunded >= captured:
+		return PaymentStatusRefunded
+	case refunded > 0:
+		return PaymentStatusPartiallyRefunded
+	case captured > 0 && captured >= total:
+		return PaymentStatusPaid
+	case captured > 0:
+		return PaymentStatusPartiallyPaid
+	case voided:
+		return PaymentStatusVoided
+	case authorized > 0:
+		return PaymentStatusAuthorized
+	case failed:
+		return PaymentStatusFailed
+	default:
+		return PaymentStatusPending
+	}
+}
+
+// CapturableAmount returns what is left to capture of the authorizations on
+// an order's transactions. Sales are captured as they are authorized, and a
+// void releases what is left of every authorization.
+func CapturableAmount(transactions []Transaction) float64 {
+	var authorized, captured float64
+	for _, transaction := range transactions {
+		if transaction.Status != TransactionStatusSuccess {
+			continue
+		}
+		switch transaction.Kind {
+		case TransactionKindAuthorization:
+			authorized += transaction.Amount
+		case TransactionKindCapture:
+			captured += transaction.Amount
+		case TransactionKindVoid:
+			return 0
+		}
+	}
+	return tax.Round(max(authorized-captured, 0))
+}
+
+// IsFullyReturned reports whether the refunded returns, or those processed
+// before returns were refunded through their workflow, cover every unit of
+// the order's line items
+func IsFullyReturned(lineItems []OrderLineItem, returns []Return) bool {
+	returned := make(map[uuid.UUID]int)
+	for _, returnItem := range returns {
+		switch returnItem.Status {
+		case ReturnStatusRefunded, ReturnStatusProcessed, ReturnStatusCompleted:
+		default:
+			continue
+		}
+		for _, lineItem := range returnItem.LineItems {
+			returned[lineItem.LineItemID] += lineItem.Quantity
+		}
+	}
+
+	if len(lineItems) == 0 {
+		return false
+	}
+	for _, lineItem := range lineItems {
+		if returned[lineItem.ID] < lineItem.Quantity {
+			return false
+		}
+	}
+	return true
+}
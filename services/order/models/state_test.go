@@ -0,0 +1,197 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/models"
+)
+
+func TestOrderStateMachine_RejectsUndeclaredTransitions(t *testing.T) {
+	order := &models.Order{Status: models.OrderStatusDelivered}
+
+	_, err := models.OrderStateMachine.Transition(order, models.OrderStatusPending, time.Now())
+
+	var transitionErr *models.TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Fatalf("expected a TransitionError, got %v", err)
+	}
+	if !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("expected error to match ErrInvalidTransition")
+	}
+	if order.Status != models.OrderStatusDelivered {
+		t.Errorf("status changed to %s on a rejected transition", order.Status)
+	}
+}
+
+func TestOrderStateMachine_GuardsAndEffects(t *testing.T) {
+	now := time.Now()
+	order := &models.Order{
+		Status:            models.OrderStatusConfirmed,
+		FulfillmentStatus: models.FulfillmentStatusPartiallyFulfilled,
+	}
+
+	_, err := models.OrderStateMachine.Transition(order, models.OrderStatusShipped, now)
+	if !errors.Is(err, models.ErrOrderNotFulfilled) {
+		t.Fatalf("expected ErrOrderNotFulfilled, got %v", err)
+	}
+
+	order.FulfillmentStatus = models.FulfillmentStatusFulfilled
+	transition, err := models.OrderStateMachine.Transition(order, models.OrderStatusShipped, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if transition.Event != models.OrderEventShipped {
+		t.Errorf("expected shipped event, got %s", transition.Event)
+	}
+	if order.ShippedAt == nil || !order.ShippedAt.Equal(now) {
+		t.Errorf("expected ShippedAt to be set")
+	}
+
+	_, err = models.OrderStateMachine.Transition(order, models.OrderStatusCancelled, now)
+	if !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("expected shipped orders not to be cancellable, got %v", err)
+	}
+
+	transition, err = models.OrderStateMachine.Transition(order, models.OrderStatusShipped, now)
+	if err != nil || transition != nil {
+		t.Errorf("expected a transition to the current status to be a no-op, got %v, %v", transition, err)
+	}
+}
+
+func TestOrderStateMachine_HoldsOrdersForReview(t *testing.T) {
+	order := &models.Order{
+		Status:        models.OrderStatusPending,
+		HeldForReview: true,
+		LineItems:     []models.OrderLineItem{{Quantity: 1}},
+	}
+
+	_, err := models.OrderStateMachine.Transition(order, models.OrderStatusConfirmed, time.Now())
+	if !errors.Is(err, models.ErrOrderHeldForReview) {
+		t.Fatalf("expected ErrOrderHeldForReview, got %v", err)
+	}
+
+	order.HeldForReview = false
+	if _, err := models.OrderStateMachine.Transition(order, models.OrderStatusConfirmed, time.Now()); err != nil {
+		t.Errorf("expected a released order to confirm, got %v", err)
+	}
+}
+
+func TestStateMachines_ReopenEditedOrders(t *testing.T) {
+	now := time.Now()
+	order := &models.Order{
+		Status:            models.OrderStatusProcessing,
+		FulfillmentStatus: models.FulfillmentStatusFulfilled,
+		PaymentStatus:     models.PaymentStatusPaid,
+		FulfilledAt:       &now,
+	}
+
+	if _, err := models.FulfillmentStateMachine.Transition(order, models.FulfillmentStatusPartiallyFulfilled, now); err != nil {
+		t.Fatalf("expected a fulfilled order with added items to become partially fulfilled, got %v", err)
+	}
+	if order.FulfilledAt != nil {
+		t.Errorf("expected FulfilledAt to be cleared")
+	}
+	if _, err := models.PaymentStateMachine.Transition(order, models.PaymentStatusPartiallyPaid, now); err != nil {
+		t.Fatalf("expected a paid order with a raised total to become partially paid, got %v", err)
+	}
+}
+
+func TestDeriveFulfillmentStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		fulfilled []int
+		expected  models.FulfillmentStatus
+	}{
+		{"nothing fulfilled", []int{0, 0}, models.FulfillmentStatusUnfulfilled},
+		{"some fulfilled", []int{2, 0}, models.FulfillmentStatusPartiallyFulfilled},
+		{"all fulfilled", []int{2, 1}, models.FulfillmentStatusFulfilled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lineItems := []models.OrderLineItem{
+				{Quantity: 2, FulfilledQuantity: tt.fulfilled[0]},
+				{Quantity: 1, FulfilledQuantity: tt.fulfilled[1]},
+			}
+			if status := models.DeriveFulfillmentStatus(lineItems); status != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, status)
+			}
+		})
+	}
+}
+
+func TestDerivePaymentStatus(t *testing.T) {
+	transaction := func(kind models.TransactionKind, status models.TransactionStatus, amount float64) models.Transaction {
+		return models.Transaction{ID: uuid.New(), Kind: kind, Status: status, Amount: amount}
+	}
+
+	tests := []struct {
+		name         string
+		transactions []models.Transaction
+		expected     models.PaymentStatus
+	}{
+		{"no transactions", nil, models.PaymentStatusPending},
+		{"failed sale", []models.Transaction{
+			transaction(models.TransactionKindSale, models.TransactionStatusFailure, 100),
+		}, models.PaymentStatusFailed},
+		{"authorized", []models.Transaction{
+			transaction(models.TransactionKindAuthorization, models.TransactionStatusSuccess, 100),
+		}, models.PaymentStatusAuthorized},
+		{"partially captured", []models.Transaction{
+			transaction(models.TransactionKindAuthorization, models.TransactionStatusSuccess, 100),
+			transaction(models.TransactionKindCapture, models.TransactionStatusSuccess, 40),
+		}, models.PaymentStatusPartiallyPaid},
+		{"paid", []models.Transaction{
+			transaction(models.TransactionKindSale, models.TransactionStatusSuccess, 100),
+		}, models.PaymentStatusPaid},
+		{"partially refunded", []models.Transaction{
+			transaction(models.TransactionKindSale, models.TransactionStatusSuccess, 100),
+			transaction(models.TransactionKindRefund, models.TransactionStatusSuccess, 30),
+		}, models.PaymentStatusPartiallyRefunded},
+		{"refunded", []models.Transaction{
+			transaction(models.TransactionKindSale, models.TransactionStatusSuccess, 100),
+			transaction(models.TransactionKindRefund, models.TransactionStatusSuccess, 100),
+		}, models.PaymentStatusRefunded},
+		{"voided", []models.Transaction{
+			transaction(models.TransactionKindAuthorization, models.TransactionStatusSuccess, 100),
+			transaction(models.TransactionKindVoid, models.TransactionStatusSuccess, 0),
+		}, models.PaymentStatusVoided},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := models.DerivePaymentStatus(100, tt.transactions); status != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, status)
+			}
+		})
+	}
+}
+
+func TestCapturableAmount(t *testing.T) {
+	transaction := func(kind models.TransactionKind, status models.TransactionStatus, amount float64) models.Transaction {
+		return models.Transaction{ID: uuid.New(), Kind: kind, Status: status, Amount: amount}
+	}
+	authorization := transaction(models.TransactionKindAuthorization, models.TransactionStatusSuccess, 100)
+
+	transactions := []models.Transaction{
+		authorization,
+		transaction(models.TransactionKindCapture, models.TransactionStatusSuccess, 30.1),
+		transaction(models.TransactionKindCapture, models.TransactionStatusFailure, 50),
+		transaction(models.TransactionKindCapture, models.TransactionStatusSuccess, 30.2),
+	}
+	if amount := models.CapturableAmount(transactions); amount != 39.7 {
+		t.Errorf("expected 39.70 left after two captures, got %v", amount)
+	}
+
+	voided := append(transactions, transaction(models.TransactionKindVoid, models.TransactionStatusSuccess, 39.7))
+	if amount := models.CapturableAmount(voided); amount != 0 {
+		t.Errorf("expected a void to release the rest, got %v", amount)
+	}
+	if amount := models.CapturableAmount([]models.Transaction{transaction(models.TransactionKindSale, models.TransactionStatusSuccess, 100)}); amount != 0 {
+		t.Errorf("expected nothing to capture of a sale, got %v", amount)
+	}
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tracking errors
+var (
+	ErrInvalidTrackingToken = errors.New("invalid tracking token")
+)
+
+// TrackingEvent is a status a carrier reported for a fulfillment's
+// shipment. Status is empty when the carrier's status has no ShipmentStatus
+// equivalent; such events appear in the timeline without changing the
+// shipment's status.
+type TrackingEvent struct {
+	ID             uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	FulfillmentID  uuid.UUID      `json:"fulfillment_id" gorm:"type:uuid;not null;uniqueIndex:idx_tracking_events_scan,priority:1"`
+	Carrier        string         `json:"carrier" gorm:"not null"`
+	TrackingNumber string         `json:"tracking_number" gorm:"not null"`
+	Status         ShipmentStatus `json:"status"`
+	CarrierStatus  string         `json:"carrier_status" gorm:"uniqueIndex:idx_tracking_events_scan,priority:3"` // the carrier's own status code
+	Description    string         `json:"description"`
+	Location       string         `json:"location"`
+	OccurredAt     time.Time      `json:"occurred_at" gorm:"not null;uniqueIndex:idx_tracking_events_scan,priority:2"`
+	CreatedAt      time.Time      `json:"created_at" gorm:"autoCreateTime"`
+}
+
+func (e *TrackingEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// HasShipped reports whether a shipment with the status has left the
+// merchant
+func (s ShipmentStatus) HasShipped() bool {
+	switch s {
+	case ShipmentStatusInTransit, ShipmentStatusOutForDelivery, ShipmentStatusDelivered,
+		ShipmentStatusException, ShipmentStatusFailure:
+		return true
+	}
+	return false
+}
+
+// ApplyTracking sets the fulfillment's shipment status to the status of its
+// latest tracking event that has one. The first event after pickup stamps
+// ShippedAt if the fulfillment was not marked shipped, and DeliveredAt
+// follows the delivered status, so a carrier can take back a delivery it
+// reported by mistake. Events are taken in order of occurrence, with later
+// events in the slice winning ties. It reports whether the fulfillment
+// changed.
+func (f *Fulfillment) ApplyTracking(events []TrackingEvent) bool {
+	var latest, pickup *TrackingEvent
+	for i := range events {
+		event := &events[i]
+		if event.Status == "" {
+			continue
+		}
+		if latest == nil || !event.OccurredAt.Before(latest.OccurredAt) {
+			latest = event
+		}
+		if event.Status.HasShipped() && (pickup == nil || event.OccurredAt.Before(pickup.OccurredAt)) {
+			pickup = event
+		}
+	}
+	if latest == nil {
+		return false
+	}
+
+	changed := false
+	if f.ShipmentStatus != latest.Status {
+		f.ShipmentStatus = latest.Status
+		changed = true
+	}
+	if f.ShippedAt == nil && pickup != nil {
+		shippedAt := pickup.OccurredAt
+		f.ShippedAt = &shippedAt
+		changed = true
+	}
+	switch {
+	case latest.Status == ShipmentStatusDelivered && (f.DeliveredAt == nil || !f.DeliveredAt.Equal(latest.OccurredAt)):
+		deliveredAt := latest.OccurredAt
+		f.DeliveredAt = &deliveredAt
+		changed = true
+	case latest.Status != ShipmentStatusDelivered && f.DeliveredAt != nil:
+		f.DeliveredAt = nil
+		changed = true
+	}
+	return changed
+}
+
+// OrderTracking is what a customer sees when tracking an order
+type OrderTracking struct {
+	OrderNumber       string             `json:"order_number"`
+	Status            OrderStatus        `json:"status"`
+	FulfillmentStatus FulfillmentStatus  `json:"fulfillment_status"`
+	CreatedAt         time.Time          `json:"created_at"`
+	ShippedAt         *time.Time         `json:"shipped_at"`
+	DeliveredAt       *time.Time         `json:"delivered_at"`
+	Shipments         []ShipmentTracking `json:"shipments"`
+}
+
+// ShipmentTracking is the tracking of one of an order's shipments, with
+// its timeline newest first
+type ShipmentTracking struct {
+	Carrier        string          `json:"carrier"`
+	TrackingNumber string          `json:"tracking_number"`
+	TrackingURL    string          `json:"tracking_url"`
+	Status         ShipmentStatus  `json:"status"`
+	ShippedAt      *time.Time      `json:"shipped_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	Timeline       []TimelineEntry `json:"timeline"`
+}
+
+// TimelineEntry is a step of a shipment's journey
+type TimelineEntry struct {
+	Status      ShipmentStatus `json:"status,omitempty"`
+	Description string         `json:"description"`
+	Location    string         `json:"location,omitempty"`
+	OccurredAt  time.Time      `json:"occurred_at"`
+}
+
+// NewOrderTracking returns the tracking of an order loaded with its
+// fulfillments and their tracking events. Cancelled shipments are left out.
+func NewOrderTracking(order *Order) *OrderTracking {
+	tracking := &OrderTracking{
+		OrderNumber:       order.OrderNumber,
+		Status:            order.Status,
+		FulfillmentStatus: order.FulfillmentStatus,
+		CreatedAt:         order.CreatedAt,
+		ShippedAt:         order.ShippedAt,
+		DeliveredAt:       order.DeliveredAt,
+		Shipments:         []ShipmentTracking{},
+	}
+	for _, fulfillment := range order.Fulfillments {
+		if fulfillment.ShipmentStatus == ShipmentStatusCancelled {
+			continue
+		}
+
+		shipment := ShipmentTracking{
+			Carrier:        fulfillment.TrackingCompany,
+			TrackingNumber: fulfillment.TrackingNumber,
+			TrackingURL:    fulfillment.TrackingURL,
+			Status:         fulfillment.ShipmentStatus,
+			ShippedAt:      fulfillment.ShippedAt,
+			DeliveredAt:    fulfillment.DeliveredAt,
+			Timeline:       make([]TimelineEntry, 0, len(fulfillment.TrackingEvents)),
+		}
+		for _, event := range fulfillment.TrackingEvents {
+			shipment.Timeline = append(shipment.Timeline, TimelineEntry{
+				Status:      event.Status,
+				Description: event.Description,
+				Location:    event.Location,
+				OccurredAt:  event.OccurredAt,
+			})
+		}
+		sort.SliceStable(shipment.Timeline, func(i, j int) bool {
+			return shipment.Timeline[i].OccurredAt.After(shipment.Timeline[j].OccurredAt)
+		})
+		tracking.Shipments = append(tracking.Shipments, shipment)
+	}
+	return tracking
+}
+
+// SignTrackingToken returns a token that lets a customer track the order
+// without the email it was placed with, for links in shipping
+// notifications. The token is the order ID followed by its HMAC-SHA256.
+func SignTrackingToken(secret []byte, orderID uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString(append(orderID[:], trackingMAC(secret, orderID)...))
+}
+
+// VerifyTrackingToken returns the ID of the order a tracking token was
+// signed for
+func VerifyTrackingToken(secret []byte, token string) (uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != len(uuid.Nil)+sha256.Size {
+		return uuid.Nil, ErrInvalidTrackingToken
+	}
+
+	orderID, err := uuid.FromBytes(raw[:len(uuid.Nil)])
+	if err != nil {
+		return uuid.Nil, ErrInvalidTrackingToken
+	}
+	if !hmac.Equal(raw[len(uuid.Nil):], trackingMAC(secret, orderID)) {
+		return uuid.Nil, ErrInvalidTrackingToken
+	}
+	return orderID, nil
+}
+
+// trackingMAC returns the HMAC-SHA256 of an order ID
+func trackingMAC(secret []byte, orderID uuid.UUID) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(orderID[:])
+	return mac.Sum(nil)
+}
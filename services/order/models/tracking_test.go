@@ -0,0 +1,93 @@
+package models_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/models"
+)
+
+func TestFulfillment_ApplyTracking(t *testing.T) {
+	pickup := time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)
+	delivery := pickup.Add(26 * time.Hour)
+	fulfillment := &models.Fulfillment{ShipmentStatus: models.ShipmentStatusPending}
+
+	events := []models.TrackingEvent{
+		{Status: models.ShipmentStatusDelivered, OccurredAt: delivery},
+		{Status: models.ShipmentStatusInTransit, OccurredAt: pickup},
+		{CarrierStatus: "HP", OccurredAt: delivery.Add(time.Hour)}, // no shipment status
+	}
+	if !fulfillment.ApplyTracking(events) {
+		t.Fatal("expected the fulfillment to change")
+	}
+	if fulfillment.ShipmentStatus != models.ShipmentStatusDelivered {
+		t.Errorf("expected the latest status to win regardless of arrival order, got %q", fulfillment.ShipmentStatus)
+	}
+	if fulfillment.ShippedAt == nil || !fulfillment.ShippedAt.Equal(pickup) {
+		t.Errorf("expected the pickup to stamp ShippedAt, got %v", fulfillment.ShippedAt)
+	}
+	if fulfillment.DeliveredAt == nil || !fulfillment.DeliveredAt.Equal(delivery) {
+		t.Errorf("expected DeliveredAt %v, got %v", delivery, fulfillment.DeliveredAt)
+	}
+	if fulfillment.ApplyTracking(events) {
+		t.Error("expected applying the same events again not to change the fulfillment")
+	}
+
+	// The carrier takes the delivery back
+	events = append(events, models.TrackingEvent{Status: models.ShipmentStatusException, OccurredAt: delivery.Add(2 * time.Hour)})
+	if !fulfillment.ApplyTracking(events) || fulfillment.ShipmentStatus != models.ShipmentStatusException || fulfillment.DeliveredAt != nil {
+		t.Errorf("expected a later exception to undo the delivery, got %+v", fulfillment)
+	}
+}
+
+func TestNewOrderTracking(t *testing.T) {
+	early := time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)
+	order := &models.Order{
+		OrderNumber: "ORD-000042",
+		Fulfillments: []models.Fulfillment{
+			{
+				TrackingNumber: "1Z999AA10123456784",
+				ShipmentStatus: models.ShipmentStatusInTransit,
+				TrackingEvents: []models.TrackingEvent{
+					{Status: models.ShipmentStatusConfirmed, Description: "Label created", OccurredAt: early},
+					{Status: models.ShipmentStatusInTransit, Description: "Picked up", OccurredAt: early.Add(time.Hour)},
+				},
+			},
+			{ShipmentStatus: models.ShipmentStatusCancelled},
+		},
+	}
+
+	tracking := models.NewOrderTracking(order)
+	if len(tracking.Shipments) != 1 {
+		t.Fatalf("expected the cancelled shipment to be left out, got %d shipments", len(tracking.Shipments))
+	}
+	timeline := tracking.Shipments[0].Timeline
+	if len(timeline) != 2 || timeline[0].Description != "Picked up" {
+		t.Errorf("expected the timeline newest first, got %+v", timeline)
+	}
+}
+
+func TestTrackingToken(t *testing.T) {
+	secret := []byte("secret")
+	orderID := uuid.New()
+
+	token := models.SignTrackingToken(secret, orderID)
+	got, err := models.VerifyTrackingToken(secret, token)
+	if err != nil || got != orderID {
+		t.Fatalf("expected the token to verify as %s, got %s, %v", orderID, got, err)
+	}
+
+	if _, err := models.VerifyTrackingToken([]byte("other"), token); !errors.Is(err, models.ErrInvalidTrackingToken) {
+		t.Errorf("expected a token signed with another secret to be rejected, got %v", err)
+	}
+	forged := models.SignTrackingToken([]byte("other"), uuid.New())
+	if _, err := models.VerifyTrackingToken(secret, forged); !errors.Is(err, models.ErrInvalidTrackingToken) {
+		t.Errorf("expected a forged token to be rejected, got %v", err)
+	}
+	if _, err := models.VerifyTrackingToken(secret, "not-a-token"); !errors.Is(err, models.ErrInvalidTrackingToken) {
+		t.Errorf("expected a malformed token to be rejected, got %v", err)
+	}
+}
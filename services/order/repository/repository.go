@@ -3,13 +3,18 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"unified-commerce/services/order/models"
 	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/sequence"
+	"unified-commerce/services/shared/tenant"
+	"unified-commerce/shared/messaging"
 )
 
 // OrderRepository handles database operations for order management
@@ -26,10 +31,42 @@ func NewOrderRepository(db *gorm.DB, logger *logger.Logger) *OrderRepository {
 	}
 }
 
+// WithTx returns a new repository with the given transaction
+func (r *OrderRepository) WithTx(tx *gorm.DB) *OrderRepository {
+	return &OrderRepository{
+		db:     tx,
+		logger: r.logger,
+	}
+}
+
+// Transaction runs fn with a repository whose operations share one
+// transaction, committed if fn returns nil
+func (r *OrderRepository) Transaction(ctx context.Context, fn func(repo *OrderRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(r.WithTx(tx))
+	})
+}
+
+// Number Operations
+
+// NextNumber allocates a merchant's next number of a sequence, such as
+// sequence.Order. Call it in the transaction that stores the numbered
+// document so numbers are not used up by documents that were not stored.
+func (r *OrderRepository) NextNumber(ctx context.Context, merchantID uuid.UUID, name string) (string, error) {
+	return sequence.NewAllocator(sequence.NewPostgresStore(r.db)).Next(ctx, merchantID, name)
+}
+
+// SaveNumberFormats replaces a merchant's number formats
+func (r *OrderRepository) SaveNumberFormats(ctx context.Context, merchantID uuid.UUID, formats map[string]sequence.Format) error {
+	return sequence.NewPostgresStore(r.db).SaveFormats(ctx, merchantID, formats)
+}
+
 // Order Operations
 
-// CreateOrder creates a new order with line items
-func (r *OrderRepository) CreateOrder(ctx context.Context, order *models.Order) error {
+// CreateOrder creates a new order with line items. Any outbox messages are
+// written in the same transaction so events are only recorded for orders
+// that were actually created.
+func (r *OrderRepository) CreateOrder(ctx context.Context, order *models.Order, outbox ...*messaging.OutboxMessage) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Create the order
 		if err := tx.Create(order).Error; err != nil {
@@ -48,14 +85,22 @@ func (r *OrderRepository) CreateOrder(ctx context.Context, order *models.Order)
 			return err
 		}
 
+		// Record integration events
+		if err := messaging.EnqueueOutbox(tx, outbox...); err != nil {
+			r.logger.WithError(err).Error("Failed to enqueue order outbox messages")
+			return err
+		}
+
 		return nil
 	})
 }
 
-// GetOrder retrieves an order by ID with all related data
+// GetOrder retrieves an order of the context's merchant by ID with all
+// related data
 func (r *OrderRepository) GetOrder(ctx context.Context, id uuid.UUID) (*models.Order, error) {
 	var order models.Order
 	if err := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
 		Preload("LineItems").
 		Preload("Fulfillments").
 		Preload("Fulfillments.LineItems").
@@ -72,10 +117,11 @@ func (r *OrderRepository) GetOrder(ctx context.Context, id uuid.UUID) (*models.O
 	return &order, nil
 }
 
-// GetOrderByNumber retrieves an order by order number
+// GetOrderByNumber retrieves an order of the context's merchant by order number
 func (r *OrderRepository) GetOrderByNumber(ctx context.Context, orderNumber string) (*models.Order, error) {
 	var order models.Order
 	if err := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
 		Preload("LineItems").
 		Preload("Fulfillments").
 		Preload("Transactions").
@@ -90,35 +136,23 @@ func (r *OrderRepository) GetOrderByNumber(ctx context.Context, orderNumber stri
 	return &order, nil
 }
 
-// GetOrdersByMerchant retrieves orders for a merchant with pagination
-func (r *OrderRepository) GetOrdersByMerchant(ctx context.Context, merchantID uuid.UUID, filters map[string]interface{}, limit, offset int) ([]*models.Order, int64, error) {
-	var orders []*models.Order
-	var total int64
-
-	query := r.db.WithContext(ctx).Model(&models.Order{}).Where("merchant_id = ?", merchantID)
-
-	// Apply filters
-	query = r.applyOrderFilters(query, filters)
-
-	// Count total records
-	if err := query.Count(&total).Error; err != nil {
-		r.logger.WithError(err).Error("Failed to count orders")
-		return nil, 0, err
-	}
-
-	// Get paginated results
-	if err := r.db.WithContext(ctx).
+// SearchOrders retrieves up to limit of a merchant's orders matching the
+// query, newest first, starting after the cursor when one is given
+func (r *OrderRepository) SearchOrders(ctx context.Context, merchantID uuid.UUID, query *models.OrderQuery, after *models.OrderCursor, limit int) ([]*models.Order, error) {
+	db := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx), orderQueryScope(query)).
 		Preload("LineItems").
-		Where("merchant_id = ?", merchantID).
-		Scopes(r.applyOrderFiltersScope(filters)).
-		Order("created_at DESC").
-		Limit(limit).Offset(offset).
-		Find(&orders).Error; err != nil {
-		r.logger.WithError(err).Error("Failed to get orders")
-		return nil, 0, err
+		Where("merchant_id = ?", merchantID)
+	if after != nil {
+		db = db.Where("(created_at, id) < (?, ?)", after.CreatedAt, after.ID)
 	}
 
-	return orders, total, nil
+	var orders []*models.Order
+	if err := db.Order("created_at DESC").Order("id DESC").Limit(limit).Find(&orders).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to search orders")
+		return nil, err
+	}
+	return orders, nil
 }
 
 // GetOrdersByCustomer retrieves orders for a customer
@@ -127,7 +161,7 @@ func (r *OrderRepository) GetOrdersByCustomer(ctx context.Context, customerID uu
 	var total int64
 
 	// Count total records
-	if err := r.db.WithContext(ctx).Model(&models.Order{}).
+	if err := r.db.WithContext(ctx).Model(&models.Order{}).Scopes(tenant.Scope(ctx)).
 		Where("customer_id = ?", customerID).Count(&total).Error; err != nil {
 		r.logger.WithError(err).Error("Failed to count customer orders")
 		return nil, 0, err
@@ -135,6 +169,7 @@ func (r *OrderRepository) GetOrdersByCustomer(ctx context.Context, customerID uu
 
 	// Get paginated results
 	if err := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
 		Preload("LineItems").
 		Where("customer_id = ?", customerID).
 		Order("created_at DESC").
@@ -147,127 +182,204 @@ func (r *OrderRepository) GetOrdersByCustomer(ctx context.Context, customerID uu
 	return orders, total, nil
 }
 
-// UpdateOrder updates an order
+// UpdateOrder updates an order. Statuses only change through transitions
+// and holds through risk reviews, so neither is written.
 func (r *OrderRepository) UpdateOrder(ctx context.Context, order *models.Order) error {
-	if err := r.db.WithContext(ctx).Save(order).Error; err != nil {
+	if err := r.db.WithContext(ctx).Omit(append(orderStatusColumns, orderRiskColumns...)...).Save(order).Error; err != nil {
 		r.logger.WithError(err).Error("Failed to update order")
 		return err
 	}
 	return nil
 }
 
-// UpdateOrderStatus updates order status and creates an event
+// UpdateOrderStatus moves an order to the status through the order state
+// machine and records the transition's event. Illegal transitions return a
+// *models.TransitionError.
 func (r *OrderRepository) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, status models.OrderStatus, description string, userID *uuid.UUID) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// Update order status
-		if err := tx.Model(&models.Order{}).
-			Where("id = ?", orderID).
-			Update("status", status).Error; err != nil {
-			return err
-		}
-
-		// Create order event
-		event := &models.OrderEvent{
-			OrderID:     orderID,
-			EventType:   r.getEventTypeFromStatus(status),
-			Description: description,
-			UserID:      userID,
-		}
-		if err := tx.Create(event).Error; err != nil {
+		order, err := r.lockOrder(tx.Scopes(tenant.Scope(ctx)), orderID)
+		if err != nil {
 			return err
 		}
 
-		return nil
+		return applyTransition(tx, models.OrderStateMachine, order, status, description, userID)
 	})
 }
 
-// CancelOrder cancels an order and restocks inventory
+// CancelOrder cancels an order through the order state machine
 func (r *OrderRepository) CancelOrder(ctx context.Context, orderID uuid.UUID, reason string, userID *uuid.UUID) error {
-	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		now := time.Now()
+	return r.UpdateOrderStatus(ctx, orderID, models.OrderStatusCancelled, fmt.Sprintf("Order cancelled: %s", reason), userID)
+}
 
-		// Update order
-		if err := tx.Model(&models.Order{}).
-			Where("id = ?", orderID).
-			Updates(map[string]interface{}{
-				"status":       models.OrderStatusCancelled,
-				"cancelled_at": &now,
-			}).Error; err != nil {
-			return err
-		}
+// Line Item Operations
 
-		// Create cancellation event
-		event := &models.OrderEvent{
-			OrderID:     orderID,
-			EventType:   models.OrderEventCancelled,
-			Description: fmt.Sprintf("Order cancelled: %s", reason),
-			UserID:      userID,
-		}
-		if err := tx.Create(event).Error; err != nil {
-			return err
+// GetLineItem retrieves a line item of one of the context's merchant's orders
+func (r *OrderRepository) GetLineItem(ctx context.Context, id uuid.UUID) (*models.OrderLineItem, error) {
+	var lineItem models.OrderLineItem
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeThrough(ctx, "order_id", "orders")).
+		First(&lineItem, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
 		}
+		r.logger.WithError(err).Error("Failed to get line item")
+		return nil, err
+	}
+	return &lineItem, nil
+}
 
-		return nil
-	})
+// Order Edit Operations
+
+// OrderEditCommit is an order edit applied to its order, ready to be saved
+type OrderEditCommit struct {
+	Order  *models.Order // the order with its edited line items and totals
+	Event  *models.OrderEvent
+	Outbox []*messaging.OutboxMessage
 }
 
-// Line Item Operations
+// CreateOrderEdit creates a new order edit
+func (r *OrderRepository) CreateOrderEdit(ctx context.Context, edit *models.OrderEdit) error {
+	if err := r.db.WithContext(ctx).Create(edit).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to create order edit")
+		return err
+	}
+	return nil
+}
 
-// AddLineItem adds a new line item to an order
-func (r *OrderRepository) AddLineItem(ctx context.Context, lineItem *models.OrderLineItem) error {
-	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// Create line item
-		if err := tx.Create(lineItem).Error; err != nil {
-			return err
+// GetOrderEdit retrieves an order edit of one of the context's merchant's
+// orders
+func (r *OrderRepository) GetOrderEdit(ctx context.Context, id uuid.UUID) (*models.OrderEdit, error) {
+	var edit models.OrderEdit
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeThrough(ctx, "order_id", "orders")).
+		First(&edit, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
 		}
+		r.logger.WithError(err).Error("Failed to get order edit")
+		return nil, err
+	}
+	return &edit, nil
+}
 
-		// Update order totals
-		if err := r.recalculateOrderTotals(tx, lineItem.OrderID); err != nil {
+// UpdateOrderEdit locks an open order edit and its order, lets update change
+// the edit and saves it. Edits that are no longer open return
+// models.ErrOrderEditClosed.
+func (r *OrderRepository) UpdateOrderEdit(ctx context.Context, id uuid.UUID, update func(edit *models.OrderEdit, order *models.Order) error) (*models.OrderEdit, error) {
+	var edit *models.OrderEdit
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var order *models.Order
+		var err error
+		edit, order, err = r.lockOrderEdit(ctx, tx, id)
+		if err != nil {
 			return err
 		}
 
-		return nil
+		if err := update(edit, order); err != nil {
+			return err
+		}
+		return tx.Save(edit).Error
 	})
+	if err != nil {
+		return nil, err
+	}
+	return edit, nil
 }
 
-// UpdateLineItem updates a line item
-func (r *OrderRepository) UpdateLineItem(ctx context.Context, lineItem *models.OrderLineItem) error {
-	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// Update line item
-		if err := tx.Save(lineItem).Error; err != nil {
+// CommitOrderEdit applies an open order edit in one transaction. The edit
+// and its order are locked and passed to build, and the order it returns is
+// saved: new line items are created, changed ones updated and missing ones
+// deleted, along with the order's totals, the edit, the audit event and the
+// outbox messages. The order's fulfillment and payment statuses are derived
+// again from its new line items and total.
+func (r *OrderRepository) CommitOrderEdit(ctx context.Context, id uuid.UUID, build func(edit *models.OrderEdit, order *models.Order) (*OrderEditCommit, error)) (*models.OrderEdit, error) {
+	var edit *models.OrderEdit
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var order *models.Order
+		var err error
+		edit, order, err = r.lockOrderEdit(ctx, tx, id)
+		if err != nil {
 			return err
 		}
 
-		// Update order totals
-		if err := r.recalculateOrderTotals(tx, lineItem.OrderID); err != nil {
+		original := make(map[uuid.UUID]models.OrderLineItem, len(order.LineItems))
+		for _, lineItem := range order.LineItems {
+			original[lineItem.ID] = lineItem
+		}
+
+		commit, err := build(edit, order)
+		if err != nil {
 			return err
 		}
 
-		return nil
-	})
-}
+		// Save the line item changes
+		for i := range commit.Order.LineItems {
+			lineItem := &commit.Order.LineItems[i]
+			old, ok := original[lineItem.ID]
+			delete(original, lineItem.ID)
+			switch {
+			case !ok:
+				if err := tx.Create(lineItem).Error; err != nil {
+					return err
+				}
+			case old.Quantity != lineItem.Quantity || old.Price != lineItem.Price:
+				if err := tx.Model(lineItem).
+					Select("quantity", "price", "line_price", "tax_lines", "fulfillment_status", "updated_at").
+					Updates(lineItem).Error; err != nil {
+					return err
+				}
+			}
+		}
+		for lineItemID := range original {
+			if err := tx.Delete(&models.OrderLineItem{}, "id = ?", lineItemID).Error; err != nil {
+				return err
+			}
+		}
 
-// RemoveLineItem removes a line item from an order
-func (r *OrderRepository) RemoveLineItem(ctx context.Context, lineItemID uuid.UUID) error {
-	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// Get line item to get order ID
-		var lineItem models.OrderLineItem
-		if err := tx.First(&lineItem, "id = ?", lineItemID).Error; err != nil {
+		// Save the totals, then the committed edit and its records
+		if err := tx.Model(commit.Order).
+			Select("subtotal_price", "total_tax", "total_price", "updated_at").
+			Updates(commit.Order).Error; err != nil {
 			return err
 		}
-
-		// Delete line item
-		if err := tx.Delete(&lineItem).Error; err != nil {
+		if err := r.updateOrderFulfillmentStatus(tx, order.ID); err != nil {
 			return err
 		}
-
-		// Update order totals
-		if err := r.recalculateOrderTotals(tx, lineItem.OrderID); err != nil {
+		if err := r.updateOrderPaymentStatus(tx, order.ID); err != nil {
 			return err
 		}
-
-		return nil
+		if err := tx.Save(edit).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(commit.Event).Error; err != nil {
+			return err
+		}
+		return messaging.EnqueueOutbox(tx, commit.Outbox...)
 	})
+	if err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// lockOrderEdit loads an open order edit of the context's merchant and its
+// order, locking both for the rest of the transaction
+func (r *OrderRepository) lockOrderEdit(ctx context.Context, tx *gorm.DB, id uuid.UUID) (*models.OrderEdit, *models.Order, error) {
+	var edit models.OrderEdit
+	if err := tx.Scopes(tenant.ScopeThrough(ctx, "order_id", "orders")).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&edit, "id = ?", id).Error; err != nil {
+		return nil, nil, err
+	}
+	if edit.Status != models.OrderEditStatusOpen {
+		return nil, nil, models.ErrOrderEditClosed
+	}
+
+	order, err := r.lockOrder(tx, edit.OrderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &edit, order, nil
 }
 
 // Fulfillment Operations
@@ -282,12 +394,7 @@ func (r *OrderRepository) CreateFulfillment(ctx context.Context, fulfillment *mo
 
 		// Update line item fulfillment status
 		for _, fulfillmentLineItem := range fulfillment.LineItems {
-			if err := tx.Model(&models.OrderLineItem{}).
-				Where("id = ?", fulfillmentLineItem.LineItemID).
-				Updates(map[string]interface{}{
-					"fulfilled_quantity": gorm.Expr("fulfilled_quantity + ?", fulfillmentLineItem.Quantity),
-					"fulfillment_status": r.calculateLineItemFulfillmentStatus(tx, fulfillmentLineItem.LineItemID),
-				}).Error; err != nil {
+			if err := r.fulfillLineItem(tx, fulfillment.OrderID, fulfillmentLineItem); err != nil {
 				return err
 			}
 		}
@@ -311,6 +418,39 @@ func (r *OrderRepository) CreateFulfillment(ctx context.Context, fulfillment *mo
 	})
 }
 
+// CreateRoutedFulfillments creates the fulfillments an order was routed to.
+// Their line items are only counted as fulfilled once they ship. Orders
+// cancelled before they were routed get no fulfillments.
+func (r *OrderRepository) CreateRoutedFulfillments(ctx context.Context, orderID uuid.UUID, fulfillments []*models.Fulfillment, description string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		order, err := r.lockOrder(tx, orderID)
+		if err != nil {
+			return err
+		}
+		if order.Status == models.OrderStatusCancelled {
+			return nil
+		}
+
+		for _, fulfillment := range fulfillments {
+			for _, fulfillmentLineItem := range fulfillment.LineItems {
+				if !hasLineItem(order, fulfillmentLineItem.LineItemID) {
+					return models.ErrLineItemNotInOrder
+				}
+			}
+			if err := tx.Create(fulfillment).Error; err != nil {
+				return err
+			}
+		}
+
+		event := &models.OrderEvent{
+			OrderID:     orderID,
+			EventType:   models.OrderEventRouted,
+			Description: description,
+		}
+		return tx.Create(event).Error
+	})
+}
+
 // UpdateFulfillment updates a fulfillment
 func (r *OrderRepository) UpdateFulfillment(ctx context.Context, fulfillment *models.Fulfillment) error {
 	if err := r.db.WithContext(ctx).Save(fulfillment).Error; err != nil {
@@ -320,29 +460,52 @@ func (r *OrderRepository) UpdateFulfillment(ctx context.Context, fulfillment *mo
 	return nil
 }
 
-// MarkFulfillmentShipped marks a fulfillment as shipped
-func (r *OrderRepository) MarkFulfillmentShipped(ctx context.Context, fulfillmentID uuid.UUID, trackingNumber, trackingURL string) error {
+// MarkFulfillmentShipped marks a fulfillment as shipped. The carrier is
+// kept when trackingCompany is empty.
+func (r *OrderRepository) MarkFulfillmentShipped(ctx context.Context, fulfillmentID uuid.UUID, trackingCompany, trackingNumber, trackingURL string) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		now := time.Now()
 
 		// Update fulfillment
+		updates := map[string]interface{}{
+			"shipment_status": models.ShipmentStatusInTransit,
+			"tracking_number": trackingNumber,
+			"tracking_url":    trackingURL,
+			"shipped_at":      &now,
+		}
+		if trackingCompany != "" {
+			updates["tracking_company"] = trackingCompany
+		}
 		if err := tx.Model(&models.Fulfillment{}).
+			Scopes(tenant.ScopeThrough(ctx, "order_id", "orders")).
 			Where("id = ?", fulfillmentID).
-			Updates(map[string]interface{}{
-				"shipment_status": models.ShipmentStatusInTransit,
-				"tracking_number": trackingNumber,
-				"tracking_url":    trackingURL,
-				"shipped_at":      &now,
-			}).Error; err != nil {
+			Updates(updates).Error; err != nil {
 			return err
 		}
 
 		// Get fulfillment to get order ID
 		var fulfillment models.Fulfillment
-		if err := tx.First(&fulfillment, "id = ?", fulfillmentID).Error; err != nil {
+		if err := tx.Scopes(tenant.ScopeThrough(ctx, "order_id", "orders")).
+			Preload("LineItems").
+			First(&fulfillment, "id = ?", fulfillmentID).Error; err != nil {
 			return err
 		}
 
+		// Routed fulfillments are fulfilled when they ship
+		if fulfillment.Status == models.FulfillmentStatusUnfulfilled {
+			for _, fulfillmentLineItem := range fulfillment.LineItems {
+				if err := r.fulfillLineItem(tx, fulfillment.OrderID, fulfillmentLineItem); err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&fulfillment).Update("status", models.FulfillmentStatusFulfilled).Error; err != nil {
+				return err
+			}
+			if err := r.updateOrderFulfillmentStatus(tx, fulfillment.OrderID); err != nil {
+				return err
+			}
+		}
+
 		// Update order if all fulfillments are shipped
 		if err := r.updateOrderShippingStatus(tx, fulfillment.OrderID); err != nil {
 			return err
@@ -362,40 +525,362 @@ func (r *OrderRepository) MarkFulfillmentShipped(ctx context.Context, fulfillmen
 	})
 }
 
-// Transaction Operations
+// Shipment Tracking Operations
+
+// RecordTrackingEvents records the statuses a carrier reported on the
+// fulfillments it ships with those tracking numbers, updates their shipment
+// status and moves their orders to shipped or delivered. Events already
+// recorded are skipped, so redelivered webhooks change nothing. It returns
+// how many events matched a fulfillment.
+func (r *OrderRepository) RecordTrackingEvents(ctx context.Context, carrier string, events []*models.TrackingEvent) (int, error) {
+	byNumber := make(map[string][]*models.TrackingEvent)
+	var numbers []string
+	for _, event := range events {
+		if _, ok := byNumber[event.TrackingNumber]; !ok {
+			numbers = append(numbers, event.TrackingNumber)
+		}
+		byNumber[event.TrackingNumber] = append(byNumber[event.TrackingNumber], event)
+	}
 
-// CreateTransaction creates a new payment transaction
-func (r *OrderRepository) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	matched := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, number := range numbers {
+			var fulfillments []models.Fulfillment
+			if err := tx.Scopes(tenant.ScopeThrough(ctx, "order_id", "orders")).
+				Where("tracking_number = ? AND LOWER(tracking_company) = ?", number, carrier).
+				Order("id").
+				Find(&fulfillments).Error; err != nil {
+				return err
+			}
+
+			for _, fulfillment := range fulfillments {
+				if err := r.recordTrackingEvents(tx, fulfillment.OrderID, fulfillment.ID, byNumber[number]); err != nil {
+					return err
+				}
+				matched += len(byNumber[number])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to record tracking events")
+		return 0, err
+	}
+	return matched, nil
+}
+
+// recordTrackingEvents records tracking events of a fulfillment and applies
+// them to the fulfillment and its order
+func (r *OrderRepository) recordTrackingEvents(tx *gorm.DB, orderID, fulfillmentID uuid.UUID, events []*models.TrackingEvent) error {
+	if _, err := r.lockOrder(tx, orderID); err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		recorded := *event
+		recorded.ID = uuid.New()
+		recorded.FulfillmentID = fulfillmentID
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&recorded).Error; err != nil {
+			return err
+		}
+	}
+
+	var fulfillment models.Fulfillment
+	if err := tx.Preload("TrackingEvents", func(db *gorm.DB) *gorm.DB {
+		return db.Order("occurred_at").Order("created_at")
+	}).First(&fulfillment, "id = ?", fulfillmentID).Error; err != nil {
+		return err
+	}
+	if !fulfillment.ApplyTracking(fulfillment.TrackingEvents) {
+		return nil
+	}
+
+	if err := tx.Model(&fulfillment).Select("shipment_status", "shipped_at", "delivered_at", "updated_at").Updates(&fulfillment).Error; err != nil {
+		return err
+	}
+	event := &models.OrderEvent{
+		OrderID:     orderID,
+		EventType:   models.OrderEventShipmentUpdated,
+		Description: fmt.Sprintf("Shipment %s is %s", fulfillment.TrackingNumber, fulfillment.ShipmentStatus),
+	}
+	if err := tx.Create(event).Error; err != nil {
+		return err
+	}
+
+	return r.updateOrderShippingStatus(tx, orderID)
+}
+
+// GetTrackedOrder retrieves an order with its fulfillments and their
+// tracking events
+func (r *OrderRepository) GetTrackedOrder(ctx context.Context, id uuid.UUID) (*models.Order, error) {
+	return r.getTrackedOrder(ctx, r.db.WithContext(ctx).Where("id = ?", id))
+}
+
+// GetTrackedOrderByEmail retrieves the order with the order number placed
+// with the email, matched case-insensitively, with its fulfillments and
+// their tracking events. Order numbers are only unique per merchant, so the
+// newest match is returned if the customer ordered from several merchants.
+func (r *OrderRepository) GetTrackedOrderByEmail(ctx context.Context, orderNumber, email string) (*models.Order, error) {
+	return r.getTrackedOrder(ctx, r.db.WithContext(ctx).
+		Where("order_number = ? AND LOWER(customer_email) = LOWER(?)", orderNumber, email).
+		Order("created_at DESC"))
+}
+
+// GetDisputedOrder retrieves an order with its line items, fulfillments and
+// their tracking events, the evidence of a dispute of its payment
+func (r *OrderRepository) GetDisputedOrder(ctx context.Context, id uuid.UUID) (*models.Order, error) {
+	return r.getTrackedOrder(ctx, r.db.WithContext(ctx).Preload("LineItems").Where("id = ?", id))
+}
+
+// getTrackedOrder retrieves the first order of the query with its
+// fulfillments and their tracking events
+func (r *OrderRepository) getTrackedOrder(ctx context.Context, db *gorm.DB) (*models.Order, error) {
+	var order models.Order
+	if err := db.Scopes(tenant.Scope(ctx)).
+		Preload("Fulfillments", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }).
+		Preload("Fulfillments.TrackingEvents").
+		First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get tracked order")
+		return nil, err
+	}
+	return &order, nil
+}
+
+// Risk Operations
+
+// RiskChange is what a risk review or rescore writes besides the assessment
+// and the order's hold
+type RiskChange struct {
+	// Cancel cancels the order through the order state machine, recording
+	// the cancellation with Description and UserID
+	Cancel      bool
+	Description string
+	UserID      *uuid.UUID
+	Event       *models.OrderEvent
+}
+
+// GetRiskHistory counts what the order's merchant has seen of the order's
+// customer, email and browser IP address for scoring the order
+func (r *OrderRepository) GetRiskHistory(ctx context.Context, order *models.Order, query models.RiskHistoryQuery) (*models.RiskHistory, error) {
+	history := &models.RiskHistory{}
+	otherOrders := func() *gorm.DB {
+		return r.db.WithContext(ctx).Model(&models.Order{}).
+			Where("merchant_id = ? AND id <> ?", order.MerchantID, order.ID)
+	}
+	email := strings.TrimSpace(order.Customer.Email)
+
+	customer, customerArgs := customerCondition(order.CustomerID, email)
+	if customer != "" {
+		if err := otherOrders().Where(customer, customerArgs...).Count(&history.PreviousOrders).Error; err != nil {
+			r.logger.WithError(err).Error("Failed to count previous orders")
+			return nil, err
+		}
+	}
+	if order.CustomerID != nil {
+		if err := otherOrders().Where("customer_id = ? AND created_at >= ?", *order.CustomerID, query.VelocitySince).
+			Count(&history.RecentCustomerOrders).Error; err != nil {
+			r.logger.WithError(err).Error("Failed to count recent customer orders")
+			return nil, err
+		}
+	}
+	if email != "" {
+		if err := otherOrders().Where("LOWER(customer_email) = LOWER(?) AND created_at >= ?", email, query.VelocitySince).
+			Count(&history.RecentEmailOrders).Error; err != nil {
+			r.logger.WithError(err).Error("Failed to count recent email orders")
+			return nil, err
+		}
+	}
+	if order.BrowserIP != "" {
+		if err := otherOrders().Where("browser_ip = ? AND created_at >= ?", order.BrowserIP, query.VelocitySince).
+			Count(&history.RecentIPOrders).Error; err != nil {
+			r.logger.WithError(err).Error("Failed to count recent IP orders")
+			return nil, err
+		}
+	}
+
+	failed := r.db.WithContext(ctx).Model(&models.Transaction{}).
+		Joins("JOIN orders ON orders.id = transactions.order_id").
+		Where("orders.merchant_id = ?", order.MerchantID).
+		Where("transactions.status = ? AND transactions.created_at >= ?", models.TransactionStatusFailure, query.FailedPaymentsSince)
+	if customer != "" {
+		failed = failed.Where("(orders.id = ? OR "+customer+")", append([]interface{}{order.ID}, customerArgs...)...)
+	} else {
+		failed = failed.Where("orders.id = ?", order.ID)
+	}
+	if err := failed.Count(&history.FailedPayments).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to count failed payments")
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// customerCondition matches orders of a customer or placed with an email,
+// or is blank when there is neither
+func customerCondition(customerID *uuid.UUID, email string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if customerID != nil {
+		conditions = append(conditions, "orders.customer_id = ?")
+		args = append(args, *customerID)
+	}
+	if email != "" {
+		conditions = append(conditions, "LOWER(orders.customer_email) = LOWER(?)")
+		args = append(args, email)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// CreateRiskAssessment stores an order's risk assessment with the event
+// recording a hold, if any
+func (r *OrderRepository) CreateRiskAssessment(ctx context.Context, assessment *models.RiskAssessment, event *models.OrderEvent) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// Create transaction
-		if err := tx.Create(transaction).Error; err != nil {
+		if err := tx.Omit(clause.Associations).Create(assessment).Error; err != nil {
+			r.logger.WithError(err).Error("Failed to create risk assessment")
+			return err
+		}
+		if event == nil {
+			return nil
+		}
+		return tx.Create(event).Error
+	})
+}
+
+// GetRiskAssessment retrieves the risk assessment of one of the context's
+// merchant's orders
+func (r *OrderRepository) GetRiskAssessment(ctx context.Context, orderID uuid.UUID) (*models.RiskAssessment, error) {
+	var assessment models.RiskAssessment
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeThrough(ctx, "order_id", "orders")).
+		First(&assessment, "order_id = ?", orderID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get risk assessment")
+		return nil, err
+	}
+	return &assessment, nil
+}
+
+// GetRiskReviews retrieves a merchant's risk assessments in a review
+// status with their orders, oldest first
+func (r *OrderRepository) GetRiskReviews(ctx context.Context, merchantID uuid.UUID, status models.RiskReviewStatus, limit, offset int) ([]*models.RiskAssessment, int64, error) {
+	var assessments []*models.RiskAssessment
+	var total int64
+
+	db := r.db.WithContext(ctx).Model(&models.RiskAssessment{}).
+		Scopes(tenant.Scope(ctx)).
+		Where("merchant_id = ? AND review_status = ?", merchantID, status)
+	if err := db.Count(&total).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to count risk reviews")
+		return nil, 0, err
+	}
+
+	if err := db.Preload("Order").
+		Order("created_at ASC").
+		Limit(limit).Offset(offset).
+		Find(&assessments).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to get risk reviews")
+		return nil, 0, err
+	}
+
+	return assessments, total, nil
+}
+
+// UpdateRiskAssessment locks an order of the context's merchant and its
+// risk assessment, lets update change them and saves the assessment, the
+// order's hold and the change's records. Orders without an assessment
+// return gorm.ErrRecordNotFound.
+func (r *OrderRepository) UpdateRiskAssessment(ctx context.Context, orderID uuid.UUID, update func(assessment *models.RiskAssessment, order *models.Order) (*RiskChange, error)) (*models.RiskAssessment, error) {
+	var assessment models.RiskAssessment
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		order, err := r.lockOrder(tx.Scopes(tenant.Scope(ctx)), orderID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&assessment, "order_id = ?", order.ID).Error; err != nil {
 			return err
 		}
 
-		// Update order payment status
-		if err := r.updateOrderPaymentStatus(tx, transaction.OrderID); err != nil {
+		change, err := update(&assessment, order)
+		if err != nil {
 			return err
 		}
 
-		// Create payment event
-		event := &models.OrderEvent{
-			OrderID:     transaction.OrderID,
-			EventType:   models.OrderEventPaymentAuthorized,
-			Description: fmt.Sprintf("Payment transaction created: %s", transaction.ID.String()),
+		if err := tx.Omit(clause.Associations).Save(&assessment).Error; err != nil {
+			return err
 		}
-		if err := tx.Create(event).Error; err != nil {
+		if err := tx.Model(order).Select(append(orderRiskColumns, "updated_at")).Updates(order).Error; err != nil {
 			return err
 		}
+		if change == nil {
+			return nil
+		}
 
+		if change.Cancel {
+			if err := applyTransition(tx, models.OrderStateMachine, order, models.OrderStatusCancelled, change.Description, change.UserID); err != nil {
+				return err
+			}
+		}
+		if change.Event != nil {
+			return tx.Create(change.Event).Error
+		}
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return &assessment, nil
+}
+
+// Transaction Operations
+
+// CreateTransaction creates a new payment transaction
+func (r *OrderRepository) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Create transaction
+		if err := tx.Create(transaction).Error; err != nil {
+			return err
+		}
+
+		// Update order payment status, which records the payment event
+		return r.updateOrderPaymentStatus(tx, transaction.OrderID)
+	})
 }
 
 // Return Operations
 
-// CreateReturn creates a new return
+// ReturnChange is the record of a return workflow step, saved with the
+// return
+type ReturnChange struct {
+	Event  *models.OrderEvent
+	Outbox []*messaging.OutboxMessage
+}
+
+// CreateReturn creates a new return. The order is locked so that its items
+// cannot be claimed by two returns at once; items that were not fulfilled or
+// are already being returned return models.ErrReturnQuantityExceeded.
 func (r *OrderRepository) CreateReturn(ctx context.Context, returnItem *models.Return) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		order, err := r.lockOrder(tx, returnItem.OrderID)
+		if err != nil {
+			return err
+		}
+		returns, err := r.findReturns(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		if err := returnItem.CheckReturnable(order, returns); err != nil {
+			return err
+		}
+
 		// Create return
 		if err := tx.Create(returnItem).Error; err != nil {
 			return err
@@ -415,43 +900,313 @@ func (r *OrderRepository) CreateReturn(ctx context.Context, returnItem *models.R
 	})
 }
 
-// ProcessReturn processes a return and restocks inventory if needed
-func (r *OrderRepository) ProcessReturn(ctx context.Context, returnID uuid.UUID, refundAmount float64, restockItems bool) error {
-	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		now := time.Now()
+// GetReturn retrieves a return of one of the context's merchant's orders
+// with its line items
+func (r *OrderRepository) GetReturn(ctx context.Context, id uuid.UUID) (*models.Return, error) {
+	var returnItem models.Return
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeThrough(ctx, "order_id", "orders")).
+		Preload("LineItems").
+		First(&returnItem, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get return")
+		return nil, err
+	}
+	return &returnItem, nil
+}
+
+// GetReturnsByOrder retrieves the returns of one of the context's merchant's
+// orders, oldest first
+func (r *OrderRepository) GetReturnsByOrder(ctx context.Context, orderID uuid.UUID) ([]*models.Return, error) {
+	var returns []*models.Return
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeThrough(ctx, "order_id", "orders")).
+		Preload("LineItems").
+		Where("order_id = ?", orderID).
+		Order("requested_at ASC").
+		Find(&returns).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to get returns by order")
+		return nil, err
+	}
+	return returns, nil
+}
 
-		// Update return
-		if err := tx.Model(&models.Return{}).
-			Where("id = ?", returnID).
-			Updates(map[string]interface{}{
-				"status":        models.ReturnStatusProcessed,
-				"refund_amount": refundAmount,
-				"restock_items": restockItems,
-				"processed_at":  &now,
-			}).Error; err != nil {
+// UpdateReturn locks a return and its order, lets update change the return
+// and saves it with its line items and the change's records. The order's
+// fulfillments are loaded with their line items. Refunded returns may
+// complete the order's return.
+func (r *OrderRepository) UpdateReturn(ctx context.Context, id uuid.UUID, update func(returnItem *models.Return, order *models.Order) (*ReturnChange, error)) (*models.Return, error) {
+	var returnItem *models.Return
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var order *models.Order
+		var err error
+		returnItem, order, err = r.lockReturn(ctx, tx, id)
+		if err != nil {
 			return err
 		}
 
-		// Get return to get order ID
-		var returnItem models.Return
-		if err := tx.Preload("LineItems").First(&returnItem, "id = ?", returnID).Error; err != nil {
+		change, err := update(returnItem, order)
+		if err != nil {
 			return err
 		}
 
-		// Update order status if all items are returned
-		if err := r.updateOrderReturnStatus(tx, returnItem.OrderID); err != nil {
+		if err := tx.Omit(clause.Associations).Save(returnItem).Error; err != nil {
 			return err
 		}
+		for i := range returnItem.LineItems {
+			if err := tx.Omit(clause.Associations).Save(&returnItem.LineItems[i]).Error; err != nil {
+				return err
+			}
+		}
+		if change != nil {
+			if change.Event != nil {
+				if err := tx.Create(change.Event).Error; err != nil {
+					return err
+				}
+			}
+			if err := messaging.EnqueueOutbox(tx, change.Outbox...); err != nil {
+				return err
+			}
+		}
 
+		if returnItem.Status == models.ReturnStatusRefunded {
+			return r.updateOrderReturnStatus(tx, order.ID, returnItem.RestockItems)
+		}
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return returnItem, nil
+}
+
+// lockReturn loads a return of the context's merchant with its line items
+// and its order, locking both for the rest of the transaction. The order is
+// locked first, as when returns are created.
+func (r *OrderRepository) lockReturn(ctx context.Context, tx *gorm.DB, id uuid.UUID) (*models.Return, *models.Order, error) {
+	var unlocked models.Return
+	if err := tx.Scopes(tenant.ScopeThrough(ctx, "order_id", "orders")).
+		Select("order_id").
+		First(&unlocked, "id = ?", id).Error; err != nil {
+		return nil, nil, err
+	}
+
+	order, err := r.lockOrder(tx, unlocked.OrderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Preload("LineItems").Find(&order.Fulfillments, "order_id = ?", order.ID).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var returnItem models.Return
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("LineItems").
+		First(&returnItem, "id = ?", id).Error; err != nil {
+		return nil, nil, err
+	}
+	return &returnItem, order, nil
+}
+
+// findReturns loads the returns of an order with their line items
+func (r *OrderRepository) findReturns(tx *gorm.DB, orderID uuid.UUID) ([]models.Return, error) {
+	var returns []models.Return
+	if err := tx.Preload("LineItems").Where("order_id = ?", orderID).Find(&returns).Error; err != nil {
+		return nil, err
+	}
+	return returns, nil
+}
+
+// Draft Order Operations
+
+// DraftOrderChange is what a draft order change writes besides the draft.
+// Completing a draft creates its order together with the transaction that
+// paid for it.
+type DraftOrderChange struct {
+	Order       *models.Order
+	Transaction *models.Transaction
+	Outbox      []*messaging.OutboxMessage
+}
+
+// CreateDraftOrder creates a draft order with its line items and outbox
+// messages
+func (r *OrderRepository) CreateDraftOrder(ctx context.Context, draft *models.DraftOrder, outbox ...*messaging.OutboxMessage) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(draft).Error; err != nil {
+			r.logger.WithError(err).Error("Failed to create draft order")
+			return err
+		}
+		return messaging.EnqueueOutbox(tx, outbox...)
+	})
+}
+
+// GetDraftOrder retrieves a draft order of the context's merchant with its
+// line items
+func (r *OrderRepository) GetDraftOrder(ctx context.Context, id uuid.UUID) (*models.DraftOrder, error) {
+	var draft models.DraftOrder
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
+		Preload("LineItems", orderByCreation).
+		First(&draft, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get draft order")
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// GetDraftOrderByInvoiceToken retrieves the draft order whose invoice token
+// has the hash. The token is all a paying customer has, so the lookup is not
+// scoped to a merchant.
+func (r *OrderRepository) GetDraftOrderByInvoiceToken(ctx context.Context, tokenHash string) (*models.DraftOrder, error) {
+	var draft models.DraftOrder
+	if err := r.db.WithContext(ctx).
+		Preload("LineItems", orderByCreation).
+		First(&draft, "invoice_token_hash = ?", tokenHash).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get draft order by invoice token")
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// GetDraftOrderByOrderID retrieves the draft order that becomes the order
+func (r *OrderRepository) GetDraftOrderByOrderID(ctx context.Context, orderID uuid.UUID) (*models.DraftOrder, error) {
+	var draft models.DraftOrder
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
+		First(&draft, "order_id = ?", orderID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get draft order by order ID")
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// GetDraftOrdersByMerchant retrieves a merchant's draft orders, optionally
+// of one status, newest first
+func (r *OrderRepository) GetDraftOrdersByMerchant(ctx context.Context, merchantID uuid.UUID, status models.DraftOrderStatus, limit, offset int) ([]*models.DraftOrder, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.DraftOrder{}).
+		Scopes(tenant.Scope(ctx)).
+		Where("merchant_id = ?", merchantID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to count draft orders")
+		return nil, 0, err
+	}
+
+	var drafts []*models.DraftOrder
+	if err := query.Preload("LineItems", orderByCreation).
+		Order("created_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&drafts).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to get draft orders")
+		return nil, 0, err
+	}
+	return drafts, total, nil
+}
+
+// GetExpiredDraftOrderIDs returns the IDs of up to limit drafts of any
+// merchant still holding stock after they expired
+func (r *OrderRepository) GetExpiredDraftOrderIDs(ctx context.Context, now time.Time, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := r.db.WithContext(ctx).Model(&models.DraftOrder{}).
+		Where("status IN ? AND expires_at < ?", []models.DraftOrderStatus{models.DraftOrderStatusOpen, models.DraftOrderStatusInvoiceSent}, now).
+		Order("expires_at").
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to get expired draft orders")
+		return nil, err
+	}
+	return ids, nil
+}
+
+// UpdateDraftOrder locks a draft order of the context's merchant, lets
+// update change it and saves it. The draft's line items are replaced by
+// the ones update leaves on it. A change with an order creates the order,
+// records its payment and updates its payment status in the same
+// transaction.
+func (r *OrderRepository) UpdateDraftOrder(ctx context.Context, id uuid.UUID, update func(draft *models.DraftOrder) (*DraftOrderChange, error)) (*models.DraftOrder, error) {
+	var draft models.DraftOrder
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(tenant.Scope(ctx)).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("LineItems", orderByCreation).
+			First(&draft, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		change, err := update(&draft)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Omit(clause.Associations).Save(&draft).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("draft_order_id = ?", draft.ID).Delete(&models.DraftOrderLineItem{}).Error; err != nil {
+			return err
+		}
+		for i := range draft.LineItems {
+			draft.LineItems[i].DraftOrderID = draft.ID
+			if err := tx.Create(&draft.LineItems[i]).Error; err != nil {
+				return err
+			}
+		}
+		if change == nil {
+			return nil
+		}
+
+		if change.Order != nil {
+			if err := r.WithTx(tx).CreateOrder(ctx, change.Order); err != nil {
+				return err
+			}
+		}
+		if change.Transaction != nil {
+			if err := tx.Create(change.Transaction).Error; err != nil {
+				return err
+			}
+			if err := r.updateOrderPaymentStatus(tx, change.Transaction.OrderID); err != nil {
+				return err
+			}
+		}
+		return messaging.EnqueueOutbox(tx, change.Outbox...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// orderByCreation keeps preloaded line items in the order they were added
+func orderByCreation(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at, id")
 }
 
 // Event Operations
 
-// CreateOrderEvent creates a new order event
-func (r *OrderRepository) CreateOrderEvent(ctx context.Context, event *models.OrderEvent) error {
-	if err := r.db.WithContext(ctx).Create(event).Error; err != nil {
+// CreateOrderEvent creates a new order event, enqueuing the outbox events
+// it publishes in the same transaction
+func (r *OrderRepository) CreateOrderEvent(ctx context.Context, event *models.OrderEvent, outbox ...*messaging.OutboxMessage) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+		return messaging.EnqueueOutbox(tx, outbox...)
+	})
+	if err != nil {
 		r.logger.WithError(err).Error("Failed to create order event")
 		return err
 	}
@@ -462,6 +1217,7 @@ func (r *OrderRepository) CreateOrderEvent(ctx context.Context, event *models.Or
 func (r *OrderRepository) GetOrderEvents(ctx context.Context, orderID uuid.UUID) ([]*models.OrderEvent, error) {
 	var events []*models.OrderEvent
 	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeThrough(ctx, "order_id", "orders")).
 		Where("order_id = ?", orderID).
 		Order("created_at DESC").
 		Find(&events).Error; err != nil {
@@ -480,6 +1236,7 @@ func (r *OrderRepository) GetOrderStats(ctx context.Context, merchantID uuid.UUI
 	// Total orders
 	var totalOrders int64
 	if err := r.db.WithContext(ctx).Model(&models.Order{}).
+		Scopes(tenant.Scope(ctx)).
 		Where("merchant_id = ? AND created_at BETWEEN ? AND ?", merchantID, dateFrom, dateTo).
 		Count(&totalOrders).Error; err != nil {
 		return nil, err
@@ -489,6 +1246,7 @@ func (r *OrderRepository) GetOrderStats(ctx context.Context, merchantID uuid.UUI
 	// Total revenue
 	var totalRevenue float64
 	if err := r.db.WithContext(ctx).Model(&models.Order{}).
+		Scopes(tenant.Scope(ctx)).
 		Where("merchant_id = ? AND created_at BETWEEN ? AND ? AND status NOT IN ?",
 			merchantID, dateFrom, dateTo, []string{"cancelled", "refunded"}).
 		Select("COALESCE(SUM(total_price), 0)").
@@ -511,6 +1269,7 @@ func (r *OrderRepository) GetOrderStats(ctx context.Context, merchantID uuid.UUI
 		Count  int64
 	}
 	if err := r.db.WithContext(ctx).Model(&models.Order{}).
+		Scopes(tenant.Scope(ctx)).
 		Select("status, COUNT(*) as count").
 		Where("merchant_id = ? AND created_at BETWEEN ? AND ?", merchantID, dateFrom, dateTo).
 		Group("status").
@@ -527,106 +1286,232 @@ func (r *OrderRepository) GetOrderStats(ctx context.Context, merchantID uuid.UUI
 
 // Helper functions
 
-func (r *OrderRepository) applyOrderFilters(query *gorm.DB, filters map[string]interface{}) *gorm.DB {
-	for key, value := range filters {
-		switch key {
-		case "status":
-			query = query.Where("status = ?", value)
-		case "payment_status":
-			query = query.Where("payment_status = ?", value)
-		case "fulfillment_status":
-			query = query.Where("fulfillment_status = ?", value)
-		case "source":
-			query = query.Where("source = ?", value)
-		case "customer_id":
-			query = query.Where("customer_id = ?", value)
-		case "date_from":
-			query = query.Where("created_at >= ?", value)
-		case "date_to":
-			query = query.Where("created_at <= ?", value)
-		case "min_total":
-			query = query.Where("total_price >= ?", value)
-		case "max_total":
-			query = query.Where("total_price <= ?", value)
-		}
-	}
-	return query
-}
-
-func (r *OrderRepository) applyOrderFiltersScope(filters map[string]interface{}) func(*gorm.DB) *gorm.DB {
+// orderQueryScope narrows a query on orders to those matching the order
+// query
+func orderQueryScope(query *models.OrderQuery) func(*gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
-		return r.applyOrderFilters(db, filters)
+		if len(query.Statuses) > 0 {
+			db = db.Where("status IN ?", query.Statuses)
+		}
+		if len(query.FulfillmentStatuses) > 0 {
+			db = db.Where("fulfillment_status IN ?", query.FulfillmentStatuses)
+		}
+		if len(query.PaymentStatuses) > 0 {
+			db = db.Where("payment_status IN ?", query.PaymentStatuses)
+		}
+		if len(query.Sources) > 0 {
+			db = db.Where("source IN ?", query.Sources)
+		}
+		if query.CustomerID != nil {
+			db = db.Where("customer_id = ?", *query.CustomerID)
+		}
+		if query.CustomerEmail != "" {
+			db = db.Where("LOWER(customer_email) = LOWER(?)", query.CustomerEmail)
+		}
+		if query.CreatedFrom != nil {
+			db = db.Where("created_at >= ?", *query.CreatedFrom)
+		}
+		if query.CreatedTo != nil {
+			db = db.Where("created_at <= ?", *query.CreatedTo)
+		}
+		if query.MinTotal != nil {
+			db = db.Where("total_price >= ?", *query.MinTotal)
+		}
+		if query.MaxTotal != nil {
+			db = db.Where("total_price <= ?", *query.MaxTotal)
+		}
+		if len(query.Tags) > 0 {
+			// Each tag gets its own placeholder, as a slice bound after "["
+			// would render as a single parenthesised row
+			tags := make([]interface{}, len(query.Tags))
+			for i, tag := range query.Tags {
+				tags[i] = tag
+			}
+			placeholders := strings.TrimSuffix(strings.Repeat("?,", len(tags)), ",")
+			db = db.Where("tags @> ARRAY["+placeholders+"]::text[]", tags...)
+		}
+		if query.SKU != "" {
+			db = db.Where("EXISTS (SELECT 1 FROM order_line_items WHERE order_line_items.order_id = orders.id AND order_line_items.sku = ?)", query.SKU)
+		}
+		if query.Search != "" {
+			db = db.Where("order_number ILIKE ?", "%"+escapeLike(query.Search)+"%")
+		}
+		return db
 	}
 }
 
-func (r *OrderRepository) recalculateOrderTotals(tx *gorm.DB, orderID uuid.UUID) error {
-	var subtotal float64
-	if err := tx.Model(&models.OrderLineItem{}).
-		Where("order_id = ?", orderID).
-		Select("COALESCE(SUM(line_price), 0)").
-		Scan(&subtotal).Error; err != nil {
+// escapeLike escapes the LIKE wildcards in s so it matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// orderStatusColumns are the order columns written by state transitions
+var orderStatusColumns = []string{
+	"status", "fulfillment_status", "payment_status",
+	"processed_at", "fulfilled_at", "shipped_at", "delivered_at", "cancelled_at",
+}
+
+// orderRiskColumns are the order columns written by risk assessments
+var orderRiskColumns = []string{"risk_level", "held_for_review"}
+
+// applyTransition moves the order to the status through the state machine,
+// saves the order's status columns and records the transition's event. A
+// blank description is replaced by one naming the new status.
+func applyTransition[S ~string](tx *gorm.DB, machine *models.StateMachine[S], order *models.Order, status S, description string, userID *uuid.UUID) error {
+	transition, err := machine.Transition(order, status, time.Now())
+	if err != nil || transition == nil {
+		return err
+	}
+
+	if err := tx.Model(order).Select(append(orderStatusColumns, "updated_at")).Updates(order).Error; err != nil {
 		return err
 	}
 
-	// Update order totals (simplified - in real implementation would include tax calculation)
-	return tx.Model(&models.Order{}).
-		Where("id = ?", orderID).
-		Updates(map[string]interface{}{
-			"subtotal_price": subtotal,
-			"total_price":    subtotal, // Simplified
-		}).Error
+	if transition.Event == "" {
+		return nil
+	}
+	if description == "" {
+		description = fmt.Sprintf("Order %s status changed to %s", machine.Name(), status)
+	}
+	event := &models.OrderEvent{
+		OrderID:     order.ID,
+		EventType:   transition.Event,
+		Description: description,
+		UserID:      userID,
+	}
+	return tx.Create(event).Error
 }
 
-func (r *OrderRepository) calculateLineItemFulfillmentStatus(tx *gorm.DB, lineItemID uuid.UUID) models.FulfillmentStatus {
-	var lineItem models.OrderLineItem
-	if err := tx.First(&lineItem, "id = ?", lineItemID).Error; err != nil {
-		return models.FulfillmentStatusUnfulfilled
+// lockOrder loads an order with the relations its statuses derive from and
+// locks its row for the rest of the transaction
+func (r *OrderRepository) lockOrder(tx *gorm.DB, orderID uuid.UUID) (*models.Order, error) {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("LineItems").
+		Preload("Fulfillments").
+		Preload("Transactions").
+		First(&order, "id = ?", orderID).Error; err != nil {
+		return nil, err
 	}
+	return &order, nil
+}
 
-	if lineItem.FulfilledQuantity == 0 {
-		return models.FulfillmentStatusUnfulfilled
-	} else if lineItem.FulfilledQuantity < lineItem.Quantity {
-		return models.FulfillmentStatusPartiallyFulfilled
-	} else {
-		return models.FulfillmentStatusFulfilled
+// hasLineItem reports whether the order has the line item
+func hasLineItem(order *models.Order, lineItemID uuid.UUID) bool {
+	for _, lineItem := range order.LineItems {
+		if lineItem.ID == lineItemID {
+			return true
+		}
 	}
+	return false
 }
 
+// fulfillLineItem adds a fulfillment's quantity to its line item of the
+// order and recomputes the line item's fulfillment status
+func (r *OrderRepository) fulfillLineItem(tx *gorm.DB, orderID uuid.UUID, fulfillmentLineItem models.FulfillmentLineItem) error {
+	var lineItem models.OrderLineItem
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&lineItem, "id = ? AND order_id = ?", fulfillmentLineItem.LineItemID, orderID).Error; err != nil {
+		return err
+	}
+
+	lineItem.FulfilledQuantity += fulfillmentLineItem.Quantity
+	return tx.Model(&lineItem).Updates(map[string]interface{}{
+		"fulfilled_quantity": lineItem.FulfilledQuantity,
+		"fulfillment_status": models.LineItemFulfillmentStatus(&lineItem),
+	}).Error
+}
+
+// updateOrderFulfillmentStatus recomputes the order's fulfillment status from
+// its line items. The first fulfillment also moves a confirmed order to
+// processing.
 func (r *OrderRepository) updateOrderFulfillmentStatus(tx *gorm.DB, orderID uuid.UUID) error {
-	// This would implement logic to check all line items and update order fulfillment status
-	// Simplified implementation
+	order, err := r.lockOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if order.FulfillmentStatus == models.FulfillmentStatusRestocked {
+		return nil
+	}
+
+	status := models.DeriveFulfillmentStatus(order.LineItems)
+	if err := applyTransition(tx, models.FulfillmentStateMachine, order, status, "", nil); err != nil {
+		return err
+	}
+
+	if order.Status == models.OrderStatusConfirmed && status != models.FulfillmentStatusUnfulfilled {
+		return applyTransition(tx, models.OrderStateMachine, order, models.OrderStatusProcessing, "", nil)
+	}
 	return nil
 }
 
+// updateOrderShippingStatus marks the order shipped once every shipment has
+// left and delivered once every shipment has arrived. Carriers can report a
+// delivery before the order was marked shipped, and can correct a delivery
+// after it, so orders step through shipped to delivered and orders that
+// cannot move to the status, such as returned ones, keep theirs.
 func (r *OrderRepository) updateOrderShippingStatus(tx *gorm.DB, orderID uuid.UUID) error {
-	// This would implement logic to check if order should be marked as shipped
-	// Simplified implementation
-	return nil
+	order, err := r.lockOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	status, ok := models.DeriveShippingStatus(order)
+	if !ok || status == order.Status {
+		return nil
+	}
+	if status == models.OrderStatusDelivered && models.OrderStateMachine.Can(order.Status, models.OrderStatusShipped) {
+		if err := applyTransition(tx, models.OrderStateMachine, order, models.OrderStatusShipped, "", nil); err != nil {
+			return err
+		}
+	}
+	if !models.OrderStateMachine.Can(order.Status, status) {
+		return nil
+	}
+	return applyTransition(tx, models.OrderStateMachine, order, status, "", nil)
 }
 
+// updateOrderPaymentStatus recomputes the order's payment status from its
+// transactions. A closed order whose payment is fully refunded becomes
+// refunded.
 func (r *OrderRepository) updateOrderPaymentStatus(tx *gorm.DB, orderID uuid.UUID) error {
-	// This would implement logic to calculate payment status based on transactions
-	// Simplified implementation
-	return nil
-}
+	order, err := r.lockOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
 
-func (r *OrderRepository) updateOrderReturnStatus(tx *gorm.DB, orderID uuid.UUID) error {
-	// This would implement logic to check if order should be marked as returned
-	// Simplified implementation
+	status := models.DerivePaymentStatus(order.TotalPrice, order.Transactions)
+	if err := applyTransition(tx, models.PaymentStateMachine, order, status, "", nil); err != nil {
+		return err
+	}
+
+	if status == models.PaymentStatusRefunded && models.OrderStateMachine.Can(order.Status, models.OrderStatusRefunded) {
+		return applyTransition(tx, models.OrderStateMachine, order, models.OrderStatusRefunded, "", nil)
+	}
 	return nil
 }
 
-func (r *OrderRepository) getEventTypeFromStatus(status models.OrderStatus) models.OrderEventType {
-	switch status {
-	case models.OrderStatusConfirmed:
-		return models.OrderEventConfirmed
-	case models.OrderStatusShipped:
-		return models.OrderEventShipped
-	case models.OrderStatusDelivered:
-		return models.OrderEventDelivered
-	case models.OrderStatusCancelled:
-		return models.OrderEventCancelled
-	default:
-		return models.OrderEventCreated
+// updateOrderReturnStatus marks the order returned once refunded returns
+// cover all of its items, restocking its fulfillment if requested
+func (r *OrderRepository) updateOrderReturnStatus(tx *gorm.DB, orderID uuid.UUID, restockItems bool) error {
+	order, err := r.lockOrder(tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	returns, err := r.findReturns(tx, orderID)
+	if err != nil {
+		return err
 	}
+	if !models.IsFullyReturned(order.LineItems, returns) {
+		return nil
+	}
+
+	if err := applyTransition(tx, models.OrderStateMachine, order, models.OrderStatusReturned, "", nil); err != nil {
+		return err
+	}
+	if restockItems {
+		return applyTransition(tx, models.FulfillmentStateMachine, order, models.FulfillmentStatusRestocked, "", nil)
+	}
+	return nil
 }
@@ -0,0 +1,148 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/migrations"
+	"unified-commerce/services/order/models"
+	"unified-commerce/services/order/repository"
+	"unified-commerce/services/shared/database/dbtest"
+	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/tenant"
+)
+
+func newTestRepository(t *testing.T) *repository.OrderRepository {
+	t.Helper()
+	db := dbtest.Open(t, "order", migrations.All())
+	return repository.NewOrderRepository(db, logger.NewLogger(logger.Config{Level: "error"}))
+}
+
+func TestOrderRepository_HidesOtherMerchantsOrders(t *testing.T) {
+	repo := newTestRepository(t)
+	merchantA, merchantB := uuid.New(), uuid.New()
+	ctxA := tenant.WithMerchantID(context.Background(), merchantA.String())
+	ctxB := tenant.WithMerchantID(context.Background(), merchantB.String())
+
+	order := &models.Order{ID: uuid.New(), OrderNumber: "#1001", MerchantID: merchantA, Currency: "USD"}
+	if err := repo.CreateOrder(ctxA, order); err != nil {
+		t.Fatal(err)
+	}
+
+	if found, err := repo.GetOrder(ctxA, order.ID); err != nil || found == nil {
+		t.Fatalf("owner lookup: order = %v, err = %v", found, err)
+	}
+	if found, err := repo.GetOrder(ctxB, order.ID); err != nil || found != nil {
+		t.Fatalf("another merchant got order %v, err = %v; want not found", found, err)
+	}
+	if found, err := repo.GetOrderByNumber(ctxB, order.OrderNumber); err != nil || found != nil {
+		t.Fatalf("another merchant got order %v by number, err = %v; want not found", found, err)
+	}
+}
+
+func TestOrderRepository_RejectsLookupWithoutMerchant(t *testing.T) {
+	repo := newTestRepository(t)
+	merchant := uuid.New()
+	ctx := tenant.WithMerchantID(context.Background(), merchant.String())
+
+	order := &models.Order{ID: uuid.New(), OrderNumber: "#1001", MerchantID: merchant, Currency: "USD"}
+	if err := repo.CreateOrder(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.GetOrder(context.Background(), order.ID); err == nil {
+		t.Fatal("expected an error looking up an order without a merchant")
+	}
+	if found, err := repo.GetOrder(tenant.WithoutScope(context.Background()), order.ID); err != nil || found == nil {
+		t.Fatalf("unscoped lookup: order = %v, err = %v", found, err)
+	}
+}
+
+func TestOrderRepository_CommitOrderEditDerivesStatuses(t *testing.T) {
+	repo := newTestRepository(t)
+	merchant := uuid.New()
+	ctx := tenant.WithMerchantID(context.Background(), merchant.String())
+
+	now := time.Now()
+	orderID, shirtID := uuid.New(), uuid.New()
+	order := &models.Order{
+		ID:                orderID,
+		OrderNumber:       "#1001",
+		MerchantID:        merchant,
+		Currency:          "USD",
+		Status:            models.OrderStatusProcessing,
+		FulfillmentStatus: models.FulfillmentStatusFulfilled,
+		PaymentStatus:     models.PaymentStatusPaid,
+		FulfilledAt:       &now,
+		TotalPrice:        100,
+		LineItems: []models.OrderLineItem{{
+			ID: shirtID, OrderID: orderID, ProductID: uuid.New(), Name: "Shirt", SKU: "SHIRT",
+			Quantity: 1, FulfilledQuantity: 1, Price: 100, LinePrice: 100, FulfillmentStatus: models.FulfillmentStatusFulfilled,
+		}},
+		Transactions: []models.Transaction{{
+			ID: uuid.New(), OrderID: orderID, Kind: models.TransactionKindSale, Status: models.TransactionStatusSuccess, Amount: 100,
+		}},
+	}
+	if err := repo.CreateOrder(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	edit := &models.OrderEdit{ID: uuid.New(), OrderID: orderID, Status: models.OrderEditStatusOpen}
+	if err := repo.CreateOrderEdit(ctx, edit); err != nil {
+		t.Fatal(err)
+	}
+
+	// The edit adds an unfulfilled hat, raising the total past what was paid
+	_, err := repo.CommitOrderEdit(ctx, edit.ID, func(edit *models.OrderEdit, order *models.Order) (*repository.OrderEditCommit, error) {
+		edited := *order
+		edited.LineItems = append(append([]models.OrderLineItem(nil), order.LineItems...), models.OrderLineItem{
+			ID: uuid.New(), OrderID: orderID, ProductID: uuid.New(), Name: "Hat", SKU: "HAT",
+			Quantity: 1, Price: 20, LinePrice: 20, FulfillmentStatus: models.FulfillmentStatusUnfulfilled,
+		})
+		edited.TotalPrice = 120
+		edit.Status = models.OrderEditStatusCommitted
+		return &repository.OrderEditCommit{
+			Order: &edited,
+			Event: &models.OrderEvent{OrderID: orderID, EventType: models.OrderEventEdited, Description: "Order edited"},
+		}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := repo.GetOrder(ctx, orderID)
+	if err != nil || found == nil {
+		t.Fatalf("reload order: order = %v, err = %v", found, err)
+	}
+	if found.FulfillmentStatus != models.FulfillmentStatusPartiallyFulfilled || found.PaymentStatus != models.PaymentStatusPartiallyPaid {
+		t.Fatalf("statuses = %s, %s; want partially fulfilled and partially paid", found.FulfillmentStatus, found.PaymentStatus)
+	}
+}
+
+func TestOrderRepository_SearchOrdersMatchesEveryTag(t *testing.T) {
+	repo := newTestRepository(t)
+	merchant := uuid.New()
+	ctx := tenant.WithMerchantID(context.Background(), merchant.String())
+
+	tagged := map[string][]string{
+		"#1001": {"vip", "wholesale"},
+		"#1002": {"vip"},
+		"#1003": {"wholesale"},
+	}
+	for number, tags := range tagged {
+		order := &models.Order{ID: uuid.New(), OrderNumber: number, MerchantID: merchant, Currency: "USD", Tags: tags}
+		if err := repo.CreateOrder(ctx, order); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	orders, err := repo.SearchOrders(ctx, merchant, &models.OrderQuery{Tags: []string{"vip", "wholesale"}}, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].OrderNumber != "#1001" {
+		t.Fatalf("orders = %v, want only #1001 carrying both tags", orders)
+	}
+}
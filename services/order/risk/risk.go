@@ -0,0 +1,212 @@
+// Package risk scores orders for fraud risk before they are confirmed. An
+// Engine runs rules over the order and what its merchant has seen of the
+// customer, and each rule that fires adds a weighted signal to the order's
+// score. The score's level decides whether the order is held for review.
+package risk
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"unified-commerce/services/order/models"
+)
+
+// Signal codes
+const (
+	SignalAddressMismatch    = "address_mismatch"
+	SignalCustomerVelocity   = "customer_velocity"
+	SignalEmailVelocity      = "email_velocity"
+	SignalIPVelocity         = "ip_velocity"
+	SignalFirstTimeHighValue = "first_time_high_value"
+	SignalFailedPayments     = "failed_payments"
+)
+
+// Config holds the thresholds and weights of the rules
+type Config struct {
+	// Orders placed by one customer, email or IP address within the
+	// velocity window beyond MaxRecentOrders are suspicious
+	VelocityWindow  time.Duration
+	MaxRecentOrders int64
+	// Failed payments of the customer's orders are counted within the
+	// failed payment window
+	FailedPaymentWindow time.Duration
+	// Orders of at least HighValueAmount from customers without earlier
+	// orders are suspicious
+	HighValueAmount float64
+
+	// Signal weights
+	CountryMismatchScore    int
+	PostalCodeMismatchScore int
+	VelocityScore           int
+	FirstTimeHighValueScore int
+	FailedPaymentScore      int // per failed payment
+	MaxFailedPaymentScore   int
+
+	// Scores from which orders are medium and high risk. High-risk orders
+	// are held for review.
+	MediumScore int
+	HighScore   int
+}
+
+// DefaultConfig returns the default thresholds and weights
+func DefaultConfig() Config {
+	return Config{
+		VelocityWindow:          time.Hour,
+		MaxRecentOrders:         3,
+		FailedPaymentWindow:     24 * time.Hour,
+		HighValueAmount:         500,
+		CountryMismatchScore:    30,
+		PostalCodeMismatchScore: 15,
+		VelocityScore:           25,
+		FirstTimeHighValueScore: 30,
+		FailedPaymentScore:      10,
+		MaxFailedPaymentScore:   40,
+		MediumScore:             30,
+		HighScore:               60,
+	}
+}
+
+// Rule returns the signals an order raises
+type Rule func(order *models.Order, history *models.RiskHistory, config Config) []models.RiskSignal
+
+// DefaultRules are the rules engines run when none are given
+var DefaultRules = []Rule{AddressMismatch, Velocity, FirstTimeHighValue, FailedPayments}
+
+// Engine scores orders with rules
+type Engine struct {
+	config Config
+	rules  []Rule
+}
+
+// NewEngine creates an engine running the rules, or DefaultRules if none
+// are given
+func NewEngine(config Config, rules ...Rule) *Engine {
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+	return &Engine{config: config, rules: rules}
+}
+
+// HistoryQuery returns the windows of the history to assess an order placed
+// at a time with
+func (e *Engine) HistoryQuery(now time.Time) models.RiskHistoryQuery {
+	return models.RiskHistoryQuery{
+		VelocitySince:       now.Add(-e.config.VelocityWindow),
+		FailedPaymentsSince: now.Add(-e.config.FailedPaymentWindow),
+	}
+}
+
+// Assess scores an order. The score is the sum of its signals, capped at
+// 100. The assessment's review status is left to the caller.
+func (e *Engine) Assess(order *models.Order, history *models.RiskHistory) *models.RiskAssessment {
+	assessment := &models.RiskAssessment{
+		OrderID:    order.ID,
+		MerchantID: order.MerchantID,
+		Signals:    []models.RiskSignal{},
+	}
+	for _, rule := range e.rules {
+		for _, signal := range rule(order, history, e.config) {
+			assessment.Signals = append(assessment.Signals, signal)
+			assessment.Score += signal.Score
+		}
+	}
+	assessment.Score = min(assessment.Score, 100)
+
+	switch {
+	case assessment.Score >= e.config.HighScore:
+		assessment.Level = models.RiskLevelHigh
+	case assessment.Score >= e.config.MediumScore:
+		assessment.Level = models.RiskLevelMedium
+	default:
+		assessment.Level = models.RiskLevelLow
+	}
+	return assessment
+}
+
+// Rules
+
+// AddressMismatch flags orders shipped to another country or postal code
+// than their billing address
+func AddressMismatch(order *models.Order, history *models.RiskHistory, config Config) []models.RiskSignal {
+	billing, shipping := order.BillingAddress, order.ShippingAddress
+	if isBlank(billing.Country) || isBlank(shipping.Country) {
+		return nil
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(billing.Country), strings.TrimSpace(shipping.Country)) {
+		return []models.RiskSignal{{
+			Code:        SignalAddressMismatch,
+			Description: fmt.Sprintf("Billing country %s differs from shipping country %s", billing.Country, shipping.Country),
+			Score:       config.CountryMismatchScore,
+		}}
+	}
+	if !isBlank(billing.PostalCode) && !isBlank(shipping.PostalCode) && normalizePostalCode(billing.PostalCode) != normalizePostalCode(shipping.PostalCode) {
+		return []models.RiskSignal{{
+			Code:        SignalAddressMismatch,
+			Description: "Billing postal code differs from shipping postal code",
+			Score:       config.PostalCodeMismatchScore,
+		}}
+	}
+	return nil
+}
+
+// Velocity flags customers, emails and IP addresses placing many orders in
+// a short time
+func Velocity(order *models.Order, history *models.RiskHistory, config Config) []models.RiskSignal {
+	var signals []models.RiskSignal
+	check := func(code, subject string, recent int64) {
+		if recent >= config.MaxRecentOrders {
+			signals = append(signals, models.RiskSignal{
+				Code:        code,
+				Description: fmt.Sprintf("%d earlier orders from this %s in the last %s", recent, subject, formatWindow(config.VelocityWindow)),
+				Score:       config.VelocityScore,
+			})
+		}
+	}
+	check(SignalCustomerVelocity, "customer", history.RecentCustomerOrders)
+	check(SignalEmailVelocity, "email", history.RecentEmailOrders)
+	check(SignalIPVelocity, "IP address", history.RecentIPOrders)
+	return signals
+}
+
+// FirstTimeHighValue flags high-value orders from customers without earlier
+// orders
+func FirstTimeHighValue(order *models.Order, history *models.RiskHistory, config Config) []models.RiskSignal {
+	if history.PreviousOrders > 0 || order.TotalPrice < config.HighValueAmount {
+		return nil
+	}
+	return []models.RiskSignal{{
+		Code:        SignalFirstTimeHighValue,
+		Description: fmt.Sprintf("First order from this customer totals %.2f %s", order.TotalPrice, order.Currency),
+		Score:       config.FirstTimeHighValueScore,
+	}}
+}
+
+// FailedPayments flags customers whose payments recently failed
+func FailedPayments(order *models.Order, history *models.RiskHistory, config Config) []models.RiskSignal {
+	if history.FailedPayments == 0 {
+		return nil
+	}
+	return []models.RiskSignal{{
+		Code:        SignalFailedPayments,
+		Description: fmt.Sprintf("%d failed payment(s) in the last %s", history.FailedPayments, formatWindow(config.FailedPaymentWindow)),
+		Score:       min(int(history.FailedPayments)*config.FailedPaymentScore, config.MaxFailedPaymentScore),
+	}}
+}
+
+// formatWindow formats a window without its zero units, such as 1h
+func formatWindow(d time.Duration) string {
+	return strings.TrimSuffix(strings.TrimSuffix(d.String(), "0s"), "0m")
+}
+
+// isBlank reports whether an address field is empty
+func isBlank(s string) bool {
+	return strings.TrimSpace(s) == ""
+}
+
+// normalizePostalCode uppercases a postal code and strips its spaces and
+// dashes
+func normalizePostalCode(code string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.ToUpper(code))
+}
@@ -0,0 +1,82 @@
+package risk_test
+
+import (
+	"testing"
+
+	"unified-commerce/services/order/models"
+	"unified-commerce/services/order/risk"
+)
+
+func signalCodes(assessment *models.RiskAssessment) map[string]bool {
+	codes := make(map[string]bool)
+	for _, signal := range assessment.Signals {
+		codes[signal.Code] = true
+	}
+	return codes
+}
+
+func TestEngine_ScoresSignals(t *testing.T) {
+	engine := risk.NewEngine(risk.DefaultConfig())
+	order := &models.Order{
+		TotalPrice:      800,
+		Currency:        "USD",
+		BillingAddress:  models.Address{Country: "US", PostalCode: "10001"},
+		ShippingAddress: models.Address{Country: "NG", PostalCode: "100001"},
+	}
+
+	assessment := engine.Assess(order, &models.RiskHistory{RecentIPOrders: 5, FailedPayments: 2})
+
+	codes := signalCodes(assessment)
+	for _, code := range []string{risk.SignalAddressMismatch, risk.SignalIPVelocity, risk.SignalFirstTimeHighValue, risk.SignalFailedPayments} {
+		if !codes[code] {
+			t.Errorf("expected signal %s, got %+v", code, assessment.Signals)
+		}
+	}
+	if codes[risk.SignalEmailVelocity] {
+		t.Errorf("expected no email velocity signal, got %+v", assessment.Signals)
+	}
+	if assessment.Score != 100 || assessment.Level != models.RiskLevelHigh {
+		t.Errorf("expected a capped high score, got %d %s", assessment.Score, assessment.Level)
+	}
+}
+
+func TestEngine_ReturningCustomerIsLowRisk(t *testing.T) {
+	engine := risk.NewEngine(risk.DefaultConfig())
+	order := &models.Order{
+		TotalPrice:      800,
+		BillingAddress:  models.Address{Country: "US", PostalCode: "10001"},
+		ShippingAddress: models.Address{Country: "us", PostalCode: "10001 "},
+	}
+
+	assessment := engine.Assess(order, &models.RiskHistory{PreviousOrders: 4, RecentCustomerOrders: 1})
+	if assessment.Score != 0 || assessment.Level != models.RiskLevelLow || len(assessment.Signals) != 0 {
+		t.Errorf("expected no risk, got %+v", assessment)
+	}
+}
+
+func TestRiskAssessment_RescoreHoldsPendingOrders(t *testing.T) {
+	engine := risk.NewEngine(risk.DefaultConfig())
+	order := &models.Order{Status: models.OrderStatusPending, TotalPrice: 20}
+	assessment := &models.RiskAssessment{Level: models.RiskLevelLow, ReviewStatus: models.RiskReviewNotRequired}
+
+	scored := engine.Assess(order, &models.RiskHistory{PreviousOrders: 1, FailedPayments: 4, RecentEmailOrders: 3})
+	if !assessment.Rescore(scored, order) {
+		t.Fatalf("expected a pending order scored %d to be held", scored.Score)
+	}
+	if !order.HeldForReview || assessment.ReviewStatus != models.RiskReviewPending {
+		t.Errorf("expected the order to wait for review, got %+v", assessment)
+	}
+
+	if err := assessment.Review(models.RiskReviewApproved, order, "Known customer", nil, order.CreatedAt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if order.HeldForReview {
+		t.Error("expected approval to release the hold")
+	}
+	if assessment.Rescore(scored, order) {
+		t.Error("expected an approved order not to be held again")
+	}
+	if err := assessment.Review(models.RiskReviewCancelled, order, "", nil, order.CreatedAt); err != models.ErrRiskReviewClosed {
+		t.Errorf("expected ErrRiskReviewClosed, got %v", err)
+	}
+}
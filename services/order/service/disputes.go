@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/order/models"
+	"unified-commerce/services/order/repository"
+	"unified-commerce/shared/messaging"
+)
+
+// Dispute Evidence
+
+// PaymentDispute is a chargeback filed against an order's payment, reported
+// by the payment service
+type PaymentDispute struct {
+	DisputeID     uuid.UUID
+	PaymentID     uuid.UUID
+	OrderID       uuid.UUID
+	Amount        float64
+	Currency      string
+	Reason        string
+	EvidenceDueBy *time.Time
+}
+
+// DisputeEvidence is what an order can show against a dispute of its
+// payment: what was bought, who bought it and how it was delivered
+type DisputeEvidence struct {
+	Order        OrderEvidence         `json:"order"`
+	Customer     CustomerEvidence      `json:"customer"`
+	Fulfillments []FulfillmentEvidence `json:"fulfillments"`
+}
+
+// OrderEvidence describes the disputed purchase
+type OrderEvidence struct {
+	OrderNumber    string             `json:"order_number"`
+	PlacedAt       time.Time          `json:"placed_at"`
+	TotalPrice     float64            `json:"total_price"`
+	Currency       string             `json:"currency"`
+	ShippingMethod string             `json:"shipping_method"`
+	LineItems      []LineItemEvidence `json:"line_items"`
+}
+
+// LineItemEvidence is a product of the disputed purchase
+type LineItemEvidence struct {
+	Name     string  `json:"name"`
+	SKU      string  `json:"sku"`
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+}
+
+// CustomerEvidence identifies who placed the order and where it went
+type CustomerEvidence struct {
+	Email           string         `json:"email"`
+	Phone           string         `json:"phone"`
+	Name            string         `json:"name"`
+	BrowserIP       string         `json:"browser_ip"`
+	BillingAddress  models.Address `json:"billing_address"`
+	ShippingAddress models.Address `json:"shipping_address"`
+}
+
+// FulfillmentEvidence is a shipment of the order and what its carrier
+// reported about it
+type FulfillmentEvidence struct {
+	Status         string             `json:"status"`
+	ShipmentStatus string             `json:"shipment_status"`
+	Carrier        string             `json:"carrier"`
+	TrackingNumber string             `json:"tracking_number"`
+	TrackingURL    string             `json:"tracking_url"`
+	ShippedAt      *time.Time         `json:"shipped_at"`
+	DeliveredAt    *time.Time         `json:"delivered_at"`
+	TrackingEvents []TrackingEvidence `json:"tracking_events"`
+}
+
+// TrackingEvidence is a status a carrier reported for a shipment
+type TrackingEvidence struct {
+	Status      string    `json:"status"`
+	Description string    `json:"description"`
+	Location    string    `json:"location"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// OrderDisputeEvidenceEvent is the payload of the order.dispute_evidence
+// event, which hands the payment service the evidence of a dispute
+type OrderDisputeEvidenceEvent struct {
+	DisputeID  uuid.UUID       `json:"dispute_id"`
+	OrderID    uuid.UUID       `json:"order_id"`
+	MerchantID uuid.UUID       `json:"merchant_id"`
+	Evidence   DisputeEvidence `json:"evidence"`
+}
+
+// AssembleDisputeEvidence records a dispute of an order's payment on the
+// order and sends the payment service the order's evidence against it
+func (s *OrderService) AssembleDisputeEvidence(ctx context.Context, dispute *PaymentDispute) error {
+	return s.assembleDisputeEvidence(ctx, s.repo, dispute)
+}
+
+// AssembleDisputeEvidenceInTx records a dispute and sends its evidence
+// inside a transaction owned by the caller
+func (s *OrderService) AssembleDisputeEvidenceInTx(ctx context.Context, tx *gorm.DB, dispute *PaymentDispute) error {
+	return s.assembleDisputeEvidence(ctx, s.repo.WithTx(tx), dispute)
+}
+
+// assembleDisputeEvidence assembles the evidence of a dispute from its
+// order, customer and fulfillments using the given repository
+func (s *OrderService) assembleDisputeEvidence(ctx context.Context, repo *repository.OrderRepository, dispute *PaymentDispute) error {
+	order, err := repo.GetDisputedOrder(ctx, dispute.OrderID)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		return ErrOrderNotFound
+	}
+
+	env, err := messaging.NewEnvelope(ctx, messaging.EventTypeOrderDisputeEvidence, 1, order.MerchantID.String(), OrderDisputeEvidenceEvent{
+		DisputeID:  dispute.DisputeID,
+		OrderID:    order.ID,
+		MerchantID: order.MerchantID,
+		Evidence:   newDisputeEvidence(order),
+	})
+	if err != nil {
+		return err
+	}
+	msg, err := messaging.NewEnvelopeOutboxMessage(s.schemas, "order", order.ID.String(), "orders.dispute_evidence", order.ID.String(), env)
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprintf("Payment of %.2f %s disputed: %s", dispute.Amount, dispute.Currency, dispute.Reason)
+	if dispute.EvidenceDueBy != nil {
+		description += fmt.Sprintf(", evidence due by %s", dispute.EvidenceDueBy.Format(time.RFC3339))
+	}
+	event := &models.OrderEvent{
+		OrderID:     order.ID,
+		EventType:   models.OrderEventPaymentDisputed,
+		Description: description,
+	}
+	if err := repo.CreateOrderEvent(ctx, event, msg); err != nil {
+		s.logger.WithError(err).WithField("order_id", order.ID).Error("Failed to send dispute evidence")
+		return err
+	}
+
+	s.logger.WithField("order_id", order.ID).WithField("dispute_id", dispute.DisputeID).Info("Dispute evidence assembled")
+	return nil
+}
+
+// newDisputeEvidence assembles the evidence of an order loaded with its
+// line items, fulfillments and their tracking events
+func newDisputeEvidence(order *models.Order) DisputeEvidence {
+	evidence := DisputeEvidence{
+		Order: OrderEvidence{
+			OrderNumber:    order.OrderNumber,
+			PlacedAt:       order.CreatedAt,
+			TotalPrice:     order.TotalPrice,
+			Currency:       order.Currency,
+			ShippingMethod: order.ShippingMethod,
+			LineItems:      make([]LineItemEvidence, 0, len(order.LineItems)),
+		},
+		Customer: CustomerEvidence{
+			Email:           order.Customer.Email,
+			Phone:           order.Customer.Phone,
+			Name:            strings.TrimSpace(order.Customer.FirstName + " " + order.Customer.LastName),
+			BrowserIP:       order.BrowserIP,
+			BillingAddress:  order.BillingAddress,
+			ShippingAddress: order.ShippingAddress,
+		},
+		Fulfillments: make([]FulfillmentEvidence, 0, len(order.Fulfillments)),
+	}
+
+	for _, lineItem := range order.LineItems {
+		// Items edited out of the order were not bought
+		if lineItem.Quantity < 1 {
+			continue
+		}
+		evidence.Order.LineItems = append(evidence.Order.LineItems, LineItemEvidence{
+			Name:     lineItem.Name,
+			SKU:      lineItem.SKU,
+			Quantity: lineItem.Quantity,
+			Price:    lineItem.Price,
+		})
+	}
+
+	for _, fulfillment := range order.Fulfillments {
+		shipment := FulfillmentEvidence{
+			Status:         string(fulfillment.Status),
+			ShipmentStatus: string(fulfillment.ShipmentStatus),
+			Carrier:        fulfillment.TrackingCompany,
+			TrackingNumber: fulfillment.TrackingNumber,
+			TrackingURL:    fulfillment.TrackingURL,
+			ShippedAt:      fulfillment.ShippedAt,
+			DeliveredAt:    fulfillment.DeliveredAt,
+			TrackingEvents: make([]TrackingEvidence, 0, len(fulfillment.TrackingEvents)),
+		}
+		for _, tracking := range fulfillment.TrackingEvents {
+			shipment.TrackingEvents = append(shipment.TrackingEvents, TrackingEvidence{
+				Status:      string(tracking.Status),
+				Description: tracking.Description,
+				Location:    tracking.Location,
+				OccurredAt:  tracking.OccurredAt,
+			})
+		}
+		sort.Slice(shipment.TrackingEvents, func(i, j int) bool {
+			return shipment.TrackingEvents[i].OccurredAt.Before(shipment.TrackingEvents[j].OccurredAt)
+		})
+		evidence.Fulfillments = append(evidence.Fulfillments, shipment)
+	}
+	return evidence
+}
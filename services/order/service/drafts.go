@@ -0,0 +1,746 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/order/models"
+	"unified-commerce/services/order/repository"
+	"unified-commerce/services/shared/sequence"
+	"unified-commerce/services/shared/tenant"
+	"unified-commerce/shared/messaging"
+)
+
+// Draft Orders
+
+// DraftOrderTTL is how long a draft order holds its stock, and its invoice
+// stays payable, after it was last changed or its invoice was sent
+const DraftOrderTTL = 7 * 24 * time.Hour
+
+// DraftOrderLineItemRequest represents a line of a draft order. Price is
+// the price charged, which may differ from the catalogue's OriginalPrice.
+type DraftOrderLineItemRequest struct {
+	ProductID        uuid.UUID             `json:"product_id" validate:"required"`
+	ProductVariantID *uuid.UUID            `json:"product_variant_id"`
+	SKU              string                `json:"sku" validate:"required"`
+	Name             string                `json:"name" validate:"required"`
+	ProductTitle     string                `json:"product_title"`
+	VariantTitle     string                `json:"variant_title"`
+	Vendor           string                `json:"vendor"`
+	Quantity         int                   `json:"quantity" validate:"required,min=1"`
+	Price            float64               `json:"price" validate:"min=0"`
+	OriginalPrice    float64               `json:"original_price" validate:"min=0"`
+	AppliedDiscount  *models.DraftDiscount `json:"applied_discount"`
+	Taxable          *bool                 `json:"taxable"`           // defaults to true
+	RequiresShipping *bool                 `json:"requires_shipping"` // defaults to true
+	TaxClass         string                `json:"tax_class"`
+	Properties       map[string]string     `json:"properties"`
+}
+
+// CreateDraftOrderRequest represents a request to create a draft order
+type CreateDraftOrderRequest struct {
+	MerchantID      uuid.UUID                   `json:"-" validate:"required"`
+	CustomerID      *uuid.UUID                  `json:"customer_id"`
+	LocationID      *uuid.UUID                  `json:"location_id"`
+	Customer        models.CustomerInfo         `json:"customer"`
+	BillingAddress  models.Address              `json:"billing_address"`
+	ShippingAddress models.Address              `json:"shipping_address"`
+	LineItems       []DraftOrderLineItemRequest `json:"line_items" validate:"required,min=1,dive"`
+	AppliedDiscount *models.DraftDiscount       `json:"applied_discount"`
+	ShippingMethod  string                      `json:"shipping_method"`
+	ShippingRate    float64                     `json:"shipping_rate" validate:"min=0"`
+	TaxesIncluded   bool                        `json:"taxes_included"`
+	Currency        string                      `json:"currency"`
+	Source          models.OrderSource          `json:"source"` // defaults to phone
+	Tags            []string                    `json:"tags"`
+	Notes           string                      `json:"notes"`
+	InternalNotes   string                      `json:"internal_notes"`
+}
+
+// CreateDraftOrder creates a draft order, prices it and reserves stock for
+// its items
+func (s *OrderService) CreateDraftOrder(ctx context.Context, req *CreateDraftOrderRequest, userID *uuid.UUID) (*models.DraftOrder, error) {
+	draft := &models.DraftOrder{
+		ID:              uuid.New(),
+		MerchantID:      req.MerchantID,
+		CustomerID:      req.CustomerID,
+		LocationID:      req.LocationID,
+		OrderID:         uuid.New(),
+		Status:          models.DraftOrderStatusOpen,
+		UserID:          userID,
+		Customer:        req.Customer,
+		BillingAddress:  req.BillingAddress,
+		ShippingAddress: req.ShippingAddress,
+		AppliedDiscount: req.AppliedDiscount,
+		ShippingMethod:  req.ShippingMethod,
+		ShippingRate:    req.ShippingRate,
+		TaxesIncluded:   req.TaxesIncluded,
+		Currency:        req.Currency,
+		Source:          req.Source,
+		Tags:            req.Tags,
+		Notes:           req.Notes,
+		InternalNotes:   req.InternalNotes,
+		ExpiresAt:       time.Now().Add(DraftOrderTTL),
+	}
+	if draft.Currency == "" {
+		draft.Currency = "USD"
+	}
+	if draft.Source == "" {
+		draft.Source = models.OrderSourcePhone
+	}
+
+	lineItems, err := draftLineItems(draft.ID, req.LineItems)
+	if err != nil {
+		return nil, err
+	}
+	draft.LineItems = lineItems
+
+	if err := s.priceDraftOrder(ctx, draft); err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Transaction(ctx, func(repo *repository.OrderRepository) error {
+		draftNumber, err := repo.NextNumber(ctx, draft.MerchantID, sequence.DraftOrder)
+		if err != nil {
+			return err
+		}
+		draft.DraftNumber = draftNumber
+
+		msg, err := s.newDraftOrderUpdatedOutboxMessage(ctx, draft)
+		if err != nil {
+			return err
+		}
+		return repo.CreateDraftOrder(ctx, draft, msg)
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to create draft order")
+		return nil, err
+	}
+
+	s.logger.WithField("draft_order_id", draft.ID).WithField("draft_number", draft.DraftNumber).Info("Draft order created successfully")
+	return draft, nil
+}
+
+// GetDraftOrder retrieves a draft order by ID
+func (s *OrderService) GetDraftOrder(ctx context.Context, id uuid.UUID) (*models.DraftOrder, error) {
+	draft, err := s.repo.GetDraftOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if draft == nil {
+		return nil, ErrDraftOrderNotFound
+	}
+	return draft, nil
+}
+
+// GetDraftOrdersByMerchant retrieves a merchant's draft orders, optionally
+// of one status
+func (s *OrderService) GetDraftOrdersByMerchant(ctx context.Context, merchantID uuid.UUID, status models.DraftOrderStatus, page, limit int) ([]*models.DraftOrder, int64, error) {
+	offset := (page - 1) * limit
+	return s.repo.GetDraftOrdersByMerchant(ctx, merchantID, status, limit, offset)
+}
+
+// UpdateDraftOrderRequest represents a request to change a draft order.
+// Fields left out are unchanged; line items, when given, replace the
+// draft's lines.
+type UpdateDraftOrderRequest struct {
+	CustomerID      *uuid.UUID                  `json:"customer_id"`
+	LocationID      *uuid.UUID                  `json:"location_id"`
+	Customer        *models.CustomerInfo        `json:"customer"`
+	BillingAddress  *models.Address             `json:"billing_address"`
+	ShippingAddress *models.Address             `json:"shipping_address"`
+	LineItems       []DraftOrderLineItemRequest `json:"line_items" validate:"omitempty,min=1,dive"`
+	AppliedDiscount *models.DraftDiscount       `json:"applied_discount"` // a value of zero removes the discount
+	ShippingMethod  *string                     `json:"shipping_method"`
+	ShippingRate    *float64                    `json:"shipping_rate" validate:"omitempty,min=0"`
+	TaxesIncluded   *bool                       `json:"taxes_included"`
+	Tags            []string                    `json:"tags"`
+	Notes           *string                     `json:"notes"`
+	InternalNotes   *string                     `json:"internal_notes"`
+}
+
+// UpdateDraftOrder changes a draft order and prices it again. A draft whose
+// invoice was sent is reopened, so the old payment link stops working and
+// the invoice must be sent again.
+func (s *OrderService) UpdateDraftOrder(ctx context.Context, id uuid.UUID, req *UpdateDraftOrderRequest) (*models.DraftOrder, error) {
+	return s.updateDraftOrder(ctx, id, "update", func(draft *models.DraftOrder) (*repository.DraftOrderChange, error) {
+		if !draft.IsEditable() {
+			return nil, ErrDraftOrderNotEditable
+		}
+		now := time.Now()
+		if draft.Status == models.DraftOrderStatusInvoiceSent {
+			if err := draft.TransitionTo(models.DraftOrderStatusOpen, now); err != nil {
+				return nil, err
+			}
+			draft.InvoiceTokenHash = ""
+		}
+
+		if req.CustomerID != nil {
+			draft.CustomerID = req.CustomerID
+		}
+		if req.LocationID != nil {
+			draft.LocationID = req.LocationID
+		}
+		if req.Customer != nil {
+			draft.Customer = *req.Customer
+		}
+		if req.BillingAddress != nil {
+			draft.BillingAddress = *req.BillingAddress
+		}
+		if req.ShippingAddress != nil {
+			draft.ShippingAddress = *req.ShippingAddress
+		}
+		if req.LineItems != nil {
+			lineItems, err := draftLineItems(draft.ID, req.LineItems)
+			if err != nil {
+				return nil, err
+			}
+			draft.LineItems = lineItems
+		}
+		if req.AppliedDiscount != nil {
+			draft.AppliedDiscount = req.AppliedDiscount
+			if req.AppliedDiscount.Value == 0 {
+				draft.AppliedDiscount = nil
+			}
+		}
+		if req.ShippingMethod != nil {
+			draft.ShippingMethod = *req.ShippingMethod
+		}
+		if req.ShippingRate != nil {
+			draft.ShippingRate = *req.ShippingRate
+		}
+		if req.TaxesIncluded != nil {
+			draft.TaxesIncluded = *req.TaxesIncluded
+		}
+		if req.Tags != nil {
+			draft.Tags = req.Tags
+		}
+		if req.Notes != nil {
+			draft.Notes = *req.Notes
+		}
+		if req.InternalNotes != nil {
+			draft.InternalNotes = *req.InternalNotes
+		}
+
+		if err := s.priceDraftOrder(ctx, draft); err != nil {
+			return nil, err
+		}
+		draft.ExpiresAt = now.Add(DraftOrderTTL)
+		return s.draftOrderUpdated(ctx, draft)
+	})
+}
+
+// SendDraftOrderInvoiceRequest represents a request to send a draft
+// order's invoice. The invoice goes to the customer's email unless To is
+// given.
+type SendDraftOrderInvoiceRequest struct {
+	To      string `json:"to" validate:"omitempty,email"`
+	Message string `json:"message"`
+}
+
+// DraftOrderInvoice is a sent invoice and the payment link it carries
+type DraftOrderInvoice struct {
+	DraftOrder *models.DraftOrder `json:"draft_order"`
+	InvoiceURL string             `json:"invoice_url"`
+}
+
+// SendDraftOrderInvoice issues a new payment link for a draft order and
+// publishes the invoice for delivery to the customer. Any earlier link
+// stops working, and the draft's stock is held for another DraftOrderTTL.
+func (s *OrderService) SendDraftOrderInvoice(ctx context.Context, id uuid.UUID, req *SendDraftOrderInvoiceRequest) (*DraftOrderInvoice, error) {
+	token, err := newInvoiceToken()
+	if err != nil {
+		return nil, err
+	}
+	invoiceURL := strings.TrimSuffix(s.invoiceURL, "/") + "/" + token
+
+	draft, err := s.updateDraftOrder(ctx, id, "send invoice for", func(draft *models.DraftOrder) (*repository.DraftOrderChange, error) {
+		to := req.To
+		if to == "" {
+			to = draft.Customer.Email
+		}
+		if to == "" {
+			return nil, ErrNoInvoiceRecipient
+		}
+		if len(draft.LineItems) == 0 {
+			return nil, ErrDraftOrderNotEditable
+		}
+
+		now := time.Now()
+		if err := draft.TransitionTo(models.DraftOrderStatusInvoiceSent, now); err != nil {
+			return nil, err
+		}
+		draft.InvoiceTokenHash = hashInvoiceToken(token)
+		draft.InvoiceSentTo = to
+		draft.PaymentError = ""
+		draft.ExpiresAt = now.Add(DraftOrderTTL)
+
+		change, err := s.draftOrderUpdated(ctx, draft)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := s.newDraftOrderInvoiceSentOutboxMessage(ctx, draft, invoiceURL, req.Message)
+		if err != nil {
+			return nil, err
+		}
+		change.Outbox = append(change.Outbox, msg)
+		return change, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DraftOrderInvoice{DraftOrder: draft, InvoiceURL: invoiceURL}, nil
+}
+
+// CancelDraftOrder cancels a draft order and releases its stock
+func (s *OrderService) CancelDraftOrder(ctx context.Context, id uuid.UUID) (*models.DraftOrder, error) {
+	return s.updateDraftOrder(ctx, id, "cancel", func(draft *models.DraftOrder) (*repository.DraftOrderChange, error) {
+		if err := draft.TransitionTo(models.DraftOrderStatusCancelled, time.Now()); err != nil {
+			return nil, err
+		}
+		draft.InvoiceTokenHash = ""
+		return s.draftOrderUpdated(ctx, draft)
+	})
+}
+
+// ExpireDraftOrders expires the drafts of every merchant that were neither
+// paid nor changed before they expired, releasing their stock. It returns
+// the number of drafts expired.
+func (s *OrderService) ExpireDraftOrders(ctx context.Context) (int, error) {
+	ctx = tenant.WithoutScope(ctx)
+	now := time.Now()
+
+	ids, err := s.repo.GetExpiredDraftOrderIDs(ctx, now, 100)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		draft, err := s.repo.UpdateDraftOrder(ctx, id, func(draft *models.DraftOrder) (*repository.DraftOrderChange, error) {
+			if !draft.ExpiresAt.Before(now) {
+				return nil, nil // changed since it was found
+			}
+			if err := draft.TransitionTo(models.DraftOrderStatusExpired, now); err != nil {
+				return nil, err
+			}
+			draft.InvoiceTokenHash = ""
+			return s.draftOrderUpdated(ctx, draft)
+		})
+		if errors.Is(err, models.ErrInvalidTransition) {
+			continue // paid or cancelled since it was found
+		}
+		if err != nil {
+			s.logger.WithError(err).WithField("draft_order_id", id).Error("Failed to expire draft order")
+			return expired, err
+		}
+		if draft.Status == models.DraftOrderStatusExpired {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// Draft Order Invoices
+
+// GetDraftOrderInvoice retrieves the draft order of a payment link
+func (s *OrderService) GetDraftOrderInvoice(ctx context.Context, token string) (*models.DraftOrder, error) {
+	draft, err := s.repo.GetDraftOrderByInvoiceToken(ctx, hashInvoiceToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if draft == nil {
+		return nil, ErrDraftOrderNotFound
+	}
+	return draft, nil
+}
+
+// DraftOrderPaymentMethod is the payment method a customer pays a draft
+// order's invoice with, tokenized by the payment provider
+type DraftOrderPaymentMethod struct {
+	Type        string `json:"type" validate:"required"`
+	Provider    string `json:"provider"`
+	Token       string `json:"token" validate:"required"`
+	Last4       string `json:"last4"`
+	Brand       string `json:"brand"`
+	ExpiryMonth int    `json:"expiry_month"`
+	ExpiryYear  int    `json:"expiry_year"`
+}
+
+// PayDraftOrderRequest represents a customer's payment of a draft order's
+// invoice
+type PayDraftOrderRequest struct {
+	PaymentMethod DraftOrderPaymentMethod `json:"payment_method" validate:"required"`
+}
+
+// PayDraftOrder asks the payment service to charge the customer for a
+// draft order through its payment link. The draft awaits the payment's
+// outcome and becomes an order once it is captured.
+func (s *OrderService) PayDraftOrder(ctx context.Context, token string, req *PayDraftOrderRequest) (*models.DraftOrder, error) {
+	invoice, err := s.GetDraftOrderInvoice(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Customers paying a link have no merchant context
+	ctx = tenant.WithoutScope(ctx)
+	return s.updateDraftOrder(ctx, invoice.ID, "request payment for", func(draft *models.DraftOrder) (*repository.DraftOrderChange, error) {
+		if draft.InvoiceTokenHash != invoice.InvoiceTokenHash || draft.Status != models.DraftOrderStatusInvoiceSent {
+			return nil, ErrDraftOrderNotPayable
+		}
+		now := time.Now()
+		if draft.ExpiresAt.Before(now) {
+			return nil, ErrDraftOrderExpired
+		}
+		if err := draft.TransitionTo(models.DraftOrderStatusPaymentPending, now); err != nil {
+			return nil, err
+		}
+		draft.PaymentError = ""
+
+		change, err := s.draftOrderUpdated(ctx, draft)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := s.newDraftOrderPaymentRequestedOutboxMessage(ctx, draft, &req.PaymentMethod)
+		if err != nil {
+			return nil, err
+		}
+		change.Outbox = append(change.Outbox, msg)
+		return change, nil
+	})
+}
+
+// DraftOrderPayment is the outcome of a draft order's payment, reported by
+// the payment service
+type DraftOrderPayment struct {
+	PaymentID uuid.UUID
+	OrderID   uuid.UUID
+	Amount    float64
+	Currency  string
+	Captured  bool
+	Reason    string // why the payment failed
+}
+
+// CompleteDraftOrderPayment applies the outcome of a draft order's payment
+// in its own transaction
+func (s *OrderService) CompleteDraftOrderPayment(ctx context.Context, payment *DraftOrderPayment) (*models.DraftOrder, error) {
+	return s.completeDraftOrderPayment(ctx, s.repo, payment)
+}
+
+// CompleteDraftOrderPaymentInTx applies the outcome of a draft order's
+// payment inside a transaction owned by the caller
+func (s *OrderService) CompleteDraftOrderPaymentInTx(ctx context.Context, tx *gorm.DB, payment *DraftOrderPayment) (*models.DraftOrder, error) {
+	return s.completeDraftOrderPayment(ctx, s.repo.WithTx(tx), payment)
+}
+
+// completeDraftOrderPayment turns the draft paid for into its order, with
+// the captured payment recorded as its transaction, and publishes the
+// order as placed. A failed payment returns the draft to awaiting payment
+// so the customer can try again. Payments for orders that did not start as
+// drafts return ErrDraftOrderNotFound.
+func (s *OrderService) completeDraftOrderPayment(ctx context.Context, repo *repository.OrderRepository, payment *DraftOrderPayment) (*models.DraftOrder, error) {
+	found, err := repo.GetDraftOrderByOrderID(ctx, payment.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrDraftOrderNotFound
+	}
+
+	// The order is numbered in the transaction that creates it
+	var draft *models.DraftOrder
+	err = repo.Transaction(ctx, func(repo *repository.OrderRepository) error {
+		var err error
+		draft, err = repo.UpdateDraftOrder(ctx, found.ID, func(draft *models.DraftOrder) (*repository.DraftOrderChange, error) {
+			if draft.Status != models.DraftOrderStatusPaymentPending {
+				return nil, ErrDraftOrderNotPayable
+			}
+			now := time.Now()
+
+			if !payment.Captured {
+				if err := draft.TransitionTo(models.DraftOrderStatusInvoiceSent, now); err != nil {
+					return nil, err
+				}
+				draft.PaymentError = payment.Reason
+				if draft.PaymentError == "" {
+					draft.PaymentError = "payment failed"
+				}
+				return nil, nil
+			}
+
+			orderNumber, err := repo.NextNumber(ctx, draft.MerchantID, sequence.Order)
+			if err != nil {
+				return nil, err
+			}
+			order := draft.BuildOrder(orderNumber)
+			if err := s.applyTaxes(ctx, order); err != nil {
+				return nil, err
+			}
+			if err := draft.TransitionTo(models.DraftOrderStatusCompleted, now); err != nil {
+				return nil, err
+			}
+			draft.InvoiceTokenHash = ""
+
+			change, err := s.draftOrderUpdated(ctx, draft)
+			if err != nil {
+				return nil, err
+			}
+			placed, err := s.newOrderPlacedOutboxMessage(ctx, order)
+			if err != nil {
+				return nil, err
+			}
+			change.Order = order
+			change.Transaction = &models.Transaction{
+				OrderID:   order.ID,
+				PaymentID: &payment.PaymentID,
+				Kind:      models.TransactionKindSale,
+				Status:    models.TransactionStatusSuccess,
+				Amount:    payment.Amount,
+				Currency:  payment.Currency,
+			}
+			change.Outbox = append(change.Outbox, placed)
+			return change, nil
+		})
+		return err
+	})
+	if err != nil {
+		s.logger.WithError(err).WithField("order_id", payment.OrderID).Error("Failed to complete draft order payment")
+		return nil, err
+	}
+
+	s.logger.WithField("draft_order_id", draft.ID).WithField("status", draft.Status).Info("Draft order payment completed")
+	return draft, nil
+}
+
+// updateDraftOrder changes a draft order of the context's merchant,
+// logging failures to verb it
+func (s *OrderService) updateDraftOrder(ctx context.Context, id uuid.UUID, verb string, update func(draft *models.DraftOrder) (*repository.DraftOrderChange, error)) (*models.DraftOrder, error) {
+	if _, err := s.GetDraftOrder(ctx, id); err != nil {
+		return nil, err
+	}
+
+	draft, err := s.repo.UpdateDraftOrder(ctx, id, update)
+	if err != nil {
+		s.logger.WithError(err).WithField("draft_order_id", id).Errorf("Failed to %s draft order", verb)
+		return nil, err
+	}
+
+	s.logger.WithField("draft_order_id", id).WithField("status", draft.Status).Info("Draft order updated successfully")
+	return draft, nil
+}
+
+// draftLineItems validates and converts requested draft order lines
+func draftLineItems(draftID uuid.UUID, items []DraftOrderLineItemRequest) ([]models.DraftOrderLineItem, error) {
+	lineItems := make([]models.DraftOrderLineItem, 0, len(items))
+	for _, item := range items {
+		if item.AppliedDiscount != nil {
+			if err := item.AppliedDiscount.Validate(); err != nil {
+				return nil, err
+			}
+		}
+		lineItems = append(lineItems, models.DraftOrderLineItem{
+			ID:               uuid.New(),
+			DraftOrderID:     draftID,
+			ProductID:        item.ProductID,
+			ProductVariantID: item.ProductVariantID,
+			Name:             item.Name,
+			SKU:              item.SKU,
+			ProductTitle:     item.ProductTitle,
+			VariantTitle:     item.VariantTitle,
+			Vendor:           item.Vendor,
+			Quantity:         item.Quantity,
+			Price:            item.Price,
+			OriginalPrice:    item.OriginalPrice,
+			AppliedDiscount:  item.AppliedDiscount,
+			Taxable:          item.Taxable == nil || *item.Taxable,
+			RequiresShipping: item.RequiresShipping == nil || *item.RequiresShipping,
+			TaxClass:         item.TaxClass,
+			Properties:       item.Properties,
+		})
+	}
+	return lineItems, nil
+}
+
+// priceDraftOrder applies the draft's discounts and sets its totals to
+// those of the order it would become, taxes included
+func (s *OrderService) priceDraftOrder(ctx context.Context, draft *models.DraftOrder) error {
+	if draft.AppliedDiscount != nil {
+		if err := draft.AppliedDiscount.Validate(); err != nil {
+			return err
+		}
+	}
+
+	draft.ApplyDiscounts()
+	order := draft.BuildOrder("")
+	if err := s.applyTaxes(ctx, order); err != nil {
+		s.logger.WithError(err).Error("Failed to calculate draft order taxes")
+		return err
+	}
+	draft.SetTotals(order)
+	return nil
+}
+
+// newInvoiceToken returns a random payment link token
+func newInvoiceToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate invoice token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashInvoiceToken returns the hash a payment link token is stored as
+func hashInvoiceToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Draft Order Events
+
+// DraftOrderUpdatedEvent is the payload of the draft_order.updated event.
+// Inventory keeps stock reserved under the order ID for as long as the
+// status holds stock and until the draft expires.
+type DraftOrderUpdatedEvent struct {
+	ID          uuid.UUID               `json:"id"`
+	DraftNumber string                  `json:"draft_number"`
+	OrderID     uuid.UUID               `json:"order_id"`
+	MerchantID  uuid.UUID               `json:"merchant_id"`
+	LocationID  *uuid.UUID              `json:"location_id"`
+	Status      models.DraftOrderStatus `json:"status"`
+	ExpiresAt   time.Time               `json:"expires_at"`
+	LineItems   []DraftOrderLineItemDTO `json:"line_items"`
+}
+
+type DraftOrderLineItemDTO struct {
+	ID               uuid.UUID  `json:"id"`
+	ProductID        uuid.UUID  `json:"product_id"`
+	ProductVariantID *uuid.UUID `json:"product_variant_id"`
+	SKU              string     `json:"sku"`
+	Quantity         int        `json:"quantity"`
+}
+
+// DraftOrderInvoiceSentEvent is the payload of the
+// draft_order.invoice_sent event, delivered to the customer by
+// notifications
+type DraftOrderInvoiceSentEvent struct {
+	ID          uuid.UUID  `json:"id"`
+	DraftNumber string     `json:"draft_number"`
+	MerchantID  uuid.UUID  `json:"merchant_id"`
+	CustomerID  *uuid.UUID `json:"customer_id"`
+	Email       string     `json:"email"`
+	InvoiceURL  string     `json:"invoice_url"`
+	Message     string     `json:"message,omitempty"`
+	Currency    string     `json:"currency"`
+	TotalPrice  float64    `json:"total_price"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+}
+
+// DraftOrderPaymentRequestedEvent is the payload of the
+// draft_order.payment_requested event, which asks the payment service to
+// charge the draft's total for its order
+type DraftOrderPaymentRequestedEvent struct {
+	ID            uuid.UUID                `json:"id"`
+	DraftNumber   string                   `json:"draft_number"`
+	OrderID       uuid.UUID                `json:"order_id"`
+	MerchantID    uuid.UUID                `json:"merchant_id"`
+	CustomerID    *uuid.UUID               `json:"customer_id"`
+	Email         string                   `json:"email"`
+	Amount        float64                  `json:"amount"`
+	Currency      string                   `json:"currency"`
+	PaymentMethod *DraftOrderPaymentMethod `json:"payment_method"`
+}
+
+// draftOrderUpdated returns a change publishing the draft's new state
+func (s *OrderService) draftOrderUpdated(ctx context.Context, draft *models.DraftOrder) (*repository.DraftOrderChange, error) {
+	msg, err := s.newDraftOrderUpdatedOutboxMessage(ctx, draft)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.DraftOrderChange{Outbox: []*messaging.OutboxMessage{msg}}, nil
+}
+
+// newDraftOrderUpdatedOutboxMessage builds the draft_order.updated event.
+// Events are keyed by order ID, as the stock they reserve is.
+func (s *OrderService) newDraftOrderUpdatedOutboxMessage(ctx context.Context, draft *models.DraftOrder) (*messaging.OutboxMessage, error) {
+	event := DraftOrderUpdatedEvent{
+		ID:          draft.ID,
+		DraftNumber: draft.DraftNumber,
+		OrderID:     draft.OrderID,
+		MerchantID:  draft.MerchantID,
+		LocationID:  draft.LocationID,
+		Status:      draft.Status,
+		ExpiresAt:   draft.ExpiresAt,
+		LineItems:   make([]DraftOrderLineItemDTO, 0, len(draft.LineItems)),
+	}
+	for _, lineItem := range draft.LineItems {
+		event.LineItems = append(event.LineItems, DraftOrderLineItemDTO{
+			ID:               lineItem.ID,
+			ProductID:        lineItem.ProductID,
+			ProductVariantID: lineItem.ProductVariantID,
+			SKU:              lineItem.SKU,
+			Quantity:         lineItem.Quantity,
+		})
+	}
+
+	env, err := messaging.NewEnvelope(ctx, messaging.EventTypeDraftOrderUpdated, 1, draft.MerchantID.String(), event)
+	if err != nil {
+		return nil, err
+	}
+	return messaging.NewEnvelopeOutboxMessage(s.schemas, "draft_order", draft.ID.String(), "draft_orders.updated", draft.OrderID.String(), env)
+}
+
+// newDraftOrderInvoiceSentOutboxMessage builds the draft_order.invoice_sent
+// event
+func (s *OrderService) newDraftOrderInvoiceSentOutboxMessage(ctx context.Context, draft *models.DraftOrder, invoiceURL, message string) (*messaging.OutboxMessage, error) {
+	event := DraftOrderInvoiceSentEvent{
+		ID:          draft.ID,
+		DraftNumber: draft.DraftNumber,
+		MerchantID:  draft.MerchantID,
+		CustomerID:  draft.CustomerID,
+		Email:       draft.InvoiceSentTo,
+		InvoiceURL:  invoiceURL,
+		Message:     message,
+		Currency:    draft.Currency,
+		TotalPrice:  draft.TotalPrice,
+		ExpiresAt:   draft.ExpiresAt,
+	}
+
+	env, err := messaging.NewEnvelope(ctx, messaging.EventTypeDraftOrderInvoiceSent, 1, draft.MerchantID.String(), event)
+	if err != nil {
+		return nil, err
+	}
+	return messaging.NewEnvelopeOutboxMessage(s.schemas, "draft_order", draft.ID.String(), "draft_orders.invoice_sent", draft.OrderID.String(), env)
+}
+
+// newDraftOrderPaymentRequestedOutboxMessage builds the
+// draft_order.payment_requested event
+func (s *OrderService) newDraftOrderPaymentRequestedOutboxMessage(ctx context.Context, draft *models.DraftOrder, paymentMethod *DraftOrderPaymentMethod) (*messaging.OutboxMessage, error) {
+	event := DraftOrderPaymentRequestedEvent{
+		ID:            draft.ID,
+		DraftNumber:   draft.DraftNumber,
+		OrderID:       draft.OrderID,
+		MerchantID:    draft.MerchantID,
+		CustomerID:    draft.CustomerID,
+		Email:         draft.InvoiceSentTo,
+		Amount:        draft.TotalPrice,
+		Currency:      draft.Currency,
+		PaymentMethod: paymentMethod,
+	}
+
+	env, err := messaging.NewEnvelope(ctx, messaging.EventTypeDraftOrderPaymentRequested, 1, draft.MerchantID.String(), event)
+	if err != nil {
+		return nil, err
+	}
+	return messaging.NewEnvelopeOutboxMessage(s.schemas, "draft_order", draft.ID.String(), "draft_orders.payment_requested", draft.OrderID.String(), env)
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/order/repository"
+	"unified-commerce/services/shared/sequence"
+)
+
+// Number Formats
+
+// SaveNumberFormats replaces the formats a merchant's orders, draft orders
+// and returns are numbered with. Sequences without a format use
+// sequence.Defaults.
+func (s *OrderService) SaveNumberFormats(ctx context.Context, merchantID uuid.UUID, formats map[string]sequence.Format) error {
+	return s.saveNumberFormats(ctx, s.repo, merchantID, formats)
+}
+
+// SaveNumberFormatsInTx replaces a merchant's number formats inside a
+// transaction owned by the caller
+func (s *OrderService) SaveNumberFormatsInTx(ctx context.Context, tx *gorm.DB, merchantID uuid.UUID, formats map[string]sequence.Format) error {
+	return s.saveNumberFormats(ctx, s.repo.WithTx(tx), merchantID, formats)
+}
+
+func (s *OrderService) saveNumberFormats(ctx context.Context, repo *repository.OrderRepository, merchantID uuid.UUID, formats map[string]sequence.Format) error {
+	if err := repo.SaveNumberFormats(ctx, merchantID, formats); err != nil {
+		s.logger.WithError(err).WithField("merchant_id", merchantID).Error("Failed to save number formats")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/order/models"
+	"unified-commerce/services/order/repository"
+	"unified-commerce/shared/messaging"
+)
+
+// Payment Ledger
+
+// PaymentTransaction is an authorization, capture or void of an order's
+// payment, reported by the payment service
+type PaymentTransaction struct {
+	PaymentID uuid.UUID
+	OrderID   uuid.UUID
+	Kind      models.TransactionKind
+	Amount    float64
+	Currency  string
+}
+
+// RecordPaymentTransaction records a payment transaction on its order
+func (s *OrderService) RecordPaymentTransaction(ctx context.Context, payment *PaymentTransaction) error {
+	return s.recordPaymentTransaction(ctx, s.repo, payment)
+}
+
+// RecordPaymentTransactionInTx records a payment transaction on its order
+// inside a transaction owned by the caller
+func (s *OrderService) RecordPaymentTransactionInTx(ctx context.Context, tx *gorm.DB, payment *PaymentTransaction) error {
+	return s.recordPaymentTransaction(ctx, s.repo.WithTx(tx), payment)
+}
+
+// recordPaymentTransaction adds a successful payment transaction to its
+// order's ledger, which the order's payment status is derived from
+func (s *OrderService) recordPaymentTransaction(ctx context.Context, repo *repository.OrderRepository, payment *PaymentTransaction) error {
+	order, err := repo.GetOrder(ctx, payment.OrderID)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		return ErrOrderNotFound
+	}
+
+	currency := payment.Currency
+	if currency == "" {
+		currency = order.Currency
+	}
+	paymentID := payment.PaymentID
+	transaction := &models.Transaction{
+		OrderID:   order.ID,
+		PaymentID: &paymentID,
+		Kind:      payment.Kind,
+		Status:    models.TransactionStatusSuccess,
+		Amount:    payment.Amount,
+		Currency:  currency,
+	}
+	if err := repo.CreateTransaction(ctx, transaction); err != nil {
+		s.logger.WithError(err).WithField("order_id", order.ID).Error("Failed to record payment transaction")
+		return err
+	}
+
+	s.logger.WithField("order_id", order.ID).WithField("payment_id", payment.PaymentID).WithField("kind", payment.Kind).Info("Payment transaction recorded")
+	return nil
+}
+
+// OrderCaptureRequestedEvent is the payload of the order.capture_requested
+// event, which asks the payment service to capture an order's authorized
+// payment. A nil amount captures all that is left of the authorization.
+type OrderCaptureRequestedEvent struct {
+	OrderID    uuid.UUID `json:"order_id"`
+	MerchantID uuid.UUID `json:"merchant_id"`
+	Amount     *float64  `json:"amount"`
+	Currency   string    `json:"currency"`
+}
+
+// RequestPaymentCapture asks the payment service to capture an amount of an
+// order's authorized payment, or all that is left of it when amount is nil.
+// The capture is recorded on the order once the payment service reports it.
+func (s *OrderService) RequestPaymentCapture(ctx context.Context, orderID uuid.UUID, amount *float64, userID *uuid.UUID) (*models.Order, error) {
+	order, err := s.repo.GetOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+
+	capturable := models.CapturableAmount(order.Transactions)
+	if capturable <= 0 {
+		return nil, ErrNothingToCapture
+	}
+	requested := capturable
+	if amount != nil {
+		if *amount <= 0 || *amount > capturable {
+			return nil, ErrInvalidCaptureAmount
+		}
+		requested = *amount
+	}
+
+	env, err := messaging.NewEnvelope(ctx, messaging.EventTypeOrderCaptureRequested, 1, order.MerchantID.String(), OrderCaptureRequestedEvent{
+		OrderID:    order.ID,
+		MerchantID: order.MerchantID,
+		Amount:     amount,
+		Currency:   order.Currency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	msg, err := messaging.NewEnvelopeOutboxMessage(s.schemas, "order", order.ID.String(), "orders.capture_requested", order.ID.String(), env)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &models.OrderEvent{
+		OrderID:     order.ID,
+		EventType:   models.OrderEventCaptureRequested,
+		Description: fmt.Sprintf("Capture of %.2f %s requested", requested, order.Currency),
+		UserID:      userID,
+	}
+	if err := s.repo.CreateOrderEvent(ctx, event, msg); err != nil {
+		s.logger.WithError(err).WithField("order_id", order.ID).Error("Failed to request payment capture")
+		return nil, err
+	}
+
+	s.logger.WithField("order_id", order.ID).WithField("amount", requested).Info("Payment capture requested")
+	return order, nil
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/order/models"
+	"unified-commerce/services/order/repository"
+)
+
+// Risk Review
+
+// assessOrder scores a new order with the merchant's history of its
+// customer. High-risk orders are held for review, returning the event that
+// records the hold.
+func (s *OrderService) assessOrder(ctx context.Context, repo *repository.OrderRepository, order *models.Order) (*models.RiskAssessment, *models.OrderEvent, error) {
+	history, err := repo.GetRiskHistory(ctx, order, s.risk.HistoryQuery(time.Now()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	assessment := s.risk.Assess(order, history)
+	assessment.ReviewStatus = models.RiskReviewNotRequired
+	order.RiskLevel = assessment.Level
+	if assessment.Level != models.RiskLevelHigh {
+		return assessment, nil, nil
+	}
+
+	assessment.ReviewStatus = models.RiskReviewPending
+	order.HeldForReview = true
+	return assessment, riskHeldEvent(order.ID, assessment), nil
+}
+
+// riskHeldEvent records an order being held for review on its timeline
+func riskHeldEvent(orderID uuid.UUID, assessment *models.RiskAssessment) *models.OrderEvent {
+	reasons := make([]string, 0, len(assessment.Signals))
+	for _, signal := range assessment.Signals {
+		reasons = append(reasons, signal.Description)
+	}
+	return &models.OrderEvent{
+		OrderID:     orderID,
+		EventType:   models.OrderEventRiskHeld,
+		Description: fmt.Sprintf("Order held for review with risk score %d: %s", assessment.Score, strings.Join(reasons, "; ")),
+	}
+}
+
+// GetRiskAssessment retrieves an order's risk assessment
+func (s *OrderService) GetRiskAssessment(ctx context.Context, orderID uuid.UUID) (*models.RiskAssessment, error) {
+	assessment, err := s.repo.GetRiskAssessment(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if assessment == nil {
+		return nil, ErrRiskAssessmentNotFound
+	}
+	return assessment, nil
+}
+
+// GetRiskReviews retrieves a merchant's risk assessments in a review status
+// with their orders, oldest first. Pending reviews make up the review
+// queue.
+func (s *OrderService) GetRiskReviews(ctx context.Context, merchantID uuid.UUID, status models.RiskReviewStatus, page, limit int) ([]*models.RiskAssessment, int64, error) {
+	offset := (page - 1) * limit
+	return s.repo.GetRiskReviews(ctx, merchantID, status, limit, offset)
+}
+
+// ApproveRiskReview releases an order held for review so it can be
+// confirmed
+func (s *OrderService) ApproveRiskReview(ctx context.Context, orderID uuid.UUID, note string, userID *uuid.UUID) (*models.RiskAssessment, error) {
+	return s.reviewRisk(ctx, orderID, "approve", func(assessment *models.RiskAssessment, order *models.Order) (*repository.RiskChange, error) {
+		if err := assessment.Review(models.RiskReviewApproved, order, note, userID, time.Now()); err != nil {
+			return nil, err
+		}
+
+		description := "Order approved after risk review"
+		if note != "" {
+			description = fmt.Sprintf("%s: %s", description, note)
+		}
+		return &repository.RiskChange{
+			Event: &models.OrderEvent{
+				OrderID:     order.ID,
+				EventType:   models.OrderEventRiskApproved,
+				Description: description,
+				UserID:      userID,
+			},
+		}, nil
+	})
+}
+
+// CancelRiskReview cancels an order held for review
+func (s *OrderService) CancelRiskReview(ctx context.Context, orderID uuid.UUID, reason string, userID *uuid.UUID) (*models.RiskAssessment, error) {
+	return s.reviewRisk(ctx, orderID, "cancel", func(assessment *models.RiskAssessment, order *models.Order) (*repository.RiskChange, error) {
+		if err := assessment.Review(models.RiskReviewCancelled, order, reason, userID, time.Now()); err != nil {
+			return nil, err
+		}
+		return &repository.RiskChange{
+			Cancel:      true,
+			Description: fmt.Sprintf("Order cancelled after risk review: %s", reason),
+			UserID:      userID,
+		}, nil
+	})
+}
+
+// reviewRisk applies a review decision to an order's risk assessment,
+// logging the outcome with the decision's verb
+func (s *OrderService) reviewRisk(ctx context.Context, orderID uuid.UUID, verb string, update func(assessment *models.RiskAssessment, order *models.Order) (*repository.RiskChange, error)) (*models.RiskAssessment, error) {
+	if _, err := s.GetRiskAssessment(ctx, orderID); err != nil {
+		return nil, err
+	}
+
+	assessment, err := s.repo.UpdateRiskAssessment(ctx, orderID, update)
+	if err != nil {
+		s.logger.WithError(err).WithField("order_id", orderID).Errorf("Failed to %s risk review", verb)
+		return nil, err
+	}
+
+	s.logger.WithField("order_id", orderID).WithField("review_status", assessment.ReviewStatus).Info("Risk review closed successfully")
+	return assessment, nil
+}
+
+// PaymentFailure is a failed attempt to pay for an order, reported by the
+// payment service
+type PaymentFailure struct {
+	PaymentID uuid.UUID
+	OrderID   uuid.UUID
+	Amount    float64
+	Currency  string
+	Reason    string
+}
+
+// RecordPaymentFailure records a failed payment attempt on its order
+func (s *OrderService) RecordPaymentFailure(ctx context.Context, failure *PaymentFailure) error {
+	return s.recordPaymentFailure(ctx, s.repo, failure)
+}
+
+// RecordPaymentFailureInTx records a failed payment attempt inside a
+// transaction owned by the caller
+func (s *OrderService) RecordPaymentFailureInTx(ctx context.Context, tx *gorm.DB, failure *PaymentFailure) error {
+	return s.recordPaymentFailure(ctx, s.repo.WithTx(tx), failure)
+}
+
+// recordPaymentFailure records a failed payment as a transaction of its
+// order and rescores the order while it is pending, holding it for review
+// once its failures make it high risk. Orders scored before risk
+// assessments existed are not rescored.
+func (s *OrderService) recordPaymentFailure(ctx context.Context, repo *repository.OrderRepository, failure *PaymentFailure) error {
+	order, err := repo.GetOrder(ctx, failure.OrderID)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		return ErrOrderNotFound
+	}
+
+	currency := failure.Currency
+	if currency == "" {
+		currency = order.Currency
+	}
+	paymentID := failure.PaymentID
+	transaction := &models.Transaction{
+		OrderID:   order.ID,
+		PaymentID: &paymentID,
+		Kind:      models.TransactionKindSale,
+		Status:    models.TransactionStatusFailure,
+		Amount:    failure.Amount,
+		Currency:  currency,
+	}
+
+	err = repo.Transaction(ctx, func(repo *repository.OrderRepository) error {
+		if err := repo.CreateTransaction(ctx, transaction); err != nil {
+			return err
+		}
+		if order.Status != models.OrderStatusPending {
+			return nil
+		}
+
+		_, err := repo.UpdateRiskAssessment(ctx, order.ID, func(assessment *models.RiskAssessment, order *models.Order) (*repository.RiskChange, error) {
+			history, err := repo.GetRiskHistory(ctx, order, s.risk.HistoryQuery(order.CreatedAt))
+			if err != nil {
+				return nil, err
+			}
+			if !assessment.Rescore(s.risk.Assess(order, history), order) {
+				return nil, nil
+			}
+			return &repository.RiskChange{Event: riskHeldEvent(order.ID, assessment)}, nil
+		})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		s.logger.WithError(err).WithField("order_id", order.ID).Error("Failed to record payment failure")
+		return err
+	}
+
+	s.logger.WithField("order_id", order.ID).WithField("payment_id", failure.PaymentID).WithField("reason", failure.Reason).Info("Payment failure recorded")
+	return nil
+}
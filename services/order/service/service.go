@@ -2,47 +2,93 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"math/rand"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 
+	"unified-commerce/services/order/carrier"
 	"unified-commerce/services/order/models"
 	"unified-commerce/services/order/repository"
+	"unified-commerce/services/order/risk"
 	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/sequence"
+	"unified-commerce/services/shared/tax"
 	"unified-commerce/shared/messaging"
 )
 
 // Service errors
 var (
-	ErrOrderNotFound         = errors.New("order not found")
-	ErrLineItemNotFound      = errors.New("line item not found")
-	ErrFulfillmentNotFound   = errors.New("fulfillment not found")
-	ErrTransactionNotFound   = errors.New("transaction not found")
-	ErrReturnNotFound        = errors.New("return not found")
-	ErrInvalidStatus         = errors.New("invalid status transition")
-	ErrInsufficientPayment   = errors.New("insufficient payment")
-	ErrOrderAlreadyCancelled = errors.New("order already cancelled")
-	ErrOrderNotCancellable   = errors.New("order cannot be cancelled")
-	ErrInvalidQuantity       = errors.New("invalid quantity")
+	ErrOrderNotFound          = errors.New("order not found")
+	ErrLineItemNotFound       = errors.New("line item not found")
+	ErrFulfillmentNotFound    = errors.New("fulfillment not found")
+	ErrTransactionNotFound    = errors.New("transaction not found")
+	ErrReturnNotFound         = errors.New("return not found")
+	ErrInvalidStatus          = models.ErrInvalidTransition
+	ErrInsufficientPayment    = errors.New("insufficient payment")
+	ErrOrderAlreadyCancelled  = errors.New("order already cancelled")
+	ErrOrderNotCancellable    = errors.New("order cannot be cancelled")
+	ErrInvalidQuantity        = errors.New("invalid quantity")
+	ErrOrderEditNotFound      = errors.New("order edit not found")
+	ErrOrderEditEmpty         = errors.New("order edit has no changes")
+	ErrOrderNotEditable       = models.ErrOrderNotEditable
+	ErrOrderEditClosed        = models.ErrOrderEditClosed
+	ErrLineItemNotInOrder     = models.ErrLineItemNotInOrder
+	ErrInvalidOrderEdit       = models.ErrInvalidOrderEdit
+	ErrQuantityFulfilled      = models.ErrQuantityBelowFulfilled
+	ErrReturnNotEditable      = models.ErrReturnNotEditable
+	ErrReturnQuantity         = models.ErrReturnQuantityExceeded
+	ErrReturnLineItem         = models.ErrReturnLineItemNotFound
+	ErrInvalidReturnReceipt   = models.ErrInvalidReturnReceipt
+	ErrInvalidOrderQuery      = models.ErrInvalidOrderQuery
+	ErrInvalidCursor          = models.ErrInvalidCursor
+	ErrDraftOrderNotFound     = errors.New("draft order not found")
+	ErrDraftOrderNotEditable  = models.ErrDraftOrderNotEditable
+	ErrDraftOrderNotPayable   = models.ErrDraftOrderNotPayable
+	ErrDraftOrderExpired      = models.ErrDraftOrderExpired
+	ErrInvalidDiscount        = models.ErrInvalidDiscount
+	ErrNoInvoiceRecipient     = errors.New("draft order has no email to send the invoice to")
+	ErrUnknownCarrier         = carrier.ErrUnknownCarrier
+	ErrInvalidWebhook         = carrier.ErrInvalidPayload
+	ErrWebhookSignature       = carrier.ErrInvalidSignature
+	ErrTrackingCredentials    = errors.New("an email or tracking token is required to track an order")
+	ErrTrackingTokenDisabled  = errors.New("tracking tokens are not configured")
+	ErrRiskAssessmentNotFound = errors.New("risk assessment not found")
+	ErrRiskReviewClosed       = models.ErrRiskReviewClosed
+	ErrOrderHeldForReview     = models.ErrOrderHeldForReview
+	ErrNothingToCapture       = errors.New("order has no authorized payment left to capture")
+	ErrInvalidCaptureAmount   = errors.New("capture amount must be positive and within the authorized amount")
 )
 
 // OrderService handles business logic for order management
 type OrderService struct {
-	repo     *repository.OrderRepository
-	logger   *logger.Logger
-	producer messaging.EventProducer
+	repo           *repository.OrderRepository
+	logger         *logger.Logger
+	schemas        *messaging.SchemaRegistry
+	taxes          tax.Calculator
+	invoiceURL     string
+	carriers       *carrier.Registry
+	trackingSecret []byte
+	risk           *risk.Engine
 }
 
-// NewOrderService creates a new order service
-func NewOrderService(repo *repository.OrderRepository, logger *logger.Logger, producer messaging.EventProducer) *OrderService {
+// NewOrderService creates a new order service. Draft order payment links
+// are the invoice token appended to invoiceURL. Shipments are tracked with
+// the carriers, and tracking tokens are signed with trackingSecret; orders
+// can only be tracked by email when it is empty. New orders are scored by
+// the risk engine.
+func NewOrderService(repo *repository.OrderRepository, logger *logger.Logger, schemas *messaging.SchemaRegistry, taxes tax.Calculator, invoiceURL string, carriers *carrier.Registry, trackingSecret string, riskEngine *risk.Engine) *OrderService {
 	return &OrderService{
-		repo:     repo,
-		logger:   logger,
-		producer: producer,
+		repo:           repo,
+		logger:         logger,
+		schemas:        schemas,
+		taxes:          taxes,
+		invoiceURL:     invoiceURL,
+		carriers:       carriers,
+		trackingSecret: []byte(trackingSecret),
+		risk:           riskEngine,
 	}
 }
 
@@ -50,7 +96,7 @@ func NewOrderService(repo *repository.OrderRepository, logger *logger.Logger, pr
 
 // CreateOrderRequest represents a request to create an order
 type CreateOrderRequest struct {
-	MerchantID      uuid.UUID               `json:"merchant_id" validate:"required"`
+	MerchantID      uuid.UUID               `json:"-" validate:"required"`
 	CustomerID      *uuid.UUID              `json:"customer_id"`
 	LocationID      *uuid.UUID              `json:"location_id"`
 	Customer        models.CustomerInfo     `json:"customer" validate:"required"`
@@ -59,11 +105,13 @@ type CreateOrderRequest struct {
 	LineItems       []CreateLineItemRequest `json:"line_items" validate:"required,min=1"`
 	ShippingMethod  string                  `json:"shipping_method"`
 	ShippingRate    float64                 `json:"shipping_rate"`
+	TaxesIncluded   bool                    `json:"taxes_included"`
 	Currency        string                  `json:"currency"`
 	Source          models.OrderSource      `json:"source"`
 	Channel         string                  `json:"channel"`
 	Tags            []string                `json:"tags"`
 	Notes           string                  `json:"notes"`
+	BrowserIP       string                  `json:"-"` // the client address the server observed
 }
 
 // CreateLineItemRequest represents a line item in an order creation request
@@ -78,19 +126,18 @@ type CreateLineItemRequest struct {
 	ProductTitle     string            `json:"product_title"`
 	VariantTitle     string            `json:"variant_title"`
 	Vendor           string            `json:"vendor"`
-	Taxable          bool              `json:"taxable"`
+	Taxable          *bool             `json:"taxable"` // defaults to true
+	TaxClass         string            `json:"tax_class"`
 	RequiresShipping bool              `json:"requires_shipping"`
 	Properties       map[string]string `json:"properties"`
 }
 
 // CreateOrder creates a new order
 func (s *OrderService) CreateOrder(ctx context.Context, req *CreateOrderRequest) (*models.Order, error) {
-	// Generate order number
-	orderNumber := s.generateOrderNumber()
-
-	// Create order
+	// Create order. IDs are assigned up front so the OrderPlaced event can be
+	// built before the order is persisted.
 	order := &models.Order{
-		OrderNumber:       orderNumber,
+		ID:                uuid.New(),
 		MerchantID:        req.MerchantID,
 		CustomerID:        req.CustomerID,
 		LocationID:        req.LocationID,
@@ -102,11 +149,13 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 		ShippingAddress:   req.ShippingAddress,
 		ShippingMethod:    req.ShippingMethod,
 		ShippingRate:      req.ShippingRate,
+		TaxesIncluded:     req.TaxesIncluded,
 		Source:            req.Source,
 		Channel:           req.Channel,
 		Currency:          req.Currency,
 		Tags:              req.Tags,
 		Notes:             req.Notes,
+		BrowserIP:         req.BrowserIP,
 	}
 
 	if order.Currency == "" {
@@ -123,6 +172,7 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 		subtotal += linePrice
 
 		lineItem := models.OrderLineItem{
+			ID:                uuid.New(),
 			OrderID:           order.ID,
 			ProductID:         item.ProductID,
 			ProductVariantID:  item.ProductVariantID,
@@ -135,7 +185,8 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 			Price:             item.Price,
 			CompareAtPrice:    item.CompareAtPrice,
 			LinePrice:         linePrice,
-			Taxable:           item.Taxable,
+			Taxable:           item.Taxable == nil || *item.Taxable,
+			TaxClass:          item.TaxClass,
 			RequiresShipping:  item.RequiresShipping,
 			Properties:        item.Properties,
 			FulfillmentStatus: models.FulfillmentStatusUnfulfilled,
@@ -143,48 +194,56 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *CreateOrderRequest)
 		order.LineItems = append(order.LineItems, lineItem)
 	}
 
-	// Calculate totals (simplified - real implementation would include tax calculation)
+	// Calculate taxes and totals
 	order.SubtotalPrice = subtotal
 	order.TotalShipping = req.ShippingRate
-	order.TotalPrice = subtotal + req.ShippingRate
-
-	if err := s.repo.CreateOrder(ctx, order); err != nil {
-		s.logger.WithError(err).Error("Failed to create order")
+	if err := s.applyTaxes(ctx, order); err != nil {
+		s.logger.WithError(err).Error("Failed to calculate order taxes")
 		return nil, err
 	}
 
-	// Publish OrderPlaced event
-	if err := s.publishOrderPlacedEvent(ctx, order); err != nil {
-		// Log the error but don't fail the transaction.
-		// This is a common pattern in event-driven systems to ensure the core operation (creating the order) succeeds
-		// even if the event notification fails. The system should have a separate mechanism to handle failed events.
-		s.logger.WithError(err).WithField("order_id", order.ID).Error("Failed to publish OrderPlaced event")
-	}
-
-	s.logger.WithField("order_id", order.ID).WithField("order_number", order.OrderNumber).Info("Order created successfully")
-	return order, nil
-}
+	// Number and score the order and build the OrderPlaced event so all
+	// are stored with the order
+	err := s.repo.Transaction(ctx, func(repo *repository.OrderRepository) error {
+		orderNumber, err := repo.NextNumber(ctx, order.MerchantID, sequence.Order)
+		if err != nil {
+			return err
+		}
+		order.OrderNumber = orderNumber
 
-// publishOrderPlacedEvent serializes the order and publishes it to Kafka.
-func (s *OrderService) publishOrderPlacedEvent(ctx context.Context, order *models.Order) error {
-	s.logger.WithField("order_id", order.ID).Info("Publishing OrderPlaced event")
+		assessment, heldEvent, err := s.assessOrder(ctx, repo, order)
+		if err != nil {
+			return err
+		}
 
-	// For simplicity, we'll just use a basic JSON serialization.
-	// In a real-world scenario, you might use a more robust serialization format like Avro or Protobuf
-	// with a schema registry.
-	eventPayload, err := json.Marshal(order)
+		placedEvent, err := s.newOrderPlacedOutboxMessage(ctx, order)
+		if err != nil {
+			return err
+		}
+		if err := repo.CreateOrder(ctx, order, placedEvent); err != nil {
+			return err
+		}
+		return repo.CreateRiskAssessment(ctx, assessment, heldEvent)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to marshal order for event: %w", err)
+		s.logger.WithError(err).Error("Failed to create order")
+		return nil, err
 	}
 
-	topic := "orders.placed"
-	err = s.producer.Publish(topic, order.ID.String(), eventPayload)
+	s.logger.WithField("order_id", order.ID).WithField("order_number", order.OrderNumber).WithField("risk_level", order.RiskLevel).Info("Order created successfully")
+	return order, nil
+}
+
+// newOrderPlacedOutboxMessage wraps the order in a versioned event envelope
+// and validates it against the registered schema. The outbox relay publishes
+// it once the order transaction has committed.
+func (s *OrderService) newOrderPlacedOutboxMessage(ctx context.Context, order *models.Order) (*messaging.OutboxMessage, error) {
+	env, err := messaging.NewEnvelope(ctx, messaging.EventTypeOrderPlaced, 1, order.MerchantID.String(), order)
 	if err != nil {
-		return fmt.Errorf("failed to publish OrderPlaced event to kafka: %w", err)
+		return nil, err
 	}
 
-	s.logger.WithField("topic", topic).WithField("order_id", order.ID).Info("Successfully published OrderPlaced event")
-	return nil
+	return messaging.NewEnvelopeOutboxMessage(s.schemas, "order", order.ID.String(), "orders.placed", order.ID.String(), env)
 }
 
 // GetOrder retrieves an order by ID
@@ -211,10 +270,76 @@ func (s *OrderService) GetOrderByNumber(ctx context.Context, orderNumber string)
 	return order, nil
 }
 
-// GetOrdersByMerchant retrieves orders for a merchant
-func (s *OrderService) GetOrdersByMerchant(ctx context.Context, merchantID uuid.UUID, filters map[string]interface{}, page, limit int) ([]*models.Order, int64, error) {
-	offset := (page - 1) * limit
-	return s.repo.GetOrdersByMerchant(ctx, merchantID, filters, limit, offset)
+// Order search page sizes
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// SearchOrders returns a page of up to limit of a merchant's orders
+// matching the query, continuing after the cursor when one is given. Limits
+// out of range fall back to the default page size.
+func (s *OrderService) SearchOrders(ctx context.Context, merchantID uuid.UUID, query *models.OrderQuery, after string, limit int) (*models.OrderPage, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > MaxSearchLimit {
+		limit = DefaultSearchLimit
+	}
+
+	var cursor *models.OrderCursor
+	if after != "" {
+		var err error
+		if cursor, err = models.DecodeOrderCursor(after); err != nil {
+			return nil, err
+		}
+	}
+
+	// One extra order tells whether there is another page
+	orders, err := s.repo.SearchOrders(ctx, merchantID, query, cursor, limit+1)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.OrderPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		page.HasMore = true
+		page.NextCursor = models.CursorFor(page.Orders[limit-1]).Encode()
+	}
+	return page, nil
+}
+
+// exportBatchSize is the number of orders read at a time while exporting
+const exportBatchSize = 500
+
+// ExportOrders passes every one of a merchant's orders matching the query to
+// write, newest first, in batches. Orders are read a batch at a time so
+// exports of any size run in constant memory. The export stops at the first
+// error from write.
+func (s *OrderService) ExportOrders(ctx context.Context, merchantID uuid.UUID, query *models.OrderQuery, write func([]*models.Order) error) error {
+	if err := query.Validate(); err != nil {
+		return err
+	}
+
+	var cursor *models.OrderCursor
+	for {
+		orders, err := s.repo.SearchOrders(ctx, merchantID, query, cursor, exportBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(orders) > 0 {
+			if err := write(orders); err != nil {
+				return err
+			}
+		}
+		if len(orders) < exportBatchSize {
+			return nil
+		}
+
+		next := models.CursorFor(orders[len(orders)-1])
+		cursor = &next
+	}
 }
 
 // GetOrdersByCustomer retrieves orders for a customer
@@ -264,23 +389,12 @@ func (s *OrderService) ConfirmOrder(ctx context.Context, id uuid.UUID, userID *u
 		return ErrOrderNotFound
 	}
 
-	if order.Status != models.OrderStatusPending {
-		return ErrInvalidStatus
-	}
-
-	now := time.Now()
+	// The state machine only confirms pending orders and stamps ProcessedAt
 	if err := s.repo.UpdateOrderStatus(ctx, id, models.OrderStatusConfirmed, "Order confirmed", userID); err != nil {
 		s.logger.WithError(err).Error("Failed to confirm order")
 		return err
 	}
 
-	// Update processed timestamp
-	order.ProcessedAt = &now
-	if err := s.repo.UpdateOrder(ctx, order); err != nil {
-		s.logger.WithError(err).Error("Failed to update order processed time")
-		return err
-	}
-
 	s.logger.WithField("order_id", id).Info("Order confirmed successfully")
 	return nil
 }
@@ -303,6 +417,12 @@ func (s *OrderService) CancelOrder(ctx context.Context, id uuid.UUID, reason str
 		return ErrOrderNotCancellable
 	}
 
+	// Cancelling a held order closes its review
+	if order.HeldForReview {
+		_, err := s.CancelRiskReview(ctx, id, reason, userID)
+		return err
+	}
+
 	if err := s.repo.CancelOrder(ctx, id, reason, userID); err != nil {
 		s.logger.WithError(err).Error("Failed to cancel order")
 		return err
@@ -322,34 +442,18 @@ type AddLineItemRequest struct {
 	Name             string            `json:"name" validate:"required"`
 	Quantity         int               `json:"quantity" validate:"required,min=1"`
 	Price            float64           `json:"price" validate:"required,min=0"`
+	Taxable          *bool             `json:"taxable"` // defaults to true
+	TaxClass         string            `json:"tax_class"`
+	RequiresShipping *bool             `json:"requires_shipping"` // defaults to true
 	Properties       map[string]string `json:"properties"`
 }
 
-// AddLineItem adds a line item to an order
+// AddLineItem adds a line item to an order. Like every direct line item
+// change it is committed as an order edit of its own.
 func (s *OrderService) AddLineItem(ctx context.Context, orderID uuid.UUID, req *AddLineItemRequest) (*models.OrderLineItem, error) {
-	order, err := s.repo.GetOrder(ctx, orderID)
+	change := newAddLineItemChange(req)
+	lineItem, err := s.applyOrderChange(ctx, orderID, change)
 	if err != nil {
-		return nil, err
-	}
-	if order == nil {
-		return nil, ErrOrderNotFound
-	}
-
-	linePrice := float64(req.Quantity) * req.Price
-	lineItem := &models.OrderLineItem{
-		OrderID:           orderID,
-		ProductID:         req.ProductID,
-		ProductVariantID:  req.ProductVariantID,
-		Name:              req.Name,
-		SKU:               req.SKU,
-		Quantity:          req.Quantity,
-		Price:             req.Price,
-		LinePrice:         linePrice,
-		Properties:        req.Properties,
-		FulfillmentStatus: models.FulfillmentStatusUnfulfilled,
-	}
-
-	if err := s.repo.AddLineItem(ctx, lineItem); err != nil {
 		s.logger.WithError(err).Error("Failed to add line item")
 		return nil, err
 	}
@@ -358,42 +462,31 @@ func (s *OrderService) AddLineItem(ctx context.Context, orderID uuid.UUID, req *
 	return lineItem, nil
 }
 
-// UpdateLineItemRequest represents a request to update a line item
+// UpdateLineItemRequest represents a request to update a line item. Omitted
+// fields are left unchanged.
 type UpdateLineItemRequest struct {
-	Quantity int     `json:"quantity" validate:"min=1"`
-	Price    float64 `json:"price" validate:"min=0"`
+	Quantity int      `json:"quantity" validate:"omitempty,min=1"`
+	Price    *float64 `json:"price" validate:"omitempty,min=0"`
 }
 
-// UpdateLineItem updates a line item
+// UpdateLineItem updates a line item's quantity or price
 func (s *OrderService) UpdateLineItem(ctx context.Context, lineItemID uuid.UUID, req *UpdateLineItemRequest) (*models.OrderLineItem, error) {
-	// Get current line item
-	order, err := s.repo.GetOrder(ctx, uuid.Nil) // This would need proper implementation to get line item
+	existing, err := s.repo.GetLineItem(ctx, lineItemID)
 	if err != nil {
 		return nil, err
 	}
-
-	var lineItem *models.OrderLineItem
-	for i := range order.LineItems {
-		if order.LineItems[i].ID == lineItemID {
-			lineItem = &order.LineItems[i]
-			break
-		}
-	}
-
-	if lineItem == nil {
+	if existing == nil {
 		return nil, ErrLineItemNotFound
 	}
 
-	// Update fields
-	if req.Quantity > 0 {
-		lineItem.Quantity = req.Quantity
-	}
-	if req.Price >= 0 {
-		lineItem.Price = req.Price
+	change := models.OrderEditChange{
+		Type:       models.OrderEditChangeUpdateLineItem,
+		LineItemID: lineItemID,
+		Quantity:   req.Quantity,
+		Price:      req.Price,
 	}
-	lineItem.LinePrice = float64(lineItem.Quantity) * lineItem.Price
-
-	if err := s.repo.UpdateLineItem(ctx, lineItem); err != nil {
+	lineItem, err := s.applyOrderChange(ctx, existing.OrderID, change)
+	if err != nil {
 		s.logger.WithError(err).Error("Failed to update line item")
 		return nil, err
 	}
@@ -404,7 +497,19 @@ func (s *OrderService) UpdateLineItem(ctx context.Context, lineItemID uuid.UUID,
 
 // RemoveLineItem removes a line item from an order
 func (s *OrderService) RemoveLineItem(ctx context.Context, lineItemID uuid.UUID) error {
-	if err := s.repo.RemoveLineItem(ctx, lineItemID); err != nil {
+	existing, err := s.repo.GetLineItem(ctx, lineItemID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrLineItemNotFound
+	}
+
+	change := models.OrderEditChange{
+		Type:       models.OrderEditChangeRemoveLineItem,
+		LineItemID: lineItemID,
+	}
+	if _, err := s.applyOrderChange(ctx, existing.OrderID, change); err != nil {
 		s.logger.WithError(err).Error("Failed to remove line item")
 		return err
 	}
@@ -413,6 +518,350 @@ func (s *OrderService) RemoveLineItem(ctx context.Context, lineItemID uuid.UUID)
 	return nil
 }
 
+// applyOrderChange commits a single change to an order as an order edit and
+// returns the changed line item, or nil if it was removed
+func (s *OrderService) applyOrderChange(ctx context.Context, orderID uuid.UUID, change models.OrderEditChange) (*models.OrderLineItem, error) {
+	edit, err := s.BeginOrderEdit(ctx, orderID, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	editID := edit.ID
+	if _, err := s.stageOrderEditChange(ctx, editID, change); err != nil {
+		s.discardAbandonedEdit(ctx, editID)
+		return nil, err
+	}
+	edit, err = s.CommitOrderEdit(ctx, editID, nil)
+	if err != nil {
+		s.discardAbandonedEdit(ctx, editID)
+		return nil, err
+	}
+
+	for i := range edit.LineItems {
+		if edit.LineItems[i].ID == change.LineItemID {
+			return &edit.LineItems[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// discardAbandonedEdit discards an edit that could not be completed. It is
+// best effort, an edit left open changes nothing.
+func (s *OrderService) discardAbandonedEdit(ctx context.Context, editID uuid.UUID) {
+	if _, err := s.DiscardOrderEdit(ctx, editID); err != nil {
+		s.logger.WithError(err).WithField("order_edit_id", editID).Warn("Failed to discard abandoned order edit")
+	}
+}
+
+// Order Editing
+
+// BeginOrderEditRequest represents a request to start editing an order
+type BeginOrderEditRequest struct {
+	Notes string `json:"notes"`
+}
+
+// BeginOrderEdit starts an edit of an order's line items. Changes are staged
+// on the edit and only reach the order when the edit is committed.
+func (s *OrderService) BeginOrderEdit(ctx context.Context, orderID uuid.UUID, notes string, userID *uuid.UUID) (*models.OrderEdit, error) {
+	order, err := s.repo.GetOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+
+	edit := &models.OrderEdit{
+		ID:      uuid.New(),
+		OrderID: orderID,
+		Status:  models.OrderEditStatusOpen,
+		Notes:   notes,
+		UserID:  userID,
+	}
+	if _, err := s.previewOrderEdit(ctx, edit, order); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateOrderEdit(ctx, edit); err != nil {
+		s.logger.WithError(err).Error("Failed to create order edit")
+		return nil, err
+	}
+
+	s.logger.WithField("order_id", orderID).WithField("order_edit_id", edit.ID).Info("Order edit started")
+	return edit, nil
+}
+
+// GetOrderEdit retrieves an order edit. Open edits are previewed against the
+// order as it is now.
+func (s *OrderService) GetOrderEdit(ctx context.Context, id uuid.UUID) (*models.OrderEdit, error) {
+	edit, err := s.getOrderEdit(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if edit.Status != models.OrderEditStatusOpen {
+		return edit, nil
+	}
+
+	order, err := s.repo.GetOrder(ctx, edit.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+	if _, err := s.previewOrderEdit(ctx, edit, order); err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// AddOrderEditLineItem stages a new line item on an order edit
+func (s *OrderService) AddOrderEditLineItem(ctx context.Context, editID uuid.UUID, req *AddLineItemRequest) (*models.OrderEdit, error) {
+	return s.stageOrderEditChange(ctx, editID, newAddLineItemChange(req))
+}
+
+// UpdateOrderEditLineItem stages a quantity or price change of one of the
+// order's line items on an order edit
+func (s *OrderService) UpdateOrderEditLineItem(ctx context.Context, editID, lineItemID uuid.UUID, req *UpdateLineItemRequest) (*models.OrderEdit, error) {
+	return s.stageOrderEditChange(ctx, editID, models.OrderEditChange{
+		Type:       models.OrderEditChangeUpdateLineItem,
+		LineItemID: lineItemID,
+		Quantity:   req.Quantity,
+		Price:      req.Price,
+	})
+}
+
+// RemoveOrderEditLineItem stages the removal of one of the order's line
+// items on an order edit
+func (s *OrderService) RemoveOrderEditLineItem(ctx context.Context, editID, lineItemID uuid.UUID) (*models.OrderEdit, error) {
+	return s.stageOrderEditChange(ctx, editID, models.OrderEditChange{
+		Type:       models.OrderEditChangeRemoveLineItem,
+		LineItemID: lineItemID,
+	})
+}
+
+// CommitOrderEdit applies an order edit's changes to its order in one
+// transaction. The order's totals are recalculated, the difference is
+// recorded as an order event and an order.edited event is emitted so the
+// payment can be adjusted.
+func (s *OrderService) CommitOrderEdit(ctx context.Context, editID uuid.UUID, userID *uuid.UUID) (*models.OrderEdit, error) {
+	if _, err := s.getOrderEdit(ctx, editID); err != nil {
+		return nil, err
+	}
+
+	edit, err := s.repo.CommitOrderEdit(ctx, editID, func(edit *models.OrderEdit, order *models.Order) (*repository.OrderEditCommit, error) {
+		if len(edit.Changes) == 0 {
+			return nil, ErrOrderEditEmpty
+		}
+
+		// Preview again against the locked order, which may have changed
+		// since the changes were staged
+		edited, err := s.previewOrderEdit(ctx, edit, order)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		edit.Status = models.OrderEditStatusCommitted
+		edit.CommittedAt = &now
+
+		diffs := models.DiffLineItems(order.LineItems, edited.LineItems)
+		if len(diffs) == 0 {
+			return nil, ErrOrderEditEmpty
+		}
+		event := &models.OrderEvent{
+			OrderID:     order.ID,
+			EventType:   models.OrderEventEdited,
+			Description: fmt.Sprintf("Order edited: %d line items changed, total %.2f -> %.2f", len(diffs), edit.OriginalTotalPrice, edit.TotalPrice),
+			UserID:      userID,
+			Metadata: map[string]interface{}{
+				"order_edit_id": edit.ID,
+				"line_items":    diffs,
+				"total_price":   map[string]float64{"from": edit.OriginalTotalPrice, "to": edit.TotalPrice},
+				"payment_delta": edit.PaymentDelta,
+			},
+		}
+
+		editedEvent, err := s.newOrderEditedOutboxMessage(ctx, edited, edit, diffs)
+		if err != nil {
+			return nil, err
+		}
+
+		return &repository.OrderEditCommit{
+			Order:  edited,
+			Event:  event,
+			Outbox: []*messaging.OutboxMessage{editedEvent},
+		}, nil
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to commit order edit")
+		return nil, err
+	}
+
+	s.logger.WithField("order_id", edit.OrderID).WithField("order_edit_id", editID).
+		WithField("payment_delta", edit.PaymentDelta).Info("Order edit committed successfully")
+	return edit, nil
+}
+
+// DiscardOrderEdit discards an open order edit without changing the order
+func (s *OrderService) DiscardOrderEdit(ctx context.Context, editID uuid.UUID) (*models.OrderEdit, error) {
+	if _, err := s.getOrderEdit(ctx, editID); err != nil {
+		return nil, err
+	}
+
+	edit, err := s.repo.UpdateOrderEdit(ctx, editID, func(edit *models.OrderEdit, order *models.Order) error {
+		now := time.Now()
+		edit.Status = models.OrderEditStatusDiscarded
+		edit.DiscardedAt = &now
+		return nil
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to discard order edit")
+		return nil, err
+	}
+
+	s.logger.WithField("order_edit_id", editID).Info("Order edit discarded")
+	return edit, nil
+}
+
+// OrderEditedEvent is the data of the order.edited event
+type OrderEditedEvent struct {
+	OrderID            uuid.UUID              `json:"order_id"`
+	OrderNumber        string                 `json:"order_number"`
+	OrderEditID        uuid.UUID              `json:"order_edit_id"`
+	MerchantID         uuid.UUID              `json:"merchant_id"`
+	Currency           string                 `json:"currency"`
+	PaymentStatus      models.PaymentStatus   `json:"payment_status"`
+	OriginalTotalPrice float64                `json:"original_total_price"`
+	TotalPrice         float64                `json:"total_price"`
+	PaymentDelta       float64                `json:"payment_delta"`
+	LineItems          []models.OrderEditDiff `json:"line_items"`
+}
+
+// newOrderEditedOutboxMessage builds the order.edited event of a committed
+// edit, which tells the payment service how much to capture or refund
+func (s *OrderService) newOrderEditedOutboxMessage(ctx context.Context, order *models.Order, edit *models.OrderEdit, diffs []models.OrderEditDiff) (*messaging.OutboxMessage, error) {
+	data := &OrderEditedEvent{
+		OrderID:            order.ID,
+		OrderNumber:        order.OrderNumber,
+		OrderEditID:        edit.ID,
+		MerchantID:         order.MerchantID,
+		Currency:           order.Currency,
+		PaymentStatus:      order.PaymentStatus,
+		OriginalTotalPrice: edit.OriginalTotalPrice,
+		TotalPrice:         edit.TotalPrice,
+		PaymentDelta:       edit.PaymentDelta,
+		LineItems:          diffs,
+	}
+
+	env, err := messaging.NewEnvelope(ctx, messaging.EventTypeOrderEdited, 1, order.MerchantID.String(), data)
+	if err != nil {
+		return nil, err
+	}
+
+	return messaging.NewEnvelopeOutboxMessage(s.schemas, "order", order.ID.String(), "orders.edited", order.ID.String(), env)
+}
+
+// getOrderEdit retrieves an order edit or returns ErrOrderEditNotFound
+func (s *OrderService) getOrderEdit(ctx context.Context, id uuid.UUID) (*models.OrderEdit, error) {
+	edit, err := s.repo.GetOrderEdit(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if edit == nil {
+		return nil, ErrOrderEditNotFound
+	}
+	return edit, nil
+}
+
+// stageOrderEditChange adds a change to an open order edit. Changes that
+// cannot be applied to the order are rejected when staged.
+func (s *OrderService) stageOrderEditChange(ctx context.Context, editID uuid.UUID, change models.OrderEditChange) (*models.OrderEdit, error) {
+	if _, err := s.getOrderEdit(ctx, editID); err != nil {
+		return nil, err
+	}
+
+	edit, err := s.repo.UpdateOrderEdit(ctx, editID, func(edit *models.OrderEdit, order *models.Order) error {
+		edit.Changes = append(edit.Changes, change)
+		_, err := s.previewOrderEdit(ctx, edit, order)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithField("order_edit_id", editID).WithField("change", change.Type).Info("Order edit change staged")
+	return edit, nil
+}
+
+// previewOrderEdit applies the edit to a copy of the order and records the
+// resulting totals and payment delta, the change to the order's total, on
+// the edit. Only added and changed line
+// items are taxed again, so untouched line items keep the taxes they were
+// sold with.
+func (s *OrderService) previewOrderEdit(ctx context.Context, edit *models.OrderEdit, order *models.Order) (*models.Order, error) {
+	lineItems, err := edit.ApplyTo(order)
+	if err != nil {
+		return nil, err
+	}
+
+	edited := *order
+	edited.LineItems = lineItems
+
+	changed := make(map[uuid.UUID]bool)
+	for _, change := range edit.Changes {
+		if change.Type != models.OrderEditChangeRemoveLineItem {
+			changed[change.LineItemID] = true
+		}
+	}
+	var retax []models.OrderLineItem
+	for _, lineItem := range lineItems {
+		if changed[lineItem.ID] {
+			retax = append(retax, lineItem)
+		}
+	}
+	if len(retax) > 0 {
+		result, err := s.calculateTaxes(ctx, &edited, retax, false)
+		if err != nil {
+			return nil, err
+		}
+		for i := range edited.LineItems {
+			if line := result.Line(edited.LineItems[i].ID.String()); line != nil {
+				edited.LineItems[i].TaxLines = toTaxLines(line)
+			}
+		}
+	}
+	edited.CalculateTotals()
+
+	edit.OriginalTotalPrice = order.TotalPrice
+	edit.SubtotalPrice = edited.SubtotalPrice
+	edit.TotalTax = edited.TotalTax
+	edit.TotalPrice = edited.TotalPrice
+	edit.PaymentDelta = tax.Round(edited.TotalPrice - edit.OriginalTotalPrice)
+	edit.LineItems = edited.LineItems
+	return &edited, nil
+}
+
+// newAddLineItemChange builds the order edit change adding the requested
+// line item
+func newAddLineItemChange(req *AddLineItemRequest) models.OrderEditChange {
+	return models.OrderEditChange{
+		Type:       models.OrderEditChangeAddLineItem,
+		LineItemID: uuid.New(),
+		LineItem: &models.OrderEditLineItem{
+			ProductID:        req.ProductID,
+			ProductVariantID: req.ProductVariantID,
+			SKU:              req.SKU,
+			Name:             req.Name,
+			Quantity:         req.Quantity,
+			Price:            req.Price,
+			Taxable:          req.Taxable == nil || *req.Taxable,
+			TaxClass:         req.TaxClass,
+			RequiresShipping: req.RequiresShipping == nil || *req.RequiresShipping,
+			Properties:       req.Properties,
+		},
+	}
+}
+
 // Fulfillment Management
 
 // CreateFulfillmentRequest represents a request to create a fulfillment
@@ -445,7 +894,7 @@ func (s *OrderService) CreateFulfillment(ctx context.Context, req *CreateFulfill
 	fulfillment := &models.Fulfillment{
 		OrderID:         req.OrderID,
 		LocationID:      req.LocationID,
-		Status:          models.FulfillmentStatusUnfulfilled,
+		Status:          models.FulfillmentStatusFulfilled, // counted as it is created
 		TrackingNumber:  req.TrackingNumber,
 		TrackingCompany: req.TrackingCompany,
 		Service:         req.Service,
@@ -471,9 +920,94 @@ func (s *OrderService) CreateFulfillment(ctx context.Context, req *CreateFulfill
 	return fulfillment, nil
 }
 
+// RouteFulfillmentsRequest holds the fulfillments inventory routed an
+// order's line items to, one per shipping location
+type RouteFulfillmentsRequest struct {
+	OrderID      uuid.UUID                   `json:"order_id"`
+	Fulfillments []RoutedFulfillmentRequest  `json:"fulfillments"`
+	Unallocated  []CreateFulfillmentLineItem `json:"unallocated"`
+}
+
+// RoutedFulfillmentRequest is the part of an order shipped from a location
+type RoutedFulfillmentRequest struct {
+	LocationID uuid.UUID                   `json:"location_id"`
+	LineItems  []CreateFulfillmentLineItem `json:"line_items"`
+}
+
+// RouteFulfillments creates an unfulfilled fulfillment for each location an
+// order was routed to. Line items no location had in stock are left for
+// manual fulfillment.
+func (s *OrderService) RouteFulfillments(ctx context.Context, req *RouteFulfillmentsRequest) ([]*models.Fulfillment, error) {
+	return s.routeFulfillments(ctx, s.repo, req)
+}
+
+// RouteFulfillmentsInTx routes fulfillments inside a transaction owned by
+// the caller
+func (s *OrderService) RouteFulfillmentsInTx(ctx context.Context, tx *gorm.DB, req *RouteFulfillmentsRequest) ([]*models.Fulfillment, error) {
+	return s.routeFulfillments(ctx, s.repo.WithTx(tx), req)
+}
+
+// routeFulfillments routes fulfillments using the given repository
+func (s *OrderService) routeFulfillments(ctx context.Context, repo *repository.OrderRepository, req *RouteFulfillmentsRequest) ([]*models.Fulfillment, error) {
+	fulfillments := make([]*models.Fulfillment, 0, len(req.Fulfillments))
+	for _, routed := range req.Fulfillments {
+		locationID := routed.LocationID
+		fulfillment := &models.Fulfillment{
+			ID:             uuid.New(),
+			OrderID:        req.OrderID,
+			LocationID:     &locationID,
+			Status:         models.FulfillmentStatusUnfulfilled,
+			ShipmentStatus: models.ShipmentStatusPending,
+		}
+		for _, item := range routed.LineItems {
+			fulfillment.LineItems = append(fulfillment.LineItems, models.FulfillmentLineItem{
+				FulfillmentID: fulfillment.ID,
+				LineItemID:    item.LineItemID,
+				Quantity:      item.Quantity,
+			})
+		}
+		fulfillments = append(fulfillments, fulfillment)
+	}
+
+	description := fmt.Sprintf("Order routed to %d location(s)", len(fulfillments))
+	if len(req.Unallocated) > 0 {
+		unallocated := 0
+		for _, item := range req.Unallocated {
+			unallocated += item.Quantity
+		}
+		description += fmt.Sprintf("; %d item(s) out of stock everywhere need manual fulfillment", unallocated)
+	}
+
+	if err := repo.CreateRoutedFulfillments(ctx, req.OrderID, fulfillments, description); err != nil {
+		s.logger.WithError(err).WithField("order_id", req.OrderID).Error("Failed to create routed fulfillments")
+		return nil, err
+	}
+
+	s.logger.WithField("order_id", req.OrderID).WithField("fulfillments", len(fulfillments)).Info("Order fulfillments routed")
+	return fulfillments, nil
+}
+
+// MarkFulfillmentShippedRequest represents a request to mark a fulfillment
+// as shipped. A known carrier is stored as its code, which lets its
+// webhooks track the shipment, and links to its tracking page when no
+// tracking URL is given.
+type MarkFulfillmentShippedRequest struct {
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number" validate:"required"`
+	TrackingURL    string `json:"tracking_url"`
+}
+
 // MarkFulfillmentShipped marks a fulfillment as shipped
-func (s *OrderService) MarkFulfillmentShipped(ctx context.Context, fulfillmentID uuid.UUID, trackingNumber, trackingURL string) error {
-	if err := s.repo.MarkFulfillmentShipped(ctx, fulfillmentID, trackingNumber, trackingURL); err != nil {
+func (s *OrderService) MarkFulfillmentShipped(ctx context.Context, fulfillmentID uuid.UUID, req *MarkFulfillmentShippedRequest) error {
+	trackingCompany, trackingURL := req.Carrier, req.TrackingURL
+	if shipper, err := s.carriers.Get(req.Carrier); err == nil {
+		trackingCompany = shipper.Code()
+		if trackingURL == "" {
+			trackingURL = shipper.TrackingURL(req.TrackingNumber)
+		}
+	}
+
+	if err := s.repo.MarkFulfillmentShipped(ctx, fulfillmentID, trackingCompany, req.TrackingNumber, trackingURL); err != nil {
 		s.logger.WithError(err).Error("Failed to mark fulfillment as shipped")
 		return err
 	}
@@ -486,7 +1020,12 @@ func (s *OrderService) MarkFulfillmentShipped(ctx context.Context, fulfillmentID
 
 // CreateTransactionRequest represents a request to create a transaction
 type CreateTransactionRequest struct {
-	OrderID uuid.UUID `json:"order_id" validate:"required"`
+	OrderID   uuid.UUID                `json:"order_id" validate:"required"`
+	PaymentID *uuid.UUID               `json:"payment_id"`
+	Kind      models.TransactionKind   `json:"kind" validate:"required,oneof=authorization capture sale void refund"`
+	Status    models.TransactionStatus `json:"status" validate:"omitempty,oneof=pending success failure"`
+	Amount    float64                  `json:"amount" validate:"min=0"`
+	Currency  string                   `json:"currency"`
 	// We're not storing detailed transaction information since it's managed by the payment service
 }
 
@@ -500,8 +1039,22 @@ func (s *OrderService) CreateTransaction(ctx context.Context, req *CreateTransac
 		return nil, ErrOrderNotFound
 	}
 
+	status := req.Status
+	if status == "" {
+		status = models.TransactionStatusSuccess
+	}
+	currency := req.Currency
+	if currency == "" {
+		currency = order.Currency
+	}
+
 	transaction := &models.Transaction{
-		OrderID: req.OrderID,
+		OrderID:   req.OrderID,
+		PaymentID: req.PaymentID,
+		Kind:      req.Kind,
+		Status:    status,
+		Amount:    req.Amount,
+		Currency:  currency,
 	}
 
 	if err := s.repo.CreateTransaction(ctx, transaction); err != nil {
@@ -544,12 +1097,8 @@ func (s *OrderService) CreateReturn(ctx context.Context, req *CreateReturnReques
 		return nil, ErrOrderNotFound
 	}
 
-	// Generate return number
-	returnNumber := s.generateReturnNumber()
-
 	returnItem := &models.Return{
 		OrderID:        req.OrderID,
-		ReturnNumber:   returnNumber,
 		Status:         models.ReturnStatusPending,
 		Reason:         req.Reason,
 		CustomerNotes:  req.CustomerNotes,
@@ -570,24 +1119,373 @@ func (s *OrderService) CreateReturn(ctx context.Context, req *CreateReturnReques
 		returnItem.LineItems = append(returnItem.LineItems, returnLineItem)
 	}
 
-	if err := s.repo.CreateReturn(ctx, returnItem); err != nil {
+	err = s.repo.Transaction(ctx, func(repo *repository.OrderRepository) error {
+		returnNumber, err := repo.NextNumber(ctx, order.MerchantID, sequence.Return)
+		if err != nil {
+			return err
+		}
+		returnItem.ReturnNumber = returnNumber
+		return repo.CreateReturn(ctx, returnItem)
+	})
+	if err != nil {
 		s.logger.WithError(err).Error("Failed to create return")
 		return nil, err
 	}
 
-	s.logger.WithField("return_id", returnItem.ID).WithField("return_number", returnNumber).Info("Return created successfully")
+	s.logger.WithField("return_id", returnItem.ID).WithField("return_number", returnItem.ReturnNumber).Info("Return created successfully")
 	return returnItem, nil
 }
 
-// ProcessReturn processes a return and issues refund
-func (s *OrderService) ProcessReturn(ctx context.Context, returnID uuid.UUID, refundAmount float64, restockItems bool) error {
-	if err := s.repo.ProcessReturn(ctx, returnID, refundAmount, restockItems); err != nil {
-		s.logger.WithError(err).Error("Failed to process return")
-		return err
+// GetReturn retrieves a return by ID
+func (s *OrderService) GetReturn(ctx context.Context, id uuid.UUID) (*models.Return, error) {
+	returnItem, err := s.repo.GetReturn(ctx, id)
+	if err != nil {
+		return nil, err
 	}
+	if returnItem == nil {
+		return nil, ErrReturnNotFound
+	}
+	return returnItem, nil
+}
 
-	s.logger.WithField("return_id", returnID).WithField("refund_amount", refundAmount).Info("Return processed successfully")
-	return nil
+// GetReturnsByOrder retrieves the returns of an order
+func (s *OrderService) GetReturnsByOrder(ctx context.Context, orderID uuid.UUID) ([]*models.Return, error) {
+	if _, err := s.GetOrder(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetReturnsByOrder(ctx, orderID)
+}
+
+// UpdateReturnRequest represents a request to update a return. Whether
+// shipping is refunded and items restocked can only change until the items
+// are received.
+type UpdateReturnRequest struct {
+	CustomerNotes  *string `json:"customer_notes"`
+	InternalNotes  *string `json:"internal_notes"`
+	RefundShipping *bool   `json:"refund_shipping"`
+	RestockItems   *bool   `json:"restock_items"`
+}
+
+// UpdateReturn updates a return's notes and options
+func (s *OrderService) UpdateReturn(ctx context.Context, id uuid.UUID, req *UpdateReturnRequest) (*models.Return, error) {
+	return s.updateReturn(ctx, id, "update", func(returnItem *models.Return, order *models.Order) (*repository.ReturnChange, error) {
+		if req.RefundShipping != nil || req.RestockItems != nil {
+			switch returnItem.Status {
+			case models.ReturnStatusPending, models.ReturnStatusAuthorized, models.ReturnStatusLabelIssued:
+			default:
+				return nil, ErrReturnNotEditable
+			}
+		}
+
+		if req.CustomerNotes != nil {
+			returnItem.CustomerNotes = *req.CustomerNotes
+		}
+		if req.InternalNotes != nil {
+			returnItem.InternalNotes = *req.InternalNotes
+		}
+		if req.RefundShipping != nil {
+			returnItem.RefundShipping = *req.RefundShipping
+		}
+		if req.RestockItems != nil {
+			returnItem.RestockItems = *req.RestockItems
+		}
+		return nil, nil
+	})
+}
+
+// ApproveReturn authorizes a requested return
+func (s *OrderService) ApproveReturn(ctx context.Context, id uuid.UUID, userID *uuid.UUID) (*models.Return, error) {
+	return s.updateReturn(ctx, id, "approve", func(returnItem *models.Return, order *models.Order) (*repository.ReturnChange, error) {
+		if err := returnItem.TransitionTo(models.ReturnStatusAuthorized, time.Now()); err != nil {
+			return nil, err
+		}
+		return returnEvent(returnItem, userID, fmt.Sprintf("Return %s authorized", returnItem.ReturnNumber)), nil
+	})
+}
+
+// RejectReturn denies a requested return, releasing its items to be returned
+// again
+func (s *OrderService) RejectReturn(ctx context.Context, id uuid.UUID, reason string, userID *uuid.UUID) (*models.Return, error) {
+	return s.updateReturn(ctx, id, "reject", func(returnItem *models.Return, order *models.Order) (*repository.ReturnChange, error) {
+		if err := returnItem.TransitionTo(models.ReturnStatusDenied, time.Now()); err != nil {
+			return nil, err
+		}
+		returnItem.DenialReason = reason
+		return returnEvent(returnItem, userID, fmt.Sprintf("Return %s denied: %s", returnItem.ReturnNumber, reason)), nil
+	})
+}
+
+// IssueReturnLabelRequest represents the shipping label sent to the customer
+// for an authorized return
+type IssueReturnLabelRequest struct {
+	Carrier        string `json:"carrier" validate:"required"`
+	TrackingNumber string `json:"tracking_number" validate:"required"`
+	LabelURL       string `json:"label_url" validate:"omitempty,url"`
+}
+
+// IssueReturnLabel records the shipping label of an authorized return. A
+// label may be reissued until the items are received.
+func (s *OrderService) IssueReturnLabel(ctx context.Context, id uuid.UUID, req *IssueReturnLabelRequest, userID *uuid.UUID) (*models.Return, error) {
+	return s.updateReturn(ctx, id, "issue label for", func(returnItem *models.Return, order *models.Order) (*repository.ReturnChange, error) {
+		if err := returnItem.TransitionTo(models.ReturnStatusLabelIssued, time.Now()); err != nil {
+			return nil, err
+		}
+		returnItem.LabelCarrier = req.Carrier
+		returnItem.LabelTrackingNumber = req.TrackingNumber
+		returnItem.LabelURL = req.LabelURL
+		return returnEvent(returnItem, userID, fmt.Sprintf("Return %s label issued with %s tracking number: %s", returnItem.ReturnNumber, req.Carrier, req.TrackingNumber)), nil
+	})
+}
+
+// ReceiveReturnRequest represents the items of a return arriving at a
+// location. Lines that are left out arrived in full and new.
+type ReceiveReturnRequest struct {
+	LocationID *uuid.UUID              `json:"location_id"`
+	LineItems  []ReceiveReturnLineItem `json:"line_items" validate:"dive"`
+}
+
+// ReceiveReturnLineItem represents how much of a return line arrived and in
+// which condition
+type ReceiveReturnLineItem struct {
+	ReturnLineItemID uuid.UUID              `json:"return_line_item_id" validate:"required"`
+	Quantity         int                    `json:"quantity" validate:"min=0"`
+	Condition        models.ReturnCondition `json:"condition" validate:"required,oneof=new opened used damaged defective"`
+}
+
+// ReceiveReturn records the items of an authorized return as received. A
+// return.received event tells inventory which units to restock and where:
+// at the receiving location, else the location the line shipped from, else
+// the order's location.
+func (s *OrderService) ReceiveReturn(ctx context.Context, id uuid.UUID, req *ReceiveReturnRequest, userID *uuid.UUID) (*models.Return, error) {
+	return s.updateReturn(ctx, id, "receive", func(returnItem *models.Return, order *models.Order) (*repository.ReturnChange, error) {
+		if err := returnItem.TransitionTo(models.ReturnStatusReceived, time.Now()); err != nil {
+			return nil, err
+		}
+
+		receipts := make([]models.ReturnReceipt, 0, len(req.LineItems))
+		for _, lineItem := range req.LineItems {
+			receipts = append(receipts, models.ReturnReceipt{
+				ReturnLineItemID: lineItem.ReturnLineItemID,
+				Quantity:         lineItem.Quantity,
+				Condition:        lineItem.Condition,
+			})
+		}
+		if err := returnItem.Receive(receipts); err != nil {
+			return nil, err
+		}
+		returnItem.LocationID = req.LocationID
+
+		received, err := s.newReturnReceivedOutboxMessage(ctx, returnItem, order)
+		if err != nil {
+			return nil, err
+		}
+		change := returnEvent(returnItem, userID, fmt.Sprintf("Return %s received", returnItem.ReturnNumber))
+		change.Outbox = []*messaging.OutboxMessage{received}
+		return change, nil
+	})
+}
+
+// InspectReturnRequest represents the result of inspecting received items
+type InspectReturnRequest struct {
+	LineItems     []InspectReturnLineItem `json:"line_items" validate:"dive"`
+	InternalNotes string                  `json:"internal_notes"`
+}
+
+// InspectReturnLineItem represents the restocking fee charged for a return
+// line
+type InspectReturnLineItem struct {
+	ReturnLineItemID uuid.UUID `json:"return_line_item_id" validate:"required"`
+	RestockingFee    float64   `json:"restocking_fee" validate:"min=0"`
+}
+
+// InspectReturn records the inspection of a received return, charging any
+// restocking fees, and calculates its refund
+func (s *OrderService) InspectReturn(ctx context.Context, id uuid.UUID, req *InspectReturnRequest, userID *uuid.UUID) (*models.Return, error) {
+	return s.updateReturn(ctx, id, "inspect", func(returnItem *models.Return, order *models.Order) (*repository.ReturnChange, error) {
+		if err := returnItem.TransitionTo(models.ReturnStatusInspected, time.Now()); err != nil {
+			return nil, err
+		}
+
+		fees := make(map[uuid.UUID]float64, len(req.LineItems))
+		for _, lineItem := range req.LineItems {
+			fees[lineItem.ReturnLineItemID] = lineItem.RestockingFee
+		}
+		for i := range returnItem.LineItems {
+			returnLine := &returnItem.LineItems[i]
+			returnLine.RestockingFee = fees[returnLine.ID]
+			delete(fees, returnLine.ID)
+		}
+		if len(fees) > 0 {
+			return nil, models.ErrReturnLineItemNotFound
+		}
+		if req.InternalNotes != "" {
+			returnItem.InternalNotes = req.InternalNotes
+		}
+		returnItem.CalculateRefund(order)
+
+		return returnEvent(returnItem, userID, fmt.Sprintf("Return %s inspected: refund %.2f, restocking fee %.2f",
+			returnItem.ReturnNumber, returnItem.RefundAmount, returnItem.RestockingFee)), nil
+	})
+}
+
+// RefundReturn refunds an inspected return. A return.refunded event tells
+// the payment service to refund the amount, and the order is marked
+// returned once its items have all been refunded.
+func (s *OrderService) RefundReturn(ctx context.Context, id uuid.UUID, userID *uuid.UUID) (*models.Return, error) {
+	return s.updateReturn(ctx, id, "refund", func(returnItem *models.Return, order *models.Order) (*repository.ReturnChange, error) {
+		if err := returnItem.TransitionTo(models.ReturnStatusRefunded, time.Now()); err != nil {
+			return nil, err
+		}
+
+		refunded, err := s.newReturnRefundedOutboxMessage(ctx, returnItem, order)
+		if err != nil {
+			return nil, err
+		}
+		change := returnEvent(returnItem, userID, fmt.Sprintf("Return %s refunded %.2f", returnItem.ReturnNumber, returnItem.RefundAmount))
+		change.Outbox = []*messaging.OutboxMessage{refunded}
+		return change, nil
+	})
+}
+
+// updateReturn applies a workflow step to a return, logging the outcome
+// with the step's verb
+func (s *OrderService) updateReturn(ctx context.Context, id uuid.UUID, verb string, update func(returnItem *models.Return, order *models.Order) (*repository.ReturnChange, error)) (*models.Return, error) {
+	if _, err := s.GetReturn(ctx, id); err != nil {
+		return nil, err
+	}
+
+	returnItem, err := s.repo.UpdateReturn(ctx, id, update)
+	if err != nil {
+		s.logger.WithError(err).WithField("return_id", id).Errorf("Failed to %s return", verb)
+		return nil, err
+	}
+
+	s.logger.WithField("return_id", id).WithField("status", returnItem.Status).Info("Return updated successfully")
+	return returnItem, nil
+}
+
+// returnEvent records a return workflow step on the order's timeline
+func returnEvent(returnItem *models.Return, userID *uuid.UUID, description string) *repository.ReturnChange {
+	return &repository.ReturnChange{
+		Event: &models.OrderEvent{
+			OrderID:     returnItem.OrderID,
+			EventType:   models.OrderEventReturned,
+			Description: description,
+			UserID:      userID,
+			Metadata: map[string]interface{}{
+				"return_id": returnItem.ID,
+				"status":    returnItem.Status,
+			},
+		},
+	}
+}
+
+// ReturnReceivedEvent is the data of the return.received event
+type ReturnReceivedEvent struct {
+	ReturnID     uuid.UUID                   `json:"return_id"`
+	ReturnNumber string                      `json:"return_number"`
+	OrderID      uuid.UUID                   `json:"order_id"`
+	MerchantID   uuid.UUID                   `json:"merchant_id"`
+	LineItems    []ReturnReceivedLineItemDTO `json:"line_items"`
+}
+
+// ReturnReceivedLineItemDTO is a received return line and the location its
+// restocked units go back to
+type ReturnReceivedLineItemDTO struct {
+	ReturnLineItemID uuid.UUID              `json:"return_line_item_id"`
+	LineItemID       uuid.UUID              `json:"line_item_id"`
+	ProductVariantID *uuid.UUID             `json:"product_variant_id,omitempty"`
+	SKU              string                 `json:"sku"`
+	LocationID       *uuid.UUID             `json:"location_id,omitempty"`
+	Quantity         int                    `json:"quantity"`
+	Condition        models.ReturnCondition `json:"condition"`
+	RestockQuantity  int                    `json:"restock_quantity"`
+}
+
+// newReturnReceivedOutboxMessage builds the return.received event of a
+// received return
+func (s *OrderService) newReturnReceivedOutboxMessage(ctx context.Context, returnItem *models.Return, order *models.Order) (*messaging.OutboxMessage, error) {
+	lineItems := make(map[uuid.UUID]models.OrderLineItem, len(order.LineItems))
+	for _, lineItem := range order.LineItems {
+		lineItems[lineItem.ID] = lineItem
+	}
+	shippedFrom := make(map[uuid.UUID]*uuid.UUID)
+	for _, fulfillment := range order.Fulfillments {
+		for _, fulfillmentLineItem := range fulfillment.LineItems {
+			if fulfillment.LocationID != nil {
+				shippedFrom[fulfillmentLineItem.LineItemID] = fulfillment.LocationID
+			}
+		}
+	}
+
+	data := &ReturnReceivedEvent{
+		ReturnID:     returnItem.ID,
+		ReturnNumber: returnItem.ReturnNumber,
+		OrderID:      order.ID,
+		MerchantID:   order.MerchantID,
+	}
+	for _, returnLine := range returnItem.LineItems {
+		locationID := returnItem.LocationID
+		if locationID == nil {
+			locationID = shippedFrom[returnLine.LineItemID]
+		}
+		if locationID == nil {
+			locationID = order.LocationID
+		}
+
+		lineItem := lineItems[returnLine.LineItemID]
+		data.LineItems = append(data.LineItems, ReturnReceivedLineItemDTO{
+			ReturnLineItemID: returnLine.ID,
+			LineItemID:       returnLine.LineItemID,
+			ProductVariantID: lineItem.ProductVariantID,
+			SKU:              lineItem.SKU,
+			LocationID:       locationID,
+			Quantity:         returnLine.ReceivedQuantity,
+			Condition:        returnLine.Condition,
+			RestockQuantity:  returnLine.RestockQuantity,
+		})
+	}
+
+	env, err := messaging.NewEnvelope(ctx, messaging.EventTypeReturnReceived, 1, order.MerchantID.String(), data)
+	if err != nil {
+		return nil, err
+	}
+
+	return messaging.NewEnvelopeOutboxMessage(s.schemas, "return", returnItem.ID.String(), "returns.received", order.ID.String(), env)
+}
+
+// ReturnRefundedEvent is the data of the return.refunded event
+type ReturnRefundedEvent struct {
+	ReturnID      uuid.UUID           `json:"return_id"`
+	ReturnNumber  string              `json:"return_number"`
+	OrderID       uuid.UUID           `json:"order_id"`
+	MerchantID    uuid.UUID           `json:"merchant_id"`
+	Reason        models.ReturnReason `json:"reason"`
+	Currency      string              `json:"currency"`
+	RefundAmount  float64             `json:"refund_amount"`
+	RestockingFee float64             `json:"restocking_fee"`
+}
+
+// newReturnRefundedOutboxMessage builds the return.refunded event, which
+// tells the payment service how much to refund
+func (s *OrderService) newReturnRefundedOutboxMessage(ctx context.Context, returnItem *models.Return, order *models.Order) (*messaging.OutboxMessage, error) {
+	data := &ReturnRefundedEvent{
+		ReturnID:      returnItem.ID,
+		ReturnNumber:  returnItem.ReturnNumber,
+		OrderID:       order.ID,
+		MerchantID:    order.MerchantID,
+		Reason:        returnItem.Reason,
+		Currency:      order.Currency,
+		RefundAmount:  returnItem.RefundAmount,
+		RestockingFee: returnItem.RestockingFee,
+	}
+
+	env, err := messaging.NewEnvelope(ctx, messaging.EventTypeReturnRefunded, 1, order.MerchantID.String(), data)
+	if err != nil {
+		return nil, err
+	}
+
+	return messaging.NewEnvelopeOutboxMessage(s.schemas, "return", returnItem.ID.String(), "returns.refunded", order.ID.String(), env)
 }
 
 // Analytics and Reporting
@@ -602,19 +1500,69 @@ func (s *OrderService) GetOrderEvents(ctx context.Context, orderID uuid.UUID) ([
 	return s.repo.GetOrderEvents(ctx, orderID)
 }
 
-// Utility Methods
+// Tax Calculation
+
+// shippingTaxLineID identifies the shipping charge in tax requests
+const shippingTaxLineID = "shipping"
+
+// applyTaxes calculates the taxes of the order's line items and shipping and
+// sets its tax lines and totals
+func (s *OrderService) applyTaxes(ctx context.Context, order *models.Order) error {
+	result, err := s.calculateTaxes(ctx, order, order.LineItems, true)
+	if err != nil {
+		return err
+	}
+
+	for i := range order.LineItems {
+		order.LineItems[i].TaxLines = toTaxLines(result.Line(order.LineItems[i].ID.String()))
+	}
+	order.ShippingTaxLines = toTaxLines(result.Line(shippingTaxLineID))
+	order.CalculateTotals()
+	return nil
+}
+
+// calculateTaxes calculates the taxes of the line items, and of the order's
+// shipping if requested, for the order's shipping address, falling back to
+// its billing address
+func (s *OrderService) calculateTaxes(ctx context.Context, order *models.Order, lineItems []models.OrderLineItem, withShipping bool) (*tax.Result, error) {
+	address := order.ShippingAddress
+	if address.Country == "" {
+		address = order.BillingAddress
+	}
 
-// generateOrderNumber generates a unique order number
-func (s *OrderService) generateOrderNumber() string {
-	// Simple implementation - in production you'd want a more sophisticated approach
-	timestamp := time.Now().Format("20060102")
-	random := rand.Intn(10000)
-	return fmt.Sprintf("ORD-%s-%04d", timestamp, random)
+	req := &tax.Request{
+		Address:          tax.Address{Country: address.Country, Province: address.State, PostalCode: address.PostalCode},
+		PricesIncludeTax: order.TaxesIncluded,
+	}
+	for _, lineItem := range lineItems {
+		req.Lines = append(req.Lines, tax.Line{
+			ID:       lineItem.ID.String(),
+			TaxClass: lineItem.TaxClass,
+			Amount:   lineItem.LinePrice - lineItem.TotalDiscount,
+			Taxable:  lineItem.Taxable,
+		})
+	}
+	if withShipping && order.TotalShipping > 0 {
+		req.Lines = append(req.Lines, tax.Line{
+			ID:       shippingTaxLineID,
+			TaxClass: tax.ShippingClass,
+			Amount:   order.TotalShipping,
+			Taxable:  true,
+		})
+	}
+
+	return s.taxes.Calculate(ctx, req)
 }
 
-// generateReturnNumber generates a unique return number
-func (s *OrderService) generateReturnNumber() string {
-	timestamp := time.Now().Format("20060102")
-	random := rand.Intn(10000)
-	return fmt.Sprintf("RET-%s-%04d", timestamp, random)
+// toTaxLines converts the calculated taxes of a line to order tax lines
+func toTaxLines(line *tax.LineResult) []models.TaxLine {
+	if line == nil {
+		return nil
+	}
+
+	var taxLines []models.TaxLine
+	for _, taxLine := range line.TaxLines {
+		taxLines = append(taxLines, models.TaxLine{Title: taxLine.Title, Rate: taxLine.Rate, Price: taxLine.Amount})
+	}
+	return taxLines
 }
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/order/models"
+)
+
+// Shipment Tracking
+
+// ReceiveCarrierWebhook authenticates a webhook a carrier sent and records
+// the statuses it reports on the fulfillments shipped with the carrier.
+// Statuses of tracking numbers no fulfillment has are ignored. It returns
+// how many statuses were recorded.
+func (s *OrderService) ReceiveCarrierWebhook(ctx context.Context, code string, header http.Header, body []byte) (int, error) {
+	shipper, err := s.carriers.Get(code)
+	if err != nil {
+		return 0, err
+	}
+	updates, err := shipper.ParseWebhook(header, body)
+	if err != nil {
+		return 0, err
+	}
+
+	events := make([]*models.TrackingEvent, 0, len(updates))
+	for _, update := range updates {
+		events = append(events, &models.TrackingEvent{
+			Carrier:        shipper.Code(),
+			TrackingNumber: update.TrackingNumber,
+			Status:         update.Status,
+			CarrierStatus:  update.CarrierStatus,
+			Description:    update.Description,
+			Location:       update.Location,
+			OccurredAt:     update.OccurredAt.UTC(),
+		})
+	}
+
+	recorded, err := s.repo.RecordTrackingEvents(ctx, shipper.Code(), events)
+	if err != nil {
+		return 0, err
+	}
+
+	s.logger.WithField("carrier", shipper.Code()).WithField("received", len(events)).WithField("recorded", recorded).Info("Carrier webhook processed")
+	return recorded, nil
+}
+
+// TrackOrder returns an order's tracking for its customer, who proves they
+// placed it with either the email it was placed with or a tracking token.
+// Orders that do not match are reported as not found.
+func (s *OrderService) TrackOrder(ctx context.Context, orderNumber, email, token string) (*models.OrderTracking, error) {
+	var (
+		order *models.Order
+		err   error
+	)
+	switch {
+	case token != "":
+		if len(s.trackingSecret) == 0 {
+			return nil, ErrTrackingTokenDisabled
+		}
+		orderID, verifyErr := models.VerifyTrackingToken(s.trackingSecret, token)
+		if verifyErr != nil {
+			return nil, ErrOrderNotFound
+		}
+		order, err = s.repo.GetTrackedOrder(ctx, orderID)
+		if order != nil && order.OrderNumber != orderNumber {
+			order = nil
+		}
+	case strings.TrimSpace(email) != "":
+		order, err = s.repo.GetTrackedOrderByEmail(ctx, orderNumber, strings.TrimSpace(email))
+	default:
+		return nil, ErrTrackingCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+
+	return models.NewOrderTracking(order), nil
+}
+
+// GetTrackingToken returns the token that lets an order's customer track it
+// without its email, for links in shipping notifications
+func (s *OrderService) GetTrackingToken(ctx context.Context, orderID uuid.UUID) (string, error) {
+	if len(s.trackingSecret) == 0 {
+		return "", ErrTrackingTokenDisabled
+	}
+
+	order, err := s.GetOrder(ctx, orderID)
+	if err != nil {
+		return "", err
+	}
+	return models.SignTrackingToken(s.trackingSecret, order.ID), nil
+}
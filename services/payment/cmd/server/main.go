@@ -9,17 +9,19 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 
+	"unified-commerce/services/payment/eventhandlers"
+	"unified-commerce/services/payment/gateway"
 	"unified-commerce/services/payment/graphql"
 	"unified-commerce/services/payment/handlers"
-	"unified-commerce/services/payment/models"
+	"unified-commerce/services/payment/migrations"
 	"unified-commerce/services/payment/repository"
 	"unified-commerce/services/payment/service"
 	"unified-commerce/services/shared/config"
 	"unified-commerce/services/shared/database"
 	"unified-commerce/services/shared/logger"
 	"unified-commerce/services/shared/middleware"
+	"unified-commerce/shared/messaging"
 )
 
 func main() {
@@ -49,16 +51,95 @@ func main() {
 		log.WithError(err).Fatal("Failed to connect to PostgreSQL")
 	}
 
+	// Run the migrate subcommand instead of the server when requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := database.RunMigrateCommand(context.Background(), db.DB, "payment", migrations.All(), os.Args[2:], os.Stdout); err != nil {
+			log.WithError(err).Fatal("Migrate command failed")
+		}
+		return
+	}
+
+	// Connect to Redis for rate limiting; requests are not limited without it
+	redisClient, err := database.NewRedisConnection(database.NewRedisConfigFromEnv(
+		cfg.RedisURL,
+		cfg.RedisPassword,
+		cfg.RedisDB,
+	))
+	if err != nil {
+		log.WithError(err).Warn("Failed to connect to Redis, continuing without rate limiting")
+	} else {
+		defer redisClient.Close()
+	}
+	rateLimiter := middleware.NewRateLimiter(redisClient)
+
 	// Run database migrations
-	if err := runMigrations(db.DB); err != nil {
+	if _, err := db.Migrate(context.Background(), "payment", migrations.All()); err != nil {
 		log.WithError(err).Fatal("Failed to run database migrations")
 	}
 
 	// Initialize repositories
 	paymentRepo := repository.NewPaymentRepository(db.DB, log)
 
+	// Load event schemas
+	schemaRegistry, err := messaging.DefaultSchemaRegistry()
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load event schemas")
+	}
+
 	// Initialize services
-	paymentService := service.NewPaymentService(paymentRepo, log)
+	paymentService := service.NewPaymentService(paymentRepo, log, schemaRegistry, gateway.DefaultRegistry())
+
+	// Initialize Event Producer for dead letters and outbox events
+	producer, err := messaging.NewEventProducer(messaging.ProducerConfig{
+		Driver:    cfg.MessagingDriver,
+		Brokers:   cfg.KafkaBrokers,
+		UseDocker: messaging.DetectEnvironment(),
+	})
+	if err != nil {
+		log.WithError(err).Warn("Failed to create event producer, using no-op producer for graceful degradation")
+		producer = messaging.NewNoOpProducer()
+	}
+	defer producer.Close()
+
+	// Consume returns refunded, draft orders paid, payments captured through
+	// the order service, edited orders and the evidence the order service
+	// assembles for disputes
+	orderEventHandler := eventhandlers.NewOrderEventHandler(paymentService, schemaRegistry, log)
+	consumerConfig := messaging.ConsumerConfig{
+		Driver:    cfg.MessagingDriver,
+		Brokers:   cfg.KafkaBrokers,
+		GroupID:   "payment-service",
+		Topics:    []string{"returns.refunded", "draft_orders.payment_requested", "orders.capture_requested", "orders.edited", "orders.dispute_evidence"},
+		UseDocker: messaging.DetectEnvironment(),
+	}
+	consumer, err := messaging.NewEventConsumer(consumerConfig)
+	if err != nil {
+		log.WithError(err).Warn("Failed to create event consumer, using no-op consumer for graceful degradation")
+		consumer = messaging.NewNoOpConsumer(consumerConfig.Topics)
+	}
+	defer consumer.Close()
+
+	consumerCtx, stopConsumer := context.WithCancel(context.Background())
+	defer stopConsumer()
+	// Skip redelivered events; the processed-event record commits with the refund or payment
+	processedEvents := messaging.NewPostgresProcessedEventStore(db.DB)
+	idempotentHandler := messaging.NewIdempotentHandler(processedEvents, consumerConfig.GroupID, orderEventHandler)
+	consumerRunner := messaging.NewConsumerRunner(consumer, idempotentHandler, producer, consumerConfig.GroupID, messaging.DefaultRetryPolicy())
+	go startEventConsumption(consumerCtx, consumerRunner, consumerConfig.Topics, log)
+	// Forget processed events once they can no longer be redelivered
+	go processedEvents.RunPurge(consumerCtx, time.Hour, messaging.DefaultProcessedEventRetention)
+
+	// Relay outbox events, such as captured payments, to the event stream
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	outboxRelay := messaging.NewOutboxRelay(db.DB, messaging.NewValidatingProducer(producer, schemaRegistry), messaging.DefaultOutboxRelayConfig())
+	go outboxRelay.Run(relayCtx)
+
+	// Deduplicate retried payment and refund requests by their Idempotency-Key
+	idempotencyStore := middleware.NewPostgresIdempotencyStore(db.DB)
+
+	// Void authorizations left to expire
+	go startBackgroundTasks(paymentService, idempotencyStore, log)
 
 	// Initialize handlers
 	paymentHandler := handlers.NewPaymentHandler(paymentService, log)
@@ -68,12 +149,18 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.New()
+	// Only trust X-Forwarded-For from our own proxies, so clients cannot
+	// choose their IP
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.WithError(err).Fatal("Invalid trusted proxies")
+	}
 
 	// Add middleware
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 	router.Use(middleware.CORS())
 	router.Use(middleware.RequestID())
+	router.Use(middleware.RateLimit(rateLimiter, middleware.DefaultRateLimitConfig()))
 
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
@@ -94,13 +181,14 @@ func main() {
 	})
 
 	// Register routes
-	paymentHandler.RegisterRoutes(router)
+	paymentHandler.RegisterRoutes(router, rateLimiter, idempotencyStore)
 
 	// Add GraphQL endpoints
 	graphqlHandler := graphql.NewGraphQLHandler(paymentService, log)
 	playgroundHandler := graphql.NewPlaygroundHandler()
 
-	router.Any("/graphql", gin.WrapH(graphqlHandler))
+	router.Any("/graphql", middleware.OptionalJWTAuth(middleware.DefaultAuthConfig()), middleware.Tenant(),
+		middleware.Idempotency(idempotencyStore, middleware.DefaultIdempotencyConfig()), gin.WrapH(graphqlHandler))
 	router.GET("/graphql/playground", gin.WrapH(playgroundHandler))
 
 	// Start server
@@ -138,14 +226,34 @@ func main() {
 	log.Info("Payment Service stopped")
 }
 
-// runMigrations runs database migrations
-func runMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&models.Payment{},
-		&models.PaymentMethod{},
-		&models.PaymentGateway{},
-		&models.Refund{},
-		&models.PaymentEvent{},
-		&models.Settlement{},
-	)
+// startEventConsumption handles event consumption from the messaging system.
+// Messages that keep failing are moved to their dead-letter topic.
+func startEventConsumption(ctx context.Context, runner *messaging.ConsumerRunner, topics []string, log *logger.Logger) {
+	log.Info("Starting event consumption for payment service")
+	if err := runner.Run(ctx, topics); err != nil && err != context.Canceled {
+		log.WithError(err).Error("Event consumption stopped")
+	}
+}
+
+// startBackgroundTasks starts background tasks for payment management
+func startBackgroundTasks(service *service.PaymentService, idempotency *middleware.PostgresIdempotencyStore, log *logger.Logger) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		log.Debug("Running background payment processing tasks")
+
+		// Release the rest of authorizations that were not captured in time
+		expired, err := service.ExpireAuthorizations(context.Background())
+		if err != nil {
+			log.WithError(err).Error("Failed to expire authorizations")
+		} else if expired > 0 {
+			log.WithField("count", expired).Info("Expired authorizations")
+		}
+
+		// Forget idempotency keys past their retention
+		if _, err := idempotency.DeleteExpired(context.Background()); err != nil {
+			log.WithError(err).Error("Failed to delete expired idempotency keys")
+		}
+	}
 }
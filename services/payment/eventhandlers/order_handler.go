@@ -0,0 +1,439 @@
+package eventhandlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/payment/models"
+	"unified-commerce/services/payment/service"
+	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/tenant"
+	"unified-commerce/shared/messaging"
+)
+
+// OrderEventHandler handles events published by the order service: refunded
+// returns, draft orders awaiting payment, captures of authorized payments,
+// edited orders and the evidence of disputed payments
+type OrderEventHandler struct {
+	paymentService *service.PaymentService
+	schemas        *messaging.SchemaRegistry
+	log            *logger.Logger
+}
+
+// NewOrderEventHandler creates a new OrderEventHandler
+func NewOrderEventHandler(paymentService *service.PaymentService, schemas *messaging.SchemaRegistry, log *logger.Logger) *OrderEventHandler {
+	return &OrderEventHandler{
+		paymentService: paymentService,
+		schemas:        schemas,
+		log:            log,
+	}
+}
+
+// ReturnRefundedEvent represents the fields of the return.refunded v1 event
+// that payment reads
+type ReturnRefundedEvent struct {
+	ReturnID     uuid.UUID `json:"return_id"`
+	ReturnNumber string    `json:"return_number"`
+	OrderID      uuid.UUID `json:"order_id"`
+	Reason       string    `json:"reason"`
+	RefundAmount float64   `json:"refund_amount"`
+}
+
+// DraftOrderPaymentRequestedEvent represents the fields of the
+// draft_order.payment_requested v1 event that payment reads
+type DraftOrderPaymentRequestedEvent struct {
+	ID            uuid.UUID        `json:"id"`
+	OrderID       uuid.UUID        `json:"order_id"`
+	MerchantID    uuid.UUID        `json:"merchant_id"`
+	CustomerID    *uuid.UUID       `json:"customer_id"`
+	Email         string           `json:"email"`
+	Amount        float64          `json:"amount"`
+	Currency      string           `json:"currency"`
+	PaymentMethod PaymentMethodDTO `json:"payment_method"`
+}
+
+// OrderCaptureRequestedEvent represents the order.capture_requested v1
+// event. A nil amount captures all that is left of the authorization.
+type OrderCaptureRequestedEvent struct {
+	OrderID    uuid.UUID `json:"order_id"`
+	MerchantID uuid.UUID `json:"merchant_id"`
+	Amount     *float64  `json:"amount"`
+	Currency   string    `json:"currency"`
+}
+
+// OrderDisputeEvidenceEvent represents the order.dispute_evidence v1 event,
+// the evidence of a dispute assembled from its order
+type OrderDisputeEvidenceEvent struct {
+	DisputeID  uuid.UUID              `json:"dispute_id"`
+	OrderID    uuid.UUID              `json:"order_id"`
+	MerchantID uuid.UUID              `json:"merchant_id"`
+	Evidence   map[string]interface{} `json:"evidence"`
+}
+
+// OrderEditedEvent represents the fields of the order.edited v1 event that
+// payment reads. On orders with captured funds a positive payment delta is
+// captured from the order's authorization and a negative one is refunded.
+// PaymentStatus is the order's payment status before the edit.
+type OrderEditedEvent struct {
+	OrderID       uuid.UUID `json:"order_id"`
+	OrderEditID   uuid.UUID `json:"order_edit_id"`
+	MerchantID    uuid.UUID `json:"merchant_id"`
+	Currency      string    `json:"currency"`
+	PaymentStatus string    `json:"payment_status"`
+	PaymentDelta  float64   `json:"payment_delta"`
+}
+
+// hasCapturedFunds reports whether the order's payment status means some of
+// its payment was captured and not all of it refunded
+func (e *OrderEditedEvent) hasCapturedFunds() bool {
+	switch e.PaymentStatus {
+	case "paid", "partially_paid", "partially_refunded":
+		return true
+	default:
+		return false
+	}
+}
+
+type PaymentMethodDTO struct {
+	Type        string `json:"type"`
+	Provider    string `json:"provider"`
+	Token       string `json:"token"`
+	Last4       string `json:"last4"`
+	Brand       string `json:"brand"`
+	ExpiryMonth int    `json:"expiry_month"`
+	ExpiryYear  int    `json:"expiry_year"`
+}
+
+// HandleMessage implements messaging.MessageHandler, dispatching on topic
+func (h *OrderEventHandler) HandleMessage(msg *messaging.Message) error {
+	return h.HandleMessageContext(context.Background(), msg)
+}
+
+// HandleMessageContext implements messaging.ContextMessageHandler. When the
+// context carries a deduplication transaction, refunds and payments are
+// created inside it.
+func (h *OrderEventHandler) HandleMessageContext(ctx context.Context, msg *messaging.Message) error {
+	switch msg.Topic {
+	case "returns.refunded":
+		return h.handleReturnRefundedEvent(ctx, msg.Value)
+	case "draft_orders.payment_requested":
+		return h.handleDraftOrderPaymentRequestedEvent(ctx, msg.Value)
+	case "orders.capture_requested":
+		return h.handleOrderCaptureRequestedEvent(ctx, msg.Value)
+	case "orders.edited":
+		return h.handleOrderEditedEvent(ctx, msg.Value)
+	case "orders.dispute_evidence":
+		return h.handleOrderDisputeEvidenceEvent(ctx, msg.Value)
+	default:
+		return messaging.Permanent(fmt.Errorf("unexpected topic %s", msg.Topic))
+	}
+}
+
+// handleReturnRefundedEvent validates and decodes a return refunded event
+// and refunds the amount from the order's payment
+func (h *OrderEventHandler) handleReturnRefundedEvent(ctx context.Context, messageBytes []byte) error {
+	env, err := h.schemas.ValidatePayload(messageBytes)
+	if err != nil {
+		h.log.WithError(err).Error("Rejected invalid ReturnRefunded event")
+		return messaging.Permanent(err)
+	}
+
+	var event ReturnRefundedEvent
+	if err := env.Decode(&event); err != nil {
+		h.log.WithError(err).Error("Failed to decode ReturnRefunded event")
+		return messaging.Permanent(err)
+	}
+
+	// Restocking fees can take up the whole refund
+	if event.RefundAmount <= 0 {
+		h.log.WithField("return_id", event.ReturnID).Info("Return has nothing to refund")
+		return nil
+	}
+
+	// Returns of every merchant arrive on this topic
+	ctx = tenant.WithoutScope(ctx)
+
+	req := &service.RefundOrderRequest{
+		OrderID: event.OrderID,
+		Amount:  event.RefundAmount,
+		Reason:  refundReason(event.Reason),
+	}
+	var refund *models.Refund
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		// Commit the refund together with the processed-event record
+		refund, err = h.paymentService.RefundOrderInTx(ctx, tx, req)
+	} else {
+		refund, err = h.paymentService.RefundOrder(ctx, req)
+	}
+	if err != nil {
+		h.log.WithError(err).WithField("return_id", event.ReturnID).Error("Failed to refund return")
+		// A missing or uncaptured payment, or an amount it cannot cover,
+		// will not change on retry
+		if errors.Is(err, service.ErrPaymentNotFound) || errors.Is(err, service.ErrInvalidPaymentStatus) ||
+			errors.Is(err, service.ErrRefundAmountExceeds) {
+			return messaging.Permanent(err)
+		}
+		return err
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"return_id": event.ReturnID,
+		"order_id":  event.OrderID,
+		"refund_id": refund.ID,
+	}).Info("Successfully refunded return")
+	return nil
+}
+
+// handleDraftOrderPaymentRequestedEvent validates and decodes a draft order
+// payment request and charges the customer
+func (h *OrderEventHandler) handleDraftOrderPaymentRequestedEvent(ctx context.Context, messageBytes []byte) error {
+	env, err := h.schemas.ValidatePayload(messageBytes)
+	if err != nil {
+		h.log.WithError(err).Error("Rejected invalid DraftOrderPaymentRequested event")
+		return messaging.Permanent(err)
+	}
+
+	var event DraftOrderPaymentRequestedEvent
+	if err := env.Decode(&event); err != nil {
+		h.log.WithError(err).Error("Failed to decode DraftOrderPaymentRequested event")
+		return messaging.Permanent(err)
+	}
+
+	// Draft orders of every merchant arrive on this topic
+	ctx = tenant.WithoutScope(ctx)
+
+	req := &service.PayDraftOrderRequest{
+		DraftOrderID: event.ID,
+		OrderID:      event.OrderID,
+		MerchantID:   event.MerchantID,
+		CustomerID:   event.CustomerID,
+		Email:        event.Email,
+		Amount:       event.Amount,
+		Currency:     event.Currency,
+		PaymentMethod: service.CreatePaymentMethodRequest{
+			Type:        models.PaymentMethodType(event.PaymentMethod.Type),
+			Provider:    event.PaymentMethod.Provider,
+			Token:       event.PaymentMethod.Token,
+			Last4:       event.PaymentMethod.Last4,
+			Brand:       event.PaymentMethod.Brand,
+			ExpiryMonth: event.PaymentMethod.ExpiryMonth,
+			ExpiryYear:  event.PaymentMethod.ExpiryYear,
+		},
+	}
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		// Commit the payment and its outcome event together with the
+		// processed-event record
+		_, err = h.paymentService.PayDraftOrderInTx(ctx, tx, req)
+	} else {
+		_, err = h.paymentService.PayDraftOrder(ctx, req)
+	}
+	if err != nil {
+		h.log.WithError(err).WithField("draft_order_id", event.ID).Error("Failed to pay draft order")
+		return err
+	}
+	return nil
+}
+
+// handleOrderCaptureRequestedEvent validates and decodes a capture request
+// and captures the order's authorized payment
+func (h *OrderEventHandler) handleOrderCaptureRequestedEvent(ctx context.Context, messageBytes []byte) error {
+	env, err := h.schemas.ValidatePayload(messageBytes)
+	if err != nil {
+		h.log.WithError(err).Error("Rejected invalid OrderCaptureRequested event")
+		return messaging.Permanent(err)
+	}
+
+	var event OrderCaptureRequestedEvent
+	if err := env.Decode(&event); err != nil {
+		h.log.WithError(err).Error("Failed to decode OrderCaptureRequested event")
+		return messaging.Permanent(err)
+	}
+
+	// Orders of every merchant arrive on this topic
+	ctx = tenant.WithoutScope(ctx)
+
+	var payment *models.Payment
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		// Commit the capture and its event together with the
+		// processed-event record
+		payment, err = h.paymentService.CaptureOrderPaymentInTx(ctx, tx, event.OrderID, event.Amount)
+	} else {
+		payment, err = h.paymentService.CaptureOrderPayment(ctx, event.OrderID, event.Amount)
+	}
+	if err != nil {
+		h.log.WithError(err).WithField("order_id", event.OrderID).Error("Failed to capture order payment")
+		// An authorization that is used up, expired or too small, or a
+		// capture the gateway declined, will not change on retry
+		if errors.Is(err, service.ErrNothingToCapture) || errors.Is(err, service.ErrAuthorizationExpired) ||
+			errors.Is(err, service.ErrInvalidCaptureAmount) || errors.Is(err, service.ErrCaptureFailed) {
+			return messaging.Permanent(err)
+		}
+		return err
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"order_id":        event.OrderID,
+		"payment_id":      payment.ID,
+		"captured_amount": payment.CapturedAmount,
+	}).Info("Successfully captured order payment")
+	return nil
+}
+
+// handleOrderEditedEvent validates and decodes an order edit and, once the
+// order's payment was captured, captures or refunds the difference the edit
+// made to the order's total
+func (h *OrderEventHandler) handleOrderEditedEvent(ctx context.Context, messageBytes []byte) error {
+	env, err := h.schemas.ValidatePayload(messageBytes)
+	if err != nil {
+		h.log.WithError(err).Error("Rejected invalid OrderEdited event")
+		return messaging.Permanent(err)
+	}
+
+	var event OrderEditedEvent
+	if err := env.Decode(&event); err != nil {
+		h.log.WithError(err).Error("Failed to decode OrderEdited event")
+		return messaging.Permanent(err)
+	}
+
+	if event.PaymentDelta == 0 {
+		h.log.WithField("order_edit_id", event.OrderEditID).Info("Order edit has nothing to capture or refund")
+		return nil
+	}
+	// Nothing was captured yet, so the order's capture takes its new total
+	if !event.hasCapturedFunds() {
+		h.log.WithField("order_edit_id", event.OrderEditID).WithField("payment_status", event.PaymentStatus).
+			Info("Order edit left to the order's capture")
+		return nil
+	}
+
+	// Orders of every merchant arrive on this topic
+	ctx = tenant.WithoutScope(ctx)
+
+	if event.PaymentDelta > 0 {
+		return h.captureOrderEdit(ctx, &event)
+	}
+	return h.refundOrderEdit(ctx, &event)
+}
+
+// captureOrderEdit captures the amount an order edit added to the order
+// from the order's authorized payment
+func (h *OrderEventHandler) captureOrderEdit(ctx context.Context, event *OrderEditedEvent) error {
+	amount := event.PaymentDelta
+	var payment *models.Payment
+	var err error
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		// Commit the capture and its event together with the
+		// processed-event record
+		payment, err = h.paymentService.CaptureOrderPaymentInTx(ctx, tx, event.OrderID, &amount)
+	} else {
+		payment, err = h.paymentService.CaptureOrderPayment(ctx, event.OrderID, &amount)
+	}
+	if err != nil {
+		h.log.WithError(err).WithField("order_edit_id", event.OrderEditID).Error("Failed to capture order edit")
+		// An authorization that is used up, expired or too small, or a
+		// capture the gateway declined, will not change on retry
+		if errors.Is(err, service.ErrNothingToCapture) || errors.Is(err, service.ErrAuthorizationExpired) ||
+			errors.Is(err, service.ErrInvalidCaptureAmount) || errors.Is(err, service.ErrCaptureFailed) {
+			return messaging.Permanent(err)
+		}
+		return err
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"order_id":        event.OrderID,
+		"order_edit_id":   event.OrderEditID,
+		"payment_id":      payment.ID,
+		"captured_amount": amount,
+	}).Info("Successfully captured order edit")
+	return nil
+}
+
+// refundOrderEdit refunds the amount an order edit took off the order from
+// the order's payment
+func (h *OrderEventHandler) refundOrderEdit(ctx context.Context, event *OrderEditedEvent) error {
+	req := &service.RefundOrderRequest{
+		OrderID: event.OrderID,
+		Amount:  -event.PaymentDelta,
+		Reason:  models.RefundReasonOther,
+	}
+	var refund *models.Refund
+	var err error
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		// Commit the refund together with the processed-event record
+		refund, err = h.paymentService.RefundOrderInTx(ctx, tx, req)
+	} else {
+		refund, err = h.paymentService.RefundOrder(ctx, req)
+	}
+	if err != nil {
+		h.log.WithError(err).WithField("order_edit_id", event.OrderEditID).Error("Failed to refund order edit")
+		// A missing or uncaptured payment, or an amount it cannot cover,
+		// will not change on retry
+		if errors.Is(err, service.ErrPaymentNotFound) || errors.Is(err, service.ErrInvalidPaymentStatus) ||
+			errors.Is(err, service.ErrRefundAmountExceeds) {
+			return messaging.Permanent(err)
+		}
+		return err
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"order_id":      event.OrderID,
+		"order_edit_id": event.OrderEditID,
+		"refund_id":     refund.ID,
+	}).Info("Successfully refunded order edit")
+	return nil
+}
+
+// handleOrderDisputeEvidenceEvent validates and decodes the evidence the
+// order service assembled for a dispute and adds it to the dispute
+func (h *OrderEventHandler) handleOrderDisputeEvidenceEvent(ctx context.Context, messageBytes []byte) error {
+	env, err := h.schemas.ValidatePayload(messageBytes)
+	if err != nil {
+		h.log.WithError(err).Error("Rejected invalid OrderDisputeEvidence event")
+		return messaging.Permanent(err)
+	}
+
+	var event OrderDisputeEvidenceEvent
+	if err := env.Decode(&event); err != nil {
+		h.log.WithError(err).Error("Failed to decode OrderDisputeEvidence event")
+		return messaging.Permanent(err)
+	}
+
+	// Orders of every merchant arrive on this topic
+	ctx = tenant.WithoutScope(ctx)
+
+	evidence := &service.DisputeEvidence{
+		DisputeID: event.DisputeID,
+		Evidence:  event.Evidence,
+	}
+	if tx, ok := messaging.TxFromContext(ctx); ok {
+		// Commit the evidence together with the processed-event record
+		err = h.paymentService.AttachDisputeEvidenceInTx(ctx, tx, evidence)
+	} else {
+		err = h.paymentService.AttachDisputeEvidence(ctx, evidence)
+	}
+	if err != nil {
+		h.log.WithError(err).WithField("dispute_id", event.DisputeID).Error("Failed to attach dispute evidence")
+		if errors.Is(err, service.ErrDisputeNotFound) {
+			return messaging.Permanent(err)
+		}
+		return err
+	}
+	return nil
+}
+
+// refundReason maps the reason of a return to the reason of its refund
+func refundReason(returnReason string) models.RefundReason {
+	switch returnReason {
+	case "defective":
+		return models.RefundReasonProductDefective
+	case "damaged":
+		return models.RefundReasonProductDamaged
+	case "not_as_described":
+		return models.RefundReasonProductNotAsDescribed
+	default:
+		return models.RefundReasonCustomerRequest
+	}
+}
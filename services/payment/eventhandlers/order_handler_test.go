@@ -0,0 +1,89 @@
+package eventhandlers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/payment/eventhandlers"
+	"unified-commerce/services/payment/gateway"
+	"unified-commerce/services/payment/migrations"
+	"unified-commerce/services/payment/models"
+	"unified-commerce/services/payment/repository"
+	"unified-commerce/services/payment/service"
+	"unified-commerce/services/shared/database/dbtest"
+	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/tenant"
+	"unified-commerce/shared/messaging"
+)
+
+func TestOrderEventHandler_LeavesEditsOfUncapturedOrdersToCapture(t *testing.T) {
+	log := logger.NewLogger(logger.Config{Level: "error"})
+	repo := repository.NewPaymentRepository(dbtest.Open(t, "payment", migrations.All()), log)
+	schemas, err := messaging.DefaultSchemaRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := eventhandlers.NewOrderEventHandler(service.NewPaymentService(repo, log, schemas, gateway.DefaultRegistry()), schemas, log)
+
+	merchantID := uuid.New()
+	ctx := tenant.WithMerchantID(context.Background(), merchantID.String())
+	paymentGateway := &models.PaymentGateway{ID: uuid.New(), Name: "simulator-" + uuid.NewString(), Provider: gateway.SimulatorProvider}
+	if err := repo.CreateGateway(ctx, paymentGateway); err != nil {
+		t.Fatal(err)
+	}
+	method := &models.PaymentMethod{ID: uuid.New(), MerchantID: &merchantID, Type: models.PaymentMethodTypeCreditCard}
+	if err := repo.CreatePaymentMethod(ctx, method); err != nil {
+		t.Fatal(err)
+	}
+	// Authorized for more than the order's total, so a capture could succeed
+	payment := &models.Payment{
+		ID:               uuid.New(),
+		OrderID:          uuid.New(),
+		MerchantID:       merchantID,
+		PaymentMethodID:  method.ID,
+		GatewayID:        paymentGateway.ID,
+		Status:           models.PaymentStatusAuthorized,
+		CaptureMethod:    models.CaptureMethodManual,
+		Amount:           150,
+		Currency:         "USD",
+		AuthorizedAmount: 150,
+		GatewayReference: "ref-1",
+	}
+	if err := repo.CreatePayment(ctx, payment); err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := messaging.NewEnvelope(ctx, messaging.EventTypeOrderEdited, 1, merchantID.String(), map[string]interface{}{
+		"order_id":             payment.OrderID,
+		"order_edit_id":        uuid.New(),
+		"merchant_id":          merchantID,
+		"currency":             "USD",
+		"payment_status":       "authorized",
+		"original_total_price": 100,
+		"total_price":          120,
+		"payment_delta":        20,
+		"line_items": []map[string]interface{}{
+			{"type": "add_line_item", "line_item_id": uuid.New(), "quantity_from": 0, "quantity_to": 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := env.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.HandleMessage(&messaging.Message{Topic: "orders.edited", Value: value}); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := repo.GetPayment(ctx, payment.ID)
+	if err != nil || found == nil {
+		t.Fatalf("reload payment: payment = %v, err = %v", found, err)
+	}
+	if found.CapturedAmount != 0 || found.Status != models.PaymentStatusAuthorized {
+		t.Fatalf("payment %s with %.2f captured, want the authorization left to the order's capture", found.Status, found.CapturedAmount)
+	}
+}
@@ -0,0 +1,222 @@
+// Package gateway connects payments to the payment providers that move the
+// money. Each provider is reached through a GatewayAdapter, built from a
+// PaymentGateway's credentials and settings by the factory registered for
+// its Provider.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/payment/models"
+)
+
+// Gateway errors
+var (
+	ErrUnknownProvider  = errors.New("unknown payment provider")
+	ErrTimeout          = errors.New("payment gateway timed out")
+	ErrInvalidCard      = errors.New("invalid card details")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidPayload   = errors.New("invalid webhook payload")
+)
+
+// Status is the outcome of a gateway operation
+type Status string
+
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusDeclined  Status = "declined"
+	// StatusActionRequired means the customer must complete a challenge,
+	// such as 3-D Secure, before the authorization can succeed
+	StatusActionRequired Status = "action_required"
+)
+
+// Result is what the gateway answered to an operation
+type Result struct {
+	Status      Status
+	Reference   string  // the gateway's ID of the operation
+	DeclineCode string  // why a declined operation was declined
+	Message     string  // the gateway's explanation of the outcome
+	ActionURL   string  // where the customer completes a required action
+	Fee         float64 // what the gateway charged for the operation
+}
+
+// Succeeded reports whether the operation succeeded
+func (r *Result) Succeeded() bool {
+	return r.Status == StatusSucceeded
+}
+
+// AuthorizeRequest asks the gateway to hold an amount on a payment method.
+// With Capture the amount is also captured, as a sale.
+type AuthorizeRequest struct {
+	PaymentID   uuid.UUID
+	Amount      float64
+	Currency    string
+	Token       string // the payment method's token
+	Capture     bool
+	Description string
+}
+
+// CaptureRequest asks the gateway to capture an amount of an authorization
+type CaptureRequest struct {
+	PaymentID uuid.UUID
+	Reference string // the authorization's reference
+	Amount    float64
+	Currency  string
+}
+
+// VoidRequest asks the gateway to release what remains of an authorization
+type VoidRequest struct {
+	PaymentID uuid.UUID
+	Reference string // the authorization's reference
+}
+
+// RefundRequest asks the gateway to return an amount of a captured payment
+type RefundRequest struct {
+	PaymentID uuid.UUID
+	RefundID  uuid.UUID
+	Reference string // the capture's reference
+	Amount    float64
+	Currency  string
+}
+
+// DisputeEvidenceRequest submits a merchant's evidence against a dispute
+type DisputeEvidenceRequest struct {
+	PaymentID uuid.UUID
+	DisputeID uuid.UUID
+	Reference string // the gateway's ID of the dispute
+	Evidence  map[string]interface{}
+}
+
+// Card holds card details to tokenize. They are passed to the gateway and
+// never stored.
+type Card struct {
+	Number      string `json:"number"`
+	ExpiryMonth int    `json:"expiry_month"`
+	ExpiryYear  int    `json:"expiry_year"`
+	CVC         string `json:"cvc"`
+	Name        string `json:"name"`
+}
+
+// Token is the gateway's stand-in for a tokenized card
+type Token struct {
+	Token       string
+	Last4       string
+	Brand       string
+	ExpiryMonth int
+	ExpiryYear  int
+}
+
+// EventType is the kind of change a gateway reports in a webhook
+type EventType string
+
+const (
+	EventCaptured EventType = "captured"
+	EventFailed   EventType = "failed"
+	EventRefunded EventType = "refunded"
+	EventDisputed EventType = "disputed"
+	// EventDisputeWon and EventDisputeLost report the card issuer's
+	// decision on a dispute
+	EventDisputeWon  EventType = "dispute_won"
+	EventDisputeLost EventType = "dispute_lost"
+)
+
+// WebhookEvent is a change the gateway reported about one of its
+// operations
+type WebhookEvent struct {
+	ID         string // the gateway's ID of the event, unique per gateway
+	Type       EventType
+	Reference  string // the reference of the operation the event is about
+	Amount     float64
+	Currency   string
+	Reason     string
+	OccurredAt time.Time
+	SignedAt   time.Time // when the gateway signed the webhook carrying the event
+
+	// Dispute events name the gateway's dispute, and opened disputes when
+	// the merchant's evidence is due. Reference is the disputed payment's.
+	DisputeID     string
+	EvidenceDueBy time.Time
+}
+
+// GatewayAdapter performs payment operations with one provider. Declines
+// are results, not errors; errors mean the outcome is unknown, such as
+// ErrTimeout, or that the request was invalid.
+type GatewayAdapter interface {
+	// Provider returns the provider the adapter reaches, as stored in
+	// PaymentGateway.Provider
+	Provider() string
+	Authorize(ctx context.Context, req *AuthorizeRequest) (*Result, error)
+	Capture(ctx context.Context, req *CaptureRequest) (*Result, error)
+	Void(ctx context.Context, req *VoidRequest) (*Result, error)
+	Refund(ctx context.Context, req *RefundRequest) (*Result, error)
+	Tokenize(ctx context.Context, card *Card) (*Token, error)
+	SubmitDisputeEvidence(ctx context.Context, req *DisputeEvidenceRequest) (*Result, error)
+	// ParseWebhook authenticates a webhook the provider sent and returns
+	// the events it reports, stamped with when the webhook was signed so
+	// old webhooks can be refused as replays
+	ParseWebhook(header http.Header, body []byte) ([]WebhookEvent, error)
+}
+
+// Config is what a factory builds an adapter from
+type Config struct {
+	GatewayID   uuid.UUID
+	Sandbox     bool
+	Credentials map[string]interface{}
+	Settings    map[string]interface{}
+}
+
+// Credential returns a credential as a string, or "" when it is not set
+func (c Config) Credential(key string) string {
+	if value, ok := c.Credentials[key].(string); ok {
+		return value
+	}
+	return ""
+}
+
+// Factory builds the adapter of a gateway
+type Factory func(config Config) (GatewayAdapter, error)
+
+// Registry holds the factories of the providers payments can be made with
+type Registry struct {
+	factories map[string]Factory
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// DefaultRegistry returns a registry of the built-in providers
+func DefaultRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register(SimulatorProvider, func(config Config) (GatewayAdapter, error) {
+		return NewSimulator(config.Credential("webhook_secret")), nil
+	})
+	return registry
+}
+
+// Register adds the factory of a provider, replacing any registered before
+func (r *Registry) Register(provider string, factory Factory) {
+	r.factories[provider] = factory
+}
+
+// Open returns the adapter of a gateway, or ErrUnknownProvider when no
+// factory is registered for its provider
+func (r *Registry) Open(gateway *models.PaymentGateway) (GatewayAdapter, error) {
+	factory, ok := r.factories[gateway.Provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, gateway.Provider)
+	}
+	return factory(Config{
+		GatewayID:   gateway.ID,
+		Sandbox:     gateway.IsSandbox,
+		Credentials: gateway.Credentials,
+		Settings:    gateway.Settings,
+	})
+}
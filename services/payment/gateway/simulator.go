@@ -0,0 +1,346 @@
+package gateway
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SimulatorProvider is the provider of the local simulator
+const SimulatorProvider = "simulator"
+
+// SimulatorSignatureHeader carries when a simulator webhook was signed and
+// its signature, as t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">
+const SimulatorSignatureHeader = "Simulator-Signature"
+
+// Simulator test cards. Every other valid card number succeeds.
+const (
+	CardSuccess           = "4242424242424242"
+	CardDeclined          = "4000000000000002"
+	CardInsufficientFunds = "4000000000009995"
+	CardExpired           = "4000000000000069"
+	CardChallenge         = "4000000000003220" // requires 3-D Secure
+	CardTimeout           = "4000000000000119"
+)
+
+// Decline codes of the simulator's test cards
+const (
+	DeclineCardDeclined      = "card_declined"
+	DeclineInsufficientFunds = "insufficient_funds"
+	DeclineExpiredCard       = "expired_card"
+)
+
+// Outcome scripts how the simulator answers an operation. The zero Outcome
+// succeeds.
+type Outcome struct {
+	DeclineCode string // declines the operation with the code
+	Timeout     bool   // fails the operation with ErrTimeout
+	Challenge   bool   // makes an authorization require 3-D Secure
+}
+
+// simulatorCards are the outcomes of the test cards, by last four digits
+var simulatorCards = map[string]Outcome{
+	last4(CardDeclined):          {DeclineCode: DeclineCardDeclined},
+	last4(CardInsufficientFunds): {DeclineCode: DeclineInsufficientFunds},
+	last4(CardExpired):           {DeclineCode: DeclineExpiredCard},
+	last4(CardChallenge):         {Challenge: true},
+	last4(CardTimeout):           {Timeout: true},
+}
+
+// Simulator is a deterministic GatewayAdapter that moves no money, for
+// development and tests. Authorizations answer as the test card of the
+// payment method's token does, unless outcomes are scripted; scripted
+// outcomes answer the next operations of any kind, in order. References
+// are numbered in the order operations are made.
+type Simulator struct {
+	secret []byte
+
+	mu       sync.Mutex
+	scripted []Outcome
+	sequence int
+}
+
+// NewSimulator creates a simulator whose webhooks are signed with secret
+func NewSimulator(secret string) *Simulator {
+	return &Simulator{secret: []byte(secret)}
+}
+
+// Script queues outcomes for the next operations
+func (s *Simulator) Script(outcomes ...Outcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripted = append(s.scripted, outcomes...)
+}
+
+// Provider implements GatewayAdapter
+func (s *Simulator) Provider() string {
+	return SimulatorProvider
+}
+
+// Authorize implements GatewayAdapter
+func (s *Simulator) Authorize(ctx context.Context, req *AuthorizeRequest) (*Result, error) {
+	outcome, reference := s.next("auth", simulatorCards[tokenLast4(req.Token)])
+	if req.Capture {
+		reference = strings.Replace(reference, "auth", "sale", 1)
+	}
+
+	result, err := outcome.result(reference)
+	if err != nil || !result.Succeeded() {
+		return result, err
+	}
+	if outcome.Challenge {
+		return &Result{
+			Status:    StatusActionRequired,
+			Reference: reference,
+			Message:   "3-D Secure authentication required",
+			ActionURL: "https://simulator.invalid/3ds/" + reference,
+		}, nil
+	}
+	if req.Capture {
+		result.Fee = simulatorFee(req.Amount)
+	}
+	return result, nil
+}
+
+// Capture implements GatewayAdapter
+func (s *Simulator) Capture(ctx context.Context, req *CaptureRequest) (*Result, error) {
+	outcome, reference := s.next("cap", Outcome{})
+	result, err := outcome.result(reference)
+	if err == nil && result.Succeeded() {
+		result.Fee = simulatorFee(req.Amount)
+	}
+	return result, err
+}
+
+// Void implements GatewayAdapter
+func (s *Simulator) Void(ctx context.Context, req *VoidRequest) (*Result, error) {
+	outcome, reference := s.next("void", Outcome{})
+	return outcome.result(reference)
+}
+
+// Refund implements GatewayAdapter
+func (s *Simulator) Refund(ctx context.Context, req *RefundRequest) (*Result, error) {
+	outcome, reference := s.next("ref", Outcome{})
+	return outcome.result(reference)
+}
+
+// Tokenize implements GatewayAdapter. Tokens name the card's brand and last
+// four digits, which is how authorizations find the test card's outcome.
+func (s *Simulator) Tokenize(ctx context.Context, card *Card) (*Token, error) {
+	number := strings.ReplaceAll(card.Number, " ", "")
+	if !validCardNumber(number) || card.ExpiryMonth < 1 || card.ExpiryMonth > 12 || card.ExpiryYear < 1 {
+		return nil, ErrInvalidCard
+	}
+
+	brand := cardBrand(number)
+	return &Token{
+		Token:       fmt.Sprintf("tok_sim_%s_%s", brand, last4(number)),
+		Last4:       last4(number),
+		Brand:       brand,
+		ExpiryMonth: card.ExpiryMonth,
+		ExpiryYear:  card.ExpiryYear,
+	}, nil
+}
+
+// SubmitDisputeEvidence implements GatewayAdapter
+func (s *Simulator) SubmitDisputeEvidence(ctx context.Context, req *DisputeEvidenceRequest) (*Result, error) {
+	outcome, reference := s.next("evd", Outcome{})
+	return outcome.result(reference)
+}
+
+// simulatorWebhook is the body of a simulator webhook
+type simulatorWebhook struct {
+	ID            string    `json:"id"`
+	Type          EventType `json:"type"`
+	Reference     string    `json:"reference"`
+	Amount        float64   `json:"amount"`
+	Currency      string    `json:"currency"`
+	Reason        string    `json:"reason"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	DisputeID     string    `json:"dispute_id"`
+	EvidenceDueBy time.Time `json:"evidence_due_by"`
+}
+
+// ParseWebhook implements GatewayAdapter. Webhooks are single events signed
+// with the simulator's secret; without a secret every webhook is rejected.
+func (s *Simulator) ParseWebhook(header http.Header, body []byte) ([]WebhookEvent, error) {
+	if len(s.secret) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	signedAt, signature, err := parseSimulatorSignature(header.Get(SimulatorSignatureHeader))
+	if err != nil || !hmac.Equal(signature, s.sign(signedAt, body)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var webhook simulatorWebhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	switch webhook.Type {
+	case EventCaptured, EventFailed, EventRefunded:
+	case EventDisputed, EventDisputeWon, EventDisputeLost:
+		if webhook.DisputeID == "" {
+			return nil, fmt.Errorf("%w: dispute_id is required", ErrInvalidPayload)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown event type %q", ErrInvalidPayload, webhook.Type)
+	}
+	if webhook.ID == "" || webhook.Reference == "" {
+		return nil, fmt.Errorf("%w: id and reference are required", ErrInvalidPayload)
+	}
+
+	return []WebhookEvent{{
+		ID:            webhook.ID,
+		Type:          webhook.Type,
+		Reference:     webhook.Reference,
+		Amount:        webhook.Amount,
+		Currency:      webhook.Currency,
+		Reason:        webhook.Reason,
+		OccurredAt:    webhook.OccurredAt,
+		SignedAt:      time.Unix(signedAt, 0),
+		DisputeID:     webhook.DisputeID,
+		EvidenceDueBy: webhook.EvidenceDueBy,
+	}}, nil
+}
+
+// Sign returns the signature header value of a webhook body signed now, for
+// sending simulated webhooks
+func (s *Simulator) Sign(body []byte) string {
+	return s.SignAt(body, time.Now())
+}
+
+// SignAt returns the signature header value of a webhook body signed at a
+// given time
+func (s *Simulator) SignAt(body []byte, at time.Time) string {
+	return fmt.Sprintf("t=%d,v1=%s", at.Unix(), hex.EncodeToString(s.sign(at.Unix(), body)))
+}
+
+// sign returns the HMAC-SHA256 of a webhook body and when it was signed
+func (s *Simulator) sign(signedAt int64, body []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strconv.FormatInt(signedAt, 10) + "."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// parseSimulatorSignature splits a signature header into when the webhook
+// was signed and its signature
+func parseSimulatorSignature(value string) (int64, []byte, error) {
+	var (
+		signedAt  int64
+		signature []byte
+		err       error
+	)
+	for _, part := range strings.Split(value, ",") {
+		name, field, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "t":
+			signedAt, err = strconv.ParseInt(field, 10, 64)
+		case "v1":
+			signature, err = hex.DecodeString(field)
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+	if signedAt == 0 || signature == nil {
+		return 0, nil, ErrInvalidSignature
+	}
+	return signedAt, signature, nil
+}
+
+// next takes the next scripted outcome, or fallback when none is queued,
+// and numbers the operation's reference
+func (s *Simulator) next(kind string, fallback Outcome) (Outcome, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	outcome := fallback
+	if len(s.scripted) > 0 {
+		outcome = s.scripted[0]
+		s.scripted = s.scripted[1:]
+	}
+	s.sequence++
+	return outcome, fmt.Sprintf("sim_%s_%06d", kind, s.sequence)
+}
+
+// result answers an operation with a scripted timeout or decline, or
+// success
+func (o Outcome) result(reference string) (*Result, error) {
+	switch {
+	case o.Timeout:
+		return nil, ErrTimeout
+	case o.DeclineCode != "":
+		return &Result{
+			Status:      StatusDeclined,
+			Reference:   reference,
+			DeclineCode: o.DeclineCode,
+			Message:     "Declined: " + strings.ReplaceAll(o.DeclineCode, "_", " "),
+		}, nil
+	}
+	return &Result{Status: StatusSucceeded, Reference: reference}, nil
+}
+
+// simulatorFee is the simulator's processing fee of 2.9% plus 0.30
+func simulatorFee(amount float64) float64 {
+	return math.Round((amount*0.029+0.30)*100) / 100
+}
+
+// tokenLast4 returns the last four digits a simulator token names
+func tokenLast4(token string) string {
+	if i := strings.LastIndex(token, "_"); i >= 0 {
+		return token[i+1:]
+	}
+	return ""
+}
+
+func last4(number string) string {
+	if len(number) < 4 {
+		return number
+	}
+	return number[len(number)-4:]
+}
+
+// cardBrand names the brand of a card number by its prefix
+func cardBrand(number string) string {
+	switch {
+	case strings.HasPrefix(number, "4"):
+		return "visa"
+	case strings.HasPrefix(number, "5"):
+		return "mastercard"
+	case strings.HasPrefix(number, "34"), strings.HasPrefix(number, "37"):
+		return "amex"
+	}
+	return "card"
+}
+
+// validCardNumber reports whether a card number has 12 to 19 digits and
+// passes the Luhn check
+func validCardNumber(number string) bool {
+	if len(number) < 12 || len(number) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range number {
+		digit := int(number[len(number)-1-i] - '0')
+		if digit < 0 || digit > 9 {
+			return false
+		}
+		if i%2 == 1 {
+			if digit *= 2; digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
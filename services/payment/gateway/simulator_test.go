@@ -0,0 +1,132 @@
+package gateway_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"unified-commerce/services/payment/gateway"
+	"unified-commerce/services/payment/models"
+)
+
+func tokenize(t *testing.T, sim *gateway.Simulator, number string) string {
+	t.Helper()
+	token, err := sim.Tokenize(context.Background(), &gateway.Card{Number: number, ExpiryMonth: 12, ExpiryYear: 2030})
+	if err != nil {
+		t.Fatalf("failed to tokenize %s: %v", number, err)
+	}
+	return token.Token
+}
+
+func TestSimulator_TestCards(t *testing.T) {
+	ctx := context.Background()
+	sim := gateway.NewSimulator("")
+
+	tests := []struct {
+		card    string
+		status  gateway.Status
+		decline string
+		err     error
+	}{
+		{gateway.CardSuccess, gateway.StatusSucceeded, "", nil},
+		{gateway.CardInsufficientFunds, gateway.StatusDeclined, gateway.DeclineInsufficientFunds, nil},
+		{gateway.CardChallenge, gateway.StatusActionRequired, "", nil},
+		{gateway.CardTimeout, "", "", gateway.ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.card, func(t *testing.T) {
+			result, err := sim.Authorize(ctx, &gateway.AuthorizeRequest{Amount: 100, Token: tokenize(t, sim, tt.card), Capture: true})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if result.Status != tt.status || result.DeclineCode != tt.decline {
+				t.Errorf("expected %s %q, got %+v", tt.status, tt.decline, result)
+			}
+			if result.Succeeded() && result.Fee != 3.20 {
+				t.Errorf("expected a fee of 3.20, got %.2f", result.Fee)
+			}
+		})
+	}
+
+	if _, err := sim.Tokenize(ctx, &gateway.Card{Number: "4242424242424241", ExpiryMonth: 1, ExpiryYear: 2030}); !errors.Is(err, gateway.ErrInvalidCard) {
+		t.Errorf("expected a number failing the Luhn check to be rejected, got %v", err)
+	}
+}
+
+func TestSimulator_ScriptedOutcomes(t *testing.T) {
+	ctx := context.Background()
+	sim := gateway.NewSimulator("")
+	sim.Script(gateway.Outcome{Timeout: true}, gateway.Outcome{DeclineCode: "processing_error"})
+
+	if _, err := sim.Capture(ctx, &gateway.CaptureRequest{Amount: 10}); !errors.Is(err, gateway.ErrTimeout) {
+		t.Fatalf("expected the first scripted outcome to time out, got %v", err)
+	}
+	result, err := sim.Refund(ctx, &gateway.RefundRequest{Amount: 10})
+	if err != nil || result.DeclineCode != "processing_error" {
+		t.Fatalf("expected the second scripted outcome to decline, got %+v, %v", result, err)
+	}
+	result, err = sim.Void(ctx, &gateway.VoidRequest{})
+	if err != nil || !result.Succeeded() || result.Reference != "sim_void_000003" {
+		t.Errorf("expected operations to succeed once the script ran out, got %+v, %v", result, err)
+	}
+}
+
+func TestSimulator_ParseWebhook(t *testing.T) {
+	sim := gateway.NewSimulator("secret")
+	body := []byte(`{"id":"evt_1","type":"captured","reference":"sim_auth_000001","amount":25,"currency":"USD"}`)
+
+	header := http.Header{}
+	header.Set(gateway.SimulatorSignatureHeader, sim.Sign(body))
+	events, err := sim.ParseWebhook(header, body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != gateway.EventCaptured || events[0].Reference != "sim_auth_000001" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+
+	signedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	header.Set(gateway.SimulatorSignatureHeader, sim.SignAt(body, signedAt))
+	if events, err := sim.ParseWebhook(header, body); err != nil || !events[0].SignedAt.Equal(signedAt) {
+		t.Errorf("expected the event to be signed at %s, got %+v, %v", signedAt, events, err)
+	}
+	tampered := strings.Replace(sim.SignAt(body, signedAt), "t=", "t=1", 1)
+	header.Set(gateway.SimulatorSignatureHeader, tampered)
+	if _, err := sim.ParseWebhook(header, body); !errors.Is(err, gateway.ErrInvalidSignature) {
+		t.Errorf("expected a changed timestamp to invalidate the signature, got %v", err)
+	}
+
+	header.Set(gateway.SimulatorSignatureHeader, gateway.NewSimulator("other").Sign(body))
+	if _, err := sim.ParseWebhook(header, body); !errors.Is(err, gateway.ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature, got %v", err)
+	}
+
+	dispute := []byte(`{"id":"evt_2","type":"disputed","reference":"sim_sale_000002","amount":25,"dispute_id":"dp_1","evidence_due_by":"2024-05-08T00:00:00Z"}`)
+	header.Set(gateway.SimulatorSignatureHeader, sim.Sign(dispute))
+	if events, err := sim.ParseWebhook(header, dispute); err != nil || events[0].DisputeID != "dp_1" || events[0].EvidenceDueBy.IsZero() {
+		t.Errorf("expected the dispute and its evidence deadline, got %+v, %v", events, err)
+	}
+	withoutDispute := []byte(`{"id":"evt_3","type":"dispute_won","reference":"sim_sale_000002"}`)
+	header.Set(gateway.SimulatorSignatureHeader, sim.Sign(withoutDispute))
+	if _, err := sim.ParseWebhook(header, withoutDispute); !errors.Is(err, gateway.ErrInvalidPayload) {
+		t.Errorf("expected a dispute event without dispute_id to be invalid, got %v", err)
+	}
+}
+
+func TestRegistry_Open(t *testing.T) {
+	registry := gateway.DefaultRegistry()
+
+	adapter, err := registry.Open(&models.PaymentGateway{Provider: gateway.SimulatorProvider})
+	if err != nil || adapter.Provider() != gateway.SimulatorProvider {
+		t.Fatalf("expected the simulator, got %v, %v", adapter, err)
+	}
+	if _, err := registry.Open(&models.PaymentGateway{Provider: "acme"}); !errors.Is(err, gateway.ErrUnknownProvider) {
+		t.Errorf("expected ErrUnknownProvider, got %v", err)
+	}
+}
@@ -22,6 +22,9 @@ func NewGraphQLHandler(paymentService *service.PaymentService, logger *logger.Lo
 	// Create the GraphQL server
 	srv := handler.NewDefaultServer(schema)
 
+	// Refuse mutations that move money unless they can be deduplicated
+	srv.AroundRootFields(requireIdempotencyKey)
+
 	// Add recovery handler
 	srv.SetRecoverFunc(func(ctx context.Context, err interface{}) error {
 		logger.WithField("panic", err).Error("GraphQL panic recovered")
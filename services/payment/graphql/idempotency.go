@@ -0,0 +1,33 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"unified-commerce/services/shared/middleware"
+)
+
+// idempotentMutations are the mutations that move money. A retried request
+// could charge or refund twice, so they must be sent with an
+// Idempotency-Key, which the Idempotency middleware deduplicates.
+var idempotentMutations = map[string]bool{
+	"createPayment":             true,
+	"capturePaymentTransaction": true,
+	"refundPayment":             true,
+}
+
+// requireIdempotencyKey rejects the idempotent mutations of requests that
+// did not claim an Idempotency-Key
+func requireIdempotencyKey(ctx context.Context, next graphql.RootResolver) graphql.Marshaler {
+	if graphql.GetOperationContext(ctx).Operation.Operation == ast.Mutation {
+		field := graphql.GetRootFieldContext(ctx).Field.Name
+		if _, ok := middleware.IdempotencyKeyFromContext(ctx); idempotentMutations[field] && !ok {
+			graphql.AddError(ctx, fmt.Errorf("%s requires an %s header", field, middleware.IdempotencyKeyHeader))
+			return graphql.Null
+		}
+	}
+	return next(ctx)
+}
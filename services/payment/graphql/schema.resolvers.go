@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 	"unified-commerce/services/payment/models"
+	"unified-commerce/services/shared/tenant"
 
 	"github.com/google/uuid"
 )
@@ -32,7 +33,15 @@ func (r *mutationResolver) CreatePayment(ctx context.Context, input models.Creat
 		cid, _ := uuid.Parse(*input.CustomerID)
 		customerID = &cid
 	}
-	merchantID, _ := uuid.Parse(input.MerchantID)
+	// The payment belongs to the merchant the request is scoped to
+	merchant, err := tenant.OwnerMerchantID(ctx, input.MerchantID)
+	if err != nil {
+		return nil, err
+	}
+	merchantID, err := uuid.Parse(merchant)
+	if err != nil {
+		return nil, fmt.Errorf("invalid merchant ID")
+	}
 
 	return &models.Payment{
 		ID:         paymentID,
@@ -47,7 +56,11 @@ func (r *mutationResolver) CreatePayment(ctx context.Context, input models.Creat
 
 // CapturePaymentTransaction is the resolver for the capturePaymentTransaction field.
 func (r *mutationResolver) CapturePaymentTransaction(ctx context.Context, id string, amount *float64) (*models.Payment, error) {
-	panic(fmt.Errorf("not implemented: CapturePaymentTransaction - capturePaymentTransaction"))
+	paymentID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment ID")
+	}
+	return r.PaymentService.CapturePayment(ctx, paymentID, amount)
 }
 
 // RefundPayment is the resolver for the refundPayment field.
@@ -57,7 +70,11 @@ func (r *mutationResolver) RefundPayment(ctx context.Context, id string, input m
 
 // VoidPayment is the resolver for the voidPayment field.
 func (r *mutationResolver) VoidPayment(ctx context.Context, id string) (*models.Payment, error) {
-	panic(fmt.Errorf("not implemented: VoidPayment - voidPayment"))
+	paymentID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment ID")
+	}
+	return r.PaymentService.VoidPayment(ctx, paymentID)
 }
 
 // CreatePaymentMethod is the resolver for the createPaymentMethod field.
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"errors"
+	"io"
+
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
@@ -9,8 +12,12 @@ import (
 	httputil "unified-commerce/services/shared/http"
 	"unified-commerce/services/shared/logger"
 	"unified-commerce/services/shared/middleware"
+	"unified-commerce/services/shared/tenant"
 )
 
+// maxWebhookBytes bounds the size of gateway webhooks
+const maxWebhookBytes = 1 << 20
+
 // PaymentHandler handles HTTP requests for payment operations
 type PaymentHandler struct {
 	service   *service.PaymentService
@@ -27,17 +34,20 @@ func NewPaymentHandler(service *service.PaymentService, logger *logger.Logger) *
 	}
 }
 
-// RegisterRoutes registers all payment routes
-func (h *PaymentHandler) RegisterRoutes(router *gin.Engine) {
+// RegisterRoutes registers all payment routes. Payment and refund mutations
+// are deduplicated by their Idempotency-Key in idempotency.
+func (h *PaymentHandler) RegisterRoutes(router *gin.Engine, rateLimiter *middleware.RateLimiter, idempotency middleware.IdempotencyStore) {
 	v1 := router.Group("/api/v1")
 	{
 		// Protected routes (authentication required)
 		authConfig := middleware.DefaultAuthConfig()
 		protected := v1.Group("/")
-		protected.Use(middleware.JWTAuth(authConfig))
+		protected.Use(middleware.JWTAuth(authConfig), middleware.Tenant(), middleware.RateLimit(rateLimiter, middleware.MerchantRateLimitConfig()))
+		idempotent := middleware.Idempotency(idempotency, middleware.DefaultIdempotencyConfig())
 		{
 			// Payment management
 			payments := protected.Group("/payments")
+			payments.Use(idempotent)
 			{
 				payments.POST("", h.CreatePayment)
 				payments.GET("/:id", h.GetPayment)
@@ -45,6 +55,9 @@ func (h *PaymentHandler) RegisterRoutes(router *gin.Engine) {
 				payments.GET("/merchant/:merchantId", h.GetPaymentsByMerchant)
 				payments.POST("/:id/process", h.ProcessPayment)
 				payments.POST("/:id/cancel", h.CancelPayment)
+				payments.POST("/:id/capture", h.CapturePayment)
+				payments.POST("/:id/void", h.VoidPayment)
+				payments.GET("/:id/transactions", h.GetPaymentTransactions)
 			}
 
 			// Payment method management
@@ -69,6 +82,7 @@ func (h *PaymentHandler) RegisterRoutes(router *gin.Engine) {
 
 			// Refund management
 			refunds := protected.Group("/refunds")
+			refunds.Use(idempotent)
 			{
 				refunds.POST("", h.CreateRefund)
 				refunds.GET("/:id", h.GetRefund)
@@ -91,6 +105,23 @@ func (h *PaymentHandler) RegisterRoutes(router *gin.Engine) {
 				settlements.PUT("/:id", h.UpdateSettlement)
 			}
 
+			// Dispute management
+			disputes := protected.Group("/disputes")
+			disputes.Use(idempotent)
+			{
+				disputes.GET("/:id", h.GetDispute)
+				disputes.GET("/merchant/:merchantId", h.GetDisputesByMerchant)
+				disputes.GET("/payment/:paymentId", h.GetDisputesByPayment)
+				disputes.POST("/:id/evidence", h.SubmitDisputeEvidence)
+				disputes.POST("/:id/accept", h.AcceptDispute)
+			}
+
+			// Merchant balances
+			balances := protected.Group("/balances")
+			{
+				balances.GET("/merchant/:merchantId", h.GetMerchantBalances)
+			}
+
 			// Administrative operations
 			admin := protected.Group("/admin")
 			{
@@ -98,6 +129,12 @@ func (h *PaymentHandler) RegisterRoutes(router *gin.Engine) {
 				admin.GET("/reports/settlements", h.GetSettlementReport)
 			}
 		}
+
+		// Gateway webhooks, authenticated by each gateway's signature
+		webhooks := v1.Group("/webhooks")
+		{
+			webhooks.POST("/gateways/:id", h.ReceiveGatewayWebhook)
+		}
 	}
 }
 
@@ -111,6 +148,12 @@ func (h *PaymentHandler) CreatePayment(c *gin.Context) {
 		return
 	}
 
+	merchantID, ok := middleware.TenantMerchantID(c)
+	if !ok {
+		return
+	}
+	req.MerchantID = merchantID
+
 	// If customer ID is not provided, try to get from JWT token
 	if req.CustomerID == nil {
 		if customerID, exists := c.Get("user_id"); exists {
@@ -230,13 +273,28 @@ func (h *PaymentHandler) ProcessPayment(c *gin.Context) {
 	}
 
 	if err := h.service.ProcessPayment(c.Request.Context(), id); err != nil {
-		switch err {
-		case service.ErrPaymentNotFound:
+		switch {
+		case err == service.ErrPaymentNotFound:
 			httputil.NotFound(c, "Payment not found")
-		case service.ErrPaymentAlreadyProcessed:
+		case err == service.ErrPaymentAlreadyProcessed:
 			httputil.BadRequest(c, "Payment already processed")
-		case service.ErrInvalidPaymentStatus:
+		case err == service.ErrInvalidPaymentStatus:
 			httputil.BadRequest(c, "Invalid payment status")
+		case errors.Is(err, service.ErrPaymentFailed):
+			httputil.BadRequest(c, "Payment declined", map[string]interface{}{"error": err.Error()})
+		case errors.Is(err, service.ErrPaymentActionRequired):
+			// The customer completes the payment at the action URL
+			payment, getErr := h.service.GetPayment(c.Request.Context(), id)
+			if getErr != nil {
+				h.logger.WithError(getErr).Error("Failed to get payment")
+				httputil.InternalServerError(c, "Failed to process payment")
+				return
+			}
+			httputil.Accepted(c, payment, "Payment requires customer authentication")
+		case errors.Is(err, service.ErrUnsupportedGateway):
+			httputil.Conflict(c, err.Error())
+		case errors.Is(err, service.ErrGatewayTimeout):
+			httputil.InternalServerError(c, "Payment gateway timed out, try again")
 		default:
 			h.logger.WithError(err).Error("Failed to process payment")
 			httputil.InternalServerError(c, "Failed to process payment")
@@ -271,6 +329,99 @@ func (h *PaymentHandler) CancelPayment(c *gin.Context) {
 	httputil.Success(c, nil, "Payment cancelled successfully")
 }
 
+// CapturePayment handles capturing an amount of an authorized payment, or
+// all that is left of its authorization when no amount is given
+func (h *PaymentHandler) CapturePayment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid payment ID")
+		return
+	}
+
+	var req struct {
+		Amount *float64 `json:"amount" validate:"omitempty,gt=0"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
+		return
+	}
+
+	payment, err := h.service.CapturePayment(c.Request.Context(), id, req.Amount)
+	if err != nil {
+		h.ledgerError(c, err, "Capture declined", "Failed to capture payment")
+		return
+	}
+
+	httputil.Success(c, payment, "Payment captured successfully")
+}
+
+// VoidPayment handles releasing what is left of a payment's authorization
+func (h *PaymentHandler) VoidPayment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid payment ID")
+		return
+	}
+
+	payment, err := h.service.VoidPayment(c.Request.Context(), id)
+	if err != nil {
+		h.ledgerError(c, err, "Void declined", "Failed to void payment")
+		return
+	}
+
+	httputil.Success(c, payment, "Payment voided successfully")
+}
+
+// GetPaymentTransactions handles retrieving the ledger of a payment
+func (h *PaymentHandler) GetPaymentTransactions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid payment ID")
+		return
+	}
+
+	transactions, err := h.service.GetPaymentTransactions(c.Request.Context(), id)
+	if err != nil {
+		if err == service.ErrPaymentNotFound {
+			httputil.NotFound(c, "Payment not found")
+			return
+		}
+		h.logger.WithError(err).Error("Failed to get payment transactions")
+		httputil.InternalServerError(c, "Failed to get payment transactions")
+		return
+	}
+
+	httputil.Success(c, transactions, "Payment transactions retrieved successfully")
+}
+
+// ledgerError responds to an error from a capture or void of a payment
+func (h *PaymentHandler) ledgerError(c *gin.Context, err error, declined, message string) {
+	switch {
+	case err == service.ErrPaymentNotFound:
+		httputil.NotFound(c, "Payment not found")
+	case errors.Is(err, service.ErrNothingToCapture), errors.Is(err, service.ErrAuthorizationExpired):
+		httputil.Conflict(c, err.Error())
+	case errors.Is(err, service.ErrInvalidCaptureAmount):
+		httputil.BadRequest(c, err.Error())
+	case errors.Is(err, service.ErrCaptureFailed), errors.Is(err, service.ErrVoidFailed):
+		httputil.BadRequest(c, declined, map[string]interface{}{"error": err.Error()})
+	case errors.Is(err, service.ErrUnsupportedGateway):
+		httputil.Conflict(c, err.Error())
+	case errors.Is(err, service.ErrGatewayTimeout):
+		httputil.InternalServerError(c, "Payment gateway timed out, try again")
+	default:
+		h.logger.WithError(err).Error(message)
+		httputil.InternalServerError(c, message)
+	}
+}
+
 // Payment Method Management Handlers
 
 // CreatePaymentMethod handles creating a new payment method
@@ -281,6 +432,17 @@ func (h *PaymentHandler) CreatePaymentMethod(c *gin.Context) {
 		return
 	}
 
+	// Payment methods saved for a merchant belong to the tenant; customer
+	// wallets may be saved without one
+	if merchantID, ok := tenant.MerchantID(c.Request.Context()); ok {
+		parsed, err := uuid.Parse(merchantID)
+		if err != nil {
+			httputil.BadRequest(c, "Invalid merchant ID")
+			return
+		}
+		req.MerchantID = &parsed
+	}
+
 	if err := h.validator.Struct(&req); err != nil {
 		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
 		return
@@ -288,8 +450,15 @@ func (h *PaymentHandler) CreatePaymentMethod(c *gin.Context) {
 
 	paymentMethod, err := h.service.CreatePaymentMethod(c.Request.Context(), &req)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to create payment method")
-		httputil.InternalServerError(c, "Failed to create payment method")
+		switch {
+		case errors.Is(err, service.ErrInvalidCard):
+			httputil.BadRequest(c, err.Error())
+		case err == service.ErrGatewayNotFound, errors.Is(err, service.ErrUnsupportedGateway):
+			httputil.BadRequest(c, "No gateway can tokenize cards of this provider")
+		default:
+			h.logger.WithError(err).Error("Failed to create payment method")
+			httputil.InternalServerError(c, "Failed to create payment method")
+		}
 		return
 	}
 
@@ -500,6 +669,46 @@ func (h *PaymentHandler) UpdateGateway(c *gin.Context) {
 	httputil.Success(c, gateway, "Payment gateway updated successfully")
 }
 
+// ReceiveGatewayWebhook handles a webhook a gateway sent about its
+// payments. Gateways retry webhooks that fail, so events already received
+// are accepted and skipped.
+func (h *PaymentHandler) ReceiveGatewayWebhook(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid gateway ID")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBytes))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid request body")
+		return
+	}
+
+	// Webhooks report payments of every merchant
+	delivery, err := h.service.ReceiveGatewayWebhook(tenant.WithoutScope(c.Request.Context()), id, c.Request.Header, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrGatewayNotFound), errors.Is(err, service.ErrUnsupportedGateway):
+			httputil.NotFound(c, "Payment gateway not found")
+		case errors.Is(err, service.ErrWebhookSignature), errors.Is(err, service.ErrStaleWebhook):
+			httputil.Unauthorized(c, err.Error())
+		case errors.Is(err, service.ErrInvalidWebhook):
+			httputil.BadRequest(c, err.Error())
+		default:
+			h.logger.WithError(err).Error("Failed to process gateway webhook")
+			httputil.InternalServerError(c, "Failed to process gateway webhook")
+		}
+		return
+	}
+
+	httputil.Success(c, map[string]interface{}{
+		"delivery_id": delivery.ID,
+		"applied":     delivery.Applied,
+		"duplicates":  delivery.Duplicates,
+	}, "Gateway webhook processed successfully")
+}
+
 // Refund Management Handlers
 
 // CreateRefund handles creating a new refund
@@ -584,11 +793,17 @@ func (h *PaymentHandler) ProcessRefund(c *gin.Context) {
 	}
 
 	if err := h.service.ProcessRefund(c.Request.Context(), id); err != nil {
-		switch err {
-		case service.ErrRefundNotFound:
+		switch {
+		case err == service.ErrRefundNotFound:
 			httputil.NotFound(c, "Refund not found")
-		case service.ErrInvalidRefundStatus:
+		case err == service.ErrInvalidRefundStatus:
 			httputil.BadRequest(c, "Refund cannot be processed")
+		case errors.Is(err, service.ErrRefundFailed):
+			httputil.BadRequest(c, "Refund declined", map[string]interface{}{"error": err.Error()})
+		case errors.Is(err, service.ErrUnsupportedGateway):
+			httputil.Conflict(c, err.Error())
+		case errors.Is(err, service.ErrGatewayTimeout):
+			httputil.InternalServerError(c, "Payment gateway timed out, try again")
 		default:
 			h.logger.WithError(err).Error("Failed to process refund")
 			httputil.InternalServerError(c, "Failed to process refund")
@@ -713,6 +928,169 @@ func (h *PaymentHandler) UpdateSettlement(c *gin.Context) {
 	httputil.Success(c, settlement, "Settlement updated successfully")
 }
 
+// Dispute Management Handlers
+
+// GetDispute handles retrieving a dispute by ID
+func (h *PaymentHandler) GetDispute(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid dispute ID")
+		return
+	}
+
+	dispute, err := h.service.GetDispute(c.Request.Context(), id)
+	if err != nil {
+		if err == service.ErrDisputeNotFound {
+			httputil.NotFound(c, "Dispute not found")
+			return
+		}
+		h.logger.WithError(err).Error("Failed to get dispute")
+		httputil.InternalServerError(c, "Failed to get dispute")
+		return
+	}
+
+	httputil.Success(c, dispute, "Dispute retrieved successfully")
+}
+
+// GetDisputesByMerchant handles retrieving disputes of a merchant
+func (h *PaymentHandler) GetDisputesByMerchant(c *gin.Context) {
+	merchantID, err := uuid.Parse(c.Param("merchantId"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid merchant ID")
+		return
+	}
+
+	pagination := httputil.GetPaginationParams(c)
+	filters := make(map[string]interface{})
+	if status := c.Query("status"); status != "" {
+		filters["status"] = status
+	}
+	if reason := c.Query("reason"); reason != "" {
+		filters["reason"] = reason
+	}
+
+	disputes, total, err := h.service.GetDisputesByMerchant(c.Request.Context(), merchantID, filters, pagination.Page, pagination.PerPage)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get disputes by merchant")
+		httputil.InternalServerError(c, "Failed to get disputes")
+		return
+	}
+
+	response := map[string]interface{}{
+		"data":        disputes,
+		"total":       total,
+		"page":        pagination.Page,
+		"per_page":    pagination.PerPage,
+		"total_pages": (total + int64(pagination.PerPage) - 1) / int64(pagination.PerPage),
+	}
+
+	httputil.Success(c, response, "Disputes retrieved successfully")
+}
+
+// GetDisputesByPayment handles retrieving the disputes of a payment
+func (h *PaymentHandler) GetDisputesByPayment(c *gin.Context) {
+	paymentID, err := uuid.Parse(c.Param("paymentId"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid payment ID")
+		return
+	}
+
+	disputes, err := h.service.GetDisputesByPayment(c.Request.Context(), paymentID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get disputes by payment")
+		httputil.InternalServerError(c, "Failed to get disputes")
+		return
+	}
+
+	httputil.Success(c, disputes, "Disputes retrieved successfully")
+}
+
+// SubmitDisputeEvidence handles submitting a dispute's evidence bundle, with
+// the merchant's own evidence added, to its gateway
+func (h *PaymentHandler) SubmitDisputeEvidence(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid dispute ID")
+		return
+	}
+
+	var req service.SubmitDisputeEvidenceRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			httputil.BadRequest(c, "Invalid request body", map[string]interface{}{"error": err.Error()})
+			return
+		}
+	}
+
+	dispute, err := h.service.SubmitDisputeEvidence(c.Request.Context(), id, &req)
+	if err != nil {
+		h.disputeError(c, err, "Failed to submit dispute evidence")
+		return
+	}
+
+	httputil.Success(c, dispute, "Dispute evidence submitted successfully")
+}
+
+// AcceptDispute handles conceding a dispute to the customer
+func (h *PaymentHandler) AcceptDispute(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid dispute ID")
+		return
+	}
+
+	dispute, err := h.service.AcceptDispute(c.Request.Context(), id)
+	if err != nil {
+		h.disputeError(c, err, "Failed to accept dispute")
+		return
+	}
+
+	httputil.Success(c, dispute, "Dispute accepted successfully")
+}
+
+// disputeError answers a failed change of a dispute
+func (h *PaymentHandler) disputeError(c *gin.Context, err error, message string) {
+	switch {
+	case err == service.ErrDisputeNotFound:
+		httputil.NotFound(c, "Dispute not found")
+	case errors.Is(err, service.ErrDisputeClosed), errors.Is(err, service.ErrEvidenceSubmitted),
+		errors.Is(err, service.ErrEvidenceDeadlinePassed):
+		httputil.Conflict(c, err.Error())
+	case errors.Is(err, service.ErrNoDisputeEvidence):
+		httputil.BadRequest(c, err.Error())
+	case errors.Is(err, service.ErrDisputeEvidenceRejected):
+		httputil.BadRequest(c, "Dispute evidence rejected", map[string]interface{}{"error": err.Error()})
+	case errors.Is(err, service.ErrUnsupportedGateway):
+		httputil.Conflict(c, err.Error())
+	case errors.Is(err, service.ErrGatewayTimeout):
+		httputil.InternalServerError(c, "Payment gateway timed out, try again")
+	default:
+		h.logger.WithError(err).Error(message)
+		httputil.InternalServerError(c, message)
+	}
+}
+
+// Balance Handlers
+
+// GetMerchantBalances handles retrieving a merchant's balance in each
+// currency
+func (h *PaymentHandler) GetMerchantBalances(c *gin.Context) {
+	merchantID, err := uuid.Parse(c.Param("merchantId"))
+	if err != nil {
+		httputil.BadRequest(c, "Invalid merchant ID")
+		return
+	}
+
+	balances, err := h.service.GetMerchantBalances(c.Request.Context(), merchantID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get merchant balances")
+		httputil.InternalServerError(c, "Failed to get merchant balances")
+		return
+	}
+
+	httputil.Success(c, balances, "Merchant balances retrieved successfully")
+}
+
 // Administrative Operations Handlers
 
 // GetRevenueReport handles retrieving revenue report
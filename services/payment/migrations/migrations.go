@@ -0,0 +1,245 @@
+// Package migrations holds the payment service's versioned schema
+// migrations. Add a migration for every schema change and never edit one
+// that has been released.
+package migrations
+
+import (
+	"unified-commerce/services/payment/migrations/baseline"
+	"unified-commerce/services/shared/database"
+)
+
+// All returns the payment service's migrations
+func All() []database.Migration {
+	return []database.Migration{
+		{
+			// Tables as AutoMigrate created them before versioned
+			// migrations, which adopts existing databases unchanged. The
+			// models are frozen in the baseline package.
+			Version: 1,
+			Name:    "baseline",
+			Up:      database.AutoMigrateModels(baseline.Models()...),
+			Down:    database.DropModels(baseline.Models()...),
+		},
+		{
+			// Deduplication of consumed events, starting with the returns
+			// refunded by the order service
+			Version: 2,
+			Name:    "processed_events",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS processed_events (
+					consumer TEXT NOT NULL,
+					event_id TEXT NOT NULL,
+					processed_at TIMESTAMPTZ,
+					PRIMARY KEY (consumer, event_id)
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_processed_events_processed_at ON processed_events (processed_at)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS processed_events`,
+			),
+		},
+		{
+			// Outbox for the outcomes of draft order payments
+			Version: 3,
+			Name:    "outbox",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS outbox_messages (
+					id UUID PRIMARY KEY,
+					sequence BIGSERIAL NOT NULL,
+					aggregate_type TEXT NOT NULL,
+					aggregate_id TEXT NOT NULL,
+					topic TEXT NOT NULL,
+					key TEXT,
+					payload BYTEA NOT NULL,
+					attempts BIGINT DEFAULT 0,
+					last_error TEXT,
+					next_attempt_at TIMESTAMPTZ NOT NULL,
+					published_at TIMESTAMPTZ,
+					created_at TIMESTAMPTZ
+				)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_outbox_messages_sequence ON outbox_messages (sequence)`,
+				`CREATE INDEX IF NOT EXISTS idx_outbox_aggregate ON outbox_messages (aggregate_type, aggregate_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_outbox_messages_next_attempt_at ON outbox_messages (next_attempt_at)`,
+				`CREATE INDEX IF NOT EXISTS idx_outbox_messages_published_at ON outbox_messages (published_at)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS outbox_messages`,
+			),
+		},
+		{
+			// Authorizations, captures and voids as a ledger of transactions,
+			// the totals payments keep of it and the expiry of their
+			// authorizations. Payments captured before the ledger are
+			// backfilled as fully captured sales.
+			Version: 4,
+			Name:    "payment_ledger",
+			Up: database.ExecSQL(
+				`ALTER TABLE payments
+					ADD COLUMN IF NOT EXISTS capture_method TEXT DEFAULT 'automatic',
+					ADD COLUMN IF NOT EXISTS authorized_amount DECIMAL(12,2) DEFAULT 0,
+					ADD COLUMN IF NOT EXISTS captured_amount DECIMAL(12,2) DEFAULT 0,
+					ADD COLUMN IF NOT EXISTS voided_amount DECIMAL(12,2) DEFAULT 0,
+					ADD COLUMN IF NOT EXISTS authorization_expires_at TIMESTAMPTZ`,
+				`UPDATE payments SET authorized_amount = amount, captured_amount = amount
+					WHERE status IN ('captured', 'refunded', 'partially_refunded') AND captured_amount = 0`,
+				`CREATE TABLE IF NOT EXISTS transactions (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					payment_id UUID NOT NULL REFERENCES payments (id),
+					order_id UUID,
+					amount DECIMAL(12,2) NOT NULL,
+					currency TEXT DEFAULT 'USD',
+					type TEXT NOT NULL,
+					status TEXT DEFAULT 'pending',
+					gateway TEXT NOT NULL,
+					gateway_reference TEXT,
+					processor_response TEXT,
+					failure_reason TEXT,
+					metadata JSONB,
+					description TEXT,
+					kind TEXT NOT NULL,
+					payment_method_id TEXT,
+					processed_at TIMESTAMPTZ,
+					created_at TIMESTAMPTZ,
+					updated_at TIMESTAMPTZ
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_transactions_payment_id ON transactions (payment_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_transactions_order_id ON transactions (order_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_payments_authorization_expires_at ON payments (authorization_expires_at)
+					WHERE authorization_expires_at IS NOT NULL`,
+			),
+			Down: database.ExecSQL(
+				`DROP INDEX IF EXISTS idx_payments_authorization_expires_at`,
+				`DROP TABLE IF EXISTS transactions`,
+				`ALTER TABLE payments
+					DROP COLUMN IF EXISTS authorization_expires_at,
+					DROP COLUMN IF EXISTS voided_amount,
+					DROP COLUMN IF EXISTS captured_amount,
+					DROP COLUMN IF EXISTS authorized_amount,
+					DROP COLUMN IF EXISTS capture_method`,
+			),
+		},
+		{
+			// Idempotency keys of retried payment mutations and their
+			// stored responses
+			Version: 5,
+			Name:    "idempotency_keys",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS idempotency_keys (
+					scope VARCHAR(100) NOT NULL,
+					key VARCHAR(255) NOT NULL,
+					fingerprint VARCHAR(64) NOT NULL,
+					status BIGINT NOT NULL DEFAULT 0,
+					content_type VARCHAR(100),
+					body BYTEA,
+					created_at TIMESTAMPTZ NOT NULL,
+					expires_at TIMESTAMPTZ NOT NULL,
+					PRIMARY KEY (scope, key)
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS idempotency_keys`,
+			),
+		},
+		{
+			// Gateway webhooks kept for audit and the events they reported,
+			// keyed by the gateway's event IDs so replays are skipped
+			Version: 6,
+			Name:    "gateway_webhooks",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					gateway_id UUID NOT NULL,
+					status TEXT NOT NULL,
+					headers JSONB,
+					payload TEXT NOT NULL,
+					events BIGINT DEFAULT 0,
+					applied BIGINT DEFAULT 0,
+					duplicates BIGINT DEFAULT 0,
+					error TEXT,
+					received_at TIMESTAMPTZ,
+					processed_at TIMESTAMPTZ
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_gateway_id ON webhook_deliveries (gateway_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_received_at ON webhook_deliveries (received_at)`,
+				`CREATE TABLE IF NOT EXISTS gateway_events (
+					gateway_id UUID NOT NULL,
+					event_id TEXT NOT NULL,
+					delivery_id UUID NOT NULL,
+					payment_id UUID,
+					type TEXT NOT NULL,
+					reference TEXT,
+					outcome TEXT NOT NULL,
+					occurred_at TIMESTAMPTZ,
+					created_at TIMESTAMPTZ,
+					PRIMARY KEY (gateway_id, event_id)
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_gateway_events_delivery_id ON gateway_events (delivery_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_gateway_events_payment_id ON gateway_events (payment_id)`,
+			),
+			Down: database.ExecSQL(
+				`DROP TABLE IF EXISTS gateway_events`,
+				`DROP TABLE IF EXISTS webhook_deliveries`,
+			),
+		},
+		{
+			// Chargeback disputes and the balance adjustments they make,
+			// netted into settlements
+			Version: 7,
+			Name:    "disputes",
+			Up: database.ExecSQL(
+				`CREATE TABLE IF NOT EXISTS disputes (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					payment_id UUID NOT NULL,
+					order_id UUID NOT NULL,
+					merchant_id UUID NOT NULL,
+					gateway_id UUID NOT NULL,
+					gateway_reference TEXT NOT NULL,
+					reason TEXT NOT NULL,
+					reason_code TEXT,
+					amount DECIMAL(12,2) NOT NULL,
+					currency TEXT DEFAULT 'USD',
+					status TEXT DEFAULT 'needs_response',
+					evidence_due_by TIMESTAMPTZ,
+					evidence JSONB,
+					evidence_assembled_at TIMESTAMPTZ,
+					evidence_submitted_at TIMESTAMPTZ,
+					submission_reference TEXT,
+					resolved_at TIMESTAMPTZ,
+					created_at TIMESTAMPTZ,
+					updated_at TIMESTAMPTZ
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_disputes_payment_id ON disputes (payment_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_disputes_order_id ON disputes (order_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_disputes_merchant_id ON disputes (merchant_id)`,
+				`CREATE UNIQUE INDEX IF NOT EXISTS idx_disputes_gateway_reference ON disputes (gateway_id, gateway_reference)`,
+				`CREATE INDEX IF NOT EXISTS idx_disputes_status ON disputes (status)`,
+				`CREATE INDEX IF NOT EXISTS idx_disputes_evidence_due_by ON disputes (evidence_due_by)`,
+				`CREATE TABLE IF NOT EXISTS balance_adjustments (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					merchant_id UUID NOT NULL,
+					gateway_id UUID NOT NULL,
+					payment_id UUID,
+					dispute_id UUID REFERENCES disputes (id),
+					settlement_id UUID,
+					type TEXT NOT NULL,
+					amount DECIMAL(12,2) NOT NULL,
+					currency TEXT DEFAULT 'USD',
+					description TEXT,
+					created_at TIMESTAMPTZ
+				)`,
+				`CREATE INDEX IF NOT EXISTS idx_balance_adjustments_merchant_id ON balance_adjustments (merchant_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_balance_adjustments_gateway_id ON balance_adjustments (gateway_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_balance_adjustments_payment_id ON balance_adjustments (payment_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_balance_adjustments_dispute_id ON balance_adjustments (dispute_id)`,
+				`CREATE INDEX IF NOT EXISTS idx_balance_adjustments_settlement_id ON balance_adjustments (settlement_id)`,
+				`ALTER TABLE settlements ADD COLUMN IF NOT EXISTS adjustment_amount DECIMAL(12,2) DEFAULT 0`,
+			),
+			Down: database.ExecSQL(
+				`ALTER TABLE settlements DROP COLUMN IF EXISTS adjustment_amount`,
+				`DROP TABLE IF EXISTS balance_adjustments`,
+				`DROP TABLE IF EXISTS disputes`,
+			),
+		},
+	}
+}
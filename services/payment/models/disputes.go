@@ -0,0 +1,174 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Dispute errors
+var (
+	ErrDisputeClosed            = errors.New("dispute is closed")
+	ErrEvidenceAlreadySubmitted = errors.New("dispute evidence was already submitted")
+	ErrEvidenceDeadlinePassed   = errors.New("dispute evidence is past due")
+)
+
+// Dispute is a chargeback a customer filed with their bank against a
+// payment. The disputed amount is withdrawn from the merchant's balance
+// when the dispute opens and returned if the merchant wins it.
+type Dispute struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	PaymentID  uuid.UUID `json:"payment_id" gorm:"type:uuid;not null;index"`
+	OrderID    uuid.UUID `json:"order_id" gorm:"type:uuid;not null;index"`
+	MerchantID uuid.UUID `json:"merchant_id" gorm:"type:uuid;not null;index"`
+	GatewayID  uuid.UUID `json:"gateway_id" gorm:"type:uuid;not null;uniqueIndex:idx_disputes_gateway_reference,priority:1"`
+	// GatewayReference is the gateway's ID of the dispute
+	GatewayReference string        `json:"gateway_reference" gorm:"not null;uniqueIndex:idx_disputes_gateway_reference,priority:2"`
+	Reason           DisputeReason `json:"reason" gorm:"not null"`
+	ReasonCode       string        `json:"reason_code"` // the reason as the gateway reported it
+	Amount           float64       `json:"amount" gorm:"type:decimal(12,2);not null"`
+	Currency         string        `json:"currency" gorm:"default:'USD'"`
+	Status           DisputeStatus `json:"status" gorm:"default:'needs_response';index"`
+	EvidenceDueBy    *time.Time    `json:"evidence_due_by" gorm:"index"`
+
+	// Evidence is the bundle submitted to the gateway: the order, customer
+	// and fulfillments sections assembled by the order service, and the
+	// merchant section the merchant added
+	Evidence            map[string]interface{} `json:"evidence" gorm:"type:jsonb"`
+	EvidenceAssembledAt *time.Time             `json:"evidence_assembled_at"`
+	EvidenceSubmittedAt *time.Time             `json:"evidence_submitted_at"`
+	SubmissionReference string                 `json:"submission_reference"`
+
+	ResolvedAt *time.Time `json:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Relationships
+	Adjustments []BalanceAdjustment `json:"adjustments,omitempty" gorm:"foreignKey:DisputeID"`
+}
+
+// DisputeStatus represents the status of a dispute
+type DisputeStatus string
+
+const (
+	// DisputeStatusNeedsResponse disputes wait for the merchant's evidence
+	DisputeStatusNeedsResponse DisputeStatus = "needs_response"
+	// DisputeStatusUnderReview disputes have evidence with the card issuer
+	DisputeStatusUnderReview DisputeStatus = "under_review"
+	DisputeStatusWon         DisputeStatus = "won"
+	DisputeStatusLost        DisputeStatus = "lost"
+)
+
+// DisputeReason is why the customer disputed a payment
+type DisputeReason string
+
+const (
+	DisputeReasonFraudulent          DisputeReason = "fraudulent"
+	DisputeReasonProductNotReceived  DisputeReason = "product_not_received"
+	DisputeReasonProductUnacceptable DisputeReason = "product_unacceptable"
+	DisputeReasonDuplicate           DisputeReason = "duplicate"
+	DisputeReasonCreditNotProcessed  DisputeReason = "credit_not_processed"
+	DisputeReasonSubscriptionCancel  DisputeReason = "subscription_canceled"
+	DisputeReasonUnrecognized        DisputeReason = "unrecognized"
+	DisputeReasonGeneral             DisputeReason = "general"
+)
+
+// ParseDisputeReason returns the reason a gateway's reason code names, or
+// DisputeReasonGeneral for codes it does not know
+func ParseDisputeReason(code string) DisputeReason {
+	switch reason := DisputeReason(code); reason {
+	case DisputeReasonFraudulent, DisputeReasonProductNotReceived, DisputeReasonProductUnacceptable,
+		DisputeReasonDuplicate, DisputeReasonCreditNotProcessed, DisputeReasonSubscriptionCancel,
+		DisputeReasonUnrecognized:
+		return reason
+	}
+	return DisputeReasonGeneral
+}
+
+// IsOpen reports whether the dispute is still to be decided
+func (d *Dispute) IsOpen() bool {
+	return d.Status == DisputeStatusNeedsResponse || d.Status == DisputeStatusUnderReview
+}
+
+// CanSubmitEvidence reports why evidence cannot be submitted for the
+// dispute at now, or nil if it can
+func (d *Dispute) CanSubmitEvidence(now time.Time) error {
+	switch {
+	case !d.IsOpen():
+		return ErrDisputeClosed
+	case d.Status == DisputeStatusUnderReview:
+		return ErrEvidenceAlreadySubmitted
+	case d.EvidenceDueBy != nil && now.After(*d.EvidenceDueBy):
+		return ErrEvidenceDeadlinePassed
+	}
+	return nil
+}
+
+// Resolve closes an open dispute as won or lost, reporting whether it was
+// open
+func (d *Dispute) Resolve(won bool, now time.Time) bool {
+	if !d.IsOpen() {
+		return false
+	}
+	d.Status = DisputeStatusLost
+	if won {
+		d.Status = DisputeStatusWon
+	}
+	d.ResolvedAt = &now
+	return true
+}
+
+// BalanceAdjustment moves money into or out of a merchant's balance outside
+// of payments and refunds, such as a disputed amount withdrawn by the
+// gateway. Adjustments are netted into their gateway's next settlement.
+type BalanceAdjustment struct {
+	ID           uuid.UUID             `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	MerchantID   uuid.UUID             `json:"merchant_id" gorm:"type:uuid;not null;index"`
+	GatewayID    uuid.UUID             `json:"gateway_id" gorm:"type:uuid;not null;index"`
+	PaymentID    *uuid.UUID            `json:"payment_id" gorm:"type:uuid;index"`
+	DisputeID    *uuid.UUID            `json:"dispute_id" gorm:"type:uuid;index"`
+	SettlementID *uuid.UUID            `json:"settlement_id" gorm:"type:uuid;index"`
+	Type         BalanceAdjustmentType `json:"type" gorm:"not null"`
+	Amount       float64               `json:"amount" gorm:"type:decimal(12,2);not null"` // negative when withdrawn
+	Currency     string                `json:"currency" gorm:"default:'USD'"`
+	Description  string                `json:"description"`
+	CreatedAt    time.Time             `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// BalanceAdjustmentType represents the type of a balance adjustment
+type BalanceAdjustmentType string
+
+const (
+	// BalanceAdjustmentDisputeWithdrawal withdraws a disputed amount
+	BalanceAdjustmentDisputeWithdrawal BalanceAdjustmentType = "dispute_withdrawal"
+	// BalanceAdjustmentDisputeFee charges the gateway's fee for a dispute
+	BalanceAdjustmentDisputeFee BalanceAdjustmentType = "dispute_fee"
+	// BalanceAdjustmentDisputeReversal returns the amount of a won dispute
+	BalanceAdjustmentDisputeReversal BalanceAdjustmentType = "dispute_reversal"
+)
+
+// MerchantBalance is what a merchant has earned in a currency: captured
+// payments net of fees, less completed refunds, plus balance adjustments
+type MerchantBalance struct {
+	Currency    string  `json:"currency"`
+	Captured    float64 `json:"captured"`
+	Refunded    float64 `json:"refunded"`
+	Adjustments float64 `json:"adjustments"`
+	Available   float64 `json:"available"`
+}
+
+func (d *Dispute) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+func (a *BalanceAdjustment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
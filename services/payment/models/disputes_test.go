@@ -0,0 +1,58 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"unified-commerce/services/payment/models"
+)
+
+func TestDispute_CanSubmitEvidence(t *testing.T) {
+	now := time.Now()
+	dueBy := now.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		dispute models.Dispute
+		at      time.Time
+		want    error
+	}{
+		{name: "open", dispute: models.Dispute{Status: models.DisputeStatusNeedsResponse, EvidenceDueBy: &dueBy}, at: now},
+		{name: "without deadline", dispute: models.Dispute{Status: models.DisputeStatusNeedsResponse}, at: now},
+		{name: "past due", dispute: models.Dispute{Status: models.DisputeStatusNeedsResponse, EvidenceDueBy: &dueBy}, at: dueBy.Add(time.Second), want: models.ErrEvidenceDeadlinePassed},
+		{name: "already submitted", dispute: models.Dispute{Status: models.DisputeStatusUnderReview, EvidenceDueBy: &dueBy}, at: now, want: models.ErrEvidenceAlreadySubmitted},
+		{name: "won", dispute: models.Dispute{Status: models.DisputeStatusWon}, at: now, want: models.ErrDisputeClosed},
+		{name: "lost", dispute: models.Dispute{Status: models.DisputeStatusLost}, at: now, want: models.ErrDisputeClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.dispute.CanSubmitEvidence(tt.at); err != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestDispute_Resolve(t *testing.T) {
+	now := time.Now()
+
+	for _, won := range []bool{true, false} {
+		dispute := &models.Dispute{Status: models.DisputeStatusUnderReview}
+		if !dispute.Resolve(won, now) {
+			t.Fatalf("expected an open dispute to resolve")
+		}
+		want := models.DisputeStatusLost
+		if won {
+			want = models.DisputeStatusWon
+		}
+		if dispute.Status != want || dispute.ResolvedAt == nil || !dispute.ResolvedAt.Equal(now) {
+			t.Errorf("expected %s resolved at %v, got %s resolved at %v", want, now, dispute.Status, dispute.ResolvedAt)
+		}
+	}
+
+	dispute := &models.Dispute{Status: models.DisputeStatusWon}
+	if dispute.Resolve(false, now) || dispute.Status != models.DisputeStatusWon || dispute.ResolvedAt != nil {
+		t.Errorf("expected a closed dispute to stay won, got %s resolved at %v", dispute.Status, dispute.ResolvedAt)
+	}
+}
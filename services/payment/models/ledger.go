@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Ledger errors
+var (
+	ErrNothingToCapture     = errors.New("payment has no authorization left to capture")
+	ErrAuthorizationExpired = errors.New("payment authorization has expired")
+	ErrInvalidCaptureAmount = errors.New("capture amount must be positive and within the authorization")
+)
+
+// CaptureMethod is how an authorized payment is captured
+type CaptureMethod string
+
+const (
+	// CaptureMethodAutomatic captures the payment as it is authorized, as a
+	// sale
+	CaptureMethodAutomatic CaptureMethod = "automatic"
+	// CaptureMethodManual only authorizes the payment. Its authorization is
+	// captured later, in one or more captures, until it is voided or
+	// expires.
+	CaptureMethodManual CaptureMethod = "manual"
+)
+
+// CapturableAmount returns what is left of the payment's authorization to
+// capture
+func (p *Payment) CapturableAmount() float64 {
+	return roundAmount(p.AuthorizedAmount - p.CapturedAmount - p.VoidedAmount)
+}
+
+// AuthorizationExpired reports whether the payment's authorization has
+// lapsed at now
+func (p *Payment) AuthorizationExpired(now time.Time) bool {
+	return p.AuthorizationExpiresAt != nil && !now.Before(*p.AuthorizationExpiresAt)
+}
+
+// CheckCapture checks that amount can be captured from the payment's
+// authorization at now
+func (p *Payment) CheckCapture(amount float64, now time.Time) error {
+	capturable := p.CapturableAmount()
+	switch {
+	case capturable <= 0:
+		return ErrNothingToCapture
+	case p.AuthorizationExpired(now):
+		return ErrAuthorizationExpired
+	case amount <= 0 || roundAmount(amount) > capturable:
+		return ErrInvalidCaptureAmount
+	}
+	return nil
+}
+
+// ApplyTransaction adds a transaction of the payment to its ledger totals.
+// Successful authorizations and sales move a pending payment on, and a void
+// of a payment with nothing captured ends it; failed transactions leave the
+// payment unchanged.
+func (p *Payment) ApplyTransaction(transaction *Transaction) {
+	if transaction.Status != TransactionStatusSuccess {
+		return
+	}
+
+	switch transaction.Kind {
+	case TransactionKindAuthorization:
+		p.AuthorizedAmount = roundAmount(p.AuthorizedAmount + transaction.Amount)
+		p.Status = PaymentStatusAuthorized
+	case TransactionKindSale:
+		p.AuthorizedAmount = roundAmount(p.AuthorizedAmount + transaction.Amount)
+		p.CapturedAmount = roundAmount(p.CapturedAmount + transaction.Amount)
+		p.Status = PaymentStatusCaptured
+	case TransactionKindCapture:
+		p.CapturedAmount = roundAmount(p.CapturedAmount + transaction.Amount)
+		if p.Status == PaymentStatusAuthorized {
+			p.Status = PaymentStatusCaptured
+		}
+	case TransactionKindVoid:
+		p.VoidedAmount = roundAmount(p.VoidedAmount + transaction.Amount)
+		if p.CapturedAmount == 0 {
+			p.Status = PaymentStatusVoided
+		}
+	}
+}
+
+// roundAmount rounds an amount to cents, as it is stored
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"unified-commerce/services/payment/models"
+)
+
+func TestPayment_PartialCapturesAndVoid(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+	payment := &models.Payment{Status: models.PaymentStatusPending, Amount: 100, AuthorizationExpiresAt: &expiresAt}
+
+	payment.ApplyTransaction(&models.Transaction{Kind: models.TransactionKindAuthorization, Status: models.TransactionStatusSuccess, Amount: 100})
+	if payment.Status != models.PaymentStatusAuthorized || payment.CapturableAmount() != 100 {
+		t.Fatalf("expected 100 authorized, got %s with %v capturable", payment.Status, payment.CapturableAmount())
+	}
+
+	for _, amount := range []float64{30.1, 30.2} {
+		if err := payment.CheckCapture(amount, now); err != nil {
+			t.Fatalf("unexpected error capturing %v: %v", amount, err)
+		}
+		payment.ApplyTransaction(&models.Transaction{Kind: models.TransactionKindCapture, Status: models.TransactionStatusSuccess, Amount: amount})
+	}
+	payment.ApplyTransaction(&models.Transaction{Kind: models.TransactionKindCapture, Status: models.TransactionStatusFailed, Amount: 10})
+	if payment.Status != models.PaymentStatusCaptured || payment.CapturedAmount != 60.3 || payment.CapturableAmount() != 39.7 {
+		t.Fatalf("expected 60.30 captured and 39.70 left, got %s %v %v", payment.Status, payment.CapturedAmount, payment.CapturableAmount())
+	}
+	if err := payment.CheckCapture(39.71, now); err != models.ErrInvalidCaptureAmount {
+		t.Errorf("expected ErrInvalidCaptureAmount, got %v", err)
+	}
+	if err := payment.CheckCapture(10, expiresAt); err != models.ErrAuthorizationExpired {
+		t.Errorf("expected ErrAuthorizationExpired, got %v", err)
+	}
+
+	payment.ApplyTransaction(&models.Transaction{Kind: models.TransactionKindVoid, Status: models.TransactionStatusSuccess, Amount: payment.CapturableAmount()})
+	if payment.Status != models.PaymentStatusCaptured || payment.CapturableAmount() != 0 {
+		t.Errorf("expected the captured part to stay captured, got %s with %v capturable", payment.Status, payment.CapturableAmount())
+	}
+	if err := payment.CheckCapture(1, now); err != models.ErrNothingToCapture {
+		t.Errorf("expected ErrNothingToCapture, got %v", err)
+	}
+}
+
+func TestPayment_VoidWithoutCaptureEndsPayment(t *testing.T) {
+	payment := &models.Payment{Status: models.PaymentStatusPending}
+	payment.ApplyTransaction(&models.Transaction{Kind: models.TransactionKindAuthorization, Status: models.TransactionStatusSuccess, Amount: 25})
+	payment.ApplyTransaction(&models.Transaction{Kind: models.TransactionKindVoid, Status: models.TransactionStatusSuccess, Amount: 25})
+
+	if payment.Status != models.PaymentStatusVoided || payment.CapturableAmount() != 0 {
+		t.Errorf("expected a voided payment, got %s with %v capturable", payment.Status, payment.CapturableAmount())
+	}
+}
@@ -18,6 +18,7 @@ type Payment struct {
 	Status           PaymentStatus          `json:"status" gorm:"default:'pending'"`
 	Amount           float64                `json:"amount" gorm:"type:decimal(12,2);not null"`
 	Currency         string                 `json:"currency" gorm:"default:'USD'"`
+	CaptureMethod    CaptureMethod          `json:"capture_method" gorm:"default:'automatic'"`
 	GatewayReference string                 `json:"gateway_reference"`
 	AuthorizationID  string                 `json:"authorization_id"`
 	TransactionFee   float64                `json:"transaction_fee" gorm:"type:decimal(10,2);default:0"`
@@ -31,12 +32,20 @@ type Payment struct {
 	CreatedAt        time.Time              `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt        time.Time              `json:"updated_at" gorm:"autoUpdateTime"`
 
+	// Ledger totals, kept by ApplyTransaction from the payment's successful
+	// transactions
+	AuthorizedAmount       float64    `json:"authorized_amount" gorm:"type:decimal(12,2);default:0"`
+	CapturedAmount         float64    `json:"captured_amount" gorm:"type:decimal(12,2);default:0"`
+	VoidedAmount           float64    `json:"voided_amount" gorm:"type:decimal(12,2);default:0"`
+	AuthorizationExpiresAt *time.Time `json:"authorization_expires_at"`
+
 	// Relationships
 	Order         interface{}    `json:"order,omitempty" gorm:"-"` // Not stored in DB, populated by service
 	PaymentMethod PaymentMethod  `json:"payment_method,omitempty" gorm:"foreignKey:PaymentMethodID"`
 	Gateway       PaymentGateway `json:"gateway,omitempty" gorm:"foreignKey:GatewayID"`
 	Refunds       []Refund       `json:"refunds,omitempty" gorm:"foreignKey:PaymentID"`
 	Events        []PaymentEvent `json:"events,omitempty" gorm:"foreignKey:PaymentID"`
+	Transactions  []Transaction  `json:"transactions,omitempty" gorm:"foreignKey:PaymentID"`
 }
 
 // IsEntity marks Payment as a federation entity
@@ -176,11 +185,14 @@ const (
 	PaymentEventCreated           PaymentEventType = "created"
 	PaymentEventProcessing        PaymentEventType = "processing"
 	PaymentEventAuthorized        PaymentEventType = "authorized"
+	PaymentEventActionRequired    PaymentEventType = "action_required"
 	PaymentEventCaptured          PaymentEventType = "captured"
+	PaymentEventVoided            PaymentEventType = "voided"
 	PaymentEventFailed            PaymentEventType = "failed"
 	PaymentEventCancelled         PaymentEventType = "cancelled"
 	PaymentEventRefunded          PaymentEventType = "refunded"
 	PaymentEventPartiallyRefunded PaymentEventType = "partially_refunded"
+	PaymentEventDisputed          PaymentEventType = "disputed"
 	PaymentEventUpdated           PaymentEventType = "updated"
 )
 
@@ -197,8 +209,14 @@ type Settlement struct {
 	CreatedAt   time.Time        `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt   time.Time        `json:"updated_at" gorm:"autoUpdateTime"`
 
+	// AdjustmentAmount is the part of Amount made up of balance
+	// adjustments, such as disputed amounts withdrawn, netted into the
+	// settlement
+	AdjustmentAmount float64 `json:"adjustment_amount" gorm:"type:decimal(12,2);default:0"`
+
 	// Relationships
-	Gateway PaymentGateway `json:"gateway,omitempty" gorm:"foreignKey:GatewayID"`
+	Gateway     PaymentGateway      `json:"gateway,omitempty" gorm:"foreignKey:GatewayID"`
+	Adjustments []BalanceAdjustment `json:"adjustments,omitempty" gorm:"foreignKey:SettlementID"`
 }
 
 // SettlementStatus represents the status of a settlement
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WebhookDelivery is a webhook a gateway sent, kept as it was received for
+// audit. Only webhooks whose signature was verified are kept.
+type WebhookDelivery struct {
+	ID          uuid.UUID              `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	GatewayID   uuid.UUID              `json:"gateway_id" gorm:"type:uuid;not null;index"`
+	Status      WebhookDeliveryStatus  `json:"status" gorm:"not null"`
+	Headers     map[string]interface{} `json:"headers" gorm:"type:jsonb"`
+	Payload     string                 `json:"payload" gorm:"type:text;not null"`
+	Events      int                    `json:"events" gorm:"default:0"`
+	Applied     int                    `json:"applied" gorm:"default:0"`    // new events, whether or not they changed a payment
+	Duplicates  int                    `json:"duplicates" gorm:"default:0"` // events recorded before, sent again
+	Error       string                 `json:"error"`
+	ReceivedAt  time.Time              `json:"received_at" gorm:"autoCreateTime;index"`
+	ProcessedAt *time.Time             `json:"processed_at"`
+}
+
+// WebhookDeliveryStatus is how far a webhook delivery was processed
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryReceived  WebhookDeliveryStatus = "received"
+	WebhookDeliveryProcessed WebhookDeliveryStatus = "processed"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// GatewayEvent is an event a gateway reported in a webhook. Events are
+// keyed by the gateway's event ID, so an event sent again, whether retried
+// by the gateway or replayed, is only applied once. Only events that
+// matched no payment are applied again.
+type GatewayEvent struct {
+	GatewayID  uuid.UUID           `json:"gateway_id" gorm:"type:uuid;primaryKey"`
+	EventID    string              `json:"event_id" gorm:"primaryKey"`
+	DeliveryID uuid.UUID           `json:"delivery_id" gorm:"type:uuid;not null;index"`
+	PaymentID  *uuid.UUID          `json:"payment_id" gorm:"type:uuid;index"`
+	Type       string              `json:"type" gorm:"not null"`
+	Reference  string              `json:"reference"`
+	Outcome    GatewayEventOutcome `json:"outcome" gorm:"not null"`
+	OccurredAt *time.Time          `json:"occurred_at"`
+	CreatedAt  time.Time           `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// GatewayEventOutcome is what applying a gateway event did
+type GatewayEventOutcome string
+
+const (
+	// GatewayEventApplied events changed their payment
+	GatewayEventApplied GatewayEventOutcome = "applied"
+	// GatewayEventRecorded events were recorded on their payment without
+	// changing it, such as a capture of a payment already captured
+	GatewayEventRecorded GatewayEventOutcome = "recorded"
+	// GatewayEventUnmatched events are about operations no payment has, or
+	// decide disputes not opened yet. They are applied again when they are
+	// sent again, in case their payment or dispute was recorded after the
+	// gateway reported them.
+	GatewayEventUnmatched GatewayEventOutcome = "unmatched"
+)
+
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
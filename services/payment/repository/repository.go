@@ -2,13 +2,17 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"unified-commerce/services/payment/models"
 	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/tenant"
+	"unified-commerce/shared/messaging"
 )
 
 // PaymentRepository handles database operations for payment management
@@ -25,6 +29,29 @@ func NewPaymentRepository(db *gorm.DB, logger *logger.Logger) *PaymentRepository
 	}
 }
 
+// WithTx returns a new repository with the given transaction
+func (r *PaymentRepository) WithTx(tx *gorm.DB) *PaymentRepository {
+	return &PaymentRepository{
+		db:     tx,
+		logger: r.logger,
+	}
+}
+
+// BeginTx starts a new database transaction
+func (r *PaymentRepository) BeginTx(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Begin()
+}
+
+// CommitTx commits a database transaction
+func (r *PaymentRepository) CommitTx(tx *gorm.DB) error {
+	return tx.Commit().Error
+}
+
+// RollbackTx rolls back a database transaction
+func (r *PaymentRepository) RollbackTx(tx *gorm.DB) {
+	tx.Rollback()
+}
+
 // Payment Operations
 
 // CreatePayment creates a new payment
@@ -36,14 +63,17 @@ func (r *PaymentRepository) CreatePayment(ctx context.Context, payment *models.P
 	return nil
 }
 
-// GetPayment retrieves a payment by ID with all related data
+// GetPayment retrieves a payment of the context's merchant by ID with all
+// related data
 func (r *PaymentRepository) GetPayment(ctx context.Context, id uuid.UUID) (*models.Payment, error) {
 	var payment models.Payment
 	if err := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
 		Preload("PaymentMethod").
 		Preload("Gateway").
 		Preload("Refunds").
 		Preload("Events").
+		Preload("Transactions", orderByCreation).
 		First(&payment, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -54,14 +84,16 @@ func (r *PaymentRepository) GetPayment(ctx context.Context, id uuid.UUID) (*mode
 	return &payment, nil
 }
 
-// GetPaymentByOrderID retrieves a payment by order ID
+// GetPaymentByOrderID retrieves a payment of the context's merchant by order ID
 func (r *PaymentRepository) GetPaymentByOrderID(ctx context.Context, orderID uuid.UUID) (*models.Payment, error) {
 	var payment models.Payment
 	if err := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
 		Preload("PaymentMethod").
 		Preload("Gateway").
 		Preload("Refunds").
 		Preload("Events").
+		Preload("Transactions", orderByCreation).
 		First(&payment, "order_id = ?", orderID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -78,6 +110,7 @@ func (r *PaymentRepository) GetPaymentsByMerchant(ctx context.Context, merchantI
 	var total int64
 
 	query := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
 		Preload("PaymentMethod").
 		Preload("Gateway").
 		Where("merchant_id = ?", merchantID)
@@ -126,6 +159,7 @@ func (r *PaymentRepository) UpdatePaymentStatus(ctx context.Context, id uuid.UUI
 
 	if err := r.db.WithContext(ctx).
 		Model(&models.Payment{}).
+		Scopes(tenant.Scope(ctx)).
 		Where("id = ?", id).
 		Updates(updates).Error; err != nil {
 		r.logger.WithError(err).Error("Failed to update payment status")
@@ -135,6 +169,121 @@ func (r *PaymentRepository) UpdatePaymentStatus(ctx context.Context, id uuid.UUI
 	return nil
 }
 
+// GetCapturablePaymentByOrderID retrieves the latest payment of an order of
+// the context's merchant with part of its authorization left to capture
+func (r *PaymentRepository) GetCapturablePaymentByOrderID(ctx context.Context, orderID uuid.UUID) (*models.Payment, error) {
+	var payment models.Payment
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
+		Where("order_id = ? AND authorized_amount > captured_amount + voided_amount", orderID).
+		Order("created_at DESC").
+		First(&payment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get capturable payment by order ID")
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// GetExpiredAuthorizationIDs retrieves the IDs of up to limit payments whose
+// authorizations lapsed before now with part of them left to capture,
+// longest expired first
+func (r *PaymentRepository) GetExpiredAuthorizationIDs(ctx context.Context, now time.Time, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if err := r.db.WithContext(ctx).Model(&models.Payment{}).
+		Where("authorization_expires_at < ? AND authorized_amount > captured_amount + voided_amount", now).
+		Order("authorization_expires_at").
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to get expired authorizations")
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Transaction Operations
+
+// LedgerChange is a transaction recorded on a payment, saved with the
+// payment together with the event and outbox messages that report it
+type LedgerChange struct {
+	Transaction *models.Transaction
+	Event       *models.PaymentEvent
+	Outbox      []*messaging.OutboxMessage
+}
+
+// UpdatePaymentLedger locks a payment of the context's merchant, lets update
+// record a transaction on it and saves the payment with the change in the
+// same transaction. The lock keeps concurrent captures and voids from
+// taking more of an authorization than is left. A nil change leaves the
+// payment as it was.
+func (r *PaymentRepository) UpdatePaymentLedger(ctx context.Context, id uuid.UUID, update func(payment *models.Payment) (*LedgerChange, error)) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(tenant.Scope(ctx)).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Gateway").
+			First(&payment, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		change, err := update(&payment)
+		if err != nil || change == nil {
+			return err
+		}
+
+		if change.Transaction != nil {
+			if err := tx.Create(change.Transaction).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit(clause.Associations).Save(&payment).Error; err != nil {
+			return err
+		}
+		if change.Event != nil {
+			if err := tx.Create(change.Event).Error; err != nil {
+				return err
+			}
+		}
+		return messaging.EnqueueOutbox(tx, change.Outbox...)
+	})
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to update payment ledger")
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// CreateTransaction records a transaction of a payment
+func (r *PaymentRepository) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	if err := r.db.WithContext(ctx).Create(transaction).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to create transaction")
+		return err
+	}
+	return nil
+}
+
+// GetTransactionsByPayment retrieves the ledger of a payment of the
+// context's merchant in the order it was recorded
+func (r *PaymentRepository) GetTransactionsByPayment(ctx context.Context, paymentID uuid.UUID) ([]*models.Transaction, error) {
+	var transactions []*models.Transaction
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeThrough(ctx, "payment_id", "payments")).
+		Where("payment_id = ?", paymentID).
+		Order("created_at ASC").
+		Find(&transactions).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to get transactions by payment")
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// orderByCreation keeps a preloaded ledger in the order it was recorded
+func orderByCreation(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at ASC")
+}
+
 // Payment Method Operations
 
 // CreatePaymentMethod creates a new payment method
@@ -146,10 +295,13 @@ func (r *PaymentRepository) CreatePaymentMethod(ctx context.Context, paymentMeth
 	return nil
 }
 
-// GetPaymentMethod retrieves a payment method by ID
+// GetPaymentMethod retrieves a payment method of the context's merchant, or
+// one saved to a customer's wallet, by ID
 func (r *PaymentRepository) GetPaymentMethod(ctx context.Context, id uuid.UUID) (*models.PaymentMethod, error) {
 	var paymentMethod models.PaymentMethod
-	if err := r.db.WithContext(ctx).First(&paymentMethod, "id = ?", id).Error; err != nil {
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeOrShared(ctx)).
+		First(&paymentMethod, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
 		}
@@ -159,10 +311,12 @@ func (r *PaymentRepository) GetPaymentMethod(ctx context.Context, id uuid.UUID)
 	return &paymentMethod, nil
 }
 
-// GetPaymentMethodsByCustomer retrieves payment methods for a customer
+// GetPaymentMethodsByCustomer retrieves a customer's payment methods saved
+// for the context's merchant or to their wallet
 func (r *PaymentRepository) GetPaymentMethodsByCustomer(ctx context.Context, customerID uuid.UUID) ([]*models.PaymentMethod, error) {
 	var paymentMethods []*models.PaymentMethod
 	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeOrShared(ctx)).
 		Where("customer_id = ? AND is_active = ?", customerID, true).
 		Order("is_default DESC, created_at DESC").
 		Find(&paymentMethods).Error; err != nil {
@@ -172,19 +326,26 @@ func (r *PaymentRepository) GetPaymentMethodsByCustomer(ctx context.Context, cus
 	return paymentMethods, nil
 }
 
-// UpdatePaymentMethod updates a payment method
+// UpdatePaymentMethod updates a payment method the context's merchant can
+// use. Unlike Save it never inserts, so a method out of scope is left alone.
 func (r *PaymentRepository) UpdatePaymentMethod(ctx context.Context, paymentMethod *models.PaymentMethod) error {
-	if err := r.db.WithContext(ctx).Save(paymentMethod).Error; err != nil {
+	if err := r.db.WithContext(ctx).
+		Model(paymentMethod).
+		Scopes(tenant.ScopeOrShared(ctx)).
+		Select("*").
+		Updates(paymentMethod).Error; err != nil {
 		r.logger.WithError(err).Error("Failed to update payment method")
 		return err
 	}
 	return nil
 }
 
-// DeletePaymentMethod soft deletes a payment method
+// DeletePaymentMethod soft deletes a payment method the context's merchant
+// can use
 func (r *PaymentRepository) DeletePaymentMethod(ctx context.Context, id uuid.UUID) error {
 	if err := r.db.WithContext(ctx).
 		Model(&models.PaymentMethod{}).
+		Scopes(tenant.ScopeOrShared(ctx)).
 		Where("id = ?", id).
 		Updates(map[string]interface{}{
 			"is_active": false,
@@ -232,6 +393,23 @@ func (r *PaymentRepository) GetGatewayByName(ctx context.Context, name string) (
 	return &gateway, nil
 }
 
+// GetGatewayByProvider retrieves the oldest enabled payment gateway of a
+// provider
+func (r *PaymentRepository) GetGatewayByProvider(ctx context.Context, provider string) (*models.PaymentGateway, error) {
+	var gateway models.PaymentGateway
+	if err := r.db.WithContext(ctx).
+		Where("provider = ? AND is_enabled = ?", provider, true).
+		Order("created_at ASC").
+		First(&gateway).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get payment gateway by provider")
+		return nil, err
+	}
+	return &gateway, nil
+}
+
 // GetAllGateways retrieves all payment gateways
 func (r *PaymentRepository) GetAllGateways(ctx context.Context) ([]*models.PaymentGateway, error) {
 	var gateways []*models.PaymentGateway
@@ -264,39 +442,53 @@ func (r *PaymentRepository) CreateRefund(ctx context.Context, refund *models.Ref
 		}
 
 		// Update payment status if fully refunded
-		var payment models.Payment
-		if err := tx.First(&payment, "id = ?", refund.PaymentID).Error; err != nil {
-			return err
-		}
+		return updateRefundedStatus(tx, refund.PaymentID)
+	})
+}
 
-		// Calculate total refunded amount
-		var totalRefunded float64
-		if err := tx.Model(&models.Refund{}).
-			Where("payment_id = ? AND status IN ?", refund.PaymentID, []models.RefundStatus{models.RefundStatusCompleted, models.RefundStatusProcessed}).
-			Select("COALESCE(SUM(amount), 0)").
-			Scan(&totalRefunded).Error; err != nil {
+// CompleteRefund saves a refund the gateway completed and updates its
+// payment's status with it
+func (r *PaymentRepository) CompleteRefund(ctx context.Context, refund *models.Refund) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Save(refund).Error; err != nil {
 			return err
 		}
+		return updateRefundedStatus(tx, refund.PaymentID)
+	})
+}
 
-		// Update payment status based on refund amount
-		if totalRefunded >= payment.Amount {
-			payment.Status = models.PaymentStatusRefunded
-		} else if totalRefunded > 0 {
-			payment.Status = models.PaymentStatusPartiallyRefunded
-		}
+// updateRefundedStatus marks a payment refunded or partially refunded by
+// its completed refunds
+func updateRefundedStatus(tx *gorm.DB, paymentID uuid.UUID) error {
+	var payment models.Payment
+	if err := tx.First(&payment, "id = ?", paymentID).Error; err != nil {
+		return err
+	}
 
-		if err := tx.Save(&payment).Error; err != nil {
-			return err
-		}
+	// Calculate total refunded amount
+	var totalRefunded float64
+	if err := tx.Model(&models.Refund{}).
+		Where("payment_id = ? AND status IN ?", paymentID, []models.RefundStatus{models.RefundStatusCompleted, models.RefundStatusProcessed}).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&totalRefunded).Error; err != nil {
+		return err
+	}
 
-		return nil
-	})
+	// Update payment status based on refund amount
+	if totalRefunded >= payment.CapturedAmount {
+		payment.Status = models.PaymentStatusRefunded
+	} else if totalRefunded > 0 {
+		payment.Status = models.PaymentStatusPartiallyRefunded
+	}
+
+	return tx.Save(&payment).Error
 }
 
 // GetRefund retrieves a refund by ID
 func (r *PaymentRepository) GetRefund(ctx context.Context, id uuid.UUID) (*models.Refund, error) {
 	var refund models.Refund
 	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeThrough(ctx, "payment_id", "payments")).
 		Preload("Payment").
 		First(&refund, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -312,6 +504,7 @@ func (r *PaymentRepository) GetRefund(ctx context.Context, id uuid.UUID) (*model
 func (r *PaymentRepository) GetRefundsByPayment(ctx context.Context, paymentID uuid.UUID) ([]*models.Refund, error) {
 	var refunds []*models.Refund
 	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeThrough(ctx, "payment_id", "payments")).
 		Preload("Payment").
 		Where("payment_id = ?", paymentID).
 		Order("created_at DESC").
@@ -346,6 +539,7 @@ func (r *PaymentRepository) CreatePaymentEvent(ctx context.Context, event *model
 func (r *PaymentRepository) GetPaymentEvents(ctx context.Context, paymentID uuid.UUID) ([]*models.PaymentEvent, error) {
 	var events []*models.PaymentEvent
 	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeThrough(ctx, "payment_id", "payments")).
 		Where("payment_id = ?", paymentID).
 		Order("created_at DESC").
 		Find(&events).Error; err != nil {
@@ -357,20 +551,78 @@ func (r *PaymentRepository) GetPaymentEvents(ctx context.Context, paymentID uuid
 
 // Settlement Operations
 
-// CreateSettlement creates a new settlement
+// CreateSettlement creates a new settlement, netting into it the balance
+// adjustments of its gateway and currency made while the gateway had no
+// pending settlement
 func (r *PaymentRepository) CreateSettlement(ctx context.Context, settlement *models.Settlement) error {
-	if err := r.db.WithContext(ctx).Create(settlement).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var unsettled []*models.BalanceAdjustment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("gateway_id = ? AND currency = ? AND settlement_id IS NULL", settlement.GatewayID, settlement.Currency).
+			Find(&unsettled).Error; err != nil {
+			return err
+		}
+
+		ids := make([]uuid.UUID, 0, len(unsettled))
+		for _, adjustment := range unsettled {
+			settlement.Amount += adjustment.Amount
+			settlement.AdjustmentAmount += adjustment.Amount
+			ids = append(ids, adjustment.ID)
+		}
+		if err := tx.Create(settlement).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Model(&models.BalanceAdjustment{}).
+			Where("id IN ?", ids).
+			Update("settlement_id", settlement.ID).Error
+	})
+	if err != nil {
 		r.logger.WithError(err).Error("Failed to create settlement")
 		return err
 	}
 	return nil
 }
 
-// GetSettlement retrieves a settlement by ID
+// settleAdjustments stores balance adjustments, netting each into the
+// newest pending settlement of its gateway and currency. Adjustments made
+// while the gateway has no pending settlement wait for its next one.
+func settleAdjustments(tx *gorm.DB, adjustments []*models.BalanceAdjustment) error {
+	for _, adjustment := range adjustments {
+		var settlement models.Settlement
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("gateway_id = ? AND currency = ? AND status = ?", adjustment.GatewayID, adjustment.Currency, models.SettlementStatusPending).
+			Order("created_at DESC").
+			First(&settlement).Error
+		switch {
+		case err == nil:
+			adjustment.SettlementID = &settlement.ID
+			if err := tx.Model(&settlement).Updates(map[string]interface{}{
+				"amount":            gorm.Expr("amount + ?", adjustment.Amount),
+				"adjustment_amount": gorm.Expr("adjustment_amount + ?", adjustment.Amount),
+			}).Error; err != nil {
+				return err
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		if err := tx.Create(adjustment).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSettlement retrieves a settlement by ID with the balance adjustments
+// netted into it
 func (r *PaymentRepository) GetSettlement(ctx context.Context, id uuid.UUID) (*models.Settlement, error) {
 	var settlement models.Settlement
 	if err := r.db.WithContext(ctx).
 		Preload("Gateway").
+		Preload("Adjustments", orderByCreation).
 		First(&settlement, "id = ?", id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -414,3 +666,360 @@ func (r *PaymentRepository) UpdateSettlement(ctx context.Context, settlement *mo
 	}
 	return nil
 }
+
+// Dispute Operations
+
+// OpenDispute stores a dispute a gateway opened together with the balance
+// adjustments it makes, the payment event recording it and the outbox
+// messages reporting it. It reports whether the dispute was stored; a
+// dispute the gateway reported before is left as it was.
+func (r *PaymentRepository) OpenDispute(ctx context.Context, dispute *models.Dispute, change *DisputeChange) (bool, error) {
+	created := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(dispute)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		created = true
+		return saveDisputeChange(tx, dispute, change)
+	})
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to open dispute")
+		return false, err
+	}
+	return created, nil
+}
+
+// DisputeChange is what a change of a dispute writes besides the dispute:
+// the balance adjustments it makes, the event recording it on the disputed
+// payment and the outbox messages reporting it
+type DisputeChange struct {
+	Adjustments []*models.BalanceAdjustment
+	Event       *models.PaymentEvent
+	Outbox      []*messaging.OutboxMessage
+}
+
+// UpdateDispute locks a dispute of the context's merchant, lets update
+// change it and saves it with the change in the same transaction. A nil
+// change leaves the dispute as it was.
+func (r *PaymentRepository) UpdateDispute(ctx context.Context, id uuid.UUID, update func(dispute *models.Dispute) (*DisputeChange, error)) (*models.Dispute, error) {
+	var dispute models.Dispute
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(tenant.Scope(ctx)).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&dispute, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		change, err := update(&dispute)
+		if err != nil || change == nil {
+			return err
+		}
+
+		if err := tx.Omit(clause.Associations).Save(&dispute).Error; err != nil {
+			return err
+		}
+		return saveDisputeChange(tx, &dispute, change)
+	})
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to update dispute")
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// saveDisputeChange stores what a change of a dispute writes besides the
+// dispute
+func saveDisputeChange(tx *gorm.DB, dispute *models.Dispute, change *DisputeChange) error {
+	if change == nil {
+		return nil
+	}
+	for _, adjustment := range change.Adjustments {
+		adjustment.DisputeID = &dispute.ID
+	}
+	if err := settleAdjustments(tx, change.Adjustments); err != nil {
+		return err
+	}
+	if change.Event != nil {
+		if err := tx.Create(change.Event).Error; err != nil {
+			return err
+		}
+	}
+	return messaging.EnqueueOutbox(tx, change.Outbox...)
+}
+
+// GetDispute retrieves a dispute of the context's merchant by ID with its
+// balance adjustments
+func (r *PaymentRepository) GetDispute(ctx context.Context, id uuid.UUID) (*models.Dispute, error) {
+	var dispute models.Dispute
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
+		Preload("Adjustments", orderByCreation).
+		First(&dispute, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get dispute")
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// GetDisputeByGatewayReference retrieves the dispute of a gateway with the
+// gateway's ID of the dispute
+func (r *PaymentRepository) GetDisputeByGatewayReference(ctx context.Context, gatewayID uuid.UUID, reference string) (*models.Dispute, error) {
+	var dispute models.Dispute
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
+		First(&dispute, "gateway_id = ? AND gateway_reference = ?", gatewayID, reference).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get dispute by gateway reference")
+		return nil, err
+	}
+	return &dispute, nil
+}
+
+// GetDisputesByMerchant retrieves disputes of a merchant, those whose
+// evidence is due soonest first
+func (r *PaymentRepository) GetDisputesByMerchant(ctx context.Context, merchantID uuid.UUID, filters map[string]interface{}, limit, offset int) ([]*models.Dispute, int64, error) {
+	var disputes []*models.Dispute
+	var total int64
+
+	query := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
+		Where("merchant_id = ?", merchantID)
+	for key, value := range filters {
+		query = query.Where(key+" = ?", value)
+	}
+
+	if err := query.Model(&models.Dispute{}).Count(&total).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to count disputes")
+		return nil, 0, err
+	}
+
+	if err := query.Order("evidence_due_by ASC NULLS LAST, created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&disputes).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to get disputes")
+		return nil, 0, err
+	}
+
+	return disputes, total, nil
+}
+
+// GetDisputesByPayment retrieves the disputes of a payment of the context's
+// merchant
+func (r *PaymentRepository) GetDisputesByPayment(ctx context.Context, paymentID uuid.UUID) ([]*models.Dispute, error) {
+	var disputes []*models.Dispute
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.Scope(ctx)).
+		Where("payment_id = ?", paymentID).
+		Order("created_at DESC").
+		Find(&disputes).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to get disputes by payment")
+		return nil, err
+	}
+	return disputes, nil
+}
+
+// GetDisputedAmount returns how much of a payment is held or taken by its
+// open and lost disputes
+func (r *PaymentRepository) GetDisputedAmount(ctx context.Context, paymentID uuid.UUID) (float64, error) {
+	var amount float64
+	if err := r.db.WithContext(ctx).
+		Model(&models.Dispute{}).
+		Where("payment_id = ? AND status IN ?", paymentID, []models.DisputeStatus{
+			models.DisputeStatusNeedsResponse, models.DisputeStatusUnderReview, models.DisputeStatusLost,
+		}).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&amount).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to get disputed amount")
+		return 0, err
+	}
+	return amount, nil
+}
+
+// Balance Operations
+
+// GetMerchantBalances retrieves a merchant's balance in each currency it
+// was paid in
+func (r *PaymentRepository) GetMerchantBalances(ctx context.Context, merchantID uuid.UUID) ([]*models.MerchantBalance, error) {
+	type total struct {
+		Currency string
+		Amount   float64
+	}
+	var captured, refunded, adjusted []total
+
+	db := r.db.WithContext(ctx)
+	if err := db.Model(&models.Payment{}).
+		Scopes(tenant.Scope(ctx)).
+		Select("currency, COALESCE(SUM(captured_amount - transaction_fee), 0) AS amount").
+		Where("merchant_id = ? AND captured_amount > 0", merchantID).
+		Group("currency").
+		Scan(&captured).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to total captured payments")
+		return nil, err
+	}
+	if err := db.Model(&models.Refund{}).
+		Scopes(tenant.ScopeThrough(ctx, "payment_id", "payments")).
+		Select("refunds.currency, COALESCE(SUM(refunds.amount), 0) AS amount").
+		Where("refunds.status IN ?", []models.RefundStatus{models.RefundStatusCompleted, models.RefundStatusProcessed}).
+		Where("refunds.payment_id IN (?)", db.Session(&gorm.Session{NewDB: true}).
+			Model(&models.Payment{}).Select("id").Where("merchant_id = ?", merchantID)).
+		Group("refunds.currency").
+		Scan(&refunded).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to total refunds")
+		return nil, err
+	}
+	if err := db.Model(&models.BalanceAdjustment{}).
+		Scopes(tenant.Scope(ctx)).
+		Select("currency, COALESCE(SUM(amount), 0) AS amount").
+		Where("merchant_id = ?", merchantID).
+		Group("currency").
+		Scan(&adjusted).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to total balance adjustments")
+		return nil, err
+	}
+
+	var balances []*models.MerchantBalance
+	byCurrency := make(map[string]*models.MerchantBalance)
+	balance := func(currency string) *models.MerchantBalance {
+		if _, ok := byCurrency[currency]; !ok {
+			byCurrency[currency] = &models.MerchantBalance{Currency: currency}
+			balances = append(balances, byCurrency[currency])
+		}
+		return byCurrency[currency]
+	}
+	for _, t := range captured {
+		balance(t.Currency).Captured += t.Amount
+	}
+	for _, t := range refunded {
+		balance(t.Currency).Refunded += t.Amount
+	}
+	for _, t := range adjusted {
+		balance(t.Currency).Adjustments += t.Amount
+	}
+	for _, b := range balances {
+		b.Available = b.Captured - b.Refunded + b.Adjustments
+	}
+	return balances, nil
+}
+
+// Webhook Operations
+
+// CreateWebhookDelivery stores a webhook a gateway sent
+func (r *PaymentRepository) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if err := r.db.WithContext(ctx).Create(delivery).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to create webhook delivery")
+		return err
+	}
+	return nil
+}
+
+// UpdateWebhookDelivery updates how far a webhook delivery was processed
+func (r *PaymentRepository) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if err := r.db.WithContext(ctx).Save(delivery).Error; err != nil {
+		r.logger.WithError(err).Error("Failed to update webhook delivery")
+		return err
+	}
+	return nil
+}
+
+// RecordGatewayEvent records a gateway event and lets apply change payments
+// for it in the same transaction, through a repository bound to the
+// transaction. It reports whether the gateway event was recorded before, in
+// which case apply is not called, unless the event matched no payment then:
+// its payment may have been recorded since, so it is applied again. A
+// concurrent delivery of the same event waits for the first one to commit
+// or roll back.
+func (r *PaymentRepository) RecordGatewayEvent(ctx context.Context, event *models.GatewayEvent, apply func(repo *PaymentRepository) error) (bool, error) {
+	duplicate := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var recorded models.GatewayEvent
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("gateway_id = ? AND event_id = ?", event.GatewayID, event.EventID).
+				First(&recorded).Error; err != nil {
+				return err
+			}
+			if recorded.Outcome != models.GatewayEventUnmatched {
+				duplicate = true
+				return nil
+			}
+			event.CreatedAt = recorded.CreatedAt
+		}
+
+		if err := apply(r.WithTx(tx)); err != nil {
+			return err
+		}
+		return tx.Save(event).Error
+	})
+	if err != nil {
+		r.logger.WithError(err).Error("Failed to record gateway event")
+		return false, err
+	}
+	return duplicate, nil
+}
+
+// GetPaymentByGatewayReference retrieves the payment of a gateway that an
+// operation reference belongs to: its authorization or sale, one of its
+// captures or voids, or one of its refunds
+func (r *PaymentRepository) GetPaymentByGatewayReference(ctx context.Context, gatewayID uuid.UUID, reference string) (*models.Payment, error) {
+	db := r.db.WithContext(ctx)
+	transactions := db.Session(&gorm.Session{NewDB: true}).
+		Model(&models.Transaction{}).Select("payment_id").Where("gateway_reference = ?", reference)
+	refunds := db.Session(&gorm.Session{NewDB: true}).
+		Model(&models.Refund{}).Select("payment_id").Where("gateway_reference = ?", reference)
+
+	var payment models.Payment
+	if err := db.Scopes(tenant.Scope(ctx)).
+		Where("gateway_id = ?", gatewayID).
+		Where("gateway_reference = ? OR authorization_id = ? OR id IN (?) OR id IN (?)", reference, reference, transactions, refunds).
+		First(&payment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get payment by gateway reference")
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// GetRefundByGatewayReference retrieves the refund of a payment with a
+// gateway reference
+func (r *PaymentRepository) GetRefundByGatewayReference(ctx context.Context, paymentID uuid.UUID, reference string) (*models.Refund, error) {
+	var refund models.Refund
+	if err := r.db.WithContext(ctx).
+		Scopes(tenant.ScopeThrough(ctx, "payment_id", "payments")).
+		Where("payment_id = ? AND gateway_reference = ?", paymentID, reference).
+		First(&refund).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		r.logger.WithError(err).Error("Failed to get refund by gateway reference")
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// Outbox Operations
+
+// EnqueueOutbox stores events to be relayed to the event stream. Use it on a
+// repository bound to the transaction of the change the events describe.
+func (r *PaymentRepository) EnqueueOutbox(ctx context.Context, messages ...*messaging.OutboxMessage) error {
+	if err := messaging.EnqueueOutbox(r.db.WithContext(ctx), messages...); err != nil {
+		r.logger.WithError(err).Error("Failed to enqueue outbox messages")
+		return err
+	}
+	return nil
+}
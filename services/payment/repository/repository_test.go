@@ -0,0 +1,186 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/payment/migrations"
+	"unified-commerce/services/payment/models"
+	"unified-commerce/services/payment/repository"
+	"unified-commerce/services/shared/database/dbtest"
+	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/tenant"
+)
+
+func newTestRepository(t *testing.T) *repository.PaymentRepository {
+	t.Helper()
+	db := dbtest.Open(t, "payment", migrations.All())
+	return repository.NewPaymentRepository(db, logger.NewLogger(logger.Config{Level: "error"}))
+}
+
+// createPayment stores a captured payment of the merchant with the gateway
+// and payment method it references
+func createPayment(t *testing.T, repo *repository.PaymentRepository, merchantID uuid.UUID, amount float64) *models.Payment {
+	t.Helper()
+	ctx := tenant.WithMerchantID(context.Background(), merchantID.String())
+
+	gateway := &models.PaymentGateway{ID: uuid.New(), Name: "gateway-" + uuid.NewString(), Provider: "stripe"}
+	if err := repo.CreateGateway(ctx, gateway); err != nil {
+		t.Fatal(err)
+	}
+	method := &models.PaymentMethod{ID: uuid.New(), MerchantID: &merchantID, Type: models.PaymentMethodTypeCreditCard}
+	if err := repo.CreatePaymentMethod(ctx, method); err != nil {
+		t.Fatal(err)
+	}
+	payment := &models.Payment{
+		ID:              uuid.New(),
+		OrderID:         uuid.New(),
+		MerchantID:      merchantID,
+		PaymentMethodID: method.ID,
+		GatewayID:       gateway.ID,
+		Status:          models.PaymentStatusCaptured,
+		Amount:          amount,
+		Currency:        "USD",
+		CapturedAmount:  amount,
+	}
+	if err := repo.CreatePayment(ctx, payment); err != nil {
+		t.Fatal(err)
+	}
+	return payment
+}
+
+func TestPaymentRepository_HidesOtherMerchantsPayments(t *testing.T) {
+	repo := newTestRepository(t)
+	merchantA, merchantB := uuid.New(), uuid.New()
+	payment := createPayment(t, repo, merchantA, 50)
+
+	ctxA := tenant.WithMerchantID(context.Background(), merchantA.String())
+	if found, err := repo.GetPayment(ctxA, payment.ID); err != nil || found == nil {
+		t.Fatalf("owner lookup: payment = %v, err = %v", found, err)
+	}
+
+	ctxB := tenant.WithMerchantID(context.Background(), merchantB.String())
+	if found, err := repo.GetPayment(ctxB, payment.ID); err != nil || found != nil {
+		t.Fatalf("another merchant got payment %v, err = %v; want not found", found, err)
+	}
+}
+
+func TestPaymentRepository_HidesOtherMerchantsPaymentMethods(t *testing.T) {
+	repo := newTestRepository(t)
+	merchantA, merchantB, customerID := uuid.New(), uuid.New(), uuid.New()
+	ctxA := tenant.WithMerchantID(context.Background(), merchantA.String())
+	ctxB := tenant.WithMerchantID(context.Background(), merchantB.String())
+
+	owned := &models.PaymentMethod{ID: uuid.New(), CustomerID: &customerID, MerchantID: &merchantA, Type: models.PaymentMethodTypeCreditCard, IsActive: true}
+	wallet := &models.PaymentMethod{ID: uuid.New(), CustomerID: &customerID, Type: models.PaymentMethodTypeCreditCard, IsActive: true}
+	for _, method := range []*models.PaymentMethod{owned, wallet} {
+		if err := repo.CreatePaymentMethod(ctxA, method); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if found, err := repo.GetPaymentMethod(ctxB, owned.ID); err != nil || found != nil {
+		t.Fatalf("another merchant got payment method %v, err = %v; want not found", found, err)
+	}
+	if found, err := repo.GetPaymentMethod(ctxB, wallet.ID); err != nil || found == nil {
+		t.Fatalf("wallet lookup: payment method = %v, err = %v", found, err)
+	}
+	methods, err := repo.GetPaymentMethodsByCustomer(ctxB, customerID)
+	if err != nil || len(methods) != 1 || methods[0].ID != wallet.ID {
+		t.Fatalf("another merchant listed %d payment methods, err = %v; want the wallet only", len(methods), err)
+	}
+
+	// Writes through another merchant leave the method untouched
+	changed := *owned
+	changed.Name = "changed"
+	if err := repo.UpdatePaymentMethod(ctxB, &changed); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeletePaymentMethod(ctxB, owned.ID); err != nil {
+		t.Fatal(err)
+	}
+	found, err := repo.GetPaymentMethod(ctxA, owned.ID)
+	if err != nil || found == nil {
+		t.Fatalf("owner lookup: payment method = %v, err = %v", found, err)
+	}
+	if found.Name != "" || !found.IsActive {
+		t.Fatalf("payment method = %+v, want it unchanged by another merchant", found)
+	}
+}
+
+func TestPaymentRepository_NetsAdjustmentsIntoSettlements(t *testing.T) {
+	repo := newTestRepository(t)
+	merchantID := uuid.New()
+	payment := createPayment(t, repo, merchantID, 100)
+	ctx := tenant.WithMerchantID(context.Background(), merchantID.String())
+
+	adjustment := func(kind models.BalanceAdjustmentType, amount float64) *models.BalanceAdjustment {
+		return &models.BalanceAdjustment{
+			MerchantID: merchantID,
+			GatewayID:  payment.GatewayID,
+			PaymentID:  &payment.ID,
+			Type:       kind,
+			Amount:     amount,
+			Currency:   "USD",
+		}
+	}
+
+	// Opened while the gateway has no pending settlement, so its
+	// adjustments wait for the next one
+	dispute := &models.Dispute{
+		PaymentID:        payment.ID,
+		OrderID:          payment.OrderID,
+		MerchantID:       merchantID,
+		GatewayID:        payment.GatewayID,
+		GatewayReference: "dp_1",
+		Reason:           models.DisputeReasonFraudulent,
+		Amount:           30,
+		Currency:         "USD",
+	}
+	change := &repository.DisputeChange{Adjustments: []*models.BalanceAdjustment{
+		adjustment(models.BalanceAdjustmentDisputeWithdrawal, -30),
+		adjustment(models.BalanceAdjustmentDisputeFee, -15),
+	}}
+	if created, err := repo.OpenDispute(ctx, dispute, change); err != nil || !created {
+		t.Fatalf("open dispute: created = %v, err = %v", created, err)
+	}
+
+	settlement := &models.Settlement{GatewayID: payment.GatewayID, Reference: "st_1", Amount: 500, Currency: "USD"}
+	if err := repo.CreateSettlement(ctx, settlement); err != nil {
+		t.Fatal(err)
+	}
+	if settlement.Amount != 455 || settlement.AdjustmentAmount != -45 {
+		t.Fatalf("settlement amount = %v with %v adjusted, want 455 with -45", settlement.Amount, settlement.AdjustmentAmount)
+	}
+
+	// Won while the settlement is pending, so the reversal nets into it
+	_, err := repo.UpdateDispute(ctx, dispute.ID, func(dispute *models.Dispute) (*repository.DisputeChange, error) {
+		dispute.Resolve(true, time.Now())
+		return &repository.DisputeChange{Adjustments: []*models.BalanceAdjustment{
+			adjustment(models.BalanceAdjustmentDisputeReversal, 30),
+		}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := repo.GetSettlement(ctx, settlement.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("reload settlement: settlement = %v, err = %v", stored, err)
+	}
+	if stored.Amount != 485 || stored.AdjustmentAmount != -15 || len(stored.Adjustments) != 3 {
+		t.Fatalf("settlement amount = %v with %v adjusted over %d adjustments, want 485 with -15 over 3",
+			stored.Amount, stored.AdjustmentAmount, len(stored.Adjustments))
+	}
+
+	// Every adjustment is settled, so the next settlement has none
+	next := &models.Settlement{GatewayID: payment.GatewayID, Reference: "st_2", Amount: 100, Currency: "USD"}
+	if err := repo.CreateSettlement(ctx, next); err != nil {
+		t.Fatal(err)
+	}
+	if next.Amount != 100 || next.AdjustmentAmount != 0 {
+		t.Fatalf("next settlement amount = %v with %v adjusted, want 100 with none", next.Amount, next.AdjustmentAmount)
+	}
+}
@@ -0,0 +1,328 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/payment/gateway"
+	"unified-commerce/services/payment/models"
+	"unified-commerce/services/payment/repository"
+	"unified-commerce/services/shared/tenant"
+	"unified-commerce/shared/messaging"
+)
+
+// Captures and Voids
+
+// DefaultAuthorizationTTL is how long an authorization can be captured when
+// its gateway's settings do not say, the shortest hold card networks
+// commonly honor
+const DefaultAuthorizationTTL = 7 * 24 * time.Hour
+
+// authorizationTTL returns how long authorizations of a gateway can be
+// captured, from its authorization_ttl_hours setting
+func authorizationTTL(paymentGateway *models.PaymentGateway) time.Duration {
+	if hours, ok := paymentGateway.Settings["authorization_ttl_hours"].(float64); ok && hours > 0 {
+		return time.Duration(hours * float64(time.Hour))
+	}
+	return DefaultAuthorizationTTL
+}
+
+// CapturePayment captures an amount of a payment's authorization, or all
+// that is left of it when amount is nil. A payment can be captured several
+// times until its authorization is used up, voided or expires.
+func (s *PaymentService) CapturePayment(ctx context.Context, paymentID uuid.UUID, amount *float64) (*models.Payment, error) {
+	return s.capturePayment(ctx, s.repo, paymentID, amount)
+}
+
+// CaptureOrderPayment captures an amount of the authorized payment of an
+// order, or all that is left of its authorization when amount is nil
+func (s *PaymentService) CaptureOrderPayment(ctx context.Context, orderID uuid.UUID, amount *float64) (*models.Payment, error) {
+	return s.captureOrderPayment(ctx, s.repo, orderID, amount)
+}
+
+// CaptureOrderPaymentInTx captures the authorized payment of an order inside
+// a transaction owned by the caller
+func (s *PaymentService) CaptureOrderPaymentInTx(ctx context.Context, tx *gorm.DB, orderID uuid.UUID, amount *float64) (*models.Payment, error) {
+	return s.captureOrderPayment(ctx, s.repo.WithTx(tx), orderID, amount)
+}
+
+// captureOrderPayment captures the latest payment of an order with part of
+// its authorization left, using the given repository
+func (s *PaymentService) captureOrderPayment(ctx context.Context, repo *repository.PaymentRepository, orderID uuid.UUID, amount *float64) (*models.Payment, error) {
+	payment, err := repo.GetCapturablePaymentByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		return nil, ErrNothingToCapture
+	}
+	return s.capturePayment(ctx, repo, payment.ID, amount)
+}
+
+// capturePayment captures a payment through its gateway using the given
+// repository, recording the capture on the payment's ledger and publishing
+// it to the payment's order. A capture the gateway declines is recorded as
+// failed and returns ErrCaptureFailed, leaving the authorization open.
+func (s *PaymentService) capturePayment(ctx context.Context, repo *repository.PaymentRepository, paymentID uuid.UUID, amount *float64) (*models.Payment, error) {
+	var declined error
+	payment, err := repo.UpdatePaymentLedger(ctx, paymentID, func(payment *models.Payment) (*repository.LedgerChange, error) {
+		now := time.Now()
+		captureAmount := payment.CapturableAmount()
+		if amount != nil {
+			captureAmount = *amount
+		}
+		if err := payment.CheckCapture(captureAmount, now); err != nil {
+			return nil, err
+		}
+
+		adapter, err := s.gateways.Open(&payment.Gateway)
+		if err != nil {
+			return nil, err
+		}
+		result, err := adapter.Capture(ctx, &gateway.CaptureRequest{
+			PaymentID: payment.ID,
+			Reference: payment.AuthorizationID,
+			Amount:    captureAmount,
+			Currency:  payment.Currency,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		transaction := newTransaction(payment, models.TransactionKindCapture, captureAmount, result)
+		if !result.Succeeded() {
+			declined = fmt.Errorf("%w: %s", ErrCaptureFailed, result.DeclineCode)
+			return &repository.LedgerChange{Transaction: transaction}, nil
+		}
+
+		payment.ApplyTransaction(transaction)
+		payment.TransactionFee += result.Fee
+		payment.NetAmount = payment.CapturedAmount - payment.TransactionFee
+		payment.CompletedAt = &now
+
+		msg, err := s.newPaymentOutboxMessage(ctx, messaging.EventTypePaymentCaptured, "payments.captured", newPaymentOutcomeEvent(payment, captureAmount))
+		if err != nil {
+			return nil, err
+		}
+		return &repository.LedgerChange{
+			Transaction: transaction,
+			Event: &models.PaymentEvent{
+				PaymentID:   payment.ID,
+				EventType:   models.PaymentEventCaptured,
+				Description: fmt.Sprintf("Captured %.2f of %.2f authorized", captureAmount, payment.AuthorizedAmount),
+				Metadata:    gatewayResultMetadata(result),
+			},
+			Outbox: []*messaging.OutboxMessage{msg},
+		}, nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("payment_id", paymentID).Error("Failed to capture payment")
+		return nil, err
+	}
+	if declined != nil {
+		s.logger.WithField("payment_id", paymentID).WithError(declined).Info("Payment capture failed")
+		return payment, declined
+	}
+
+	s.logger.WithField("payment_id", paymentID).WithField("captured_amount", payment.CapturedAmount).Info("Payment captured successfully")
+	return payment, nil
+}
+
+// VoidPayment releases what is left of a payment's authorization, ending
+// the payment if nothing of it was captured
+func (s *PaymentService) VoidPayment(ctx context.Context, paymentID uuid.UUID) (*models.Payment, error) {
+	return s.voidPayment(ctx, paymentID, false)
+}
+
+// ExpireAuthorizations voids what is left of authorizations that lapsed,
+// returning how many were voided
+func (s *PaymentService) ExpireAuthorizations(ctx context.Context) (int, error) {
+	ctx = tenant.WithoutScope(ctx)
+
+	ids, err := s.repo.GetExpiredAuthorizationIDs(ctx, time.Now(), 100)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		if _, err := s.voidPayment(ctx, id, true); err != nil {
+			if errors.Is(err, ErrNothingToCapture) {
+				continue // captured or voided since it was found
+			}
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// voidPayment voids a payment's authorization through its gateway,
+// recording the void on the payment's ledger and publishing it to the
+// payment's order. A void the gateway declines is recorded as failed and
+// returns ErrVoidFailed. Expired authorizations are released whatever the
+// gateway answers, since the provider lets them go as well.
+func (s *PaymentService) voidPayment(ctx context.Context, paymentID uuid.UUID, expired bool) (*models.Payment, error) {
+	reason, description := "voided", "Authorization voided"
+	if expired {
+		reason, description = "expired", "Authorization expired"
+	}
+
+	var declined error
+	payment, err := s.repo.UpdatePaymentLedger(ctx, paymentID, func(payment *models.Payment) (*repository.LedgerChange, error) {
+		now := time.Now()
+		remaining := payment.CapturableAmount()
+		if remaining <= 0 {
+			return nil, ErrNothingToCapture
+		}
+
+		result, err := s.voidAuthorization(ctx, payment)
+		switch {
+		case expired && (err != nil || !result.Succeeded()):
+			s.logger.WithError(err).WithField("payment_id", payment.ID).Warn("Gateway failed to void expired authorization")
+			result = &gateway.Result{Status: gateway.StatusSucceeded, Reference: payment.AuthorizationID}
+		case err != nil:
+			return nil, err
+		case !result.Succeeded():
+			declined = fmt.Errorf("%w: %s", ErrVoidFailed, result.DeclineCode)
+			return &repository.LedgerChange{Transaction: newTransaction(payment, models.TransactionKindVoid, remaining, result)}, nil
+		}
+
+		transaction := newTransaction(payment, models.TransactionKindVoid, remaining, result)
+		transaction.Description = description
+		payment.ApplyTransaction(transaction)
+		if payment.CapturedAmount == 0 {
+			payment.CompletedAt = &now
+		}
+
+		outcome := newPaymentOutcomeEvent(payment, remaining)
+		outcome.Reason = reason
+		msg, err := s.newPaymentOutboxMessage(ctx, messaging.EventTypePaymentVoided, "payments.voided", outcome)
+		if err != nil {
+			return nil, err
+		}
+		return &repository.LedgerChange{
+			Transaction: transaction,
+			Event: &models.PaymentEvent{
+				PaymentID:   payment.ID,
+				EventType:   models.PaymentEventVoided,
+				Description: fmt.Sprintf("%s, releasing %.2f", description, remaining),
+				Metadata:    gatewayResultMetadata(result),
+			},
+			Outbox: []*messaging.OutboxMessage{msg},
+		}, nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("payment_id", paymentID).Error("Failed to void payment")
+		return nil, err
+	}
+	if declined != nil {
+		s.logger.WithField("payment_id", paymentID).WithError(declined).Info("Payment void failed")
+		return payment, declined
+	}
+
+	s.logger.WithField("payment_id", paymentID).WithField("reason", reason).Info("Payment authorization voided")
+	return payment, nil
+}
+
+// voidAuthorization asks a payment's gateway to release its authorization
+func (s *PaymentService) voidAuthorization(ctx context.Context, payment *models.Payment) (*gateway.Result, error) {
+	adapter, err := s.gateways.Open(&payment.Gateway)
+	if err != nil {
+		return nil, err
+	}
+	return adapter.Void(ctx, &gateway.VoidRequest{
+		PaymentID: payment.ID,
+		Reference: payment.AuthorizationID,
+	})
+}
+
+// GetPaymentTransactions retrieves the ledger of a payment
+func (s *PaymentService) GetPaymentTransactions(ctx context.Context, paymentID uuid.UUID) ([]*models.Transaction, error) {
+	if _, err := s.GetPayment(ctx, paymentID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetTransactionsByPayment(ctx, paymentID)
+}
+
+// newTransaction records a gateway's answer to an operation on a payment as
+// a transaction of its ledger
+func newTransaction(payment *models.Payment, kind models.TransactionKind, amount float64, result *gateway.Result) *models.Transaction {
+	now := time.Now()
+	orderID := payment.OrderID
+	transaction := &models.Transaction{
+		PaymentID:         payment.ID,
+		OrderID:           &orderID,
+		Amount:            amount,
+		Currency:          payment.Currency,
+		Type:              transactionType(kind),
+		Status:            models.TransactionStatusSuccess,
+		Gateway:           payment.Gateway.Provider,
+		GatewayReference:  result.Reference,
+		ProcessorResponse: result.Message,
+		Kind:              kind,
+		PaymentMethodID:   payment.PaymentMethodID.String(),
+		ProcessedAt:       &now,
+	}
+	if !result.Succeeded() {
+		transaction.Status = models.TransactionStatusFailed
+		transaction.FailureReason = result.DeclineCode
+	}
+	return transaction
+}
+
+// transactionType returns the money movement of a kind of transaction. A
+// sale authorizes and captures at once, so it moves money as a capture.
+func transactionType(kind models.TransactionKind) models.TransactionType {
+	if kind == models.TransactionKindSale {
+		return models.TransactionTypeCapture
+	}
+	return models.TransactionType(kind)
+}
+
+// newPaymentOutcomeEvent describes a change of amount to a payment for its
+// order
+func newPaymentOutcomeEvent(payment *models.Payment, amount float64) *PaymentOutcomeEvent {
+	paymentID := payment.ID
+	return &PaymentOutcomeEvent{
+		PaymentID:  &paymentID,
+		OrderID:    payment.OrderID,
+		MerchantID: payment.MerchantID,
+		Amount:     amount,
+		Currency:   payment.Currency,
+	}
+}
+
+// newPaymentOutboxMessage builds a payment event. Events are keyed by order
+// ID, so the order sees its payments' changes in order.
+func (s *PaymentService) newPaymentOutboxMessage(ctx context.Context, eventType, topic string, outcome *PaymentOutcomeEvent) (*messaging.OutboxMessage, error) {
+	env, err := messaging.NewEnvelope(ctx, eventType, 1, outcome.MerchantID.String(), outcome)
+	if err != nil {
+		return nil, err
+	}
+	aggregateID := outcome.OrderID.String()
+	if outcome.PaymentID != nil {
+		aggregateID = outcome.PaymentID.String()
+	}
+	return messaging.NewEnvelopeOutboxMessage(s.schemas, "payment", aggregateID, topic, outcome.OrderID.String(), env)
+}
+
+// publishPaymentEvent enqueues a payment event using the given repository
+func (s *PaymentService) publishPaymentEvent(ctx context.Context, repo *repository.PaymentRepository, eventType, topic string, outcome *PaymentOutcomeEvent) error {
+	msg, err := s.newPaymentOutboxMessage(ctx, eventType, topic, outcome)
+	if err != nil {
+		return err
+	}
+	return repo.EnqueueOutbox(ctx, msg)
+}
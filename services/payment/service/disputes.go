@@ -0,0 +1,397 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/payment/gateway"
+	"unified-commerce/services/payment/models"
+	"unified-commerce/services/payment/repository"
+	"unified-commerce/shared/messaging"
+)
+
+// Disputes
+
+// DefaultEvidenceWindow is how long a merchant has to answer a dispute when
+// its gateway does not report the deadline
+const DefaultEvidenceWindow = 7 * 24 * time.Hour
+
+// errDisputeNotOpened reports a decision on a dispute the gateway has not
+// reported opening yet
+var errDisputeNotOpened = errors.New("dispute not opened")
+
+// disputeFee returns what a gateway charges the merchant for a dispute,
+// from its dispute_fee setting
+func disputeFee(paymentGateway *models.PaymentGateway) float64 {
+	if fee, ok := paymentGateway.Settings["dispute_fee"].(float64); ok && fee > 0 {
+		return fee
+	}
+	return 0
+}
+
+// PaymentDisputedEvent is the payload of the payment.disputed event, which
+// asks the order service for the evidence of a dispute of an order's
+// payment
+type PaymentDisputedEvent struct {
+	DisputeID     uuid.UUID  `json:"dispute_id"`
+	PaymentID     uuid.UUID  `json:"payment_id"`
+	OrderID       uuid.UUID  `json:"order_id"`
+	MerchantID    uuid.UUID  `json:"merchant_id"`
+	Amount        float64    `json:"amount"`
+	Currency      string     `json:"currency"`
+	Reason        string     `json:"reason"`
+	EvidenceDueBy *time.Time `json:"evidence_due_by"`
+}
+
+// openGatewayDispute opens a dispute the gateway reported against a
+// payment, withdrawing the disputed amount and the gateway's dispute fee
+// from the merchant's balance and asking the order service for evidence.
+// It reports whether the dispute is new.
+func (s *PaymentService) openGatewayDispute(ctx context.Context, repo *repository.PaymentRepository, paymentGateway *models.PaymentGateway, payment *models.Payment, event gateway.WebhookEvent, metadata map[string]interface{}) (bool, error) {
+	amount := event.Amount
+	if amount <= 0 || amount > payment.CapturedAmount {
+		amount = payment.CapturedAmount
+	}
+	dueBy := time.Now().Add(DefaultEvidenceWindow)
+	if !event.EvidenceDueBy.IsZero() {
+		dueBy = event.EvidenceDueBy.UTC()
+	}
+
+	dispute := &models.Dispute{
+		ID:               uuid.New(),
+		PaymentID:        payment.ID,
+		OrderID:          payment.OrderID,
+		MerchantID:       payment.MerchantID,
+		GatewayID:        paymentGateway.ID,
+		GatewayReference: event.DisputeID,
+		Reason:           models.ParseDisputeReason(event.Reason),
+		ReasonCode:       event.Reason,
+		Amount:           amount,
+		Currency:         payment.Currency,
+		Status:           models.DisputeStatusNeedsResponse,
+		EvidenceDueBy:    &dueBy,
+	}
+
+	adjustments := []*models.BalanceAdjustment{
+		newDisputeAdjustment(dispute, models.BalanceAdjustmentDisputeWithdrawal, -amount, "Disputed amount withdrawn"),
+	}
+	if fee := disputeFee(paymentGateway); fee > 0 {
+		adjustments = append(adjustments, newDisputeAdjustment(dispute, models.BalanceAdjustmentDisputeFee, -fee, "Dispute fee"))
+	}
+
+	env, err := messaging.NewEnvelope(ctx, messaging.EventTypePaymentDisputed, 1, dispute.MerchantID.String(), PaymentDisputedEvent{
+		DisputeID:     dispute.ID,
+		PaymentID:     dispute.PaymentID,
+		OrderID:       dispute.OrderID,
+		MerchantID:    dispute.MerchantID,
+		Amount:        dispute.Amount,
+		Currency:      dispute.Currency,
+		Reason:        string(dispute.Reason),
+		EvidenceDueBy: dispute.EvidenceDueBy,
+	})
+	if err != nil {
+		return false, err
+	}
+	msg, err := messaging.NewEnvelopeOutboxMessage(s.schemas, "dispute", dispute.ID.String(), "payments.disputed", dispute.OrderID.String(), env)
+	if err != nil {
+		return false, err
+	}
+
+	created, err := repo.OpenDispute(ctx, dispute, &repository.DisputeChange{
+		Adjustments: adjustments,
+		Event: &models.PaymentEvent{
+			PaymentID:   payment.ID,
+			EventType:   models.PaymentEventDisputed,
+			Description: fmt.Sprintf("Dispute of %.2f opened: %s, evidence due by %s", amount, dispute.Reason, dueBy.Format(time.RFC3339)),
+			Metadata:    metadata,
+		},
+		Outbox: []*messaging.OutboxMessage{msg},
+	})
+	if err != nil {
+		return false, err
+	}
+	if created {
+		s.logger.WithField("dispute_id", dispute.ID).WithField("payment_id", payment.ID).Info("Dispute opened")
+	}
+	return created, nil
+}
+
+// resolveGatewayDispute closes a dispute with the card issuer's decision
+// the gateway reported, returning the disputed amount to the merchant's
+// balance if the merchant won. It reports whether the dispute was open, and
+// returns errDisputeNotOpened for decisions that arrive before the dispute.
+func (s *PaymentService) resolveGatewayDispute(ctx context.Context, repo *repository.PaymentRepository, payment *models.Payment, event gateway.WebhookEvent, metadata map[string]interface{}) (bool, error) {
+	won := event.Type == gateway.EventDisputeWon
+	dispute, err := repo.GetDisputeByGatewayReference(ctx, payment.GatewayID, event.DisputeID)
+	if err != nil {
+		return false, err
+	}
+	if dispute == nil {
+		s.logger.WithField("event_id", event.ID).WithField("dispute_id", event.DisputeID).Warn("Gateway decided a dispute that is not opened")
+		return false, errDisputeNotOpened
+	}
+
+	applied := false
+	_, err = repo.UpdateDispute(ctx, dispute.ID, func(dispute *models.Dispute) (*repository.DisputeChange, error) {
+		if !dispute.Resolve(won, time.Now()) {
+			return nil, nil
+		}
+		applied = true
+
+		change := &repository.DisputeChange{
+			Event: &models.PaymentEvent{
+				PaymentID:   dispute.PaymentID,
+				EventType:   models.PaymentEventDisputed,
+				Description: fmt.Sprintf("Dispute of %.2f %s", dispute.Amount, dispute.Status),
+				Metadata:    metadata,
+			},
+		}
+		if won {
+			change.Adjustments = []*models.BalanceAdjustment{
+				newDisputeAdjustment(dispute, models.BalanceAdjustmentDisputeReversal, dispute.Amount, "Won dispute returned"),
+			}
+		}
+		return change, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if applied {
+		s.logger.WithField("dispute_id", dispute.ID).WithField("won", won).Info("Dispute resolved")
+	}
+	return applied, nil
+}
+
+// newDisputeAdjustment builds a balance adjustment a dispute makes
+func newDisputeAdjustment(dispute *models.Dispute, kind models.BalanceAdjustmentType, amount float64, description string) *models.BalanceAdjustment {
+	paymentID := dispute.PaymentID
+	return &models.BalanceAdjustment{
+		MerchantID:  dispute.MerchantID,
+		GatewayID:   dispute.GatewayID,
+		PaymentID:   &paymentID,
+		Type:        kind,
+		Amount:      amount,
+		Currency:    dispute.Currency,
+		Description: description,
+	}
+}
+
+// GetDispute retrieves a dispute by ID
+func (s *PaymentService) GetDispute(ctx context.Context, id uuid.UUID) (*models.Dispute, error) {
+	dispute, err := s.repo.GetDispute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if dispute == nil {
+		return nil, ErrDisputeNotFound
+	}
+	return dispute, nil
+}
+
+// GetDisputesByMerchant retrieves disputes of a merchant, those whose
+// evidence is due soonest first
+func (s *PaymentService) GetDisputesByMerchant(ctx context.Context, merchantID uuid.UUID, filters map[string]interface{}, page, limit int) ([]*models.Dispute, int64, error) {
+	offset := (page - 1) * limit
+	return s.repo.GetDisputesByMerchant(ctx, merchantID, filters, limit, offset)
+}
+
+// GetDisputesByPayment retrieves the disputes of a payment
+func (s *PaymentService) GetDisputesByPayment(ctx context.Context, paymentID uuid.UUID) ([]*models.Dispute, error) {
+	return s.repo.GetDisputesByPayment(ctx, paymentID)
+}
+
+// SubmitDisputeEvidenceRequest represents a merchant's answer to a dispute.
+// Evidence is added to the bundle assembled from the order as its merchant
+// section, such as an explanation or links to receipts and correspondence.
+type SubmitDisputeEvidenceRequest struct {
+	Evidence map[string]interface{} `json:"evidence"`
+}
+
+// SubmitDisputeEvidence submits a dispute's evidence bundle to its gateway,
+// putting the dispute under review by the card issuer. Evidence can be
+// submitted once, before it is due; evidence the gateway rejects returns
+// ErrDisputeEvidenceRejected and can be corrected and submitted again. The
+// gateway is called before the dispute is locked, so a slow gateway does
+// not hold up the dispute's webhooks; its answer is recorded afterwards.
+func (s *PaymentService) SubmitDisputeEvidence(ctx context.Context, id uuid.UUID, req *SubmitDisputeEvidenceRequest) (*models.Dispute, error) {
+	dispute, err := s.GetDispute(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := dispute.CanSubmitEvidence(time.Now()); err != nil {
+		return nil, err
+	}
+
+	evidence := make(map[string]interface{}, len(dispute.Evidence)+1)
+	for section, value := range dispute.Evidence {
+		evidence[section] = value
+	}
+	if len(req.Evidence) > 0 {
+		evidence["merchant"] = req.Evidence
+	}
+	if len(evidence) == 0 {
+		return nil, ErrNoDisputeEvidence
+	}
+
+	paymentGateway, err := s.repo.GetGateway(ctx, dispute.GatewayID)
+	if err != nil {
+		return nil, err
+	}
+	if paymentGateway == nil {
+		return nil, ErrGatewayNotFound
+	}
+	adapter, err := s.gateways.Open(paymentGateway)
+	if err != nil {
+		return nil, err
+	}
+	result, err := adapter.SubmitDisputeEvidence(ctx, &gateway.DisputeEvidenceRequest{
+		PaymentID: dispute.PaymentID,
+		DisputeID: dispute.ID,
+		Reference: dispute.GatewayReference,
+		Evidence:  evidence,
+	})
+	if err != nil {
+		s.logger.WithError(err).WithField("dispute_id", id).Error("Gateway failed to submit dispute evidence")
+		return nil, err
+	}
+
+	var rejected error
+	dispute, err = s.repo.UpdateDispute(ctx, id, func(dispute *models.Dispute) (*repository.DisputeChange, error) {
+		event := &models.PaymentEvent{
+			PaymentID: dispute.PaymentID,
+			EventType: models.PaymentEventDisputed,
+			Metadata:  gatewayResultMetadata(result),
+		}
+		if !result.Succeeded() {
+			rejected = fmt.Errorf("%w: %s", ErrDisputeEvidenceRejected, result.DeclineCode)
+			event.Description = fmt.Sprintf("Gateway rejected the evidence of a dispute: %s", result.DeclineCode)
+			return &repository.DisputeChange{Event: event}, nil
+		}
+
+		// Another submission, or the gateway closing the dispute, may have
+		// been recorded while the gateway was called. Evidence the gateway
+		// accepted past its due date is still recorded.
+		now := time.Now()
+		if dispute.Status != models.DisputeStatusNeedsResponse {
+			rejected = dispute.CanSubmitEvidence(now)
+			event.Description = fmt.Sprintf("Gateway accepted evidence for a dispute already %s", dispute.Status)
+			return &repository.DisputeChange{Event: event}, nil
+		}
+
+		dispute.Evidence = evidence
+		dispute.Status = models.DisputeStatusUnderReview
+		dispute.EvidenceSubmittedAt = &now
+		dispute.SubmissionReference = result.Reference
+		event.Description = fmt.Sprintf("Evidence submitted for a dispute of %.2f", dispute.Amount)
+		return &repository.DisputeChange{Event: event}, nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDisputeNotFound
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("dispute_id", id).Error("Failed to record dispute evidence")
+		return nil, err
+	}
+	if rejected != nil {
+		s.logger.WithField("dispute_id", id).WithError(rejected).Info("Dispute evidence rejected")
+		return dispute, rejected
+	}
+
+	s.logger.WithField("dispute_id", id).Info("Dispute evidence submitted")
+	return dispute, nil
+}
+
+// AcceptDispute concedes an open dispute to the customer, closing it as
+// lost. The disputed amount stays withdrawn. The gateway is not told; it
+// closes disputes left without evidence as lost once the evidence is due.
+func (s *PaymentService) AcceptDispute(ctx context.Context, id uuid.UUID) (*models.Dispute, error) {
+	dispute, err := s.repo.UpdateDispute(ctx, id, func(dispute *models.Dispute) (*repository.DisputeChange, error) {
+		if dispute.Status != models.DisputeStatusNeedsResponse {
+			return nil, ErrDisputeClosed
+		}
+		dispute.Resolve(false, time.Now())
+		return &repository.DisputeChange{
+			Event: &models.PaymentEvent{
+				PaymentID:   dispute.PaymentID,
+				EventType:   models.PaymentEventDisputed,
+				Description: fmt.Sprintf("Merchant accepted a dispute of %.2f", dispute.Amount),
+			},
+		}, nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDisputeNotFound
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("dispute_id", id).Error("Failed to accept dispute")
+		return nil, err
+	}
+
+	s.logger.WithField("dispute_id", id).Info("Dispute accepted")
+	return dispute, nil
+}
+
+// DisputeEvidence is the evidence of a dispute the order service assembled
+// from the disputed order, its customer and its fulfillments
+type DisputeEvidence struct {
+	DisputeID uuid.UUID
+	Evidence  map[string]interface{}
+}
+
+// AttachDisputeEvidence adds the evidence the order service assembled to a
+// dispute's bundle
+func (s *PaymentService) AttachDisputeEvidence(ctx context.Context, evidence *DisputeEvidence) error {
+	return s.attachDisputeEvidence(ctx, s.repo, evidence)
+}
+
+// AttachDisputeEvidenceInTx adds the evidence the order service assembled
+// to a dispute's bundle inside a transaction owned by the caller
+func (s *PaymentService) AttachDisputeEvidenceInTx(ctx context.Context, tx *gorm.DB, evidence *DisputeEvidence) error {
+	return s.attachDisputeEvidence(ctx, s.repo.WithTx(tx), evidence)
+}
+
+// attachDisputeEvidence adds assembled evidence to a dispute using the
+// given repository. Disputes whose evidence was already submitted, or that
+// are closed, keep the bundle they have; the merchant's own section is
+// never replaced.
+func (s *PaymentService) attachDisputeEvidence(ctx context.Context, repo *repository.PaymentRepository, evidence *DisputeEvidence) error {
+	_, err := repo.UpdateDispute(ctx, evidence.DisputeID, func(dispute *models.Dispute) (*repository.DisputeChange, error) {
+		if dispute.Status != models.DisputeStatusNeedsResponse {
+			return nil, nil
+		}
+
+		if dispute.Evidence == nil {
+			dispute.Evidence = make(map[string]interface{}, len(evidence.Evidence))
+		}
+		for section, value := range evidence.Evidence {
+			if section != "merchant" {
+				dispute.Evidence[section] = value
+			}
+		}
+		now := time.Now()
+		dispute.EvidenceAssembledAt = &now
+		return &repository.DisputeChange{}, nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrDisputeNotFound
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("dispute_id", evidence.DisputeID).Error("Failed to attach dispute evidence")
+		return err
+	}
+
+	s.logger.WithField("dispute_id", evidence.DisputeID).Info("Dispute evidence assembled")
+	return nil
+}
+
+// Balances
+
+// GetMerchantBalances retrieves a merchant's balance in each currency it
+// was paid in
+func (s *PaymentService) GetMerchantBalances(ctx context.Context, merchantID uuid.UUID) ([]*models.MerchantBalance, error) {
+	return s.repo.GetMerchantBalances(ctx, merchantID)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/payment/models"
+	"unified-commerce/services/payment/repository"
+	"unified-commerce/shared/messaging"
+)
+
+// Draft Order Payments
+
+// PayDraftOrderRequest asks for a customer to be charged for a draft
+// order's invoice. The payment is made for the order the draft becomes.
+type PayDraftOrderRequest struct {
+	DraftOrderID  uuid.UUID
+	OrderID       uuid.UUID
+	MerchantID    uuid.UUID
+	CustomerID    *uuid.UUID
+	Email         string
+	Amount        float64
+	Currency      string
+	PaymentMethod CreatePaymentMethodRequest
+}
+
+// PaymentOutcomeEvent is the payload of the payment.authorized,
+// payment.captured, payment.voided and payment.failed events, which tell
+// the order service what happened to an order's payment. Amount is what
+// the change authorized, captured or released.
+type PaymentOutcomeEvent struct {
+	PaymentID  *uuid.UUID `json:"payment_id,omitempty"`
+	OrderID    uuid.UUID  `json:"order_id"`
+	MerchantID uuid.UUID  `json:"merchant_id"`
+	Amount     float64    `json:"amount"`
+	Currency   string     `json:"currency"`
+	Reason     string     `json:"reason,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // when an authorization lapses
+}
+
+// PayDraftOrder charges for a draft order in one transaction
+func (s *PaymentService) PayDraftOrder(ctx context.Context, req *PayDraftOrderRequest) (*models.Payment, error) {
+	tx := s.repo.BeginTx(ctx)
+	if tx == nil {
+		return nil, fmt.Errorf("failed to begin transaction")
+	}
+	defer s.repo.RollbackTx(tx)
+
+	payment, err := s.payDraftOrder(ctx, s.repo.WithTx(tx), req)
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, s.repo.CommitTx(tx)
+}
+
+// PayDraftOrderInTx charges for a draft order inside a transaction owned by
+// the caller
+func (s *PaymentService) PayDraftOrderInTx(ctx context.Context, tx *gorm.DB, req *PayDraftOrderRequest) (*models.Payment, error) {
+	return s.payDraftOrder(ctx, s.repo.WithTx(tx), req)
+}
+
+// payDraftOrder stores the customer's payment method and creates and
+// processes the payment, which publishes whether it was captured or
+// declined. A payment that no gateway can take is reported as failed here.
+// Neither is returned as an error, so the customer can try again. Gateway
+// timeouts are returned so the payment is retried.
+func (s *PaymentService) payDraftOrder(ctx context.Context, repo *repository.PaymentRepository, req *PayDraftOrderRequest) (*models.Payment, error) {
+	method := req.PaymentMethod
+	paymentMethod := &models.PaymentMethod{
+		CustomerID:  req.CustomerID,
+		MerchantID:  &req.MerchantID,
+		Type:        method.Type,
+		Provider:    method.Provider,
+		Token:       method.Token,
+		Last4:       method.Last4,
+		ExpiryMonth: method.ExpiryMonth,
+		ExpiryYear:  method.ExpiryYear,
+		Brand:       method.Brand,
+		Email:       req.Email,
+	}
+	if err := repo.CreatePaymentMethod(ctx, paymentMethod); err != nil {
+		s.logger.WithError(err).Error("Failed to create payment method")
+		return nil, err
+	}
+
+	outcome := PaymentOutcomeEvent{
+		OrderID:    req.OrderID,
+		MerchantID: req.MerchantID,
+		Amount:     req.Amount,
+		Currency:   req.Currency,
+	}
+
+	payment, err := s.createPayment(ctx, repo, &CreatePaymentRequest{
+		OrderID:         req.OrderID,
+		MerchantID:      req.MerchantID,
+		CustomerID:      req.CustomerID,
+		PaymentMethodID: paymentMethod.ID,
+		Amount:          req.Amount,
+		Currency:        req.Currency,
+		Description:     fmt.Sprintf("Draft order %s", req.DraftOrderID),
+	})
+	if err == nil {
+		outcome.PaymentID = &payment.ID
+		err = s.processPayment(ctx, repo, payment.ID)
+	}
+
+	switch {
+	case err == nil, errors.Is(err, ErrPaymentFailed):
+		// Processing published the outcome
+		s.logger.WithField("draft_order_id", req.DraftOrderID).WithField("payment_id", payment.ID).Info("Draft order payment processed")
+		return payment, nil
+	case errors.Is(err, ErrGatewayNotFound), errors.Is(err, ErrUnsupportedGateway), errors.Is(err, ErrPaymentActionRequired):
+		// Invoices are paid without the customer at hand, so a required
+		// authentication fails the payment too
+		outcome.Reason = err.Error()
+	default:
+		return nil, err
+	}
+
+	if err := s.publishPaymentEvent(ctx, repo, messaging.EventTypePaymentFailed, "payments.failed", &outcome); err != nil {
+		return nil, err
+	}
+
+	s.logger.WithField("draft_order_id", req.DraftOrderID).WithField("outcome", messaging.EventTypePaymentFailed).Info("Draft order payment processed")
+	return payment, nil
+}
@@ -7,10 +7,13 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 
+	"unified-commerce/services/payment/gateway"
 	"unified-commerce/services/payment/models"
 	"unified-commerce/services/payment/repository"
 	"unified-commerce/services/shared/logger"
+	"unified-commerce/shared/messaging"
 )
 
 // Service errors
@@ -24,19 +27,44 @@ var (
 	ErrPaymentAlreadyProcessed = errors.New("payment already processed")
 	ErrRefundAmountExceeds     = errors.New("refund amount exceeds payment amount")
 	ErrInvalidRefundStatus     = errors.New("invalid refund status")
+	ErrPaymentFailed           = errors.New("payment processing failed")
+	ErrPaymentActionRequired   = errors.New("payment requires customer authentication")
+	ErrRefundFailed            = errors.New("refund processing failed")
+	ErrCaptureFailed           = errors.New("capture processing failed")
+	ErrVoidFailed              = errors.New("void processing failed")
+	ErrNothingToCapture        = models.ErrNothingToCapture
+	ErrAuthorizationExpired    = models.ErrAuthorizationExpired
+	ErrInvalidCaptureAmount    = models.ErrInvalidCaptureAmount
+	ErrUnsupportedGateway      = gateway.ErrUnknownProvider
+	ErrGatewayTimeout          = gateway.ErrTimeout
+	ErrInvalidCard             = gateway.ErrInvalidCard
+	ErrWebhookSignature        = gateway.ErrInvalidSignature
+	ErrInvalidWebhook          = gateway.ErrInvalidPayload
+	ErrStaleWebhook            = errors.New("webhook signature is outside the accepted time window")
+	ErrDisputeNotFound         = errors.New("dispute not found")
+	ErrNoDisputeEvidence       = errors.New("dispute has no evidence to submit")
+	ErrDisputeEvidenceRejected = errors.New("dispute evidence was rejected")
+	ErrDisputeClosed           = models.ErrDisputeClosed
+	ErrEvidenceSubmitted       = models.ErrEvidenceAlreadySubmitted
+	ErrEvidenceDeadlinePassed  = models.ErrEvidenceDeadlinePassed
 )
 
 // PaymentService handles business logic for payment management
 type PaymentService struct {
-	repo   *repository.PaymentRepository
-	logger *logger.Logger
+	repo     *repository.PaymentRepository
+	logger   *logger.Logger
+	schemas  *messaging.SchemaRegistry
+	gateways *gateway.Registry
 }
 
-// NewPaymentService creates a new payment service
-func NewPaymentService(repo *repository.PaymentRepository, logger *logger.Logger) *PaymentService {
+// NewPaymentService creates a new payment service. Payments are made
+// through the adapters the registry opens for their gateways.
+func NewPaymentService(repo *repository.PaymentRepository, logger *logger.Logger, schemas *messaging.SchemaRegistry, gateways *gateway.Registry) *PaymentService {
 	return &PaymentService{
-		repo:   repo,
-		logger: logger,
+		repo:     repo,
+		logger:   logger,
+		schemas:  schemas,
+		gateways: gateways,
 	}
 }
 
@@ -45,18 +73,26 @@ func NewPaymentService(repo *repository.PaymentRepository, logger *logger.Logger
 // CreatePaymentRequest represents a request to create a payment
 type CreatePaymentRequest struct {
 	OrderID         uuid.UUID  `json:"order_id" validate:"required"`
-	MerchantID      uuid.UUID  `json:"merchant_id" validate:"required"`
+	MerchantID      uuid.UUID  `json:"-" validate:"required"`
 	CustomerID      *uuid.UUID `json:"customer_id"`
 	PaymentMethodID uuid.UUID  `json:"payment_method_id" validate:"required"`
 	Amount          float64    `json:"amount" validate:"required,min=0"`
 	Currency        string     `json:"currency"`
 	Description     string     `json:"description"`
+	// CaptureMethod is automatic unless the payment is only authorized,
+	// to be captured later
+	CaptureMethod models.CaptureMethod `json:"capture_method" validate:"omitempty,oneof=automatic manual"`
 }
 
 // CreatePayment creates a new payment
 func (s *PaymentService) CreatePayment(ctx context.Context, req *CreatePaymentRequest) (*models.Payment, error) {
+	return s.createPayment(ctx, s.repo, req)
+}
+
+// createPayment creates a payment using the given repository
+func (s *PaymentService) createPayment(ctx context.Context, repo *repository.PaymentRepository, req *CreatePaymentRequest) (*models.Payment, error) {
 	// Validate payment method exists
-	paymentMethod, err := s.repo.GetPaymentMethod(ctx, req.PaymentMethodID)
+	paymentMethod, err := repo.GetPaymentMethod(ctx, req.PaymentMethodID)
 	if err != nil {
 		return nil, err
 	}
@@ -64,27 +100,32 @@ func (s *PaymentService) CreatePayment(ctx context.Context, req *CreatePaymentRe
 		return nil, ErrPaymentMethodNotFound
 	}
 
-	// Get gateway for payment method
-	gateway, err := s.repo.GetGateway(ctx, paymentMethod.ID)
-	if err != nil {
-		return nil, err
+	// Pay through a gateway of the payment method's provider
+	var paymentGateway *models.PaymentGateway
+	if paymentMethod.Provider != "" {
+		if paymentGateway, err = repo.GetGatewayByProvider(ctx, paymentMethod.Provider); err != nil {
+			return nil, err
+		}
 	}
-	if gateway == nil {
-		// Use default gateway if payment method gateway not found
-		gateways, err := s.repo.GetAllGateways(ctx)
+	if paymentGateway == nil {
+		// Use default gateway if the provider has no gateway
+		gateways, err := repo.GetAllGateways(ctx)
 		if err != nil {
 			return nil, err
 		}
 		if len(gateways) == 0 {
 			return nil, ErrGatewayNotFound
 		}
-		gateway = gateways[0]
+		paymentGateway = gateways[0]
 	}
 
-	// Set default currency
+	// Set default currency and capture method
 	if req.Currency == "" {
 		req.Currency = "USD"
 	}
+	if req.CaptureMethod == "" {
+		req.CaptureMethod = models.CaptureMethodAutomatic
+	}
 
 	// Create payment
 	payment := &models.Payment{
@@ -92,15 +133,16 @@ func (s *PaymentService) CreatePayment(ctx context.Context, req *CreatePaymentRe
 		MerchantID:      req.MerchantID,
 		CustomerID:      req.CustomerID,
 		PaymentMethodID: req.PaymentMethodID,
-		GatewayID:       gateway.ID,
+		GatewayID:       paymentGateway.ID,
 		Status:          models.PaymentStatusPending,
 		Amount:          req.Amount,
 		Currency:        req.Currency,
+		CaptureMethod:   req.CaptureMethod,
 		Description:     req.Description,
 		NetAmount:       req.Amount, // Will be updated after processing
 	}
 
-	if err := s.repo.CreatePayment(ctx, payment); err != nil {
+	if err := repo.CreatePayment(ctx, payment); err != nil {
 		s.logger.WithError(err).Error("Failed to create payment")
 		return nil, err
 	}
@@ -111,7 +153,7 @@ func (s *PaymentService) CreatePayment(ctx context.Context, req *CreatePaymentRe
 		EventType:   models.PaymentEventCreated,
 		Description: "Payment created",
 	}
-	if err := s.repo.CreatePaymentEvent(ctx, event); err != nil {
+	if err := repo.CreatePaymentEvent(ctx, event); err != nil {
 		s.logger.WithError(err).Error("Failed to create payment event")
 	}
 
@@ -151,22 +193,37 @@ func (s *PaymentService) GetPaymentsByMerchant(ctx context.Context, merchantID u
 
 // ProcessPayment processes a payment through the gateway
 func (s *PaymentService) ProcessPayment(ctx context.Context, paymentID uuid.UUID) error {
-	payment, err := s.GetPayment(ctx, paymentID)
+	return s.processPayment(ctx, s.repo, paymentID)
+}
+
+// processPayment authorizes a payment through its gateway using the given
+// repository, capturing it in the same step unless it is captured manually,
+// and publishes the outcome to the payment's order. A payment the gateway
+// declines is marked failed and returns ErrPaymentFailed. A payment that
+// needs the customer to authenticate, or whose gateway timed out, stays
+// pending and returns ErrPaymentActionRequired or ErrGatewayTimeout.
+func (s *PaymentService) processPayment(ctx context.Context, repo *repository.PaymentRepository, paymentID uuid.UUID) error {
+	payment, err := repo.GetPayment(ctx, paymentID)
 	if err != nil {
 		return err
 	}
+	if payment == nil {
+		return ErrPaymentNotFound
+	}
 
 	// Check if payment is already processed
 	if payment.Status != models.PaymentStatusPending {
 		return ErrPaymentAlreadyProcessed
 	}
 
-	// In a real implementation, this would integrate with the actual payment gateway
-	// For now, we'll simulate the process
+	adapter, err := s.gateways.Open(&payment.Gateway)
+	if err != nil {
+		return err
+	}
 
 	// Update payment status to processing
 	now := time.Now()
-	if err := s.repo.UpdatePaymentStatus(ctx, paymentID, models.PaymentStatusPending, &now); err != nil {
+	if err := repo.UpdatePaymentStatus(ctx, paymentID, models.PaymentStatusPending, &now); err != nil {
 		s.logger.WithError(err).Error("Failed to update payment status to processing")
 		return err
 	}
@@ -177,53 +234,120 @@ func (s *PaymentService) ProcessPayment(ctx context.Context, paymentID uuid.UUID
 		EventType:   models.PaymentEventProcessing,
 		Description: "Payment processing started",
 	}
-	if err := s.repo.CreatePaymentEvent(ctx, event); err != nil {
+	if err := repo.CreatePaymentEvent(ctx, event); err != nil {
 		s.logger.WithError(err).Error("Failed to create payment event")
 	}
 
-	// Simulate gateway processing delay
-	time.Sleep(2 * time.Second)
+	// Authorize the amount, capturing it in the same step as a sale unless
+	// it is captured manually
+	capture := payment.CaptureMethod != models.CaptureMethodManual
+	result, err := adapter.Authorize(ctx, &gateway.AuthorizeRequest{
+		PaymentID:   payment.ID,
+		Amount:      payment.Amount,
+		Currency:    payment.Currency,
+		Token:       payment.PaymentMethod.Token,
+		Capture:     capture,
+		Description: payment.Description,
+	})
+	if err != nil {
+		s.logger.WithError(err).WithField("payment_id", paymentID).Error("Gateway failed to process payment")
+		return err
+	}
+	payment.GatewayReference = result.Reference
 
-	// Simulate successful payment processing
-	// In a real implementation, this would depend on the gateway response
-	success := true // This would come from the gateway response
+	kind := models.TransactionKindSale
+	if !capture {
+		kind = models.TransactionKindAuthorization
+	}
 
-	if success {
-		// Update payment status to captured
-		if err := s.repo.UpdatePaymentStatus(ctx, paymentID, models.PaymentStatusCaptured, &now); err != nil {
-			s.logger.WithError(err).Error("Failed to update payment status to captured")
+	switch result.Status {
+	case gateway.StatusSucceeded:
+		// Record the authorization or sale on the payment's ledger, which
+		// moves the payment on
+		transaction := newTransaction(payment, kind, payment.Amount, result)
+		if err := repo.CreateTransaction(ctx, transaction); err != nil {
 			return err
 		}
-
-		// Update completed timestamp
-		payment.CompletedAt = &now
+		payment.ApplyTransaction(transaction)
+		payment.AuthorizationID = result.Reference
 		payment.ProcessedAt = &now
-		if err := s.repo.UpdatePayment(ctx, payment); err != nil {
+
+		event := &models.PaymentEvent{
+			PaymentID: payment.ID,
+			Metadata:  gatewayResultMetadata(result),
+		}
+		outcome := newPaymentOutcomeEvent(payment, payment.Amount)
+		eventType, topic := messaging.EventTypePaymentCaptured, "payments.captured"
+		if capture {
+			payment.TransactionFee = result.Fee
+			payment.NetAmount = payment.Amount - result.Fee
+			payment.CompletedAt = &now
+			event.EventType = models.PaymentEventCaptured
+			event.Description = "Payment captured successfully"
+		} else {
+			expiresAt := now.Add(authorizationTTL(&payment.Gateway))
+			payment.AuthorizationExpiresAt = &expiresAt
+			outcome.ExpiresAt = &expiresAt
+			eventType, topic = messaging.EventTypePaymentAuthorized, "payments.authorized"
+			event.EventType = models.PaymentEventAuthorized
+			event.Description = fmt.Sprintf("Payment authorized until %s", expiresAt.Format(time.RFC3339))
+		}
+
+		if err := repo.UpdatePayment(ctx, payment); err != nil {
 			s.logger.WithError(err).Error("Failed to update payment completion time")
 			return err
 		}
+		if err := repo.CreatePaymentEvent(ctx, event); err != nil {
+			s.logger.WithError(err).Error("Failed to create payment event")
+		}
+		if err := s.publishPaymentEvent(ctx, repo, eventType, topic, outcome); err != nil {
+			return err
+		}
+
+		s.logger.WithField("payment_id", paymentID).WithField("status", payment.Status).Info("Payment processed successfully")
+		return nil
+
+	case gateway.StatusActionRequired:
+		// The payment stays pending until the customer authenticates at
+		// the action URL
+		if payment.Metadata == nil {
+			payment.Metadata = make(map[string]interface{})
+		}
+		payment.Metadata["action_url"] = result.ActionURL
+		if err := repo.UpdatePayment(ctx, payment); err != nil {
+			s.logger.WithError(err).Error("Failed to update payment gateway reference")
+			return err
+		}
 
-		// Create payment event
 		event := &models.PaymentEvent{
 			PaymentID:   payment.ID,
-			EventType:   models.PaymentEventCaptured,
-			Description: "Payment captured successfully",
+			EventType:   models.PaymentEventActionRequired,
+			Description: "Payment requires customer authentication",
+			Metadata:    gatewayResultMetadata(result),
 		}
-		if err := s.repo.CreatePaymentEvent(ctx, event); err != nil {
+		if err := repo.CreatePaymentEvent(ctx, event); err != nil {
 			s.logger.WithError(err).Error("Failed to create payment event")
 		}
 
-		s.logger.WithField("payment_id", paymentID).Info("Payment processed successfully")
-	} else {
+		s.logger.WithField("payment_id", paymentID).Info("Payment requires customer authentication")
+		return ErrPaymentActionRequired
+
+	default:
+		// Record the declined attempt on the payment's ledger
+		if err := repo.CreateTransaction(ctx, newTransaction(payment, kind, payment.Amount, result)); err != nil {
+			return err
+		}
+
 		// Update payment status to failed
-		if err := s.repo.UpdatePaymentStatus(ctx, paymentID, models.PaymentStatusFailed, &now); err != nil {
+		if err := repo.UpdatePaymentStatus(ctx, paymentID, models.PaymentStatusFailed, &now); err != nil {
 			s.logger.WithError(err).Error("Failed to update payment status to failed")
 			return err
 		}
 
-		// Update failed timestamp
+		// Update failed timestamp, keeping the status just set
+		payment.Status = models.PaymentStatusFailed
 		payment.FailedAt = &now
-		if err := s.repo.UpdatePayment(ctx, payment); err != nil {
+		if err := repo.UpdatePayment(ctx, payment); err != nil {
 			s.logger.WithError(err).Error("Failed to update payment failure time")
 			return err
 		}
@@ -232,17 +356,41 @@ func (s *PaymentService) ProcessPayment(ctx context.Context, paymentID uuid.UUID
 		event := &models.PaymentEvent{
 			PaymentID:   payment.ID,
 			EventType:   models.PaymentEventFailed,
-			Description: "Payment processing failed",
+			Description: fmt.Sprintf("Payment declined: %s", result.DeclineCode),
+			Metadata:    gatewayResultMetadata(result),
 		}
-		if err := s.repo.CreatePaymentEvent(ctx, event); err != nil {
+		if err := repo.CreatePaymentEvent(ctx, event); err != nil {
 			s.logger.WithError(err).Error("Failed to create payment event")
 		}
 
-		s.logger.WithField("payment_id", paymentID).Info("Payment processing failed")
-		return errors.New("payment processing failed")
+		declined := fmt.Errorf("%w: %s", ErrPaymentFailed, result.DeclineCode)
+		outcome := newPaymentOutcomeEvent(payment, payment.Amount)
+		outcome.Reason = declined.Error()
+		if err := s.publishPaymentEvent(ctx, repo, messaging.EventTypePaymentFailed, "payments.failed", outcome); err != nil {
+			return err
+		}
+
+		s.logger.WithField("payment_id", paymentID).WithField("decline_code", result.DeclineCode).Info("Payment processing failed")
+		return declined
 	}
+}
 
-	return nil
+// gatewayResultMetadata records a gateway's answer on a payment event
+func gatewayResultMetadata(result *gateway.Result) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"gateway_status":    string(result.Status),
+		"gateway_reference": result.Reference,
+	}
+	if result.DeclineCode != "" {
+		metadata["decline_code"] = result.DeclineCode
+	}
+	if result.Message != "" {
+		metadata["message"] = result.Message
+	}
+	if result.ActionURL != "" {
+		metadata["action_url"] = result.ActionURL
+	}
+	return metadata
 }
 
 // CancelPayment cancels a pending payment
@@ -253,7 +401,7 @@ func (s *PaymentService) CancelPayment(ctx context.Context, paymentID uuid.UUID)
 	}
 
 	// Check if payment can be cancelled
-	if payment.Status != models.PaymentStatusPending && payment.Status != models.PaymentStatusPending {
+	if payment.Status != models.PaymentStatusPending {
 		return ErrInvalidPaymentStatus
 	}
 
@@ -289,7 +437,7 @@ func (s *PaymentService) CancelPayment(ctx context.Context, paymentID uuid.UUID)
 // CreatePaymentMethodRequest represents a request to create a payment method
 type CreatePaymentMethodRequest struct {
 	CustomerID  *uuid.UUID               `json:"customer_id"`
-	MerchantID  *uuid.UUID               `json:"merchant_id"`
+	MerchantID  *uuid.UUID               `json:"-"`
 	Type        models.PaymentMethodType `json:"type" validate:"required"`
 	Provider    string                   `json:"provider"`
 	Token       string                   `json:"token"`
@@ -301,10 +449,19 @@ type CreatePaymentMethodRequest struct {
 	Email       string                   `json:"email"`
 	IsDefault   bool                     `json:"is_default"`
 	Metadata    map[string]interface{}   `json:"metadata"`
+	// Card is tokenized by a gateway of Provider in place of Token. Its
+	// details are not stored.
+	Card *gateway.Card `json:"card,omitempty"`
 }
 
 // CreatePaymentMethod creates a new payment method
 func (s *PaymentService) CreatePaymentMethod(ctx context.Context, req *CreatePaymentMethodRequest) (*models.PaymentMethod, error) {
+	if req.Card != nil {
+		if err := s.tokenizeCard(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+
 	paymentMethod := &models.PaymentMethod{
 		CustomerID:  req.CustomerID,
 		MerchantID:  req.MerchantID,
@@ -330,6 +487,34 @@ func (s *PaymentService) CreatePaymentMethod(ctx context.Context, req *CreatePay
 	return paymentMethod, nil
 }
 
+// tokenizeCard replaces a payment method request's card with the token a
+// gateway of its provider issues for it
+func (s *PaymentService) tokenizeCard(ctx context.Context, req *CreatePaymentMethodRequest) error {
+	paymentGateway, err := s.repo.GetGatewayByProvider(ctx, req.Provider)
+	if err != nil {
+		return err
+	}
+	if paymentGateway == nil {
+		return ErrGatewayNotFound
+	}
+	adapter, err := s.gateways.Open(paymentGateway)
+	if err != nil {
+		return err
+	}
+
+	token, err := adapter.Tokenize(ctx, req.Card)
+	if err != nil {
+		return err
+	}
+	req.Token = token.Token
+	req.Last4 = token.Last4
+	req.Brand = token.Brand
+	req.ExpiryMonth = token.ExpiryMonth
+	req.ExpiryYear = token.ExpiryYear
+	req.Card = nil
+	return nil
+}
+
 // GetPaymentMethod retrieves a payment method by ID
 func (s *PaymentService) GetPaymentMethod(ctx context.Context, id uuid.UUID) (*models.PaymentMethod, error) {
 	paymentMethod, err := s.repo.GetPaymentMethod(ctx, id)
@@ -498,11 +683,55 @@ type CreateRefundRequest struct {
 
 // CreateRefund creates a new refund
 func (s *PaymentService) CreateRefund(ctx context.Context, req *CreateRefundRequest) (*models.Refund, error) {
+	return s.createRefund(ctx, s.repo, req)
+}
+
+// RefundOrderRequest represents a request to refund part of an order's
+// payment
+type RefundOrderRequest struct {
+	OrderID uuid.UUID
+	Amount  float64
+	Reason  models.RefundReason
+}
+
+// RefundOrder refunds an amount of the payment of an order
+func (s *PaymentService) RefundOrder(ctx context.Context, req *RefundOrderRequest) (*models.Refund, error) {
+	return s.refundOrder(ctx, s.repo, req)
+}
+
+// RefundOrderInTx refunds an amount of the payment of an order inside a
+// transaction owned by the caller
+func (s *PaymentService) RefundOrderInTx(ctx context.Context, tx *gorm.DB, req *RefundOrderRequest) (*models.Refund, error) {
+	return s.refundOrder(ctx, s.repo.WithTx(tx), req)
+}
+
+// refundOrder refunds an order's payment using the given repository
+func (s *PaymentService) refundOrder(ctx context.Context, repo *repository.PaymentRepository, req *RefundOrderRequest) (*models.Refund, error) {
+	payment, err := repo.GetPaymentByOrderID(ctx, req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		return nil, ErrPaymentNotFound
+	}
+
+	return s.createRefund(ctx, repo, &CreateRefundRequest{
+		PaymentID: payment.ID,
+		Amount:    req.Amount,
+		Reason:    req.Reason,
+	})
+}
+
+// createRefund creates a refund using the given repository
+func (s *PaymentService) createRefund(ctx context.Context, repo *repository.PaymentRepository, req *CreateRefundRequest) (*models.Refund, error) {
 	// Get payment
-	payment, err := s.GetPayment(ctx, req.PaymentID)
+	payment, err := repo.GetPayment(ctx, req.PaymentID)
 	if err != nil {
 		return nil, err
 	}
+	if payment == nil {
+		return nil, ErrPaymentNotFound
+	}
 
 	// Check if payment is captured
 	if payment.Status != models.PaymentStatusCaptured {
@@ -514,13 +743,13 @@ func (s *PaymentService) CreateRefund(ctx context.Context, req *CreateRefundRequ
 		return nil, errors.New("refund amount must be greater than 0")
 	}
 
-	// Check if refund amount exceeds payment amount
-	if req.Amount > payment.Amount {
+	// Check if refund amount exceeds the captured amount
+	if req.Amount > payment.CapturedAmount {
 		return nil, ErrRefundAmountExceeds
 	}
 
 	// Calculate total refunded amount
-	refunds, err := s.repo.GetRefundsByPayment(ctx, req.PaymentID)
+	refunds, err := repo.GetRefundsByPayment(ctx, req.PaymentID)
 	if err != nil {
 		return nil, err
 	}
@@ -532,8 +761,14 @@ func (s *PaymentService) CreateRefund(ctx context.Context, req *CreateRefundRequ
 		}
 	}
 
-	// Check if total refunds exceed payment amount
-	if totalRefunded+req.Amount > payment.Amount {
+	// Amounts held or taken by disputes cannot be refunded as well
+	disputed, err := repo.GetDisputedAmount(ctx, req.PaymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if total refunds exceed the captured amount
+	if totalRefunded+disputed+req.Amount > payment.CapturedAmount {
 		return nil, ErrRefundAmountExceeds
 	}
 
@@ -547,7 +782,7 @@ func (s *PaymentService) CreateRefund(ctx context.Context, req *CreateRefundRequ
 		Status:    models.RefundStatusPending,
 	}
 
-	if err := s.repo.CreateRefund(ctx, refund); err != nil {
+	if err := repo.CreateRefund(ctx, refund); err != nil {
 		s.logger.WithError(err).Error("Failed to create refund")
 		return nil, err
 	}
@@ -558,7 +793,7 @@ func (s *PaymentService) CreateRefund(ctx context.Context, req *CreateRefundRequ
 		EventType:   models.PaymentEventRefunded,
 		Description: fmt.Sprintf("Refund initiated for amount: %.2f", req.Amount),
 	}
-	if err := s.repo.CreatePaymentEvent(ctx, event); err != nil {
+	if err := repo.CreatePaymentEvent(ctx, event); err != nil {
 		s.logger.WithError(err).Error("Failed to create payment event")
 	}
 
@@ -583,7 +818,9 @@ func (s *PaymentService) GetRefundsByPayment(ctx context.Context, paymentID uuid
 	return s.repo.GetRefundsByPayment(ctx, paymentID)
 }
 
-// ProcessRefund processes a refund through the gateway
+// ProcessRefund returns a refund's amount through its payment's gateway. A
+// refund the gateway declines is marked failed and returns ErrRefundFailed;
+// one whose gateway timed out stays pending and returns ErrGatewayTimeout.
 func (s *PaymentService) ProcessRefund(ctx context.Context, refundID uuid.UUID) error {
 	refund, err := s.GetRefund(ctx, refundID)
 	if err != nil {
@@ -595,26 +832,31 @@ func (s *PaymentService) ProcessRefund(ctx context.Context, refundID uuid.UUID)
 		return ErrInvalidRefundStatus
 	}
 
-	// In a real implementation, this would integrate with the actual payment gateway
-	// For now, we'll simulate the process
-
-	// Update refund status to processing
-	now := time.Now()
-	refund.Status = models.RefundStatusProcessed
-	refund.ProcessedAt = &now
-	if err := s.repo.UpdateRefund(ctx, refund); err != nil {
-		s.logger.WithError(err).Error("Failed to update refund status to processed")
+	payment, err := s.GetPayment(ctx, refund.PaymentID)
+	if err != nil {
+		return err
+	}
+	adapter, err := s.gateways.Open(&payment.Gateway)
+	if err != nil {
 		return err
 	}
 
-	// Simulate gateway processing delay
-	time.Sleep(2 * time.Second)
-
-	// Simulate successful refund processing
-	// In a real implementation, this would depend on the gateway response
-	success := true // This would come from the gateway response
+	result, err := adapter.Refund(ctx, &gateway.RefundRequest{
+		PaymentID: payment.ID,
+		RefundID:  refund.ID,
+		Reference: payment.GatewayReference,
+		Amount:    refund.Amount,
+		Currency:  refund.Currency,
+	})
+	if err != nil {
+		s.logger.WithError(err).WithField("refund_id", refundID).Error("Gateway failed to process refund")
+		return err
+	}
 
-	if success {
+	now := time.Now()
+	refund.GatewayReference = result.Reference
+	refund.ProcessedAt = &now
+	if result.Succeeded() {
 		// Update refund status to completed
 		refund.Status = models.RefundStatusCompleted
 		refund.CompletedAt = &now
@@ -624,20 +866,19 @@ func (s *PaymentService) ProcessRefund(ctx context.Context, refundID uuid.UUID)
 		}
 
 		s.logger.WithField("refund_id", refundID).Info("Refund processed successfully")
-	} else {
-		// Update refund status to failed
-		refund.Status = models.RefundStatusFailed
-		refund.FailedAt = &now
-		if err := s.repo.UpdateRefund(ctx, refund); err != nil {
-			s.logger.WithError(err).Error("Failed to update refund status to failed")
-			return err
-		}
+		return nil
+	}
 
-		s.logger.WithField("refund_id", refundID).Info("Refund processing failed")
-		return errors.New("refund processing failed")
+	// Update refund status to failed
+	refund.Status = models.RefundStatusFailed
+	refund.FailedAt = &now
+	if err := s.repo.UpdateRefund(ctx, refund); err != nil {
+		s.logger.WithError(err).Error("Failed to update refund status to failed")
+		return err
 	}
 
-	return nil
+	s.logger.WithField("refund_id", refundID).WithField("decline_code", result.DeclineCode).Info("Refund processing failed")
+	return fmt.Errorf("%w: %s", ErrRefundFailed, result.DeclineCode)
 }
 
 // Event Operations
@@ -657,7 +898,9 @@ type CreateSettlementRequest struct {
 	Currency  string    `json:"currency"`
 }
 
-// CreateSettlement creates a new settlement
+// CreateSettlement creates a new settlement. Balance adjustments made while
+// the gateway had no pending settlement, such as disputed amounts
+// withdrawn, are netted into its amount.
 func (s *PaymentService) CreateSettlement(ctx context.Context, req *CreateSettlementRequest) (*models.Settlement, error) {
 	// Set default currency
 	if req.Currency == "" {
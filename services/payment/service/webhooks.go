@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/payment/gateway"
+	"unified-commerce/services/payment/models"
+	"unified-commerce/services/payment/repository"
+	"unified-commerce/shared/messaging"
+)
+
+// Gateway Webhooks
+
+// DefaultWebhookTolerance is how far a webhook's signing time can be from
+// now before the webhook is refused as a replay, when its gateway's
+// settings do not say
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// webhookTolerance returns how old webhooks of a gateway can be, from its
+// webhook_tolerance_seconds setting
+func webhookTolerance(paymentGateway *models.PaymentGateway) time.Duration {
+	if seconds, ok := paymentGateway.Settings["webhook_tolerance_seconds"].(float64); ok && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	return DefaultWebhookTolerance
+}
+
+// ReceiveGatewayWebhook authenticates a webhook a gateway sent with the
+// gateway's credentials and applies the events it reports to the payments
+// they are about. Webhooks signed outside the gateway's tolerance are
+// refused with ErrStaleWebhook; events already recorded, because the
+// gateway retried them or the webhook was replayed, are skipped unless they
+// matched no payment. Accepted webhooks are kept as received for audit.
+func (s *PaymentService) ReceiveGatewayWebhook(ctx context.Context, gatewayID uuid.UUID, header http.Header, body []byte) (*models.WebhookDelivery, error) {
+	paymentGateway, err := s.repo.GetGateway(ctx, gatewayID)
+	if err != nil {
+		return nil, err
+	}
+	if paymentGateway == nil {
+		return nil, ErrGatewayNotFound
+	}
+	adapter, err := s.gateways.Open(paymentGateway)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := adapter.ParseWebhook(header, body)
+	if err != nil {
+		s.logger.WithError(err).WithField("gateway_id", gatewayID).Warn("Rejected gateway webhook")
+		return nil, err
+	}
+	now := time.Now()
+	tolerance := webhookTolerance(paymentGateway)
+	for _, event := range events {
+		if age := now.Sub(event.SignedAt); age > tolerance || age < -tolerance {
+			s.logger.WithField("gateway_id", gatewayID).WithField("event_id", event.ID).Warn("Rejected stale gateway webhook")
+			return nil, ErrStaleWebhook
+		}
+	}
+
+	delivery := &models.WebhookDelivery{
+		GatewayID: paymentGateway.ID,
+		Status:    models.WebhookDeliveryReceived,
+		Headers:   webhookHeaders(header),
+		Payload:   string(body),
+		Events:    len(events),
+	}
+	if err := s.repo.CreateWebhookDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	var applyErr error
+	for _, event := range events {
+		duplicate, err := s.applyGatewayEvent(ctx, paymentGateway, delivery, event)
+		if err != nil {
+			applyErr = fmt.Errorf("failed to apply gateway event %s: %w", event.ID, err)
+			break
+		}
+		if duplicate {
+			delivery.Duplicates++
+		} else {
+			delivery.Applied++
+		}
+	}
+
+	processedAt := time.Now()
+	delivery.ProcessedAt = &processedAt
+	delivery.Status = models.WebhookDeliveryProcessed
+	if applyErr != nil {
+		// Events applied so far stay recorded, so the gateway's retry
+		// skips them
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.Error = applyErr.Error()
+	}
+	if err := s.repo.UpdateWebhookDelivery(ctx, delivery); err != nil && applyErr == nil {
+		return nil, err
+	}
+	if applyErr != nil {
+		s.logger.WithError(applyErr).WithField("delivery_id", delivery.ID).Error("Failed to process gateway webhook")
+		return nil, applyErr
+	}
+
+	s.logger.WithField("gateway_id", gatewayID).WithField("applied", delivery.Applied).WithField("duplicates", delivery.Duplicates).Info("Gateway webhook processed")
+	return delivery, nil
+}
+
+// applyGatewayEvent records a gateway event and applies it to the payment
+// it is about, reporting whether it was recorded before. Events about
+// operations no payment has, and decisions on disputes not opened yet, are
+// recorded as unmatched, and applied again if they are sent again once the
+// payment or dispute is recorded.
+func (s *PaymentService) applyGatewayEvent(ctx context.Context, paymentGateway *models.PaymentGateway, delivery *models.WebhookDelivery, event gateway.WebhookEvent) (bool, error) {
+	record := &models.GatewayEvent{
+		GatewayID:  paymentGateway.ID,
+		EventID:    event.ID,
+		DeliveryID: delivery.ID,
+		Type:       string(event.Type),
+		Reference:  event.Reference,
+		Outcome:    models.GatewayEventUnmatched,
+	}
+	if !event.OccurredAt.IsZero() {
+		occurredAt := event.OccurredAt.UTC()
+		record.OccurredAt = &occurredAt
+	}
+
+	return s.repo.RecordGatewayEvent(ctx, record, func(repo *repository.PaymentRepository) error {
+		payment, err := repo.GetPaymentByGatewayReference(ctx, paymentGateway.ID, event.Reference)
+		if err != nil {
+			return err
+		}
+		if payment == nil {
+			s.logger.WithField("event_id", event.ID).WithField("reference", event.Reference).Warn("Gateway event matches no payment")
+			return nil
+		}
+		record.PaymentID = &payment.ID
+
+		metadata := map[string]interface{}{
+			"gateway_event_id":    event.ID,
+			"webhook_delivery_id": delivery.ID.String(),
+			"reference":           event.Reference,
+			"amount":              event.Amount,
+		}
+		if event.Reason != "" {
+			metadata["reason"] = event.Reason
+		}
+		if event.DisputeID != "" {
+			metadata["dispute_id"] = event.DisputeID
+		}
+
+		var applied bool
+		switch event.Type {
+		case gateway.EventCaptured:
+			applied, err = s.applyGatewayCapture(ctx, repo, payment.ID, event, metadata)
+		case gateway.EventFailed:
+			applied, err = s.applyGatewayFailure(ctx, repo, payment.ID, event, metadata)
+		case gateway.EventRefunded:
+			applied, err = s.applyGatewayRefund(ctx, repo, payment, event, metadata)
+		case gateway.EventDisputed:
+			applied, err = s.openGatewayDispute(ctx, repo, paymentGateway, payment, event, metadata)
+		case gateway.EventDisputeWon, gateway.EventDisputeLost:
+			applied, err = s.resolveGatewayDispute(ctx, repo, payment, event, metadata)
+			if errors.Is(err, errDisputeNotOpened) {
+				return nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		record.Outcome = models.GatewayEventRecorded
+		if applied {
+			record.Outcome = models.GatewayEventApplied
+		}
+		return nil
+	})
+}
+
+// applyGatewayCapture records a capture the gateway reported. A pending
+// payment, such as one authenticated after a challenge, is captured as a
+// sale and an authorized one up to what is left of its authorization;
+// captures of payments with nothing left to capture are only recorded.
+func (s *PaymentService) applyGatewayCapture(ctx context.Context, repo *repository.PaymentRepository, paymentID uuid.UUID, event gateway.WebhookEvent, metadata map[string]interface{}) (bool, error) {
+	applied := false
+	_, err := repo.UpdatePaymentLedger(ctx, paymentID, func(payment *models.Payment) (*repository.LedgerChange, error) {
+		paymentEvent := &models.PaymentEvent{
+			PaymentID: payment.ID,
+			EventType: models.PaymentEventCaptured,
+			Metadata:  metadata,
+		}
+
+		kind, amount := models.TransactionKindCapture, event.Amount
+		switch capturable := payment.CapturableAmount(); {
+		case payment.Status == models.PaymentStatusPending:
+			kind = models.TransactionKindSale
+			if amount <= 0 {
+				amount = payment.Amount
+			}
+		case capturable > 0:
+			if amount <= 0 || amount > capturable {
+				amount = capturable
+			}
+		default:
+			paymentEvent.Description = fmt.Sprintf("Gateway confirmed a capture of %.2f", event.Amount)
+			return &repository.LedgerChange{Event: paymentEvent}, nil
+		}
+
+		now := time.Now()
+		transaction := newTransaction(payment, kind, amount, &gateway.Result{Status: gateway.StatusSucceeded, Reference: event.Reference})
+		transaction.Description = "Reported by gateway webhook"
+		payment.ApplyTransaction(transaction)
+		payment.NetAmount = payment.CapturedAmount - payment.TransactionFee
+		if payment.ProcessedAt == nil {
+			payment.ProcessedAt = &now
+		}
+		payment.CompletedAt = &now
+		paymentEvent.Description = fmt.Sprintf("Gateway captured %.2f", amount)
+
+		msg, err := s.newPaymentOutboxMessage(ctx, messaging.EventTypePaymentCaptured, "payments.captured", newPaymentOutcomeEvent(payment, amount))
+		if err != nil {
+			return nil, err
+		}
+		applied = true
+		return &repository.LedgerChange{
+			Transaction: transaction,
+			Event:       paymentEvent,
+			Outbox:      []*messaging.OutboxMessage{msg},
+		}, nil
+	})
+	return applied, err
+}
+
+// applyGatewayFailure records a failure the gateway reported. Pending
+// payments fail; failures of payments that already moved on are only
+// recorded.
+func (s *PaymentService) applyGatewayFailure(ctx context.Context, repo *repository.PaymentRepository, paymentID uuid.UUID, event gateway.WebhookEvent, metadata map[string]interface{}) (bool, error) {
+	applied := false
+	_, err := repo.UpdatePaymentLedger(ctx, paymentID, func(payment *models.Payment) (*repository.LedgerChange, error) {
+		paymentEvent := &models.PaymentEvent{
+			PaymentID: payment.ID,
+			EventType: models.PaymentEventFailed,
+			Metadata:  metadata,
+		}
+		if payment.Status != models.PaymentStatusPending {
+			paymentEvent.Description = fmt.Sprintf("Gateway reported a failure of a %s payment: %s", payment.Status, event.Reason)
+			return &repository.LedgerChange{Event: paymentEvent}, nil
+		}
+
+		kind := models.TransactionKindSale
+		if payment.CaptureMethod == models.CaptureMethodManual {
+			kind = models.TransactionKindAuthorization
+		}
+		now := time.Now()
+		transaction := newTransaction(payment, kind, payment.Amount, &gateway.Result{
+			Status:      gateway.StatusDeclined,
+			Reference:   event.Reference,
+			DeclineCode: event.Reason,
+		})
+		transaction.Description = "Reported by gateway webhook"
+		payment.Status = models.PaymentStatusFailed
+		payment.FailedAt = &now
+		paymentEvent.Description = fmt.Sprintf("Gateway failed the payment: %s", event.Reason)
+
+		outcome := newPaymentOutcomeEvent(payment, payment.Amount)
+		outcome.Reason = fmt.Errorf("%w: %s", ErrPaymentFailed, event.Reason).Error()
+		msg, err := s.newPaymentOutboxMessage(ctx, messaging.EventTypePaymentFailed, "payments.failed", outcome)
+		if err != nil {
+			return nil, err
+		}
+		applied = true
+		return &repository.LedgerChange{
+			Transaction: transaction,
+			Event:       paymentEvent,
+			Outbox:      []*messaging.OutboxMessage{msg},
+		}, nil
+	})
+	return applied, err
+}
+
+// applyGatewayRefund records a refund the gateway reported. A refund made
+// through the service is completed; one made at the gateway, such as from
+// its dashboard, is added to the payment. Either way the payment is marked
+// refunded or partially refunded.
+func (s *PaymentService) applyGatewayRefund(ctx context.Context, repo *repository.PaymentRepository, payment *models.Payment, event gateway.WebhookEvent, metadata map[string]interface{}) (bool, error) {
+	refund, err := repo.GetRefundByGatewayReference(ctx, payment.ID, event.Reference)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	paymentEvent := &models.PaymentEvent{
+		PaymentID: payment.ID,
+		EventType: models.PaymentEventRefunded,
+		Metadata:  metadata,
+	}
+	applied := true
+	switch {
+	case refund != nil && refund.Status == models.RefundStatusCompleted:
+		paymentEvent.Description = fmt.Sprintf("Gateway confirmed a refund of %.2f", refund.Amount)
+		applied = false
+	case refund != nil:
+		refund.Status = models.RefundStatusCompleted
+		refund.CompletedAt = &now
+		if err := repo.CompleteRefund(ctx, refund); err != nil {
+			return false, err
+		}
+		paymentEvent.Description = fmt.Sprintf("Gateway completed a refund of %.2f", refund.Amount)
+	case event.Amount > 0:
+		refund = &models.Refund{
+			PaymentID:        payment.ID,
+			OrderID:          payment.OrderID,
+			Amount:           event.Amount,
+			Currency:         payment.Currency,
+			Reason:           models.RefundReasonOther,
+			Status:           models.RefundStatusCompleted,
+			GatewayReference: event.Reference,
+			ProcessedAt:      &now,
+			CompletedAt:      &now,
+		}
+		if err := repo.CreateRefund(ctx, refund); err != nil {
+			return false, err
+		}
+		paymentEvent.Description = fmt.Sprintf("Gateway refunded %.2f", event.Amount)
+	default:
+		paymentEvent.Description = "Gateway reported a refund without an amount"
+		applied = false
+	}
+
+	if err := repo.CreatePaymentEvent(ctx, paymentEvent); err != nil {
+		return false, err
+	}
+	return applied, nil
+}
+
+// webhookHeaders keeps a webhook's headers for audit, without credentials
+// the sender may have added
+func webhookHeaders(header http.Header) map[string]interface{} {
+	headers := make(map[string]interface{}, len(header))
+	for name, values := range header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Authorization", "Cookie", "Proxy-Authorization":
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
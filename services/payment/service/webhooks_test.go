@@ -0,0 +1,184 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"unified-commerce/services/payment/gateway"
+	"unified-commerce/services/payment/migrations"
+	"unified-commerce/services/payment/models"
+	"unified-commerce/services/payment/repository"
+	"unified-commerce/services/payment/service"
+	"unified-commerce/services/shared/database/dbtest"
+	"unified-commerce/services/shared/logger"
+	"unified-commerce/services/shared/tenant"
+	"unified-commerce/shared/messaging"
+)
+
+const webhookSecret = "whsec_test"
+
+// newTestService returns a payment service over a test database and the
+// simulator gateway whose webhooks it accepts
+func newTestService(t *testing.T) (*service.PaymentService, *repository.PaymentRepository, *models.PaymentGateway) {
+	t.Helper()
+	log := logger.NewLogger(logger.Config{Level: "error"})
+	repo := repository.NewPaymentRepository(dbtest.Open(t, "payment", migrations.All()), log)
+	schemas, err := messaging.DefaultSchemaRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paymentGateway := &models.PaymentGateway{
+		ID:          uuid.New(),
+		Name:        "simulator-" + uuid.NewString(),
+		Provider:    gateway.SimulatorProvider,
+		Credentials: map[string]interface{}{"webhook_secret": webhookSecret},
+	}
+	if err := repo.CreateGateway(tenant.WithoutScope(context.Background()), paymentGateway); err != nil {
+		t.Fatal(err)
+	}
+	return service.NewPaymentService(repo, log, schemas, gateway.DefaultRegistry()), repo, paymentGateway
+}
+
+// createPendingPayment stores a pending payment the gateway knows by
+// reference
+func createPendingPayment(t *testing.T, repo *repository.PaymentRepository, gatewayID uuid.UUID, reference string) *models.Payment {
+	t.Helper()
+	merchantID := uuid.New()
+	ctx := tenant.WithMerchantID(context.Background(), merchantID.String())
+
+	method := &models.PaymentMethod{ID: uuid.New(), MerchantID: &merchantID, Type: models.PaymentMethodTypeCreditCard}
+	if err := repo.CreatePaymentMethod(ctx, method); err != nil {
+		t.Fatal(err)
+	}
+	payment := &models.Payment{
+		ID:               uuid.New(),
+		OrderID:          uuid.New(),
+		MerchantID:       merchantID,
+		PaymentMethodID:  method.ID,
+		GatewayID:        gatewayID,
+		Status:           models.PaymentStatusPending,
+		Amount:           50,
+		Currency:         "USD",
+		GatewayReference: reference,
+	}
+	if err := repo.CreatePayment(ctx, payment); err != nil {
+		t.Fatal(err)
+	}
+	return payment
+}
+
+// sendFailure delivers a simulator webhook failing the payment with the
+// reference, signed at signedAt
+func sendFailure(t *testing.T, svc *service.PaymentService, gatewayID uuid.UUID, eventID, reference string, signedAt time.Time) (*models.WebhookDelivery, error) {
+	t.Helper()
+	return sendEvent(t, svc, gatewayID, map[string]interface{}{
+		"id":        eventID,
+		"type":      gateway.EventFailed,
+		"reference": reference,
+		"reason":    "insufficient_funds",
+	}, signedAt)
+}
+
+// sendEvent delivers a simulator webhook with the event, signed at signedAt
+func sendEvent(t *testing.T, svc *service.PaymentService, gatewayID uuid.UUID, event map[string]interface{}, signedAt time.Time) (*models.WebhookDelivery, error) {
+	t.Helper()
+	body, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Set(gateway.SimulatorSignatureHeader, gateway.NewSimulator(webhookSecret).SignAt(body, signedAt))
+	return svc.ReceiveGatewayWebhook(tenant.WithoutScope(context.Background()), gatewayID, header, body)
+}
+
+// paymentStatus reloads the status of a payment
+func paymentStatus(t *testing.T, repo *repository.PaymentRepository, id uuid.UUID) models.PaymentStatus {
+	t.Helper()
+	payment, err := repo.GetPayment(tenant.WithoutScope(context.Background()), id)
+	if err != nil || payment == nil {
+		t.Fatalf("reload payment: payment = %v, err = %v", payment, err)
+	}
+	return payment.Status
+}
+
+func TestReceiveGatewayWebhook_RejectsStaleSignature(t *testing.T) {
+	svc, repo, paymentGateway := newTestService(t)
+	payment := createPendingPayment(t, repo, paymentGateway.ID, "ref-1")
+
+	signedAt := time.Now().Add(-service.DefaultWebhookTolerance - time.Minute)
+	if _, err := sendFailure(t, svc, paymentGateway.ID, "evt-1", "ref-1", signedAt); !errors.Is(err, service.ErrStaleWebhook) {
+		t.Fatalf("err = %v, want ErrStaleWebhook", err)
+	}
+	if status := paymentStatus(t, repo, payment.ID); status != models.PaymentStatusPending {
+		t.Fatalf("status = %s, want the stale webhook ignored", status)
+	}
+}
+
+func TestReceiveGatewayWebhook_SkipsReplayedEvents(t *testing.T) {
+	svc, repo, paymentGateway := newTestService(t)
+	payment := createPendingPayment(t, repo, paymentGateway.ID, "ref-1")
+
+	delivery, err := sendFailure(t, svc, paymentGateway.ID, "evt-1", "ref-1", time.Now())
+	if err != nil || delivery.Applied != 1 {
+		t.Fatalf("first delivery: %+v, err = %v", delivery, err)
+	}
+
+	// Signed again, so only the event ID gives the replay away
+	delivery, err = sendFailure(t, svc, paymentGateway.ID, "evt-1", "ref-1", time.Now())
+	if err != nil || delivery.Applied != 0 || delivery.Duplicates != 1 {
+		t.Fatalf("replayed delivery: %+v, err = %v; want one duplicate", delivery, err)
+	}
+	transactions, err := repo.GetTransactionsByPayment(tenant.WithoutScope(context.Background()), payment.ID)
+	if err != nil || len(transactions) != 1 {
+		t.Fatalf("transactions = %d, err = %v; want the failure recorded once", len(transactions), err)
+	}
+}
+
+func TestReceiveGatewayWebhook_ReappliesUnmatchedEvents(t *testing.T) {
+	svc, repo, paymentGateway := newTestService(t)
+
+	// The gateway reports the payment before it is recorded
+	if _, err := sendFailure(t, svc, paymentGateway.ID, "evt-1", "ref-1", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	payment := createPendingPayment(t, repo, paymentGateway.ID, "ref-1")
+
+	delivery, err := sendFailure(t, svc, paymentGateway.ID, "evt-1", "ref-1", time.Now())
+	if err != nil || delivery.Duplicates != 0 {
+		t.Fatalf("retried delivery: %+v, err = %v; want the event applied", delivery, err)
+	}
+	if status := paymentStatus(t, repo, payment.ID); status != models.PaymentStatusFailed {
+		t.Fatalf("status = %s, want failed", status)
+	}
+}
+
+func TestReceiveGatewayWebhook_ReappliesDecisionsOnUnopenedDisputes(t *testing.T) {
+	svc, repo, paymentGateway := newTestService(t)
+	createPendingPayment(t, repo, paymentGateway.ID, "ref-1")
+	won := map[string]interface{}{"id": "evt-2", "type": gateway.EventDisputeWon, "reference": "ref-1", "dispute_id": "dp-1"}
+
+	// The gateway reports the decision before the dispute
+	if _, err := sendEvent(t, svc, paymentGateway.ID, won, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	opened := map[string]interface{}{"id": "evt-1", "type": gateway.EventDisputed, "reference": "ref-1", "dispute_id": "dp-1"}
+	if _, err := sendEvent(t, svc, paymentGateway.ID, opened, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	delivery, err := sendEvent(t, svc, paymentGateway.ID, won, time.Now())
+	if err != nil || delivery.Applied != 1 {
+		t.Fatalf("retried delivery: %+v, err = %v; want the decision applied", delivery, err)
+	}
+	dispute, err := repo.GetDisputeByGatewayReference(tenant.WithoutScope(context.Background()), paymentGateway.ID, "dp-1")
+	if err != nil || dispute == nil || dispute.Status != models.DisputeStatusWon {
+		t.Fatalf("dispute = %+v, err = %v; want won", dispute, err)
+	}
+}
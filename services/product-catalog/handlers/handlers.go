@@ -47,7 +47,7 @@ func (h *ProductHandler) RegisterRoutes(router *gin.Engine) {
 
 	// Authentication required for merchant routes
 	authConfig := middleware.DefaultAuthConfig()
-	v1.Use(middleware.JWTAuth(authConfig))
+	v1.Use(middleware.JWTAuth(authConfig), middleware.Tenant())
 
 	// Product management routes
 	products := v1.Group("/products")
@@ -112,14 +112,11 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	// Get merchant ID from authenticated user context
-	// This would typically come from the user's merchant association
-	merchantID := c.GetHeader("X-Merchant-ID")
-	if merchantID == "" {
-		httputil.BadRequest(c, "Merchant ID is required")
+	merchantID, ok := middleware.TenantMerchantID(c)
+	if !ok {
 		return
 	}
-	req.MerchantID = merchantID
+	req.MerchantID = merchantID.String()
 
 	if err := h.validator.Struct(&req); err != nil {
 		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
@@ -419,12 +416,11 @@ func (h *ProductHandler) CreateCategory(c *gin.Context) {
 		return
 	}
 
-	merchantID := c.GetHeader("X-Merchant-ID")
-	if merchantID == "" {
-		httputil.BadRequest(c, "Merchant ID is required")
+	merchantID, ok := middleware.TenantMerchantID(c)
+	if !ok {
 		return
 	}
-	req.MerchantID = merchantID
+	req.MerchantID = merchantID.String()
 
 	if err := h.validator.Struct(&req); err != nil {
 		httputil.ValidationError(c, map[string]interface{}{"validation": err.Error()})
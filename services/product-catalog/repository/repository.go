@@ -12,6 +12,7 @@ import (
 
 	"unified-commerce/services/product-catalog/models"
 	"unified-commerce/services/shared/database"
+	"unified-commerce/services/shared/tenant"
 )
 
 // ProductRepository handles product data operations
@@ -42,15 +43,20 @@ func (r *ProductRepository) Create(ctx context.Context, product *models.Product)
 	return nil
 }
 
-// GetByID retrieves a product by ID
+// GetByID retrieves a product of the context's merchant by ID
 func (r *ProductRepository) GetByID(ctx context.Context, id string) (*models.Product, error) {
 	objectID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid product ID: %w", err)
 	}
 
+	filter, err := tenant.Filter(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return nil, err
+	}
+
 	var product models.Product
-	err = r.collection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&product)
+	err = r.collection.FindOne(ctx, filter).Decode(&product)
 	if err != nil {
 		return nil, err
 	}
@@ -94,10 +100,13 @@ func (r *ProductRepository) GetBySlug(ctx context.Context, merchantID, slug stri
 func (r *ProductRepository) Update(ctx context.Context, product *models.Product) error {
 	product.UpdatedAt = time.Now()
 
-	filter := bson.M{"_id": product.ID}
+	filter, err := tenant.Filter(ctx, bson.M{"_id": product.ID})
+	if err != nil {
+		return err
+	}
 	update := bson.M{"$set": product}
 
-	_, err := r.collection.UpdateOne(ctx, filter, update)
+	_, err = r.collection.UpdateOne(ctx, filter, update)
 	return err
 }
 
@@ -108,7 +117,10 @@ func (r *ProductRepository) Delete(ctx context.Context, id string) error {
 		return fmt.Errorf("invalid product ID: %w", err)
 	}
 
-	filter := bson.M{"_id": objectID}
+	filter, err := tenant.Filter(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return err
+	}
 	update := bson.M{
 		"$set": bson.M{
 			"status":     "archived",
@@ -218,7 +230,10 @@ func (r *ProductRepository) UpdateStatus(ctx context.Context, id, status string)
 		return fmt.Errorf("invalid product ID: %w", err)
 	}
 
-	filter := bson.M{"_id": objectID}
+	filter, err := tenant.Filter(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return err
+	}
 	update := bson.M{
 		"$set": bson.M{
 			"status":     status,
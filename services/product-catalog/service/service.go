@@ -39,7 +39,7 @@ func NewProductService(repo *repository.Repository, logger *logger.Logger) *Prod
 
 // CreateProductRequest represents a product creation request
 type CreateProductRequest struct {
-	MerchantID      string                  `json:"merchant_id" validate:"required"`
+	MerchantID      string                  `json:"-" validate:"required"`
 	Name            string                  `json:"name" validate:"required"`
 	Description     string                  `json:"description"`
 	SKU             string                  `json:"sku"`
@@ -477,7 +477,7 @@ func (s *ProductService) ensureUniqueSlug(ctx context.Context, merchantID, slug,
 
 // CreateCategoryRequest represents a category creation request
 type CreateCategoryRequest struct {
-	MerchantID  string               `json:"merchant_id" validate:"required"`
+	MerchantID  string               `json:"-" validate:"required"`
 	Name        string               `json:"name" validate:"required"`
 	Description string               `json:"description"`
 	ParentID    string               `json:"parent_id"`
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
+	"os"
+
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 
 	"unified-commerce/services/promotions/graphql"
 	"unified-commerce/services/promotions/handlers"
-	"unified-commerce/services/promotions/models"
+	"unified-commerce/services/promotions/migrations"
 	"unified-commerce/services/promotions/repository"
 	promotionsService "unified-commerce/services/promotions/service"
+	"unified-commerce/services/shared/database"
 	sharedService "unified-commerce/services/shared/service"
 )
 
@@ -24,8 +27,16 @@ func main() {
 		panic("Failed to create base service: " + err.Error())
 	}
 
+	// Run the migrate subcommand instead of the server when requested
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := database.RunMigrateCommand(context.Background(), baseService.PostgresDB.DB, baseService.Name, migrations.All(), os.Args[2:], os.Stdout); err != nil {
+			baseService.Logger.WithError(err).Fatal("Migrate command failed")
+		}
+		return
+	}
+
 	// Run database migrations
-	if err := runMigrations(baseService.PostgresDB.DB); err != nil {
+	if _, err := baseService.PostgresDB.Migrate(context.Background(), baseService.Name, migrations.All()); err != nil {
 		baseService.Logger.WithError(err).Fatal("Failed to run database migrations")
 	}
 
@@ -52,7 +63,7 @@ func setupRoutes(router *gin.Engine, baseService *sharedService.BaseService) {
 	promotionsHandler := handlers.NewPromotionsHandler(promotionsServiceInstance, baseService.Logger)
 
 	// Register routes
-	promotionsHandler.RegisterRoutes(router)
+	promotionsHandler.RegisterRoutes(router, baseService.RateLimiter)
 
 	// Add GraphQL endpoints
 	graphqlHandler := graphql.NewGraphQLHandler(promotionsServiceInstance, baseService.Logger)
@@ -65,19 +76,3 @@ func setupRoutes(router *gin.Engine, baseService *sharedService.BaseService) {
 		router.GET("/graphql/playground", gin.WrapH(playgroundHandler))
 	}
 }
-
-// runMigrations runs database migrations
-func runMigrations(db *gorm.DB) error {
-	// Add all promotions models here for migration
-	return db.AutoMigrate(
-		&models.Promotion{},
-		&models.DiscountCode{},
-		&models.CodeUsage{},
-		&models.GiftCard{},
-		&models.GiftCardTransaction{},
-		&models.LoyaltyProgram{},
-		&models.LoyaltyMember{},
-		&models.LoyaltyTier{},
-		&models.LoyaltyActivity{},
-	)
-}
@@ -28,13 +28,13 @@ func NewPromotionsHandler(service *service.PromotionsService, logger *logger.Log
 }
 
 // RegisterRoutes registers all promotions routes
-func (h *PromotionsHandler) RegisterRoutes(router *gin.Engine) {
+func (h *PromotionsHandler) RegisterRoutes(router *gin.Engine, rateLimiter *middleware.RateLimiter) {
 	v1 := router.Group("/api/v1")
 	{
 		// Protected routes (authentication required)
 		authConfig := middleware.DefaultAuthConfig()
 		protected := v1.Group("/")
-		protected.Use(middleware.JWTAuth(authConfig))
+		protected.Use(middleware.JWTAuth(authConfig), middleware.RateLimit(rateLimiter, middleware.MerchantRateLimitConfig()))
 		{
 			// Promotion management
 			promotions := protected.Group("/promotions")
@@ -103,6 +103,7 @@ func (h *PromotionsHandler) RegisterRoutes(router *gin.Engine) {
 
 		// Public routes for discount code validation
 		public := v1.Group("/public")
+		public.Use(middleware.RateLimit(rateLimiter, middleware.PublicRateLimitConfig()))
 		{
 			public.POST("/discount-codes/validate", h.ValidateDiscountCode)
 		}
@@ -37,8 +37,9 @@ type Config struct {
 	RedisDB       int
 
 	// JWT configuration
-	JWTSecret     string
-	JWTExpiration int
+	JWTSigningKeysDir string // PEM private keys named <kid>.pem
+	JWTSigningKeyID   string // kid of the key used to sign new tokens
+	JWTExpiration     int
 
 	// Observability
 	JaegerEndpoint string
@@ -50,6 +51,20 @@ type Config struct {
 	// External services
 	ElasticsearchURL string
 	KafkaBrokers     []string
+	MessagingDriver  string
+
+	// Tax
+	TaxRulesFile string // JSON tax rules; no tax is charged when empty
+
+	// Draft orders
+	InvoiceURL string // base of the payment links sent with draft order invoices
+
+	// Shipment tracking
+	TrackingTokenSecret   string            // signs order tracking links; tracking is by email only when empty
+	CarrierWebhookSecrets map[string]string // carrier code to the secret its webhooks are authenticated with
+
+	// HTTP
+	TrustedProxies []string // CIDRs or IPs whose X-Forwarded-For is trusted; none when empty
 
 	// Development flags
 	DebugMode bool
@@ -103,8 +118,9 @@ func LoadConfig(serviceName string) (*Config, error) {
 		RedisDB:       getEnvAsInt("REDIS_DB", 0),
 
 		// JWT
-		JWTSecret:     getEnv("JWT_SECRET", "unified-commerce-jwt-secret-key"),
-		JWTExpiration: getEnvAsInt("JWT_EXPIRATION", 3600), // 1 hour
+		JWTSigningKeysDir: getEnv("JWT_SIGNING_KEYS_DIR", ""),
+		JWTSigningKeyID:   getEnv("JWT_SIGNING_KEY_ID", ""), // defaults to the last kid in sort order
+		JWTExpiration:     getEnvAsInt("JWT_EXPIRATION", 3600), // 1 hour
 
 		// Observability
 		JaegerEndpoint: getEnv("JAEGER_ENDPOINT", "http://localhost:14268/api/traces"),
@@ -116,6 +132,20 @@ func LoadConfig(serviceName string) (*Config, error) {
 		// External services
 		ElasticsearchURL: getEnv("ELASTICSEARCH_URL", "http://localhost:9200"),
 		KafkaBrokers:     []string{getEnv("KAFKA_BROKERS", "localhost:9092")},
+		MessagingDriver:  getEnv("MESSAGING_DRIVER", "kafka"), // "memory" runs events in-process
+
+		// Tax
+		TaxRulesFile: getEnv("TAX_RULES_FILE", ""),
+
+		// Draft orders
+		InvoiceURL: getEnv("INVOICE_URL", "http://localhost:3000/invoices"),
+
+		// Shipment tracking
+		TrackingTokenSecret:   getEnv("TRACKING_TOKEN_SECRET", ""),
+		CarrierWebhookSecrets: getEnvAsMap("CARRIER_WEBHOOK_SECRETS"), // ups=...,fedex=...
+
+		// HTTP
+		TrustedProxies: getEnvAsList("TRUSTED_PROXIES"), // 10.0.0.0/8,192.168.1.1
 
 		// Development
 		DebugMode: getEnvAsBool("DEBUG_MODE", true),
@@ -257,6 +287,33 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getEnvAsMap parses comma-separated key=value pairs, such as
+// "ups=secret,fedex=secret"
+func getEnvAsMap(key string) map[string]string {
+	values := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if name = strings.TrimSpace(name); name != "" {
+			values[name] = strings.TrimSpace(value)
+		}
+	}
+	return values
+}
+
+// getEnvAsList parses comma-separated values, dropping empty ones
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // min returns the minimum of two integers
 func min(a, b int) int {
 	if a < b {
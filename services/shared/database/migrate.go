@@ -0,0 +1,410 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Migration errors
+var (
+	ErrMigrationVersion   = errors.New("invalid migration version")
+	ErrUnknownMigration   = errors.New("database has a migration this binary does not know")
+	ErrIrreversible       = errors.New("migration cannot be reverted")
+	ErrInvalidMigrateArgs = errors.New("usage: migrate status | up | down [steps] | to <version>")
+)
+
+// schemaMigrationsTable records the migrations applied to a database. Several
+// services may share a database, so rows are keyed by service.
+const schemaMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	service    TEXT        NOT NULL,
+	version    BIGINT      NOT NULL,
+	name       TEXT        NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (service, version)
+)`
+
+// Migration is a versioned schema change. Up and Down run in a transaction
+// together with the schema_migrations update, so a failed migration leaves
+// no trace. A nil Down makes the migration irreversible.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// ExecSQL returns a migration step that executes the statements in order
+func ExecSQL(statements ...string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// AutoMigrateModels returns a migration step that creates or extends the
+// models' tables with AutoMigrate. It only adds tables and columns and is a
+// no-op when they exist, which makes it suitable for baselines. Give it
+// models frozen for the migration, never the service's own: those change
+// with the code, and a released migration must not. Other changes need
+// ExecSQL.
+func AutoMigrateModels(models ...interface{}) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.AutoMigrate(models...)
+	}
+}
+
+// DropModels returns a migration step that drops the models' tables in
+// reverse order, so dependent tables listed after their parents go first
+func DropModels(models ...interface{}) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for i := len(models) - 1; i >= 0; i-- {
+			if err := tx.Migrator().DropTable(models[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	Version   int64
+	Name      string
+	AppliedAt time.Time
+}
+
+// Migrator applies a service's migrations. Every operation holds a Postgres
+// advisory lock for the service, so replicas starting at the same time apply
+// each migration once.
+type Migrator struct {
+	db         *gorm.DB
+	service    string
+	migrations []Migration
+}
+
+// NewMigrator creates a migrator for the service's migrations. Versions must
+// be positive and unique; they are applied in ascending order.
+func NewMigrator(db *gorm.DB, service string, migrations []Migration) (*Migrator, error) {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, migration := range sorted {
+		if migration.Version <= 0 {
+			return nil, fmt.Errorf("%w: %d (%s)", ErrMigrationVersion, migration.Version, migration.Name)
+		}
+		if i > 0 && sorted[i-1].Version == migration.Version {
+			return nil, fmt.Errorf("%w: duplicate version %d", ErrMigrationVersion, migration.Version)
+		}
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migration %d (%s) has no up step", migration.Version, migration.Name)
+		}
+	}
+
+	return &Migrator{db: db, service: service, migrations: sorted}, nil
+}
+
+// Status lists every known migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		statuses = make([]MigrationStatus, 0, len(m.migrations))
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if row, ok := applied[migration.Version]; ok {
+				appliedAt := row.AppliedAt
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// Up applies every pending migration and returns how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	return m.To(ctx, m.latestVersion())
+}
+
+// Down reverts the given number of most recently applied migrations
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	var count int
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := m.revert(conn, migration); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// To migrates up or down until the given version is the latest applied one.
+// Version 0 reverts every migration.
+func (m *Migrator) To(ctx context.Context, version int64) (int, error) {
+	if version != 0 && m.find(version) == nil {
+		return 0, fmt.Errorf("%w: %d", ErrMigrationVersion, version)
+	}
+
+	var count int
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		up, down, err := m.plan(applied, version)
+		if err != nil {
+			return err
+		}
+		for _, migration := range down {
+			if err := m.revert(conn, migration); err != nil {
+				return err
+			}
+			count++
+		}
+		for _, migration := range up {
+			if err := m.apply(conn, migration); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// plan returns the migrations to apply, in ascending order, and the ones to
+// revert, in descending order, to reach the target version
+func (m *Migrator) plan(applied map[int64]appliedMigration, target int64) (up, down []Migration, err error) {
+	for version := range applied {
+		if m.find(version) == nil {
+			return nil, nil, fmt.Errorf("%w: %d", ErrUnknownMigration, version)
+		}
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; ok && migration.Version > target {
+			down = append(down, migration)
+		}
+	}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok && migration.Version <= target {
+			up = append(up, migration)
+		}
+	}
+	return up, down, nil
+}
+
+// apply runs a migration's up step and records it
+func (m *Migrator) apply(conn *gorm.DB, migration Migration) error {
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Exec("INSERT INTO schema_migrations (service, version, name) VALUES (?, ?, ?)",
+			m.service, migration.Version, migration.Name).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// revert runs a migration's down step and removes its record
+func (m *Migrator) revert(conn *gorm.DB, migration Migration) error {
+	if migration.Down == nil {
+		return fmt.Errorf("%w: %d (%s)", ErrIrreversible, migration.Version, migration.Name)
+	}
+
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		return tx.Exec("DELETE FROM schema_migrations WHERE service = ? AND version = ?",
+			m.service, migration.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revert migration %d (%s): %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// applied returns the service's rows of schema_migrations by version
+func (m *Migrator) applied(conn *gorm.DB) (map[int64]appliedMigration, error) {
+	var rows []appliedMigration
+	if err := conn.Raw("SELECT version, name, applied_at FROM schema_migrations WHERE service = ?", m.service).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	applied := make(map[int64]appliedMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// withLock runs fn on a single connection holding the service's advisory
+// lock. The schema_migrations table is created first if needed.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		key := m.lockKey()
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", key).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer m.unlock(conn, key)
+
+		if err := conn.Exec(schemaMigrationsTable).Error; err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+		return fn(conn)
+	})
+}
+
+// unlock releases the advisory lock taken by withLock. The lock belongs to
+// the session, so a failed unlock leaves it held by the pooled connection
+// and blocks other replicas until that connection closes.
+func (m *Migrator) unlock(conn *gorm.DB, key int64) {
+	var released bool
+	if err := conn.Raw("SELECT pg_advisory_unlock(?)", key).Scan(&released).Error; err != nil {
+		logrus.WithError(err).WithField("service", m.service).Error("Failed to release migration lock")
+		return
+	}
+	if !released {
+		logrus.WithField("service", m.service).Warn("Migration lock was not held when releasing it")
+	}
+}
+
+// lockKey derives the advisory lock key from the service name
+func (m *Migrator) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("schema_migrations:" + m.service))
+	return int64(h.Sum64())
+}
+
+// find returns the migration with the given version
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// latestVersion returns the highest known version
+func (m *Migrator) latestVersion() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// RunMigrateCommand runs the migrate subcommand of a service binary:
+//
+//	migrate status        list migrations and whether they are applied
+//	migrate up            apply every pending migration
+//	migrate down [steps]  revert the last migration, or the last steps
+//	migrate to <version>  migrate up or down to the version
+func RunMigrateCommand(ctx context.Context, db *gorm.DB, service string, migrations []Migration, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return ErrInvalidMigrateArgs
+	}
+
+	m, err := NewMigrator(db, service, migrations)
+	if err != nil {
+		return err
+	}
+
+	var count int
+	switch args[0] {
+	case "status":
+		return printMigrationStatus(ctx, m, out)
+	case "up":
+		count, err = m.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return ErrInvalidMigrateArgs
+			}
+		}
+		count, err = m.Down(ctx, steps)
+	case "to":
+		if len(args) < 2 {
+			return ErrInvalidMigrateArgs
+		}
+		version, parseErr := strconv.ParseInt(args[1], 10, 64)
+		if parseErr != nil {
+			return ErrInvalidMigrateArgs
+		}
+		count, err = m.To(ctx, version)
+	default:
+		return ErrInvalidMigrateArgs
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "%s: %d migration(s) run\n", m.service, count)
+	return nil
+}
+
+// printMigrationStatus writes the migration status as a table
+func printMigrationStatus(ctx context.Context, m *Migrator, out io.Writer) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+	return w.Flush()
+}
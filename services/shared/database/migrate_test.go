@@ -0,0 +1,85 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func noop(tx *gorm.DB) error { return nil }
+
+func testMigrations() []Migration {
+	return []Migration{
+		{Version: 3, Name: "backfill", Up: noop, Down: noop},
+		{Version: 1, Name: "baseline", Up: noop, Down: noop},
+		{Version: 2, Name: "add_column", Up: noop, Down: noop},
+	}
+}
+
+func versions(migrations []Migration) []int64 {
+	result := make([]int64, 0, len(migrations))
+	for _, migration := range migrations {
+		result = append(result, migration.Version)
+	}
+	return result
+}
+
+func equalVersions(got, want []int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNewMigrator_RejectsInvalidVersions(t *testing.T) {
+	cases := map[string][]Migration{
+		"zero":      {{Version: 0, Name: "zero", Up: noop}},
+		"duplicate": {{Version: 1, Name: "a", Up: noop}, {Version: 1, Name: "b", Up: noop}},
+	}
+	for name, migrations := range cases {
+		if _, err := NewMigrator(nil, "test", migrations); !errors.Is(err, ErrMigrationVersion) {
+			t.Errorf("%s: err = %v, want ErrMigrationVersion", name, err)
+		}
+	}
+}
+
+func TestMigrator_PlanOrdersUpAndDown(t *testing.T) {
+	m, err := NewMigrator(nil, "test", testMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up, down, err := m.plan(map[int64]appliedMigration{1: {Version: 1}}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalVersions(versions(up), []int64{2, 3}) || len(down) != 0 {
+		t.Fatalf("up = %v, down = %v; want up [2 3]", versions(up), versions(down))
+	}
+
+	up, down, err = m.plan(map[int64]appliedMigration{1: {Version: 1}, 2: {Version: 2}, 3: {Version: 3}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(up) != 0 || !equalVersions(versions(down), []int64{3, 2}) {
+		t.Fatalf("up = %v, down = %v; want down [3 2]", versions(up), versions(down))
+	}
+}
+
+func TestMigrator_PlanRejectsUnknownAppliedVersion(t *testing.T) {
+	m, err := NewMigrator(nil, "test", testMigrations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = m.plan(map[int64]appliedMigration{4: {Version: 4}}, 3)
+	if !errors.Is(err, ErrUnknownMigration) {
+		t.Fatalf("err = %v, want ErrUnknownMigration", err)
+	}
+}
@@ -103,9 +103,14 @@ func (pdb *PostgresDB) Health(ctx context.Context) error {
 	return pdb.SqlDB.PingContext(ctx)
 }
 
-// Migrate runs database migrations for the given models
-func (pdb *PostgresDB) Migrate(models ...interface{}) error {
-	return pdb.DB.AutoMigrate(models...)
+// Migrate applies the service's pending migrations and returns how many
+// were applied
+func (pdb *PostgresDB) Migrate(ctx context.Context, service string, migrations []Migration) (int, error) {
+	migrator, err := NewMigrator(pdb.DB, service, migrations)
+	if err != nil {
+		return 0, err
+	}
+	return migrator.Up(ctx)
 }
 
 // Transaction executes a function within a database transaction
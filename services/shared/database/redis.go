@@ -75,6 +75,12 @@ func (r *RedisClient) Set(ctx context.Context, key string, value interface{}, ex
 	return r.Client.Set(ctx, key, value, expiration).Err()
 }
 
+// SetNX stores a key-value pair only if the key does not exist yet and
+// reports whether it was stored
+func (r *RedisClient) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	return r.Client.SetNX(ctx, key, value, expiration).Result()
+}
+
 // Get retrieves a value by key
 func (r *RedisClient) Get(ctx context.Context, key string) (string, error) {
 	return r.Client.Get(ctx, key).Result()
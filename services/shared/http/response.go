@@ -29,6 +29,10 @@ type MetaInfo struct {
 	PerPage    int   `json:"per_page,omitempty"`
 	Total      int64 `json:"total,omitempty"`
 	TotalPages int   `json:"total_pages,omitempty"`
+
+	// Cursor pagination
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more,omitempty"`
 }
 
 // PaginationParams holds pagination parameters from query string
@@ -128,6 +132,20 @@ func Created(c *gin.Context, data interface{}, message ...string) {
 	c.JSON(http.StatusCreated, response)
 }
 
+// Accepted sends a 202 Accepted response for work that completes later
+func Accepted(c *gin.Context, data interface{}, message ...string) {
+	response := Response{
+		Success: true,
+		Data:    data,
+	}
+
+	if len(message) > 0 {
+		response.Message = message[0]
+	}
+
+	c.JSON(http.StatusAccepted, response)
+}
+
 // NoContent sends a 204 No Content response
 func NoContent(c *gin.Context) {
 	c.Status(http.StatusNoContent)
@@ -221,6 +239,24 @@ func Conflict(c *gin.Context, message string, details ...map[string]interface{})
 	c.JSON(http.StatusConflict, response)
 }
 
+// TooManyRequests sends a 429 Too Many Requests response
+func TooManyRequests(c *gin.Context, message ...string) {
+	msg := "Too many requests"
+	if len(message) > 0 {
+		msg = message[0]
+	}
+
+	response := Response{
+		Success: false,
+		Error: &ErrorInfo{
+			Code:    "RATE_LIMITED",
+			Message: msg,
+		},
+	}
+
+	c.JSON(http.StatusTooManyRequests, response)
+}
+
 // InternalServerError sends a 500 Internal Server Error response
 func InternalServerError(c *gin.Context, message ...string) {
 	msg := "Internal server error"
@@ -0,0 +1,331 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	httputil "unified-commerce/services/shared/http"
+)
+
+// IdempotencyKeyHeader carries the client's key for a mutation it may retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader marks a response replayed from an earlier request
+// with the same key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength bounds the keys clients can send
+const maxIdempotencyKeyLength = 255
+
+type idempotencyKeyContextKey struct{}
+
+// ContextWithIdempotencyKey returns a context carrying the idempotency key
+// the request claimed
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+// IdempotencyKeyFromContext returns the idempotency key the request claimed,
+// if it was sent with one. Handlers that must not run without a key, such as
+// GraphQL mutations moving money, check it.
+func IdempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKeyContextKey{}).(string)
+	return key, ok && key != ""
+}
+
+// IdempotencyRecord is a request made with an idempotency key and, once the
+// request has completed, its response. A record without a status is still
+// in progress.
+type IdempotencyRecord struct {
+	Scope       string    `gorm:"primaryKey;size:100"`
+	Key         string    `gorm:"primaryKey;size:255"`
+	Fingerprint string    `gorm:"size:64;not null"`
+	Status      int       `gorm:"not null;default:0"`
+	ContentType string    `gorm:"size:100"`
+	Body        []byte    `gorm:"type:bytea"`
+	CreatedAt   time.Time `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
+
+// TableName places records in the idempotency_keys table
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_keys"
+}
+
+// Completed reports whether the record's request has finished and its
+// response can be replayed
+func (r *IdempotencyRecord) Completed() bool {
+	return r.Status != 0
+}
+
+// IdempotencyModels lists the tables a service storing idempotency keys in
+// Postgres needs, for its migrations
+func IdempotencyModels() []interface{} {
+	return []interface{}{&IdempotencyRecord{}}
+}
+
+// IdempotencyStore remembers requests made with idempotency keys
+type IdempotencyStore interface {
+	// Begin claims the key for a request. It returns nil if the request
+	// should run, or the unexpired record of an earlier request with the
+	// key otherwise.
+	Begin(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error)
+	// Complete stores the response of a claimed request
+	Complete(ctx context.Context, record *IdempotencyRecord) error
+	// Release forgets a claimed request, so it can be retried with the key
+	Release(ctx context.Context, scope, key string) error
+}
+
+// PostgresIdempotencyStore keeps idempotency records in Postgres. Claims
+// are inserts on the key, so concurrent requests with the same key cannot
+// both run.
+type PostgresIdempotencyStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresIdempotencyStore creates a Postgres-backed idempotency store
+func NewPostgresIdempotencyStore(db *gorm.DB) *PostgresIdempotencyStore {
+	return &PostgresIdempotencyStore{db: db}
+}
+
+// Begin implements IdempotencyStore. An expired record is replaced.
+func (s *PostgresIdempotencyStore) Begin(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	db := s.db.WithContext(ctx)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to claim idempotency key: %w", result.Error)
+		}
+		if result.RowsAffected == 1 {
+			return nil, nil
+		}
+
+		var existing IdempotencyRecord
+		err := db.Where("scope = ? AND key = ?", record.Scope, record.Key).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Released since the insert, so claim it again
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load idempotency key: %w", err)
+		}
+		if existing.ExpiresAt.After(record.CreatedAt) {
+			return &existing, nil
+		}
+
+		err = db.Where("scope = ? AND key = ? AND expires_at <= ?", record.Scope, record.Key, record.CreatedAt).
+			Delete(&IdempotencyRecord{}).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to replace expired idempotency key: %w", err)
+		}
+	}
+
+	return nil, fmt.Errorf("failed to claim idempotency key %q", record.Key)
+}
+
+// Complete implements IdempotencyStore
+func (s *PostgresIdempotencyStore) Complete(ctx context.Context, record *IdempotencyRecord) error {
+	return s.db.WithContext(ctx).
+		Model(&IdempotencyRecord{}).
+		Where("scope = ? AND key = ?", record.Scope, record.Key).
+		Updates(map[string]interface{}{
+			"status":       record.Status,
+			"content_type": record.ContentType,
+			"body":         record.Body,
+		}).Error
+}
+
+// Release implements IdempotencyStore
+func (s *PostgresIdempotencyStore) Release(ctx context.Context, scope, key string) error {
+	return s.db.WithContext(ctx).
+		Where("scope = ? AND key = ?", scope, key).
+		Delete(&IdempotencyRecord{}).Error
+}
+
+// DeleteExpired deletes the records whose keys have expired
+func (s *PostgresIdempotencyStore) DeleteExpired(ctx context.Context) (int64, error) {
+	result := s.db.WithContext(ctx).
+		Where("expires_at <= ?", time.Now()).
+		Delete(&IdempotencyRecord{})
+	return result.RowsAffected, result.Error
+}
+
+// IdempotencyConfig holds how long keys are remembered and whose keys they
+// are
+type IdempotencyConfig struct {
+	TTL       time.Duration
+	ScopeFunc func(c *gin.Context) string
+}
+
+// DefaultIdempotencyConfig remembers keys for a day, per merchant, or per
+// user for requests without a merchant
+func DefaultIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		TTL:       24 * time.Hour,
+		ScopeFunc: idempotencyScope,
+	}
+}
+
+// idempotencyScope scopes keys to the request's merchant or user. Public
+// requests share a scope; their keys are expected to be random.
+func idempotencyScope(c *gin.Context) string {
+	if merchantID := c.GetString("merchant_id"); merchantID != "" {
+		return "merchant:" + merchantID
+	}
+	if userID := c.GetString("user_id"); userID != "" {
+		return "user:" + userID
+	}
+	return "public"
+}
+
+// Idempotency makes mutations safe to retry. A request sent with an
+// Idempotency-Key header runs once; retries with the same key get the
+// stored response, marked with Idempotent-Replayed, and the same key with a
+// different method, path or body is rejected with a 409, as is a retry
+// while the first request is still running. Server errors release the key
+// so the request can be retried. Requests without a key, and reads, are
+// let through; IdempotencyKeyFromContext tells handlers which is which. It
+// must run after JWTAuth so keys are scoped to the caller.
+func Idempotency(store IdempotencyStore, config IdempotencyConfig) gin.HandlerFunc {
+	if config.ScopeFunc == nil {
+		config.ScopeFunc = idempotencyScope
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || !isMutation(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			httputil.BadRequest(c, fmt.Sprintf("%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			httputil.BadRequest(c, "Failed to read request body")
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		now := time.Now()
+		record := &IdempotencyRecord{
+			Scope:       config.ScopeFunc(c),
+			Key:         key,
+			Fingerprint: requestFingerprint(c.Request.Method, c.Request.URL.Path, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(config.TTL),
+		}
+		log := logrus.WithField("idempotency_key", key).WithField("scope", record.Scope)
+
+		existing, err := store.Begin(ctx, record)
+		if err != nil {
+			// Without the key the request could run twice, so refuse it
+			log.WithError(err).Error("Idempotency key check failed")
+			httputil.InternalServerError(c, "Failed to check idempotency key")
+			c.Abort()
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != record.Fingerprint:
+				httputil.Conflict(c, fmt.Sprintf("%s was already used for a different request", IdempotencyKeyHeader))
+			case !existing.Completed():
+				httputil.Conflict(c, fmt.Sprintf("A request with this %s is still in progress", IdempotencyKeyHeader))
+			default:
+				c.Header(IdempotentReplayedHeader, "true")
+				c.Data(existing.Status, existing.ContentType, existing.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		c.Request = c.Request.WithContext(ContextWithIdempotencyKey(ctx, key))
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer func() {
+			// A panicking handler is treated as a server error
+			if recovered := recover(); recovered != nil {
+				if err := store.Release(context.Background(), record.Scope, key); err != nil {
+					log.WithError(err).Error("Failed to release idempotency key")
+				}
+				panic(recovered)
+			}
+		}()
+
+		c.Next()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			if err := store.Release(context.Background(), record.Scope, key); err != nil {
+				log.WithError(err).Error("Failed to release idempotency key")
+			}
+			return
+		}
+
+		record.Status = status
+		record.ContentType = writer.Header().Get("Content-Type")
+		record.Body = writer.body.Bytes()
+		if err := store.Complete(context.Background(), record); err != nil {
+			// The key stays claimed, so retries are refused rather than rerun
+			log.WithError(err).Error("Failed to store idempotent response")
+		}
+	}
+}
+
+// isMutation reports whether requests with the method change state
+func isMutation(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// requestFingerprint hashes a request's method, path and body. JSON bodies
+// are compacted so formatting alone does not make a retry differ.
+func requestFingerprint(method, path string, body []byte) string {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, body); err == nil {
+		body = compacted.Bytes()
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the response body it writes
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
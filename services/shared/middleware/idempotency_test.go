@@ -0,0 +1,93 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"unified-commerce/services/shared/middleware"
+)
+
+// memoryIdempotencyStore keeps idempotency records in memory
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]middleware.IdempotencyRecord
+}
+
+func (s *memoryIdempotencyStore) Begin(ctx context.Context, record *middleware.IdempotencyRecord) (*middleware.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.records[record.Scope+"/"+record.Key]; ok && existing.ExpiresAt.After(record.CreatedAt) {
+		return &existing, nil
+	}
+	s.records[record.Scope+"/"+record.Key] = *record
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, record *middleware.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.Scope+"/"+record.Key] = *record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, scope, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, scope+"/"+key)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotencyStore{records: map[string]middleware.IdempotencyRecord{}}
+
+	charges, status, claimed := 0, http.StatusCreated, ""
+	router := gin.New()
+	router.POST("/payments/:id/process", middleware.Idempotency(store, middleware.DefaultIdempotencyConfig()), func(c *gin.Context) {
+		charges++
+		claimed, _ = middleware.IdempotencyKeyFromContext(c.Request.Context())
+		c.JSON(status, gin.H{"charge": charges})
+	})
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/payments/1/process", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(middleware.IdempotencyKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send("key-1", `{"amount": 10}`)
+	retry := send("key-1", `{"amount":10}`)
+	if first.Code != http.StatusCreated || retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Fatalf("expected the retry to replay %d %s, got %d %s", first.Code, first.Body, retry.Code, retry.Body)
+	}
+	if retry.Header().Get(middleware.IdempotentReplayedHeader) != "true" || charges != 1 {
+		t.Fatalf("expected one charge and a replayed response, got %d charges", charges)
+	}
+	if claimed != "key-1" {
+		t.Errorf("expected the handler to see the claimed key, got %q", claimed)
+	}
+
+	if rec := send("key-1", `{"amount": 20}`); rec.Code != http.StatusConflict || charges != 1 {
+		t.Errorf("expected a different body to conflict, got %d with %d charges", rec.Code, charges)
+	}
+	if send("", `{"amount": 10}`); charges != 2 {
+		t.Errorf("expected a request without a key to run, got %d charges", charges)
+	}
+
+	status = http.StatusInternalServerError
+	send("key-2", `{"amount": 10}`)
+	status = http.StatusCreated
+	if rec := send("key-2", `{"amount": 10}`); rec.Code != http.StatusCreated || charges != 4 {
+		t.Errorf("expected a server error to release the key, got %d with %d charges", rec.Code, charges)
+	}
+}
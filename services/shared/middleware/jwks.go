@@ -0,0 +1,274 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWKS errors
+var (
+	ErrMissingKeyID     = errors.New("token has no kid header")
+	ErrUnknownKeyID     = errors.New("token signed with unknown key")
+	ErrUnsupportedKey   = errors.New("unsupported key type")
+	ErrAlgorithmInvalid = errors.New("token algorithm does not match key")
+)
+
+// SupportedSigningMethods lists the JWT algorithms accepted by JWTAuth
+var SupportedSigningMethods = []string{"RS256", "ES256"}
+
+// KeySet resolves the public key used to verify a token
+type KeySet interface {
+	Keyfunc(token *jwt.Token) (interface{}, error)
+}
+
+// JWK is a public JSON Web Key (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set as served at /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK converts an RSA or P-256 public key to a JWK
+func NewJWK(kid string, key crypto.PublicKey) (JWK, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return JWK{}, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, k.Curve.Params().Name)
+		}
+		return JWK{
+			Kty: "EC",
+			Kid: kid,
+			Use: "sig",
+			Alg: "ES256",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(k.X.FillBytes(make([]byte, 32))),
+			Y:   base64.RawURLEncoding.EncodeToString(k.Y.FillBytes(make([]byte, 32))),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+}
+
+// PublicKey decodes the JWK into an RSA or ECDSA public key
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus for key %s: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent for key %s: %w", k.Kid, err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate for key %s: %w", k.Kid, err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate for key %s: %w", k.Kid, err)
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("key %s is not on curve P-256", k.Kid)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKey, k.Kty)
+	}
+}
+
+// jwksKey is a decoded key from a JWKS
+type jwksKey struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// JWKSCache fetches the identity service's JWKS and caches the keys. The set
+// is refreshed when it is older than the TTL or when a token names a key that
+// is not cached yet, which picks up newly rotated keys without a restart.
+type JWKSCache struct {
+	url             string
+	client          *http.Client
+	ttl             time.Duration
+	minRefreshDelay time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]jwksKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// NewJWKSCache creates a JWKS cache for the given URL
+func NewJWKSCache(url string, ttl time.Duration) *JWKSCache {
+	return &JWKSCache{
+		url:             url,
+		client:          &http.Client{Timeout: 5 * time.Second},
+		ttl:             ttl,
+		minRefreshDelay: 10 * time.Second,
+		keys:            make(map[string]jwksKey),
+	}
+}
+
+var (
+	sharedJWKSCaches   = make(map[string]*JWKSCache)
+	sharedJWKSCachesMu sync.Mutex
+)
+
+// SharedJWKSCache returns the process-wide cache for a JWKS URL
+func SharedJWKSCache(url string) *JWKSCache {
+	sharedJWKSCachesMu.Lock()
+	defer sharedJWKSCachesMu.Unlock()
+
+	cache, ok := sharedJWKSCaches[url]
+	if !ok {
+		cache = NewJWKSCache(url, 15*time.Minute)
+		sharedJWKSCaches[url] = cache
+	}
+	return cache
+}
+
+// Keyfunc implements KeySet
+func (c *JWKSCache) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrMissingKeyID
+	}
+
+	key, ok, stale := c.lookup(kid)
+	if !ok || stale {
+		if err := c.refresh(context.Background()); err != nil && !ok {
+			return nil, err
+		}
+		key, ok, _ = c.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyID, kid)
+	}
+
+	if key.alg != "" && key.alg != token.Method.Alg() {
+		return nil, ErrAlgorithmInvalid
+	}
+	return key.key, nil
+}
+
+// lookup returns a cached key and whether the cache needs refreshing
+func (c *JWKSCache) lookup(kid string) (jwksKey, bool, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	key, ok := c.keys[kid]
+	return key, ok, time.Since(c.fetchedAt) > c.ttl
+}
+
+// refresh fetches the JWKS. Attempts are throttled so tokens with unknown
+// kids cannot make every request hit the identity service. The fetch runs
+// without holding the lock, so lookups keep using the cached keys while the
+// identity service is slow. On failure the previously fetched keys stay in
+// use.
+func (c *JWKSCache) refresh(ctx context.Context) error {
+	c.mu.Lock()
+	if time.Since(c.lastAttempt) < c.minRefreshDelay {
+		c.mu.Unlock()
+		return nil
+	}
+	c.lastAttempt = time.Now()
+	c.mu.Unlock()
+
+	keys, err := c.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+// fetch downloads and decodes the JWKS
+func (c *JWKSCache) fetch(ctx context.Context) (map[string]jwksKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build JWKS request: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	var set JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]jwksKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// Skip keys this service cannot use rather than rejecting the set
+			continue
+		}
+		keys[jwk.Kid] = jwksKey{alg: jwk.Alg, key: key}
+	}
+	return keys, nil
+}
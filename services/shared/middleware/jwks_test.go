@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWKSCache_LookupDoesNotWaitForRefresh(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := NewJWK("k1", &privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every fetch after the first hangs until the test releases it
+	var requests atomic.Int32
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) > 1 {
+			close(fetching)
+			<-release
+		}
+		json.NewEncoder(w).Encode(JWKS{Keys: []JWK{jwk}})
+	}))
+	defer server.Close()
+	defer close(release)
+
+	cache := NewJWKSCache(server.URL, time.Millisecond)
+	cache.minRefreshDelay = 0
+
+	token := jwt.New(jwt.SigningMethodES256)
+	token.Header["kid"] = "k1"
+	if _, err := cache.Keyfunc(token); err != nil {
+		t.Fatalf("first lookup: %v", err)
+	}
+
+	// The cached set is now stale, so this lookup refreshes it
+	time.Sleep(5 * time.Millisecond)
+	go cache.Keyfunc(token)
+	<-fetching
+
+	found := make(chan bool, 1)
+	go func() {
+		_, ok, _ := cache.lookup("k1")
+		found <- ok
+	}()
+	select {
+	case ok := <-found:
+		if !ok {
+			t.Fatal("cached key was dropped while refreshing")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lookup blocked behind the JWKS fetch")
+	}
+}
+
+func TestJWKSCache_KeepsKeysWhenRefreshFails(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := NewJWK("k1", &privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(JWKS{Keys: []JWK{jwk}})
+	}))
+	defer server.Close()
+
+	cache := NewJWKSCache(server.URL, time.Millisecond)
+	cache.minRefreshDelay = 0
+
+	token := jwt.New(jwt.SigningMethodES256)
+	token.Header["kid"] = "k1"
+	if _, err := cache.Keyfunc(token); err != nil {
+		t.Fatalf("first lookup: %v", err)
+	}
+
+	failing.Store(true)
+	time.Sleep(5 * time.Millisecond)
+	if _, err := cache.Keyfunc(token); err != nil {
+		t.Fatalf("stale key was not used while the identity service is down: %v", err)
+	}
+}
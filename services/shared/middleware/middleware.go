@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"os"
 	"strings"
 	"time"
 
@@ -49,8 +50,8 @@ func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Request-ID")
-		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Request-ID, Idempotency-Key")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After, Idempotent-Replayed")
 		c.Header("Access-Control-Max-Age", "86400")
 
 		if c.Request.Method == "OPTIONS" {
@@ -64,16 +65,21 @@ func CORS() gin.HandlerFunc {
 
 // JWTClaims represents the claims in a JWT token
 type JWTClaims struct {
-	UserID     string   `json:"user_id"`
-	MerchantID string   `json:"merchant_id,omitempty"`
-	Email      string   `json:"email"`
-	Roles      []string `json:"roles"`
+	UserID      string   `json:"user_id"`
+	MerchantID  string   `json:"merchant_id,omitempty"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions,omitempty"` // "resource:action"
 	jwt.RegisteredClaims
 }
 
+// DefaultJWKSURL is where the identity service publishes its signing keys
+const DefaultJWKSURL = "http://localhost:8001/.well-known/jwks.json"
+
 // AuthConfig holds JWT authentication configuration
 type AuthConfig struct {
-	SecretKey     string
+	JWKSURL       string
+	KeySet        KeySet // overrides JWKSURL, e.g. for the identity service's own keys
 	TokenLookup   string // "header:Authorization" or "query:token" or "cookie:jwt"
 	TokenHeadName string // "Bearer"
 	SkipPaths     []string
@@ -81,8 +87,13 @@ type AuthConfig struct {
 
 // DefaultAuthConfig returns default authentication configuration
 func DefaultAuthConfig() *AuthConfig {
+	jwksURL := os.Getenv("JWKS_URL")
+	if jwksURL == "" {
+		jwksURL = DefaultJWKSURL
+	}
+
 	return &AuthConfig{
-		SecretKey:     "unified-commerce-jwt-secret-key",
+		JWKSURL:       jwksURL,
 		TokenLookup:   "header:Authorization",
 		TokenHeadName: "Bearer",
 		SkipPaths:     []string{"/health", "/metrics"},
@@ -91,6 +102,11 @@ func DefaultAuthConfig() *AuthConfig {
 
 // JWTAuth creates a JWT authentication middleware
 func JWTAuth(config *AuthConfig) gin.HandlerFunc {
+	keySet := config.KeySet
+	if keySet == nil {
+		keySet = SharedJWKSCache(config.JWKSURL)
+	}
+
 	return func(c *gin.Context) {
 		// Skip authentication for certain paths
 		for _, path := range config.SkipPaths {
@@ -107,24 +123,64 @@ func JWTAuth(config *AuthConfig) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := validateToken(token, config.SecretKey)
+		claims, err := validateToken(token, keySet)
 		if err != nil {
 			httputil.Unauthorized(c, "Invalid token")
 			c.Abort()
 			return
 		}
 
-		// Set user information in context
-		c.Set("user_id", claims.UserID)
-		c.Set("merchant_id", claims.MerchantID)
-		c.Set("email", claims.Email)
-		c.Set("roles", claims.Roles)
-		c.Set("claims", claims)
+		setAuthContext(c, claims, token)
+
+		c.Next()
+	}
+}
+
+// OptionalJWTAuth authenticates requests that carry a token and lets
+// anonymous requests through, for endpoints such as GraphQL that authorize
+// individual fields
+func OptionalJWTAuth(config *AuthConfig) gin.HandlerFunc {
+	keySet := config.KeySet
+	if keySet == nil {
+		keySet = SharedJWKSCache(config.JWKSURL)
+	}
+
+	return func(c *gin.Context) {
+		token := extractToken(c, config)
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		claims, err := validateToken(token, keySet)
+		if err != nil {
+			httputil.Unauthorized(c, "Invalid token")
+			c.Abort()
+			return
+		}
 
+		setAuthContext(c, claims, token)
 		c.Next()
 	}
 }
 
+// setAuthContext stores the authenticated user in the gin context and the
+// request context
+func setAuthContext(c *gin.Context, claims *JWTClaims, token string) {
+	c.Set("user_id", claims.UserID)
+	c.Set("merchant_id", claims.MerchantID)
+	c.Set("email", claims.Email)
+	c.Set("roles", claims.Roles)
+	c.Set("permissions", claims.Permissions)
+	c.Set("claims", claims)
+
+	ctx := ContextWithClaims(c.Request.Context(), claims, token)
+	if merchantID := c.GetHeader(MerchantIDHeader); merchantID != "" && claims.MerchantID == "" {
+		ctx = ContextWithMerchantID(ctx, merchantID)
+	}
+	c.Request = c.Request.WithContext(ctx)
+}
+
 // RequireRole creates a middleware that requires specific roles
 func RequireRole(requiredRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -204,13 +260,12 @@ func extractToken(c *gin.Context, config *AuthConfig) string {
 	return ""
 }
 
-// validateToken validates a JWT token and returns claims
-func validateToken(tokenString, secretKey string) (*JWTClaims, error) {
+// validateToken validates a JWT token against the key set and returns claims
+func validateToken(tokenString string, keySet KeySet) (*JWTClaims, error) {
 	claims := &JWTClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, keySet.Keyfunc,
+		jwt.WithValidMethods(SupportedSigningMethods))
 
 	if err != nil {
 		return nil, err
@@ -238,12 +293,3 @@ func Recovery() gin.HandlerFunc {
 		httputil.InternalServerError(c, "Internal server error")
 	})
 }
-
-// RateLimit creates a simple rate limiting middleware
-func RateLimit() gin.HandlerFunc {
-	// This is a simple implementation. In production, you would use Redis or similar
-	return func(c *gin.Context) {
-		// TODO: Implement proper rate limiting with Redis
-		c.Next()
-	}
-}
@@ -0,0 +1,308 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	httputil "unified-commerce/services/shared/http"
+	"unified-commerce/services/shared/tenant"
+)
+
+// Authorization errors
+var (
+	ErrUnauthenticated  = errors.New("authentication required")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// MerchantIDHeader selects the merchant a request acts on when the token is
+// not bound to one
+const MerchantIDHeader = "X-Merchant-ID"
+
+// DefaultMerchantAccountURL is where merchant member permissions are looked up
+const DefaultMerchantAccountURL = "http://localhost:8008"
+
+// Permission formats a resource and action as "resource:action", the form
+// used in tokens and merchant member permissions
+func Permission(resource, action string) string {
+	return resource + ":" + action
+}
+
+// PermissionGranted reports whether the granted permissions include the
+// action on the resource. "*" grants everything and "resource:*" grants
+// every action on the resource.
+func PermissionGranted(granted []string, resource, action string) bool {
+	for _, permission := range granted {
+		if permission == "*" || permission == "*:*" {
+			return true
+		}
+		grantedResource, grantedAction, ok := strings.Cut(permission, ":")
+		if !ok {
+			continue
+		}
+		if (grantedResource == resource || grantedResource == "*") &&
+			(grantedAction == action || grantedAction == "*") {
+			return true
+		}
+	}
+	return false
+}
+
+type claimsContextKey struct{}
+type tokenContextKey struct{}
+type merchantContextKey struct{}
+
+// ContextWithClaims returns a context carrying the authenticated claims and
+// the raw token they came from
+func ContextWithClaims(ctx context.Context, claims *JWTClaims, token string) context.Context {
+	ctx = context.WithValue(ctx, claimsContextKey{}, claims)
+	return context.WithValue(ctx, tokenContextKey{}, token)
+}
+
+// ClaimsFromContext returns the authenticated claims, if any
+func ClaimsFromContext(ctx context.Context) (*JWTClaims, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*JWTClaims)
+	return claims, ok && claims != nil
+}
+
+// tokenFromContext returns the raw token of the authenticated request
+func tokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(tokenContextKey{}).(string)
+	return token
+}
+
+// ContextWithMerchantID returns a context carrying the merchant the request
+// acts on
+func ContextWithMerchantID(ctx context.Context, merchantID string) context.Context {
+	return context.WithValue(ctx, merchantContextKey{}, merchantID)
+}
+
+// MerchantIDFromContext returns the merchant the request acts on. The tenant
+// verified by the Tenant middleware takes precedence over the requested one.
+func MerchantIDFromContext(ctx context.Context) string {
+	if merchantID, ok := tenant.MerchantID(ctx); ok {
+		return merchantID
+	}
+	if merchantID, _ := ctx.Value(merchantContextKey{}).(string); merchantID != "" {
+		return merchantID
+	}
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		return claims.MerchantID
+	}
+	return ""
+}
+
+// PermissionResolver looks up the permissions a user holds for a merchant
+type PermissionResolver interface {
+	ResolvePermissions(ctx context.Context, userID, merchantID string) ([]string, error)
+}
+
+// CachedPermissionResolver caches resolved permissions for a short time so
+// authorization does not call the merchant account service on every request
+type CachedPermissionResolver struct {
+	resolver PermissionResolver
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedPermissions
+}
+
+// cachedPermissions is a cache entry of CachedPermissionResolver
+type cachedPermissions struct {
+	permissions []string
+	expiresAt   time.Time
+}
+
+// NewCachedPermissionResolver wraps a resolver with a cache
+func NewCachedPermissionResolver(resolver PermissionResolver, ttl time.Duration) *CachedPermissionResolver {
+	return &CachedPermissionResolver{
+		resolver: resolver,
+		ttl:      ttl,
+		entries:  make(map[string]cachedPermissions),
+	}
+}
+
+// ResolvePermissions implements PermissionResolver
+func (r *CachedPermissionResolver) ResolvePermissions(ctx context.Context, userID, merchantID string) ([]string, error) {
+	key := userID + "/" + merchantID
+
+	r.mu.Lock()
+	entry, ok := r.entries[key]
+	r.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.permissions, nil
+	}
+
+	permissions, err := r.resolver.ResolvePermissions(ctx, userID, merchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.entries[key] = cachedPermissions{permissions: permissions, expiresAt: time.Now().Add(r.ttl)}
+	r.mu.Unlock()
+
+	return permissions, nil
+}
+
+// MerchantPermissionResolver resolves a user's merchant member permissions
+// from the merchant account service, calling it with the user's own token
+type MerchantPermissionResolver struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewMerchantPermissionResolver creates a resolver for the merchant account
+// service at baseURL
+func NewMerchantPermissionResolver(baseURL string) *MerchantPermissionResolver {
+	return &MerchantPermissionResolver{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// ResolvePermissions implements PermissionResolver. Users who are not active
+// members of the merchant get nil, members always get a non-nil slice.
+func (r *MerchantPermissionResolver) ResolvePermissions(ctx context.Context, userID, merchantID string) ([]string, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/merchants/%s/members/me/permissions", r.baseURL, url.PathEscape(merchantID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := tokenFromContext(ctx); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch merchant permissions: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusForbidden:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("failed to fetch merchant permissions: unexpected status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data struct {
+			Permissions []string `json:"permissions"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode merchant permissions: %w", err)
+	}
+	if body.Data.Permissions == nil {
+		return []string{}, nil
+	}
+	return body.Data.Permissions, nil
+}
+
+// Authorizer checks permissions from the token first and falls back to the
+// user's member permissions for the merchant the request acts on
+type Authorizer struct {
+	resolver PermissionResolver
+}
+
+// NewAuthorizer creates an authorizer. A nil resolver only checks the token.
+func NewAuthorizer(resolver PermissionResolver) *Authorizer {
+	return &Authorizer{resolver: resolver}
+}
+
+var (
+	defaultAuthorizer     *Authorizer
+	defaultAuthorizerOnce sync.Once
+)
+
+// DefaultAuthorizer returns the process-wide authorizer, which resolves
+// merchant permissions from MERCHANT_ACCOUNT_URL and caches them for a minute
+func DefaultAuthorizer() *Authorizer {
+	defaultAuthorizerOnce.Do(func() {
+		baseURL := os.Getenv("MERCHANT_ACCOUNT_URL")
+		if baseURL == "" {
+			baseURL = DefaultMerchantAccountURL
+		}
+		defaultAuthorizer = NewAuthorizer(NewCachedPermissionResolver(NewMerchantPermissionResolver(baseURL), time.Minute))
+	})
+	return defaultAuthorizer
+}
+
+// Authorize returns nil if the authenticated user may perform the action on
+// the resource
+func (a *Authorizer) Authorize(ctx context.Context, resource, action string) error {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	if PermissionGranted(claims.Permissions, resource, action) {
+		return nil
+	}
+
+	merchantID := MerchantIDFromContext(ctx)
+	if merchantID == "" || a.resolver == nil {
+		return ErrPermissionDenied
+	}
+
+	permissions, err := a.resolver.ResolvePermissions(ctx, claims.UserID, merchantID)
+	if err != nil {
+		return err
+	}
+	if PermissionGranted(permissions, resource, action) {
+		return nil
+	}
+	return ErrPermissionDenied
+}
+
+// IsMember reports whether the authenticated user is an active member of the
+// merchant
+func (a *Authorizer) IsMember(ctx context.Context, merchantID string) (bool, error) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return false, ErrUnauthenticated
+	}
+	if a.resolver == nil {
+		return false, nil
+	}
+
+	permissions, err := a.resolver.ResolvePermissions(ctx, claims.UserID, merchantID)
+	if err != nil {
+		return false, err
+	}
+	return permissions != nil, nil
+}
+
+// RequirePermission creates a middleware that requires the authenticated user
+// to hold the permission, either in the token or as a merchant member. It must
+// run after JWTAuth.
+func RequirePermission(resource, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := DefaultAuthorizer().Authorize(c.Request.Context(), resource, action)
+		switch {
+		case err == nil:
+			c.Next()
+			return
+		case errors.Is(err, ErrUnauthenticated):
+			httputil.Unauthorized(c, "Authentication required")
+		case errors.Is(err, ErrPermissionDenied):
+			httputil.Forbidden(c, "Insufficient permissions")
+		default:
+			logrus.WithError(err).WithField("permission", Permission(resource, action)).Error("Permission check failed")
+			httputil.Forbidden(c, "Unable to verify permissions")
+		}
+		c.Abort()
+	}
+}
@@ -0,0 +1,312 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+
+	"unified-commerce/services/shared/database"
+	httputil "unified-commerce/services/shared/http"
+)
+
+// RateLimitAlgorithm selects how requests are counted
+type RateLimitAlgorithm string
+
+const (
+	// TokenBucket allows bursts up to Limit and refills Limit tokens per Window
+	TokenBucket RateLimitAlgorithm = "token_bucket"
+	// SlidingWindow allows at most Limit requests in any Window
+	SlidingWindow RateLimitAlgorithm = "sliding_window"
+)
+
+// RateLimitKeyFunc returns the identity a request is limited by
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// RateLimitConfig holds the rate limit for a route group
+type RateLimitConfig struct {
+	Name      string // scopes counters, so route groups do not share limits
+	Algorithm RateLimitAlgorithm
+	Limit     int
+	Window    time.Duration
+	KeyFunc   RateLimitKeyFunc
+	SkipPaths []string
+}
+
+// DefaultRateLimitConfig returns the service-wide limit applied per client IP
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Name:      "default",
+		Algorithm: SlidingWindow,
+		Limit:     300,
+		Window:    time.Minute,
+		KeyFunc:   KeyByIP(),
+		SkipPaths: []string{"/health", "/ready", "/metrics"},
+	}
+}
+
+// PublicRateLimitConfig returns a stricter limit for unauthenticated routes
+func PublicRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Name:      "public",
+		Algorithm: TokenBucket,
+		Limit:     30,
+		Window:    time.Minute,
+		KeyFunc:   KeyByIP(),
+	}
+}
+
+// MerchantRateLimitConfig returns the limit shared by every user of a
+// merchant on authenticated routes. It must run after JWTAuth.
+func MerchantRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Name:      "merchant",
+		Algorithm: SlidingWindow,
+		Limit:     1200,
+		Window:    time.Minute,
+		KeyFunc:   KeyByMerchantID(),
+	}
+}
+
+// UserRateLimitConfig returns the limit of each user on authenticated
+// routes. It must run after JWTAuth.
+func UserRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Name:      "user",
+		Algorithm: TokenBucket,
+		Limit:     120,
+		Window:    time.Minute,
+		KeyFunc:   KeyByUserID(),
+	}
+}
+
+// KeyByIP limits requests per client IP. X-Forwarded-For is only honoured
+// from the engine's trusted proxies, so clients cannot rotate their key.
+func KeyByIP() RateLimitKeyFunc {
+	return func(c *gin.Context) string {
+		return "ip:" + c.ClientIP()
+	}
+}
+
+// KeyByUserID limits requests per authenticated user. It must run after
+// JWTAuth; anonymous requests fall back to the client IP.
+func KeyByUserID() RateLimitKeyFunc {
+	return keyByContextValue("user_id", "user")
+}
+
+// KeyByMerchantID limits requests per merchant. It must run after JWTAuth;
+// requests without a merchant fall back to the client IP.
+func KeyByMerchantID() RateLimitKeyFunc {
+	return keyByContextValue("merchant_id", "merchant")
+}
+
+// KeyByAPIKey limits requests per API key sent in the header. Keys are
+// hashed so the secrets are not stored in Redis; requests without a key fall
+// back to the client IP.
+func KeyByAPIKey(header string) RateLimitKeyFunc {
+	return func(c *gin.Context) string {
+		if key := c.GetHeader(header); key != "" {
+			sum := sha256.Sum256([]byte(key))
+			return "apikey:" + hex.EncodeToString(sum[:])
+		}
+		return "ip:" + c.ClientIP()
+	}
+}
+
+// keyByContextValue keys requests by a value set in the gin context
+func keyByContextValue(contextKey, prefix string) RateLimitKeyFunc {
+	return func(c *gin.Context) string {
+		if value := c.GetString(contextKey); value != "" {
+			return prefix + ":" + value
+		}
+		return "ip:" + c.ClientIP()
+	}
+}
+
+// RateLimitResult is the outcome of a rate limit check
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration // how long until the next request is allowed
+	ResetAfter time.Duration // how long until the full limit is available again
+}
+
+// tokenBucketScript refills the bucket for the time elapsed since the last
+// request and takes a token if one is available. Redis server time is used
+// so every service instance shares the same clock.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local rate = capacity / window
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], window)
+
+return {allowed, math.floor(tokens), retry, math.ceil((capacity - tokens) / rate)}
+`)
+
+// slidingWindowScript keeps a sorted set of request timestamps, drops the
+// ones older than the window and records the request if under the limit
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[3])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], window)
+
+local retry = 0
+local reset = 0
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+	if allowed == 0 then
+		retry = reset
+	end
+end
+
+return {allowed, limit - count, retry, reset}
+`)
+
+// RateLimiter enforces rate limits with counters stored in Redis, so limits
+// hold across every instance of a service
+type RateLimiter struct {
+	redis  *database.RedisClient
+	prefix string
+}
+
+// NewRateLimiter creates a Redis-backed rate limiter. A nil client disables
+// rate limiting.
+func NewRateLimiter(redisClient *database.RedisClient) *RateLimiter {
+	return &RateLimiter{
+		redis:  redisClient,
+		prefix: "ratelimit",
+	}
+}
+
+// Allow checks and records a request against the limit for the key
+func (l *RateLimiter) Allow(ctx context.Context, config RateLimitConfig, key string) (*RateLimitResult, error) {
+	script := slidingWindowScript
+	args := []interface{}{config.Limit, config.Window.Milliseconds()}
+	switch config.Algorithm {
+	case TokenBucket:
+		script = tokenBucketScript
+	case SlidingWindow, "":
+		args = append(args, uuid.New().String())
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", config.Algorithm)
+	}
+
+	redisKey := fmt.Sprintf("%s:%s:%s:%s", l.prefix, config.Name, config.Algorithm, key)
+	values, err := script.Run(ctx, l.redis.Client, []string{redisKey}, args...).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate rate limit: %w", err)
+	}
+	if len(values) != 4 {
+		return nil, fmt.Errorf("unexpected rate limit result %v", values)
+	}
+
+	return &RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      config.Limit,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
+
+// enabled reports whether the limiter has a Redis connection
+func (l *RateLimiter) enabled() bool {
+	return l != nil && l.redis != nil
+}
+
+// RateLimit creates a rate limiting middleware for a route group. Limits are
+// reported with the RateLimit-* headers and rejected requests get a 429 with
+// Retry-After. If Redis is unavailable, requests are let through.
+func RateLimit(limiter *RateLimiter, config RateLimitConfig) gin.HandlerFunc {
+	if config.KeyFunc == nil {
+		config.KeyFunc = KeyByIP()
+	}
+	policy := fmt.Sprintf("%d;w=%d", config.Limit, int(config.Window.Seconds()))
+
+	return func(c *gin.Context) {
+		if !limiter.enabled() {
+			c.Next()
+			return
+		}
+
+		for _, path := range config.SkipPaths {
+			if c.Request.URL.Path == path {
+				c.Next()
+				return
+			}
+		}
+
+		result, err := limiter.Allow(c.Request.Context(), config, config.KeyFunc(c))
+		if err != nil {
+			logrus.WithError(err).WithField("rate_limit", config.Name).Warn("Rate limit check failed, allowing request")
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+		c.Header("RateLimit-Policy", policy)
+
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			httputil.TooManyRequests(c, "Rate limit exceeded")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
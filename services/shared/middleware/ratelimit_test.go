@@ -0,0 +1,210 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"unified-commerce/services/shared/database"
+	"unified-commerce/services/shared/middleware"
+)
+
+// newTestLimiter returns a rate limiter backed by an in-memory Redis whose
+// clock the test controls
+func newTestLimiter(t *testing.T) (*middleware.RateLimiter, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	server.SetTime(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return middleware.NewRateLimiter(&database.RedisClient{Client: client}), server
+}
+
+func TestRateLimiter_TokenBucketRefillsOverWindow(t *testing.T) {
+	limiter, server := newTestLimiter(t)
+	config := middleware.RateLimitConfig{Name: "test", Algorithm: middleware.TokenBucket, Limit: 3, Window: time.Minute}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow(ctx, config, "ip:1.2.3.4")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != 2-i {
+			t.Fatalf("request %d: allowed = %v, remaining = %d", i+1, result.Allowed, result.Remaining)
+		}
+	}
+
+	result, err := limiter.Allow(ctx, config, "ip:1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.RetryAfter != 20*time.Second {
+		t.Fatalf("empty bucket: allowed = %v, retry after = %s; want denied for 20s", result.Allowed, result.RetryAfter)
+	}
+
+	// One token refills every 20 seconds
+	server.SetTime(time.Date(2026, 1, 1, 12, 0, 20, 0, time.UTC))
+	if result, err = limiter.Allow(ctx, config, "ip:1.2.3.4"); err != nil || !result.Allowed {
+		t.Fatalf("after refill: allowed = %v, err = %v", result.Allowed, err)
+	}
+	if result, err = limiter.Allow(ctx, config, "ip:1.2.3.4"); err != nil || result.Allowed {
+		t.Fatalf("after spending the refill: allowed = %v, err = %v", result.Allowed, err)
+	}
+}
+
+func TestRateLimiter_SlidingWindowForgetsOldRequests(t *testing.T) {
+	limiter, server := newTestLimiter(t)
+	config := middleware.RateLimitConfig{Name: "test", Algorithm: middleware.SlidingWindow, Limit: 2, Window: time.Minute}
+	ctx := context.Background()
+
+	limiter.Allow(ctx, config, "ip:1.2.3.4")
+	server.SetTime(time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC))
+	limiter.Allow(ctx, config, "ip:1.2.3.4")
+
+	result, err := limiter.Allow(ctx, config, "ip:1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed || result.Remaining != 0 || result.RetryAfter != 30*time.Second {
+		t.Fatalf("full window: allowed = %v, remaining = %d, retry after = %s", result.Allowed, result.Remaining, result.RetryAfter)
+	}
+
+	// The first request leaves the window a minute after it was made
+	server.SetTime(time.Date(2026, 1, 1, 12, 1, 0, 1000000, time.UTC))
+	if result, err = limiter.Allow(ctx, config, "ip:1.2.3.4"); err != nil || !result.Allowed {
+		t.Fatalf("after the window slid: allowed = %v, err = %v", result.Allowed, err)
+	}
+}
+
+func TestRateLimiter_KeysAndGroupsAreLimitedSeparately(t *testing.T) {
+	limiter, _ := newTestLimiter(t)
+	public := middleware.RateLimitConfig{Name: "public", Algorithm: middleware.TokenBucket, Limit: 1, Window: time.Minute}
+	ctx := context.Background()
+
+	limiter.Allow(ctx, public, "ip:1.2.3.4")
+	if result, _ := limiter.Allow(ctx, public, "ip:5.6.7.8"); !result.Allowed {
+		t.Fatal("another client shares the first client's limit")
+	}
+	other := public
+	other.Name = "other"
+	if result, _ := limiter.Allow(ctx, other, "ip:1.2.3.4"); !result.Allowed {
+		t.Fatal("another route group shares the public limit")
+	}
+}
+
+func TestRateLimit_RejectsWithHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter, _ := newTestLimiter(t)
+
+	router := gin.New()
+	router.SetTrustedProxies(nil)
+	router.Use(middleware.RateLimit(limiter, middleware.RateLimitConfig{
+		Name: "test", Algorithm: middleware.TokenBucket, Limit: 1, Window: time.Minute,
+	}))
+	router.GET("/carts", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/carts", nil)
+		req.RemoteAddr = "1.2.3.4:5000"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send()
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("first request: status %d, headers %v", w.Code, w.Header())
+	}
+	if w.Header().Get("RateLimit-Policy") != "1;w=60" {
+		t.Fatalf("policy = %q, want 1;w=60", w.Header().Get("RateLimit-Policy"))
+	}
+
+	w = send()
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("second request: status %d, Retry-After %q; want 429 after 60s", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestKeyByIP_IgnoresForwardedForFromUntrustedClients(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter, _ := newTestLimiter(t)
+
+	router := gin.New()
+	if err := router.SetTrustedProxies([]string{"10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	router.Use(middleware.RateLimit(limiter, middleware.RateLimitConfig{
+		Name: "test", Algorithm: middleware.TokenBucket, Limit: 1, Window: time.Minute, KeyFunc: middleware.KeyByIP(),
+	}))
+	router.GET("/carts", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/carts", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A client rotating the header is still limited by its own address
+	send("1.2.3.4:5000", "9.9.9.1")
+	if code := send("1.2.3.4:5000", "9.9.9.2"); code != http.StatusTooManyRequests {
+		t.Fatalf("spoofed X-Forwarded-For bypassed the limit: status %d", code)
+	}
+
+	// Behind the trusted proxy, clients are told apart by the header
+	if code := send("10.0.0.1:5000", "5.6.7.8"); code != http.StatusOK {
+		t.Fatalf("first client behind the proxy: status %d", code)
+	}
+	if code := send("10.0.0.1:5000", "5.6.7.9"); code != http.StatusOK {
+		t.Fatalf("second client behind the proxy: status %d", code)
+	}
+}
+
+func TestKeyByMerchantID_FallsBackToClientIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keyFunc := middleware.KeyByMerchantID()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/orders", nil)
+	c.Request.RemoteAddr = "1.2.3.4:5000"
+	if key := keyFunc(c); key != "ip:1.2.3.4" {
+		t.Fatalf("anonymous key = %q, want ip:1.2.3.4", key)
+	}
+
+	c.Set("merchant_id", "merchant-1")
+	if key := keyFunc(c); key != "merchant:merchant-1" {
+		t.Fatalf("merchant key = %q, want merchant:merchant-1", key)
+	}
+}
+
+func TestKeyByAPIKey_HashesTheKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keyFunc := middleware.KeyByAPIKey("X-API-Key")
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/products", nil)
+	c.Request.RemoteAddr = "1.2.3.4:5000"
+	if key := keyFunc(c); key != "ip:1.2.3.4" {
+		t.Fatalf("key without an API key = %q, want ip:1.2.3.4", key)
+	}
+
+	c.Request.Header.Set("X-API-Key", "sk_live_secret")
+	key := keyFunc(c)
+	if !strings.HasPrefix(key, "apikey:") || strings.Contains(key, "sk_live_secret") {
+		t.Fatalf("key = %q, want a hash of the API key", key)
+	}
+	c.Request.Header.Set("X-API-Key", "sk_live_other")
+	if other := keyFunc(c); other == key {
+		t.Fatal("different API keys share a limit")
+	}
+}
@@ -0,0 +1,196 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Counter is the last number allocated from a merchant's sequence in a
+// period
+type Counter struct {
+	MerchantID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Name       string    `gorm:"primaryKey;size:50"`
+	Period     string    `gorm:"primaryKey;size:20"`
+	Value      int64     `gorm:"not null"`
+	UpdatedAt  time.Time
+}
+
+// TableName places counters in the number_sequences table
+func (Counter) TableName() string {
+	return "number_sequences"
+}
+
+// NumberFormat is a merchant's format for one of its sequences
+type NumberFormat struct {
+	MerchantID  uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Name        string    `gorm:"primaryKey;size:50"`
+	Template    string    `gorm:"not null"`
+	Prefix      string    `gorm:"size:20"`
+	Padding     int       `gorm:"not null;default:0"`
+	ResetYearly bool      `gorm:"not null;default:false"`
+	UpdatedAt   time.Time
+}
+
+// TableName places formats in the number_formats table
+func (NumberFormat) TableName() string {
+	return "number_formats"
+}
+
+// Format returns the format the row stores
+func (f *NumberFormat) Format() Format {
+	return Format{Template: f.Template, Prefix: f.Prefix, Padding: f.Padding, ResetYearly: f.ResetYearly}
+}
+
+// Models lists the tables a service numbering documents needs, for its
+// migrations
+func Models() []interface{} {
+	return []interface{}{&Counter{}, &NumberFormat{}}
+}
+
+// PostgresStore keeps formats and counters in PostgreSQL. Give it the
+// transaction that stores the numbered document so the number is only
+// used up if the document is.
+type PostgresStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresStore creates a PostgreSQL store
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Format returns the merchant's format for a sequence, or nil if the
+// merchant has not configured one
+func (s *PostgresStore) Format(ctx context.Context, merchantID uuid.UUID, name string) (*Format, error) {
+	var row NumberFormat
+	err := s.db.WithContext(ctx).
+		Where("merchant_id = ? AND name = ?", merchantID, name).
+		First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	format := row.Format()
+	return &format, nil
+}
+
+// Increment advances the counter with an upsert, which locks its row until
+// the transaction ends
+func (s *PostgresStore) Increment(ctx context.Context, merchantID uuid.UUID, name, period string) (int64, error) {
+	counter := Counter{MerchantID: merchantID, Name: name, Period: period, Value: 1, UpdatedAt: time.Now()}
+	err := s.db.WithContext(ctx).
+		Clauses(
+			clause.OnConflict{
+				Columns: []clause.Column{{Name: "merchant_id"}, {Name: "name"}, {Name: "period"}},
+				DoUpdates: clause.Set{
+					{Column: clause.Column{Name: "value"}, Value: gorm.Expr("number_sequences.value + 1")},
+					{Column: clause.Column{Name: "updated_at"}, Value: counter.UpdatedAt},
+				},
+			},
+			clause.Returning{Columns: []clause.Column{{Name: "value"}}},
+		).
+		Create(&counter).Error
+	if err != nil {
+		return 0, err
+	}
+	return counter.Value, nil
+}
+
+// SaveFormats replaces a merchant's formats in one transaction. Sequences
+// missing from formats go back to their defaults. A sequence that starts or
+// stops resetting yearly carries its counter over to its new period, so it
+// does not number again from 1 and render numbers it already gave out.
+func (s *PostgresStore) SaveFormats(ctx context.Context, merchantID uuid.UUID, formats map[string]Format) error {
+	names := make([]string, 0, len(formats))
+	for name, format := range formats {
+		if err := format.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		names = append(names, name)
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []NumberFormat
+		if err := tx.Where("merchant_id = ?", merchantID).Find(&rows).Error; err != nil {
+			return err
+		}
+		saved := make(map[string]Format, len(rows))
+		for _, row := range rows {
+			saved[row.Name] = row.Format()
+		}
+
+		now := time.Now()
+		for _, name := range names {
+			format := formats[name]
+			row := NumberFormat{
+				MerchantID:  merchantID,
+				Name:        name,
+				Template:    format.Template,
+				Prefix:      format.Prefix,
+				Padding:     format.Padding,
+				ResetYearly: format.ResetYearly,
+				UpdatedAt:   now,
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "merchant_id"}, {Name: "name"}},
+				DoUpdates: clause.AssignmentColumns([]string{"template", "prefix", "padding", "reset_yearly", "updated_at"}),
+			}).Create(&row).Error; err != nil {
+				return err
+			}
+		}
+
+		stale := tx.Where("merchant_id = ?", merchantID)
+		if len(names) > 0 {
+			stale = stale.Where("name NOT IN ?", names)
+		}
+		if err := stale.Delete(&NumberFormat{}).Error; err != nil {
+			return err
+		}
+
+		sequences := make(map[string]bool, len(saved)+len(formats))
+		for name := range saved {
+			sequences[name] = true
+		}
+		for name := range formats {
+			sequences[name] = true
+		}
+		for name := range sequences {
+			from, to := effectiveFormat(saved, name), effectiveFormat(formats, name)
+			if err := carryCounter(tx, merchantID, name, from.Period(now), to.Period(now), now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// effectiveFormat returns the format of a sequence among formats, or its
+// default format if it is not among them
+func effectiveFormat(formats map[string]Format, name string) Format {
+	if format, ok := formats[name]; ok {
+		return format
+	}
+	return DefaultFormat(name)
+}
+
+// carryCounter raises the counter of a merchant's sequence in period to the
+// counter in from, if the periods differ
+func carryCounter(tx *gorm.DB, merchantID uuid.UUID, name, from, period string, at time.Time) error {
+	if from == period {
+		return nil
+	}
+	return tx.Exec(`INSERT INTO number_sequences (merchant_id, name, period, value, updated_at)
+		SELECT merchant_id, name, ?, value, ? FROM number_sequences
+		WHERE merchant_id = ? AND name = ? AND period = ?
+		ON CONFLICT (merchant_id, name, period)
+		DO UPDATE SET value = GREATEST(number_sequences.value, EXCLUDED.value), updated_at = EXCLUDED.updated_at`,
+		period, at, merchantID, name, from).Error
+}
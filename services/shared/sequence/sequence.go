@@ -0,0 +1,175 @@
+// Package sequence allocates the human-readable numbers of documents such as
+// orders, returns, stock transfers and invoices. Each merchant has its own
+// counter per sequence, rendered through a format template that merchants
+// can configure.
+//
+// Allocator takes its numbers from a Store. PostgresStore increments a
+// counter row inside the caller's transaction, so numbers are gap-free: a
+// rolled back document gives its number back, and concurrent allocations
+// for a merchant's sequence wait for each other.
+package sequence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sequence errors
+var (
+	ErrInvalidFormat = errors.New("invalid number format")
+)
+
+// Sequences the services number documents with
+const (
+	Order      = "order"
+	DraftOrder = "draft_order"
+	Return     = "return"
+	Transfer   = "transfer"
+	Invoice    = "invoice"
+)
+
+// Template tokens. A template must contain NumberToken.
+const (
+	PrefixToken = "{prefix}"
+	YearToken   = "{year}"
+	NumberToken = "{number}"
+)
+
+// maxPadding bounds the zero padding of numbers
+const maxPadding = 12
+
+// Format describes how a sequence's numbers are rendered, such as
+// "{prefix}-{year}-{number}" with prefix "INV" and padding 6 giving
+// INV-2026-000042. Sequences that reset yearly start again at 1 every
+// calendar year.
+type Format struct {
+	Template    string `json:"template"`
+	Prefix      string `json:"prefix"`
+	Padding     int    `json:"padding"`
+	ResetYearly bool   `json:"reset_yearly"`
+}
+
+// Defaults are the formats of sequences a merchant has not configured
+var Defaults = map[string]Format{
+	Order:      {Template: "{prefix}-{number}", Prefix: "ORD", Padding: 6},
+	DraftOrder: {Template: "{prefix}-{number}", Prefix: "DRF", Padding: 6},
+	Return:     {Template: "{prefix}-{year}-{number}", Prefix: "RET", Padding: 5, ResetYearly: true},
+	Transfer:   {Template: "{prefix}-{year}-{number}", Prefix: "TRF", Padding: 5, ResetYearly: true},
+	Invoice:    {Template: "{prefix}-{year}-{number}", Prefix: "INV", Padding: 6, ResetYearly: true},
+}
+
+// DefaultFormat returns the default format of a sequence, falling back to
+// the sequence's name as its prefix
+func DefaultFormat(name string) Format {
+	if format, ok := Defaults[name]; ok {
+		return format
+	}
+	return Format{Template: "{prefix}-{number}", Prefix: strings.ToUpper(name), Padding: 6}
+}
+
+// Validate checks the format can render unique numbers
+func (f Format) Validate() error {
+	if !strings.Contains(f.Template, NumberToken) {
+		return fmt.Errorf("%w: the template must contain %s", ErrInvalidFormat, NumberToken)
+	}
+	if f.ResetYearly && !strings.Contains(f.Template, YearToken) {
+		return fmt.Errorf("%w: a sequence that resets yearly must render %s", ErrInvalidFormat, YearToken)
+	}
+	if f.Padding < 0 || f.Padding > maxPadding {
+		return fmt.Errorf("%w: padding must be between 0 and %d", ErrInvalidFormat, maxPadding)
+	}
+	return nil
+}
+
+// Period returns the counter period a number allocated at a time belongs
+// to: its year for sequences that reset yearly, otherwise a single period
+func (f Format) Period(at time.Time) string {
+	if f.ResetYearly {
+		return strconv.Itoa(at.UTC().Year())
+	}
+	return ""
+}
+
+// Render renders the nth number of the sequence allocated at a time
+func (f Format) Render(n int64, at time.Time) string {
+	number := fmt.Sprintf("%0*d", f.Padding, n)
+	return strings.NewReplacer(
+		PrefixToken, f.Prefix,
+		YearToken, strconv.Itoa(at.UTC().Year()),
+		NumberToken, number,
+	).Replace(f.Template)
+}
+
+// NamedFormat is a format with the sequence it numbers, the way events
+// carry a merchant's formats
+type NamedFormat struct {
+	Sequence string `json:"sequence"`
+	Format
+}
+
+// Named lists formats with their sequences, sorted by sequence
+func Named(formats map[string]Format) []NamedFormat {
+	named := make([]NamedFormat, 0, len(formats))
+	for name, format := range formats {
+		named = append(named, NamedFormat{Sequence: name, Format: format})
+	}
+	sort.Slice(named, func(i, j int) bool { return named[i].Sequence < named[j].Sequence })
+	return named
+}
+
+// ByName indexes named formats by their sequence
+func ByName(named []NamedFormat) map[string]Format {
+	formats := make(map[string]Format, len(named))
+	for _, format := range named {
+		formats[format.Sequence] = format.Format
+	}
+	return formats
+}
+
+// Store keeps merchants' formats and counters
+type Store interface {
+	// Format returns the merchant's format for a sequence, or nil if the
+	// merchant has not configured one
+	Format(ctx context.Context, merchantID uuid.UUID, name string) (*Format, error)
+	// Increment advances the merchant's counter for a sequence and period
+	// and returns its new value, starting at 1
+	Increment(ctx context.Context, merchantID uuid.UUID, name, period string) (int64, error)
+}
+
+// Allocator allocates numbers from a store's counters
+type Allocator struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewAllocator creates an allocator
+func NewAllocator(store Store) *Allocator {
+	return &Allocator{store: store, now: time.Now}
+}
+
+// Next allocates the merchant's next number of a sequence, rendered with
+// the merchant's format or, if it has none, the sequence's default format
+func (a *Allocator) Next(ctx context.Context, merchantID uuid.UUID, name string) (string, error) {
+	format, err := a.store.Format(ctx, merchantID, name)
+	if err != nil {
+		return "", err
+	}
+	if format == nil {
+		defaultFormat := DefaultFormat(name)
+		format = &defaultFormat
+	}
+
+	at := a.now()
+	n, err := a.store.Increment(ctx, merchantID, name, format.Period(at))
+	if err != nil {
+		return "", fmt.Errorf("failed to allocate %s number: %w", name, err)
+	}
+	return format.Render(n, at), nil
+}
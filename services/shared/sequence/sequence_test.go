@@ -0,0 +1,234 @@
+package sequence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/shared/database"
+	"unified-commerce/services/shared/database/dbtest"
+)
+
+// memoryStore keeps counters in memory, serializing increments the way the
+// counter row lock does in PostgreSQL
+type memoryStore struct {
+	mu       sync.Mutex
+	formats  map[string]Format
+	counters map[string]int64
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{formats: map[string]Format{}, counters: map[string]int64{}}
+}
+
+func (s *memoryStore) Format(ctx context.Context, merchantID uuid.UUID, name string) (*Format, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	format, ok := s.formats[merchantID.String()+"/"+name]
+	if !ok {
+		return nil, nil
+	}
+	return &format, nil
+}
+
+func (s *memoryStore) Increment(ctx context.Context, merchantID uuid.UUID, name, period string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := fmt.Sprintf("%s/%s/%s", merchantID, name, period)
+	s.counters[key]++
+	return s.counters[key], nil
+}
+
+func TestAllocator_ConcurrentAllocationIsGapFree(t *testing.T) {
+	allocator := NewAllocator(newMemoryStore())
+	merchants := []uuid.UUID{uuid.New(), uuid.New()}
+	const perMerchant = 200
+
+	var mu sync.Mutex
+	allocated := map[string]bool{}
+	var wg sync.WaitGroup
+	for _, merchantID := range merchants {
+		for i := 0; i < perMerchant; i++ {
+			wg.Add(1)
+			go func(merchantID uuid.UUID) {
+				defer wg.Done()
+				number, err := allocator.Next(context.Background(), merchantID, Order)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				key := merchantID.String() + "/" + number
+				if allocated[key] {
+					t.Errorf("number %s allocated twice", number)
+				}
+				allocated[key] = true
+			}(merchantID)
+		}
+	}
+	wg.Wait()
+
+	// Every merchant numbers its orders from 1 without gaps
+	for _, merchantID := range merchants {
+		for n := 1; n <= perMerchant; n++ {
+			number := fmt.Sprintf("ORD-%06d", n)
+			if !allocated[merchantID.String()+"/"+number] {
+				t.Fatalf("expected %s to be allocated for merchant %s", number, merchantID)
+			}
+		}
+	}
+}
+
+func TestAllocator_UsesMerchantFormatAndResetsYearly(t *testing.T) {
+	store := newMemoryStore()
+	merchantID := uuid.New()
+	store.formats[merchantID.String()+"/"+Invoice] = Format{Template: "{year}/{prefix}{number}", Prefix: "ACME", Padding: 3, ResetYearly: true}
+	allocator := NewAllocator(store)
+
+	at := time.Date(2025, time.December, 31, 23, 0, 0, 0, time.UTC)
+	allocator.now = func() time.Time { return at }
+	for _, want := range []string{"2025/ACME001", "2025/ACME002"} {
+		if got, _ := allocator.Next(context.Background(), merchantID, Invoice); got != want {
+			t.Errorf("expected %s, got %s", want, got)
+		}
+	}
+
+	at = at.Add(2 * time.Hour)
+	if got, _ := allocator.Next(context.Background(), merchantID, Invoice); got != "2026/ACME001" {
+		t.Errorf("expected the sequence to restart in the new year, got %s", got)
+	}
+	if got, _ := allocator.Next(context.Background(), uuid.New(), Invoice); got != "INV-2026-000001" {
+		t.Errorf("expected the default format for another merchant, got %s", got)
+	}
+}
+
+func TestFormat_Validate(t *testing.T) {
+	for _, format := range []Format{
+		{Template: "{prefix}-{year}", Prefix: "ORD"},
+		{Template: "{prefix}-{number}", ResetYearly: true},
+		{Template: "{number}", Padding: 40},
+	} {
+		if err := format.Validate(); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("expected %+v to be rejected, got %v", format, err)
+		}
+	}
+	for name, format := range Defaults {
+		if err := format.Validate(); err != nil {
+			t.Errorf("expected the default %s format to be valid, got %v", name, err)
+		}
+	}
+}
+
+// newTestPostgresStore returns a PostgreSQL store over a test database
+func newTestPostgresStore(t *testing.T) (*PostgresStore, *gorm.DB) {
+	t.Helper()
+	db := dbtest.Open(t, "sequence", []database.Migration{{
+		Version: 1,
+		Name:    "number_sequences",
+		Up:      database.AutoMigrateModels(Models()...),
+		Down:    database.DropModels(Models()...),
+	}})
+	return NewPostgresStore(db), db
+}
+
+func TestPostgresStore_ConcurrentIncrementIsGapFree(t *testing.T) {
+	_, db := newTestPostgresStore(t)
+	merchantID := uuid.New()
+	errRollback := errors.New("rollback")
+	const allocations = 60
+
+	var mu sync.Mutex
+	committed := map[int64]bool{}
+	var wg sync.WaitGroup
+	for i := 0; i < allocations; i++ {
+		wg.Add(1)
+		go func(rollback bool) {
+			defer wg.Done()
+			var n int64
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				if n, err = NewPostgresStore(tx).Increment(context.Background(), merchantID, Order, ""); err != nil {
+					return err
+				}
+				if rollback {
+					return errRollback
+				}
+				return nil
+			})
+			if rollback && errors.Is(err, errRollback) {
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if committed[n] {
+				t.Errorf("number %d committed twice", n)
+			}
+			committed[n] = true
+		}(i%3 == 0)
+	}
+	wg.Wait()
+
+	// Rolled back numbers are given back, so the committed ones run from 1
+	// without gaps
+	for n := int64(1); n <= int64(len(committed)); n++ {
+		if !committed[n] {
+			t.Fatalf("expected %d to be committed, got %v", n, committed)
+		}
+	}
+	if want := allocations - (allocations+2)/3; len(committed) != want {
+		t.Fatalf("expected %d numbers committed, got %d", want, len(committed))
+	}
+}
+
+func TestPostgresStore_SaveFormatsCarriesCounterAcrossPeriods(t *testing.T) {
+	store, _ := newTestPostgresStore(t)
+	ctx := context.Background()
+	merchantID := uuid.New()
+	allocator := NewAllocator(store)
+
+	yearly := Format{Template: "{prefix}-{year}-{number}", Prefix: "INV", Padding: 3, ResetYearly: true}
+	if err := store.SaveFormats(ctx, merchantID, map[string]Format{Invoice: yearly}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := allocator.Next(ctx, merchantID, Invoice); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Still rendering the year, the sequence must not start again at 1
+	continuous := yearly
+	continuous.ResetYearly = false
+	if err := store.SaveFormats(ctx, merchantID, map[string]Format{Invoice: continuous}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := allocator.Next(ctx, merchantID, Invoice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := continuous.Render(3, time.Now()); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+
+	// Nor when it goes back to resetting yearly
+	if err := store.SaveFormats(ctx, merchantID, map[string]Format{Invoice: yearly}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = allocator.Next(ctx, merchantID, Invoice); err != nil {
+		t.Fatal(err)
+	}
+	if want := yearly.Render(4, time.Now()); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
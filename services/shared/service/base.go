@@ -26,6 +26,7 @@ type BaseService struct {
 	PostgresDB   *database.PostgresDB
 	MongoDB      *database.MongoDB
 	RedisClient  *database.RedisClient
+	RateLimiter  *middleware.RateLimiter
 	Router       *gin.Engine
 	Server       *http.Server
 	HealthChecks []HealthCheck
@@ -169,12 +170,19 @@ func NewBaseService(opts ServiceOptions) (*BaseService, error) {
 	}
 
 	service.Router = gin.New()
+	// Only trust X-Forwarded-For from our own proxies, so clients cannot
+	// choose the IP they are rate limited by
+	if err := service.Router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
 
 	// Add global middleware
 	service.Router.Use(middleware.RequestID())
 	service.Router.Use(middleware.Logger())
 	service.Router.Use(middleware.Recovery())
 	service.Router.Use(middleware.CORS())
+	service.RateLimiter = middleware.NewRateLimiter(service.RedisClient)
+	service.Router.Use(middleware.RateLimit(service.RateLimiter, middleware.DefaultRateLimitConfig()))
 	if service.Tracer != nil {
 		service.Router.Use(otelgin.Middleware(opts.Name))
 	}
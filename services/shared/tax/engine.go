@@ -0,0 +1,224 @@
+package tax
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Rule is a tax levied in a jurisdiction. A rule applies to addresses in its
+// country and, when set, its province and postal codes starting with its
+// prefix, so a destination can owe a national, a provincial and a local tax
+// at once.
+//
+// Compound taxes are charged on the line plus the non-compound taxes and the
+// compound taxes of lower priority, otherwise taxes are charged on the line
+// alone.
+type Rule struct {
+	Country          string             `json:"country"`
+	Province         string             `json:"province,omitempty"`
+	PostalCodePrefix string             `json:"postal_code_prefix,omitempty"`
+	Title            string             `json:"title"`
+	Rate             float64            `json:"rate"`
+	ClassRates       map[string]float64 `json:"class_rates,omitempty"` // overrides Rate per tax class, 0 exempts
+	Compound         bool               `json:"compound,omitempty"`
+	Priority         int                `json:"priority,omitempty"`
+}
+
+// matches reports whether the rule applies to the address
+func (r *Rule) matches(address Address) bool {
+	if !strings.EqualFold(r.Country, strings.TrimSpace(address.Country)) {
+		return false
+	}
+	if r.Province != "" && !strings.EqualFold(r.Province, strings.TrimSpace(address.Province)) {
+		return false
+	}
+	if r.PostalCodePrefix != "" && !strings.HasPrefix(normalizePostalCode(address.PostalCode), normalizePostalCode(r.PostalCodePrefix)) {
+		return false
+	}
+	return true
+}
+
+// rateFor returns the rule's rate for the tax class
+func (r *Rule) rateFor(class string) float64 {
+	if class == ExemptClass {
+		return 0
+	}
+	if rate, ok := r.ClassRates[class]; ok {
+		return rate
+	}
+	return r.Rate
+}
+
+// validate checks the rule is usable
+func (r *Rule) validate() error {
+	if r.Country == "" || r.Title == "" {
+		return fmt.Errorf("%w: country and title are required", ErrInvalidRule)
+	}
+	if r.Rate < 0 || r.Rate >= 1 {
+		return fmt.Errorf("%w: %s rate %v is not a fraction", ErrInvalidRule, r.Title, r.Rate)
+	}
+	for class, rate := range r.ClassRates {
+		if rate < 0 || rate >= 1 {
+			return fmt.Errorf("%w: %s rate %v for %s is not a fraction", ErrInvalidRule, r.Title, rate, class)
+		}
+	}
+	return nil
+}
+
+// normalizePostalCode uppercases a postal code and strips its spaces and
+// dashes
+func normalizePostalCode(code string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.ToUpper(code))
+}
+
+// Engine calculates taxes from jurisdiction rules
+type Engine struct {
+	rules []Rule
+}
+
+// NewEngine creates an engine for the rules. An engine without rules charges
+// no tax.
+func NewEngine(rules []Rule) (*Engine, error) {
+	sorted := make([]Rule, len(rules))
+	copy(sorted, rules)
+	for i := range sorted {
+		if err := sorted[i].validate(); err != nil {
+			return nil, err
+		}
+		classRates := make(map[string]float64, len(sorted[i].ClassRates))
+		for name, rate := range sorted[i].ClassRates {
+			classRates[normalizeClass(name)] = rate
+		}
+		sorted[i].ClassRates = classRates
+	}
+
+	// Non-compound taxes first, then compound taxes by priority
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Compound != sorted[j].Compound {
+			return !sorted[i].Compound
+		}
+		return sorted[i].Priority < sorted[j].Priority
+	})
+
+	return &Engine{rules: sorted}, nil
+}
+
+// LoadRules reads rules from a JSON file holding an array of rules
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tax rules: %w", err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse tax rules %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// NewEngineFromFile creates an engine for the rules in the JSON file. An
+// empty path creates an engine without rules.
+func NewEngineFromFile(path string) (*Engine, error) {
+	if path == "" {
+		return NewEngine(nil)
+	}
+
+	rules, err := LoadRules(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewEngine(rules)
+}
+
+// Calculate implements Calculator
+func (e *Engine) Calculate(ctx context.Context, req *Request) (*Result, error) {
+	var rules []*Rule
+	for i := range e.rules {
+		if e.rules[i].matches(req.Address) {
+			rules = append(rules, &e.rules[i])
+		}
+	}
+
+	result := &Result{
+		Lines:            make([]LineResult, 0, len(req.Lines)),
+		PricesIncludeTax: req.PricesIncludeTax,
+	}
+	for _, line := range req.Lines {
+		if line.Amount < 0 {
+			return nil, fmt.Errorf("%w: %s has a negative amount", ErrInvalidLine, line.ID)
+		}
+
+		lineResult := calculateLine(line, rules, req.PricesIncludeTax)
+		result.TotalTax = Round(result.TotalTax + lineResult.Tax)
+		result.Lines = append(result.Lines, lineResult)
+	}
+	return result, nil
+}
+
+// calculateLine applies the matching rules to a line. With tax-inclusive
+// prices the net amount is backed out of the line amount first, and the
+// rounded taxes and net always add up to the line amount.
+func calculateLine(line Line, rules []*Rule, pricesIncludeTax bool) LineResult {
+	result := LineResult{ID: line.ID, Net: line.Amount}
+	if !line.Taxable || line.Amount == 0 {
+		return result
+	}
+
+	class := normalizeClass(line.TaxClass)
+	type applied struct {
+		rule *Rule
+		rate float64
+	}
+	var taxes []applied
+	factor, simple := 1.0, 0.0
+	for _, rule := range rules {
+		rate := rule.rateFor(class)
+		if rate == 0 {
+			continue
+		}
+		taxes = append(taxes, applied{rule: rule, rate: rate})
+		if rule.Compound {
+			factor *= 1 + rate
+		} else {
+			simple += rate
+		}
+	}
+	if len(taxes) == 0 {
+		return result
+	}
+	factor *= 1 + simple
+
+	net := line.Amount
+	if pricesIncludeTax {
+		net = line.Amount / factor
+	}
+
+	base := net
+	for _, tax := range taxes {
+		amount := net * tax.rate
+		if tax.rule.Compound {
+			amount = base * tax.rate
+		}
+		base += amount
+
+		amount = Round(amount)
+		result.Tax += amount
+		result.TaxLines = append(result.TaxLines, TaxLine{
+			Title:    tax.rule.Title,
+			Rate:     tax.rate,
+			Amount:   amount,
+			Compound: tax.rule.Compound,
+		})
+	}
+	result.Tax = Round(result.Tax)
+
+	if pricesIncludeTax {
+		result.Net = Round(line.Amount - result.Tax)
+	}
+	return result
+}
@@ -0,0 +1,119 @@
+package tax
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func testEngine(t *testing.T) *Engine {
+	t.Helper()
+	engine, err := NewEngine([]Rule{
+		{Country: "US", Province: "CA", Title: "CA State Tax", Rate: 0.0725, ClassRates: map[string]float64{"shipping": 0}},
+		{Country: "US", Province: "CA", PostalCodePrefix: "941", Title: "San Francisco Tax", Rate: 0.0138},
+		{Country: "DE", Title: "MwSt", Rate: 0.19, ClassRates: map[string]float64{"Reduced": 0.07}},
+		{Country: "CA", Province: "QC", Title: "QST", Rate: 0.095, Compound: true},
+		{Country: "CA", Title: "GST", Rate: 0.05},
+	})
+	if err != nil {
+		t.Fatalf("failed to create engine: %v", err)
+	}
+	return engine
+}
+
+func calculate(t *testing.T, engine *Engine, req *Request) *Result {
+	t.Helper()
+	result, err := engine.Calculate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return result
+}
+
+func TestEngine_StacksJurisdictionsByPostalCode(t *testing.T) {
+	engine := testEngine(t)
+	lines := []Line{
+		{ID: "item", Amount: 100, Taxable: true},
+		{ID: "shipping", TaxClass: ShippingClass, Amount: 10, Taxable: true},
+		{ID: "gift-card", Amount: 50, Taxable: false},
+	}
+
+	sf := calculate(t, engine, &Request{Address: Address{Country: "us", Province: "ca", PostalCode: "94103"}, Lines: lines})
+	if got := sf.Line("item"); got.Tax != 8.63 || len(got.TaxLines) != 2 {
+		t.Errorf("expected state and city tax of 8.63, got %v in %d lines", got.Tax, len(got.TaxLines))
+	}
+	if got := sf.Line("shipping"); got.Tax != 0.14 || len(got.TaxLines) != 1 {
+		t.Errorf("expected only city tax on shipping, got %v", got.TaxLines)
+	}
+	if got := sf.Line("gift-card"); got.Tax != 0 || got.Net != 50 {
+		t.Errorf("expected untaxed line, got %+v", got)
+	}
+	if sf.TotalTax != 8.77 {
+		t.Errorf("expected total tax 8.77, got %v", sf.TotalTax)
+	}
+
+	la := calculate(t, engine, &Request{Address: Address{Country: "US", Province: "CA", PostalCode: "90012"}, Lines: lines})
+	if got := la.Line("item"); got.Tax != 7.25 {
+		t.Errorf("expected state tax only outside San Francisco, got %v", got.Tax)
+	}
+
+	none := calculate(t, engine, &Request{Address: Address{Country: "US", Province: "OR"}, Lines: lines})
+	if none.TotalTax != 0 {
+		t.Errorf("expected no tax without matching rules, got %v", none.TotalTax)
+	}
+}
+
+func TestEngine_TaxInclusivePricesAndClasses(t *testing.T) {
+	result := calculate(t, testEngine(t), &Request{
+		Address:          Address{Country: "DE"},
+		PricesIncludeTax: true,
+		Lines: []Line{
+			{ID: "standard", Amount: 119, Taxable: true},
+			{ID: "book", TaxClass: "reduced", Amount: 10.70, Taxable: true},
+			{ID: "exempt", TaxClass: ExemptClass, Amount: 20, Taxable: true},
+		},
+	})
+
+	if got := result.Line("standard"); got.Net != 100 || got.Tax != 19 {
+		t.Errorf("expected 100 net and 19 tax, got %+v", got)
+	}
+	if got := result.Line("book"); got.Net != 10 || got.Tax != 0.70 {
+		t.Errorf("expected reduced rate, got %+v", got)
+	}
+	if got := result.Line("exempt"); got.Tax != 0 || got.Net != 20 {
+		t.Errorf("expected exempt line, got %+v", got)
+	}
+}
+
+func TestEngine_CompoundTaxes(t *testing.T) {
+	engine := testEngine(t)
+
+	exclusive := calculate(t, engine, &Request{
+		Address: Address{Country: "CA", Province: "QC"},
+		Lines:   []Line{{ID: "item", Amount: 100, Taxable: true}},
+	}).Line("item")
+	// GST applies to the price, QST to the price plus GST
+	if len(exclusive.TaxLines) != 2 || exclusive.TaxLines[0].Title != "GST" || exclusive.TaxLines[1].Amount != 9.98 {
+		t.Errorf("expected GST then compound QST of 9.98, got %+v", exclusive.TaxLines)
+	}
+
+	inclusive := calculate(t, engine, &Request{
+		Address:          Address{Country: "CA", Province: "QC"},
+		PricesIncludeTax: true,
+		Lines:            []Line{{ID: "item", Amount: 114.98, Taxable: true}},
+	}).Line("item")
+	if inclusive.Net != 100 || inclusive.Tax != 14.98 {
+		t.Errorf("expected 100 net and 14.98 tax, got %+v", inclusive)
+	}
+}
+
+func TestEngine_Validation(t *testing.T) {
+	if _, err := NewEngine([]Rule{{Country: "US", Title: "Percent", Rate: 7.25}}); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("expected rates given as percentages to be rejected, got %v", err)
+	}
+
+	_, err := testEngine(t).Calculate(context.Background(), &Request{Lines: []Line{{ID: "refund", Amount: -1, Taxable: true}}})
+	if !errors.Is(err, ErrInvalidLine) {
+		t.Errorf("expected negative amounts to be rejected, got %v", err)
+	}
+}
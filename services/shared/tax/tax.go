@@ -0,0 +1,104 @@
+// Package tax calculates the taxes charged on orders and carts. Services
+// depend on the Calculator interface; Engine implements it with local
+// jurisdiction rules, and an external tax provider can be plugged in by
+// implementing it as well.
+package tax
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+)
+
+// Tax errors
+var (
+	ErrInvalidLine = errors.New("invalid tax line")
+	ErrInvalidRule = errors.New("invalid tax rule")
+)
+
+// Tax classes with a built-in meaning. Any other class is matched against
+// the class rates of the rules.
+const (
+	// StandardClass is the class of products without a tax class
+	StandardClass = "standard"
+	// ShippingClass is the class of shipping charges
+	ShippingClass = "shipping"
+	// ExemptClass is never taxed
+	ExemptClass = "exempt"
+)
+
+// Address is the destination taxes are calculated for
+type Address struct {
+	Country    string `json:"country"`
+	Province   string `json:"province"`
+	PostalCode string `json:"postal_code"`
+}
+
+// Line is a taxable amount, such as a line item or a shipping charge
+type Line struct {
+	ID       string  `json:"id"`
+	TaxClass string  `json:"tax_class"`
+	Amount   float64 `json:"amount"` // after discounts; includes tax when prices include tax
+	Taxable  bool    `json:"taxable"`
+}
+
+// Request asks for the taxes of lines shipped to an address
+type Request struct {
+	Address          Address `json:"address"`
+	PricesIncludeTax bool    `json:"prices_include_tax"`
+	Lines            []Line  `json:"lines"`
+}
+
+// TaxLine is a single tax charged on a line
+type TaxLine struct {
+	Title    string  `json:"title"`
+	Rate     float64 `json:"rate"`
+	Amount   float64 `json:"amount"`
+	Compound bool    `json:"compound"`
+}
+
+// LineResult holds the taxes of a line. Net is the line's amount without
+// tax, so Net + Tax is what the customer pays for it.
+type LineResult struct {
+	ID       string    `json:"id"`
+	Net      float64   `json:"net"`
+	Tax      float64   `json:"tax"`
+	TaxLines []TaxLine `json:"tax_lines"`
+}
+
+// Result holds the taxes of every line of a request, in request order
+type Result struct {
+	Lines            []LineResult `json:"lines"`
+	TotalTax         float64      `json:"total_tax"`
+	PricesIncludeTax bool         `json:"prices_include_tax"`
+}
+
+// Line returns the result of the line with the ID, or nil
+func (r *Result) Line(id string) *LineResult {
+	for i := range r.Lines {
+		if r.Lines[i].ID == id {
+			return &r.Lines[i]
+		}
+	}
+	return nil
+}
+
+// Calculator calculates the taxes of a request
+type Calculator interface {
+	Calculate(ctx context.Context, req *Request) (*Result, error)
+}
+
+// Round rounds an amount to cents, half away from zero
+func Round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// normalizeClass maps an empty tax class to the standard class
+func normalizeClass(class string) string {
+	class = strings.ToLower(strings.TrimSpace(class))
+	if class == "" {
+		return StandardClass
+	}
+	return class
+}
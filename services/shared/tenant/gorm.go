@@ -0,0 +1,81 @@
+package tenant
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MerchantColumn is the column tenant-owned tables store their merchant in
+const MerchantColumn = "merchant_id"
+
+// Scope restricts a query to rows of the context's merchant:
+//
+//	r.db.WithContext(ctx).Scopes(tenant.Scope(ctx)).First(&order, "id = ?", id)
+//
+// Without a merchant the query fails with ErrNoTenant unless the context was
+// marked WithoutScope.
+func Scope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		merchantID, err := resolve(ctx)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		if merchantID == "" {
+			return db
+		}
+		return db.Where(clause.Eq{
+			Column: clause.Column{Table: clause.CurrentTable, Name: MerchantColumn},
+			Value:  merchantID,
+		})
+	}
+}
+
+// ScopeOrShared restricts a query to rows of the context's merchant and rows
+// without a merchant, which every merchant may use, such as payment methods
+// a customer saved to their own wallet.
+func ScopeOrShared(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		merchantID, err := resolve(ctx)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		if merchantID == "" {
+			return db
+		}
+		column := clause.Column{Table: clause.CurrentTable, Name: MerchantColumn}
+		return db.Where(clause.Or(
+			clause.Eq{Column: column, Value: merchantID},
+			clause.Eq{Column: column, Value: nil},
+		))
+	}
+}
+
+// ScopeThrough restricts a query on a table without its own merchant column
+// to rows whose parent belongs to the context's merchant, for example
+// inventory items through their location:
+//
+//	db.Scopes(tenant.ScopeThrough(ctx, "location_id", "locations"))
+func ScopeThrough(ctx context.Context, foreignKey, parentTable string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		merchantID, err := resolve(ctx)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		if merchantID == "" {
+			return db
+		}
+		parents := db.Session(&gorm.Session{NewDB: true}).
+			Table(parentTable).
+			Select("id").
+			Where(clause.Eq{Column: clause.Column{Name: MerchantColumn}, Value: merchantID})
+		return db.Where(clause.Expr{
+			SQL:  "? IN (?)",
+			Vars: []interface{}{clause.Column{Table: clause.CurrentTable, Name: foreignKey}, parents},
+		})
+	}
+}
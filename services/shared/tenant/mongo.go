@@ -0,0 +1,26 @@
+package tenant
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Filter adds the context's merchant to a MongoDB filter. The filter passed
+// in is not modified. Without a merchant it returns ErrNoTenant unless the
+// context was marked WithoutScope.
+func Filter(ctx context.Context, filter bson.M) (bson.M, error) {
+	merchantID, err := resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scoped := make(bson.M, len(filter)+1)
+	for key, value := range filter {
+		scoped[key] = value
+	}
+	if merchantID != "" {
+		scoped[MerchantColumn] = merchantID
+	}
+	return scoped, nil
+}
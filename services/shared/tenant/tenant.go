@@ -0,0 +1,71 @@
+// Package tenant carries the merchant a request acts on through
+// context.Context and scopes database queries to it, so repositories cannot
+// return another merchant's records.
+package tenant
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoTenant is returned by scoped queries run without a merchant in the
+// context. Queries fail closed rather than returning every merchant's data.
+var ErrNoTenant = errors.New("no merchant in context")
+
+// ErrForeignMerchant is returned when a request names a merchant other than
+// the one it is scoped to
+var ErrForeignMerchant = errors.New("merchant does not match the request's merchant")
+
+type merchantContextKey struct{}
+type unscopedContextKey struct{}
+
+// WithMerchantID returns a context scoped to the merchant
+func WithMerchantID(ctx context.Context, merchantID string) context.Context {
+	return context.WithValue(ctx, merchantContextKey{}, merchantID)
+}
+
+// MerchantID returns the merchant the context is scoped to
+func MerchantID(ctx context.Context) (string, bool) {
+	merchantID, _ := ctx.Value(merchantContextKey{}).(string)
+	return merchantID, merchantID != ""
+}
+
+// OwnerMerchantID returns the merchant records created under ctx belong to,
+// which is always the context's merchant. A requested merchant, for example
+// from a GraphQL input, must name the same merchant.
+func OwnerMerchantID(ctx context.Context, requested string) (string, error) {
+	merchantID, ok := MerchantID(ctx)
+	if !ok {
+		return "", ErrNoTenant
+	}
+	if requested != "" && requested != merchantID {
+		return "", ErrForeignMerchant
+	}
+	return merchantID, nil
+}
+
+// WithoutScope returns a context whose queries are not scoped to a merchant.
+// It is meant for event consumers, background jobs and platform admins that
+// legitimately work across merchants; a merchant set on the context still
+// takes precedence.
+func WithoutScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unscopedContextKey{}, true)
+}
+
+// IsUnscoped reports whether the context opted out of merchant scoping
+func IsUnscoped(ctx context.Context) bool {
+	unscoped, _ := ctx.Value(unscopedContextKey{}).(bool)
+	return unscoped
+}
+
+// resolve returns the merchant to scope to. An empty merchant with a nil
+// error means the context is unscoped.
+func resolve(ctx context.Context) (string, error) {
+	if merchantID, ok := MerchantID(ctx); ok {
+		return merchantID, nil
+	}
+	if IsUnscoped(ctx) {
+		return "", nil
+	}
+	return "", ErrNoTenant
+}
@@ -0,0 +1,155 @@
+package tenant_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"unified-commerce/services/shared/tenant"
+)
+
+type order struct {
+	ID         string
+	MerchantID string
+}
+
+type inventoryItem struct {
+	ID         string
+	LocationID string
+}
+
+const (
+	merchantA = "11111111-1111-1111-1111-111111111111"
+	merchantB = "22222222-2222-2222-2222-222222222222"
+)
+
+// newDryRunDB returns a postgres GORM handle that builds SQL without a
+// database connection
+func newDryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("open dry run db: %v", err)
+	}
+	return db
+}
+
+func TestScope_ConstrainsQueryToContextMerchant(t *testing.T) {
+	ctx := tenant.WithMerchantID(context.Background(), merchantA)
+
+	stmt := newDryRunDB(t).WithContext(ctx).Scopes(tenant.Scope(ctx)).
+		First(&order{}, "id = ?", "order-owned-by-b").Statement
+
+	sql := stmt.SQL.String()
+	if !strings.Contains(sql, `"orders"."merchant_id" = $`) {
+		t.Fatalf("query is not scoped to the merchant: %s", sql)
+	}
+	if !containsVar(stmt.Vars, merchantA) || containsVar(stmt.Vars, merchantB) {
+		t.Fatalf("query vars = %v, want merchant %s only", stmt.Vars, merchantA)
+	}
+}
+
+func TestScope_RejectsQueryWithoutMerchant(t *testing.T) {
+	ctx := context.Background()
+
+	err := newDryRunDB(t).WithContext(ctx).Scopes(tenant.Scope(ctx)).
+		First(&order{}, "id = ?", "any-order").Error
+	if !errors.Is(err, tenant.ErrNoTenant) {
+		t.Fatalf("err = %v, want ErrNoTenant", err)
+	}
+}
+
+func TestScope_WithoutScopeSkipsConstraint(t *testing.T) {
+	ctx := tenant.WithoutScope(context.Background())
+
+	stmt := newDryRunDB(t).WithContext(ctx).Scopes(tenant.Scope(ctx)).
+		First(&order{}, "id = ?", "any-order").Statement
+	if stmt.Error != nil {
+		t.Fatalf("unexpected error: %v", stmt.Error)
+	}
+	if strings.Contains(stmt.SQL.String(), "merchant_id") {
+		t.Fatalf("unscoped query is constrained: %s", stmt.SQL.String())
+	}
+}
+
+func TestScopeOrShared_AllowsRowsWithoutMerchant(t *testing.T) {
+	ctx := tenant.WithMerchantID(context.Background(), merchantA)
+
+	stmt := newDryRunDB(t).WithContext(ctx).Scopes(tenant.ScopeOrShared(ctx)).
+		First(&order{}, "id = ?", "shared-order").Statement
+
+	sql := stmt.SQL.String()
+	want := `("orders"."merchant_id" = $2 OR "orders"."merchant_id" IS NULL)`
+	if !strings.Contains(sql, want) {
+		t.Fatalf("query is not scoped to the merchant and shared rows: %s", sql)
+	}
+	if !containsVar(stmt.Vars, merchantA) {
+		t.Fatalf("query vars = %v, want merchant %s", stmt.Vars, merchantA)
+	}
+}
+
+func TestScopeThrough_ConstrainsQueryThroughParent(t *testing.T) {
+	ctx := tenant.WithMerchantID(context.Background(), merchantA)
+
+	stmt := newDryRunDB(t).WithContext(ctx).Scopes(tenant.ScopeThrough(ctx, "location_id", "locations")).
+		First(&inventoryItem{}, "id = ?", "item-at-location-of-b").Statement
+
+	sql := stmt.SQL.String()
+	want := `"inventory_items"."location_id" IN (SELECT id FROM "locations" WHERE "merchant_id" = $`
+	if !strings.Contains(sql, want) {
+		t.Fatalf("query is not scoped through locations: %s", sql)
+	}
+	if !containsVar(stmt.Vars, merchantA) {
+		t.Fatalf("query vars = %v, want merchant %s", stmt.Vars, merchantA)
+	}
+}
+
+func TestFilter_AddsMerchantWithoutMutatingInput(t *testing.T) {
+	ctx := tenant.WithMerchantID(context.Background(), merchantA)
+	filter := bson.M{"_id": "product-1", "merchant_id": merchantB}
+
+	scoped, err := tenant.Filter(ctx, filter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if scoped["merchant_id"] != merchantA {
+		t.Fatalf("merchant_id = %v, want %s", scoped["merchant_id"], merchantA)
+	}
+	if filter["merchant_id"] != merchantB {
+		t.Fatalf("input filter was modified: %v", filter)
+	}
+}
+
+func TestFilter_RejectsContextWithoutMerchant(t *testing.T) {
+	if _, err := tenant.Filter(context.Background(), bson.M{"_id": "product-1"}); !errors.Is(err, tenant.ErrNoTenant) {
+		t.Fatalf("err = %v, want ErrNoTenant", err)
+	}
+}
+
+func TestOwnerMerchantID_RejectsForeignMerchant(t *testing.T) {
+	ctx := tenant.WithMerchantID(context.Background(), merchantA)
+
+	if merchantID, err := tenant.OwnerMerchantID(ctx, ""); err != nil || merchantID != merchantA {
+		t.Fatalf("merchant = %q, err = %v; want %s", merchantID, err, merchantA)
+	}
+	if _, err := tenant.OwnerMerchantID(ctx, merchantB); !errors.Is(err, tenant.ErrForeignMerchant) {
+		t.Fatalf("err = %v, want ErrForeignMerchant", err)
+	}
+	if _, err := tenant.OwnerMerchantID(tenant.WithoutScope(context.Background()), merchantB); !errors.Is(err, tenant.ErrNoTenant) {
+		t.Fatalf("err = %v, want ErrNoTenant for an unscoped context", err)
+	}
+}
+
+func containsVar(vars []interface{}, want string) bool {
+	for _, v := range vars {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
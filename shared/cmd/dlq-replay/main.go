@@ -0,0 +1,140 @@
+// Command dlq-replay moves messages from a dead-letter topic back onto the
+// topic they originally failed on.
+//
+// Usage:
+//
+//	dlq-replay -topic orders.placed [-brokers localhost:9092] [-group dlq-replay] [-max 0] [-idle 10s] [-dry-run]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"unified-commerce/shared/messaging"
+)
+
+func main() {
+	brokers := flag.String("brokers", envOrDefault("KAFKA_BROKERS", "localhost:9092"), "comma-separated Kafka brokers")
+	topic := flag.String("topic", "", "source topic whose dead letters should be replayed (e.g. orders.placed)")
+	group := flag.String("group", "dlq-replay", "consumer group used to track replay progress")
+	max := flag.Int("max", 0, "maximum number of messages to replay (0 = all)")
+	idle := flag.Duration("idle", 10*time.Second, "stop after no message arrives for this long")
+	dryRun := flag.Bool("dry-run", false, "print dead letters without replaying them")
+	flag.Parse()
+
+	if *topic == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	sourceTopic := strings.TrimSuffix(*topic, ".dlq")
+	dlqTopic := messaging.DeadLetterTopic(sourceTopic)
+	brokerList := strings.Split(*brokers, ",")
+
+	// A dry run commits nothing, but reads through a throwaway group so it
+	// does not trigger a rebalance of a replay in progress
+	groupID := *group
+	if *dryRun {
+		groupID = fmt.Sprintf("%s-dry-run-%d", *group, time.Now().Unix())
+	}
+
+	consumer, err := messaging.NewSaramaConsumer(brokerList, groupID, []string{dlqTopic}, true)
+	if err != nil {
+		log.Fatalf("Failed to create consumer: %v", err)
+	}
+
+	producer, err := messaging.NewSaramaProducer(brokerList)
+	if err != nil {
+		log.Fatalf("Failed to create producer: %v", err)
+	}
+	defer producer.Close()
+
+	if err := consumer.Subscribe([]string{dlqTopic}); err != nil {
+		log.Fatalf("Failed to subscribe to %s: %v", dlqTopic, err)
+	}
+
+	messages := make(chan *messaging.Message)
+	go func() {
+		for {
+			msg, err := consumer.ReadMessage()
+			if err != nil {
+				close(messages)
+				return
+			}
+			messages <- msg
+		}
+	}()
+
+	replayed, skipped := 0, 0
+	var replayErr error
+	for *max == 0 || replayed < *max {
+		var msg *messaging.Message
+		select {
+		case m, ok := <-messages:
+			if !ok {
+				break
+			}
+			msg = m
+		case <-time.After(*idle):
+		}
+		if msg == nil {
+			break
+		}
+
+		letter, err := messaging.UnmarshalDeadLetter(msg.Value)
+		if err != nil {
+			log.Printf("Skipping unreadable dead letter at offset %d: %v", msg.Offset, err)
+			skipped++
+			// It can never be replayed, so do not read it again
+			if !*dryRun {
+				commit(consumer, msg)
+			}
+			continue
+		}
+
+		fmt.Printf("%s partition=%d offset=%d attempts=%d failed_at=%s error=%q\n",
+			letter.SourceTopic, letter.SourcePartition, letter.SourceOffset, letter.Attempts,
+			letter.FailedAt.Format(time.RFC3339), letter.Error)
+
+		if *dryRun {
+			continue
+		}
+
+		// The dead letter is only committed once it is back on its topic,
+		// so a failed replay leaves it to be read again
+		if err := messaging.ReplayDeadLetter(producer, letter); err != nil {
+			replayErr = err
+			break
+		}
+		commit(consumer, msg)
+		replayed++
+	}
+
+	// Closing the consumer commits the offsets marked so far
+	if err := consumer.Close(); err != nil {
+		log.Printf("Failed to close consumer: %v", err)
+	}
+	log.Printf("Replayed %d message(s) from %s to %s, skipped %d", replayed, dlqTopic, sourceTopic, skipped)
+	if replayErr != nil {
+		log.Fatalf("Stopping replay: %v", replayErr)
+	}
+}
+
+// commit marks a dead letter as consumed by the replay group
+func commit(consumer *messaging.SaramaConsumer, msg *messaging.Message) {
+	if err := consumer.CommitMessage(msg); err != nil {
+		log.Printf("Failed to commit dead letter at offset %d: %v", msg.Offset, err)
+	}
+}
+
+// envOrDefault returns the environment variable or the default value
+func envOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,254 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// MessageHandlerFunc adapts an ordinary function to the MessageHandler interface
+type MessageHandlerFunc func(msg *Message) error
+
+// HandleMessage calls f(msg)
+func (f MessageHandlerFunc) HandleMessage(msg *Message) error {
+	return f(msg)
+}
+
+// TopicHandlers dispatches messages to a handler by topic, letting one
+// consumer serve events published by several services
+type TopicHandlers map[string]MessageHandler
+
+// HandleMessage implements MessageHandler
+func (t TopicHandlers) HandleMessage(msg *Message) error {
+	return t.HandleMessageContext(context.Background(), msg)
+}
+
+// HandleMessageContext implements ContextMessageHandler, passing the
+// context on to handlers that accept one. Messages on unknown topics are
+// permanent failures.
+func (t TopicHandlers) HandleMessageContext(ctx context.Context, msg *Message) error {
+	handler, ok := t[msg.Topic]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for topic %s", msg.Topic))
+	}
+	if h, ok := handler.(ContextMessageHandler); ok {
+		return h.HandleMessageContext(ctx, msg)
+	}
+	return handler.HandleMessage(msg)
+}
+
+// Topics returns the topics with a handler
+func (t TopicHandlers) Topics() []string {
+	topics := make([]string, 0, len(t))
+	for topic := range t {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// permanentError marks a handler failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps an error to signal that the message should be dead-lettered
+// immediately instead of retried, e.g. because it cannot be decoded
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether the error was marked with Permanent
+func IsPermanent(err error) bool {
+	var perm *permanentError
+	return errors.As(err, &perm)
+}
+
+// RetryPolicy controls how often a failing message is retried before it is
+// sent to the dead-letter topic
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+}
+
+// DefaultRetryPolicy returns a default retry policy
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+// Backoff returns the delay before the given retry attempt (1-based)
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		delay *= p.Multiplier
+		if delay >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+	return time.Duration(delay)
+}
+
+// ConsumerRunner reads messages from a consumer and passes them to a handler.
+// Failed messages are retried with exponential backoff; once the retry policy
+// is exhausted, or the handler returns a Permanent error, the message is
+// published to <topic>.dlq with failure metadata. A message is only
+// committed once it has been handled or dead-lettered.
+type ConsumerRunner struct {
+	consumer EventConsumer
+	handler  MessageHandler
+	dlq      EventProducer
+	policy   RetryPolicy
+	groupID  string
+}
+
+// NewConsumerRunner creates a new consumer runner. The producer is used to
+// publish dead letters.
+func NewConsumerRunner(consumer EventConsumer, handler MessageHandler, dlq EventProducer, groupID string, policy RetryPolicy) *ConsumerRunner {
+	defaults := DefaultRetryPolicy()
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaults.MaxAttempts
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaults.InitialBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaults.MaxBackoff
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = defaults.Multiplier
+	}
+
+	return &ConsumerRunner{
+		consumer: consumer,
+		handler:  handler,
+		dlq:      dlq,
+		policy:   policy,
+		groupID:  groupID,
+	}
+}
+
+// Run subscribes to the topics and processes messages until the context is
+// cancelled or the consumer is closed
+func (r *ConsumerRunner) Run(ctx context.Context, topics []string) error {
+	if err := r.consumer.Subscribe(topics); err != nil {
+		return fmt.Errorf("failed to subscribe to topics %v: %w", topics, err)
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		msg, err := r.consumer.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("Consumer runner: failed to read message: %v", err)
+			if !sleepContext(ctx, time.Second) {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		if err := r.Process(ctx, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// Process handles a single message, retrying and dead-lettering as needed,
+// and commits it. It only returns an error if the context is cancelled
+// before the message could be handled or dead-lettered.
+func (r *ConsumerRunner) Process(ctx context.Context, msg *Message) error {
+	var handleErr error
+	attempts := 0
+
+	for attempts < r.policy.MaxAttempts {
+		attempts++
+		if handler, ok := r.handler.(ContextMessageHandler); ok {
+			handleErr = handler.HandleMessageContext(ctx, msg)
+		} else {
+			handleErr = r.handler.HandleMessage(msg)
+		}
+		if handleErr == nil || IsPermanent(handleErr) {
+			break
+		}
+
+		if attempts < r.policy.MaxAttempts {
+			backoff := r.policy.Backoff(attempts)
+			log.Printf("Consumer runner: failed to handle message from topic=%s partition=%d offset=%d (attempt %d/%d, retry in %s): %v",
+				msg.Topic, msg.Partition, msg.Offset, attempts, r.policy.MaxAttempts, backoff, handleErr)
+			if !sleepContext(ctx, backoff) {
+				return ctx.Err()
+			}
+		}
+	}
+
+	if handleErr != nil {
+		if err := r.deadLetter(ctx, msg, handleErr, attempts); err != nil {
+			return err
+		}
+	}
+
+	if err := r.consumer.CommitMessage(msg); err != nil {
+		log.Printf("Consumer runner: failed to commit message from topic=%s offset=%d: %v", msg.Topic, msg.Offset, err)
+	}
+	return nil
+}
+
+// deadLetter publishes the message to its dead-letter topic, retrying until
+// the publish succeeds so the message is never dropped
+func (r *ConsumerRunner) deadLetter(ctx context.Context, msg *Message, cause error, attempts int) error {
+	letter := NewDeadLetter(msg, r.groupID, cause, attempts)
+	payload, err := letter.Marshal()
+	if err != nil {
+		return err
+	}
+
+	topic := DeadLetterTopic(msg.Topic)
+	for retry := 1; ; retry++ {
+		err := r.dlq.Publish(topic, string(msg.Key), payload)
+		if err == nil {
+			log.Printf("Consumer runner: dead-lettered message from topic=%s offset=%d to %s after %d attempt(s): %v",
+				msg.Topic, msg.Offset, topic, attempts, cause)
+			return nil
+		}
+
+		backoff := r.policy.Backoff(retry)
+		log.Printf("Consumer runner: failed to publish dead letter to %s (retry in %s): %v", topic, backoff, err)
+		if !sleepContext(ctx, backoff) {
+			return ctx.Err()
+		}
+	}
+}
+
+// sleepContext waits for the duration and reports false if the context was
+// cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
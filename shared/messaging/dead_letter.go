@@ -0,0 +1,82 @@
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// deadLetterSuffix is appended to a topic name to form its dead-letter topic
+const deadLetterSuffix = ".dlq"
+
+// DeadLetterTopic returns the dead-letter topic for a source topic
+func DeadLetterTopic(topic string) string {
+	return topic + deadLetterSuffix
+}
+
+// IsDeadLetterTopic reports whether the topic is a dead-letter topic
+func IsDeadLetterTopic(topic string) bool {
+	return strings.HasSuffix(topic, deadLetterSuffix)
+}
+
+// DeadLetter is the payload published to a dead-letter topic. It keeps the
+// original message intact together with the reason it could not be handled.
+type DeadLetter struct {
+	SourceTopic     string    `json:"source_topic"`
+	SourcePartition int32     `json:"source_partition"`
+	SourceOffset    int64     `json:"source_offset"`
+	ConsumerGroup   string    `json:"consumer_group,omitempty"`
+	Key             []byte    `json:"key,omitempty"`
+	Payload         []byte    `json:"payload"`
+	Error           string    `json:"error"`
+	Permanent       bool      `json:"permanent"`
+	Attempts        int       `json:"attempts"`
+	FailedAt        time.Time `json:"failed_at"`
+}
+
+// NewDeadLetter records a failed message
+func NewDeadLetter(msg *Message, groupID string, cause error, attempts int) *DeadLetter {
+	return &DeadLetter{
+		SourceTopic:     msg.Topic,
+		SourcePartition: msg.Partition,
+		SourceOffset:    msg.Offset,
+		ConsumerGroup:   groupID,
+		Key:             msg.Key,
+		Payload:         msg.Value,
+		Error:           cause.Error(),
+		Permanent:       IsPermanent(cause),
+		Attempts:        attempts,
+		FailedAt:        time.Now().UTC(),
+	}
+}
+
+// Marshal serializes the dead letter for publishing
+func (d *DeadLetter) Marshal() ([]byte, error) {
+	payload, err := json.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+	return payload, nil
+}
+
+// UnmarshalDeadLetter parses a message consumed from a dead-letter topic
+func UnmarshalDeadLetter(payload []byte) (*DeadLetter, error) {
+	var letter DeadLetter
+	if err := json.Unmarshal(payload, &letter); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dead letter: %w", err)
+	}
+	if letter.SourceTopic == "" {
+		return nil, fmt.Errorf("dead letter has no source topic")
+	}
+	return &letter, nil
+}
+
+// ReplayDeadLetter publishes the original message of a dead letter back onto
+// its source topic
+func ReplayDeadLetter(producer EventProducer, letter *DeadLetter) error {
+	if err := producer.Publish(letter.SourceTopic, string(letter.Key), letter.Payload); err != nil {
+		return fmt.Errorf("failed to replay dead letter to %s: %w", letter.SourceTopic, err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Envelope errors
+var (
+	ErrNotEnvelope = errors.New("message is not an event envelope")
+)
+
+// Envelope wraps every event published to the event stream with the metadata
+// consumers need to route, validate and trace it
+type Envelope struct {
+	EventID       string          `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	Version       int             `json:"version"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	CausationID   string          `json:"causation_id,omitempty"`
+	MerchantID    string          `json:"merchant_id,omitempty"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// NewEnvelope creates an envelope for the given event data. The correlation
+// ID is taken from the context when present, otherwise the event starts a new
+// correlation chain.
+func NewEnvelope(ctx context.Context, eventType string, version int, merchantID string, data interface{}) (*Envelope, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s event data: %w", eventType, err)
+	}
+
+	env := &Envelope{
+		EventID:    uuid.New().String(),
+		EventType:  eventType,
+		Version:    version,
+		OccurredAt: time.Now().UTC(),
+		MerchantID: merchantID,
+		Data:       raw,
+	}
+
+	env.CorrelationID = CorrelationIDFromContext(ctx)
+	if env.CorrelationID == "" {
+		env.CorrelationID = env.EventID
+	}
+	env.CausationID = CausationIDFromContext(ctx)
+
+	return env, nil
+}
+
+// Marshal serializes the envelope for publishing
+func (e *Envelope) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// Decode unmarshals the event data into v
+func (e *Envelope) Decode(v interface{}) error {
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return fmt.Errorf("failed to decode %s v%d event data: %w", e.EventType, e.Version, err)
+	}
+	return nil
+}
+
+// Context returns a context carrying this event's correlation and causation
+// IDs, so events emitted while handling it are linked back to it
+func (e *Envelope) Context(ctx context.Context) context.Context {
+	ctx = WithCorrelationID(ctx, e.CorrelationID)
+	return context.WithValue(ctx, causationIDKey, e.EventID)
+}
+
+// UnmarshalEnvelope parses an envelope from a message payload. It returns
+// ErrNotEnvelope for payloads published before envelopes were introduced.
+func UnmarshalEnvelope(payload []byte) (*Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(payload, &env); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event envelope: %w", err)
+	}
+	if env.EventType == "" || env.EventID == "" {
+		return nil, ErrNotEnvelope
+	}
+	return &env, nil
+}
+
+type contextKey string
+
+const (
+	correlationIDKey contextKey = "messaging_correlation_id"
+	causationIDKey   contextKey = "messaging_causation_id"
+)
+
+// WithCorrelationID returns a context carrying the given correlation ID
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, correlationID)
+}
+
+// CorrelationIDFromContext returns the correlation ID carried by the context
+func CorrelationIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(correlationIDKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// CausationIDFromContext returns the ID of the event being handled, if any
+func CausationIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(causationIDKey).(string); ok {
+		return id
+	}
+	return ""
+}
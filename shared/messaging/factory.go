@@ -1,21 +1,40 @@
 package messaging
 
+import "fmt"
+
+// Messaging drivers
+const (
+	DriverKafka  = "kafka"  // Kafka via sarama, falling back to no-op when unavailable
+	DriverMemory = "memory" // Process-wide in-memory broker for tests and local development
+)
+
 // ProducerConfig holds configuration for creating producers
 type ProducerConfig struct {
+	Driver    string // DriverKafka (default) or DriverMemory
 	Brokers   []string
 	UseDocker bool // Use this to switch between implementations
 }
 
 // ConsumerConfig holds configuration for creating consumers
 type ConsumerConfig struct {
-	Brokers   []string
-	GroupID   string
-	Topics    []string
-	UseDocker bool // Use this to switch between implementations
+	Driver     string // DriverKafka (default) or DriverMemory
+	Brokers    []string
+	GroupID    string
+	Topics     []string
+	FromOldest bool // Start new consumer groups at the oldest offset instead of the newest
+	UseDocker  bool // Use this to switch between implementations
 }
 
 // NewEventProducer creates an appropriate producer based on configuration
 func NewEventProducer(config ProducerConfig) (EventProducer, error) {
+	switch config.Driver {
+	case "", DriverKafka:
+	case DriverMemory:
+		return DefaultMemoryBroker().NewProducer(), nil
+	default:
+		return nil, fmt.Errorf("unknown messaging driver %q", config.Driver)
+	}
+
 	// Try to create Sarama producer first
 	producer, err := NewSaramaProducer(config.Brokers)
 	if err != nil {
@@ -27,8 +46,16 @@ func NewEventProducer(config ProducerConfig) (EventProducer, error) {
 
 // NewEventConsumer creates an appropriate consumer based on configuration
 func NewEventConsumer(config ConsumerConfig) (EventConsumer, error) {
+	switch config.Driver {
+	case "", DriverKafka:
+	case DriverMemory:
+		return DefaultMemoryBroker().NewConsumer(config.GroupID), nil
+	default:
+		return nil, fmt.Errorf("unknown messaging driver %q", config.Driver)
+	}
+
 	// Try to create Sarama consumer first
-	consumer, err := NewSaramaConsumer(config.Brokers, config.GroupID, config.Topics)
+	consumer, err := NewSaramaConsumer(config.Brokers, config.GroupID, config.Topics, config.FromOldest)
 	if err != nil {
 		// If Kafka is not available, return a no-op consumer for graceful degradation
 		return NewNoOpConsumer(config.Topics), nil
@@ -0,0 +1,250 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Idempotency errors
+var (
+	ErrEventInFlight = errors.New("event is being processed by another consumer")
+)
+
+// ContextMessageHandler is implemented by handlers that accept a context.
+// Handlers wrapped in an IdempotentHandler should implement it so their side
+// effects can join the deduplication transaction (see TxFromContext).
+type ContextMessageHandler interface {
+	HandleMessageContext(ctx context.Context, msg *Message) error
+}
+
+// ProcessedEventStore remembers which events a consumer has already handled
+type ProcessedEventStore interface {
+	// RunOnce runs fn unless the consumer already processed the event, and
+	// reports whether the event was a duplicate. The event is only recorded
+	// as processed if fn succeeds.
+	RunOnce(ctx context.Context, consumer, eventID string, fn func(ctx context.Context) error) (bool, error)
+}
+
+type txContextKey struct{}
+
+// ContextWithTx returns a context carrying a database transaction
+func ContextWithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// TxFromContext returns the database transaction carried by the context.
+// Handlers use it to make their writes part of the deduplication record.
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(*gorm.DB)
+	return tx, ok && tx != nil
+}
+
+// ProcessedEvent records that a consumer has handled an event
+type ProcessedEvent struct {
+	Consumer    string    `json:"consumer" gorm:"primaryKey"`
+	EventID     string    `json:"event_id" gorm:"primaryKey"`
+	ProcessedAt time.Time `json:"processed_at" gorm:"autoCreateTime;index"`
+}
+
+// TableName sets the table name for processed events
+func (ProcessedEvent) TableName() string {
+	return "processed_events"
+}
+
+// PostgresProcessedEventStore deduplicates events in Postgres. The
+// processed-event row is inserted in the same transaction as the handler's
+// writes, so an event is recorded if and only if its side effect commits.
+// A concurrent delivery of the same event blocks on the row until the first
+// one commits or rolls back.
+type PostgresProcessedEventStore struct {
+	db *gorm.DB
+}
+
+// NewPostgresProcessedEventStore creates a Postgres-backed processed event store
+func NewPostgresProcessedEventStore(db *gorm.DB) *PostgresProcessedEventStore {
+	return &PostgresProcessedEventStore{db: db}
+}
+
+// RunOnce runs fn inside a transaction that also records the event. The
+// transaction is available to fn through TxFromContext.
+func (s *PostgresProcessedEventStore) RunOnce(ctx context.Context, consumer, eventID string, fn func(ctx context.Context) error) (bool, error) {
+	duplicate := false
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&ProcessedEvent{Consumer: consumer, EventID: eventID})
+		if result.Error != nil {
+			return fmt.Errorf("failed to record processed event: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			duplicate = true
+			return nil
+		}
+
+		return fn(ContextWithTx(ctx, tx))
+	})
+
+	return duplicate, err
+}
+
+// DefaultProcessedEventRetention is how long processed-event records are
+// kept. It outlasts Kafka's default seven-day retention, the longest a
+// message can be redelivered after it was processed.
+const DefaultProcessedEventRetention = 14 * 24 * time.Hour
+
+// RunPurge deletes processed-event records past the retention every
+// interval until the context is cancelled
+func (s *PostgresProcessedEventStore) RunPurge(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := s.PurgeOlderThan(ctx, retention)
+			if err != nil {
+				log.Printf("Processed events: failed to purge records: %v", err)
+			} else if purged > 0 {
+				log.Printf("Processed events: purged %d records", purged)
+			}
+		}
+	}
+}
+
+// PurgeOlderThan deletes processed-event records older than the given age.
+// Only purge records older than the longest possible redelivery window.
+func (s *PostgresProcessedEventStore) PurgeOlderThan(ctx context.Context, age time.Duration) (int64, error) {
+	result := s.db.WithContext(ctx).
+		Where("processed_at < ?", time.Now().Add(-age)).
+		Delete(&ProcessedEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// RedisClaimStore is the subset of the shared Redis client used for
+// deduplication
+type RedisClaimStore interface {
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	Get(ctx context.Context, key string) (string, error)
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Redis claim states
+const (
+	redisClaimProcessing = "processing"
+	redisClaimDone       = "done"
+)
+
+// RedisProcessedEventStore deduplicates events in Redis. An event is claimed
+// with SETNX before the handler runs and marked done afterwards; a failed
+// handler releases the claim so the event can be retried. Redis cannot share
+// a transaction with the handler's database writes, so prefer the Postgres
+// store when the side effect lives in Postgres.
+type RedisProcessedEventStore struct {
+	redis     RedisClaimStore
+	prefix    string
+	claimTTL  time.Duration
+	retention time.Duration
+}
+
+// NewRedisProcessedEventStore creates a Redis-backed processed event store.
+// claimTTL bounds how long a crashed consumer can hold an event; retention
+// is how long processed events are remembered.
+func NewRedisProcessedEventStore(redis RedisClaimStore, claimTTL, retention time.Duration) *RedisProcessedEventStore {
+	return &RedisProcessedEventStore{
+		redis:     redis,
+		prefix:    "processed_event",
+		claimTTL:  claimTTL,
+		retention: retention,
+	}
+}
+
+// RunOnce claims the event and runs fn if no other delivery has claimed it
+func (s *RedisProcessedEventStore) RunOnce(ctx context.Context, consumer, eventID string, fn func(ctx context.Context) error) (bool, error) {
+	key := fmt.Sprintf("%s:%s:%s", s.prefix, consumer, eventID)
+
+	claimed, err := s.redis.SetNX(ctx, key, redisClaimProcessing, s.claimTTL)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim event: %w", err)
+	}
+	if !claimed {
+		state, err := s.redis.Get(ctx, key)
+		if err == nil && state == redisClaimDone {
+			return true, nil
+		}
+		// Either still in flight elsewhere or the claim just expired; let the
+		// caller retry rather than risk running the side effect twice.
+		return false, ErrEventInFlight
+	}
+
+	if err := fn(ctx); err != nil {
+		if releaseErr := s.redis.Delete(ctx, key); releaseErr != nil {
+			return false, fmt.Errorf("%w (and failed to release claim: %v)", err, releaseErr)
+		}
+		return false, err
+	}
+
+	if err := s.redis.Set(ctx, key, redisClaimDone, s.retention); err != nil {
+		return false, fmt.Errorf("failed to mark event processed: %w", err)
+	}
+	return false, nil
+}
+
+// IdempotentHandler skips messages whose event was already processed by the
+// consumer. Event IDs come from the message envelope; messages without an
+// envelope are keyed by their topic, partition and offset, which still
+// catches broker redeliveries.
+type IdempotentHandler struct {
+	store    ProcessedEventStore
+	consumer string
+	handler  MessageHandler
+}
+
+// NewIdempotentHandler wraps a handler with deduplication. The consumer name
+// scopes event IDs, so different consumer groups each process every event.
+func NewIdempotentHandler(store ProcessedEventStore, consumer string, handler MessageHandler) *IdempotentHandler {
+	return &IdempotentHandler{
+		store:    store,
+		consumer: consumer,
+		handler:  handler,
+	}
+}
+
+// HandleMessage implements MessageHandler
+func (h *IdempotentHandler) HandleMessage(msg *Message) error {
+	return h.HandleMessageContext(context.Background(), msg)
+}
+
+// HandleMessageContext implements ContextMessageHandler
+func (h *IdempotentHandler) HandleMessageContext(ctx context.Context, msg *Message) error {
+	duplicate, err := h.store.RunOnce(ctx, h.consumer, MessageEventID(msg), func(ctx context.Context) error {
+		if handler, ok := h.handler.(ContextMessageHandler); ok {
+			return handler.HandleMessageContext(ctx, msg)
+		}
+		return h.handler.HandleMessage(msg)
+	})
+	if err != nil {
+		return err
+	}
+	if duplicate {
+		log.Printf("Idempotent handler: skipping duplicate event %s for consumer %s (topic=%s offset=%d)",
+			MessageEventID(msg), h.consumer, msg.Topic, msg.Offset)
+	}
+	return nil
+}
+
+// MessageEventID returns the event ID used to deduplicate a message
+func MessageEventID(msg *Message) string {
+	if env, err := UnmarshalEnvelope(msg.Value); err == nil {
+		return env.EventID
+	}
+	return fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset)
+}
@@ -0,0 +1,114 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is an in-memory stand-in for the shared Redis client
+type fakeRedis struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{values: make(map[string]string)}
+}
+
+func (r *fakeRedis) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.values[key]; ok {
+		return false, nil
+	}
+	r.values[key] = value.(string)
+	return true, nil
+}
+
+func (r *fakeRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[key] = value.(string)
+	return nil
+}
+
+func (r *fakeRedis) Get(ctx context.Context, key string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, ok := r.values[key]
+	if !ok {
+		return "", errors.New("redis: nil")
+	}
+	return value, nil
+}
+
+func (r *fakeRedis) Delete(ctx context.Context, keys ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range keys {
+		delete(r.values, key)
+	}
+	return nil
+}
+
+func TestIdempotentHandler_SkipsRedeliveredEvent(t *testing.T) {
+	env, err := NewEnvelope(context.Background(), EventTypeOrderPlaced, 1, "", map[string]string{"id": "order-1"})
+	if err != nil {
+		t.Fatalf("failed to build envelope: %v", err)
+	}
+	payload, err := env.Marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal envelope: %v", err)
+	}
+
+	calls := 0
+	store := NewRedisProcessedEventStore(newFakeRedis(), time.Minute, time.Hour)
+	handler := NewIdempotentHandler(store, "inventory", MessageHandlerFunc(func(msg *Message) error {
+		calls++
+		return nil
+	}))
+
+	// The same event published twice lands at different offsets
+	for offset := int64(0); offset < 2; offset++ {
+		msg := &Message{Topic: "orders.placed", Value: payload, Offset: offset}
+		if err := handler.HandleMessage(msg); err != nil {
+			t.Fatalf("delivery %d failed: %v", offset, err)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected handler to run once, ran %d times", calls)
+	}
+}
+
+func TestRedisProcessedEventStore_FailedHandlerReleasesClaim(t *testing.T) {
+	store := NewRedisProcessedEventStore(newFakeRedis(), time.Minute, time.Hour)
+	ctx := context.Background()
+
+	_, err := store.RunOnce(ctx, "inventory", "event-1", func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+	if err == nil {
+		t.Fatal("expected handler error to be returned")
+	}
+
+	ran := false
+	duplicate, err := store.RunOnce(ctx, "inventory", "event-1", func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	if err != nil || duplicate || !ran {
+		t.Fatalf("expected retry to run: ran=%v duplicate=%v err=%v", ran, duplicate, err)
+	}
+
+	duplicate, err = store.RunOnce(ctx, "inventory", "event-1", func(ctx context.Context) error {
+		t.Fatal("processed event ran again")
+		return nil
+	})
+	if err != nil || !duplicate {
+		t.Fatalf("expected duplicate: duplicate=%v err=%v", duplicate, err)
+	}
+}
@@ -0,0 +1,392 @@
+package messaging
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory broker errors
+var (
+	ErrConsumerClosed = errors.New("consumer is closed")
+	ErrNotSubscribed  = errors.New("consumer is not subscribed to any topic")
+	ErrReadTimeout    = errors.New("timed out waiting for message")
+)
+
+// DefaultMemoryPartitions is the number of partitions per topic used by the
+// shared in-memory broker
+const DefaultMemoryPartitions = 3
+
+var (
+	defaultMemoryBroker     *MemoryBroker
+	defaultMemoryBrokerOnce sync.Once
+)
+
+// DefaultMemoryBroker returns the process-wide in-memory broker used when the
+// memory driver is selected in ProducerConfig or ConsumerConfig
+func DefaultMemoryBroker() *MemoryBroker {
+	defaultMemoryBrokerOnce.Do(func() {
+		defaultMemoryBroker = NewMemoryBroker(DefaultMemoryPartitions)
+	})
+	return defaultMemoryBroker
+}
+
+// MemoryBroker is an in-process event broker for tests and local
+// development. Like Kafka, it keeps an append-only log per topic partition,
+// routes keyed messages to a stable partition, shares partitions between the
+// consumers of a group and tracks committed offsets per group, so messages
+// that were read but not committed are redelivered after a rebalance.
+type MemoryBroker struct {
+	mu         sync.Mutex
+	partitions int
+	topics     map[string][][]*Message
+	groups     map[string]*memoryGroup
+	roundRobin map[string]int
+	notify     chan struct{}
+}
+
+// memoryGroup holds the members and committed offsets of a consumer group
+type memoryGroup struct {
+	committed map[topicPartition]int64
+	members   []*MemoryConsumer
+}
+
+// topicPartition identifies a single partition of a topic
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// NewMemoryBroker creates an in-memory broker with the given number of
+// partitions per topic
+func NewMemoryBroker(partitions int) *MemoryBroker {
+	if partitions <= 0 {
+		partitions = 1
+	}
+	return &MemoryBroker{
+		partitions: partitions,
+		topics:     make(map[string][][]*Message),
+		groups:     make(map[string]*memoryGroup),
+		roundRobin: make(map[string]int),
+		notify:     make(chan struct{}),
+	}
+}
+
+// NewProducer creates a producer that publishes to this broker
+func (b *MemoryBroker) NewProducer() *MemoryProducer {
+	return &MemoryProducer{broker: b}
+}
+
+// NewConsumer creates a consumer that joins the given consumer group
+func (b *MemoryBroker) NewConsumer(groupID string) *MemoryConsumer {
+	return &MemoryConsumer{
+		broker:   b,
+		groupID:  groupID,
+		position: make(map[topicPartition]int64),
+		closed:   make(chan struct{}),
+	}
+}
+
+// Messages returns every message published to a topic, ordered by partition
+// and offset
+func (b *MemoryBroker) Messages(topic string) []*Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var messages []*Message
+	for _, partition := range b.topics[topic] {
+		for _, msg := range partition {
+			messages = append(messages, copyMessage(msg))
+		}
+	}
+	return messages
+}
+
+// CommittedOffset returns the next offset a group will read from a partition
+func (b *MemoryBroker) CommittedOffset(groupID, topic string, partition int32) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	group, ok := b.groups[groupID]
+	if !ok {
+		return 0
+	}
+	return group.committed[topicPartition{topic: topic, partition: partition}]
+}
+
+// Lag returns how many messages of a topic the group has not committed yet
+func (b *MemoryBroker) Lag(groupID, topic string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lag int64
+	group := b.groups[groupID]
+	for p, partition := range b.topics[topic] {
+		committed := int64(0)
+		if group != nil {
+			committed = group.committed[topicPartition{topic: topic, partition: int32(p)}]
+		}
+		lag += int64(len(partition)) - committed
+	}
+	return lag
+}
+
+// publish appends a message to the partition selected by its key
+func (b *MemoryBroker) publish(topic, key string, payload []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	partitions := b.ensureTopicLocked(topic)
+
+	var partition int
+	if key == "" {
+		partition = b.roundRobin[topic] % len(partitions)
+		b.roundRobin[topic]++
+	} else {
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		partition = int(hash.Sum32() % uint32(len(partitions)))
+	}
+
+	msg := &Message{
+		Topic:     topic,
+		Key:       []byte(key),
+		Value:     append([]byte(nil), payload...),
+		Partition: int32(partition),
+		Offset:    int64(len(partitions[partition])),
+	}
+	partitions[partition] = append(partitions[partition], msg)
+
+	b.broadcastLocked()
+}
+
+// ensureTopicLocked creates the topic partitions if needed
+func (b *MemoryBroker) ensureTopicLocked(topic string) [][]*Message {
+	partitions, ok := b.topics[topic]
+	if !ok {
+		partitions = make([][]*Message, b.partitions)
+		b.topics[topic] = partitions
+	}
+	return partitions
+}
+
+// broadcastLocked wakes up every consumer waiting for messages
+func (b *MemoryBroker) broadcastLocked() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// rebalanceLocked assigns the partitions of every subscribed topic to the
+// group members subscribed to it. Members restart from the committed offsets,
+// so uncommitted messages are delivered again.
+func (b *MemoryBroker) rebalanceLocked(group *memoryGroup) {
+	for _, member := range group.members {
+		member.assigned = nil
+		member.position = make(map[topicPartition]int64)
+		member.next = 0
+	}
+
+	topics := make(map[string][]*MemoryConsumer)
+	for _, member := range group.members {
+		for _, topic := range member.topics {
+			topics[topic] = append(topics[topic], member)
+		}
+	}
+
+	names := make([]string, 0, len(topics))
+	for topic := range topics {
+		names = append(names, topic)
+	}
+	sort.Strings(names)
+
+	for _, topic := range names {
+		members := topics[topic]
+		for p := range b.ensureTopicLocked(topic) {
+			tp := topicPartition{topic: topic, partition: int32(p)}
+			member := members[p%len(members)]
+			member.assigned = append(member.assigned, tp)
+			member.position[tp] = group.committed[tp]
+		}
+	}
+
+	b.broadcastLocked()
+}
+
+// MemoryProducer publishes messages to a MemoryBroker
+type MemoryProducer struct {
+	broker *MemoryBroker
+}
+
+// Publish appends the message to the topic
+func (p *MemoryProducer) Publish(topic, key string, payload []byte) error {
+	p.broker.publish(topic, key, payload)
+	return nil
+}
+
+// Close does nothing for the memory producer
+func (p *MemoryProducer) Close() error {
+	return nil
+}
+
+// MemoryConsumer consumes messages from a MemoryBroker as a member of a
+// consumer group
+type MemoryConsumer struct {
+	broker    *MemoryBroker
+	groupID   string
+	topics    []string
+	assigned  []topicPartition
+	position  map[topicPartition]int64
+	next      int
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Subscribe joins the consumer group for the given topics
+func (c *MemoryConsumer) Subscribe(topics []string) error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	select {
+	case <-c.closed:
+		return ErrConsumerClosed
+	default:
+	}
+
+	group, ok := b.groups[c.groupID]
+	if !ok {
+		group = &memoryGroup{committed: make(map[topicPartition]int64)}
+		b.groups[c.groupID] = group
+	}
+
+	joined := false
+	for _, member := range group.members {
+		if member == c {
+			joined = true
+			break
+		}
+	}
+	if !joined {
+		group.members = append(group.members, c)
+	}
+
+	c.topics = append([]string(nil), topics...)
+	b.rebalanceLocked(group)
+	return nil
+}
+
+// ReadMessage blocks until a message is available on an assigned partition
+// or the consumer is closed
+func (c *MemoryConsumer) ReadMessage() (*Message, error) {
+	return c.read(nil)
+}
+
+// ReadMessageTimeout is like ReadMessage but gives up after the timeout
+func (c *MemoryConsumer) ReadMessageTimeout(timeout time.Duration) (*Message, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	return c.read(timer.C)
+}
+
+// read fetches the next message, waiting for new messages as needed
+func (c *MemoryConsumer) read(timeout <-chan time.Time) (*Message, error) {
+	b := c.broker
+	for {
+		b.mu.Lock()
+		select {
+		case <-c.closed:
+			b.mu.Unlock()
+			return nil, ErrConsumerClosed
+		default:
+		}
+		if c.topics == nil {
+			b.mu.Unlock()
+			return nil, ErrNotSubscribed
+		}
+		msg := c.fetchLocked()
+		notify := b.notify
+		b.mu.Unlock()
+
+		if msg != nil {
+			return msg, nil
+		}
+
+		select {
+		case <-notify:
+		case <-c.closed:
+			return nil, ErrConsumerClosed
+		case <-timeout:
+			return nil, ErrReadTimeout
+		}
+	}
+}
+
+// fetchLocked returns the next unread message, cycling through the assigned
+// partitions so no partition starves the others
+func (c *MemoryConsumer) fetchLocked() *Message {
+	for i := 0; i < len(c.assigned); i++ {
+		idx := (c.next + i) % len(c.assigned)
+		tp := c.assigned[idx]
+		log := c.broker.topics[tp.topic][tp.partition]
+		pos := c.position[tp]
+		if pos < int64(len(log)) {
+			c.position[tp] = pos + 1
+			c.next = (idx + 1) % len(c.assigned)
+			return copyMessage(log[pos])
+		}
+	}
+	return nil
+}
+
+// CommitMessage commits the message offset for the consumer group
+func (c *MemoryConsumer) CommitMessage(msg *Message) error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	group, ok := b.groups[c.groupID]
+	if !ok {
+		return ErrNotSubscribed
+	}
+
+	tp := topicPartition{topic: msg.Topic, partition: msg.Partition}
+	if msg.Offset+1 > group.committed[tp] {
+		group.committed[tp] = msg.Offset + 1
+	}
+	return nil
+}
+
+// Close leaves the consumer group, handing its partitions to the remaining
+// members
+func (c *MemoryConsumer) Close() error {
+	c.closeOnce.Do(func() {
+		b := c.broker
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		close(c.closed)
+		if group, ok := b.groups[c.groupID]; ok {
+			for i, member := range group.members {
+				if member == c {
+					group.members = append(group.members[:i], group.members[i+1:]...)
+					break
+				}
+			}
+			b.rebalanceLocked(group)
+		}
+	})
+	return nil
+}
+
+// copyMessage returns a copy of the message so callers cannot modify the log
+func copyMessage(msg *Message) *Message {
+	return &Message{
+		Topic:     msg.Topic,
+		Key:       append([]byte(nil), msg.Key...),
+		Value:     append([]byte(nil), msg.Value...),
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+	}
+}
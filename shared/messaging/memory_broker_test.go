@@ -0,0 +1,151 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryBroker_KeyedMessagesShareAPartition(t *testing.T) {
+	broker := NewMemoryBroker(4)
+	producer := broker.NewProducer()
+
+	for i := 0; i < 10; i++ {
+		if err := producer.Publish("orders.placed", "order-1", []byte(fmt.Sprintf("%d", i))); err != nil {
+			t.Fatalf("publish failed: %v", err)
+		}
+	}
+
+	messages := broker.Messages("orders.placed")
+	if len(messages) != 10 {
+		t.Fatalf("expected 10 messages, got %d", len(messages))
+	}
+	for i, msg := range messages {
+		if msg.Partition != messages[0].Partition {
+			t.Fatalf("message %d landed on partition %d, expected %d", i, msg.Partition, messages[0].Partition)
+		}
+		if msg.Offset != int64(i) || string(msg.Value) != fmt.Sprintf("%d", i) {
+			t.Fatalf("message %d out of order: offset=%d value=%s", i, msg.Offset, msg.Value)
+		}
+	}
+}
+
+func TestMemoryBroker_UncommittedMessagesAreRedelivered(t *testing.T) {
+	broker := NewMemoryBroker(1)
+	producer := broker.NewProducer()
+	producer.Publish("orders.placed", "a", []byte("first"))
+	producer.Publish("orders.placed", "a", []byte("second"))
+
+	consumer := broker.NewConsumer("inventory")
+	if err := consumer.Subscribe([]string{"orders.placed"}); err != nil {
+		t.Fatalf("subscribe failed: %v", err)
+	}
+
+	first, err := consumer.ReadMessageTimeout(time.Second)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if err := consumer.CommitMessage(first); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+	if _, err := consumer.ReadMessageTimeout(time.Second); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	consumer.Close()
+
+	// The second message was read but never committed
+	replacement := broker.NewConsumer("inventory")
+	replacement.Subscribe([]string{"orders.placed"})
+	defer replacement.Close()
+
+	msg, err := replacement.ReadMessageTimeout(time.Second)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(msg.Value) != "second" {
+		t.Fatalf("expected uncommitted message to be redelivered, got %s", msg.Value)
+	}
+
+	if _, err := replacement.ReadMessageTimeout(50 * time.Millisecond); !errors.Is(err, ErrReadTimeout) {
+		t.Fatalf("expected no further messages, got %v", err)
+	}
+}
+
+func TestMemoryBroker_GroupsConsumeIndependently(t *testing.T) {
+	broker := NewMemoryBroker(2)
+	broker.NewProducer().Publish("orders.placed", "", []byte("event"))
+
+	for _, group := range []string{"inventory", "analytics"} {
+		consumer := broker.NewConsumer(group)
+		consumer.Subscribe([]string{"orders.placed"})
+		msg, err := consumer.ReadMessageTimeout(time.Second)
+		if err != nil {
+			t.Fatalf("group %s: read failed: %v", group, err)
+		}
+		consumer.CommitMessage(msg)
+		consumer.Close()
+
+		if lag := broker.Lag(group, "orders.placed"); lag != 0 {
+			t.Fatalf("group %s: expected no lag, got %d", group, lag)
+		}
+	}
+}
+
+func TestConsumerRunner_DeadLettersAfterMaxAttempts(t *testing.T) {
+	broker := NewMemoryBroker(1)
+	producer := broker.NewProducer()
+	producer.Publish("orders.placed", "order-1", []byte("bad"))
+	producer.Publish("orders.placed", "order-2", []byte("good"))
+
+	attempts := 0
+	handled := make(chan string, 1)
+	handler := MessageHandlerFunc(func(msg *Message) error {
+		if string(msg.Value) == "bad" {
+			attempts++
+			return errors.New("boom")
+		}
+		handled <- string(msg.Value)
+		return nil
+	})
+
+	consumer := broker.NewConsumer("inventory")
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 2}
+	runner := NewConsumerRunner(consumer, handler, producer, "inventory", policy)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runner.Run(ctx, []string{"orders.placed"}) }()
+
+	select {
+	case value := <-handled:
+		if value != "good" {
+			t.Fatalf("unexpected message handled: %s", value)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the good message")
+	}
+	cancel()
+	consumer.Close()
+	<-done
+
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+
+	letters := broker.Messages(DeadLetterTopic("orders.placed"))
+	if len(letters) != 1 {
+		t.Fatalf("expected 1 dead letter, got %d", len(letters))
+	}
+	letter, err := UnmarshalDeadLetter(letters[0].Value)
+	if err != nil {
+		t.Fatalf("failed to read dead letter: %v", err)
+	}
+	if string(letter.Payload) != "bad" || letter.Attempts != 3 || letter.Error != "boom" {
+		t.Fatalf("unexpected dead letter: %+v", letter)
+	}
+	if lag := broker.Lag("inventory", "orders.placed"); lag != 0 {
+		t.Fatalf("expected both messages committed, lag is %d", lag)
+	}
+}
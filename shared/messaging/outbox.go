@@ -0,0 +1,253 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxMessage is an event waiting to be relayed to the event stream.
+// It is written in the same database transaction as the state change it
+// describes, so the event is recorded if and only if the change commits.
+type OutboxMessage struct {
+	ID            uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	Sequence      int64      `json:"sequence" gorm:"autoIncrement;not null;uniqueIndex"`
+	AggregateType string     `json:"aggregate_type" gorm:"not null;index:idx_outbox_aggregate"`
+	AggregateID   string     `json:"aggregate_id" gorm:"not null;index:idx_outbox_aggregate"`
+	Topic         string     `json:"topic" gorm:"not null"`
+	Key           string     `json:"key"`
+	Payload       []byte     `json:"payload" gorm:"type:bytea;not null"`
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	LastError     string     `json:"last_error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"not null;index"`
+	PublishedAt   *time.Time `json:"published_at" gorm:"index"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName sets the table name for outbox messages
+func (OutboxMessage) TableName() string {
+	return "outbox_messages"
+}
+
+// BeforeCreate sets up UUID and scheduling for new records
+func (m *OutboxMessage) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	if m.NextAttemptAt.IsZero() {
+		m.NextAttemptAt = time.Now()
+	}
+	return nil
+}
+
+// NewOutboxMessage creates an outbox message for the given aggregate.
+// Messages that share an aggregate type and ID are relayed in the order
+// they were written.
+func NewOutboxMessage(aggregateType, aggregateID, topic, key string, payload []byte) *OutboxMessage {
+	return &OutboxMessage{
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Topic:         topic,
+		Key:           key,
+		Payload:       payload,
+	}
+}
+
+// EnqueueOutbox stores outbox messages using the given transaction handle
+func EnqueueOutbox(tx *gorm.DB, messages ...*OutboxMessage) error {
+	for _, msg := range messages {
+		if err := tx.Create(msg).Error; err != nil {
+			return fmt.Errorf("failed to enqueue outbox message for topic %s: %w", msg.Topic, err)
+		}
+	}
+	return nil
+}
+
+// OutboxRelayConfig holds configuration for the outbox relay
+type OutboxRelayConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	// PurgeInterval is how often published messages are deleted, and
+	// RetainPublished how long they are kept after publishing
+	PurgeInterval   time.Duration
+	RetainPublished time.Duration
+}
+
+// DefaultOutboxRelayConfig returns a default outbox relay configuration
+func DefaultOutboxRelayConfig() OutboxRelayConfig {
+	return OutboxRelayConfig{
+		PollInterval:    1 * time.Second,
+		BatchSize:       100,
+		BaseBackoff:     1 * time.Second,
+		MaxBackoff:      5 * time.Minute,
+		PurgeInterval:   1 * time.Hour,
+		RetainPublished: 7 * 24 * time.Hour,
+	}
+}
+
+// OutboxRelay drains the outbox table into an EventProducer.
+//
+// Each pass only picks the oldest unpublished message of every aggregate, so
+// a message is never published before an earlier one for the same aggregate,
+// even with several relays running against the same database. Failed
+// messages stay at the head of their aggregate and are retried with
+// exponential backoff until the producer accepts them.
+type OutboxRelay struct {
+	db       *gorm.DB
+	producer EventProducer
+	config   OutboxRelayConfig
+}
+
+// NewOutboxRelay creates a new outbox relay
+func NewOutboxRelay(db *gorm.DB, producer EventProducer, config OutboxRelayConfig) *OutboxRelay {
+	defaults := DefaultOutboxRelayConfig()
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = defaults.BaseBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+	if config.PurgeInterval <= 0 {
+		config.PurgeInterval = defaults.PurgeInterval
+	}
+	if config.RetainPublished <= 0 {
+		config.RetainPublished = defaults.RetainPublished
+	}
+
+	return &OutboxRelay{
+		db:       db,
+		producer: producer,
+		config:   config,
+	}
+}
+
+// Run relays outbox messages until the context is cancelled, purging
+// published messages past their retention every PurgeInterval
+func (r *OutboxRelay) Run(ctx context.Context) {
+	if isNoOpProducer(r.producer) {
+		// Publishing to the no-op producer would mark events as sent while
+		// discarding them, so leave them in the outbox for a real producer.
+		log.Println("Warning: outbox relay disabled because the no-op producer is in use - events will be kept in the outbox")
+		return
+	}
+
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+	purgeTicker := time.NewTicker(r.config.PurgeInterval)
+	defer purgeTicker.Stop()
+
+	for {
+		for {
+			relayed, err := r.RelayBatch(ctx)
+			if err != nil {
+				log.Printf("Outbox relay: failed to relay batch: %v", err)
+				break
+			}
+			if relayed < r.config.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-purgeTicker.C:
+			purged, err := r.PurgePublished(ctx, r.config.RetainPublished)
+			if err != nil {
+				log.Printf("Outbox relay: failed to purge published messages: %v", err)
+			} else if purged > 0 {
+				log.Printf("Outbox relay: purged %d published messages", purged)
+			}
+		}
+	}
+}
+
+// RelayBatch publishes one batch of due outbox messages and returns how many
+// were published
+func (r *OutboxRelay) RelayBatch(ctx context.Context) (int, error) {
+	relayed := 0
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var messages []OutboxMessage
+		if err := tx.
+			Where("published_at IS NULL AND next_attempt_at <= ?", time.Now()).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM outbox_messages prev
+				WHERE prev.aggregate_type = outbox_messages.aggregate_type
+				AND prev.aggregate_id = outbox_messages.aggregate_id
+				AND prev.published_at IS NULL
+				AND prev.sequence < outbox_messages.sequence)`).
+			Order("sequence ASC").
+			Limit(r.config.BatchSize).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Find(&messages).Error; err != nil {
+			return err
+		}
+
+		for i := range messages {
+			msg := &messages[i]
+			if publishErr := r.producer.Publish(msg.Topic, msg.Key, msg.Payload); publishErr != nil {
+				msg.Attempts++
+				backoff := r.backoff(msg.Attempts)
+				log.Printf("Outbox relay: failed to publish message %s to topic %s (attempt %d, retry in %s): %v",
+					msg.ID, msg.Topic, msg.Attempts, backoff, publishErr)
+
+				if err := tx.Model(msg).Updates(map[string]interface{}{
+					"attempts":        msg.Attempts,
+					"last_error":      publishErr.Error(),
+					"next_attempt_at": time.Now().Add(backoff),
+				}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			now := time.Now()
+			if err := tx.Model(msg).Updates(map[string]interface{}{
+				"published_at": &now,
+				"last_error":   "",
+			}).Error; err != nil {
+				return err
+			}
+			relayed++
+		}
+
+		return nil
+	})
+
+	return relayed, err
+}
+
+// PurgePublished deletes published messages older than the given age
+func (r *OutboxRelay) PurgePublished(ctx context.Context, olderThan time.Duration) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("published_at IS NOT NULL AND published_at < ?", time.Now().Add(-olderThan)).
+		Delete(&OutboxMessage{})
+	return result.RowsAffected, result.Error
+}
+
+// backoff returns the retry delay for the given attempt number
+func (r *OutboxRelay) backoff(attempt int) time.Duration {
+	delay := r.config.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= r.config.MaxBackoff {
+			return r.config.MaxBackoff
+		}
+	}
+	return delay
+}
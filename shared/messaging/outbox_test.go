@@ -0,0 +1,267 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB connects to the PostgreSQL database named by TEST_DATABASE_URL
+// inside a schema of its own that is dropped when the test ends. Tests that
+// need a database are skipped when the variable is unset.
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	config := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	admin, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		t.Fatalf("connect to test database: %v", err)
+	}
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create test schema: %v", err)
+	}
+
+	separator := " "
+	if strings.Contains(dsn, "://") {
+		separator = "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+	}
+	db, err := gorm.Open(postgres.Open(dsn+separator+"search_path="+schema), config)
+	if err != nil {
+		t.Fatalf("connect to test schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := db.AutoMigrate(&OutboxMessage{}); err != nil {
+		t.Fatalf("migrate outbox: %v", err)
+	}
+	return db
+}
+
+// recordingProducer records published payloads and fails the payloads it
+// is told to
+type recordingProducer struct {
+	mu        sync.Mutex
+	published []string
+	failing   map[string]bool
+}
+
+func (p *recordingProducer) Publish(topic, key string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failing[string(payload)] {
+		return errors.New("broker unavailable")
+	}
+	p.published = append(p.published, string(payload))
+	return nil
+}
+
+func (p *recordingProducer) Close() error { return nil }
+
+func (p *recordingProducer) Published() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.published...)
+}
+
+func enqueue(t *testing.T, db *gorm.DB, aggregateID, payload string) *OutboxMessage {
+	t.Helper()
+	msg := NewOutboxMessage("order", aggregateID, "orders.placed", aggregateID, []byte(payload))
+	if err := EnqueueOutbox(db, msg); err != nil {
+		t.Fatalf("enqueue %s: %v", payload, err)
+	}
+	return msg
+}
+
+func TestEnqueueOutbox_CommitsWithCallerTransaction(t *testing.T) {
+	db := openTestDB(t)
+
+	rollback := errors.New("rollback")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := EnqueueOutbox(tx, NewOutboxMessage("order", "a", "orders.placed", "a", []byte("a1"))); err != nil {
+			return err
+		}
+		var count int64
+		if err := db.Model(&OutboxMessage{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count != 0 {
+			t.Errorf("message visible outside the transaction before commit")
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("transaction err = %v", err)
+	}
+
+	var count int64
+	db.Model(&OutboxMessage{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("rolled back message was kept: %d messages", count)
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return EnqueueOutbox(tx, NewOutboxMessage("order", "a", "orders.placed", "a", []byte("a1")))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&OutboxMessage{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("committed messages = %d, want 1", count)
+	}
+}
+
+func TestOutboxRelay_PublishesEachAggregateInOrder(t *testing.T) {
+	db := openTestDB(t)
+	producer := &recordingProducer{failing: map[string]bool{"a1": true}}
+	relay := NewOutboxRelay(db, producer, OutboxRelayConfig{BaseBackoff: time.Hour})
+	ctx := context.Background()
+
+	a1 := enqueue(t, db, "a", "a1")
+	enqueue(t, db, "a", "a2")
+	enqueue(t, db, "b", "b1")
+
+	// a1 fails and holds back a2, while b is unaffected
+	for i := 0; i < 2; i++ {
+		if _, err := relay.RelayBatch(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := producer.Published(); fmt.Sprint(got) != "[b1]" {
+		t.Fatalf("published = %v, want [b1]", got)
+	}
+
+	// Once a1 is due and goes through, a2 follows it
+	producer.mu.Lock()
+	producer.failing = nil
+	producer.mu.Unlock()
+	if err := db.Model(a1).Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := relay.RelayBatch(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := producer.Published(); fmt.Sprint(got) != "[b1 a1 a2]" {
+		t.Fatalf("published = %v, want [b1 a1 a2]", got)
+	}
+}
+
+func TestOutboxRelay_BacksOffFailedMessage(t *testing.T) {
+	db := openTestDB(t)
+	producer := &recordingProducer{failing: map[string]bool{"a1": true}}
+	relay := NewOutboxRelay(db, producer, OutboxRelayConfig{BaseBackoff: time.Minute, MaxBackoff: time.Hour})
+	ctx := context.Background()
+
+	msg := enqueue(t, db, "a", "a1")
+	before := time.Now()
+	if relayed, err := relay.RelayBatch(ctx); err != nil || relayed != 0 {
+		t.Fatalf("RelayBatch = %d, %v; want 0, nil", relayed, err)
+	}
+
+	var stored OutboxMessage
+	if err := db.First(&stored, "id = ?", msg.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Attempts != 1 || stored.LastError == "" || stored.PublishedAt != nil {
+		t.Fatalf("attempts = %d, last error = %q, published = %v", stored.Attempts, stored.LastError, stored.PublishedAt)
+	}
+	if stored.NextAttemptAt.Before(before.Add(time.Minute)) {
+		t.Fatalf("next attempt at %s, want at least a minute after %s", stored.NextAttemptAt, before)
+	}
+
+	// The message is not retried before its backoff expires
+	if relayed, err := relay.RelayBatch(ctx); err != nil || relayed != 0 {
+		t.Fatalf("RelayBatch = %d, %v; want 0, nil", relayed, err)
+	}
+	db.First(&stored, "id = ?", msg.ID)
+	if stored.Attempts != 1 {
+		t.Fatalf("attempts = %d after backoff, want 1", stored.Attempts)
+	}
+}
+
+func TestOutboxRelay_SkipsMessagesClaimedByAnotherRelay(t *testing.T) {
+	db := openTestDB(t)
+	producer := &recordingProducer{}
+	relay := NewOutboxRelay(db, producer, OutboxRelayConfig{})
+
+	a1 := enqueue(t, db, "a", "a1")
+	enqueue(t, db, "b", "b1")
+
+	// Another relay holds a1 in its batch
+	other := db.Begin()
+	defer other.Rollback()
+	var claimed OutboxMessage
+	if err := other.Clauses(clause.Locking{Strength: "UPDATE"}).First(&claimed, "id = ?", a1.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	relayed, err := relay.RelayBatch(ctx)
+	if err != nil {
+		t.Fatalf("RelayBatch blocked on the claimed message: %v", err)
+	}
+	if relayed != 1 || fmt.Sprint(producer.Published()) != "[b1]" {
+		t.Fatalf("relayed %d, published %v; want only b1", relayed, producer.Published())
+	}
+}
+
+func TestOutboxRelay_PurgesPublishedMessages(t *testing.T) {
+	db := openTestDB(t)
+	relay := NewOutboxRelay(db, &recordingProducer{}, OutboxRelayConfig{})
+
+	old := enqueue(t, db, "a", "a1")
+	recent := enqueue(t, db, "b", "b1")
+	enqueue(t, db, "c", "c1")
+	db.Model(old).Update("published_at", time.Now().Add(-48*time.Hour))
+	db.Model(recent).Update("published_at", time.Now())
+
+	purged, err := relay.PurgePublished(context.Background(), 24*time.Hour)
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgePublished = %d, %v; want 1, nil", purged, err)
+	}
+	var count int64
+	db.Model(&OutboxMessage{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("kept %d messages, want the recent and unpublished ones", count)
+	}
+}
+
+func TestOutboxRelay_BackoffDoublesUpToMax(t *testing.T) {
+	relay := NewOutboxRelay(nil, &recordingProducer{}, OutboxRelayConfig{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second})
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, expected := range want {
+		if got := relay.backoff(i + 1); got != expected {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, expected)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package messaging
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/IBM/sarama"
@@ -64,10 +65,13 @@ type SaramaConsumer struct {
 }
 
 // NewSaramaConsumer creates a new pure Go Kafka consumer
-func NewSaramaConsumer(brokers []string, groupID string, topics []string) (*SaramaConsumer, error) {
+func NewSaramaConsumer(brokers []string, groupID string, topics []string, fromOldest bool) (*SaramaConsumer, error) {
 	config := sarama.NewConfig()
 	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRoundRobin
 	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	if fromOldest {
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	}
 	config.Consumer.Group.Session.Timeout = 10 * time.Second
 	config.Consumer.Group.Heartbeat.Interval = 3 * time.Second
 
@@ -78,7 +82,7 @@ func NewSaramaConsumer(brokers []string, groupID string, topics []string) (*Sara
 
 	ctx, cancel := context.WithCancel(context.Background())
 	messages := make(chan *Message, 100)
-	handler := &ConsumerGroupHandler{messages: messages}
+	handler := newConsumerGroupHandler(messages)
 
 	return &SaramaConsumer{
 		consumer: consumer,
@@ -94,7 +98,7 @@ func NewSaramaConsumer(brokers []string, groupID string, topics []string) (*Sara
 // Subscribe subscribes to the configured topics and starts consumption
 func (c *SaramaConsumer) Subscribe(topics []string) error {
 	c.topics = topics
-	
+
 	// Start consuming in a goroutine
 	go func() {
 		for {
@@ -104,14 +108,14 @@ func (c *SaramaConsumer) Subscribe(topics []string) error {
 				}
 				fmt.Printf("Error from consumer: %v\n", err)
 			}
-			
+
 			// Check if context was cancelled
 			if c.ctx.Err() != nil {
 				return
 			}
 		}
 	}()
-	
+
 	return nil
 }
 
@@ -125,9 +129,13 @@ func (c *SaramaConsumer) ReadMessage() (*Message, error) {
 	}
 }
 
-// CommitMessage commits a message offset
+// CommitMessage marks the message's offset as consumed. Marked offsets are
+// committed to Kafka in the background, so a message that is read but never
+// committed is delivered again after a restart or rebalance.
 func (c *SaramaConsumer) CommitMessage(msg *Message) error {
-	// Message commit is handled automatically in the ConsumerGroupHandler
+	if c.handler == nil || !c.handler.mark(msg) {
+		return fmt.Errorf("message from topic %s offset %d was not read by this consumer", msg.Topic, msg.Offset)
+	}
 	return nil
 }
 
@@ -137,13 +145,65 @@ func (c *SaramaConsumer) Close() error {
 	return c.consumer.Close()
 }
 
-// ConsumerGroupHandler implements sarama.ConsumerGroupHandler
+// ConsumerGroupHandler implements sarama.ConsumerGroupHandler. It remembers
+// the session each handed-over message was claimed in, so the message's
+// offset can be marked in it once the message is committed.
 type ConsumerGroupHandler struct {
 	messages chan *Message
+
+	mu      sync.Mutex
+	pending map[messagePosition]pendingMessage
 }
 
-func (h *ConsumerGroupHandler) Setup(sarama.ConsumerGroupSession) error   { return nil }
-func (h *ConsumerGroupHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+// messagePosition identifies a message by where it is in its topic
+type messagePosition struct {
+	topic     string
+	partition int32
+	offset    int64
+}
+
+// pendingMessage is a handed-over message and the session it was claimed in
+type pendingMessage struct {
+	session sarama.ConsumerGroupSession
+	message *sarama.ConsumerMessage
+}
+
+// newConsumerGroupHandler creates a handler handing messages over on
+// messages
+func newConsumerGroupHandler(messages chan *Message) *ConsumerGroupHandler {
+	return &ConsumerGroupHandler{messages: messages, pending: make(map[messagePosition]pendingMessage)}
+}
+
+func (h *ConsumerGroupHandler) Setup(sarama.ConsumerGroupSession) error { return nil }
+
+// Cleanup forgets the messages of an ended session. Their partitions may
+// have moved to another consumer, which receives them again.
+func (h *ConsumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for position, pending := range h.pending {
+		if pending.session == session {
+			delete(h.pending, position)
+		}
+	}
+	return nil
+}
+
+// mark marks the offset of a handed-over message in its session, reporting
+// whether the message was handed over by this handler
+func (h *ConsumerGroupHandler) mark(msg *Message) bool {
+	position := messagePosition{topic: msg.Topic, partition: msg.Partition, offset: msg.Offset}
+	h.mu.Lock()
+	pending, ok := h.pending[position]
+	delete(h.pending, position)
+	h.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	pending.session.MarkMessage(pending.message, "")
+	return true
+}
 
 func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	for message := range claim.Messages() {
@@ -154,10 +214,13 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 			Partition: message.Partition,
 			Offset:    message.Offset,
 		}
-		
+		h.mu.Lock()
+		h.pending[messagePosition{topic: message.Topic, partition: message.Partition, offset: message.Offset}] = pendingMessage{session: session, message: message}
+		h.mu.Unlock()
+
+		// The offset is marked by CommitMessage once the message is handled
 		select {
 		case h.messages <- msg:
-			session.MarkMessage(message, "")
 		case <-session.Context().Done():
 			return nil
 		}
@@ -0,0 +1,136 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// fakeSession records the offsets marked in a consumer group session
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx context.Context
+
+	mu     sync.Mutex
+	marked []int64
+}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = append(s.marked, msg.Offset)
+}
+
+func (s *fakeSession) Context() context.Context { return s.ctx }
+
+func (s *fakeSession) Marked() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.marked...)
+}
+
+// fakeClaim delivers the given messages of a partition
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func TestSaramaConsumer_MarksOffsetOnlyOnCommit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := &fakeSession{ctx: ctx}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 2)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders.placed", Offset: 7, Value: []byte("first")}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders.placed", Offset: 8, Value: []byte("second")}
+	close(claim.messages)
+
+	messages := make(chan *Message, 2)
+	consumer := &SaramaConsumer{messages: messages, handler: newConsumerGroupHandler(messages), ctx: ctx, cancel: cancel}
+	if err := consumer.handler.ConsumeClaim(session, claim); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := consumer.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := consumer.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+	if marked := session.Marked(); len(marked) != 0 {
+		t.Fatalf("offsets %v marked before the messages were committed", marked)
+	}
+
+	if err := consumer.CommitMessage(first); err != nil {
+		t.Fatal(err)
+	}
+	if marked := session.Marked(); len(marked) != 1 || marked[0] != 7 {
+		t.Fatalf("marked offsets = %v, want [7]", marked)
+	}
+}
+
+func TestSaramaConsumer_RejectsForeignMessageCommit(t *testing.T) {
+	consumer := &SaramaConsumer{handler: newConsumerGroupHandler(nil)}
+	if err := consumer.CommitMessage(&Message{Topic: "orders.placed", Offset: 1}); err == nil {
+		t.Fatal("expected an error committing a message the consumer did not read")
+	}
+}
+
+func TestConsumerGroupHandler_StopsWhenSessionEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	session := &fakeSession{ctx: ctx}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders.placed", Offset: 1}
+
+	// Nobody reads the handed-over message, so the handler waits until the
+	// session ends without marking it
+	handler := newConsumerGroupHandler(make(chan *Message))
+	done := make(chan error, 1)
+	go func() { done <- handler.ConsumeClaim(session, claim) }()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ConsumeClaim did not return after the session ended")
+	}
+	if marked := session.Marked(); len(marked) != 0 {
+		t.Fatalf("offsets %v marked for an unhandled message", marked)
+	}
+}
+
+func TestSaramaConsumer_ForgetsMessagesOfEndedSession(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := &fakeSession{ctx: ctx}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+	claim.messages <- &sarama.ConsumerMessage{Topic: "orders.placed", Offset: 3}
+	close(claim.messages)
+
+	messages := make(chan *Message, 1)
+	consumer := &SaramaConsumer{messages: messages, handler: newConsumerGroupHandler(messages), ctx: ctx, cancel: cancel}
+	if err := consumer.handler.ConsumeClaim(session, claim); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := consumer.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The partition was revoked before the message was handled
+	consumer.handler.Cleanup(session)
+	if err := consumer.CommitMessage(msg); err == nil {
+		t.Fatal("expected an error committing a message of an ended session")
+	}
+	if marked := session.Marked(); len(marked) != 0 {
+		t.Fatalf("offsets %v marked in an ended session", marked)
+	}
+}
@@ -0,0 +1,397 @@
+package messaging
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schema registry errors
+var (
+	ErrSchemaNotFound     = errors.New("event schema not found")
+	ErrSchemaValidation   = errors.New("event does not match schema")
+	ErrIncompatibleSchema = errors.New("event schema is not backward compatible")
+)
+
+// Event types with registered schemas
+const (
+	EventTypeOrderPlaced       = "order.placed"
+	EventTypeOrderEdited       = "order.edited"
+	EventTypeFulfillmentRouted = "fulfillment.routed"
+	EventTypeReturnReceived    = "return.received"
+	EventTypeReturnRefunded    = "return.refunded"
+
+	EventTypeDraftOrderUpdated          = "draft_order.updated"
+	EventTypeDraftOrderInvoiceSent      = "draft_order.invoice_sent"
+	EventTypeDraftOrderPaymentRequested = "draft_order.payment_requested"
+	EventTypeOrderCaptureRequested      = "order.capture_requested"
+	EventTypePaymentAuthorized          = "payment.authorized"
+	EventTypePaymentCaptured            = "payment.captured"
+	EventTypePaymentVoided              = "payment.voided"
+	EventTypePaymentFailed              = "payment.failed"
+	EventTypePaymentDisputed            = "payment.disputed"
+	EventTypeOrderDisputeEvidence       = "order.dispute_evidence"
+	EventTypeMerchantUpdated            = "merchant.updated"
+)
+
+//go:embed schemas/*.json
+var defaultSchemas embed.FS
+
+// schemaFilePattern matches schema files named <event_type>.v<version>.json
+var schemaFilePattern = regexp.MustCompile(`^(.+)\.v([0-9]+)\.json$`)
+
+// Schema is the subset of JSON Schema used to describe event payloads
+type Schema struct {
+	Type                 SchemaType         `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+}
+
+// SchemaType holds one or more JSON types, e.g. "string" or ["string", "null"]
+type SchemaType []string
+
+// UnmarshalJSON accepts both a single type name and a list of type names
+func (t *SchemaType) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = SchemaType{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("schema type must be a string or list of strings: %w", err)
+	}
+	*t = multiple
+	return nil
+}
+
+// SchemaRegistry holds versioned schemas for every event type and validates
+// envelopes against them
+type SchemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[string]map[int]*Schema
+}
+
+// NewSchemaRegistry creates an empty schema registry
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{schemas: make(map[string]map[int]*Schema)}
+}
+
+// DefaultSchemaRegistry returns a registry with the schemas shipped with this
+// package
+func DefaultSchemaRegistry() (*SchemaRegistry, error) {
+	sub, err := fs.Sub(defaultSchemas, "schemas")
+	if err != nil {
+		return nil, err
+	}
+	return NewSchemaRegistryFromFS(sub)
+}
+
+// LoadSchemaRegistry loads schemas from a directory on disk
+func LoadSchemaRegistry(dir string) (*SchemaRegistry, error) {
+	return NewSchemaRegistryFromFS(os.DirFS(dir))
+}
+
+// NewSchemaRegistryFromFS loads every <event_type>.v<version>.json file in
+// the root of fsys. Versions of an event type are registered in ascending
+// order so each one is checked for compatibility with its predecessor.
+func NewSchemaRegistryFromFS(fsys fs.FS) (*SchemaRegistry, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema directory: %w", err)
+	}
+
+	type schemaFile struct {
+		name      string
+		eventType string
+		version   int
+	}
+	var files []schemaFile
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := schemaFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[2])
+		files = append(files, schemaFile{name: entry.Name(), eventType: match[1], version: version})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].eventType != files[j].eventType {
+			return files[i].eventType < files[j].eventType
+		}
+		return files[i].version < files[j].version
+	})
+
+	registry := NewSchemaRegistry()
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema %s: %w", file.name, err)
+		}
+		var schema Schema
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return nil, fmt.Errorf("failed to parse schema %s: %w", file.name, err)
+		}
+		if err := registry.Register(file.eventType, file.version, &schema); err != nil {
+			return nil, fmt.Errorf("failed to register schema %s: %w", file.name, err)
+		}
+	}
+
+	return registry, nil
+}
+
+// Register adds a schema version for an event type. A new version must be
+// backward compatible with the previous one: it may not require fields the
+// previous version did not require, nor change the type of existing fields.
+func (r *SchemaRegistry) Register(eventType string, version int, schema *Schema) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	versions, ok := r.schemas[eventType]
+	if !ok {
+		versions = make(map[int]*Schema)
+		r.schemas[eventType] = versions
+	}
+
+	if previous, ok := versions[version-1]; ok {
+		if err := checkCompatibility(previous, schema, eventType); err != nil {
+			return fmt.Errorf("%w: %s v%d: %v", ErrIncompatibleSchema, eventType, version, err)
+		}
+	}
+
+	versions[version] = schema
+	return nil
+}
+
+// Schema returns the schema for an event type and version
+func (r *SchemaRegistry) Schema(eventType string, version int) (*Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schema, ok := r.schemas[eventType][version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s v%d", ErrSchemaNotFound, eventType, version)
+	}
+	return schema, nil
+}
+
+// LatestVersion returns the highest registered version of an event type
+func (r *SchemaRegistry) LatestVersion(eventType string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	latest := 0
+	for version := range r.schemas[eventType] {
+		if version > latest {
+			latest = version
+		}
+	}
+	if latest == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrSchemaNotFound, eventType)
+	}
+	return latest, nil
+}
+
+// Validate checks the envelope data against its registered schema
+func (r *SchemaRegistry) Validate(env *Envelope) error {
+	schema, err := r.Schema(env.EventType, env.Version)
+	if err != nil {
+		return err
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(env.Data, &data); err != nil {
+		return fmt.Errorf("%w: %s v%d: invalid JSON: %v", ErrSchemaValidation, env.EventType, env.Version, err)
+	}
+
+	if err := schema.validate(data, "data"); err != nil {
+		return fmt.Errorf("%w: %s v%d: %v", ErrSchemaValidation, env.EventType, env.Version, err)
+	}
+	return nil
+}
+
+// ValidatePayload parses a message payload as an envelope and validates it
+func (r *SchemaRegistry) ValidatePayload(payload []byte) (*Envelope, error) {
+	env, err := UnmarshalEnvelope(payload)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.Validate(env); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// validate checks a decoded JSON value against the schema
+func (s *Schema) validate(value interface{}, field string) error {
+	if len(s.Type) > 0 && !s.Type.matches(value) {
+		return fmt.Errorf("%s: expected %s, got %s", field, strings.Join(s.Type, " or "), jsonTypeOf(value))
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if allowed == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value %v is not one of %v", field, value, s.Enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s.%s: required field is missing", field, name)
+			}
+		}
+		for name, fieldValue := range v {
+			propSchema, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s.%s: unexpected field", field, name)
+				}
+				continue
+			}
+			if err := propSchema.validate(fieldValue, field+"."+name); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			return fmt.Errorf("%s: expected at least %d items, got %d", field, *s.MinItems, len(v))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return fmt.Errorf("%s: %v is less than minimum %v", field, v, *s.Minimum)
+		}
+	case string:
+		if err := validateFormat(s.Format, v); err != nil {
+			return fmt.Errorf("%s: %v", field, err)
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether the value has one of the schema types
+func (t SchemaType) matches(value interface{}) bool {
+	return t.accepts(jsonTypeOf(value))
+}
+
+// jsonTypeOf returns the JSON Schema type name of a decoded JSON value
+func jsonTypeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validateFormat checks the supported string formats
+func validateFormat(format, value string) error {
+	switch format {
+	case "uuid":
+		if !uuidPattern.MatchString(value) {
+			return fmt.Errorf("%q is not a valid uuid", value)
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return fmt.Errorf("%q is not a valid date-time", value)
+		}
+	}
+	return nil
+}
+
+// checkCompatibility verifies that data written with the previous schema can
+// still be read with the next one
+func checkCompatibility(previous, next *Schema, field string) error {
+	if len(previous.Type) > 0 && len(next.Type) > 0 {
+		for _, t := range previous.Type {
+			if !next.Type.accepts(t) {
+				return fmt.Errorf("%s: type changed from %s to %s", field,
+					strings.Join(previous.Type, " or "), strings.Join(next.Type, " or "))
+			}
+		}
+	}
+
+	previouslyRequired := make(map[string]bool, len(previous.Required))
+	for _, name := range previous.Required {
+		previouslyRequired[name] = true
+	}
+	for _, name := range next.Required {
+		if !previouslyRequired[name] {
+			return fmt.Errorf("%s.%s: new required field", field, name)
+		}
+	}
+
+	for name, prevProp := range previous.Properties {
+		if nextProp, ok := next.Properties[name]; ok {
+			if err := checkCompatibility(prevProp, nextProp, field+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if previous.Items != nil && next.Items != nil {
+		if err := checkCompatibility(previous.Items, next.Items, field+"[]"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// accepts reports whether values of the given type satisfy this schema type
+func (t SchemaType) accepts(typeName string) bool {
+	for _, allowed := range t {
+		if allowed == typeName || (allowed == "number" && typeName == "integer") {
+			return true
+		}
+	}
+	return false
+}
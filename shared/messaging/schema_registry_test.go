@@ -0,0 +1,101 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDefaultSchemaRegistry_ValidatesOrderPlaced(t *testing.T) {
+	registry, err := DefaultSchemaRegistry()
+	if err != nil {
+		t.Fatalf("failed to load default schemas: %v", err)
+	}
+
+	order := map[string]interface{}{
+		"id":           "7f1c1c3e-8f6a-4b7e-9d55-3d1f0b1f2a10",
+		"order_number": "ORD-20240101-0001",
+		"merchant_id":  "2b6d8a52-6a43-4c8e-a0c5-98a3b7e7d1f4",
+		"status":       "pending",
+		"currency":     "USD",
+		"total_price":  25.5,
+		"line_items": []map[string]interface{}{
+			{
+				"id":                 "0a4c9f2e-1f5e-4d8b-8a3c-5b2e7c9d1e0f",
+				"product_id":         "5e3b1a7c-2d4f-4e6a-9b8c-1d2e3f4a5b6c",
+				"product_variant_id": nil,
+				"sku":                "SKU-1",
+				"quantity":           2,
+				"price":              12.75,
+			},
+		},
+	}
+
+	env, err := NewEnvelope(context.Background(), EventTypeOrderPlaced, 1, "2b6d8a52-6a43-4c8e-a0c5-98a3b7e7d1f4", order)
+	if err != nil {
+		t.Fatalf("failed to create envelope: %v", err)
+	}
+	if err := registry.Validate(env); err != nil {
+		t.Fatalf("expected valid event, got %v", err)
+	}
+
+	order["line_items"] = []map[string]interface{}{{"sku": "SKU-1", "quantity": 0}}
+	env, _ = NewEnvelope(context.Background(), EventTypeOrderPlaced, 1, "", order)
+	if err := registry.Validate(env); !errors.Is(err, ErrSchemaValidation) {
+		t.Fatalf("expected schema validation error, got %v", err)
+	}
+
+	env.Version = 99
+	if err := registry.Validate(env); !errors.Is(err, ErrSchemaNotFound) {
+		t.Fatalf("expected schema not found error, got %v", err)
+	}
+}
+
+func TestSchemaRegistry_RejectsIncompatibleVersion(t *testing.T) {
+	registry := NewSchemaRegistry()
+	v1 := &Schema{
+		Type:     SchemaType{"object"},
+		Required: []string{"id"},
+		Properties: map[string]*Schema{
+			"id":    {Type: SchemaType{"string"}},
+			"total": {Type: SchemaType{"number"}},
+		},
+	}
+	if err := registry.Register("test.event", 1, v1); err != nil {
+		t.Fatalf("failed to register v1: %v", err)
+	}
+
+	addsRequired := &Schema{
+		Type:       SchemaType{"object"},
+		Required:   []string{"id", "total"},
+		Properties: v1.Properties,
+	}
+	if err := registry.Register("test.event", 2, addsRequired); !errors.Is(err, ErrIncompatibleSchema) {
+		t.Fatalf("expected incompatible schema error for new required field, got %v", err)
+	}
+
+	changesType := &Schema{
+		Type:     SchemaType{"object"},
+		Required: []string{"id"},
+		Properties: map[string]*Schema{
+			"id":    {Type: SchemaType{"string"}},
+			"total": {Type: SchemaType{"string"}},
+		},
+	}
+	if err := registry.Register("test.event", 2, changesType); !errors.Is(err, ErrIncompatibleSchema) {
+		t.Fatalf("expected incompatible schema error for changed type, got %v", err)
+	}
+
+	addsOptional := &Schema{
+		Type:     SchemaType{"object"},
+		Required: []string{"id"},
+		Properties: map[string]*Schema{
+			"id":    {Type: SchemaType{"string"}},
+			"total": {Type: SchemaType{"number"}},
+			"note":  {Type: SchemaType{"string", "null"}},
+		},
+	}
+	if err := registry.Register("test.event", 2, addsOptional); err != nil {
+		t.Fatalf("expected compatible schema, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package messaging
+
+import (
+	"fmt"
+)
+
+// ValidatingProducer validates event envelopes against the schema registry
+// before handing them to the wrapped producer
+type ValidatingProducer struct {
+	producer EventProducer
+	registry *SchemaRegistry
+}
+
+// NewValidatingProducer creates a producer that rejects invalid events
+func NewValidatingProducer(producer EventProducer, registry *SchemaRegistry) *ValidatingProducer {
+	return &ValidatingProducer{
+		producer: producer,
+		registry: registry,
+	}
+}
+
+// Publish validates the payload and publishes it if it matches its schema
+func (p *ValidatingProducer) Publish(topic, key string, payload []byte) error {
+	if _, err := p.registry.ValidatePayload(payload); err != nil {
+		return fmt.Errorf("refusing to publish invalid event to topic %s: %w", topic, err)
+	}
+	return p.producer.Publish(topic, key, payload)
+}
+
+// Close closes the wrapped producer
+func (p *ValidatingProducer) Close() error {
+	return p.producer.Close()
+}
+
+// Unwrap returns the wrapped producer
+func (p *ValidatingProducer) Unwrap() EventProducer {
+	return p.producer
+}
+
+// ValidatingHandler validates consumed envelopes against the schema registry
+// before handing them to the wrapped handler
+type ValidatingHandler struct {
+	handler  MessageHandler
+	registry *SchemaRegistry
+}
+
+// NewValidatingHandler creates a handler that rejects invalid events
+func NewValidatingHandler(handler MessageHandler, registry *SchemaRegistry) *ValidatingHandler {
+	return &ValidatingHandler{
+		handler:  handler,
+		registry: registry,
+	}
+}
+
+// HandleMessage validates the message and passes it on if it matches its schema
+func (h *ValidatingHandler) HandleMessage(msg *Message) error {
+	if _, err := h.registry.ValidatePayload(msg.Value); err != nil {
+		return fmt.Errorf("invalid event on topic %s: %w", msg.Topic, err)
+	}
+	return h.handler.HandleMessage(msg)
+}
+
+// NewEnvelopeOutboxMessage validates an envelope and wraps it in an outbox
+// message, so invalid events are rejected before they are stored
+func NewEnvelopeOutboxMessage(registry *SchemaRegistry, aggregateType, aggregateID, topic, key string, env *Envelope) (*OutboxMessage, error) {
+	if err := registry.Validate(env); err != nil {
+		return nil, err
+	}
+
+	payload, err := env.Marshal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s envelope: %w", env.EventType, err)
+	}
+
+	return NewOutboxMessage(aggregateType, aggregateID, topic, key, payload), nil
+}
+
+// isNoOpProducer reports whether the producer, or any producer it wraps,
+// discards events
+func isNoOpProducer(producer EventProducer) bool {
+	for producer != nil {
+		if _, ok := producer.(*NoOpProducer); ok {
+			return true
+		}
+		wrapper, ok := producer.(interface{ Unwrap() EventProducer })
+		if !ok {
+			return false
+		}
+		producer = wrapper.Unwrap()
+	}
+	return false
+}